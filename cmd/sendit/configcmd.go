@@ -0,0 +1,56 @@
+package main
+
+import (
+	"github.com/lewta/sendit/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// configCmd returns the cobra command for 'sendit config' and its
+// subcommands.
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect configuration",
+	}
+	cmd.AddCommand(configDumpCmd())
+	return cmd
+}
+
+func configDumpCmd() *cobra.Command {
+	var (
+		cfgPath     string
+		profile     string
+		showSecrets bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dump",
+		Short: "Print the effective configuration as YAML",
+		Long: `Load and validate a config exactly as 'sendit start' would, then print
+the fully-resolved result as YAML: built-in defaults applied, include
+fragments and the --profile overlay merged, targets_file entries and URL
+patterns expanded, and ${VAR} and valueFrom references substituted. When
+a kv backend is configured its current targets and rate limits are
+overlaid too.
+
+Literal credentials (auth tokens, passwords, API keys, and headers such as
+Authorization) are printed as <redacted> unless --show-secrets is given.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lc, err := loadConfig(cmd.Context(), cfgPath, profile)
+			if err != nil {
+				return err
+			}
+			out, err := config.Marshal(lc.cfg, showSecrets)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(out)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVarP(&cfgPath, "config", "c", "config/example.yaml", "Path or http(s)://, s3:// URL of the YAML config file")
+	cmd.Flags().StringVar(&profile, "profile", "", "Apply the named overlay from the config's profiles section")
+	cmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "Print credentials instead of <redacted>")
+	return cmd
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+// loadedConfig is a --config as loaded by loadConfig, with the sources that
+// 'sendit start' keeps polling for changes.
+type loadedConfig struct {
+	cfg    *config.Config       // base with the kv entries overlaid
+	base   *config.Config       // as loaded from --config
+	remote *config.RemoteSource // nil for a local file
+	kv     *config.KVSource     // nil without a kv backend
+}
+
+// loadConfig loads the config at path, a local file or an http(s):// or
+// s3:// URL, with the named profile applied, and overlays the current
+// entries of its kv backend when it has one.
+func loadConfig(ctx context.Context, path, profile string) (loadedConfig, error) {
+	var (
+		lc  loadedConfig
+		err error
+	)
+	if config.IsRemote(path) {
+		if lc.remote, err = config.NewRemoteSource(path, profile); err != nil {
+			return loadedConfig{}, err
+		}
+		lc.base, _, err = lc.remote.Load(ctx)
+	} else {
+		lc.base, err = config.LoadProfile(path, profile)
+	}
+	if err != nil {
+		return loadedConfig{}, err
+	}
+
+	lc.cfg = lc.base
+	if lc.base.KV.Type != "" {
+		if lc.kv, err = config.NewKVSource(lc.base.KV); err != nil {
+			return loadedConfig{}, err
+		}
+		if _, err := lc.kv.Refresh(ctx); err != nil {
+			return loadedConfig{}, fmt.Errorf("kv: %w", err)
+		}
+		if lc.cfg, err = lc.kv.Apply(lc.base); err != nil {
+			return loadedConfig{}, fmt.Errorf("kv: %w", err)
+		}
+	}
+	return lc, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// daemonChildEnv is set in the environment of the process started by a
+// detaching 'sendit start', so that it runs the engine instead of
+// detaching again.
+const daemonChildEnv = "SENDIT_DAEMON_CHILD"
+
+// daemonStartTimeout bounds how long the parent waits for the detached
+// child to write its PID file.
+const daemonStartTimeout = 10 * time.Second
+
+// isDaemonChild reports whether this process was started by detach.
+func isDaemonChild() bool {
+	return os.Getenv(daemonChildEnv) == "1"
+}
+
+// detach starts this command again as a background process in its own
+// session, with stdin closed and stdout/stderr appended to d.LogFile, then
+// waits until the child has written its PID file. Output from the child
+// before its logger is set up (for example a config error) therefore ends
+// up in the log file too, and is echoed here when the child exits early.
+func detach(cmd *cobra.Command, d config.DaemonConfig) error {
+	cmd.SilenceUsage = true
+	if info, err := readPIDInfo(d.PIDFile); err == nil && processAlive(info.PID) {
+		return fmt.Errorf("sendit is already running (pid %d, PID file %s); stop it first or use --foreground", info.PID, d.PIDFile)
+	}
+	if d.LogFile == "" {
+		return errors.New("daemon.log_file is empty; set it or use --foreground")
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locating the sendit executable: %w", err)
+	}
+	logFile, err := os.OpenFile(d.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	defer logFile.Close()
+	startOffset, _ := logFile.Seek(0, io.SeekEnd)
+
+	child := exec.Command(exe, os.Args[1:]...) //nolint:gosec // re-executes this binary with its own arguments
+	child.Env = append(os.Environ(), daemonChildEnv+"=1")
+	child.Stdout = logFile
+	child.Stderr = logFile
+	child.SysProcAttr = detachAttr()
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("starting background process: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+	deadline := time.After(daemonStartTimeout)
+	for {
+		select {
+		case err := <-exited:
+			if tail := logTail(d.LogFile, startOffset, 10); tail != "" {
+				fmt.Fprint(cmd.ErrOrStderr(), tail)
+			}
+			return fmt.Errorf("sendit exited during startup (%v); see %s", err, d.LogFile)
+		case <-deadline:
+			fmt.Fprintf(cmd.ErrOrStderr(), "warning: pid %d has not written %s after %s; check %s\n",
+				child.Process.Pid, d.PIDFile, daemonStartTimeout, d.LogFile)
+			return nil
+		case <-time.After(50 * time.Millisecond):
+			if info, err := readPIDInfo(d.PIDFile); err == nil && info.PID == child.Process.Pid {
+				out := cmd.OutOrStdout()
+				fmt.Fprintf(out, "Started sendit in the background (pid %d)\n", info.PID)
+				fmt.Fprintf(out, "Logs: %s\n", d.LogFile)
+				fmt.Fprintln(out, "Stop with: sendit stop")
+				return nil
+			}
+		}
+	}
+}
+
+// logTail returns up to n trailing lines written to path after offset.
+func logTail(path string, offset int64, n int) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return ""
+	}
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// pidInfo is the content of a PID file: the process ID on the first line
+// and, from files written by this version on, the start time on the second.
+type pidInfo struct {
+	PID     int
+	Started time.Time // zero when the file has no start time
+}
+
+func writePID(path string) error {
+	data := fmt.Sprintf("%d\n%s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+	return os.WriteFile(path, []byte(data), 0o600)
+}
+
+func readPIDInfo(path string) (pidInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pidInfo{}, err
+	}
+	pidLine, rest, _ := strings.Cut(string(data), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(pidLine))
+	if err != nil {
+		return pidInfo{}, err
+	}
+	info := pidInfo{PID: pid}
+	if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(rest)); err == nil {
+		info.Started = ts
+	}
+	return info, nil
+}
+
+func readPID(path string) (int, error) {
+	info, err := readPIDInfo(path)
+	return info.PID, err
+}
+
+// processAlive reports whether pid refers to a running process.
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 checks if the process is alive without killing it.
+	return proc.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// detachAttr starts the child in a new session so it has no controlling
+// terminal and does not receive the terminal's SIGHUP or SIGINT.
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package main
+
+import "syscall"
+
+// detachedProcess is DETACHED_PROCESS from the Windows API: the child gets
+// no console.
+const detachedProcess = 0x00000008
+
+// detachAttr starts the child without a console, in its own process group
+// so that Ctrl-C in the parent's console does not reach it.
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
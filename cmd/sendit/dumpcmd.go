@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lewta/sendit/internal/control"
+	"github.com/lewta/sendit/internal/engine"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// dumpCmd returns the cobra command for 'sendit dump'.
+func dumpCmd() *cobra.Command {
+	var (
+		socket  string
+		jsonOut bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dump",
+		Short: "Log and print a snapshot of a running daemon's internal state",
+		Long: `Ask a running 'sendit start' over its control socket (daemon.control_socket)
+to write a state dump to its log, and print the same snapshot here: goroutine
+count, worker pool occupancy, pacing and scheduled-window state, each
+domain's current rate limit, wait totals, and backoff, and the targets with
+the most errors. Use it when a run is alive but slower than expected.
+
+Sending SIGUSR2 to the process writes the same dump to its log, for when the
+control socket is disabled (not available on Windows):
+
+  kill -USR2 $(head -1 /tmp/sendit.pid)`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := control.NewClient(socket).Dump(cmd.Context())
+			if err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if jsonOut {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				return enc.Encode(d)
+			}
+			writeDump(out, d)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&socket, "socket", defaultControlSocket, "Path to the daemon's control socket")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Print the snapshot as JSON")
+	return cmd
+}
+
+// writeDump renders d as the status --full report followed by the pool,
+// per-domain, and per-target sections of the dump.
+func writeDump(out io.Writer, d control.Dump) {
+	fmt.Fprintf(out, "State dump written to the daemon's log at %s\n\n", d.Time.Local().Format(time.RFC3339))
+	writeFullStatus(out, d.Status)
+
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Goroutines:\t%d\n", d.Goroutines)
+	fmt.Fprintf(tw, "Workers:\t%d/%d in use (browser %d/%d)\n", d.Workers, d.MaxWorkers, d.BrowserWorkers, d.MaxBrowserWorkers)
+	_ = tw.Flush()
+
+	if len(d.Domains) > 0 {
+		fmt.Fprintln(out, "\nDomains (rate limit, held by rate limit / backoff):")
+		tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, dd := range d.Domains {
+			limit := fmt.Sprintf("%.3g rps", dd.LimitRPS)
+			if dd.LimitRPS == 0 {
+				limit = "budget exhausted"
+			}
+			line := fmt.Sprintf("  %s\t%s\t%s / %s", dd.Domain, limit, seconds(dd.RateLimitS), seconds(dd.BackoffS))
+			if b := dd.Backoff; b != nil {
+				line += fmt.Sprintf("\tbackoff attempt %d/%d", b.Attempts, b.MaxAttempts)
+				if b.Cooldown {
+					line += ", in cooldown"
+				}
+			}
+			fmt.Fprintln(tw, line)
+		}
+		_ = tw.Flush()
+	}
+
+	if len(d.TopErrors) > 0 {
+		fmt.Fprintln(out, "\nMost errors:")
+		tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, t := range d.TopErrors {
+			fmt.Fprintf(tw, "  %s\t%d of %d\tlast status %d\tavg %.0f ms\n", t.URL, t.Errors, t.Requests, t.LastStatus, t.AvgMs)
+		}
+		_ = tw.Flush()
+	}
+}
+
+// dumpOnSignal writes a state dump of eng to the log whenever one of
+// dumpSignals arrives, until ctx is cancelled.
+func dumpOnSignal(ctx context.Context, eng *engine.Engine) {
+	if len(dumpSignals) == 0 {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, dumpSignals...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				log.Info().Str("signal", sig.String()).Msg("dumping state")
+				eng.LogDump()
+			}
+		}
+	}()
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// dumpSignals make a running 'sendit start' log a state dump.
+var dumpSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// dumpSignals is empty: Windows has no SIGUSR2, so state dumps are only
+// available through the control socket.
+var dumpSignals []os.Signal
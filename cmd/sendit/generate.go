@@ -165,11 +165,13 @@ func targetsFromFile(path string) ([]config.TargetConfig, error) {
 		if !validTypes[typ] {
 			return nil, fmt.Errorf("line %d: unknown type %q (must be http|browser|dns|websocket)", lineNum, typ)
 		}
-		weight := 1
-		if len(fields) >= 3 {
-			w, err := strconv.Atoi(fields[2])
+		weight := 1.0
+		// Trailing key=value overrides are accepted but not carried into
+		// the generated config.
+		if len(fields) >= 3 && !strings.Contains(fields[2], "=") {
+			w, err := strconv.ParseFloat(fields[2], 64)
 			if err != nil || w <= 0 {
-				return nil, fmt.Errorf("line %d: invalid weight %q (must be a positive integer)", lineNum, fields[2])
+				return nil, fmt.Errorf("line %d: invalid weight %q (must be a positive number)", lineNum, fields[2])
 			}
 			weight = w
 		}
@@ -608,7 +610,7 @@ func historyFromSQLite(dbPath, query string, limit int) ([]config.TargetConfig,
 		if weight < 1 {
 			weight = 1
 		}
-		targets = append(targets, defaultTarget(u, "http", weight))
+		targets = append(targets, defaultTarget(u, "http", float64(weight)))
 	}
 	return targets, rows.Err()
 }
@@ -860,13 +862,8 @@ func emitGeneratedConfig(cmd *cobra.Command, targets []config.TargetConfig, outP
 	w := cmd.OutOrStdout()
 
 	if outPath != "" {
-		if _, err := os.Stat(outPath); err == nil {
-			fmt.Fprintf(cmd.ErrOrStderr(), "File %q already exists. Overwrite? [y/N] ", outPath)
-			var answer string
-			fmt.Fscan(cmd.InOrStdin(), &answer) //nolint:errcheck,gosec
-			if !strings.EqualFold(strings.TrimSpace(answer), "y") {
-				return fmt.Errorf("aborted")
-			}
+		if err := confirmOverwrite(cmd, outPath, false); err != nil {
+			return err
 		}
 		f, err := os.Create(outPath)
 		if err != nil {
@@ -929,7 +926,7 @@ func formatConfig(w io.Writer, targets []config.TargetConfig) {
 func formatTarget(w io.Writer, t config.TargetConfig) {
 	fmt.Fprintln(w)
 	fmt.Fprintf(w, "  - url: %q\n", t.URL)
-	fmt.Fprintf(w, "    weight: %d\n", t.Weight)
+	fmt.Fprintf(w, "    weight: %g\n", t.Weight)
 	fmt.Fprintf(w, "    type: %s\n", t.Type)
 	switch t.Type {
 	case "http", "browser":
@@ -962,7 +959,7 @@ func formatTarget(w io.Writer, t config.TargetConfig) {
 // --- helpers ---
 
 // defaultTarget constructs a TargetConfig with sensible driver defaults.
-func defaultTarget(u, typ string, weight int) config.TargetConfig {
+func defaultTarget(u, typ string, weight float64) config.TargetConfig {
 	return config.TargetConfig{
 		URL:    u,
 		Weight: weight,
@@ -466,7 +466,7 @@ func TestHistoryFromSQLite_Chrome(t *testing.T) {
 		t.Errorf("expected example.com first, got %q", targets[0].URL)
 	}
 	if targets[0].Weight != 10 {
-		t.Errorf("expected weight 10 (capped), got %d", targets[0].Weight)
+		t.Errorf("expected weight 10 (capped), got %v", targets[0].Weight)
 	}
 	if targets[1].URL != "https://go.dev/doc" {
 		t.Errorf("expected go.dev second, got %q", targets[1].URL)
@@ -522,7 +522,7 @@ func TestHistoryFromSQLite_WeightCappedAt10(t *testing.T) {
 		t.Fatalf("expected 1 target, got %d", len(targets))
 	}
 	if targets[0].Weight != 10 {
-		t.Errorf("expected weight capped at 10, got %d", targets[0].Weight)
+		t.Errorf("expected weight capped at 10, got %v", targets[0].Weight)
 	}
 }
 
@@ -657,7 +657,7 @@ func TestSafariBookmarks_HappyPath(t *testing.T) {
 			t.Errorf("expected type http, got %q for %s", tgt.Type, tgt.URL)
 		}
 		if tgt.Weight != 1 {
-			t.Errorf("expected weight 1, got %d for %s", tgt.Weight, tgt.URL)
+			t.Errorf("expected weight 1, got %v for %s", tgt.Weight, tgt.URL)
 		}
 	}
 	if !urls["https://example.com"] {
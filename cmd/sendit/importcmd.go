@@ -0,0 +1,390 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	maxToplistBytes   = 256 << 20
+	toplistFetchLimit = 2 * time.Minute
+)
+
+// importCmd returns the cobra command for 'sendit import'.
+func importCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Convert third-party lists into sendit targets files",
+		Long: `Convert lists from elsewhere into targets files for targets_file.
+
+Subcommands:
+  toplist   a popularity-ranked domain list (Tranco, Alexa, Umbrella, ...)`,
+	}
+	cmd.AddCommand(importToplistCmd())
+	return cmd
+}
+
+// toplistOptions controls how a ranked list becomes targets.
+type toplistOptions struct {
+	count     int
+	weighting string // zipf | log | linear | equal
+	exponent  float64
+	mix       []typeShare
+	scheme    string
+}
+
+// typeShare is one entry of --mix: a target type and its relative share.
+type typeShare struct {
+	typ   string
+	share float64
+}
+
+// rankedDomain is one entry of a toplist.
+type rankedDomain struct {
+	rank   int
+	domain string
+}
+
+func importToplistCmd() *cobra.Command {
+	var (
+		opts    toplistOptions
+		mix     string
+		outPath string
+		force   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "toplist <file|url>",
+		Short: "Write a weighted targets file from a ranked domain list",
+		Long: `Read a popularity-ranked domain list and write the top --count domains as a
+plain-text targets file, weighted by rank so that popular sites are picked
+more often — the shape of real browsing that decoy traffic should follow.
+
+The list may be a local file or an http(s):// URL, plain, gzipped, or a zip
+archive (the first file in it is read). Lines are either "rank,domain", as
+in Tranco, Alexa, and Cisco Umbrella exports, or a bare domain per line,
+ranked by position. Blank lines, '#' comments, and a header row are skipped.
+
+Rank-to-weight conversion (--weighting), for the r-th of the N domains kept:
+  zipf     (N/r)^s, with s from --exponent (default). Popularity on the web
+           follows Zipf's law closely, so this is the realistic choice.
+  log      1 + ln(N/r): popular sites favoured, long tail still visited
+  linear   N - r + 1
+  equal    1 for every domain
+
+The least popular domain kept always gets weight 1. --mix sets the target
+types written for each domain and their share of its weight, e.g.
+"http=3,dns=1" writes an http and a dns line per domain and sends three
+quarters of picks to http.
+
+Examples:
+  sendit import toplist tranco.csv --count 1000 --output config/targets.txt
+  sendit import toplist https://tranco-list.eu/top-1m.csv.zip --count 5000 --mix http=3,dns=1
+  sendit import toplist domains.txt --weighting log --scheme http`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if opts.mix, err = parseTypeMix(mix); err != nil {
+				return fmt.Errorf("--mix: %w", err)
+			}
+			if opts.count < 1 {
+				return fmt.Errorf("--count must be at least 1")
+			}
+			if opts.scheme != "http" && opts.scheme != "https" {
+				return fmt.Errorf("--scheme must be http or https, got %q", opts.scheme)
+			}
+			switch opts.weighting {
+			case "zipf", "log", "linear", "equal":
+			default:
+				return fmt.Errorf("--weighting must be one of zipf|log|linear|equal, got %q", opts.weighting)
+			}
+			if opts.exponent <= 0 {
+				return fmt.Errorf("--exponent must be > 0")
+			}
+
+			data, err := readToplist(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			domains, err := parseToplist(data, opts.count)
+			if err != nil {
+				return fmt.Errorf("reading %q: %w", args[0], err)
+			}
+			if len(domains) == 0 {
+				return fmt.Errorf("%q: no domains found", args[0])
+			}
+
+			write := func(w io.Writer) { writeToplistTargets(w, args[0], domains, opts) }
+			if outPath == "" {
+				write(cmd.OutOrStdout())
+				return nil
+			}
+			if err := confirmOverwrite(cmd, outPath, force); err != nil {
+				return err
+			}
+			if err := writeInitFile(outPath, write); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d domain(s) to %q\n", len(domains), outPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&opts.count, "count", 1000, "Number of top-ranked domains to keep")
+	cmd.Flags().StringVar(&opts.weighting, "weighting", "zipf", "Rank-to-weight conversion: zipf|log|linear|equal")
+	cmd.Flags().Float64Var(&opts.exponent, "exponent", 1.0, "Zipf exponent s for --weighting zipf")
+	cmd.Flags().StringVar(&mix, "mix", "http=1", "Target types per domain and their share of its weight (http, dns, browser)")
+	cmd.Flags().StringVar(&opts.scheme, "scheme", "https", "URL scheme for http and browser targets: http|https")
+	cmd.Flags().StringVarP(&outPath, "output", "o", "", "Write the targets file here instead of stdout")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite --output without asking")
+	return cmd
+}
+
+// parseTypeMix parses "http=3,dns=1". A type without "=share" has share 1.
+func parseTypeMix(s string) ([]typeShare, error) {
+	valid := map[string]bool{"http": true, "dns": true, "browser": true}
+	var out []typeShare
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		typ, val, hasShare := strings.Cut(part, "=")
+		typ = strings.ToLower(strings.TrimSpace(typ))
+		if !valid[typ] {
+			return nil, fmt.Errorf("unknown type %q (must be http|dns|browser)", typ)
+		}
+		if seen[typ] {
+			return nil, fmt.Errorf("type %q listed twice", typ)
+		}
+		seen[typ] = true
+		share := 1.0
+		if hasShare {
+			f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil || f <= 0 {
+				return nil, fmt.Errorf("invalid share %q for %s (must be a positive number)", val, typ)
+			}
+			share = f
+		}
+		out = append(out, typeShare{typ: typ, share: share})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("at least one type is required")
+	}
+	return out, nil
+}
+
+// readToplist returns the contents of a local or remote list, decompressing
+// gzip and zip (first file) archives by their magic bytes.
+func readToplist(ctx context.Context, src string) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		data, err = fetchToplist(ctx, src)
+	} else {
+		data, err = os.ReadFile(src)
+		if err != nil {
+			err = fmt.Errorf("opening %q: %w", src, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("%q: reading zip: %w", src, err)
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("%q: opening %s: %w", src, f.Name, err)
+			}
+			defer rc.Close() //nolint:errcheck
+			return readLimited(rc, src)
+		}
+		return nil, fmt.Errorf("%q: zip archive is empty", src)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%q: reading gzip: %w", src, err)
+		}
+		defer gz.Close() //nolint:errcheck
+		return readLimited(gz, src)
+	}
+	return data, nil
+}
+
+func fetchToplist(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, toplistFetchLimit)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %q: %w", url, err)
+	}
+	req.Header.Set("User-Agent", generateUserAgent)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %q: %w", url, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %q: HTTP %d", url, resp.StatusCode)
+	}
+	return readLimited(resp.Body, url)
+}
+
+func readLimited(r io.Reader, src string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxToplistBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", src, err)
+	}
+	if len(data) > maxToplistBytes {
+		return nil, fmt.Errorf("%q exceeds %d MiB", src, maxToplistBytes>>20)
+	}
+	return data, nil
+}
+
+// parseToplist returns the count best-ranked domains in data, best first.
+// Lines are "rank,domain" or a bare domain ranked by its position; a domain
+// listed more than once keeps its best rank.
+func parseToplist(data []byte, count int) ([]rankedDomain, error) {
+	best := map[string]int{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNum, pos := 0, 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pos++
+		rank := pos
+		domain := line
+		if first, rest, ok := strings.Cut(line, ","); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(first))
+			if err != nil {
+				if pos == 1 {
+					pos = 0 // header row
+					continue
+				}
+				return nil, fmt.Errorf("line %d: invalid rank %q", lineNum, first)
+			}
+			rank = n
+			domain, _, _ = strings.Cut(rest, ",")
+		}
+		domain = normalizeDomain(domain)
+		if domain == "" {
+			return nil, fmt.Errorf("line %d: no domain in %q", lineNum, line)
+		}
+		if r, ok := best[domain]; !ok || rank < r {
+			best[domain] = rank
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]rankedDomain, 0, len(best))
+	for d, r := range best {
+		out = append(out, rankedDomain{rank: r, domain: d})
+	}
+	slices.SortFunc(out, func(a, b rankedDomain) int {
+		if a.rank != b.rank {
+			return a.rank - b.rank
+		}
+		return strings.Compare(a.domain, b.domain)
+	})
+	if len(out) > count {
+		out = out[:count]
+	}
+	return out, nil
+}
+
+// normalizeDomain lowercases d and strips any scheme, path, port, and
+// trailing dot, so that lists of URLs work too.
+func normalizeDomain(d string) string {
+	d = strings.ToLower(strings.TrimSpace(d))
+	if _, rest, ok := strings.Cut(d, "://"); ok {
+		d = rest
+	}
+	d, _, _ = strings.Cut(d, "/")
+	if host, _, ok := strings.Cut(d, ":"); ok {
+		d = host
+	}
+	return strings.TrimSuffix(d, ".")
+}
+
+// toplistWeight converts the position i (0-based) of a domain among n kept
+// into a weight; the last one always gets 1. Position rather than list rank
+// is used, so gaps left by duplicates or a partial list do not skew it.
+func toplistWeight(i, n int, opts toplistOptions) float64 {
+	r, top := float64(i+1), float64(n)
+	switch opts.weighting {
+	case "log":
+		return 1 + math.Log(top/r)
+	case "linear":
+		return top - r + 1
+	case "equal":
+		return 1
+	default:
+		return math.Pow(top/r, opts.exponent)
+	}
+}
+
+// formatWeight rounds w to four significant digits without an exponent.
+func formatWeight(w float64) string {
+	w, _ = strconv.ParseFloat(strconv.FormatFloat(w, 'g', 4, 64), 64)
+	return strconv.FormatFloat(w, 'f', -1, 64)
+}
+
+// writeToplistTargets writes domains as a plain-text targets file.
+func writeToplistTargets(w io.Writer, src string, domains []rankedDomain, opts toplistOptions) {
+	total := 0.0
+	var mix []string
+	for _, m := range opts.mix {
+		total += m.share
+		mix = append(mix, fmt.Sprintf("%s=%g", m.typ, m.share))
+	}
+	fmt.Fprintf(w, "# Generated by sendit import toplist on %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "# Source: %s\n", src)
+	weighting := opts.weighting
+	if weighting == "zipf" {
+		weighting = fmt.Sprintf("zipf (s=%g)", opts.exponent)
+	}
+	fmt.Fprintf(w, "# Top %d domains, weighting %s, mix %s\n", len(domains), weighting, strings.Join(mix, ","))
+	fmt.Fprintln(w, "# Use with: targets_file: <this file>")
+
+	for i, d := range domains {
+		weight := toplistWeight(i, len(domains), opts)
+		for _, m := range opts.mix {
+			url := d.domain
+			if m.typ != "dns" {
+				url = opts.scheme + "://" + d.domain + "/"
+			}
+			fmt.Fprintf(w, "%s %s %s\n", url, m.typ, formatWeight(weight*m.share/total))
+		}
+	}
+}
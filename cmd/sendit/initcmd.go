@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/spf13/cobra"
+)
+
+// initAnswers holds everything sendit init needs to write a starter config.
+type initAnswers struct {
+	targets []string
+	mode    string
+	rpm     float64
+	metrics bool
+
+	// memoryMB is limits.memory_threshold_mb; see initMemoryThresholdMB.
+	memoryMB uint64
+}
+
+// initCmd returns the cobra command for 'sendit init'.
+func initCmd() *cobra.Command {
+	var (
+		output      string
+		targetsPath string
+		urls        []string
+		mode        string
+		rpm         float64
+		metricsOn   bool
+		yes         bool
+		force       bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a starter config.yaml and targets file",
+		Long: `Write a small, valid starter config plus a targets file next to it.
+
+init asks a few questions — target URLs, pacing mode, the overall request
+rate, and whether to expose Prometheus metrics — and fills in the rest with
+defaults that work out of the box (for example, the per-domain rate limit is
+raised so that it does not cap the rate you asked for). Any question can be
+answered up front with a flag; --yes skips the remaining questions and uses
+the defaults shown in brackets.
+
+The targets file uses the plain-text format (url type [weight] per line) and
+is referenced from the config via targets_file, so targets can be edited
+without touching the YAML. The result is validated before init exits.
+
+Examples:
+  sendit init
+  sendit init --url https://example.com --url example.com --mode rate_limited --rpm 60 --yes
+  sendit init --output config/staging.yaml --metrics --yes`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if targetsPath == "" {
+				targetsPath = filepath.Join(filepath.Dir(output), "targets.txt")
+			}
+
+			// Ask about existing files first so nobody answers every
+			// question only to abort at the end.
+			for _, p := range []string{output, targetsPath} {
+				if err := confirmOverwrite(cmd, p, force); err != nil {
+					return err
+				}
+			}
+
+			ans := initAnswers{targets: urls, mode: mode, rpm: rpm, metrics: metricsOn}
+			if !yes {
+				if err := askInit(cmd, &ans); err != nil {
+					return err
+				}
+			}
+			if err := ans.fill(); err != nil {
+				return err
+			}
+			ans.memoryMB = initMemoryThresholdMB()
+			if err := writeInitFile(targetsPath, func(w io.Writer) { formatInitTargets(w, ans.targets) }); err != nil {
+				return err
+			}
+			if err := writeInitFile(output, func(w io.Writer) { formatInitConfig(w, ans, targetsPath) }); err != nil {
+				return err
+			}
+
+			if _, err := config.Load(output); err != nil {
+				return fmt.Errorf("generated config does not validate (please report this): %w", err)
+			}
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Wrote %s and %s (%d target(s))\n", output, targetsPath, len(ans.targets))
+			fmt.Fprintf(out, "Next: sendit start --config %s --dry-run\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "config.yaml", "Config file to write")
+	cmd.Flags().StringVar(&targetsPath, "targets-file", "", "Targets file to write (default: targets.txt next to --output)")
+	cmd.Flags().StringSliceVar(&urls, "url", nil, "Target URL or DNS hostname; repeat or comma-separate for several")
+	cmd.Flags().StringVar(&mode, "mode", "", "Pacing mode: human | rate_limited (default human)")
+	cmd.Flags().Float64Var(&rpm, "rpm", 0, "Overall requests per minute for rate_limited mode (default 30)")
+	cmd.Flags().BoolVar(&metricsOn, "metrics", false, "Enable the Prometheus metrics endpoint on :9090")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Do not ask questions; use flags and defaults")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing files without asking")
+	return cmd
+}
+
+// askInit prompts on cmd's stdin for every answer not already given by a
+// flag. An empty reply (or EOF) keeps the default shown in brackets.
+func askInit(cmd *cobra.Command, ans *initAnswers) error {
+	in := bufio.NewReader(cmd.InOrStdin())
+	w := cmd.ErrOrStderr()
+	ask := func(question, def string) string {
+		fmt.Fprintf(w, "%s [%s]: ", question, def)
+		line, _ := in.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+		return def
+	}
+
+	if !cmd.Flags().Changed("url") {
+		reply := ask("Target URLs or DNS hostnames (space or comma separated)", "https://example.com")
+		ans.targets = strings.FieldsFunc(reply, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	if !cmd.Flags().Changed("mode") {
+		ans.mode = ask("Pacing mode: human (random think-time) or rate_limited (steady rate)", "human")
+	}
+	if ans.mode == "rate_limited" && !cmd.Flags().Changed("rpm") {
+		reply := ask("Overall requests per minute", "30")
+		v, err := strconv.ParseFloat(reply, 64)
+		if err != nil {
+			return fmt.Errorf("requests per minute %q is not a number", reply)
+		}
+		ans.rpm = v
+	}
+	if !cmd.Flags().Changed("metrics") {
+		reply := ask("Expose Prometheus metrics on :9090? (y/n)", "n")
+		ans.metrics = strings.EqualFold(reply, "y") || strings.EqualFold(reply, "yes")
+	}
+	return nil
+}
+
+// fill applies defaults and checks the answers.
+func (a *initAnswers) fill() error {
+	if len(a.targets) == 0 {
+		a.targets = []string{"https://example.com"}
+	}
+	for _, t := range a.targets {
+		if typ := detectProbeType(t); typ == "dns" && strings.Contains(t, "/") {
+			return fmt.Errorf("target %q is neither a URL (http://, https://, ws://, wss://) nor a bare hostname", t)
+		}
+	}
+	switch a.mode {
+	case "":
+		a.mode = "human"
+	case "human", "rate_limited":
+	default:
+		return fmt.Errorf("mode must be human or rate_limited, got %q", a.mode)
+	}
+	if a.rpm == 0 {
+		a.rpm = 30
+	}
+	if a.rpm < 0 {
+		return fmt.Errorf("requests per minute must be positive, got %g", a.rpm)
+	}
+	return nil
+}
+
+// initMemoryThresholdMB returns 90% of this machine's RAM. The threshold is
+// compared against memory in use across the whole host, so the built-in
+// 512 MB default would pause dispatch on almost any workstation.
+func initMemoryThresholdMB() uint64 {
+	vm, err := mem.VirtualMemory()
+	if err != nil || vm.Total == 0 {
+		return 4096
+	}
+	return vm.Total / (1024 * 1024) * 9 / 10
+}
+
+// confirmOverwrite asks before replacing an existing file at path unless
+// force is set.
+func confirmOverwrite(cmd *cobra.Command, path string, force bool) error {
+	if _, err := os.Stat(path); err != nil || force {
+		return nil
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "File %q already exists. Overwrite? [y/N] ", path)
+	var answer string
+	fmt.Fscan(cmd.InOrStdin(), &answer) //nolint:errcheck,gosec
+	if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+		return fmt.Errorf("aborted")
+	}
+	return nil
+}
+
+func writeInitFile(path string, write func(io.Writer)) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating %q: %w", dir, err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %q: %w", path, err)
+	}
+	bw := bufio.NewWriter(f)
+	write(bw)
+	if err := bw.Flush(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %q: %w", path, err)
+	}
+	return f.Close()
+}
+
+// formatInitTargets writes the plain-text targets file, one aligned
+// "url type weight" row per target.
+func formatInitTargets(w io.Writer, targets []string) {
+	fmt.Fprintln(w, "# Targets for sendit — one per line: <url> <type> [weight]")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "#   type    http | browser | dns | websocket | grpc | sftp")
+	fmt.Fprintln(w, "#   weight  relative share of requests (default 1); share=25% fixes a percentage")
+	fmt.Fprintln(w, "#")
+	fmt.Fprintln(w, "# Per-target overrides go after the weight, e.g. method=POST timeout_s=5.")
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, t := range targets {
+		fmt.Fprintf(tw, "%s\t%s\t1\n", t, detectProbeType(t))
+	}
+	_ = tw.Flush()
+}
+
+// formatInitConfig writes the starter config YAML.
+func formatInitConfig(w io.Writer, a initAnswers, targetsPath string) {
+	fmt.Fprintf(w, "# Generated by sendit init on %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintln(w, "# See config/example.yaml or the docs for every option.")
+	fmt.Fprintln(w, "# Run 'sendit start --config <file> --dry-run' to preview before sending traffic.")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "targets_file: %q\n", targetsPath)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "# Fields applied to every target in targets_file.")
+	fmt.Fprintln(w, "target_defaults:")
+	fmt.Fprintln(w, "  weight: 1")
+	fmt.Fprintln(w, "  http:")
+	fmt.Fprintln(w, "    method: GET")
+	fmt.Fprintln(w, "    timeout_s: 15")
+	fmt.Fprintln(w, "  dns:")
+	fmt.Fprintln(w, "    resolver: \"8.8.8.8:53\"")
+	fmt.Fprintln(w, "    record_type: A")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "pacing:")
+	fmt.Fprintf(w, "  mode: %s\n", a.mode)
+	// The per-domain limiter must allow at least the overall rate, or a
+	// single-domain target list would be capped well below what was asked for.
+	domainRPS := 0.5
+	if a.mode == "rate_limited" {
+		fmt.Fprintf(w, "  requests_per_minute: %g\n", a.rpm)
+		domainRPS = max(domainRPS, a.rpm/60)
+	} else {
+		fmt.Fprintln(w, "  min_delay_ms: 800   # random think-time between requests")
+		fmt.Fprintln(w, "  max_delay_ms: 8000")
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "limits:")
+	fmt.Fprintln(w, "  max_workers: 4")
+	fmt.Fprintln(w, "  max_browser_workers: 1")
+	fmt.Fprintln(w, "  cpu_threshold_pct: 80.0    # pause dispatch above this host CPU usage")
+	fmt.Fprintf(w, "  memory_threshold_mb: %d  # pause dispatch when host memory in use reaches this\n", a.memoryMB)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "rate_limits:")
+	fmt.Fprintf(w, "  default_rps: %s  # per-domain ceiling\n", strconv.FormatFloat(domainRPS, 'g', 4, 64))
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "metrics:")
+	fmt.Fprintf(w, "  enabled: %t\n", a.metrics)
+	fmt.Fprintln(w, "  prometheus_port: 9090")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "daemon:")
+	fmt.Fprintln(w, "  log_level: info")
+	fmt.Fprintln(w, "  log_format: text")
+}
@@ -116,6 +116,10 @@ func TestIntegrationCmd_Start_DryRun(t *testing.T) {
 	if !strings.Contains(got, "rate_limited") {
 		t.Errorf("expected pacing mode in dry-run output, got: %q", got)
 	}
+	// The default jitter_factor of 0.4 at 60 rpm allows up to 400ms.
+	if !strings.Contains(got, "jitter: ≤400ms (jitter_factor 0.4)") {
+		t.Errorf("expected the configured jitter in dry-run output, got: %q", got)
+	}
 	if !strings.Contains(got, "https://example.com") {
 		t.Errorf("expected target URL in dry-run output, got: %q", got)
 	}
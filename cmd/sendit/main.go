@@ -1,21 +1,35 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/coder/websocket"
+	"github.com/google/uuid"
 	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/control"
 	"github.com/lewta/sendit/internal/driver"
 	"github.com/lewta/sendit/internal/engine"
+	"github.com/lewta/sendit/internal/logfile"
 	"github.com/lewta/sendit/internal/metrics"
 	"github.com/lewta/sendit/internal/pcap"
 	"github.com/lewta/sendit/internal/task"
@@ -54,7 +68,8 @@ history or bookmarks — no manual config editing required.
 Use 'sendit export --pcap <results.jsonl>' to convert a results file to
 PCAP format for analysis in Wireshark or similar tools.
 
-Use 'sendit validate' to check a config before running.`,
+Use 'sendit validate' to check a config before running, and
+'sendit config dump' to print the effective config with all defaults.`,
 }
 
 func main() {
@@ -66,15 +81,25 @@ func main() {
 
 func init() {
 	rootCmd.AddCommand(startCmd())
+	rootCmd.AddCommand(runCmd())
 	rootCmd.AddCommand(stopCmd())
 	rootCmd.AddCommand(reloadCmd())
 	rootCmd.AddCommand(statusCmd())
+	rootCmd.AddCommand(targetsCmd())
+	rootCmd.AddCommand(pauseCmd())
+	rootCmd.AddCommand(resumeCmd())
+	rootCmd.AddCommand(dumpCmd())
 	rootCmd.AddCommand(validateCmd())
+	rootCmd.AddCommand(configCmd())
 	rootCmd.AddCommand(versionCmd())
 	rootCmd.AddCommand(probeCmd())
 	rootCmd.AddCommand(pinchCmd())
 	rootCmd.AddCommand(exportCmd())
+	rootCmd.AddCommand(importCmd())
+	rootCmd.AddCommand(reportCmd())
 	rootCmd.AddCommand(generateCmd())
+	rootCmd.AddCommand(initCmd())
+	rootCmd.AddCommand(serveCmd())
 }
 
 // --- probe ---
@@ -87,28 +112,51 @@ func probeCmd() *cobra.Command {
 		resolver   string
 		recordType string
 		sendMsg    string
+		count      int
+		deadline   time.Duration
+		jsonOut    bool
+		maxLoss    float64
+		trace      bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "probe <target>",
-		Short: "Test a single endpoint in a loop (like ping for HTTP/DNS/WebSocket)",
-		Long: `Probe an HTTP, DNS, or WebSocket endpoint in a loop until stopped.
+		Short: "Test a single endpoint in a loop (like ping for HTTP/DNS/WebSocket/TLS)",
+		Long: `Probe an HTTP, DNS, WebSocket, or TLS endpoint in a loop until stopped.
 
 No config file is required. The driver type is auto-detected from the target:
   https:// or http:// prefix → http
   wss:// or ws:// prefix     → websocket
   bare hostname              → dns
 
-For WebSocket targets, each iteration connects, optionally sends a message and
-waits for one reply, then closes the connection. Use --send to trigger the
-send/receive round-trip measurement.
+For WebSocket targets, each iteration performs the opening handshake and
+reports its latency, then closes the connection. With --send it also sends a
+message, waits for one reply, and reports the echo round-trip separately,
+noting when the reply differs from what was sent.
+
+With --type tls, each iteration performs a TLS handshake with the target
+(host, host:port, or an https:// URL; port 443 by default) and reports the
+handshake time, negotiated protocol version, and days until the leaf
+certificate expires. A certificate that fails verification is reported as
+an error together with its details, so an expired or mismatched cert is
+easy to spot.
+
+By default probe runs until Ctrl-C. --count stops after N attempts and
+--deadline after a total wall-clock time, whichever comes first. --json
+prints one JSON object per attempt and a final summary object instead of
+the human-readable output. probe exits non-zero when the share of failed
+attempts exceeds --max-loss (in percent), so it can gate scripts and health
+checks:
+
+  sendit probe https://example.com --count 5 --max-loss 0 --json
 
 Examples:
   sendit probe https://example.com
   sendit probe example.com
   sendit probe example.com --type dns --record-type AAAA --resolver 1.1.1.1:53
   sendit probe wss://echo.example.com
-  sendit probe wss://echo.example.com --send '{"type":"ping"}'`,
+  sendit probe wss://echo.example.com --send '{"type":"ping"}'
+  sendit probe example.com --type tls`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			target := args[0]
@@ -116,8 +164,18 @@ Examples:
 			if driverType == "" {
 				driverType = detectProbeType(target)
 			}
-			if driverType != "http" && driverType != "dns" && driverType != "websocket" {
-				return fmt.Errorf("probe supports http, dns, and websocket targets; got type %q", driverType)
+			if driverType != "http" && driverType != "dns" && driverType != "websocket" && driverType != "tls" {
+				return fmt.Errorf("probe supports http, dns, websocket, and tls targets; got type %q", driverType)
+			}
+			if trace && driverType != "http" {
+				return fmt.Errorf("--trace is only supported for http targets; got type %q", driverType)
+			}
+			if driverType == "dns" {
+				addr, err := config.ResolverAddr(resolver)
+				if err != nil {
+					return fmt.Errorf("--resolver %q: %w", resolver, err)
+				}
+				resolver = addr
 			}
 
 			t := task.Task{
@@ -141,13 +199,28 @@ Examples:
 			var drv driver.Driver
 			switch driverType {
 			case "http":
-				drv = driver.NewHTTPDriver()
+				if !trace {
+					drv = driver.NewHTTPDriver()
+					break
+				}
+				// A fresh connection per attempt so every phase is measured,
+				// not just the first one before keep-alive kicks in.
+				t.Config.HTTP.Headers = map[string]string{"Connection": "close"}
+				drv = driver.NewHTTPDriverWithOptions(driver.HTTPDriverOptions{
+					Details: config.OutputDetailsConfig{Timings: true},
+				})
 			case "dns":
 				drv = driver.NewDNSDriver()
 			}
 
 			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 			defer stop()
+			if deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, deadline)
+				defer cancel()
+			}
+			enc := json.NewEncoder(os.Stdout)
 
 			var header string
 			switch driverType {
@@ -159,10 +232,17 @@ Examples:
 				} else {
 					header = fmt.Sprintf("Probing %s (websocket, connect only)", target)
 				}
+			case "tls":
+				header = fmt.Sprintf("Probing %s (tls handshake)", target)
 			default:
 				header = fmt.Sprintf("Probing %s (http)", target)
+				if trace {
+					header = fmt.Sprintf("Probing %s (http, trace)", target)
+				}
+			}
+			if !jsonOut {
+				fmt.Printf("\n%s — Ctrl-C to stop\n\n", header)
 			}
-			fmt.Printf("\n%s — Ctrl-C to stop\n\n", header)
 
 			var (
 				total   int
@@ -170,6 +250,7 @@ Examples:
 				minDur  time.Duration
 				maxDur  time.Duration
 				sumDur  time.Duration
+				echo    probeEchoStats
 			)
 
 			run := func() {
@@ -181,19 +262,59 @@ Examples:
 					dur    time.Duration
 					bytes  int64
 					err    error
+					ws     probeWSResult
+					tr     probeTLSResult
+					phases probeTrace
 				)
 
-				if driverType == "websocket" {
-					status, dur, err = probeWS(execCtx, target, sendMsg)
-				} else {
+				switch driverType {
+				case "websocket":
+					ws, err = probeWS(execCtx, target, sendMsg)
+					status, dur = ws.status, ws.connect
+				case "tls":
+					tr, err = probeTLS(execCtx, target)
+					dur = tr.handshake
+				default:
 					result := drv.Execute(execCtx, t)
 					status, dur, bytes, err = result.StatusCode, result.Duration, result.BytesRead, result.Error
+					if trace {
+						phases = probeTraceFromMeta(result.Meta)
+					}
 				}
 
 				total++
 				displayDur := dur.Round(time.Millisecond)
 
+				if jsonOut {
+					rec := probeRecord{Seq: total, Time: time.Now().UTC(), Status: status, LatencyMs: probeMs(dur), Bytes: bytes}
+					switch {
+					case driverType == "dns" && err == nil:
+						rec.RCode = probeRcodeLabel(status)
+					case driverType == "websocket" && sendMsg != "" && err == nil:
+						rec.EchoMs = probeMs(ws.echo)
+						rec.EchoMatched = &ws.matched
+					}
+					if tr.version != "" {
+						days := tr.daysLeft()
+						rec.TLSVersion, rec.ExpiresInDays, rec.Subject = tr.version, &days, tr.subject
+					}
+					if trace {
+						rec.Trace = &phases
+					}
+					if err != nil {
+						rec.Error = err.Error()
+					}
+					_ = enc.Encode(rec)
+				}
+
 				if err != nil {
+					if jsonOut {
+						return
+					}
+					if tr.version != "" {
+						fmt.Printf("  ERR  %s  %s  %v\n", tr.version, tr.expiry(), err)
+						return
+					}
 					fmt.Printf("  ERR  %v\n", err)
 					return
 				}
@@ -206,43 +327,172 @@ Examples:
 				if dur > maxDur {
 					maxDur = dur
 				}
+				if driverType == "websocket" && sendMsg != "" {
+					echo.add(ws.echo)
+				}
+				if jsonOut {
+					return
+				}
 
 				switch driverType {
 				case "dns":
 					fmt.Printf("  %-8s  %6s\n", probeRcodeLabel(status), displayDur)
 				case "websocket":
-					fmt.Printf("  %3d  %6s\n", status, displayDur)
+					if sendMsg == "" {
+						fmt.Printf("  %3d  %6s\n", status, displayDur)
+						break
+					}
+					note := ""
+					if !ws.matched {
+						note = "  (reply differs)"
+					}
+					fmt.Printf("  %3d  %6s  echo %6s%s\n", status, displayDur, ws.echo.Round(time.Millisecond), note)
+				case "tls":
+					fmt.Printf("  %-7s  %6s  %s  %s\n", tr.version, displayDur, tr.expiry(), tr.subject)
 				default:
+					if trace {
+						fmt.Printf("  %3d  %6s  %-9s  %s\n", status, displayDur, probeFormatBytes(bytes), phases)
+						break
+					}
 					fmt.Printf("  %3d  %6s  %s\n", status, displayDur, probeFormatBytes(bytes))
 				}
 			}
 
-			// Fire immediately, then on each tick.
+			// Fire immediately, then on each tick until Ctrl-C, --deadline,
+			// or --count.
 			run()
 			ticker := time.NewTicker(interval)
 			defer ticker.Stop()
-			for {
+			for ctx.Err() == nil && (count <= 0 || total < count) {
 				select {
 				case <-ctx.Done():
-					probeSummary(target, total, success, minDur, maxDur, sumDur)
-					return nil
 				case <-ticker.C:
 					run()
 				}
 			}
+
+			loss := 0.0
+			if total > 0 {
+				loss = float64(total-success) / float64(total) * 100
+			}
+			if jsonOut {
+				sum := probeSummaryRecord{Target: target, Type: driverType, Sent: total, OK: success, Errors: total - success, LossPct: loss}
+				if success > 0 {
+					sum.MinMs, sum.AvgMs, sum.MaxMs = probeMs(minDur), probeMs(sumDur/time.Duration(success)), probeMs(maxDur)
+				}
+				if echo.n > 0 {
+					sum.EchoAvgMs = probeMs(echo.sumDur / time.Duration(echo.n))
+				}
+				_ = enc.Encode(sum)
+			} else {
+				probeSummary(target, total, success, minDur, maxDur, sumDur)
+				echo.print()
+			}
+			if loss > maxLoss {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("%.1f%% of probes failed, more than --max-loss %g%%", loss, maxLoss)
+			}
+			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&driverType, "type", "", "Driver type: http|dns|websocket (auto-detected from target if omitted)")
+	cmd.Flags().StringVar(&driverType, "type", "", "Probe type: http|dns|websocket|tls (auto-detected from target if omitted; tls must be explicit)")
 	cmd.Flags().DurationVar(&interval, "interval", time.Second, "Delay between requests")
 	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "Per-request timeout")
 	cmd.Flags().StringVar(&resolver, "resolver", "8.8.8.8:53", "DNS resolver address (dns targets only)")
 	cmd.Flags().StringVar(&recordType, "record-type", "A", "DNS record type (dns targets only)")
 	cmd.Flags().StringVar(&sendMsg, "send", "", "Message to send after connecting (websocket only); waits for one reply and reports round-trip latency")
+	cmd.Flags().IntVar(&count, "count", 0, "Stop after this many attempts (0 = until Ctrl-C)")
+	cmd.Flags().DurationVar(&deadline, "deadline", 0, "Stop after this total wall-clock time (e.g. 30s; 0 = no limit)")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Print one JSON object per attempt and a JSON summary")
+	cmd.Flags().Float64Var(&maxLoss, "max-loss", 100, "Exit non-zero when more than this percentage of attempts fail")
+	cmd.Flags().BoolVar(&trace, "trace", false, "Print per-phase timings (dns, connect, tls, ttfb, transfer) for each attempt (http only)")
 
 	return cmd
 }
 
+// probeRecord is one --json output line of sendit probe.
+type probeRecord struct {
+	Seq           int         `json:"seq"`
+	Time          time.Time   `json:"time"`
+	Status        int         `json:"status,omitempty"`
+	RCode         string      `json:"rcode,omitempty"`
+	LatencyMs     float64     `json:"latency_ms"`
+	Bytes         int64       `json:"bytes,omitempty"`
+	EchoMs        float64     `json:"echo_ms,omitempty"`
+	EchoMatched   *bool       `json:"echo_matched,omitempty"`
+	TLSVersion    string      `json:"tls_version,omitempty"`
+	ExpiresInDays *int        `json:"expires_in_days,omitempty"`
+	Subject       string      `json:"subject,omitempty"`
+	Trace         *probeTrace `json:"trace,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
+
+// probeTrace holds the per-phase timings of one --trace attempt in
+// milliseconds. A phase that did not happen (DNS for an IP literal, TLS for
+// plain HTTP, anything after a failure) is zero.
+type probeTrace struct {
+	DNSMs      float64 `json:"dns_ms,omitempty"`
+	ConnectMs  float64 `json:"connect_ms,omitempty"`
+	TLSMs      float64 `json:"tls_ms,omitempty"`
+	TTFBMs     float64 `json:"ttfb_ms,omitempty"`
+	TransferMs float64 `json:"transfer_ms,omitempty"`
+}
+
+// probeTraceFromMeta reads the timing fields the HTTP driver records in
+// Result.Meta.
+func probeTraceFromMeta(meta map[string]string) probeTrace {
+	ms := func(key string) float64 {
+		v, _ := strconv.ParseFloat(meta[key], 64)
+		return v
+	}
+	return probeTrace{
+		DNSMs:      ms("dns_ms"),
+		ConnectMs:  ms("connect_ms"),
+		TLSMs:      ms("tls_ms"),
+		TTFBMs:     ms("ttfb_ms"),
+		TransferMs: ms("transfer_ms"),
+	}
+}
+
+// String renders the phases for the text output, e.g.
+// "dns 3.1ms  connect 12.0ms  tls 25.4ms  ttfb 80.2ms  transfer 1.3ms".
+// Phases that did not happen are left out.
+func (p probeTrace) String() string {
+	var parts []string
+	for _, ph := range []struct {
+		name string
+		ms   float64
+	}{
+		{"dns", p.DNSMs}, {"connect", p.ConnectMs}, {"tls", p.TLSMs},
+		{"ttfb", p.TTFBMs}, {"transfer", p.TransferMs},
+	} {
+		if ph.ms > 0 {
+			parts = append(parts, fmt.Sprintf("%s %.1fms", ph.name, ph.ms))
+		}
+	}
+	return strings.Join(parts, "  ")
+}
+
+// probeSummaryRecord is the final --json output line of sendit probe.
+type probeSummaryRecord struct {
+	Target    string  `json:"target"`
+	Type      string  `json:"type"`
+	Sent      int     `json:"sent"`
+	OK        int     `json:"ok"`
+	Errors    int     `json:"errors"`
+	LossPct   float64 `json:"loss_pct"`
+	MinMs     float64 `json:"min_ms,omitempty"`
+	AvgMs     float64 `json:"avg_ms,omitempty"`
+	MaxMs     float64 `json:"max_ms,omitempty"`
+	EchoAvgMs float64 `json:"echo_avg_ms,omitempty"`
+}
+
+// probeMs converts d to fractional milliseconds rounded to 0.001 ms.
+func probeMs(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
 func detectProbeType(target string) string {
 	switch {
 	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
@@ -254,27 +504,147 @@ func detectProbeType(target string) string {
 	}
 }
 
+// probeWSResult is the outcome of one WebSocket probe iteration.
+type probeWSResult struct {
+	status  int           // 101 on a completed handshake
+	connect time.Duration // handshake latency
+	echo    time.Duration // send-to-reply round-trip; zero without --send
+	matched bool          // the reply equals the message sent
+}
+
 // probeWS dials a WebSocket endpoint, optionally sends sendMsg and reads one
-// reply, then closes gracefully. Returns status 101 on success.
-func probeWS(ctx context.Context, target, sendMsg string) (int, time.Duration, error) {
+// reply, then closes gracefully.
+func probeWS(ctx context.Context, target, sendMsg string) (probeWSResult, error) {
+	var res probeWSResult
 	start := time.Now()
 	conn, _, err := websocket.Dial(ctx, target, nil)
+	res.connect = time.Since(start)
 	if err != nil {
-		return 0, time.Since(start), fmt.Errorf("dial: %w", err)
+		return res, fmt.Errorf("dial: %w", err)
 	}
 	defer conn.CloseNow() //nolint:errcheck
+	res.status = 101
 
 	if sendMsg != "" {
+		sent := time.Now()
 		if err := conn.Write(ctx, websocket.MessageText, []byte(sendMsg)); err != nil {
-			return 0, time.Since(start), fmt.Errorf("send: %w", err)
+			return res, fmt.Errorf("send: %w", err)
 		}
-		if _, _, err := conn.Read(ctx); err != nil {
-			return 0, time.Since(start), fmt.Errorf("recv: %w", err)
+		_, reply, err := conn.Read(ctx)
+		if err != nil {
+			return res, fmt.Errorf("recv: %w", err)
 		}
+		res.echo = time.Since(sent)
+		res.matched = string(reply) == sendMsg
 	}
 
 	conn.Close(websocket.StatusNormalClosure, "done") //nolint:errcheck,gosec
-	return 101, time.Since(start), nil
+	return res, nil
+}
+
+// probeTLSResult is the outcome of one TLS probe iteration. version and the
+// certificate fields are set whenever the handshake completed, even if the
+// certificate then failed verification.
+type probeTLSResult struct {
+	handshake time.Duration
+	version   string
+	notAfter  time.Time
+	subject   string
+}
+
+// daysLeft returns the whole days until the leaf certificate expires;
+// negative once it has expired.
+func (r probeTLSResult) daysLeft() int {
+	return int(math.Floor(time.Until(r.notAfter).Hours() / 24))
+}
+
+// expiry formats the time left on the leaf certificate in whole days.
+func (r probeTLSResult) expiry() string {
+	days := r.daysLeft()
+	if days < 0 {
+		return fmt.Sprintf("expired %dd ago", -days)
+	}
+	return fmt.Sprintf("expires in %dd", days)
+}
+
+// probeTLS performs a TLS handshake with target and verifies the presented
+// chain against the system roots. Verification is done after the handshake
+// so that the details of an invalid certificate can still be reported.
+func probeTLS(ctx context.Context, target string) (probeTLSResult, error) {
+	var res probeTLSResult
+	addr, host := probeTLSAddr(target)
+	d := &tls.Dialer{Config: &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true, //nolint:gosec // verified below so invalid certs can be described
+	}}
+	start := time.Now()
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	res.handshake = time.Since(start)
+	if err != nil {
+		return res, fmt.Errorf("handshake: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	state := conn.(*tls.Conn).ConnectionState()
+	res.version = tls.VersionName(state.Version)
+	if len(state.PeerCertificates) == 0 {
+		return res, errors.New("server presented no certificate")
+	}
+	leaf := state.PeerCertificates[0]
+	res.notAfter = leaf.NotAfter
+	res.subject = "CN=" + leaf.Subject.CommonName
+
+	inter := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		inter.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Intermediates: inter}); err != nil {
+		return res, fmt.Errorf("verify: %w", err)
+	}
+	return res, nil
+}
+
+// probeTLSAddr returns the dial address and server name for a tls probe
+// target given as host, host:port, or a URL.
+func probeTLSAddr(target string) (addr, host string) {
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		target = u.Host
+	}
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		return target, strings.Trim(h, "[]")
+	}
+	return net.JoinHostPort(strings.Trim(target, "[]"), "443"), strings.Trim(target, "[]")
+}
+
+// probeEchoStats accumulates WebSocket echo round-trips for the summary.
+type probeEchoStats struct {
+	n              int
+	minDur, maxDur time.Duration
+	sumDur         time.Duration
+}
+
+func (s *probeEchoStats) add(d time.Duration) {
+	s.n++
+	s.sumDur += d
+	if s.n == 1 || d < s.minDur {
+		s.minDur = d
+	}
+	if d > s.maxDur {
+		s.maxDur = d
+	}
+}
+
+// print writes the echo line of the summary; nothing when no echo was
+// measured.
+func (s *probeEchoStats) print() {
+	if s.n == 0 {
+		return
+	}
+	fmt.Printf("min/avg/max echo: %s / %s / %s\n",
+		s.minDur.Round(time.Millisecond),
+		(s.sumDur / time.Duration(s.n)).Round(time.Millisecond),
+		s.maxDur.Round(time.Millisecond),
+	)
 }
 
 func probeRcodeLabel(status int) string {
@@ -494,12 +864,15 @@ func versionCmd() *cobra.Command {
 func startCmd() *cobra.Command {
 	var (
 		cfgPath     string
+		profile     string
 		foreground  bool
 		logLevel    string
 		dryRun      bool
 		capturePath string
 		duration    time.Duration
 		tuiFlag     bool
+		runID       string
+		refresh     time.Duration
 	)
 
 	cmd := &cobra.Command{
@@ -515,7 +888,7 @@ targets_file format (one entry per line):
 
   url     Full URL (https://, wss://) or bare hostname for dns targets
   type    http | browser | dns | websocket
-  weight  Optional positive integer (default: target_defaults.weight)
+  weight  Optional positive number (default: target_defaults.weight)
   #       Lines beginning with '#' and blank lines are ignored
 
 Example targets_file:
@@ -525,17 +898,43 @@ Example targets_file:
 Default field values for file-loaded targets (method, timeout, resolver,
 etc.) are configured under 'target_defaults:' in the YAML.
 
-The engine shuts down gracefully on SIGINT or SIGTERM, waiting for all
-in-flight requests to complete before exiting.
+By default start detaches: it re-launches itself in the background, in its
+own session with no terminal, and returns once the background process has
+written daemon.pid_file (PID and start time). Logs go to daemon.log_file,
+rotated at daemon.log_max_size_mb with daemon.log_max_backups old files
+kept. Use --foreground to stay attached, log to stderr, and skip the PID
+file — as in containers, under systemd, or in CI. --tui also stays attached.
+
+The engine shuts down gracefully on SIGINT or SIGTERM ('sendit stop'),
+waiting for all in-flight requests to complete before exiting.
 
 Send SIGHUP to reload the config without restarting. Targets, rate limits,
 backoff, and pacing are updated atomically with no dropped requests. Changes
-to pacing mode or resource limits (workers, cpu, memory) require a restart.`,
+to pacing mode or resource limits (workers, cpu, memory) require a restart.
+Send SIGUSR2 to write a snapshot of the engine's internal state to the log
+(see 'sendit dump').
+
+--config also accepts an http(s):// or s3:// URL. The remote config is
+polled every --config-refresh using its ETag and hot-reloaded when it
+changes.
+
+--profile merges the overlay under 'profiles.<name>' over the base config,
+so one file can hold near-identical dev, staging, and prod variants. The
+same profile is applied on every reload.
+
+While running, start listens on daemon.control_socket (default
+/tmp/sendit.sock) so that 'sendit targets' can list, add, and remove
+targets without editing files, and 'sendit pause' / 'sendit resume' can
+halt and restart dispatch.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg, err := config.Load(cfgPath)
+			lc, err := loadConfig(cmd.Context(), cfgPath, profile)
 			if err != nil {
 				return err
 			}
+			cfg, remote, kv := lc.cfg, lc.remote, lc.kv
+			// baseCfg is the config as loaded from --config; kv entries are
+			// overlaid on it for every (re)load.
+			baseCfg := lc.base
 
 			if capturePath != "" {
 				cfg.Output.PCAPFile = capturePath
@@ -551,19 +950,35 @@ to pacing mode or resource limits (workers, cpu, memory) require a restart.`,
 				return nil
 			}
 
+			// Without --foreground, start re-executes itself in the
+			// background and returns once the child has written its PID
+			// file. The TUI needs the terminal, so --tui stays attached.
+			if !foreground && !tuiFlag && !isDaemonChild() {
+				return detach(cmd, cfg.Daemon)
+			}
+
 			// CLI flag overrides config log level.
 			lvl := cfg.Daemon.LogLevel
 			if logLevel != "" {
 				lvl = logLevel
 			}
-			initLogger(lvl, cfg.Daemon.LogFormat)
-
-			if !foreground {
-				if err := writePID(cfg.Daemon.PIDFile); err != nil {
-					log.Warn().Err(err).Msg("could not write PID file")
+			if isDaemonChild() {
+				// Errors from here on are runtime failures; usage text
+				// would only bury them in the log the parent echoes.
+				cmd.SilenceUsage = true
+				lf, err := logfile.Open(cfg.Daemon.LogFile, cfg.Daemon.LogMaxSizeMB, cfg.Daemon.LogMaxBackups)
+				if err != nil {
+					return err
 				}
-				defer os.Remove(cfg.Daemon.PIDFile) //nolint:errcheck
+				defer lf.Close() //nolint:errcheck
+				if err := lf.CaptureStdio(); err != nil {
+					return err
+				}
+				initLoggerTo(lf, lvl, cfg.Daemon.LogFormat)
+			} else {
+				initLogger(lvl, cfg.Daemon.LogFormat)
 			}
+			runID = tagRunID(runID)
 
 			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 			defer stop()
@@ -578,7 +993,7 @@ to pacing mode or resource limits (workers, cpu, memory) require a restart.`,
 
 			var m *metrics.Metrics
 			if cfg.Metrics.Enabled {
-				m = metrics.New()
+				m = metrics.NewWithOptions(metrics.Options{PerTarget: cfg.Metrics.PerTarget, RunID: runID})
 				go m.ServeHTTP(ctx, cfg.Metrics.BindAddress, cfg.Metrics.PrometheusPort)
 			} else {
 				m = metrics.Noop()
@@ -588,6 +1003,68 @@ to pacing mode or resource limits (workers, cpu, memory) require a restart.`,
 			if err != nil {
 				return fmt.Errorf("creating engine: %w", err)
 			}
+			eng.SetRunID(runID)
+			taskLog, closeTaskLog, err := openTaskLog(cfg.Daemon)
+			if err != nil {
+				return err
+			}
+			defer closeTaskLog()
+			eng.SetTaskLogger(taskLog)
+
+			// The PID file doubles as the detached parent's signal that
+			// startup succeeded, so it is written once the engine exists.
+			if !foreground {
+				if err := writePID(cfg.Daemon.PIDFile); err != nil {
+					log.Warn().Err(err).Msg("could not write PID file")
+				}
+				defer os.Remove(cfg.Daemon.PIDFile) //nolint:errcheck
+			}
+
+			// reload swaps in newBase (or re-applies the current base when nil)
+			// with kv entries and runtime target changes overlaid. SIGHUP,
+			// remote polling, the kv watcher, and the control socket all
+			// funnel through it.
+			var (
+				reloadMu  sync.Mutex
+				overrides config.TargetOverrides
+			)
+			reload := func(newBase *config.Config) error {
+				reloadMu.Lock()
+				defer reloadMu.Unlock()
+				if newBase != nil {
+					baseCfg = newBase
+				}
+				next := baseCfg
+				if kv != nil {
+					var err error
+					if next, err = kv.Apply(baseCfg); err != nil {
+						log.Error().Err(err).Msg("hot-reload: invalid kv entries, keeping current")
+						return err
+					}
+				}
+				next, err := overrides.Apply(next)
+				if err != nil {
+					log.Error().Err(err).Msg("hot-reload: invalid runtime targets, keeping current")
+					return err
+				}
+				if err := eng.Reload(next); err != nil {
+					log.Error().Err(err).Msg("hot-reload: reload failed, keeping current")
+					return err
+				}
+				return nil
+			}
+
+			if cfg.Daemon.ControlSocket != "" {
+				srv := control.NewServer(eng, &overrides, func() error { return reload(nil) })
+				srv.ConfigPath = cfgPath
+				go func() {
+					if err := srv.Serve(ctx, cfg.Daemon.ControlSocket); err != nil {
+						log.Warn().Err(err).Msg("control socket unavailable; 'sendit targets' will not work")
+					}
+				}()
+			}
+
+			dumpOnSignal(ctx, eng)
 
 			// Hot-reload on SIGHUP.
 			sighupCh := make(chan os.Signal, 1)
@@ -600,18 +1077,94 @@ to pacing mode or resource limits (workers, cpu, memory) require a restart.`,
 						return
 					case <-sighupCh:
 						log.Info().Str("config", cfgPath).Msg("SIGHUP received, reloading config")
-						newCfg, err := config.Load(cfgPath)
+						newCfg, err := config.LoadProfile(cfgPath, profile)
 						if err != nil {
 							log.Error().Err(err).Msg("hot-reload: invalid config, keeping current")
 							continue
 						}
-						if err := eng.Reload(newCfg); err != nil {
-							log.Error().Err(err).Msg("hot-reload: reload failed, keeping current")
-						}
+						reload(newCfg)
 					}
 				}
 			}()
 
+			// Poll a remote config and hot-reload when its ETag changes.
+			if remote != nil && refresh > 0 {
+				go func() {
+					ticker := time.NewTicker(refresh)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-ticker.C:
+							newCfg, changed, err := remote.Load(ctx)
+							if err != nil {
+								log.Error().Err(err).Str("config", cfgPath).Msg("remote config: refresh failed, keeping current")
+								continue
+							}
+							if !changed {
+								continue
+							}
+							log.Info().Str("config", cfgPath).Msg("remote config changed, reloading")
+							reload(newCfg)
+						}
+					}
+				}()
+			}
+
+			// Re-fetch a remote targets_file and hot-reload when its content
+			// hash changes. The interval is fixed at startup.
+			if config.IsRemote(cfg.TargetsFile) && cfg.TargetsFileRefreshS > 0 {
+				go func() {
+					ticker := time.NewTicker(time.Duration(cfg.TargetsFileRefreshS) * time.Second)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-ticker.C:
+							reloadMu.Lock()
+							current := baseCfg
+							reloadMu.Unlock()
+							changed, err := config.TargetsFileChanged(ctx, current)
+							if err != nil {
+								log.Error().Err(err).Msg("targets_file: refresh failed, keeping current")
+								continue
+							}
+							if !changed {
+								continue
+							}
+							log.Info().Str("targets_file", current.TargetsFile).Msg("remote targets_file changed, reloading")
+							newCfg, err := config.LoadProfile(cfgPath, profile)
+							if err != nil {
+								log.Error().Err(err).Msg("hot-reload: invalid config, keeping current")
+								continue
+							}
+							reload(newCfg)
+						}
+					}
+				}()
+			}
+
+			// Follow the kv prefix and hot-reload when its entries change.
+			if kv != nil {
+				go func() {
+					for ctx.Err() == nil {
+						changed, err := kv.Refresh(ctx)
+						switch {
+						case err != nil && ctx.Err() == nil:
+							log.Error().Err(err).Str("kv", cfg.KV.Type).Msg("kv: refresh failed, keeping current")
+							sleepCtx(ctx, 5*time.Second)
+							continue
+						case changed:
+							log.Info().Str("kv", cfg.KV.Type).Msg("kv entries changed, reloading")
+							reload(nil)
+						}
+						sleepCtx(ctx, kv.PollInterval())
+					}
+				}()
+			}
+
 			if tuiFlag {
 				fi, err := os.Stdout.Stat()
 				isTerminal := err == nil && (fi.Mode()&os.ModeCharDevice) != 0
@@ -630,12 +1183,15 @@ to pacing mode or resource limits (workers, cpu, memory) require a restart.`,
 		},
 	}
 
-	cmd.Flags().StringVarP(&cfgPath, "config", "c", "config/example.yaml", "Path to YAML config file")
-	cmd.Flags().BoolVar(&foreground, "foreground", false, "Skip writing the PID file (process always runs in foreground)")
+	cmd.Flags().StringVarP(&cfgPath, "config", "c", "config/example.yaml", "Path or http(s)://, s3:// URL of the YAML config file")
+	cmd.Flags().StringVar(&profile, "profile", "", "Apply the named overlay from the config's profiles section")
+	cmd.Flags().DurationVar(&refresh, "config-refresh", time.Minute, "Poll interval for a remote --config URL (0 disables polling)")
+	cmd.Flags().BoolVar(&foreground, "foreground", false, "Stay attached to the terminal, log to stderr, and skip the PID file")
 	cmd.Flags().StringVar(&logLevel, "log-level", "", "Override log level (debug|info|warn|error)")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print config summary and exit without sending any traffic")
 	cmd.Flags().StringVar(&capturePath, "capture", "", "Write a synthetic PCAP file while running (e.g. capture.pcap); finalised on clean shutdown")
 	cmd.Flags().DurationVar(&duration, "duration", 0, "Auto-stop after this wall-clock duration (e.g. 5m, 30s); required when pacing.mode is burst")
+	cmd.Flags().StringVar(&runID, "run-id", "", "ID attached to metrics, logs, and output records of this run (default: a random UUID)")
 	cmd.Flags().BoolVar(&tuiFlag, "tui", false, "Enable the terminal UI (requires a TTY; silently ignored otherwise)")
 
 	return cmd
@@ -655,7 +1211,9 @@ func exportCmd() *cobra.Command {
 		Long: `Convert a sendit result file to another format.
 
 Currently supports converting a JSONL results file (written by the output
-writer) to a synthetic PCAP file for analysis in Wireshark or tshark.
+writer) to a synthetic PCAP file for analysis in Wireshark or tshark. The
+'dashboard' subcommand writes a Grafana dashboard for the Prometheus metrics
+instead.
 
 The PCAP uses LINKTYPE_USER0 (147) — no IP/TCP framing. Each packet payload
 is a text record containing the URL, type, status code, latency, bytes, and
@@ -675,10 +1233,60 @@ Examples:
 
 	cmd.Flags().StringVar(&pcapIn, "pcap", "", "JSONL results file to convert to PCAP")
 	cmd.Flags().StringVar(&pcapOut, "output", "", "Output PCAP file path (default: input file with .pcap extension)")
+	cmd.AddCommand(exportDashboardCmd())
 
 	return cmd
 }
 
+func exportDashboardCmd() *cobra.Command {
+	var (
+		opts    metrics.DashboardOptions
+		outPath string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Write a Grafana dashboard for sendit's Prometheus metrics",
+		Long: `Print a Grafana dashboard (JSON model) whose panels query the metrics that
+'sendit start' and 'sendit run' export when metrics.enabled is true: request
+and error rates, status codes, error ratio, latency percentiles, bytes read,
+dropped output records, and per-domain breakdowns, filterable by type and
+domain.
+
+The Targets row uses the per-target series, which are only exported with
+metrics.per_target: true; without it those panels show no data.
+
+Import the file through Dashboards → New → Import in Grafana, or place it in
+a dashboard provisioning directory. Pick the Prometheus data source on import.
+
+Examples:
+  sendit export dashboard > sendit-dashboard.json
+  sendit export dashboard --output dashboards/sendit.json --title "Staging load"`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := metrics.Dashboard(opts)
+			if err != nil {
+				return err
+			}
+			data = append(data, '\n')
+			if outPath == "" {
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			if err := os.WriteFile(outPath, data, 0o644); err != nil { //nolint:gosec // dashboards are not secret
+				return fmt.Errorf("writing dashboard: %w", err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s\n", outPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outPath, "output", "o", "", "Write the dashboard to this file instead of stdout")
+	cmd.Flags().StringVar(&opts.Title, "title", "sendit", "Dashboard title")
+	cmd.Flags().StringVar(&opts.UID, "uid", "sendit", "Dashboard UID; importing a dashboard with the same UID replaces it")
+	return cmd
+}
+
 // --- stop ---
 
 func stopCmd() *cobra.Command {
@@ -754,43 +1362,182 @@ config on Windows.`,
 // --- status ---
 
 func statusCmd() *cobra.Command {
-	var pidFile string
+	var (
+		pidFile string
+		socket  string
+		full    bool
+		jsonOut bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Check whether the traffic generator daemon is running",
+		Long: `Report whether the daemon named by the PID file is running, and since when.
+
+With --full, also ask the running 'sendit start' for live stats over its
+control socket (daemon.control_socket): uptime, config path and hash, the
+request rate over the last minute, totals and error rate by driver type,
+pacing mode, and pause and scheduled-window state. When the socket cannot
+be reached, status falls back to the PID file check and says why the live
+stats are missing.
+
+--json prints the live stats as JSON and implies --full; it fails instead
+of falling back when the socket cannot be reached.`,
+		Args: cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			pid, err := readPID(pidFile)
-			if err != nil {
-				fmt.Printf("Not running (no PID file at %s)\n", pidFile)
-				return nil
-			}
-
-			proc, err := os.FindProcess(pid)
-			if err != nil {
-				fmt.Printf("Not running (process %d not found)\n", pid)
-				return nil
-			}
-
-			// Signal 0 checks if the process is alive without killing it.
-			if err := proc.Signal(syscall.Signal(0)); err != nil {
-				fmt.Printf("Not running (process %d: %v)\n", pid, err)
-				return nil
+			out := cmd.OutOrStdout()
+			if full || jsonOut {
+				st, err := control.NewClient(socket).Status(cmd.Context())
+				switch {
+				case err == nil && jsonOut:
+					enc := json.NewEncoder(out)
+					enc.SetIndent("", "  ")
+					return enc.Encode(st)
+				case err == nil:
+					writeFullStatus(out, st)
+					return nil
+				case jsonOut:
+					cmd.SilenceUsage = true
+					return err
+				}
+				defer fmt.Fprintf(out, "Live stats unavailable: %v\n", err)
 			}
-
-			fmt.Printf("Running (PID %d)\n", pid)
+			writePIDStatus(out, pidFile)
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&pidFile, "pid-file", "/tmp/sendit.pid", "Path to PID file")
+	cmd.Flags().BoolVar(&full, "full", false, "Also show live runtime stats from the control socket")
+	cmd.Flags().StringVar(&socket, "socket", defaultControlSocket, "Path to the daemon's control socket (with --full)")
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Print live runtime stats as JSON (implies --full)")
 	return cmd
 }
 
+// writePIDStatus reports whether the process named in pidFile is alive.
+func writePIDStatus(out io.Writer, pidFile string) {
+	info, err := readPIDInfo(pidFile)
+	if err != nil {
+		fmt.Fprintf(out, "Not running (no PID file at %s)\n", pidFile)
+		return
+	}
+	pid := info.PID
+
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		fmt.Fprintf(out, "Not running (process %d not found)\n", pid)
+		return
+	}
+
+	// Signal 0 checks if the process is alive without killing it.
+	if err := proc.Signal(syscall.Signal(0)); err != nil {
+		fmt.Fprintf(out, "Not running (process %d: %v)\n", pid, err)
+		return
+	}
+
+	if info.Started.IsZero() {
+		fmt.Fprintf(out, "Running (PID %d)\n", pid)
+		return
+	}
+	fmt.Fprintf(out, "Running (PID %d, started %s, up %s)\n",
+		pid, info.Started.Local().Format(time.RFC3339), time.Since(info.Started).Round(time.Second))
+}
+
+// writeFullStatus prints the live stats reported by a running daemon.
+func writeFullStatus(out io.Writer, st control.Status) {
+	fmt.Fprintf(out, "Running (PID %d, started %s, up %s)\n",
+		st.PID, st.Started.Local().Format(time.RFC3339), (time.Duration(st.UptimeS) * time.Second).Round(time.Second))
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if st.RunID != "" {
+		fmt.Fprintf(tw, "Run ID:\t%s\n", st.RunID)
+	}
+	fmt.Fprintf(tw, "Config:\t%s (sha256 %s), %d targets\n", cmp.Or(st.Config, "-"), st.ConfigHash, st.Targets)
+
+	pacing := st.Mode
+	switch {
+	case st.InWindow != nil && *st.InWindow:
+		pacing += fmt.Sprintf(", window open at %g rpm", st.ActiveRPM)
+		if st.WindowGroup != "" {
+			pacing += fmt.Sprintf(" (group %s)", st.WindowGroup)
+		}
+	case st.InWindow != nil:
+		pacing += ", outside scheduled windows"
+	case st.ActiveRPM > 0:
+		pacing += fmt.Sprintf(", %g rpm", st.ActiveRPM)
+	}
+	fmt.Fprintf(tw, "Pacing:\t%s\n", pacing)
+
+	state := "dispatching"
+	switch {
+	case st.Paused:
+		state = "paused" + pausedSince(control.PauseState{Since: st.PausedSince})
+	case st.Blackout != "":
+		state = "in blackout " + st.Blackout
+		if st.BlackoutUntil != nil {
+			state += " until " + st.BlackoutUntil.Local().Format(time.RFC3339)
+		}
+	}
+	fmt.Fprintf(tw, "State:\t%s\n", state)
+	fmt.Fprintf(tw, "Rate:\t%.2f req/s (last minute)\n", st.RPS)
+	fmt.Fprintf(tw, "Requests:\t%d (%d errors, %.1f%%)\n", st.Requests, st.Errors, st.ErrorPct)
+	for _, ts := range st.ByType {
+		fmt.Fprintf(tw, "  %s:\t%d (%d errors, %.1f%%)\n", ts.Type, ts.Requests, ts.Errors, ts.ErrorPct)
+	}
+	fmt.Fprintf(tw, "Host:\tCPU %.1f%%, memory %d MB in use\n", st.CPUPct, st.MemUsedMB)
+
+	var limitWait, backoffWait float64
+	for _, w := range st.Waits {
+		limitWait += w.RateLimitS
+		backoffWait += w.BackoffS
+	}
+	fmt.Fprintf(tw, "Backoff:\t%d domain(s) backing off\n", len(st.Backoff))
+	fmt.Fprintf(tw, "Held:\t%s by rate limits, %s by backoff, since start\n", seconds(limitWait), seconds(backoffWait))
+	_ = tw.Flush()
+
+	// Domain rows get their own columns so that long hostnames do not
+	// widen the summary above.
+	if len(st.Backoff) > 0 {
+		fmt.Fprintln(out, "\nBacking off:")
+		tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, b := range st.Backoff {
+			next := "ready"
+			switch {
+			case b.Cooldown && b.RemainingS > 0:
+				next = "in cooldown, skipped for " + seconds(b.RemainingS)
+			case b.RemainingS > 0:
+				next = "next in " + seconds(b.RemainingS)
+			}
+			fmt.Fprintf(tw, "  %s\tattempt %d/%d, %s\n", b.Domain, b.Attempts, b.MaxAttempts, next)
+		}
+		_ = tw.Flush()
+	}
+	if len(st.Waits) > 0 {
+		fmt.Fprintln(out, "\nLongest held (rate limit / backoff):")
+		tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for i, w := range st.Waits {
+			if i == maxStatusWaits {
+				fmt.Fprintf(tw, "  … %d more\t\n", len(st.Waits)-i)
+				break
+			}
+			fmt.Fprintf(tw, "  %s\t%s / %s\n", w.Domain, seconds(w.RateLimitS), seconds(w.BackoffS))
+		}
+		_ = tw.Flush()
+	}
+}
+
+// maxStatusWaits is how many domains status --full lists under Held.
+const maxStatusWaits = 10
+
+// seconds formats s seconds as a rounded duration.
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(100 * time.Millisecond).String()
+}
+
 // --- validate ---
 
 func validateCmd() *cobra.Command {
-	var cfgPath string
+	var cfgPath, profile string
 
 	cmd := &cobra.Command{
 		Use:   "validate",
@@ -804,10 +1551,13 @@ If 'targets_file' is set in the config, that file is also read and parsed
 as part of validation — a missing file, malformed line, unknown driver
 type, or invalid weight is reported here before any traffic is sent.
 
+With --profile, the named overlay from 'profiles:' is merged in first and
+the combined config is validated.
+
 Exits 0 and prints "config valid" on success.
 Exits non-zero and prints the validation error on failure.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			_, err := config.Load(cfgPath)
+			_, err := config.LoadProfile(cfgPath, profile)
 			if err != nil {
 				return err
 			}
@@ -817,6 +1567,7 @@ Exits non-zero and prints the validation error on failure.`,
 	}
 
 	cmd.Flags().StringVarP(&cfgPath, "config", "c", "config/example.yaml", "Path to YAML config file")
+	cmd.Flags().StringVar(&profile, "profile", "", "Validate with the named overlay from the config's profiles section applied")
 	return cmd
 }
 
@@ -825,29 +1576,38 @@ Exits non-zero and prints the validation error on failure.`,
 func printDryRun(path string, cfg *config.Config, duration time.Duration) {
 	fmt.Printf("Config: %s  ✓ valid\n\n", path)
 
-	// Compute total weight.
-	totalWeight := 0
-	for _, t := range cfg.Targets {
-		totalWeight += t.Weight
+	// Compute effective weights, which account for any fixed shares.
+	weights, _ := config.EffectiveWeights(cfg.Targets)
+	type row struct {
+		t config.TargetConfig
+		w float64
+	}
+	sorted := make([]row, len(cfg.Targets))
+	totalWeight := 0.0
+	for i, t := range cfg.Targets {
+		sorted[i] = row{t, weights[i]}
+		totalWeight += weights[i]
 	}
 
-	// Sort a copy by weight descending.
-	sorted := make([]config.TargetConfig, len(cfg.Targets))
-	copy(sorted, cfg.Targets)
-	slices.SortFunc(sorted, func(a, b config.TargetConfig) int {
-		return b.Weight - a.Weight
+	// Sort by effective weight descending.
+	slices.SortStableFunc(sorted, func(a, b row) int {
+		return cmp.Compare(b.w, a.w)
 	})
 
 	fmt.Printf("Targets (%d):\n", len(sorted))
 	fmt.Printf("  %-40s %-10s %-10s %s\n", "URL", "TYPE", "WEIGHT", "SHARE")
-	for _, t := range sorted {
+	for _, r := range sorted {
 		share := 0.0
 		if totalWeight > 0 {
-			share = float64(t.Weight) / float64(totalWeight) * 100
+			share = r.w / totalWeight * 100
 		}
-		fmt.Printf("  %-40s %-10s %-10d %.1f%%\n", t.URL, t.Type, t.Weight, share)
+		weight := strconv.FormatFloat(r.t.Weight, 'g', -1, 64)
+		if r.t.Share > 0 {
+			weight = strconv.FormatFloat(float64(r.t.Share), 'g', -1, 64) + "%"
+		}
+		fmt.Printf("  %-40s %-10s %-10s %.1f%%\n", r.t.URL, r.t.Type, weight, share)
 	}
-	fmt.Printf("  Total weight: %d\n", totalWeight)
+	fmt.Printf("  Total weight: %g\n", totalWeight)
 	fmt.Println()
 
 	// Pacing.
@@ -857,7 +1617,13 @@ func printDryRun(path string, cfg *config.Config, duration time.Duration) {
 		fmt.Printf("Pacing:\n  mode: human | delay: %dms–%dms (random uniform)\n", p.MinDelayMs, p.MaxDelayMs)
 	case "rate_limited":
 		rps := p.RequestsPerMinute / 60.0
-		fmt.Printf("Pacing:\n  mode: rate_limited | rpm: %.0f (~%.2f rps) | jitter: ≤200ms\n", p.RequestsPerMinute, rps)
+		// As in Scheduler.rateLimitedWait: up to jitter_factor of the
+		// interval between requests.
+		var maxJitter time.Duration
+		if p.RequestsPerMinute > 0 {
+			maxJitter = time.Duration(p.JitterFactor * float64(time.Minute) / p.RequestsPerMinute).Round(time.Millisecond)
+		}
+		fmt.Printf("Pacing:\n  mode: rate_limited | rpm: %.0f (~%.2f rps) | jitter: ≤%s (jitter_factor %g)\n", p.RequestsPerMinute, rps, maxJitter, p.JitterFactor)
 	case "scheduled":
 		fmt.Printf("Pacing:\n  mode: scheduled\n")
 		for i, s := range p.Schedule {
@@ -877,6 +1643,13 @@ func printDryRun(path string, cfg *config.Config, duration time.Duration) {
 	default:
 		fmt.Printf("Pacing:\n  mode: %s\n", p.Mode)
 	}
+	if p.LoadModel == "closed" {
+		users := p.VirtualUsers
+		if users == 0 {
+			users = cfg.Limits.MaxWorkers
+		}
+		fmt.Printf("  load model: closed | virtual users: %d (each waits for its request, then paces)\n", users)
+	}
 	fmt.Println()
 
 	// Limits.
@@ -886,6 +1659,62 @@ func printDryRun(path string, cfg *config.Config, duration time.Duration) {
 }
 
 func initLogger(level, format string) {
+	initLoggerTo(os.Stderr, level, format)
+}
+
+// tagRunID returns id, or a new UUID when id is empty, and adds it as
+// run_id to every log line written from here on.
+func tagRunID(id string) string {
+	if id == "" {
+		id = uuid.NewString()
+	}
+	log.Logger = log.With().Str("run_id", id).Logger()
+	return id
+}
+
+// openTaskLog returns the logger for per-task events selected by
+// daemon.task_log, and a function that closes its file, if any. Call it
+// after the main logger is set up: text and json follow log_format, and
+// run_id is carried over.
+func openTaskLog(d config.DaemonConfig) (zerolog.Logger, func(), error) {
+	tl := d.TaskLog
+	sampler := taskLogSampler{rate: tl.SampleRate, errorRate: tl.SampleErrors}
+	switch tl.Mode {
+	case "none":
+		return zerolog.Nop(), func() {}, nil
+	case "file":
+		lf, err := logfile.Open(tl.File, d.LogMaxSizeMB, d.LogMaxBackups)
+		if err != nil {
+			return zerolog.Logger{}, nil, fmt.Errorf("daemon.task_log: %w", err)
+		}
+		var w io.Writer = lf
+		if d.LogFormat == "text" {
+			w = zerolog.ConsoleWriter{Out: lf, NoColor: true, TimeFormat: time.RFC3339}
+		}
+		// Output keeps the context of the main logger, including run_id.
+		return log.Output(w).Sample(sampler), func() { _ = lf.Close() }, nil
+	default:
+		return log.Logger.Sample(sampler), func() {}, nil
+	}
+}
+
+// taskLogSampler keeps each task event with the probability set for its
+// level. Tasks that failed are logged at warn or error.
+type taskLogSampler struct {
+	rate, errorRate float64
+}
+
+func (s taskLogSampler) Sample(lvl zerolog.Level) bool {
+	rate := s.rate
+	if lvl >= zerolog.WarnLevel {
+		rate = s.errorRate
+	}
+	return rate >= 1 || rand.Float64() < rate //nolint:gosec
+}
+
+// initLoggerTo is initLogger writing to w; text output is uncoloured unless
+// w is stderr.
+func initLoggerTo(w io.Writer, level, format string) {
 	lvl, err := zerolog.ParseLevel(level)
 	if err != nil {
 		lvl = zerolog.InfoLevel
@@ -894,20 +1723,24 @@ func initLogger(level, format string) {
 
 	if format == "text" {
 		log.Logger = log.Output(zerolog.ConsoleWriter{
-			Out:        os.Stderr,
+			Out:        w,
+			NoColor:    w != io.Writer(os.Stderr),
 			TimeFormat: time.RFC3339,
 		})
+	} else if w != io.Writer(os.Stderr) {
+		log.Logger = log.Output(w)
 	}
 }
 
-func writePID(path string) error {
-	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())), 0o600)
-}
-
-func readPID(path string) (int, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return 0, err
+// sleepCtx waits for d or until ctx is done, whichever comes first.
+func sleepCtx(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
 	}
-	return strconv.Atoi(string(data))
 }
@@ -1,19 +1,33 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/coder/websocket"
 	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/control"
+	"github.com/lewta/sendit/internal/report"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
 )
 
 // writePIDFile writes pid to a temp file and returns the path.
@@ -146,6 +160,17 @@ func TestStartCmd_DurationFlag(t *testing.T) {
 	}
 }
 
+// TestStartCmd_ProfileFlag verifies --profile is registered on startCmd and
+// validateCmd.
+func TestStartCmd_ProfileFlag(t *testing.T) {
+	if f := startCmd().Flags().Lookup("profile"); f == nil {
+		t.Error("--profile flag not registered on startCmd")
+	}
+	if f := validateCmd().Flags().Lookup("profile"); f == nil {
+		t.Error("--profile flag not registered on validateCmd")
+	}
+}
+
 // TestStartCmd_BurstRequiresDuration verifies that starting with pacing.mode=burst
 // but no --duration returns a clear error rather than running indefinitely.
 func TestStartCmd_BurstRequiresDuration(t *testing.T) {
@@ -244,6 +269,30 @@ func TestExportCmd_PCAPRequired(t *testing.T) {
 	}
 }
 
+func TestExportDashboardCmd_WritesGrafanaJSON(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "dash.json")
+	cmd := exportCmd()
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"dashboard", "--output", out, "--title", "Staging"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("export dashboard: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dash struct {
+		Title  string           `json:"title"`
+		Panels []map[string]any `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dash); err != nil {
+		t.Fatalf("dashboard is not JSON: %v", err)
+	}
+	if dash.Title != "Staging" || len(dash.Panels) == 0 {
+		t.Errorf("title %q, %d panels", dash.Title, len(dash.Panels))
+	}
+}
+
 // --- statusCmd ---
 
 func TestStatusCmd_MissingPIDFile(t *testing.T) {
@@ -274,6 +323,20 @@ func TestStatusCmd_DeadProcess(t *testing.T) {
 	}
 }
 
+func TestStatusCmd_FullFallsBackWithoutSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "none.sock")
+	out, err := runControlCmd(statusCmd(), socket, "--full", "--pid-file", writePIDFile(t, os.Getpid()))
+	if err != nil {
+		t.Fatalf("status --full: %v", err)
+	}
+	if !strings.HasPrefix(out, "Running (PID") || !strings.Contains(out, "Live stats unavailable: cannot reach sendit") {
+		t.Errorf("output = %q", out)
+	}
+	if _, err := runControlCmd(statusCmd(), socket, "--json"); err == nil {
+		t.Error("status --json without a daemon: want error")
+	}
+}
+
 // --- detectProbeType ---
 
 func TestDetectProbeType(t *testing.T) {
@@ -295,6 +358,185 @@ func TestDetectProbeType(t *testing.T) {
 	}
 }
 
+// --- probeWS ---
+
+func TestProbeWS_ConnectAndEcho(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow() //nolint:errcheck
+		typ, msg, err := c.Read(r.Context())
+		if err != nil {
+			return
+		}
+		if string(msg) == "twist" {
+			msg = []byte("twisted")
+		}
+		_ = c.Write(r.Context(), typ, msg)
+	}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	res, err := probeWS(context.Background(), url, "")
+	if err != nil || res.status != 101 || res.connect <= 0 || res.echo != 0 {
+		t.Errorf("connect only: %+v, %v", res, err)
+	}
+	res, err = probeWS(context.Background(), url, "ping")
+	if err != nil || res.echo <= 0 || !res.matched {
+		t.Errorf("echo: %+v, %v", res, err)
+	}
+	res, err = probeWS(context.Background(), url, "twist")
+	if err != nil || res.matched {
+		t.Errorf("differing reply: %+v, %v", res, err)
+	}
+}
+
+// --- probe --count / --json / --max-loss ---
+
+func TestProbeCmd_CountJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	cmd := probeCmd()
+	cmd.SetArgs([]string{srv.URL, "--count", "3", "--interval", "10ms", "--json"})
+	var err error
+	out := captureStdout(t, func() { err = cmd.Execute() })
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("want 3 attempt lines and a summary, got %d:\n%s", len(lines), out)
+	}
+	var rec probeRecord
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil || rec.Seq != 1 || rec.Status != 200 || rec.Bytes != 2 {
+		t.Errorf("first line = %+v, %v", rec, err)
+	}
+	var sum probeSummaryRecord
+	if err := json.Unmarshal([]byte(lines[3]), &sum); err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if sum.Sent != 3 || sum.OK != 3 || sum.LossPct != 0 || sum.MaxMs <= 0 {
+		t.Errorf("summary = %+v", sum)
+	}
+}
+
+func TestProbeCmd_MaxLossExitsNonZero(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close() // every attempt now fails to connect
+
+	cmd := probeCmd()
+	cmd.SetArgs([]string{url, "--count", "2", "--interval", "10ms", "--max-loss", "50"})
+	cmd.SetErr(io.Discard)
+	var err error
+	captureStdout(t, func() { err = cmd.Execute() })
+	if err == nil || !strings.Contains(err.Error(), "--max-loss") {
+		t.Errorf("expected max-loss error, got %v", err)
+	}
+}
+
+func TestProbeCmd_Deadline(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	cmd := probeCmd()
+	cmd.SetArgs([]string{srv.URL, "--interval", "20ms", "--deadline", "100ms", "--json"})
+	start := time.Now()
+	var err error
+	captureStdout(t, func() { err = cmd.Execute() })
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("--deadline not honoured: ran for %s", elapsed)
+	}
+}
+
+func TestProbeCmd_TraceJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	cmd := probeCmd()
+	cmd.SetArgs([]string{srv.URL, "--count", "2", "--interval", "10ms", "--json", "--trace"})
+	var err error
+	out := captureStdout(t, func() { err = cmd.Execute() })
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for _, line := range lines[:2] {
+		var rec probeRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("unmarshal %q: %v", line, err)
+		}
+		// Connection: close means each attempt dials again.
+		if rec.Trace == nil || rec.Trace.ConnectMs <= 0 || rec.Trace.TTFBMs <= 0 {
+			t.Errorf("attempt %d trace = %+v", rec.Seq, rec.Trace)
+		}
+	}
+}
+
+func TestProbeCmd_TraceRequiresHTTP(t *testing.T) {
+	cmd := probeCmd()
+	cmd.SetArgs([]string{"example.com", "--trace"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--trace") {
+		t.Errorf("expected --trace error for dns target, got %v", err)
+	}
+}
+
+func TestProbeTrace_String(t *testing.T) {
+	p := probeTrace{ConnectMs: 1.2, TTFBMs: 10, TransferMs: 0.5}
+	if got, want := p.String(), "connect 1.2ms  ttfb 10.0ms  transfer 0.5ms"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := probeTraceFromMeta(map[string]string{"dns_ms": "3.500", "tls_ms": "7.000"}); got.DNSMs != 3.5 || got.TLSMs != 7 {
+		t.Errorf("probeTraceFromMeta = %+v", got)
+	}
+}
+
+// --- probeTLS ---
+
+func TestProbeTLS_ReportsUntrustedCert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	res, err := probeTLS(context.Background(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "verify") {
+		t.Errorf("err = %v, want verification error for the test server's self-signed cert", err)
+	}
+	if res.version == "" || res.handshake <= 0 || res.notAfter.IsZero() {
+		t.Errorf("result = %+v, want handshake details despite verification failure", res)
+	}
+	if !strings.HasPrefix(res.expiry(), "expires in ") {
+		t.Errorf("expiry() = %q", res.expiry())
+	}
+}
+
+func TestProbeTLSAddr(t *testing.T) {
+	cases := []struct{ in, addr, host string }{
+		{"example.com", "example.com:443", "example.com"},
+		{"example.com:8443", "example.com:8443", "example.com"},
+		{"https://example.com/path", "example.com:443", "example.com"},
+		{"https://[::1]:8443/", "[::1]:8443", "::1"},
+	}
+	for _, c := range cases {
+		addr, host := probeTLSAddr(c.in)
+		if addr != c.addr || host != c.host {
+			t.Errorf("probeTLSAddr(%q) = %q, %q; want %q, %q", c.in, addr, host, c.addr, c.host)
+		}
+	}
+}
+
 // --- probeRcodeLabel ---
 
 func TestProbeRcodeLabel(t *testing.T) {
@@ -531,3 +773,598 @@ func TestPrintDryRun_EmptyTargets(t *testing.T) {
 		t.Errorf("expected 'Targets (0)' in output, got: %q", out)
 	}
 }
+
+// TestConfigDumpCmd verifies that 'sendit config dump' prints the resolved
+// config, including built-in defaults the file does not set.
+func TestConfigDumpCmd(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "c.yaml")
+	if err := os.WriteFile(cfgPath, []byte(`
+targets:
+  - url: "https://example.com"
+    weight: 1
+    type: http
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := configCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"dump", "--config", cfgPath})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config dump: %v", err)
+	}
+	if !strings.Contains(out.String(), "default_rps: 0.5") {
+		t.Errorf("dump missing default rate limit:\n%s", out.String())
+	}
+}
+
+// --- report ---
+
+func TestReportCmd(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "r.jsonl")
+	if err := os.WriteFile(in, []byte(`{"ts":"2026-10-14T12:00:00Z","url":"https://example.com/","type":"http","status":200,"duration_ms":12,"bytes":100}
+{"ts":"2026-10-14T12:00:01Z","url":"https://example.com/","type":"http","status":500,"duration_ms":30,"bytes":0}
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := reportCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{in})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if !strings.Contains(out.String(), "Requests: 2 (50.0% errors)") || !strings.Contains(out.String(), "status 500") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+
+	html := filepath.Join(dir, "r.html")
+	cmd = reportCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{in, "--html", html})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("report --html: %v", err)
+	}
+	if b, err := os.ReadFile(html); err != nil || !strings.Contains(string(b), "<table>") {
+		t.Errorf("HTML report not written: %v", err)
+	}
+}
+
+// --- run ---
+
+func runTestConfig(t *testing.T, url string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "run.yaml")
+	if err := os.WriteFile(p, []byte(`
+pacing:
+  mode: rate_limited
+  requests_per_minute: 6000
+limits:
+  max_workers: 2
+  cpu_threshold_pct: 100
+  memory_threshold_mb: 1048576
+rate_limits:
+  default_rps: 100
+targets:
+  - url: "`+url+`"
+    weight: 1
+    type: http
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRunCmd_PassesAndFailsOnErrorRate(t *testing.T) {
+	var fail atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	cfgPath := runTestConfig(t, srv.URL)
+
+	cmd := runCmd()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"-c", cfgPath, "--duration", "500ms", "--max-error-rate", "0", "--log-level", "error"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("run with healthy target: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "(0.0% errors)") {
+		t.Errorf("expected summary in output:\n%s", out.String())
+	}
+
+	fail.Store(true)
+	cmd = runCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"-c", cfgPath, "--duration", "500ms", "--max-error-rate", "0", "--log-level", "error"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--max-error-rate") {
+		t.Errorf("expected error-rate failure, got %v", err)
+	}
+}
+
+func TestRunCmd_RequiresDuration(t *testing.T) {
+	cmd := runCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"-c", "unused.yaml"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "--duration") {
+		t.Errorf("expected --duration error, got %v", err)
+	}
+}
+
+func TestCheckRunThresholds(t *testing.T) {
+	rep := report.Report{Overall: report.Stats{Count: 10, Errors: 1, P95: 250}}
+	if err := checkRunThresholds(rep, 10, 300*time.Millisecond); err != nil {
+		t.Errorf("within thresholds: %v", err)
+	}
+	if err := checkRunThresholds(rep, 5, 0); err == nil {
+		t.Error("expected error-rate breach")
+	}
+	if err := checkRunThresholds(rep, 100, 200*time.Millisecond); err == nil || !strings.Contains(err.Error(), "p95") {
+		t.Errorf("expected p95 breach, got %v", err)
+	}
+}
+
+// --- init ---
+
+func TestInitCmd_FlagsWriteValidConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "sendit.yaml")
+	cmd := initCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"-o", cfgPath, "--url", "https://example.com,example.com", "--mode", "rate_limited", "--rpm", "120", "--yes"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("generated config does not load: %v", err)
+	}
+	if cfg.Pacing.Mode != "rate_limited" || cfg.Pacing.RequestsPerMinute != 120 {
+		t.Errorf("pacing = %+v", cfg.Pacing)
+	}
+	if cfg.RateLimits.DefaultRPS != 2 {
+		t.Errorf("default_rps = %g, want 2 so the per-domain limit does not cap 120 rpm", cfg.RateLimits.DefaultRPS)
+	}
+	if len(cfg.Targets) != 2 || cfg.Targets[0].Type != "http" || cfg.Targets[1].Type != "dns" {
+		t.Errorf("targets = %+v", cfg.Targets)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "targets.txt")); err != nil {
+		t.Errorf("targets file not written next to config: %v", err)
+	}
+}
+
+func TestInitCmd_Interactive(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "c.yaml")
+	cmd := initCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetIn(strings.NewReader("wss://echo.example.com\n\ny\n"))
+	cmd.SetArgs([]string{"-o", cfgPath})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("generated config does not load: %v", err)
+	}
+	if cfg.Pacing.Mode != "human" || !cfg.Metrics.Enabled {
+		t.Errorf("mode = %q, metrics = %v; want human and enabled", cfg.Pacing.Mode, cfg.Metrics.Enabled)
+	}
+	if len(cfg.Targets) != 1 || cfg.Targets[0].Type != "websocket" {
+		t.Errorf("targets = %+v", cfg.Targets)
+	}
+}
+
+func TestInitCmd_RejectsUnknownMode(t *testing.T) {
+	cmd := initCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"-o", filepath.Join(t.TempDir(), "c.yaml"), "--mode", "burst", "--yes"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "mode") {
+		t.Errorf("expected mode error, got %v", err)
+	}
+}
+
+func TestInitCmd_OverwriteAbort(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "c.yaml")
+	if err := os.WriteFile(cfgPath, []byte("existing"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cmd := initCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetIn(strings.NewReader("n\n"))
+	cmd.SetArgs([]string{"-o", cfgPath, "--yes"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected abort error")
+	}
+	if b, _ := os.ReadFile(cfgPath); string(b) != "existing" {
+		t.Error("config was overwritten despite answering no")
+	}
+}
+
+// startWithControlSocket runs 'sendit start --foreground' against url for
+// duration and returns its control socket once it is listening, plus a
+// channel that receives start's result.
+func startWithControlSocket(t *testing.T, url string, duration time.Duration) (string, <-chan error) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "sendit.sock")
+	cfgPath := runTestConfig(t, url)
+	f, err := os.OpenFile(cfgPath, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, "daemon:\n  control_socket: %q\n", socket)
+	f.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		cmd := startCmd()
+		cmd.SetOut(io.Discard)
+		cmd.SetArgs([]string{"-c", cfgPath, "--foreground", "--duration", duration.String(), "--log-level", "error"})
+		done <- cmd.Execute()
+	}()
+	for i := 0; i < 200; i++ {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return socket, done
+}
+
+// runControlCmd executes cmd with args plus --socket and returns its stdout.
+func runControlCmd(cmd *cobra.Command, socket string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs(append(args, "--socket", socket))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestTargetsCmd_AgainstRunningStart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	socket, done := startWithControlSocket(t, srv.URL, 2*time.Second)
+
+	targets := func(args ...string) (string, error) {
+		return runControlCmd(targetsCmd(), socket, args...)
+	}
+
+	out, err := targets("add", srv.URL+"/extra", "--weight", "3")
+	if err != nil || !strings.Contains(out, "2 target(s)") {
+		t.Fatalf("add: %q, %v", out, err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	out, err = targets("list")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !strings.Contains(out, srv.URL+"/extra *") || !strings.Contains(out, "75.0%") {
+		t.Errorf("list output missing runtime target:\n%s", out)
+	}
+	out, err = targets("list", "--json")
+	if err != nil {
+		t.Fatalf("list --json: %v", err)
+	}
+	var list struct {
+		Targets []struct {
+			URL      string `json:"url"`
+			Requests int64  `json:"requests"`
+		} `json:"targets"`
+	}
+	if err := json.Unmarshal([]byte(out), &list); err != nil || len(list.Targets) != 2 {
+		t.Fatalf("list --json = %s (%v)", out, err)
+	}
+	if list.Targets[0].Requests+list.Targets[1].Requests == 0 {
+		t.Errorf("no requests counted after 300ms:\n%s", out)
+	}
+	if out, err = targets("remove", srv.URL); err != nil || !strings.Contains(out, "1 target(s)") {
+		t.Errorf("remove: %q, %v", out, err)
+	}
+	if _, err = targets("remove", srv.URL+"/extra"); err == nil {
+		t.Error("removing the last target should fail")
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := targets("list"); err == nil {
+		t.Error("list after the daemon exited should fail")
+	}
+}
+
+// --- daemon ---
+
+func TestPIDFile_RecordsStartTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sendit.pid")
+	if err := writePID(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := readPIDInfo(path)
+	if err != nil {
+		t.Fatalf("readPIDInfo: %v", err)
+	}
+	if info.PID != os.Getpid() || time.Since(info.Started) > time.Minute {
+		t.Errorf("info = %+v", info)
+	}
+
+	// PID files written before the start time was recorded hold only the PID.
+	info, err = readPIDInfo(writePIDFile(t, 42))
+	if err != nil || info.PID != 42 || !info.Started.IsZero() {
+		t.Errorf("legacy PID file: %+v, %v", info, err)
+	}
+}
+
+func TestDetach_RefusesWhenAlreadyRunning(t *testing.T) {
+	pidFile := writePIDFile(t, os.Getpid())
+	cmd := startCmd()
+	err := detach(cmd, config.DaemonConfig{PIDFile: pidFile, LogFile: filepath.Join(t.TempDir(), "sendit.log")})
+	if err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("want already-running error, got %v", err)
+	}
+}
+
+func TestLogTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sendit.log")
+	if err := os.WriteFile(path, []byte("old run\nline 1\nline 2\nline 3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := logTail(path, int64(len("old run\n")), 2); got != "line 2\nline 3\n" {
+		t.Errorf("logTail = %q", got)
+	}
+}
+
+func TestStatusCmd_FullAgainstRunningStart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	socket, done := startWithControlSocket(t, srv.URL, 2*time.Second)
+	time.Sleep(1200 * time.Millisecond) // let a few requests complete
+
+	out, err := runControlCmd(statusCmd(), socket, "--full")
+	if err != nil {
+		t.Fatalf("status --full: %v", err)
+	}
+	for _, want := range []string{"Running (PID", "sha256", "Pacing:", "dispatching", "req/s", "  http:", "100.0%", "Backoff:"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("status --full output missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = runControlCmd(statusCmd(), socket, "--json")
+	if err != nil {
+		t.Fatalf("status --json: %v", err)
+	}
+	var st control.Status
+	if err := json.Unmarshal([]byte(out), &st); err != nil {
+		t.Fatalf("decoding %q: %v", out, err)
+	}
+	if st.PID != os.Getpid() || st.Requests == 0 || st.Errors != st.Requests || len(st.ByType) != 1 {
+		t.Errorf("status = %+v", st)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("start: %v", err)
+	}
+}
+
+func TestPauseResumeCmd_AgainstRunningStart(t *testing.T) {
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits.Add(1) }))
+	defer srv.Close()
+	socket, done := startWithControlSocket(t, srv.URL, 3*time.Second)
+
+	if out, err := runControlCmd(pauseCmd(), socket); err != nil || !strings.Contains(out, "Paused") {
+		t.Fatalf("pause: %q, %v", out, err)
+	}
+	if out, err := runControlCmd(pauseCmd(), socket); err != nil || !strings.Contains(out, "Already paused since") {
+		t.Errorf("second pause: %q, %v", out, err)
+	}
+	time.Sleep(300 * time.Millisecond) // let requests dispatched before the pause finish
+	before := hits.Load()
+	time.Sleep(700 * time.Millisecond)
+	if n := hits.Load(); n != before {
+		t.Errorf("%d requests sent while paused", n-before)
+	}
+
+	if out, err := runControlCmd(resumeCmd(), socket); err != nil || !strings.Contains(out, "Resumed after") {
+		t.Fatalf("resume: %q, %v", out, err)
+	}
+	if out, err := runControlCmd(resumeCmd(), socket); err != nil || !strings.Contains(out, "Not paused") {
+		t.Errorf("second resume: %q, %v", out, err)
+	}
+	deadline := time.Now().Add(1500 * time.Millisecond)
+	for hits.Load() == before && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if hits.Load() == before {
+		t.Error("no requests sent after resume")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("start: %v", err)
+	}
+}
+
+// --- serve ---
+
+func TestServeCmd_AnswersUntilCancelled(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := serveCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{"--http", addr, "--dns", "", "--error-rate", "100", "--error-status", "503"})
+	done := make(chan error, 1)
+	go func() { done <- cmd.ExecuteContext(ctx) }()
+
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		if resp, err = http.Get("http://" + addr + "/"); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("serve never answered: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want injected 503", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+}
+
+func TestOpenTaskLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	d := config.DaemonConfig{
+		LogFormat: "json",
+		TaskLog:   config.TaskLogConfig{Mode: "file", File: path, SampleRate: 0.000001, SampleErrors: 1},
+	}
+	lg, closeLog, err := openTaskLog(d)
+	if err != nil {
+		t.Fatalf("openTaskLog: %v", err)
+	}
+	lg.Info().Msg("task complete")
+	lg.Error().Msg("permanent error, skipping")
+	closeLog()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if strings.Contains(out, "task complete") || !strings.Contains(out, "permanent error") {
+		t.Errorf("task log = %q, want only the sampled-in error", out)
+	}
+
+	d.TaskLog.Mode = "none"
+	lg, closeLog, err = openTaskLog(d)
+	if err != nil {
+		t.Fatalf("openTaskLog: %v", err)
+	}
+	defer closeLog()
+	if lg.GetLevel() != zerolog.Disabled {
+		t.Errorf("mode none logger level = %s, want disabled", lg.GetLevel())
+	}
+}
+
+// --- import toplist ---
+
+func TestParseToplist(t *testing.T) {
+	got, err := parseToplist([]byte("rank,domain\n3,Example.org.\n1,google.com\n2,https://www.facebook.com/home\n\n# comment\n4,google.com\n"), 10)
+	if err != nil {
+		t.Fatalf("parseToplist: %v", err)
+	}
+	want := []rankedDomain{{1, "google.com"}, {2, "www.facebook.com"}, {3, "example.org"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ranked = %v, want %v", got, want)
+	}
+
+	got, err = parseToplist([]byte("a.example\nb.example\nc.example\n"), 2)
+	if err != nil || len(got) != 2 || got[1] != (rankedDomain{2, "b.example"}) {
+		t.Errorf("bare list = %v, %v", got, err)
+	}
+
+	if _, err := parseToplist([]byte("1,a.example\nx,b.example\n"), 10); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected line 2 error, got %v", err)
+	}
+}
+
+func TestToplistWeight(t *testing.T) {
+	for _, tc := range []struct {
+		weighting string
+		first     float64
+	}{
+		{"zipf", 4},
+		{"log", 1 + math.Log(4)},
+		{"linear", 4},
+		{"equal", 1},
+	} {
+		opts := toplistOptions{weighting: tc.weighting, exponent: 1}
+		if w := toplistWeight(0, 4, opts); math.Abs(w-tc.first) > 1e-9 {
+			t.Errorf("%s: weight of first = %g, want %g", tc.weighting, w, tc.first)
+		}
+		if w := toplistWeight(3, 4, opts); w != 1 {
+			t.Errorf("%s: weight of last = %g, want 1", tc.weighting, w)
+		}
+	}
+	if w := toplistWeight(0, 4, toplistOptions{weighting: "zipf", exponent: 2}); w != 16 {
+		t.Errorf("zipf s=2: weight of first = %g, want 16", w)
+	}
+}
+
+func TestImportToplistCmd_ZipToValidTargetsFile(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("top-1m.csv")
+	fmt.Fprint(f, "1,google.com\n2,facebook.com\n3,example.org\n4,example.net\n")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	targetsPath := filepath.Join(dir, "targets.txt")
+	cmd := importCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"toplist", srv.URL + "/top-1m.csv.zip", "--count", "3", "--mix", "http=3,dns=1", "-o", targetsPath})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("import toplist: %v", err)
+	}
+
+	cfgPath := filepath.Join(dir, "sendit.yaml")
+	if err := os.WriteFile(cfgPath, []byte("targets_file: "+strconv.Quote(targetsPath)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("imported targets file does not load: %v", err)
+	}
+	if len(cfg.Targets) != 6 {
+		t.Fatalf("targets = %d, want 6 (3 domains × 2 types)", len(cfg.Targets))
+	}
+	first, dns := cfg.Targets[0], cfg.Targets[1]
+	if first.URL != "https://google.com/" || first.Type != "http" || first.Weight != 2.25 {
+		t.Errorf("first target = %s %s %g, want https://google.com/ http 2.25", first.URL, first.Type, first.Weight)
+	}
+	if dns.URL != "google.com" || dns.Type != "dns" || dns.Weight != 0.75 {
+		t.Errorf("second target = %s %s %g, want google.com dns 0.75", dns.URL, dns.Type, dns.Weight)
+	}
+	if last := cfg.Targets[5]; last.URL != "example.org" || last.Weight != 0.25 {
+		t.Errorf("last target = %s %g, want example.org 0.25", last.URL, last.Weight)
+	}
+}
+
+func TestImportToplistCmd_RejectsBadMix(t *testing.T) {
+	cmd := importToplistCmd()
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"list.txt", "--mix", "http=3,ftp=1"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "ftp") {
+		t.Errorf("expected --mix error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lewta/sendit/internal/control"
+	"github.com/spf13/cobra"
+)
+
+// pauseCmd returns the cobra command for 'sendit pause'.
+func pauseCmd() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "pause",
+		Short: "Stop a running daemon from sending new requests",
+		Long: `Pause dispatch of a running 'sendit start' through its control socket
+(daemon.control_socket). Requests already in flight finish; no new ones are
+started until 'sendit resume'. The process keeps running with its config,
+connections, rate limiters, and counters intact, so resuming is instant —
+unlike stopping and starting again.
+
+A pause lasts until resume, a restart, or the end of --duration; SIGHUP
+and other reloads do not lift it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := control.NewClient(socket).Pause(cmd.Context())
+			if err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if !st.Changed {
+				fmt.Fprintf(out, "Already paused%s\n", pausedSince(st))
+				return nil
+			}
+			fmt.Fprintln(out, "Paused: no new requests will be sent (in-flight requests finish). Run 'sendit resume' to continue.")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&socket, "socket", defaultControlSocket, "Path to the daemon's control socket")
+	return cmd
+}
+
+// resumeCmd returns the cobra command for 'sendit resume'.
+func resumeCmd() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Resume a daemon paused with 'sendit pause'",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			st, err := control.NewClient(socket).Resume(cmd.Context())
+			if err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if !st.Changed {
+				fmt.Fprintln(out, "Not paused; dispatch is running")
+				return nil
+			}
+			if st.Since != nil {
+				fmt.Fprintf(out, "Resumed after %s paused\n", time.Since(*st.Since).Round(time.Second))
+				return nil
+			}
+			fmt.Fprintln(out, "Resumed")
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&socket, "socket", defaultControlSocket, "Path to the daemon's control socket")
+	return cmd
+}
+
+// pausedSince formats " since <time> (<duration> ago)" for st, or "" when
+// the start of the pause is unknown.
+func pausedSince(st control.PauseState) string {
+	if st.Since == nil {
+		return ""
+	}
+	return fmt.Sprintf(" since %s (%s ago)", st.Since.Local().Format(time.RFC3339), time.Since(*st.Since).Round(time.Second))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lewta/sendit/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd returns the cobra command for 'sendit report'.
+func reportCmd() *cobra.Command {
+	var (
+		htmlOut string
+		top     int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "report <results.jsonl|results.csv>...",
+		Short: "Summarise result files: latency percentiles, errors, per-target tables",
+		Long: `Read one or more result files written by the output writer (JSONL, or
+CSV when the file ends in .csv) and print a summary: request count, error
+rate and throughput, latency min/avg/p50/p90/p95/p99/max, the most common
+errors, and per-target and per-domain tables.
+
+Several files are combined into one report, so rotated segments can be
+passed together. Latency figures cover successful requests only; a request
+counts as failed when it errored or returned a status of 400 or above.
+
+Examples:
+  sendit report results.jsonl
+  sendit report results.*.jsonl --top 50
+  sendit report results.csv --html report.html`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				records []report.Record
+				skipped int
+			)
+			for _, path := range args {
+				recs, n, err := report.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				records = append(records, recs...)
+				skipped += n
+			}
+			if len(records) == 0 {
+				return fmt.Errorf("no result records found in %s", strings.Join(args, ", "))
+			}
+			rep := report.Summarize(records)
+			rep.Skipped = skipped
+
+			if htmlOut != "" {
+				f, err := os.OpenFile(htmlOut, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+				if err != nil {
+					return fmt.Errorf("opening %q: %w", htmlOut, err)
+				}
+				title := "sendit report: " + filepath.Base(args[0])
+				if len(args) > 1 {
+					title += fmt.Sprintf(" (+%d more)", len(args)-1)
+				}
+				if err := report.WriteHTML(f, rep, title); err != nil {
+					_ = f.Close()
+					return fmt.Errorf("writing %q: %w", htmlOut, err)
+				}
+				if err := f.Close(); err != nil {
+					return fmt.Errorf("closing %q: %w", htmlOut, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Wrote HTML report → %s\n", htmlOut)
+				return nil
+			}
+			return report.WriteText(cmd.OutOrStdout(), rep, top)
+		},
+	}
+
+	cmd.Flags().StringVar(&htmlOut, "html", "", "Write a standalone HTML report to this file instead of printing text")
+	cmd.Flags().IntVar(&top, "top", 20, "Rows shown per table in text output (0 = all)")
+	return cmd
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/lewta/sendit/internal/engine"
+	"github.com/lewta/sendit/internal/metrics"
+	"github.com/lewta/sendit/internal/report"
+	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// runCmd returns the cobra command for 'sendit run'.
+func runCmd() *cobra.Command {
+	var (
+		cfgPath      string
+		profile      string
+		logLevel     string
+		capturePath  string
+		duration     time.Duration
+		maxErrorRate float64
+		maxP95       time.Duration
+		top          int
+		runID        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "Run for a fixed duration, print a summary, and exit",
+		Long: `Run the traffic generator in the foreground for --duration, then print an
+end-of-run summary (the same tables as 'sendit report') and exit.
+
+Unlike 'start' there is no PID file, no SIGHUP or remote-config reload, and
+no TUI, which makes run the CI-friendly counterpart: the config is loaded
+once, Ctrl-C ends the run early and still prints the summary, and the exit
+code reflects the thresholds below.
+
+The command exits non-zero when the error rate exceeds --max-error-rate
+(percent) or, with --max-p95 set, when the p95 latency of successful
+requests exceeds it. A request counts as failed when it errored or returned
+a status of 400 or above; requests still in flight when the run ends are
+not counted. It also exits non-zero when any objective in the config's slo
+section is missed; the objectives and their measured values are printed
+after the summary.
+
+Examples:
+  sendit run -c config.yaml --duration 10m
+  sendit run -c smoke.yaml --duration 2m --max-error-rate 1 --max-p95 800ms`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if duration <= 0 {
+				return fmt.Errorf("--duration is required (e.g. --duration 10m)")
+			}
+
+			lc, err := loadConfig(cmd.Context(), cfgPath, profile)
+			if err != nil {
+				return err
+			}
+			cfg := lc.cfg
+			if capturePath != "" {
+				cfg.Output.PCAPFile = capturePath
+			}
+
+			lvl := cfg.Daemon.LogLevel
+			if logLevel != "" {
+				lvl = logLevel
+			}
+			initLogger(lvl, cfg.Daemon.LogFormat)
+			runID = tagRunID(runID)
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			ctx, cancel := context.WithTimeout(ctx, duration)
+			defer cancel()
+
+			var m *metrics.Metrics
+			if cfg.Metrics.Enabled {
+				m = metrics.NewWithOptions(metrics.Options{PerTarget: cfg.Metrics.PerTarget, RunID: runID})
+				go m.ServeHTTP(ctx, cfg.Metrics.BindAddress, cfg.Metrics.PrometheusPort)
+			} else {
+				m = metrics.Noop()
+			}
+
+			eng, err := engine.New(cfg, m)
+			if err != nil {
+				return fmt.Errorf("creating engine: %w", err)
+			}
+			eng.SetRunID(runID)
+			taskLog, closeTaskLog, err := openTaskLog(cfg.Daemon)
+			if err != nil {
+				return err
+			}
+			defer closeTaskLog()
+			eng.SetTaskLogger(taskLog)
+			// Requests cut off when the run ends are not failures of the
+			// target, so they are left out of the summary.
+			var results report.Collector
+			eng.SetObserver(func(r task.Result) {
+				if ctx.Err() != nil && (errors.Is(r.Error, context.Canceled) || errors.Is(r.Error, context.DeadlineExceeded)) {
+					return
+				}
+				results.Record(r)
+			})
+
+			dumpOnSignal(ctx, eng)
+			log.Info().Dur("duration", duration).Msg("run started")
+			eng.Run(ctx)
+
+			rep := results.Report()
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "\n--- sendit run: %s (run %s) ---\n", cfgPath, runID)
+			if rep.Overall.Count == 0 {
+				fmt.Fprintln(out, "No requests completed.")
+				cmd.SilenceUsage = true
+				return fmt.Errorf("no requests completed within %s", duration)
+			}
+			if err := report.WriteText(out, rep, top); err != nil {
+				return err
+			}
+			var missed []report.Objective
+			if objs := results.CheckSLO(cfg.SLO); len(objs) > 0 {
+				if err := report.WriteSLO(out, objs); err != nil {
+					return err
+				}
+				missed = report.Violations(objs)
+			}
+			if err := checkRunThresholds(rep, maxErrorRate, maxP95); err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			if len(missed) > 0 {
+				cmd.SilenceUsage = true
+				o := missed[0]
+				return fmt.Errorf("%d SLO objective(s) missed, first: %s %s is %s, want %s", len(missed), o.Scope, o.Name, o.Actual, o.Target)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&cfgPath, "config", "c", "config/example.yaml", "Path or http(s)://, s3:// URL of the YAML config file")
+	cmd.Flags().StringVar(&profile, "profile", "", "Apply the named overlay from the config's profiles section")
+	cmd.Flags().StringVar(&logLevel, "log-level", "", "Override log level (debug|info|warn|error)")
+	cmd.Flags().StringVar(&capturePath, "capture", "", "Write a synthetic PCAP file while running (e.g. capture.pcap)")
+	cmd.Flags().DurationVar(&duration, "duration", 0, "How long to run (e.g. 10m, 30s); required")
+	cmd.Flags().Float64Var(&maxErrorRate, "max-error-rate", 100, "Exit non-zero when more than this percentage of requests fail")
+	cmd.Flags().DurationVar(&maxP95, "max-p95", 0, "Exit non-zero when p95 latency of successful requests exceeds this (0 disables)")
+	cmd.Flags().IntVar(&top, "top", 20, "Rows shown per summary table (0 = all)")
+	cmd.Flags().StringVar(&runID, "run-id", "", "ID attached to metrics, logs, and output records of this run (default: a random UUID)")
+	return cmd
+}
+
+// checkRunThresholds returns an error describing the first breached
+// threshold, or nil when the run passed.
+func checkRunThresholds(rep report.Report, maxErrorRate float64, maxP95 time.Duration) error {
+	if rate := rep.Overall.ErrorRate(); rate > maxErrorRate {
+		return fmt.Errorf("error rate %.1f%% exceeds --max-error-rate %g%%", rate, maxErrorRate)
+	}
+	if maxP95 > 0 {
+		p95 := time.Duration(rep.Overall.P95 * float64(time.Millisecond))
+		if p95 > maxP95 {
+			return fmt.Errorf("p95 latency %s exceeds --max-p95 %s", p95, maxP95)
+		}
+	}
+	return nil
+}
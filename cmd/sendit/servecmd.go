@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os/signal"
+	"syscall"
+
+	"github.com/lewta/sendit/internal/echoserver"
+	"github.com/spf13/cobra"
+)
+
+// serveCmd returns the cobra command for 'sendit serve'.
+func serveCmd() *cobra.Command {
+	var (
+		opts     echoserver.Options
+		logLevel string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run a local HTTP/WebSocket/DNS echo server to aim sendit at",
+		Long: `Run a self-contained test target, so that demos, docs, and integration
+tests do not depend on external services. It listens until Ctrl-C.
+
+HTTP (--http) echoes every request back as JSON (method, path, query,
+headers, and body), except for two paths:
+  /status/<code>  respond with that status code, e.g. /status/503
+  /delay/<dur>    wait before responding, e.g. /delay/250ms or /delay/2
+
+WebSocket upgrades on the same address, on any path, are accepted and each
+message is echoed back. DNS (--dns, UDP) answers A queries with 127.0.0.1,
+AAAA with ::1, and TXT with a fixed string for any name; point a dns target
+at it with dns.resolver: "127.0.0.1:5353".
+
+--latency and --jitter delay every response (each WebSocket echo too), and
+--error-rate answers that percentage of requests with --error-status (HTTP
+and WebSocket handshakes) or SERVFAIL (DNS). Pass "" to --http or --dns to
+disable that listener.
+
+Examples:
+  sendit serve
+  sendit serve --latency 50ms --jitter 100ms --error-rate 5
+  sendit serve --http 0.0.0.0:8080 --dns ""`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			initLogger(logLevel, "text")
+
+			srv, err := echoserver.Listen(opts)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if addr := srv.HTTPAddr(); addr != "" {
+				fmt.Fprintf(out, "HTTP echo on http://%s (WebSocket: ws://%s)\n", addr, addr)
+			}
+			if addr := srv.DNSAddr(); addr != "" {
+				fmt.Fprintf(out, "DNS echo on %s (udp)\n", addr)
+			}
+			if opts.Latency > 0 || opts.Jitter > 0 || opts.ErrorRate > 0 {
+				fmt.Fprintf(out, "Injecting %s latency + up to %s jitter, %g%% errors\n", opts.Latency, opts.Jitter, opts.ErrorRate)
+			}
+			fmt.Fprintln(out, "Press Ctrl-C to stop.")
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			return srv.Serve(ctx)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.HTTPAddr, "http", "127.0.0.1:8080", `HTTP and WebSocket listen address ("" to disable)`)
+	cmd.Flags().StringVar(&opts.DNSAddr, "dns", "127.0.0.1:5353", `DNS (UDP) listen address ("" to disable)`)
+	cmd.Flags().DurationVar(&opts.Latency, "latency", 0, "Fixed delay added to every response")
+	cmd.Flags().DurationVar(&opts.Jitter, "jitter", 0, "Extra random delay of up to this much per response")
+	cmd.Flags().Float64Var(&opts.ErrorRate, "error-rate", 0, "Percentage of requests (0-100) answered with an error")
+	cmd.Flags().IntVar(&opts.ErrorStatus, "error-status", 500, "HTTP status returned for injected errors")
+	cmd.Flags().StringVar(&logLevel, "log-level", "info", "Log level: debug logs every request")
+	return cmd
+}
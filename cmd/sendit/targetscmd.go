@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/lewta/sendit/internal/control"
+	"github.com/spf13/cobra"
+)
+
+// defaultControlSocket matches the daemon.control_socket default.
+const defaultControlSocket = "/tmp/sendit.sock"
+
+// targetsCmd returns the cobra command for 'sendit targets' and its
+// subcommands.
+func targetsCmd() *cobra.Command {
+	var socket string
+
+	cmd := &cobra.Command{
+		Use:   "targets",
+		Short: "List, add, or remove targets of a running daemon",
+		Long: `Inspect and change the targets of a running 'sendit start' through its
+control socket (daemon.control_socket), without editing files or sending
+SIGHUP.
+
+Changes take effect immediately and are validated like a reload: a change
+that would leave an invalid config is rejected and nothing is altered.
+They are kept in memory only — they survive SIGHUP and remote config
+refreshes, which are overlaid with them, but are lost when the daemon
+exits. Edit the config or targets_file to make a change permanent.`,
+	}
+	cmd.PersistentFlags().StringVar(&socket, "socket", defaultControlSocket, "Path to the daemon's control socket")
+	cmd.AddCommand(targetsListCmd(&socket), targetsAddCmd(&socket), targetsRemoveCmd(&socket))
+	return cmd
+}
+
+func targetsListCmd(socket *string) *cobra.Command {
+	var jsonOut bool
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List current targets with live counters",
+		Long: `List the daemon's current targets with their effective share of picks and
+counters since the daemon started: requests completed, error rate (errors
+or status 400 and above), average latency, and the last status seen.
+Targets added with 'sendit targets add' are marked with *.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := control.NewClient(*socket).Targets(cmd.Context())
+			if err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			if jsonOut {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(control.TargetList{Targets: targets})
+			}
+			writeTargetsTable(cmd.OutOrStdout(), targets)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&jsonOut, "json", false, "Print the list as JSON")
+	return cmd
+}
+
+func targetsAddCmd(socket *string) *cobra.Command {
+	var (
+		typ    string
+		weight float64
+		share  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add <url>",
+		Short: "Add a target to a running daemon",
+		Long: `Add a target to the running daemon. The type is detected from the URL
+scheme when --type is not given (http(s):// → http, ws(s):// → websocket,
+anything else → dns), and the weight defaults to target_defaults.weight.
+URL patterns such as [1..3] and {a,b} are expanded as in the config.
+
+Examples:
+  sendit targets add https://example.com/new
+  sendit targets add example.org --type dns --weight 2
+  sendit targets add https://api.example.com --share 10%`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			url := args[0]
+			if typ == "" {
+				typ = detectProbeType(url)
+			}
+			fields := map[string]any{"url": url, "type": typ}
+			if weight != 0 {
+				fields["weight"] = weight
+			}
+			if share != "" {
+				fields["share"] = share
+			}
+			targets, err := control.NewClient(*socket).AddTarget(cmd.Context(), fields)
+			if err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Added %s (%s); %d target(s) now active\n", url, typ, len(targets))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&typ, "type", "", "Target type: http | browser | dns | websocket | grpc | sftp (default: from the URL)")
+	cmd.Flags().Float64Var(&weight, "weight", 0, "Relative weight (default: target_defaults.weight)")
+	cmd.Flags().StringVar(&share, "share", "", "Fixed percentage of all picks instead of a weight (e.g. 10%)")
+	return cmd
+}
+
+func targetsRemoveCmd(socket *string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remove <url>",
+		Short: "Remove a target from a running daemon",
+		Long: `Remove every target with the given URL from the running daemon, whether it
+came from the config, targets_file, kv, or 'sendit targets add'. The last
+remaining target cannot be removed.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := control.NewClient(*socket).RemoveTarget(cmd.Context(), args[0])
+			if err != nil {
+				cmd.SilenceUsage = true
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %s; %d target(s) now active\n", args[0], len(targets))
+			return nil
+		},
+	}
+	return cmd
+}
+
+func writeTargetsTable(w io.Writer, targets []control.TargetInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "URL\tTYPE\tWEIGHT\tSHARE\tREQS\tERR%\tSLOW%\tAVG\tLAST")
+	for _, t := range targets {
+		url := t.URL
+		if t.Runtime {
+			url += " *"
+		}
+		errPct, slowPct, avg, last := "-", "-", "-", "-"
+		if t.Requests > 0 {
+			errPct = fmt.Sprintf("%.1f%%", float64(t.Errors)/float64(t.Requests)*100)
+			slowPct = fmt.Sprintf("%.1f%%", float64(t.Slow)/float64(t.Requests)*100)
+			avg = fmt.Sprintf("%.0fms", t.AvgMs)
+			last = fmt.Sprintf("%d", t.LastStatus)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%g\t%.1f%%\t%d\t%s\t%s\t%s\t%s\n",
+			url, t.Type, t.Weight, t.SharePct, t.Requests, errPct, slowPct, avg, last)
+	}
+	_ = tw.Flush()
+}
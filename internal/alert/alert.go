@@ -0,0 +1,384 @@
+// Package alert watches results for the thresholds in the alerts config
+// section and notifies webhooks when one is breached or recovers, so that a
+// long unattended run does not fail silently.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+	queueSize             = 64
+)
+
+// Event is one notification, POSTed as JSON to webhooks with format json.
+type Event struct {
+	Status    string    `json:"status"` // firing | resolved
+	Rule      string    `json:"rule"`
+	Target    string    `json:"target,omitempty"`
+	Message   string    `json:"message"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	RunID     string    `json:"run_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Alerter evaluates alert rules against results. A nil *Alerter ignores
+// every call, so callers need not check whether alerts are configured.
+type Alerter struct {
+	cfg      config.AlertsConfig
+	window   time.Duration
+	interval time.Duration
+	client   *http.Client
+	events   chan Event
+	done     chan struct{}
+	now      func() time.Time
+
+	mu     sync.Mutex
+	runID  string
+	closed bool
+	// samples holds the results of the last window, oldest first; only
+	// kept when an error-rate or latency rule needs them.
+	samples []sample
+	keep    bool
+	streaks map[alertKey]int
+	firing  map[alertKey]bool
+}
+
+type sample struct {
+	at     time.Time
+	url    string
+	failed bool
+	ms     float64
+}
+
+// alertKey identifies one alert: a rule, and for consecutive-failure rules
+// without a target, the target it fired for.
+type alertKey struct {
+	rule   int
+	target string
+}
+
+// New returns an Alerter for cfg, or nil when no rules are configured.
+func New(cfg config.AlertsConfig) *Alerter {
+	if len(cfg.Rules) == 0 {
+		return nil
+	}
+	a := &Alerter{
+		cfg:      cfg,
+		window:   time.Duration(cfg.WindowS) * time.Second,
+		interval: time.Duration(cfg.CheckIntervalS) * time.Second,
+		client:   &http.Client{},
+		events:   make(chan Event, queueSize),
+		done:     make(chan struct{}),
+		now:      time.Now,
+		streaks:  make(map[alertKey]int),
+		firing:   make(map[alertKey]bool),
+	}
+	go a.deliver()
+	for _, r := range cfg.Rules {
+		if r.ConsecutiveFailures == 0 {
+			a.keep = true
+		}
+	}
+	return a
+}
+
+// Start evaluates the error-rate and latency rules every check interval
+// until ctx is cancelled. runID is included in every notification.
+func (a *Alerter) Start(ctx context.Context, runID string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	a.runID = runID
+	a.mu.Unlock()
+
+	if !a.keep {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.check()
+			}
+		}
+	}()
+}
+
+// Close delivers the notifications still queued and stops the Alerter.
+// Results recorded afterwards are ignored.
+func (a *Alerter) Close() {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return
+	}
+	a.closed = true
+	close(a.events)
+	a.mu.Unlock()
+	<-a.done
+}
+
+// Record adds r to the window and updates consecutive-failure counts. As in
+// reports, a result failed when it errored or returned a status of 400 or
+// above, and latency is only taken from successful results.
+func (a *Alerter) Record(r task.Result) {
+	if a == nil {
+		return
+	}
+	failed := r.Error != nil || r.StatusCode >= 400
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keep {
+		a.samples = append(a.samples, sample{
+			at:     a.now(),
+			url:    r.Task.URL,
+			failed: failed,
+			ms:     float64(r.Duration.Milliseconds()),
+		})
+	}
+
+	for i, rule := range a.cfg.Rules {
+		if rule.ConsecutiveFailures == 0 || (rule.Target != "" && rule.Target != r.Task.URL) {
+			continue
+		}
+		key := alertKey{rule: i, target: r.Task.URL}
+		if !failed {
+			delete(a.streaks, key)
+			a.transition(key, false, func() (string, float64) {
+				return "requests are succeeding again", 0
+			})
+			continue
+		}
+		a.streaks[key]++
+		n := a.streaks[key]
+		if n < rule.ConsecutiveFailures {
+			continue
+		}
+		a.transition(key, true, func() (string, float64) {
+			return fmt.Sprintf("%d consecutive failures, last: %s", n, reason(r)), float64(n)
+		})
+	}
+}
+
+// check evaluates the error-rate and latency rules over the current window.
+func (a *Alerter) check() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := a.now().Add(-a.window)
+	drop := 0
+	for drop < len(a.samples) && a.samples[drop].at.Before(cutoff) {
+		drop++
+	}
+	a.samples = slices.Delete(a.samples, 0, drop)
+
+	for i, rule := range a.cfg.Rules {
+		if rule.ConsecutiveFailures != 0 {
+			continue
+		}
+		var n, failed int
+		var lat []float64
+		for _, s := range a.samples {
+			if rule.Target != "" && s.url != rule.Target {
+				continue
+			}
+			n++
+			if s.failed {
+				failed++
+			} else {
+				lat = append(lat, s.ms)
+			}
+		}
+		if n == 0 || n < a.cfg.MinRequests {
+			continue
+		}
+
+		key := alertKey{rule: i, target: rule.Target}
+		if rule.ErrorRatePct > 0 {
+			rate := float64(failed) / float64(n) * 100
+			breached := rate > rule.ErrorRatePct
+			a.transition(key, breached, func() (string, float64) {
+				return fmt.Sprintf("error rate %.1f%% over the last %s is %s %g%%",
+					rate, a.window, aboveOrUnder(breached), rule.ErrorRatePct), rate
+			})
+			continue
+		}
+		if len(lat) == 0 {
+			continue
+		}
+		slices.Sort(lat)
+		got := percentile(lat, rule.Latency.Percentile)
+		breached := got > float64(rule.Latency.MaxMs)
+		a.transition(key, breached, func() (string, float64) {
+			return fmt.Sprintf("p%g latency %.0fms over the last %s is %s %dms",
+				rule.Latency.Percentile, got, a.window, aboveOrUnder(breached), rule.Latency.MaxMs), got
+		})
+	}
+}
+
+// transition queues a notification when the alert identified by key starts
+// or stops firing. describe is only called then. a.mu must be held.
+func (a *Alerter) transition(key alertKey, breached bool, describe func() (string, float64)) {
+	if a.firing[key] == breached || a.closed {
+		return
+	}
+	a.firing[key] = breached
+
+	rule := a.cfg.Rules[key.rule]
+	msg, value := describe()
+	ev := Event{
+		Status:    "resolved",
+		Rule:      ruleName(key.rule, rule),
+		Target:    key.target,
+		Message:   msg,
+		Value:     value,
+		Threshold: threshold(rule),
+		RunID:     a.runID,
+		Time:      a.now().UTC(),
+	}
+	lg := log.Info()
+	if breached {
+		ev.Status = "firing"
+		lg = log.Warn()
+	}
+	lg.Str("rule", ev.Rule).Str("target", ev.Target).Str("detail", msg).Msgf("alert %s", ev.Status)
+
+	select {
+	case a.events <- ev:
+	default:
+		log.Warn().Str("rule", ev.Rule).Msg("alerts: notification queue full, dropping notification")
+	}
+}
+
+// deliver POSTs queued events to every webhook until Close.
+func (a *Alerter) deliver() {
+	defer close(a.done)
+	for ev := range a.events {
+		for _, w := range a.cfg.Webhooks {
+			if err := a.post(w, ev); err != nil {
+				log.Warn().Err(err).Str("rule", ev.Rule).Msg("alerts: webhook delivery failed")
+			}
+		}
+	}
+}
+
+// post sends ev to one webhook in its configured format.
+func (a *Alerter) post(w config.AlertWebhookConfig, ev Event) error {
+	var payload any = ev
+	if w.Format == "slack" {
+		payload = map[string]string{"text": slackText(ev)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	timeout := time.Duration(w.TimeoutS) * time.Second
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// slackText renders ev as the text of a Slack incoming-webhook message.
+func slackText(ev Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*[sendit] %s: %s*", strings.ToUpper(ev.Status), ev.Rule)
+	if ev.Target != "" {
+		fmt.Fprintf(&b, " (%s)", ev.Target)
+	}
+	fmt.Fprintf(&b, "\n%s", ev.Message)
+	if ev.RunID != "" {
+		fmt.Fprintf(&b, "\nrun %s", ev.RunID)
+	}
+	return b.String()
+}
+
+// ruleName returns the configured name of rule i, or its config path.
+func ruleName(i int, rule config.AlertRuleConfig) string {
+	if rule.Name != "" {
+		return rule.Name
+	}
+	return fmt.Sprintf("alerts.rules[%d]", i)
+}
+
+func threshold(rule config.AlertRuleConfig) float64 {
+	switch {
+	case rule.ErrorRatePct > 0:
+		return rule.ErrorRatePct
+	case rule.ConsecutiveFailures > 0:
+		return float64(rule.ConsecutiveFailures)
+	default:
+		return float64(rule.Latency.MaxMs)
+	}
+}
+
+func aboveOrUnder(breached bool) string {
+	if breached {
+		return "above"
+	}
+	return "back under"
+}
+
+// reason describes why r failed.
+func reason(r task.Result) string {
+	if r.Error != nil {
+		return r.Error.Error()
+	}
+	return fmt.Sprintf("status %d", r.StatusCode)
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,171 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+)
+
+// webhook records the bodies POSTed to it.
+type webhook struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	b, _ := io.ReadAll(r.Body)
+	w.mu.Lock()
+	w.bodies = append(w.bodies, string(b))
+	w.mu.Unlock()
+}
+
+func (w *webhook) events(t *testing.T) []Event {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []Event
+	for _, b := range w.bodies {
+		var ev Event
+		if err := json.Unmarshal([]byte(b), &ev); err != nil {
+			t.Fatalf("decoding %q: %v", b, err)
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+func result(url string, status int, dur time.Duration) task.Result {
+	return task.Result{Task: task.Task{URL: url, Type: "http"}, StatusCode: status, Duration: dur}
+}
+
+func TestNew_NoRules(t *testing.T) {
+	a := New(config.AlertsConfig{})
+	if a != nil {
+		t.Fatalf("New without rules = %v, want nil", a)
+	}
+	// A nil Alerter ignores every call.
+	a.Record(result("https://a.example/", 500, 0))
+	a.Close()
+}
+
+func TestAlerter_ConsecutiveFailures(t *testing.T) {
+	hook := &webhook{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	a := New(config.AlertsConfig{
+		Webhooks: []config.AlertWebhookConfig{{URL: srv.URL}},
+		Rules:    []config.AlertRuleConfig{{Name: "down", ConsecutiveFailures: 3}},
+	})
+	a.Start(t.Context(), "run-1")
+
+	fail := task.Result{Task: task.Task{URL: "https://a.example/"}, Error: errors.New("connection refused")}
+	a.Record(fail)
+	a.Record(fail)
+	a.Record(result("https://b.example/", 503, 0)) // counted separately
+	a.Record(fail)
+	a.Record(fail) // still firing; no second notification
+	a.Record(result("https://a.example/", 200, 0))
+	a.Close()
+
+	evs := hook.events(t)
+	if len(evs) != 2 {
+		t.Fatalf("got %d notifications, want 2: %+v", len(evs), evs)
+	}
+	if e := evs[0]; e.Status != "firing" || e.Rule != "down" || e.Target != "https://a.example/" || e.RunID != "run-1" ||
+		e.Value != 3 || !strings.Contains(e.Message, "connection refused") {
+		t.Errorf("firing = %+v", e)
+	}
+	if e := evs[1]; e.Status != "resolved" || e.Target != "https://a.example/" {
+		t.Errorf("resolved = %+v", e)
+	}
+}
+
+func TestAlerter_Window(t *testing.T) {
+	hook := &webhook{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	a := New(config.AlertsConfig{
+		Webhooks: []config.AlertWebhookConfig{{URL: srv.URL}},
+		Rules: []config.AlertRuleConfig{
+			{ErrorRatePct: 10},
+			{Target: "https://a.example/", Latency: config.LatencyObjective{Percentile: 90, MaxMs: 100}},
+		},
+		WindowS:     60,
+		MinRequests: 10,
+	})
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	for i := range 9 {
+		a.Record(result("https://a.example/", 500, time.Duration(i)*time.Millisecond))
+	}
+	a.check() // below min_requests
+	for range 11 {
+		a.Record(result("https://a.example/", 200, 500*time.Millisecond))
+	}
+	a.check() // 45% errors, p90 500ms: both fire
+
+	now = now.Add(2 * time.Minute)
+	for range 10 {
+		a.Record(result("https://a.example/", 200, 20*time.Millisecond))
+	}
+	a.check() // old results left the window: both resolve
+	a.Close()
+
+	evs := hook.events(t)
+	want := []struct{ status, rule string }{
+		{"firing", "alerts.rules[0]"},
+		{"firing", "alerts.rules[1]"},
+		{"resolved", "alerts.rules[0]"},
+		{"resolved", "alerts.rules[1]"},
+	}
+	if len(evs) != len(want) {
+		t.Fatalf("got %d notifications, want %d: %+v", len(evs), len(want), evs)
+	}
+	for i, w := range want {
+		if evs[i].Status != w.status || evs[i].Rule != w.rule {
+			t.Errorf("notification %d = %+v, want %s %s", i, evs[i], w.status, w.rule)
+		}
+	}
+	if evs[0].Value != 45 || evs[1].Value != 500 || evs[1].Threshold != 100 {
+		t.Errorf("values = %+v, %+v", evs[0], evs[1])
+	}
+}
+
+func TestAlerter_SlackFormat(t *testing.T) {
+	hook := &webhook{}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	a := New(config.AlertsConfig{
+		Webhooks: []config.AlertWebhookConfig{{URL: srv.URL, Format: "slack"}},
+		Rules:    []config.AlertRuleConfig{{Name: "down", ConsecutiveFailures: 1}},
+	})
+	a.Start(t.Context(), "run-1")
+	a.Record(result("https://a.example/", 503, 0))
+	a.Close()
+
+	if len(hook.bodies) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(hook.bodies))
+	}
+	var msg struct{ Text string }
+	if err := json.Unmarshal([]byte(hook.bodies[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"FIRING: down", "https://a.example/", "status 503", "run run-1"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("slack text missing %q: %q", want, msg.Text)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// Range returns when a blackout given by start and end begins and ends.
+func (b BlackoutConfig) Range() (start, end time.Time, err error) {
+	if start, err = blackoutTime(b.Start, false); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("start: %w", err)
+	}
+	if end, err = blackoutTime(b.End, true); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("end: %w", err)
+	}
+	return start, end, nil
+}
+
+// blackoutTime parses s, an RFC 3339 timestamp or a YYYY-MM-DD date. A
+// date is midnight at its start, or with end set, at its end.
+func blackoutTime(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or YYYY-MM-DD date", s)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// yamlCodec decodes YAML for viper as its built-in codec does, except that
+// unquoted timestamps stay the strings they were written as. Otherwise
+// `end: 2026-12-25` and `end: 2026-12-25T00:00:00Z` reach blackouts[].end
+// as the same time.Time, and a date could not be read as a whole day.
+type yamlCodec struct{}
+
+func (yamlCodec) Encode(v map[string]any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (yamlCodec) Decode(b []byte, v map[string]any) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		return nil
+	}
+	untagTimestamps(&doc)
+	return doc.Decode(&v)
+}
+
+// untagTimestamps retags the timestamp scalars under n as strings.
+func untagTimestamps(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!timestamp" {
+		n.Tag = "!!str"
+	}
+	for _, c := range n.Content {
+		untagTimestamps(c)
+	}
+}
+
+// newYAMLViper returns a viper instance that reads YAML with yamlCodec.
+func newYAMLViper() *viper.Viper {
+	codecs := viper.NewCodecRegistry()
+	_ = codecs.RegisterCodec("yaml", yamlCodec{})
+	v := viper.NewWithOptions(viper.WithDecoderRegistry(codecs))
+	v.SetConfigType("yaml")
+	return v
+}
+
+// validateBlackouts checks the blackouts section.
+func validateBlackouts(blackouts []BlackoutConfig) []string {
+	var errs []string
+	for i, b := range blackouts {
+		prefix := fmt.Sprintf("blackouts[%d]", i)
+		fixed := b.Start != "" || b.End != ""
+		recurring := b.Cron != "" || b.DurationMinutes != 0
+		switch {
+		case fixed == recurring:
+			errs = append(errs, prefix+" must set either start and end, or cron and duration_minutes")
+		case fixed:
+			start, end, err := b.Range()
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Sprintf("%s.%v", prefix, err))
+			case !end.After(start):
+				errs = append(errs, prefix+".end must be after start")
+			}
+		default:
+			if _, err := cron.ParseStandard(b.Cron); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.cron %q is invalid: %v", prefix, b.Cron, err))
+			}
+			if b.DurationMinutes <= 0 {
+				errs = append(errs, prefix+".duration_minutes must be > 0")
+			}
+		}
+	}
+	return errs
+}
@@ -2,44 +2,112 @@ package config
 
 import (
 	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	_ "time/tzdata" // browser.timezone is checked even where the OS has no zoneinfo
 
+	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
+	"golang.org/x/net/http/httpguts"
 )
 
 // Load reads the YAML config at path, applies defaults, and validates.
+// When path is an http(s):// or s3:// URL the config is fetched remotely;
+// see RemoteSource.
 func Load(path string) (*Config, error) {
-	v := viper.New()
-	v.SetConfigFile(path)
-	v.SetConfigType("yaml")
+	return LoadProfile(path, "")
+}
 
-	setDefaults(v)
+// LoadProfile is like Load but first merges the overlay under
+// profiles.<profile> over the base config. An empty profile loads the base
+// config only.
+func LoadProfile(path, profile string) (*Config, error) {
+	if IsRemote(path) {
+		src, err := NewRemoteSource(path, profile)
+		if err != nil {
+			return nil, err
+		}
+		cfg, _, err := src.Load(context.Background())
+		return cfg, err
+	}
+
+	v := newViper()
+	v.SetConfigFile(path)
 
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("reading config: %w", err)
 	}
 
+	if v.IsSet("include") {
+		if err := mergeIncludes(v, path); err != nil {
+			return nil, fmt.Errorf("include: %w", err)
+		}
+	}
+
+	return decode(v, profile)
+}
+
+func newViper() *viper.Viper {
+	v := newYAMLViper()
+	setDefaults(v)
+	return v
+}
+
+// decode applies the named profile to the settings read into v, unmarshals
+// them, appends targets_file entries, and validates the result.
+func decode(v *viper.Viper, profile string) (*Config, error) {
+	if err := applyProfile(v, profile); err != nil {
+		return nil, err
+	}
 	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	st := newDecodeState()
+	if err := v.Unmarshal(&cfg, decodeHook(st)); err != nil {
 		return nil, fmt.Errorf("unmarshalling config: %w", err)
 	}
 
+	// AllSettings, unlike Get, merges built-in defaults into a partially
+	// specified target_defaults section.
+	defaults, _ := v.AllSettings()["target_defaults"].(map[string]any)
+	cfg.targetDefaults = defaults
+	if cfg.TargetDefaults.ApplyToInline {
+		if err := applyInlineDefaults(v, &cfg, defaults, st); err != nil {
+			return nil, err
+		}
+	}
 	if cfg.TargetsFile != "" {
-		if err := loadTargetsFile(&cfg); err != nil {
+		if err := loadTargetsFile(&cfg, defaults, st); err != nil {
 			return nil, fmt.Errorf("targets_file: %w", err)
 		}
 	}
+	for name := range st.unset {
+		log.Warn().Msgf("config references ${%s}, which is not set — using an empty value", name)
+	}
+
+	targets, err := expandTargets(cfg.Targets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	cfg.Targets = targets
+	normalizeDNSResolvers(cfg.Targets)
+	inheritBackoff(&cfg.Backoff)
 
 	if err := validate(&cfg); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	warnLiteralTokens(&cfg)
+	warnLiteralTokens(&cfg, st.secrets)
 
 	return &cfg, nil
 }
@@ -48,33 +116,86 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("pacing.mode", "human")
 	v.SetDefault("pacing.requests_per_minute", 20.0)
 	v.SetDefault("pacing.jitter_factor", 0.4)
+	v.SetDefault("pacing.schedule_overlap", "sum")
 	v.SetDefault("pacing.min_delay_ms", 800)
 	v.SetDefault("pacing.max_delay_ms", 8000)
+	v.SetDefault("pacing.domain_spacing_ms", 0)
+	v.SetDefault("pacing.selection", "random")
+	v.SetDefault("pacing.load_model", "open")
+	v.SetDefault("pacing.virtual_users", 0)
 
 	v.SetDefault("limits.max_workers", 4)
 	v.SetDefault("limits.max_browser_workers", 1)
+	v.SetDefault("limits.browser_autoscale.enabled", false)
+	v.SetDefault("limits.browser_autoscale.memory_budget_mb", 0)
 	v.SetDefault("limits.cpu_threshold_pct", 60.0)
 	v.SetDefault("limits.memory_threshold_mb", 512)
 
 	v.SetDefault("rate_limits.default_rps", 0.5)
+	v.SetDefault("rate_limits.burst", 1)
+	v.SetDefault("rate_limits.global_rps", 0.0)
+	v.SetDefault("rate_limits.honor_headers", true)
+	v.SetDefault("rate_limits.honor_crawl_delay", false)
+	v.SetDefault("rate_limits.adaptive.enabled", false)
+	v.SetDefault("rate_limits.adaptive.p95_threshold_ms", 1000)
+	v.SetDefault("rate_limits.adaptive.min_rps", 0.05)
+	v.SetDefault("rate_limits.adaptive.decrease_factor", 0.5)
+	v.SetDefault("rate_limits.adaptive.recovery_step", 0.1)
+	v.SetDefault("rate_limits.adaptive.interval_s", 10)
+	v.SetDefault("rate_limits.redis.key_prefix", "sendit:ratelimit:")
+	v.SetDefault("rate_limits.redis.timeout_ms", 1000)
 
 	v.SetDefault("backoff.initial_ms", 1000)
 	v.SetDefault("backoff.max_ms", 120000)
 	v.SetDefault("backoff.multiplier", 2.0)
 	v.SetDefault("backoff.max_attempts", 3)
+	v.SetDefault("backoff.cooldown_s", 0)
+
+	v.SetDefault("retry.max_retries", 0)
+	v.SetDefault("retry.on", []string{"transient"})
+	v.SetDefault("retry.budget_per_minute", 0)
 
 	v.SetDefault("output.enabled", false)
 	v.SetDefault("output.file", "sendit-results.jsonl")
 	v.SetDefault("output.format", "jsonl")
 	v.SetDefault("output.append", false)
+	v.SetDefault("output.on_full", "drop")
+	v.SetDefault("output.sample_rate", 1.0)
+	v.SetDefault("output.sample_errors", 1.0)
 
 	v.SetDefault("metrics.enabled", false)
 	v.SetDefault("metrics.bind_address", "127.0.0.1")
 	v.SetDefault("metrics.prometheus_port", 9090)
+	v.SetDefault("metrics.per_target", false)
+
+	v.SetDefault("alerts.window_s", 300)
+	v.SetDefault("alerts.check_interval_s", 30)
+	v.SetDefault("alerts.min_requests", 20)
 
 	v.SetDefault("daemon.pid_file", "/tmp/sendit.pid")
 	v.SetDefault("daemon.log_level", "info")
 	v.SetDefault("daemon.log_format", "text")
+	v.SetDefault("daemon.log_file", "/tmp/sendit.log")
+	v.SetDefault("daemon.log_max_size_mb", 100)
+	v.SetDefault("daemon.log_max_backups", 3)
+	v.SetDefault("daemon.control_socket", "/tmp/sendit.sock")
+	v.SetDefault("daemon.task_log.mode", "main")
+	v.SetDefault("daemon.task_log.sample_rate", 1.0)
+	v.SetDefault("daemon.task_log.sample_errors", 1.0)
+
+	v.SetDefault("targets_file_refresh_s", 300)
+	v.SetDefault("targets_file_max_entries", 0)
+	v.SetDefault("targets_file_sampling", "none")
+
+	v.SetDefault("network.ip_family", "any")
+	v.SetDefault("network.proxy_selection", "round_robin")
+	v.SetDefault("network.proxy_health_check.interval_s", 30)
+	v.SetDefault("network.proxy_health_check.timeout_ms", 2000)
+
+	v.SetDefault("drivers.http.max_idle_conns", 100)
+	v.SetDefault("drivers.http.max_idle_conns_per_host", 10)
+	v.SetDefault("drivers.http.idle_conn_timeout_s", 90)
+	v.SetDefault("drivers.dns.tcp_fallback", true)
 
 	// target_defaults: applied to every target loaded from targets_file.
 	v.SetDefault("target_defaults.weight", 1)
@@ -84,50 +205,114 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("target_defaults.browser.timeout_s", 30)
 	v.SetDefault("target_defaults.dns.resolver", "8.8.8.8:53")
 	v.SetDefault("target_defaults.dns.record_type", "A")
+	v.SetDefault("target_defaults.dns.timeout_s", 10)
 	v.SetDefault("target_defaults.websocket.duration_s", 30)
+	v.SetDefault("target_defaults.websocket.measure_echo", false)
+	v.SetDefault("target_defaults.websocket.close", "normal")
 	v.SetDefault("target_defaults.sftp.port", 22)
 	v.SetDefault("target_defaults.sftp.operation", "upload")
 	v.SetDefault("target_defaults.sftp.timeout_s", 30)
 }
 
 // warnLiteralTokens logs a warning for any target that has a literal token or
-// password in its auth config. Env-var references are preferred in production.
-func warnLiteralTokens(cfg *Config) {
+// password in its auth config. Env-var and valueFrom references are preferred
+// in production and are not reported.
+func warnLiteralTokens(cfg *Config, secrets map[string]bool) {
 	for i, t := range cfg.Targets {
 		a := t.Auth
 		if a.Type == "" {
 			continue
 		}
-		if a.Token != "" {
+		if a.Token != "" && !secrets[a.Token] {
 			log.Warn().Msgf("targets[%d]: auth.token is a literal value — consider using auth.token_env instead", i)
 		}
-		if a.Password != "" {
+		if a.Password != "" && !secrets[a.Password] {
 			log.Warn().Msgf("targets[%d]: auth.password is a literal value — consider using auth.password_env instead", i)
 		}
 	}
 }
 
+// applyInlineDefaults re-decodes each inline target on top of
+// target_defaults, so that fields a target leaves unset take the default
+// value. Fields the target sets always win; nested maps such as headers are
+// merged key by key.
+func applyInlineDefaults(v *viper.Viper, cfg *Config, defaults map[string]any, st *decodeState) error {
+	raw, _ := v.Get("targets").([]any)
+	if len(raw) != len(cfg.Targets) {
+		return nil
+	}
+	for i, item := range raw {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		merged := copyMap(defaults)
+		overlayMaps(merged, m)
+		var t TargetConfig
+		if err := decodeTarget(merged, false, st, &t); err != nil {
+			return fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		cfg.Targets[i] = t
+	}
+	return nil
+}
+
 // loadTargetsFile reads the file at cfg.TargetsFile and appends a TargetConfig
 // for each entry to cfg.Targets, applying cfg.TargetDefaults for all fields
-// not specified in the file.
-//
-// File format — one entry per line:
+// not specified in the file. An http(s):// or s3:// targets_file is fetched
+// instead of read from disk. The format is chosen by extension: .csv, .json,
+// .yaml and .yml are structured (see loadStructuredTargets); anything else is
+// the plain-text format. defaults is the raw target_defaults section.
 //
-//	<url> <type> [weight]
-//
-// Lines beginning with '#' and blank lines are ignored. Weight defaults to
-// target_defaults.weight when omitted.
-func loadTargetsFile(cfg *Config) error {
-	f, err := os.Open(cfg.TargetsFile)
+// Local text, CSV, and JSON files are parsed as they are read, so only the
+// entries kept are held in memory; targets_file_max_entries and
+// targets_file_sampling bound how many that is (see targetSink).
+func loadTargetsFile(cfg *Config, defaults map[string]any, st *decodeState) error {
+	sink, err := newTargetSink(cfg, defaults, st)
 	if err != nil {
-		return fmt.Errorf("opening %q: %w", cfg.TargetsFile, err)
+		return err
+	}
+	f, err := openTargetsFile(context.Background(), cfg.TargetsFile)
+	if err != nil {
+		return err
 	}
 	defer f.Close()
+	h := sha256.New()
+	r := io.TeeReader(f, h)
+
+	switch strings.ToLower(filepath.Ext(targetsFileName(cfg.TargetsFile))) {
+	case ".csv", ".json", ".yaml", ".yml":
+		sink.unit = "entry"
+		err = loadStructuredTargets(cfg.TargetsFile, r, sink.add)
+	default:
+		sink.unit = "line"
+		err = readTextTargets(cfg.TargetsFile, r, sink.add)
+	}
+	if err != nil {
+		return err
+	}
+	// Hash the whole file even when the parser stopped before its end.
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return fmt.Errorf("reading %q: %w", cfg.TargetsFile, err)
+	}
+	cfg.targetsDigest = hex.EncodeToString(h.Sum(nil))
+	return sink.finish()
+}
 
-	d := cfg.TargetDefaults
+// readTextTargets parses the plain-text targets file format — one entry per
+// line:
+//
+//	<url> <type> [weight] [key=value ...]
+//
+// Lines beginning with '#' and blank lines are ignored. Weight defaults to
+// target_defaults.weight when omitted. Trailing key=value pairs override
+// target fields using the same keys as CSV columns (see
+// loadStructuredTargets), e.g. method=POST, timeout_s=5, or
+// record_type=AAAA. Each entry is passed to emit with its line number.
+func readTextTargets(path string, r io.Reader, emit func(n int, row map[string]any) error) error {
 	validTypes := map[string]bool{"http": true, "browser": true, "dns": true, "websocket": true, "grpc": true, "sftp": true}
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(r)
 	lineNum := 0
 	for scanner.Scan() {
 		lineNum++
@@ -148,34 +333,31 @@ func loadTargetsFile(cfg *Config) error {
 			return fmt.Errorf("line %d: unknown type %q (must be http|browser|dns|websocket|grpc|sftp)", lineNum, typ)
 		}
 
-		weight := d.Weight
-		if len(fields) >= 3 {
-			w, err := strconv.Atoi(fields[2])
+		row := map[string]any{"url": url, "type": typ}
+		rest := fields[2:]
+		if len(rest) > 0 && !strings.Contains(rest[0], "=") {
+			w, err := strconv.ParseFloat(rest[0], 64)
 			if err != nil || w <= 0 {
-				return fmt.Errorf("line %d: invalid weight %q (must be a positive integer)", lineNum, fields[2])
+				return fmt.Errorf("line %d: invalid weight %q (must be a positive number)", lineNum, rest[0])
 			}
-			weight = w
+			row["weight"] = w
+			rest = rest[1:]
 		}
-		if weight <= 0 {
-			weight = 1
+		for _, kv := range rest {
+			k, val, ok := strings.Cut(kv, "=")
+			if !ok || k == "" {
+				return fmt.Errorf("line %d: expected key=value, got %q", lineNum, kv)
+			}
+			row[k] = val
 		}
 
-		cfg.Targets = append(cfg.Targets, TargetConfig{
-			URL:       url,
-			Weight:    weight,
-			Type:      typ,
-			Auth:      d.Auth,
-			HTTP:      d.HTTP,
-			Browser:   d.Browser,
-			DNS:       d.DNS,
-			WebSocket: d.WebSocket,
-			GRPC:      d.GRPC,
-			SFTP:      d.SFTP,
-		})
+		if err := emit(lineNum, row); err != nil {
+			return err
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("reading %q: %w", cfg.TargetsFile, err)
+		return fmt.Errorf("reading %q: %w", path, err)
 	}
 	return nil
 }
@@ -209,9 +391,48 @@ func validate(cfg *Config) error {
 		errs = append(errs, "pacing.max_delay_ms must be >= min_delay_ms")
 	}
 
+	if cfg.Pacing.DomainSpacingMs < 0 {
+		errs = append(errs, "pacing.domain_spacing_ms must be >= 0")
+	}
+
+	if s := cfg.Pacing.Selection; s != "random" && s != "deck" {
+		errs = append(errs, fmt.Sprintf("pacing.selection must be random or deck, got %q", s))
+	}
+
+	if m := cfg.Pacing.LoadModel; m != "open" && m != "closed" {
+		errs = append(errs, fmt.Sprintf("pacing.load_model must be open or closed, got %q", m))
+	}
+
+	if cfg.Pacing.VirtualUsers < 0 {
+		errs = append(errs, "pacing.virtual_users must be >= 0")
+	}
+
 	if cfg.Pacing.Mode == "scheduled" && len(cfg.Pacing.Schedule) == 0 {
 		errs = append(errs, "pacing.schedule must have at least one entry when mode is scheduled")
 	}
+	if o := cfg.Pacing.ScheduleOverlap; o != "sum" && o != "max" && o != "latest" {
+		errs = append(errs, fmt.Sprintf("pacing.schedule_overlap must be one of sum|max|latest, got %q", o))
+	}
+	for i, e := range cfg.Pacing.Schedule {
+		// Same parser as the scheduler's cron.New(), so anything accepted
+		// here also opens its window at runtime.
+		if _, err := cron.ParseStandard(e.Cron); err != nil {
+			errs = append(errs, fmt.Sprintf("pacing.schedule[%d].cron %q is invalid: %v", i, e.Cron, err))
+		}
+		if e.Group != "" && cfg.KV.Type == "" && !hasGroup(cfg.Targets, e.Group) {
+			errs = append(errs, fmt.Sprintf("pacing.schedule[%d].group %q matches no target", i, e.Group))
+		}
+	}
+
+	if cfg.TargetsFileRefreshS < 0 {
+		errs = append(errs, "targets_file_refresh_s must be >= 0")
+	}
+	if cfg.TargetsFileMaxEntries < 0 {
+		errs = append(errs, "targets_file_max_entries must be >= 0")
+	}
+	if !validSampling[cfg.TargetsFileSampling] {
+		errs = append(errs, fmt.Sprintf("targets_file_sampling must be one of none|top_weight|reservoir, got %q", cfg.TargetsFileSampling))
+	}
 
 	if cfg.Limits.MaxWorkers <= 0 {
 		errs = append(errs, "limits.max_workers must be > 0")
@@ -229,6 +450,58 @@ func validate(cfg *Config) error {
 		errs = append(errs, "rate_limits.default_rps must be > 0")
 	}
 
+	if cfg.RateLimits.GlobalRPS < 0 {
+		errs = append(errs, "rate_limits.global_rps must be >= 0")
+	}
+
+	if cfg.RateLimits.Burst < 1 {
+		errs = append(errs, "rate_limits.burst must be >= 1")
+	}
+	for i, d := range cfg.RateLimits.PerDomain {
+		if !validDomainPattern(d.Domain) {
+			errs = append(errs, fmt.Sprintf("rate_limits.per_domain[%d].domain %q must be a hostname, *.suffix, or .suffix", i, d.Domain))
+		}
+		if d.Burst < 0 {
+			errs = append(errs, fmt.Sprintf("rate_limits.per_domain[%d].burst must be >= 0", i))
+		}
+		if d.RPS < 0 {
+			errs = append(errs, fmt.Sprintf("rate_limits.per_domain[%d].rps must be >= 0", i))
+		}
+		if d.MinIntervalMs < 0 {
+			errs = append(errs, fmt.Sprintf("rate_limits.per_domain[%d].min_interval_ms must be >= 0", i))
+		}
+	}
+
+	if a := cfg.RateLimits.Adaptive; a.Enabled {
+		if a.P95ThresholdMs <= 0 {
+			errs = append(errs, "rate_limits.adaptive.p95_threshold_ms must be > 0")
+		}
+		if a.MinRPS <= 0 {
+			errs = append(errs, "rate_limits.adaptive.min_rps must be > 0")
+		}
+		if a.DecreaseFactor <= 0 || a.DecreaseFactor >= 1 {
+			errs = append(errs, "rate_limits.adaptive.decrease_factor must be in (0, 1)")
+		}
+		if a.RecoveryStep <= 0 || a.RecoveryStep > 1 {
+			errs = append(errs, "rate_limits.adaptive.recovery_step must be in (0, 1]")
+		}
+		if a.IntervalS <= 0 {
+			errs = append(errs, "rate_limits.adaptive.interval_s must be > 0")
+		}
+	}
+
+	if r := cfg.RateLimits.Redis; r.Address != "" {
+		if _, _, err := net.SplitHostPort(r.Address); err != nil {
+			errs = append(errs, fmt.Sprintf("rate_limits.redis.address %q must be host:port", r.Address))
+		}
+		if r.DB < 0 {
+			errs = append(errs, "rate_limits.redis.db must be >= 0")
+		}
+		if r.TimeoutMs <= 0 {
+			errs = append(errs, "rate_limits.redis.timeout_ms must be > 0")
+		}
+	}
+
 	if cfg.Backoff.InitialMs <= 0 {
 		errs = append(errs, "backoff.initial_ms must be > 0")
 	}
@@ -245,17 +518,105 @@ func validate(cfg *Config) error {
 		errs = append(errs, "backoff.max_attempts must be > 0")
 	}
 
-	if len(cfg.Targets) == 0 {
+	if cfg.Backoff.CooldownS < 0 {
+		errs = append(errs, "backoff.cooldown_s must be >= 0")
+	}
+
+	for i, d := range cfg.Backoff.PerDomain {
+		prefix := fmt.Sprintf("backoff.per_domain[%d]", i)
+		if !validDomainPattern(d.Domain) {
+			errs = append(errs, fmt.Sprintf("%s.domain %q must be a hostname, *.suffix, or .suffix", prefix, d.Domain))
+		}
+		if d.InitialMs <= 0 {
+			errs = append(errs, prefix+".initial_ms must be > 0")
+		}
+		if d.MaxMs < d.InitialMs {
+			errs = append(errs, prefix+".max_ms must be >= initial_ms")
+		}
+		if d.Multiplier <= 1 {
+			errs = append(errs, prefix+".multiplier must be > 1")
+		}
+		if d.MaxAttempts <= 0 {
+			errs = append(errs, prefix+".max_attempts must be > 0")
+		}
+		if d.CooldownS < 0 {
+			errs = append(errs, prefix+".cooldown_s must be >= 0")
+		}
+	}
+
+	if cfg.Retry.MaxRetries < 0 {
+		errs = append(errs, "retry.max_retries must be >= 0")
+	}
+	for _, class := range cfg.Retry.On {
+		if class != "transient" && class != "permanent" {
+			errs = append(errs, fmt.Sprintf("retry.on entries must be transient|permanent, got %q", class))
+		}
+	}
+	if cfg.Retry.MaxRetries > 0 && len(cfg.Retry.On) == 0 {
+		errs = append(errs, "retry.on must list at least one class when max_retries is set")
+	}
+	if cfg.Retry.BudgetPerMinute < 0 {
+		errs = append(errs, "retry.budget_per_minute must be >= 0")
+	}
+
+	// With a kv backend, targets may come entirely from the store;
+	// KVSource.Apply checks that the merged set is non-empty.
+	if len(cfg.Targets) == 0 && cfg.KV.Type == "" {
 		errs = append(errs, "targets must have at least one entry (via 'targets' in config or 'targets_file')")
 	}
 
+	switch cfg.KV.Type {
+	case "", "consul", "etcd":
+	default:
+		errs = append(errs, fmt.Sprintf("kv.type must be consul|etcd, got %q", cfg.KV.Type))
+	}
+	if cfg.KV.Address != "" && !strings.HasPrefix(cfg.KV.Address, "http://") && !strings.HasPrefix(cfg.KV.Address, "https://") {
+		errs = append(errs, fmt.Sprintf("kv.address must start with http:// or https://, got %q", cfg.KV.Address))
+	}
+	if cfg.KV.PollIntervalS < 0 {
+		errs = append(errs, "kv.poll_interval_s must be >= 0")
+	}
+
+	validIPFamilies := map[string]bool{"any": true, "ipv4": true, "ipv6": true}
+	if f := cfg.Network.IPFamily; f != "" && !validIPFamilies[f] {
+		errs = append(errs, fmt.Sprintf("network.ip_family must be any|ipv4|ipv6, got %q", f))
+	}
+	for i, p := range cfg.Network.Proxies {
+		// Proxy URLs may carry a password, so only the redacted form is
+		// echoed back.
+		u, err := url.Parse(p)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Sprintf("network.proxies[%d] is not a valid URL", i))
+		case u.Scheme != "socks5" && u.Scheme != "socks5h", u.Port() == "":
+			errs = append(errs, fmt.Sprintf("network.proxies[%d] must be a socks5://host:port URL, got %q", i, u.Redacted()))
+		}
+	}
+	if len(cfg.Network.Proxies) > 0 {
+		if s := cfg.Network.ProxySelection; s != "round_robin" && s != "random" {
+			errs = append(errs, fmt.Sprintf("network.proxy_selection must be round_robin or random, got %q", s))
+		}
+		if hc := cfg.Network.ProxyHealthCheck; hc.IntervalS < 0 {
+			errs = append(errs, "network.proxy_health_check.interval_s must be >= 0")
+		} else if hc.IntervalS > 0 && hc.TimeoutMs <= 0 {
+			errs = append(errs, "network.proxy_health_check.timeout_ms must be > 0")
+		}
+	}
+
+	if _, err := EffectiveWeights(cfg.Targets); err != nil {
+		errs = append(errs, err.Error())
+	}
+
 	validTypes := map[string]bool{"http": true, "browser": true, "dns": true, "websocket": true, "grpc": true, "sftp": true}
 	validAuthTypes := map[string]bool{"bearer": true, "basic": true, "header": true, "query": true}
 	for i, t := range cfg.Targets {
 		if t.URL == "" {
 			errs = append(errs, fmt.Sprintf("targets[%d].url must not be empty", i))
 		}
-		if t.Weight <= 0 {
+		switch {
+		case t.Share < 0 || t.Share > 100:
+			errs = append(errs, fmt.Sprintf("targets[%d].share must be between 0%% and 100%%, got %g%%", i, float64(t.Share)))
+		case t.Share == 0 && t.Weight <= 0:
 			errs = append(errs, fmt.Sprintf("targets[%d].weight must be > 0", i))
 		}
 		if !validTypes[t.Type] {
@@ -264,9 +625,50 @@ func validate(cfg *Config) error {
 		if t.Type == "grpc" && !strings.HasPrefix(t.URL, "grpc://") && !strings.HasPrefix(t.URL, "grpcs://") {
 			errs = append(errs, fmt.Sprintf("targets[%d].url must start with grpc:// or grpcs:// for type grpc, got %q", i, t.URL))
 		}
+		switch {
+		case t.MirrorPct < 0 || t.MirrorPct > 100:
+			errs = append(errs, fmt.Sprintf("targets[%d].mirror_pct must be between 0 and 100, got %g", i, t.MirrorPct))
+		case t.MirrorPct > 0 && t.MirrorURL == "":
+			errs = append(errs, fmt.Sprintf("targets[%d].mirror_pct requires mirror_url", i))
+		}
+		if t.LatencyBudgetMs < 0 {
+			errs = append(errs, fmt.Sprintf("targets[%d].latency_budget_ms must be >= 0", i))
+		}
+		if t.TaskDeadlineS < 0 {
+			errs = append(errs, fmt.Sprintf("targets[%d].task_deadline_s must be >= 0", i))
+		}
+		if t.MirrorURL != "" && t.MirrorURL == t.URL {
+			errs = append(errs, fmt.Sprintf("targets[%d].mirror_url must differ from url", i))
+		}
+		if t.Type == "browser" && t.MirrorURL != "" {
+			errs = append(errs, fmt.Sprintf("targets[%d].mirror_url is not supported for type browser", i))
+		}
+		if t.Type == "grpc" && t.MirrorURL != "" && !strings.HasPrefix(t.MirrorURL, "grpc://") && !strings.HasPrefix(t.MirrorURL, "grpcs://") {
+			errs = append(errs, fmt.Sprintf("targets[%d].mirror_url must start with grpc:// or grpcs:// for type grpc, got %q", i, t.MirrorURL))
+		}
 		if t.Type == "sftp" {
 			errs = append(errs, validateSFTPTarget(i, t)...)
 		}
+		if t.Type == "http" {
+			errs = append(errs, validateHTTPTarget(i, t.HTTP)...)
+		}
+		if t.Type == "browser" {
+			errs = append(errs, validateBrowserTarget(i, t.Browser)...)
+		}
+		if t.Type == "websocket" {
+			errs = append(errs, validateWebSocketTarget(i, t.WebSocket)...)
+		}
+		if t.Type == "dns" {
+			family := t.Network.IPFamily
+			if family == "" {
+				family = cfg.Network.IPFamily
+			}
+			errs = append(errs, validateDNSTarget(i, t.DNS, family)...)
+		}
+		if f := t.Network.IPFamily; f != "" && !validIPFamilies[f] {
+			errs = append(errs, fmt.Sprintf("targets[%d].network.ip_family must be any|ipv4|ipv6, got %q", i, f))
+		}
+		errs = append(errs, validateThinkTime(fmt.Sprintf("targets[%d].think_time", i), t.ThinkTime)...)
 		if a := t.Auth; a.Type != "" {
 			if !validAuthTypes[a.Type] {
 				errs = append(errs, fmt.Sprintf("targets[%d].auth.type must be one of bearer|basic|header|query, got %q", i, a.Type))
@@ -298,12 +700,33 @@ func validate(cfg *Config) error {
 		if cfg.Output.File == "" {
 			errs = append(errs, "output.file must not be empty when output.enabled is true")
 		}
-		validFormats := map[string]bool{"jsonl": true, "csv": true}
+		validFormats := map[string]bool{"jsonl": true, "csv": true, "clf": true}
 		if !validFormats[cfg.Output.Format] {
-			errs = append(errs, fmt.Sprintf("output.format must be jsonl|csv, got %q", cfg.Output.Format))
+			errs = append(errs, fmt.Sprintf("output.format must be jsonl|csv|clf, got %q", cfg.Output.Format))
 		}
 	}
 
+	if cfg.Output.OnFull != "drop" && cfg.Output.OnFull != "block" {
+		errs = append(errs, fmt.Sprintf("output.on_full must be drop|block, got %q", cfg.Output.OnFull))
+	}
+
+	if cfg.Output.SampleRate <= 0 || cfg.Output.SampleRate > 1 {
+		errs = append(errs, "output.sample_rate must be in (0, 1]")
+	}
+	if cfg.Output.SampleErrors <= 0 || cfg.Output.SampleErrors > 1 {
+		errs = append(errs, "output.sample_errors must be in (0, 1]")
+	}
+
+	if cfg.Output.Details.BodySnippetBytes < 0 {
+		errs = append(errs, "output.details.body_snippet_bytes must be >= 0")
+	}
+
+	errs = append(errs, validateUpload(cfg.Output)...)
+
+	for i, s := range cfg.Output.Sinks {
+		errs = append(errs, validateSink(i, s)...)
+	}
+
 	validLogLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
 	if !validLogLevels[cfg.Daemon.LogLevel] {
 		errs = append(errs, fmt.Sprintf("daemon.log_level must be one of debug|info|warn|error, got %q", cfg.Daemon.LogLevel))
@@ -313,6 +736,43 @@ func validate(cfg *Config) error {
 	if !validLogFormats[cfg.Daemon.LogFormat] {
 		errs = append(errs, fmt.Sprintf("daemon.log_format must be text|json, got %q", cfg.Daemon.LogFormat))
 	}
+	if cfg.Daemon.LogMaxSizeMB < 0 {
+		errs = append(errs, fmt.Sprintf("daemon.log_max_size_mb must be >= 0, got %d", cfg.Daemon.LogMaxSizeMB))
+	}
+	if cfg.Daemon.LogMaxBackups < 0 {
+		errs = append(errs, fmt.Sprintf("daemon.log_max_backups must be >= 0, got %d", cfg.Daemon.LogMaxBackups))
+	}
+	tl := cfg.Daemon.TaskLog
+	switch tl.Mode {
+	case "main", "none":
+	case "file":
+		if tl.File == "" {
+			errs = append(errs, "daemon.task_log.file is required when mode is file")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("daemon.task_log.mode must be main|file|none, got %q", tl.Mode))
+	}
+	if tl.SampleRate <= 0 || tl.SampleRate > 1 {
+		errs = append(errs, "daemon.task_log.sample_rate must be in (0, 1]")
+	}
+	if tl.SampleErrors <= 0 || tl.SampleErrors > 1 {
+		errs = append(errs, "daemon.task_log.sample_errors must be in (0, 1]")
+	}
+
+	errs = append(errs, validateSLOObjectives("slo", cfg.SLO.AvailabilityPct, cfg.SLO.Latency)...)
+	for i, t := range cfg.SLO.Targets {
+		prefix := fmt.Sprintf("slo.targets[%d]", i)
+		if t.URL == "" {
+			errs = append(errs, prefix+".url is required")
+		} else if cfg.KV.Type == "" && !hasTargetURL(cfg.Targets, t.URL) {
+			errs = append(errs, fmt.Sprintf("%s.url %q matches no target", prefix, t.URL))
+		}
+		errs = append(errs, validateSLOObjectives(prefix, t.AvailabilityPct, t.Latency)...)
+	}
+
+	errs = append(errs, validateAlerts(cfg)...)
+	errs = append(errs, validateBlackouts(cfg.Blackouts)...)
+	errs = append(errs, validateDrivers(cfg.Drivers)...)
 
 	if len(errs) > 0 {
 		return errors.New(strings.Join(errs, "; "))
@@ -320,6 +780,343 @@ func validate(cfg *Config) error {
 	return nil
 }
 
+// maxCaptureBodyBytes caps http.capture_body.max_bytes, since every captured
+// body is held in its output record.
+const maxCaptureBodyBytes = 1 << 20
+
+// maxBodySizeBytes caps http.body_size.max.
+const maxBodySizeBytes = 64 << 20
+
+func validateHTTPTarget(i int, h HTTPConfig) []string {
+	var errs []string
+	switch h.TLSFingerprint {
+	case "", "go", "chrome", "firefox", "safari":
+	default:
+		errs = append(errs, fmt.Sprintf("targets[%d].http.tls_fingerprint must be go|chrome|firefox|safari, got %q", i, h.TLSFingerprint))
+	}
+	switch h.HeaderProfile {
+	case "", "none", "chrome", "firefox", "safari":
+	default:
+		errs = append(errs, fmt.Sprintf("targets[%d].http.header_profile must be chrome|firefox|safari|none, got %q", i, h.HeaderProfile))
+	}
+	for m, w := range h.Methods {
+		if !httpguts.ValidHeaderFieldName(m) {
+			errs = append(errs, fmt.Sprintf("targets[%d].http.methods: %q is not a valid method", i, m))
+		}
+		if w <= 0 {
+			errs = append(errs, fmt.Sprintf("targets[%d].http.methods.%s weight must be > 0, got %g", i, strings.ToUpper(m), w))
+		}
+	}
+	if h.ReadRateBps < 0 {
+		errs = append(errs, fmt.Sprintf("targets[%d].http.read_rate_bps must be >= 0", i))
+	}
+	errs = append(errs, validateAbort(fmt.Sprintf("targets[%d].http", i), h.AbortProbability, h.AbortAfterMs)...)
+	if h.ReadBody < ReadNone {
+		errs = append(errs, fmt.Sprintf("targets[%d].http.read_body must be true, false, or a number of bytes > 0, got %d", i, h.ReadBody))
+	}
+	if h.TraceHeader != "" && !httpguts.ValidHeaderFieldName(h.TraceHeader) {
+		errs = append(errs, fmt.Sprintf("targets[%d].http.trace_header %q is not a valid header name", i, h.TraceHeader))
+	}
+	for j, r := range h.Resolve {
+		prefix := fmt.Sprintf("targets[%d].http.resolve[%d]", i, j)
+		if r.Host == "" {
+			errs = append(errs, prefix+".host must not be empty")
+		}
+		if net.ParseIP(r.Address) == nil {
+			errs = append(errs, fmt.Sprintf("%s.address must be an IP address, got %q", prefix, r.Address))
+		}
+	}
+	if h.Resolver != "" {
+		if _, _, err := net.SplitHostPort(h.Resolver); err != nil {
+			errs = append(errs, fmt.Sprintf("targets[%d].http.resolver must be host:port, got %q", i, h.Resolver))
+		}
+	}
+	if bs := h.BodySize; bs != (BodySizeConfig{}) {
+		prefix := fmt.Sprintf("targets[%d].http.body_size", i)
+		switch {
+		case bs.Min < 0:
+			errs = append(errs, prefix+".min must be >= 0")
+		case bs.Max <= 0 || bs.Max < bs.Min:
+			errs = append(errs, prefix+".max must be > 0 and >= min")
+		case bs.Max > maxBodySizeBytes:
+			errs = append(errs, fmt.Sprintf("%s.max must be <= %d, got %d", prefix, maxBodySizeBytes, bs.Max))
+		case bs.Distribution == "lognormal" && bs.Min < 1:
+			errs = append(errs, prefix+".min must be >= 1 for distribution lognormal")
+		}
+		switch bs.Distribution {
+		case "", "uniform", "lognormal":
+		default:
+			errs = append(errs, fmt.Sprintf("%s.distribution must be uniform|lognormal, got %q", prefix, bs.Distribution))
+		}
+		if h.Body != "" {
+			errs = append(errs, fmt.Sprintf("targets[%d].http: body and body_size are mutually exclusive", i))
+		}
+	}
+	if c := h.CaptureBody; c.MaxBytes < 0 || c.MaxBytes > maxCaptureBodyBytes {
+		errs = append(errs, fmt.Sprintf("targets[%d].http.capture_body.max_bytes must be between 0 and %d, got %d", i, maxCaptureBodyBytes, c.MaxBytes))
+	}
+	switch h.CaptureBody.On {
+	case "", "error", "always":
+	default:
+		errs = append(errs, fmt.Sprintf("targets[%d].http.capture_body.on must be error|always, got %q", i, h.CaptureBody.On))
+	}
+	return errs
+}
+
+// validateDNSTarget checks the timeout, UDP size, and resolvers of dns
+// target i, which queries over the IP family family.
+func validateDNSTarget(i int, d DNSConfig, family string) []string {
+	var errs []string
+	check := func(field, r string) {
+		addr, err := ResolverAddr(r)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("targets[%d].dns.%s must be an address or hostname with an optional :port, got %q: %v", i, field, r, err))
+			return
+		}
+		if f := resolverFamily(addr); f != "" && (family == "ipv4" || family == "ipv6") && f != family {
+			errs = append(errs, fmt.Sprintf("targets[%d].dns.%s %q is an %s address, but ip_family is %s", i, field, r, f, family))
+		}
+	}
+	if d.TimeoutS < 0 {
+		errs = append(errs, fmt.Sprintf("targets[%d].dns.timeout_s must be >= 0", i))
+	}
+	if !validUDPSize(d.UDPSize) {
+		errs = append(errs, fmt.Sprintf("targets[%d].dns.udp_size must be 0 or in [512, 65535], got %d", i, d.UDPSize))
+	}
+	if d.Resolver != "" {
+		check("resolver", d.Resolver)
+	}
+	for j, r := range d.Resolvers {
+		check(fmt.Sprintf("resolvers[%d]", j), r)
+	}
+	return errs
+}
+
+var validWebSocketCloses = map[string]bool{"normal": true, "going_away": true, "reset": true}
+
+func validateWebSocketTarget(i int, w WebSocketConfig) []string {
+	var errs []string
+	if w.MeasureEcho && len(w.SendMessages) == 0 {
+		errs = append(errs, fmt.Sprintf("targets[%d].websocket.measure_echo requires send_messages", i))
+	}
+	if w.Close != "" && !validWebSocketCloses[w.Close] {
+		errs = append(errs, fmt.Sprintf("targets[%d].websocket.close must be normal|going_away|reset, got %q", i, w.Close))
+	}
+	for c, wt := range w.CloseMix {
+		if !validWebSocketCloses[c] {
+			errs = append(errs, fmt.Sprintf("targets[%d].websocket.close_mix: %q must be normal|going_away|reset", i, c))
+		}
+		if wt <= 0 {
+			errs = append(errs, fmt.Sprintf("targets[%d].websocket.close_mix.%s weight must be > 0, got %g", i, c, wt))
+		}
+	}
+	return errs
+}
+
+// localePattern matches a BCP 47 language tag such as "en", "de-DE", or
+// "zh-Hant-TW": a language subtag followed by alphanumeric subtags.
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+func validateBrowserTarget(i int, b BrowserConfig) []string {
+	var errs []string
+	if b.Locale != "" && !localePattern.MatchString(b.Locale) {
+		errs = append(errs, fmt.Sprintf("targets[%d].browser.locale must be a language tag such as en-US, got %q", i, b.Locale))
+	}
+	if b.Timezone != "" {
+		if _, err := time.LoadLocation(b.Timezone); err != nil || b.Timezone == "Local" {
+			errs = append(errs, fmt.Sprintf("targets[%d].browser.timezone must be an IANA time zone such as Europe/Berlin, got %q", i, b.Timezone))
+		}
+	}
+	errs = append(errs, validateAbort(fmt.Sprintf("targets[%d].browser", i), b.AbortProbability, b.AbortAfterMs)...)
+	for j, f := range b.ExtraFlags {
+		if name, _, _ := strings.Cut(strings.TrimPrefix(f, "--"), "="); !strings.HasPrefix(f, "--") || name == "" {
+			errs = append(errs, fmt.Sprintf("targets[%d].browser.extra_flags[%d] must be a switch such as --no-sandbox or --name=value, got %q", i, j, f))
+		}
+	}
+	n := b.NetworkCustom
+	switch b.NetworkProfile {
+	case "", "3g", "4g", "cable":
+		if n != (NetworkConditionsConfig{}) {
+			errs = append(errs, fmt.Sprintf("targets[%d].browser.network_custom requires network_profile: custom", i))
+		}
+	case "custom":
+		if n.LatencyMs < 0 {
+			errs = append(errs, fmt.Sprintf("targets[%d].browser.network_custom.latency_ms must be >= 0", i))
+		}
+		if n.DownloadKbps <= 0 || n.UploadKbps <= 0 {
+			errs = append(errs, fmt.Sprintf("targets[%d].browser.network_custom: download_kbps and upload_kbps must be > 0", i))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("targets[%d].browser.network_profile must be 3g|4g|cable|custom, got %q", i, b.NetworkProfile))
+	}
+	return errs
+}
+
+// validateAbort checks the abort_probability and abort_after_ms of the
+// target settings at prefix.
+func validateAbort(prefix string, p float64, afterMs int) []string {
+	var errs []string
+	if p < 0 || p > 1 {
+		errs = append(errs, fmt.Sprintf("%s.abort_probability must be between 0 and 1, got %g", prefix, p))
+	}
+	if afterMs < 0 {
+		errs = append(errs, prefix+".abort_after_ms must be >= 0")
+	}
+	return errs
+}
+
+// validateSLOObjectives checks one set of SLO objectives; prefix is the
+// config path they were read from.
+func validateSLOObjectives(prefix string, availabilityPct float64, latency []LatencyObjective) []string {
+	var errs []string
+	if availabilityPct < 0 || availabilityPct > 100 {
+		errs = append(errs, fmt.Sprintf("%s.availability_pct must be between 0 and 100, got %g", prefix, availabilityPct))
+	}
+	for i, l := range latency {
+		if l.Percentile <= 0 || l.Percentile > 100 {
+			errs = append(errs, fmt.Sprintf("%s.latency[%d].percentile must be > 0 and <= 100, got %g", prefix, i, l.Percentile))
+		}
+		if l.MaxMs <= 0 {
+			errs = append(errs, fmt.Sprintf("%s.latency[%d].max_ms must be > 0", prefix, i))
+		}
+	}
+	return errs
+}
+
+// validUDPSize reports whether n is a usable EDNS0 UDP payload size, or 0
+// for none.
+func validUDPSize(n int) bool {
+	return n == 0 || (n >= 512 && n <= 65535)
+}
+
+// validateDrivers checks the drivers section.
+func validateDrivers(d DriversConfig) []string {
+	var errs []string
+	h := d.HTTP
+	for _, f := range []struct {
+		name string
+		v    int
+	}{
+		{"max_idle_conns", h.MaxIdleConns},
+		{"max_idle_conns_per_host", h.MaxIdleConnsPerHost},
+		{"max_conns_per_host", h.MaxConnsPerHost},
+		{"idle_conn_timeout_s", h.IdleConnTimeoutS},
+		{"tls_session_cache_size", h.TLSSessionCacheSize},
+	} {
+		if f.v < 0 {
+			errs = append(errs, fmt.Sprintf("drivers.http.%s must be >= 0", f.name))
+		}
+	}
+	if !validUDPSize(d.DNS.UDPSize) {
+		errs = append(errs, fmt.Sprintf("drivers.dns.udp_size must be 0 or in [512, 65535], got %d", d.DNS.UDPSize))
+	}
+	for name, v := range d.Browser.Flags {
+		switch v.(type) {
+		case bool, string, int, int64, float64:
+		default:
+			errs = append(errs, fmt.Sprintf("drivers.browser.flags.%s must be a bool, string, or number, got %T", name, v))
+		}
+		if name == "" || strings.HasPrefix(name, "-") {
+			errs = append(errs, fmt.Sprintf("drivers.browser.flags has %q; name switches without the leading dashes", name))
+		}
+	}
+	return errs
+}
+
+// validateAlerts checks the alerts section.
+func validateAlerts(cfg *Config) []string {
+	var errs []string
+	a := cfg.Alerts
+	if len(a.Rules) > 0 && len(a.Webhooks) == 0 {
+		errs = append(errs, "alerts.webhooks must have at least one entry when alerts.rules is set")
+	}
+	for i, w := range a.Webhooks {
+		prefix := fmt.Sprintf("alerts.webhooks[%d]", i)
+		if !strings.HasPrefix(w.URL, "http://") && !strings.HasPrefix(w.URL, "https://") {
+			errs = append(errs, fmt.Sprintf("%s.url must start with http:// or https://, got %q", prefix, w.URL))
+		}
+		if w.Format != "" && w.Format != "json" && w.Format != "slack" {
+			errs = append(errs, fmt.Sprintf("%s.format must be json|slack, got %q", prefix, w.Format))
+		}
+		if w.TimeoutS < 0 {
+			errs = append(errs, fmt.Sprintf("%s.timeout_s must be >= 0", prefix))
+		}
+	}
+	for i, r := range a.Rules {
+		prefix := fmt.Sprintf("alerts.rules[%d]", i)
+		conditions := 0
+		if r.ErrorRatePct != 0 {
+			conditions++
+			if r.ErrorRatePct < 0 || r.ErrorRatePct > 100 {
+				errs = append(errs, fmt.Sprintf("%s.error_rate_pct must be > 0 and <= 100, got %g", prefix, r.ErrorRatePct))
+			}
+		}
+		if r.Latency != (LatencyObjective{}) {
+			conditions++
+			if r.Latency.Percentile <= 0 || r.Latency.Percentile > 100 {
+				errs = append(errs, fmt.Sprintf("%s.latency.percentile must be > 0 and <= 100, got %g", prefix, r.Latency.Percentile))
+			}
+			if r.Latency.MaxMs <= 0 {
+				errs = append(errs, prefix+".latency.max_ms must be > 0")
+			}
+		}
+		if r.ConsecutiveFailures != 0 {
+			conditions++
+			if r.ConsecutiveFailures < 0 {
+				errs = append(errs, fmt.Sprintf("%s.consecutive_failures must be > 0", prefix))
+			}
+		}
+		if conditions != 1 {
+			errs = append(errs, fmt.Sprintf("%s must set exactly one of error_rate_pct, latency, consecutive_failures", prefix))
+		}
+		if r.Target != "" && cfg.KV.Type == "" && !hasTargetURL(cfg.Targets, r.Target) {
+			errs = append(errs, fmt.Sprintf("%s.target %q matches no target", prefix, r.Target))
+		}
+	}
+	if a.WindowS <= 0 {
+		errs = append(errs, "alerts.window_s must be > 0")
+	}
+	if a.CheckIntervalS <= 0 {
+		errs = append(errs, "alerts.check_interval_s must be > 0")
+	}
+	if a.MinRequests < 0 {
+		errs = append(errs, "alerts.min_requests must be >= 0")
+	}
+	return errs
+}
+
+func validateThinkTime(prefix string, tt ThinkTimeConfig) []string {
+	var errs []string
+	p := tt.Params
+	switch tt.Distribution {
+	case "":
+	case "fixed":
+		if p.DelayMs < 0 {
+			errs = append(errs, prefix+".params.delay_ms must be >= 0")
+		}
+	case "uniform":
+		if p.MinMs < 0 {
+			errs = append(errs, prefix+".params.min_ms must be >= 0")
+		}
+		if p.MaxMs < p.MinMs {
+			errs = append(errs, prefix+".params.max_ms must be >= min_ms")
+		}
+	case "lognormal":
+		if p.MedianMs <= 0 {
+			errs = append(errs, prefix+".params.median_ms must be > 0")
+		}
+		if p.Sigma <= 0 {
+			errs = append(errs, prefix+".params.sigma must be > 0")
+		}
+		if p.MaxMs < 0 {
+			errs = append(errs, prefix+".params.max_ms must be >= 0")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s.distribution must be fixed|uniform|lognormal, got %q", prefix, tt.Distribution))
+	}
+	return errs
+}
+
 func validateSFTPTarget(i int, t TargetConfig) []string {
 	var errs []string
 	s := t.SFTP
@@ -373,3 +1170,145 @@ func validateSFTPTarget(i int, t TargetConfig) []string {
 
 	return errs
 }
+
+func validateSink(i int, s SinkConfig) []string {
+	var errs []string
+	prefix := fmt.Sprintf("output.sinks[%d]", i)
+
+	switch s.Type {
+	case "http", "elasticsearch":
+		if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+			errs = append(errs, fmt.Sprintf("%s.url must start with http:// or https:// for type %s, got %q", prefix, s.Type, s.URL))
+		}
+	case "syslog":
+		if s.URL != "" && !strings.HasPrefix(s.URL, "udp://") && !strings.HasPrefix(s.URL, "tcp://") && !strings.HasPrefix(s.URL, "unix://") {
+			errs = append(errs, fmt.Sprintf("%s.url must start with udp://, tcp://, or unix:// for type syslog, got %q", prefix, s.URL))
+		}
+		validFacilities := map[string]bool{
+			"kern": true, "user": true, "daemon": true, "auth": true, "syslog": true,
+			"local0": true, "local1": true, "local2": true, "local3": true,
+			"local4": true, "local5": true, "local6": true, "local7": true,
+		}
+		if s.Facility != "" {
+			if !validFacilities[s.Facility] {
+				errs = append(errs, fmt.Sprintf("%s.facility must be one of kern|user|daemon|auth|syslog|local0..local7, got %q", prefix, s.Facility))
+			}
+		}
+	case "stdout":
+		if s.URL != "" {
+			errs = append(errs, fmt.Sprintf("%s.url is not used by type stdout", prefix))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("%s.type must be one of http|elasticsearch|syslog|stdout, got %q", prefix, s.Type))
+	}
+
+	if s.Type == "elasticsearch" {
+		hasBasic := s.Username != ""
+		hasKey := s.APIKey != "" || s.APIKeyEnv != ""
+		if hasBasic && hasKey {
+			errs = append(errs, fmt.Sprintf("%s.username and api_key are mutually exclusive", prefix))
+		}
+		if strings.Count(s.Index, "{") != strings.Count(s.Index, "}") {
+			errs = append(errs, fmt.Sprintf("%s.index has unbalanced braces: %q", prefix, s.Index))
+		}
+	}
+
+	if s.BatchSize < 0 {
+		errs = append(errs, fmt.Sprintf("%s.batch_size must be >= 0", prefix))
+	}
+	if s.FlushIntervalMs < 0 {
+		errs = append(errs, fmt.Sprintf("%s.flush_interval_ms must be >= 0", prefix))
+	}
+	if s.TimeoutS < 0 {
+		errs = append(errs, fmt.Sprintf("%s.timeout_s must be >= 0", prefix))
+	}
+	if s.MaxRetries < 0 {
+		errs = append(errs, fmt.Sprintf("%s.max_retries must be >= 0", prefix))
+	}
+
+	return errs
+}
+
+func validateUpload(o OutputConfig) []string {
+	u := o.Upload
+	if u.Provider == "" {
+		return nil
+	}
+	var errs []string
+	if u.Provider != "s3" && u.Provider != "gcs" {
+		errs = append(errs, fmt.Sprintf("output.upload.provider must be s3|gcs, got %q", u.Provider))
+	}
+	if !o.Enabled {
+		errs = append(errs, "output.upload requires output.enabled to be true")
+	}
+	if o.Append {
+		errs = append(errs, "output.upload cannot be combined with output.append")
+	}
+	if u.Bucket == "" {
+		errs = append(errs, "output.upload.bucket must not be empty")
+	}
+	if u.IntervalS <= 0 {
+		errs = append(errs, "output.upload.interval_s must be > 0")
+	}
+	if u.Endpoint != "" {
+		if u.Provider != "s3" {
+			errs = append(errs, "output.upload.endpoint is only valid for provider s3")
+		} else if !strings.HasPrefix(u.Endpoint, "http://") && !strings.HasPrefix(u.Endpoint, "https://") {
+			errs = append(errs, fmt.Sprintf("output.upload.endpoint must start with http:// or https://, got %q", u.Endpoint))
+		}
+	}
+	return errs
+}
+
+// inheritBackoff fills the unset fields of each backoff.per_domain entry
+// from the global backoff profile.
+func inheritBackoff(b *BackoffConfig) {
+	for i := range b.PerDomain {
+		d := &b.PerDomain[i]
+		if d.InitialMs == 0 {
+			d.InitialMs = b.InitialMs
+		}
+		if d.MaxMs == 0 {
+			d.MaxMs = b.MaxMs
+		}
+		if d.Multiplier == 0 {
+			d.Multiplier = b.Multiplier
+		}
+		if d.MaxAttempts == 0 {
+			d.MaxAttempts = b.MaxAttempts
+		}
+		if d.CooldownS == 0 {
+			d.CooldownS = b.CooldownS
+		}
+	}
+}
+
+// validDomainPattern reports whether a rate_limits.per_domain key is a
+// hostname or a "*.suffix" / ".suffix" pattern.
+func validDomainPattern(d string) bool {
+	name, ok := strings.CutPrefix(d, "*.")
+	if !ok {
+		name = strings.TrimPrefix(d, ".")
+	}
+	return name != "" && !strings.ContainsAny(name, "*/")
+}
+
+// hasTargetURL reports whether any target has the given url.
+func hasTargetURL(targets []TargetConfig, url string) bool {
+	for _, t := range targets {
+		if t.URL == url {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGroup reports whether any target belongs to group.
+func hasGroup(targets []TargetConfig, group string) bool {
+	for _, t := range targets {
+		if t.Group == group {
+			return true
+		}
+	}
+	return false
+}
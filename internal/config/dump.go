@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// redacted replaces secret values in Marshal output. Loading a config
+// that still holds it fails, so a redacted dump cannot run with the
+// placeholder as a credential.
+const redacted = "<redacted>"
+
+// errRedacted is returned when a config value is the redacted placeholder.
+var errRedacted = errors.New(`is the "` + redacted + `" placeholder of a redacted config dump; dump with --show-secrets for a loadable config`)
+
+// Marshal renders cfg as YAML using the same keys as the config file, with
+// fields in schema order. Unless showSecrets is set, literal credentials —
+// auth tokens, passwords, API keys, and header values that look like
+// credentials — are replaced with "<redacted>", and the output, marked as
+// such in a comment, no longer loads. Fields such as token_env that only
+// name an environment variable are always shown.
+//
+// targets_file (with its targets_file_* settings) and include are left
+// out: their targets and settings are already in the output, and loading
+// it back would add them twice.
+func Marshal(cfg *Config, showSecrets bool) ([]byte, error) {
+	root := toNode(reflect.ValueOf(cfg).Elem(), "", !showSecrets)
+	for i := 0; i < len(root.Content); i += 2 {
+		if k := root.Content[i].Value; strings.HasPrefix(k, "targets_file") || k == "include" {
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			i -= 2
+		}
+	}
+	if hasRedacted(root) {
+		root.HeadComment = "Credentials are " + redacted + ", so this config does not load;\n" +
+			"dump it with --show-secrets for a loadable copy."
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func toNode(v reflect.Value, key string, redact bool) *yaml.Node {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+		return toNode(v.Elem(), key, redact)
+	case reflect.Struct:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("mapstructure"), ",")
+			if name == "" || name == "-" || !t.Field(i).IsExported() {
+				continue
+			}
+			n.Content = append(n.Content, scalarNode(name), toNode(v.Field(i), name, redact))
+		}
+		return n
+	case reflect.Map:
+		n := &yaml.Node{Kind: yaml.MappingNode}
+		iter := v.MapRange()
+		var keys []string
+		vals := map[string]reflect.Value{}
+		for iter.Next() {
+			k := iter.Key().String()
+			keys = append(keys, k)
+			vals[k] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			n.Content = append(n.Content, scalarNode(k), toNode(vals[k], k, redact))
+		}
+		return n
+	case reflect.Slice, reflect.Array:
+		n := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			n.Content = append(n.Content, toNode(v.Index(i), key, redact))
+		}
+		return n
+	}
+	if redact && v.Kind() == reflect.String && v.String() != "" && isSecretKey(key) {
+		return scalarNode(redacted)
+	}
+	n := &yaml.Node{}
+	_ = n.Encode(v.Interface())
+	return n
+}
+
+// hasRedacted reports whether any value under n is the redacted placeholder.
+func hasRedacted(n *yaml.Node) bool {
+	if n.Kind == yaml.ScalarNode && n.Value == redacted {
+		return true
+	}
+	return slices.ContainsFunc(n.Content, hasRedacted)
+}
+
+func scalarNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// isSecretKey reports whether a field or header named key holds a
+// credential rather than a reference to one.
+func isSecretKey(key string) bool {
+	k := strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+	if strings.HasSuffix(k, "_env") {
+		return false
+	}
+	for _, s := range []string{"token", "password", "secret", "api_key", "apikey", "authorization", "cookie"} {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnit returns the unit of an integer field named with a _ms or _s
+// suffix, or 0 for any other field.
+func durationUnit(tag string) time.Duration {
+	switch {
+	case strings.HasSuffix(tag, "_ms"):
+		return time.Millisecond
+	case strings.HasSuffix(tag, "_s"):
+		return time.Second
+	}
+	return 0
+}
+
+// durationFieldsHook lets every integer _ms and _s field also be written as a
+// Go duration string ("1.5s", "2m", "250ms"). It runs when a mapping is about
+// to be decoded into a struct, converting duration strings for that struct's
+// unit-suffixed fields to the integer count the field expects. Plain integers,
+// quoted or not, pass through unchanged.
+func durationFieldsHook(unset map[string]bool) func(f, t reflect.Type, data any) (any, error) {
+	return func(f, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.Map || t.Kind() != reflect.Struct {
+			return data, nil
+		}
+		m, ok := data.(map[string]any)
+		if !ok {
+			return data, nil
+		}
+		var out map[string]any
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			unit := durationUnit(tag)
+			if unit == 0 || field.Type.Kind() != reflect.Int {
+				continue
+			}
+			for k, v := range m {
+				s, ok := v.(string)
+				if !ok || !strings.EqualFold(k, tag) {
+					continue
+				}
+				s = strings.TrimSpace(expandEnv(s, unset))
+				if _, err := strconv.Atoi(s); err == nil {
+					continue
+				}
+				n, err := parseDurationCount(s, unit)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", tag, err)
+				}
+				if out == nil {
+					out = copyMap(m)
+				}
+				out[k] = n
+			}
+		}
+		if out == nil {
+			return data, nil
+		}
+		return out, nil
+	}
+}
+
+// parseDurationCount parses the duration string s and returns it as a whole
+// number of unit.
+func parseDurationCount(s string, unit time.Duration) (int, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither an integer nor a duration such as \"1.5s\"", s)
+	}
+	if d%unit != 0 {
+		return 0, fmt.Errorf("%q is not a whole number of %s", s, unitName(unit))
+	}
+	return int(d / unit), nil
+}
+
+func unitName(unit time.Duration) string {
+	if unit == time.Millisecond {
+		return "milliseconds"
+	}
+	return "seconds"
+}
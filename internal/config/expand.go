@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxExpandedTargets caps how many targets a single URL pattern may expand
+// to, so a typo such as [1..100000] fails loudly instead of exhausting memory.
+const maxExpandedTargets = 10000
+
+var (
+	rangePattern = regexp.MustCompile(`\[(\d+)\.\.(\d+)\]`)
+	bracePattern = regexp.MustCompile(`\{([^{}]*,[^{}]*)\}`)
+)
+
+// expandTargets replaces every target whose URL contains a pattern with one
+// target per expansion; each copy keeps the original's weight and settings,
+// while a share is split evenly across the copies.
+//
+//   - [N..M] expands to the integers N through M; a zero-padded start such
+//     as [01..10] pads every value to the same width.
+//   - {a,b,c} expands to each comma-separated alternative.
+//   - For dns targets, a bare CIDR prefix (10.0.0.0/30) expands to every
+//     address in it.
+//
+// When no URL has a pattern, targets is returned as is rather than copied, so
+// a large targets_file is not held twice.
+func expandTargets(targets []TargetConfig) ([]TargetConfig, error) {
+	var out []TargetConfig
+	for i, t := range targets {
+		urls, err := expandURL(t.URL, t.Type)
+		if err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		if out == nil {
+			if len(urls) == 1 && urls[0] == t.URL {
+				continue
+			}
+			out = make([]TargetConfig, i, len(targets)+len(urls)-1)
+			copy(out, targets[:i])
+		}
+		for _, u := range urls {
+			c := t
+			c.URL = u
+			c.Share = t.Share / Percent(len(urls))
+			out = append(out, c)
+		}
+	}
+	if out == nil {
+		return targets, nil
+	}
+	return out, nil
+}
+
+func expandURL(url, typ string) ([]string, error) {
+	if typ == "dns" && strings.Contains(url, "/") {
+		if p, err := netip.ParsePrefix(url); err == nil {
+			return expandCIDR(p)
+		}
+	}
+
+	urls := []string{url}
+	for {
+		var next []string
+		expanded := false
+		for _, u := range urls {
+			alts, ok, err := expandFirst(u)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				next = append(next, u)
+				continue
+			}
+			expanded = true
+			next = append(next, alts...)
+			if len(next) > maxExpandedTargets {
+				return nil, fmt.Errorf("url %q expands to more than %d targets", url, maxExpandedTargets)
+			}
+		}
+		urls = next
+		if !expanded {
+			return urls, nil
+		}
+	}
+}
+
+// expandFirst expands the left-most range or brace group in u.
+func expandFirst(u string) ([]string, bool, error) {
+	r := rangePattern.FindStringSubmatchIndex(u)
+	b := bracePattern.FindStringSubmatchIndex(u)
+	switch {
+	case r == nil && b == nil:
+		return nil, false, nil
+	case b == nil || (r != nil && r[0] < b[0]):
+		lo, hi := u[r[2]:r[3]], u[r[4]:r[5]]
+		start, err1 := strconv.Atoi(lo)
+		end, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || end < start {
+			return nil, false, fmt.Errorf("invalid range [%s..%s] in %q", lo, hi, u)
+		}
+		if end-start+1 > maxExpandedTargets {
+			return nil, false, fmt.Errorf("range [%s..%s] exceeds %d targets", lo, hi, maxExpandedTargets)
+		}
+		width := 0
+		if len(lo) > 1 && lo[0] == '0' {
+			width = len(lo)
+		}
+		out := make([]string, 0, end-start+1)
+		for n := start; n <= end; n++ {
+			out = append(out, u[:r[0]]+fmt.Sprintf("%0*d", width, n)+u[r[1]:])
+		}
+		return out, true, nil
+	default:
+		alts := strings.Split(u[b[2]:b[3]], ",")
+		out := make([]string, 0, len(alts))
+		for _, a := range alts {
+			out = append(out, u[:b[0]]+a+u[b[1]:])
+		}
+		return out, true, nil
+	}
+}
+
+func expandCIDR(p netip.Prefix) ([]string, error) {
+	p = p.Masked()
+	hostBits := p.Addr().BitLen() - p.Bits()
+	if hostBits >= 31 || 1<<hostBits > maxExpandedTargets {
+		return nil, fmt.Errorf("cidr %s expands to more than %d targets", p, maxExpandedTargets)
+	}
+	out := make([]string, 0, 1<<hostBits)
+	for a := p.Addr(); p.Contains(a); a = a.Next() {
+		out = append(out, a.String())
+	}
+	return out, nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+// mergeIncludes reads every fragment matched by the root config's include
+// patterns and merges them with the root file into v. Fragments are applied
+// in pattern order (matches of one pattern sorted by name) and the root file
+// last, so for scalar keys later files win and the root always has the final
+// say. Lists — targets, rate_limits.per_domain, output.sinks, and so on — are
+// concatenated rather than replaced, so each fragment can contribute its own
+// entries.
+func mergeIncludes(v *viper.Viper, rootPath string) error {
+	root, err := readFragment(rootPath)
+	if err != nil {
+		return err
+	}
+	patterns := v.GetStringSlice("include")
+	dir := filepath.Dir(rootPath)
+
+	merged := map[string]any{}
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("bad pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("pattern %q matched no files", pattern)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			abs, _ := filepath.Abs(m)
+			if seen[abs] {
+				continue
+			}
+			seen[abs] = true
+			frag, err := readFragment(m)
+			if err != nil {
+				return err
+			}
+			if _, nested := frag["include"]; nested {
+				return fmt.Errorf("%s: nested include is not supported", m)
+			}
+			mergeMaps(merged, frag)
+		}
+	}
+	mergeMaps(merged, root)
+	delete(merged, "include")
+
+	return v.MergeConfigMap(merged)
+}
+
+// readFragment parses a single YAML file without defaults applied.
+func readFragment(path string) (map[string]any, error) {
+	fv := newYAMLViper()
+	fv.SetConfigFile(path)
+	if err := fv.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return fv.AllSettings(), nil
+}
+
+// mergeMaps merges src into dst: nested maps are merged recursively, lists
+// are appended, and any other value in src replaces the one in dst.
+func mergeMaps(dst, src map[string]any) {
+	for k, sv := range src {
+		switch s := sv.(type) {
+		case map[string]any:
+			if d, ok := dst[k].(map[string]any); ok {
+				mergeMaps(d, s)
+				continue
+			}
+		case []any:
+			if d, ok := dst[k].([]any); ok {
+				dst[k] = append(d, s...)
+				continue
+			}
+		}
+		dst[k] = sv
+	}
+}
@@ -0,0 +1,167 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// decodeState collects side information while a config is decoded. A nil
+// *decodeState is valid and records nothing.
+type decodeState struct {
+	unset   map[string]bool // ${VAR} references that were not set
+	secrets map[string]bool // values resolved from valueFrom references
+}
+
+func newDecodeState() *decodeState {
+	return &decodeState{unset: map[string]bool{}, secrets: map[string]bool{}}
+}
+
+// decodeHook expands environment variable references in every string value,
+// accepts duration strings for _ms and _s fields, and resolves valueFrom
+// references before the usual duration and slice conversions run, so that non-string fields such as limits.max_workers can
+// also be set from the environment.
+func decodeHook(st *decodeState) viper.DecoderConfigOption {
+	return viper.DecodeHook(envDecodeHook(st))
+}
+
+// envDecodeHook is the mapstructure hook behind decodeHook, for decoding
+// that does not go through viper.
+func envDecodeHook(st *decodeState) mapstructure.DecodeHookFunc {
+	var unset map[string]bool
+	if st != nil {
+		unset = st.unset
+	}
+	return mapstructure.ComposeDecodeHookFunc(
+		func(f, _ reflect.Type, data any) (any, error) {
+			if f.Kind() != reflect.String {
+				return data, nil
+			}
+			if data.(string) == redacted {
+				return nil, errRedacted
+			}
+			return expandEnv(data.(string), unset), nil
+		},
+		durationFieldsHook(unset),
+		percentHook,
+		readBodyHook,
+		func(f, t reflect.Type, data any) (any, error) {
+			if f.Kind() != reflect.Map || t.Kind() != reflect.String {
+				return data, nil
+			}
+			m, ok := data.(map[string]any)
+			if !ok {
+				return data, nil
+			}
+			v, ok, err := resolveValueFrom(m, unset)
+			if err != nil || !ok {
+				return data, err
+			}
+			if st != nil {
+				st.secrets[v] = true
+			}
+			return v, nil
+		},
+		mapstructure.StringToTimeDurationHookFunc(),
+		// Same behaviour as viper's default string-to-slice hook.
+		func(f, t reflect.Type, data any) (any, error) {
+			if f.Kind() != reflect.String || t.Kind() != reflect.Slice {
+				return data, nil
+			}
+			if data.(string) == "" {
+				return []string{}, nil
+			}
+			return strings.Split(data.(string), ","), nil
+		},
+	)
+}
+
+// resolveValueFrom resolves a {valueFrom: {env: NAME}} or
+// {valueFrom: {file: path}} reference used in place of a string value. ok is
+// false when m is not such a reference. File contents have one trailing
+// newline removed, matching how secret files are usually written.
+func resolveValueFrom(m map[string]any, unset map[string]bool) (string, bool, error) {
+	if len(m) != 1 {
+		return "", false, nil
+	}
+	var ref map[string]any
+	for k, v := range m {
+		if !strings.EqualFold(k, "valueFrom") {
+			return "", false, nil
+		}
+		var ok bool
+		if ref, ok = v.(map[string]any); !ok {
+			return "", false, errors.New("valueFrom must be a mapping with env or file")
+		}
+	}
+	if len(ref) != 1 {
+		return "", false, errors.New("valueFrom must set exactly one of env or file")
+	}
+	for k, v := range ref {
+		name, _ := v.(string)
+		switch strings.ToLower(k) {
+		case "env":
+			val, ok := os.LookupEnv(name)
+			if !ok {
+				return "", false, fmt.Errorf("valueFrom: environment variable %q is not set", name)
+			}
+			return val, true, nil
+		case "file":
+			data, err := os.ReadFile(expandEnv(name, unset))
+			if err != nil {
+				return "", false, fmt.Errorf("valueFrom: %w", err)
+			}
+			val := strings.TrimSuffix(string(data), "\n")
+			return strings.TrimSuffix(val, "\r"), true, nil
+		}
+		return "", false, fmt.Errorf("valueFrom: unknown source %q (must be env or file)", k)
+	}
+	return "", false, nil
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} references in s. A bare $
+// or $VAR is left untouched so literal dollar signs in passwords and regexes
+// keep working; $${ produces a literal ${. Unset variables without a default
+// expand to the empty string and are recorded in unset.
+func expandEnv(s string, unset map[string]bool) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if s[i+1] == '$' && i+2 < len(s) && s[i+2] == '{' {
+			b.WriteString("${")
+			i += 2
+			continue
+		}
+		if s[i+1] != '{' {
+			b.WriteByte(s[i])
+			continue
+		}
+		end := strings.IndexByte(s[i+2:], '}')
+		if end < 0 {
+			b.WriteString(s[i:])
+			break
+		}
+		ref := s[i+2 : i+2+end]
+		name, def, hasDef := strings.Cut(ref, ":-")
+		if val, ok := os.LookupEnv(name); ok && (val != "" || !hasDef) {
+			b.WriteString(val)
+		} else if hasDef {
+			b.WriteString(def)
+		} else if unset != nil {
+			unset[name] = true
+		}
+		i += 2 + end
+	}
+	return b.String()
+}
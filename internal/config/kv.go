@@ -0,0 +1,381 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConsulAddress = "http://127.0.0.1:8500"
+	defaultEtcdAddress   = "http://127.0.0.1:2379"
+	defaultKVPrefix      = "sendit/"
+	defaultKVPollS       = 10
+
+	// consulMinWait spaces Consul queries whose index did not advance.
+	consulMinWait = time.Second
+)
+
+// KVSource reads targets and rate limits from a Consul or etcd key prefix.
+//
+// Layout under the prefix:
+//
+//	targets/<id>                 one target, as YAML or JSON (same fields as a targets entry)
+//	rate_limits/default_rps      a number
+//	rate_limits/per_domain/<d>   requests per second for domain <d>
+//
+// Refresh fetches the prefix — as a Consul blocking query, or a plain read
+// for etcd — and Apply overlays the last fetched entries on a base config.
+type KVSource struct {
+	kind     string
+	address  string
+	prefix   string
+	token    string
+	username string
+	password string
+	wait     time.Duration
+	client   *http.Client
+
+	mu          sync.Mutex
+	entries     map[string][]byte
+	fingerprint [sha256.Size]byte
+	consulIndex uint64
+	consulPause time.Duration
+}
+
+// NewKVSource returns a source for cfg. Secrets are read from the
+// environment variables named by cfg.TokenEnv and cfg.PasswordEnv.
+func NewKVSource(cfg KVConfig) (*KVSource, error) {
+	s := &KVSource{
+		kind:     cfg.Type,
+		address:  strings.TrimSuffix(cfg.Address, "/"),
+		prefix:   cfg.Prefix,
+		username: cfg.Username,
+		wait:     time.Duration(cfg.PollIntervalS) * time.Second,
+	}
+	if s.prefix == "" {
+		s.prefix = defaultKVPrefix
+	}
+	if s.wait <= 0 {
+		s.wait = defaultKVPollS * time.Second
+	}
+	if cfg.TokenEnv != "" {
+		s.token = os.Getenv(cfg.TokenEnv)
+	}
+	if cfg.PasswordEnv != "" {
+		s.password = os.Getenv(cfg.PasswordEnv)
+	}
+	switch s.kind {
+	case "consul":
+		if s.address == "" {
+			s.address = defaultConsulAddress
+		}
+	case "etcd":
+		if s.address == "" {
+			s.address = defaultEtcdAddress
+		}
+	default:
+		return nil, fmt.Errorf("unknown kv type %q", s.kind)
+	}
+	// Consul holds a blocking query open for up to wait; leave headroom.
+	s.client = &http.Client{Timeout: s.wait + 30*time.Second}
+	return s, nil
+}
+
+// PollInterval is how long callers should sleep between Refresh calls.
+// It is zero for Consul, whose blocking queries already wait for changes,
+// unless the last query's index did not move forward: then the next query
+// would return at once, so callers pause as for a plain read.
+func (s *KVSource) PollInterval() time.Duration {
+	if s.kind == "consul" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.consulPause
+	}
+	return s.wait
+}
+
+// Refresh fetches all keys under the prefix and reports whether they differ
+// from the previous fetch. The first successful call always reports true.
+func (s *KVSource) Refresh(ctx context.Context) (bool, error) {
+	var (
+		entries map[string][]byte
+		err     error
+	)
+	if s.kind == "consul" {
+		entries, err = s.fetchConsul(ctx)
+	} else {
+		entries, err = s.fetchEtcd(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\x00%s\x00", k, entries[k])
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := s.entries == nil || sum != s.fingerprint
+	s.entries = entries
+	s.fingerprint = sum
+	return changed, nil
+}
+
+// Apply returns a copy of base with the fetched targets appended and the
+// fetched rate limits overriding base's, validated like a loaded config.
+func (s *KVSource) Apply(base *Config) (*Config, error) {
+	s.mu.Lock()
+	entries := s.entries
+	s.mu.Unlock()
+
+	cfg := *base
+	cfg.Targets = append([]TargetConfig(nil), base.Targets...)
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	perDomain := map[string]DomainRateLimit{}
+	var domainOrder []string
+	for _, d := range base.RateLimits.PerDomain {
+		if _, ok := perDomain[d.Domain]; !ok {
+			domainOrder = append(domainOrder, d.Domain)
+		}
+		perDomain[d.Domain] = d
+	}
+
+	for _, k := range keys {
+		val := entries[k]
+		switch {
+		case strings.HasPrefix(k, "targets/"):
+			t, err := decodeKVTarget(val, base.targetDefaults)
+			if err != nil {
+				return nil, fmt.Errorf("kv key %s%s: %w", s.prefix, k, err)
+			}
+			cfg.Targets = append(cfg.Targets, t)
+		case k == "rate_limits/default_rps":
+			rps, err := strconv.ParseFloat(strings.TrimSpace(string(val)), 64)
+			if err != nil {
+				return nil, fmt.Errorf("kv key %s%s: %w", s.prefix, k, err)
+			}
+			cfg.RateLimits.DefaultRPS = rps
+		case strings.HasPrefix(k, "rate_limits/per_domain/"):
+			domain := strings.TrimPrefix(k, "rate_limits/per_domain/")
+			rps, err := strconv.ParseFloat(strings.TrimSpace(string(val)), 64)
+			if err != nil {
+				return nil, fmt.Errorf("kv key %s%s: %w", s.prefix, k, err)
+			}
+			d, ok := perDomain[domain]
+			if !ok {
+				domainOrder = append(domainOrder, domain)
+			}
+			d.Domain, d.RPS = domain, rps
+			perDomain[domain] = d
+		}
+	}
+
+	cfg.RateLimits.PerDomain = make([]DomainRateLimit, 0, len(domainOrder))
+	for _, d := range domainOrder {
+		cfg.RateLimits.PerDomain = append(cfg.RateLimits.PerDomain, perDomain[d])
+	}
+
+	targets, err := expandTargets(cfg.Targets[len(base.Targets):])
+	if err != nil {
+		return nil, fmt.Errorf("kv: %w", err)
+	}
+	normalizeDNSResolvers(targets)
+	cfg.Targets = append(cfg.Targets[:len(base.Targets)], targets...)
+
+	if len(cfg.Targets) == 0 {
+		return nil, errors.New("no targets in config or under the kv prefix")
+	}
+	if err := validate(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// decodeKVTarget decodes a targets/<id> value on top of the raw
+// target_defaults section, as buildTarget does for targets_file entries.
+func decodeKVTarget(val []byte, defaults map[string]any) (TargetConfig, error) {
+	v := newYAMLViper() // YAML is a superset of JSON
+	if err := v.ReadConfig(bytes.NewReader(val)); err != nil {
+		return TargetConfig{}, err
+	}
+	return buildTarget(v.AllSettings(), defaults, nil)
+}
+
+type consulKV struct {
+	Key   string `json:"Key"`
+	Value []byte `json:"Value"` // base64 in JSON; nil for folder keys
+}
+
+func (s *KVSource) fetchConsul(ctx context.Context) (map[string][]byte, error) {
+	s.mu.Lock()
+	index := s.consulIndex
+	s.mu.Unlock()
+
+	q := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", fmt.Sprintf("%ds", int(s.wait.Seconds())))
+	}
+	u := s.address + "/v1/kv/" + strings.TrimPrefix(s.prefix, "/") + "?" + q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.token != "" {
+		req.Header.Set("X-Consul-Token", s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var kvs []consulKV
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.NewDecoder(resp.Body).Decode(&kvs); err != nil {
+			return nil, fmt.Errorf("consul: decoding response: %w", err)
+		}
+	case http.StatusNotFound: // empty prefix
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("consul: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Per the Consul blocking-query docs, reset the index if it goes
+	// backwards or is not positive.
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if next < index {
+		next = 0
+	}
+	// A query whose index did not advance either timed out or woke early;
+	// without an index at all there is nothing to block on, so fall back to
+	// polling.
+	var pause time.Duration
+	switch {
+	case next == 0:
+		pause = s.wait
+	case next == index:
+		pause = consulMinWait
+	}
+	s.mu.Lock()
+	s.consulIndex = next
+	s.consulPause = pause
+	s.mu.Unlock()
+
+	out := make(map[string][]byte, len(kvs))
+	for _, kv := range kvs {
+		if kv.Value == nil || strings.HasSuffix(kv.Key, "/") {
+			continue
+		}
+		out[strings.TrimPrefix(kv.Key, strings.TrimPrefix(s.prefix, "/"))] = kv.Value
+	}
+	return out, nil
+}
+
+func (s *KVSource) fetchEtcd(ctx context.Context) (map[string][]byte, error) {
+	var token string
+	if s.username != "" {
+		t, err := s.etcdAuthenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		token = t
+	}
+
+	body, _ := json.Marshal(map[string]string{
+		"key":       base64.StdEncoding.EncodeToString([]byte(s.prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(prefixRangeEnd(s.prefix)),
+	})
+	var out struct {
+		KVs []struct {
+			Key   []byte `json:"key"`
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}
+	if err := s.etcdPost(ctx, "/v3/kv/range", token, body, &out); err != nil {
+		return nil, err
+	}
+	entries := make(map[string][]byte, len(out.KVs))
+	for _, kv := range out.KVs {
+		entries[strings.TrimPrefix(string(kv.Key), s.prefix)] = kv.Value
+	}
+	return entries, nil
+}
+
+func (s *KVSource) etcdAuthenticate(ctx context.Context) (string, error) {
+	body, _ := json.Marshal(map[string]string{"name": s.username, "password": s.password})
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := s.etcdPost(ctx, "/v3/auth/authenticate", "", body, &out); err != nil {
+		return "", err
+	}
+	return out.Token, nil
+}
+
+func (s *KVSource) etcdPost(ctx context.Context, path, token string, body []byte, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.address+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("etcd: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("etcd %s: HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("etcd %s: decoding response: %w", path, err)
+	}
+	return nil
+}
+
+// prefixRangeEnd returns the etcd range_end that selects every key with the
+// given prefix: the prefix with its last byte incremented.
+func prefixRangeEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0} // whole keyspace
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// TargetOverrides holds targets added or removed at runtime through the
+// control socket. Like kv entries they are overlaid on the base config on
+// every reload, so they survive SIGHUP and remote refreshes, but they are
+// never written back to any file and are lost when the process exits.
+//
+// The zero value has no overrides and is ready to use.
+type TargetOverrides struct {
+	mu      sync.Mutex
+	added   []TargetConfig
+	removed map[string]bool // URLs dropped from the base config
+}
+
+// TargetOverridesSnapshot is a saved state of a TargetOverrides; see
+// Snapshot and Restore.
+type TargetOverridesSnapshot struct {
+	added   []TargetConfig
+	removed map[string]bool
+}
+
+// ParseTarget decodes one target from YAML or JSON, with the same fields as
+// a targets entry.
+func ParseTarget(data []byte) (TargetConfig, error) {
+	v := newYAMLViper() // YAML is a superset of JSON
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return TargetConfig{}, err
+	}
+	var t TargetConfig
+	if err := v.Unmarshal(&t, decodeHook(nil)); err != nil {
+		return TargetConfig{}, err
+	}
+	return t, nil
+}
+
+// Add records t as a runtime target. A URL that was removed earlier is
+// restored instead of being added twice.
+func (o *TargetOverrides) Add(t TargetConfig) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.removed, t.URL)
+	o.added = append(o.added, t)
+}
+
+// Remove drops every target with url, whether it came from the base config,
+// an earlier Add, or the expansion of an added pattern.
+func (o *TargetOverrides) Remove(url string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.added = slices.DeleteFunc(o.added, func(t TargetConfig) bool { return t.URL == url })
+	if o.removed == nil {
+		o.removed = make(map[string]bool)
+	}
+	o.removed[url] = true
+}
+
+// IsAdded reports whether url was added at runtime, either literally or as
+// one expansion of an added URL pattern.
+func (o *TargetOverrides) IsAdded(url string) bool {
+	o.mu.Lock()
+	added := slices.Clone(o.added)
+	o.mu.Unlock()
+	targets, _ := expandTargets(added)
+	return slices.ContainsFunc(targets, func(t TargetConfig) bool { return t.URL == url })
+}
+
+// Snapshot returns a copy of the current overrides so that a change can be
+// rolled back with Restore when the resulting config does not validate.
+func (o *TargetOverrides) Snapshot() TargetOverridesSnapshot {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return TargetOverridesSnapshot{added: slices.Clone(o.added), removed: maps.Clone(o.removed)}
+}
+
+// Restore replaces the overrides with s.
+func (o *TargetOverrides) Restore(s TargetOverridesSnapshot) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.added, o.removed = slices.Clone(s.added), maps.Clone(s.removed)
+}
+
+// Apply returns a copy of base with added targets appended (weights
+// defaulted to target_defaults.weight and patterns expanded) and removed URLs
+// dropped, validated like a loaded config.
+func (o *TargetOverrides) Apply(base *Config) (*Config, error) {
+	o.mu.Lock()
+	added := slices.Clone(o.added)
+	removed := maps.Clone(o.removed)
+	o.mu.Unlock()
+
+	cfg := *base
+	cfg.Targets = make([]TargetConfig, 0, len(base.Targets)+len(added))
+	for _, t := range base.Targets {
+		if !removed[t.URL] {
+			cfg.Targets = append(cfg.Targets, t)
+		}
+	}
+
+	for i := range added {
+		if added[i].Weight == 0 {
+			added[i].Weight = base.TargetDefaults.Weight
+		}
+		if added[i].Weight <= 0 {
+			added[i].Weight = 1
+		}
+	}
+	targets, err := expandTargets(added)
+	if err != nil {
+		return nil, fmt.Errorf("runtime targets: %w", err)
+	}
+	normalizeDNSResolvers(targets)
+	for _, t := range targets {
+		if !removed[t.URL] {
+			cfg.Targets = append(cfg.Targets, t)
+		}
+	}
+
+	if len(cfg.Targets) == 0 {
+		return nil, errors.New("no targets left")
+	}
+	if err := validate(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &cfg, nil
+}
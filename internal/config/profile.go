@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// applyProfile merges the overlay under profiles.<name> over the base settings
+// in v. Nested maps are merged key by key; scalars and lists in the overlay
+// replace the base value, so a profile can swap out the whole targets list.
+// An empty name leaves v unchanged.
+func applyProfile(v *viper.Viper, name string) error {
+	if name == "" {
+		return nil
+	}
+	profiles, _ := v.Get("profiles").(map[string]any)
+	overlay, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		if len(profiles) == 0 {
+			return fmt.Errorf("profile %q: config defines no profiles", name)
+		}
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("profile %q not defined (have: %s)", name, strings.Join(names, ", "))
+	}
+	if overlay == nil {
+		return nil
+	}
+	m, ok := overlay.(map[string]any)
+	if !ok {
+		return fmt.Errorf("profile %q must be a mapping", name)
+	}
+	for _, key := range []string{"profiles", "include"} {
+		if _, nested := m[key]; nested {
+			return fmt.Errorf("profile %q: %s is not supported inside a profile", name, key)
+		}
+	}
+	return v.MergeConfigMap(m)
+}
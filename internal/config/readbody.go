@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ReadBody is how much of a response body http.read_body reads before
+// closing the connection. In YAML it is true (all of it, the default),
+// false (none: stop after the headers), or a number of bytes.
+type ReadBody int64
+
+const (
+	// ReadAll reads the whole body; it is the zero value.
+	ReadAll ReadBody = 0
+	// ReadNone stops after the headers.
+	ReadNone ReadBody = -1
+)
+
+var readBodyType = reflect.TypeOf(ReadBody(0))
+
+// readBodyHook parses true, false, and byte counts into ReadBody fields. A
+// count of 0 reads no body, like false.
+func readBodyHook(f, t reflect.Type, data any) (any, error) {
+	if t != readBodyType {
+		return data, nil
+	}
+	switch f.Kind() {
+	case reflect.Bool:
+		if data.(bool) {
+			return ReadAll, nil
+		}
+		return ReadNone, nil
+	case reflect.String:
+		s := strings.TrimSpace(data.(string))
+		if b, err := strconv.ParseBool(s); err == nil {
+			return readBodyHook(reflect.TypeOf(b), t, b)
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid read_body %q: want true, false, or a number of bytes", data)
+		}
+		return readBodyBytes(n), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return readBodyBytes(reflect.ValueOf(data).Int()), nil
+	}
+	return data, nil
+}
+
+func readBodyBytes(n int64) ReadBody {
+	if n == 0 {
+		return ReadNone
+	}
+	return ReadBody(n)
+}
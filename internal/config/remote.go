@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lewta/sendit/internal/awssig"
+)
+
+const (
+	remoteFetchTimeout = 30 * time.Second
+	maxRemoteConfig    = 8 << 20 // 8 MiB
+
+	// A remote targets_file is streamed rather than buffered, so it gets a
+	// far larger cap and a longer deadline than a config document.
+	remoteTargetsTimeout = 10 * time.Minute
+	maxRemoteTargetsFile = 2 << 30 // 2 GiB
+)
+
+// IsRemote reports whether path names a remote config source (http://,
+// https://, or s3://) rather than a local file.
+func IsRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") ||
+		strings.HasPrefix(path, "https://") ||
+		strings.HasPrefix(path, "s3://")
+}
+
+// RemoteSource fetches a config over HTTP(S) or from S3 and remembers the
+// ETag and digest of the last successfully loaded version, so that polling
+// only re-parses the config when it has changed, even from a server that
+// sends no ETag.
+//
+// s3:// URLs are signed with credentials from AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY (region from AWS_REGION). AWS_ENDPOINT_URL selects an
+// S3-compatible endpoint, addressed path-style.
+type RemoteSource struct {
+	url     string
+	s3      bool
+	profile string
+	client  *http.Client
+	etag    string
+	digest  string // sha256 of the last loaded body
+}
+
+// NewRemoteSource validates rawURL and returns a source for it. A non-empty
+// profile is applied to every version loaded, as in LoadProfile.
+func NewRemoteSource(rawURL, profile string) (*RemoteSource, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config url: %w", err)
+	}
+	src := &RemoteSource{profile: profile, client: &http.Client{Timeout: remoteFetchTimeout}}
+	switch u.Scheme {
+	case "http", "https":
+		src.url = rawURL
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("s3 config url must be s3://bucket/key, got %q", rawURL)
+		}
+		if ep := strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/"); ep != "" {
+			src.url = ep + "/" + awssig.URIEscape(u.Host) + "/" + awssig.URIEscape(key)
+		} else {
+			src.url = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", u.Host, awssig.RegionFromEnv(), awssig.URIEscape(key))
+		}
+		src.s3 = true
+	default:
+		return nil, fmt.Errorf("unsupported config url scheme %q", u.Scheme)
+	}
+	return src, nil
+}
+
+// Load fetches the config and, if it changed since the last successful
+// Load, parses and validates it. changed is false (and cfg nil) when the
+// server reports the current version is unchanged, or sends the same body.
+func (s *RemoteSource) Load(ctx context.Context) (cfg *Config, changed bool, err error) {
+	body, etag, err := s.fetch(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("fetching config: %w", err)
+	}
+	if body == nil {
+		return nil, false, nil
+	}
+	sum := digest(body)
+	if sum == s.digest {
+		s.etag = etag
+		return nil, false, nil
+	}
+
+	v := newViper()
+	if err := v.ReadConfig(bytes.NewReader(body)); err != nil {
+		return nil, false, fmt.Errorf("reading config: %w", err)
+	}
+	if v.IsSet("include") {
+		return nil, false, errors.New("include is not supported in remote configs")
+	}
+	cfg, err = decode(v, s.profile)
+	if err != nil {
+		return nil, false, err
+	}
+	s.etag, s.digest = etag, sum
+	return cfg, true, nil
+}
+
+// fetch returns a nil body when the server answers 304 Not Modified.
+func (s *RemoteSource) fetch(ctx context.Context) ([]byte, string, error) {
+	rc, etag, err := s.open(ctx, maxRemoteConfig)
+	if err != nil || rc == nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, etag, nil
+}
+
+// open starts the request and returns the response body, which fails with
+// an error once more than limit bytes have been read from it. It returns a
+// nil body when the server answers 304 Not Modified.
+func (s *RemoteSource) open(ctx context.Context, limit int64) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.etag != "" {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	if s.s3 {
+		creds, err := awssig.CredentialsFromEnv()
+		if err != nil {
+			return nil, "", fmt.Errorf("s3: %w", err)
+		}
+		awssig.Sign(req, awssig.EmptyPayloadHash, awssig.RegionFromEnv(), "s3", creds, time.Now())
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		resp.Body.Close()
+		return nil, "", nil
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return &limitedBody{rc: resp.Body, limit: limit}, resp.Header.Get("ETag"), nil
+}
+
+// limitedBody reads from rc, failing once more than limit bytes have been
+// read instead of truncating silently as io.LimitReader would.
+type limitedBody struct {
+	rc    io.ReadCloser
+	limit int64
+	n     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	b.n += int64(n)
+	if b.n > b.limit {
+		return n, fmt.Errorf("response exceeds %d bytes", b.limit)
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error { return b.rc.Close() }
+
+// openTargetsFile opens a local targets_file, or starts fetching a remote
+// one with the same http(s):// and s3:// handling as RemoteSource. Either way
+// the file is read as a stream, so its size is bounded only by
+// maxRemoteTargetsFile for remote files.
+func openTargetsFile(ctx context.Context, path string) (io.ReadCloser, error) {
+	if !IsRemote(path) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening %q: %w", path, err)
+		}
+		return f, nil
+	}
+	src, err := NewRemoteSource(path, "")
+	if err != nil {
+		return nil, err
+	}
+	src.client = &http.Client{Timeout: remoteTargetsTimeout}
+	rc, _, err := src.open(ctx, maxRemoteTargetsFile)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %q: %w", path, err)
+	}
+	return &namedBody{rc, path}, nil
+}
+
+// namedBody adds the targets_file URL to read errors, which otherwise only
+// surface from the parser part way through the file.
+type namedBody struct {
+	io.ReadCloser
+	path string
+}
+
+func (b *namedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = fmt.Errorf("fetching %q: %w", b.path, err)
+	}
+	return n, err
+}
+
+// targetsFileName returns the part of a targets_file path or URL whose
+// extension selects the file format, ignoring any query string.
+func targetsFileName(path string) string {
+	if IsRemote(path) {
+		if u, err := url.Parse(path); err == nil {
+			return u.Path
+		}
+	}
+	return path
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// TargetsFileChanged re-fetches cfg's remote targets_file and reports whether
+// its contents differ from those cfg was loaded with. It always returns false
+// for a local or unset targets_file, which is only re-read on reload.
+func TargetsFileChanged(ctx context.Context, cfg *Config) (bool, error) {
+	if !IsRemote(cfg.TargetsFile) {
+		return false, nil
+	}
+	f, err := openTargetsFile(ctx, cfg.TargetsFile)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) != cfg.targetsDigest, nil
+}
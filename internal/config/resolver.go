@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultDNSPort is the port of a dns resolver given without one.
+const defaultDNSPort = "53"
+
+// hostnamePattern matches a DNS hostname: dot-separated labels of letters,
+// digits, hyphens, and underscores, not starting or ending with a hyphen.
+var hostnamePattern = regexp.MustCompile(`^([A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?\.)*[A-Za-z0-9_]([A-Za-z0-9_-]{0,61}[A-Za-z0-9_])?\.?$`)
+
+// ResolverAddr returns s, a dns.resolver or dns.resolvers entry, as the
+// host:port to send queries to. s may be an IPv4 address, an IPv6 address
+// with or without brackets, or a hostname, each with an optional port that
+// defaults to 53. An IPv6 address is only taken to have a port when it is
+// bracketed, as in "[2001:db8::1]:5353".
+func ResolverAddr(s string) (string, error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		// No port, or an IPv6 address without brackets.
+		host, port = s, defaultDNSPort
+		if h, ok := strings.CutPrefix(s, "["); ok {
+			if host, ok = strings.CutSuffix(h, "]"); !ok {
+				return "", errors.New("unclosed [")
+			}
+		}
+	}
+	if host == "" {
+		return "", errors.New("missing host")
+	}
+	if _, err := netip.ParseAddr(host); err != nil && !hostnamePattern.MatchString(host) {
+		return "", errors.New("not an IP address or hostname")
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return "", fmt.Errorf("invalid port %q", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// resolverFamily returns "ipv4" or "ipv6" for addr, a host:port from
+// ResolverAddr, when its host is an IP address, and "" for a hostname.
+func resolverFamily(addr string) string {
+	host, _, _ := net.SplitHostPort(addr)
+	ip, err := netip.ParseAddr(host)
+	switch {
+	case err != nil:
+		return ""
+	case ip.Is4() || ip.Is4In6():
+		return "ipv4"
+	}
+	return "ipv6"
+}
+
+// normalizeDNSResolvers rewrites the resolvers of dns targets as host:port,
+// so that the driver, its failover state, and output records all see one
+// spelling of each. Entries that do not parse are left for validate to
+// report.
+func normalizeDNSResolvers(targets []TargetConfig) {
+	for i := range targets {
+		if targets[i].Type != "dns" {
+			continue
+		}
+		d := &targets[i].DNS
+		if addr, err := ResolverAddr(d.Resolver); err == nil {
+			d.Resolver = addr
+		}
+		for j, r := range d.Resolvers {
+			if addr, err := ResolverAddr(r); err == nil {
+				d.Resolvers[j] = addr
+			}
+		}
+	}
+}
@@ -6,19 +6,48 @@ type Config struct {
 	Limits         LimitsConfig         `mapstructure:"limits"`
 	RateLimits     RateLimitsConfig     `mapstructure:"rate_limits"`
 	Backoff        BackoffConfig        `mapstructure:"backoff"`
+	Retry          RetryConfig          `mapstructure:"retry"`
 	Targets        []TargetConfig       `mapstructure:"targets"`
 	TargetsFile    string               `mapstructure:"targets_file"`
 	TargetDefaults TargetDefaultsConfig `mapstructure:"target_defaults"`
 	Output         OutputConfig         `mapstructure:"output"`
 	Metrics        MetricsConfig        `mapstructure:"metrics"`
 	Daemon         DaemonConfig         `mapstructure:"daemon"`
+	KV             KVConfig             `mapstructure:"kv"`
+	Network        NetworkConfig        `mapstructure:"network"`
+	SLO            SLOConfig            `mapstructure:"slo"`
+	Alerts         AlertsConfig         `mapstructure:"alerts"`
+	Blackouts      []BlackoutConfig     `mapstructure:"blackouts"`
+	Drivers        DriversConfig        `mapstructure:"drivers"`
+	// Include lists glob patterns of YAML fragments merged into this config.
+	// Relative patterns are resolved against the directory of the root file.
+	Include []string `mapstructure:"include"`
+	// TargetsFileRefreshS is how often a remote targets_file is re-fetched;
+	// 0 disables polling. Ignored for local files.
+	TargetsFileRefreshS int `mapstructure:"targets_file_refresh_s"`
+	// TargetsFileMaxEntries caps how many targets_file entries are kept;
+	// 0 keeps them all. TargetsFileSampling chooses what happens past the
+	// cap: none fails the load, top_weight keeps the heaviest entries, and
+	// reservoir keeps a uniform random sample.
+	TargetsFileMaxEntries int    `mapstructure:"targets_file_max_entries"`
+	TargetsFileSampling   string `mapstructure:"targets_file_sampling"`
+
+	// targetsDigest is the sha256 of the targets_file contents last loaded,
+	// used by TargetsFileChanged.
+	targetsDigest string
+	// targetDefaults is the raw target_defaults section, kept so that kv
+	// targets are built on the same defaults as targets_file entries.
+	targetDefaults map[string]any
 }
 
 // TargetDefaultsConfig holds fallback values applied to every target loaded
-// from targets_file. Fields left at their zero value fall through to each
-// driver's own built-in defaults.
+// from targets_file, and to inline targets when ApplyToInline is set. Fields
+// left at their zero value fall through to each driver's own built-in
+// defaults.
 type TargetDefaultsConfig struct {
-	Weight    int             `mapstructure:"weight"`
+	ApplyToInline bool `mapstructure:"apply_to_inline"`
+
+	Weight    float64         `mapstructure:"weight"`
 	Auth      AuthConfig      `mapstructure:"auth"`
 	HTTP      HTTPConfig      `mapstructure:"http"`
 	Browser   BrowserConfig   `mapstructure:"browser"`
@@ -26,6 +55,9 @@ type TargetDefaultsConfig struct {
 	WebSocket WebSocketConfig `mapstructure:"websocket"`
 	GRPC      GRPCConfig      `mapstructure:"grpc"`
 	SFTP      SFTPConfig      `mapstructure:"sftp"`
+
+	LatencyBudgetMs int `mapstructure:"latency_budget_ms"`
+	TaskDeadlineS   int `mapstructure:"task_deadline_s"`
 }
 
 // PacingConfig controls how requests are spaced in time.
@@ -36,10 +68,30 @@ type PacingConfig struct {
 	MinDelayMs        int             `mapstructure:"min_delay_ms"`
 	MaxDelayMs        int             `mapstructure:"max_delay_ms"`
 	Schedule          []ScheduleEntry `mapstructure:"schedule"`
+	// ScheduleOverlap is how scheduled windows that are open at once
+	// combine: "sum" adds up their rates, "max" runs at the fastest one's,
+	// and "latest" at that of the window opened last.
+	ScheduleOverlap string `mapstructure:"schedule_overlap"`
 	// RampUpS is the number of seconds over which burst mode linearly
 	// increases from a throttled start to full-speed dispatch. Only used
 	// when Mode is "burst". 0 means no ramp-up (immediate full speed).
 	RampUpS int `mapstructure:"ramp_up_s"`
+	// DomainSpacingMs keeps target selection from picking the same domain
+	// again within this many milliseconds while other domains are
+	// available. 0 disables it.
+	DomainSpacingMs int `mapstructure:"domain_spacing_ms"`
+	// Selection is how targets are picked: "random" draws each one
+	// independently by weight; "deck" deals them from shuffled decks that
+	// keep the weights over short stretches too.
+	Selection string `mapstructure:"selection"`
+	// LoadModel is how requests arrive: "open" dispatches on the pacing
+	// schedule whether or not earlier requests have finished; "closed" runs
+	// VirtualUsers loops that each wait for their request before pausing
+	// and picking the next.
+	LoadModel string `mapstructure:"load_model"`
+	// VirtualUsers is the number of closed-model loops; 0 means one per
+	// limits.max_workers.
+	VirtualUsers int `mapstructure:"virtual_users"`
 }
 
 // ScheduleEntry defines a cron-based active window with its own RPM.
@@ -47,6 +99,9 @@ type ScheduleEntry struct {
 	Cron              string  `mapstructure:"cron"`
 	DurationMinutes   int     `mapstructure:"duration_minutes"`
 	RequestsPerMinute float64 `mapstructure:"requests_per_minute"`
+	// Group limits the window to the targets in that group; empty drives
+	// every target.
+	Group string `mapstructure:"group"`
 }
 
 // LimitsConfig controls concurrency and resource thresholds.
@@ -55,18 +110,76 @@ type LimitsConfig struct {
 	MaxBrowserWorkers int     `mapstructure:"max_browser_workers"`
 	CPUThresholdPct   float64 `mapstructure:"cpu_threshold_pct"`
 	MemoryThresholdMB uint64  `mapstructure:"memory_threshold_mb"`
+	// BrowserAutoscale, when enabled, moves the browser limit between 1
+	// and MaxBrowserWorkers with the memory Chrome is seen to use.
+	BrowserAutoscale BrowserAutoscaleConfig `mapstructure:"browser_autoscale"`
+}
+
+// BrowserAutoscaleConfig configures limits.browser_autoscale.
+type BrowserAutoscaleConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MemoryBudgetMB caps the memory all Chrome processes may use together;
+	// 0 lets them use what is left below memory_threshold_mb.
+	MemoryBudgetMB uint64 `mapstructure:"memory_budget_mb"`
 }
 
 // RateLimitsConfig holds global and per-domain rate limits.
 type RateLimitsConfig struct {
 	DefaultRPS float64           `mapstructure:"default_rps"`
 	PerDomain  []DomainRateLimit `mapstructure:"per_domain"`
+	// Burst is the bucket size of the per-domain limiters and of the
+	// rate_limited / scheduled pacing limiter: how many requests may go out
+	// back to back after an idle spell.
+	Burst int `mapstructure:"burst"`
+	// GlobalRPS caps the combined rate across all domains; 0 disables it.
+	GlobalRPS float64 `mapstructure:"global_rps"`
+	// HonorHeaders lowers a domain's rate to the budget advertised by
+	// RateLimit-* / X-RateLimit-* response headers until it resets.
+	HonorHeaders bool `mapstructure:"honor_headers"`
+	// HonorCrawlDelay spaces the requests of http and browser targets to a
+	// domain by at least the Crawl-delay its robots.txt sets.
+	HonorCrawlDelay bool `mapstructure:"honor_crawl_delay"`
+	// Adaptive lowers a domain's rate while its p95 latency is high.
+	Adaptive AdaptiveRateConfig `mapstructure:"adaptive"`
+	// Redis shares the per-domain budgets with other sendit instances.
+	Redis RedisRateConfig `mapstructure:"redis"`
+}
+
+// RedisRateConfig points sendit at a Redis server holding per-domain rate
+// limit buckets shared by every instance configured with the same server
+// and KeyPrefix, so that together they stay within each domain's rate.
+type RedisRateConfig struct {
+	Address     string `mapstructure:"address"` // host:port; empty disables
+	Username    string `mapstructure:"username"`
+	PasswordEnv string `mapstructure:"password_env"`
+	DB          int    `mapstructure:"db"`
+	TLS         bool   `mapstructure:"tls"`
+	KeyPrefix   string `mapstructure:"key_prefix"` // default "sendit:ratelimit:"
+	TimeoutMs   int    `mapstructure:"timeout_ms"` // per command, default 1000
+}
+
+// AdaptiveRateConfig controls latency-adaptive per-domain rate limiting.
+// Every IntervalS seconds a domain whose recent p95 latency exceeds
+// P95ThresholdMs has its rate multiplied by DecreaseFactor, down to MinRPS;
+// once latency is back under the threshold it regains RecoveryStep of its
+// configured rate per interval.
+type AdaptiveRateConfig struct {
+	Enabled        bool    `mapstructure:"enabled"`
+	P95ThresholdMs int     `mapstructure:"p95_threshold_ms"`
+	MinRPS         float64 `mapstructure:"min_rps"`
+	DecreaseFactor float64 `mapstructure:"decrease_factor"`
+	RecoveryStep   float64 `mapstructure:"recovery_step"`
+	IntervalS      int     `mapstructure:"interval_s"`
 }
 
 // DomainRateLimit specifies a per-domain requests-per-second limit.
 type DomainRateLimit struct {
 	Domain string  `mapstructure:"domain"`
-	RPS    float64 `mapstructure:"rps"`
+	RPS    float64 `mapstructure:"rps"`   // 0 = rate_limits.default_rps
+	Burst  int     `mapstructure:"burst"` // 0 = rate_limits.burst
+	// MinIntervalMs is the least time between the starts of two requests
+	// to the domain, whatever RPS and Burst allow; 0 = none.
+	MinIntervalMs int `mapstructure:"min_interval_ms"`
 }
 
 // BackoffConfig controls retry/backoff behaviour.
@@ -75,12 +188,44 @@ type BackoffConfig struct {
 	MaxMs       int     `mapstructure:"max_ms"`
 	Multiplier  float64 `mapstructure:"multiplier"`
 	MaxAttempts int     `mapstructure:"max_attempts"`
+	// CooldownS quarantines a domain for this many seconds once it has
+	// failed MaxAttempts times in a row: its tasks are skipped until the
+	// cooldown ends. 0 lets traffic resume after the last backoff delay.
+	CooldownS int `mapstructure:"cooldown_s"`
+	// PerDomain overrides the profile above for matching domains.
+	PerDomain []DomainBackoff `mapstructure:"per_domain"`
+}
+
+// RetryConfig re-dispatches a failed task, after its domain's backoff
+// delay, instead of dropping it.
+type RetryConfig struct {
+	// MaxRetries is how many times one task may be sent again; 0 disables
+	// retries.
+	MaxRetries int `mapstructure:"max_retries"`
+	// On lists the failure classes that are retried: transient (network
+	// errors, 429, 5xx) and permanent (other 4xx).
+	On []string `mapstructure:"on"`
+	// BudgetPerMinute caps retries across all tasks, so that an outage
+	// does not multiply the load on a failing service; 0 is unlimited.
+	BudgetPerMinute int `mapstructure:"budget_per_minute"`
+}
+
+// DomainBackoff is a per-domain backoff profile. Domain is a hostname or a
+// "*.suffix" / ".suffix" pattern, as in rate_limits.per_domain; fields left
+// unset inherit the global backoff values when the config is loaded.
+type DomainBackoff struct {
+	Domain      string  `mapstructure:"domain"`
+	InitialMs   int     `mapstructure:"initial_ms"`
+	MaxMs       int     `mapstructure:"max_ms"`
+	Multiplier  float64 `mapstructure:"multiplier"`
+	MaxAttempts int     `mapstructure:"max_attempts"`
+	CooldownS   int     `mapstructure:"cooldown_s"`
 }
 
 // TargetConfig describes a single request target.
 type TargetConfig struct {
 	URL       string          `mapstructure:"url"`
-	Weight    int             `mapstructure:"weight"`
+	Weight    float64         `mapstructure:"weight"`
 	Type      string          `mapstructure:"type"` // http | browser | dns | websocket | grpc | sftp
 	Auth      AuthConfig      `mapstructure:"auth"`
 	HTTP      HTTPConfig      `mapstructure:"http"`
@@ -89,6 +234,113 @@ type TargetConfig struct {
 	WebSocket WebSocketConfig `mapstructure:"websocket"`
 	GRPC      GRPCConfig      `mapstructure:"grpc"`
 	SFTP      SFTPConfig      `mapstructure:"sftp"`
+	// Share, when set, gives the target a fixed percentage of all picks
+	// instead of one proportional to its weight. See EffectiveWeights.
+	Share Percent `mapstructure:"share"`
+	// Group names the target set a pacing.schedule window can drive.
+	Group string `mapstructure:"group"`
+	// Network overrides the global network settings for this target.
+	Network TargetNetworkConfig `mapstructure:"network"`
+	// ThinkTime, when set, replaces the human-mode delay range for the
+	// pause that follows a request to this target.
+	ThinkTime ThinkTimeConfig `mapstructure:"think_time"`
+	// MirrorURL, when set, duplicates MirrorPct percent of the requests to
+	// this target (all of them when MirrorPct is 0) to a second endpoint,
+	// sent at the same time with the same settings. Both results carry a
+	// shared correlation_id.
+	MirrorURL string  `mapstructure:"mirror_url"`
+	MirrorPct float64 `mapstructure:"mirror_pct"`
+	// LatencyBudgetMs, when set, marks a response that took longer than
+	// this as slow, whatever its status. 0 disables it.
+	LatencyBudgetMs int `mapstructure:"latency_budget_ms"`
+	// TaskDeadlineS bounds how long the driver may take over one request,
+	// whatever its own timeouts; the engine cancels it and frees the
+	// worker slot when it passes. 0 disables it.
+	TaskDeadlineS int `mapstructure:"task_deadline_s"`
+}
+
+// ThinkTimeConfig draws the pause after a request from a distribution.
+type ThinkTimeConfig struct {
+	Distribution string          `mapstructure:"distribution"` // fixed | uniform | lognormal; "" uses pacing's range
+	Params       ThinkTimeParams `mapstructure:"params"`
+}
+
+// ThinkTimeParams holds the parameters of every distribution; each uses
+// only its own.
+type ThinkTimeParams struct {
+	DelayMs  int     `mapstructure:"delay_ms"`  // fixed
+	MinMs    int     `mapstructure:"min_ms"`    // uniform
+	MaxMs    int     `mapstructure:"max_ms"`    // uniform; caps lognormal when > 0
+	MedianMs int     `mapstructure:"median_ms"` // lognormal
+	Sigma    float64 `mapstructure:"sigma"`     // lognormal spread
+}
+
+// NetworkConfig controls how drivers open connections.
+type NetworkConfig struct {
+	// IPFamily restricts the addresses drivers dial: "any" uses whatever
+	// the resolver returns, "ipv4" or "ipv6" only that family.
+	IPFamily string `mapstructure:"ip_family"`
+	// Proxies is a pool of socks5:// URLs that TCP connections are routed
+	// through, one proxy chosen per connection; empty dials directly.
+	Proxies []string `mapstructure:"proxies"`
+	// ProxySelection is how a proxy is chosen: "round_robin" or "random".
+	ProxySelection string `mapstructure:"proxy_selection"`
+	// ProxyHealthCheck takes proxies that fail a check out of rotation.
+	ProxyHealthCheck ProxyHealthCheckConfig `mapstructure:"proxy_health_check"`
+}
+
+// ProxyHealthCheckConfig controls the periodic SOCKS5 handshake made with
+// every proxy in network.proxies.
+type ProxyHealthCheckConfig struct {
+	IntervalS int `mapstructure:"interval_s"` // 0 disables checks
+	TimeoutMs int `mapstructure:"timeout_ms"`
+}
+
+// DriversConfig tunes the internals each driver shares across all of its
+// targets, as opposed to the per-target settings under targets[].
+type DriversConfig struct {
+	HTTP    HTTPDriverConfig    `mapstructure:"http"`
+	DNS     DNSDriverConfig     `mapstructure:"dns"`
+	Browser BrowserDriverConfig `mapstructure:"browser"`
+}
+
+// HTTPDriverConfig tunes the HTTP driver's transports. Zero values keep
+// the built-in defaults noted on each field.
+type HTTPDriverConfig struct {
+	MaxIdleConns        int `mapstructure:"max_idle_conns"`          // across all hosts (default 100)
+	MaxIdleConnsPerHost int `mapstructure:"max_idle_conns_per_host"` // default 10
+	MaxConnsPerHost     int `mapstructure:"max_conns_per_host"`      // default 0, unlimited
+	IdleConnTimeoutS    int `mapstructure:"idle_conn_timeout_s"`     // default 90
+	// TLSSessionCacheSize, if set, keeps that many TLS sessions for
+	// resumption on new connections; 0 does a full handshake every time.
+	// Targets with a tls_fingerprint do not use the cache.
+	TLSSessionCacheSize int `mapstructure:"tls_session_cache_size"`
+}
+
+// DNSDriverConfig tunes the DNS driver's client.
+type DNSDriverConfig struct {
+	// UDPSize is the EDNS0 UDP payload size of targets that set no
+	// dns.udp_size; 0 sends no EDNS0 record.
+	UDPSize int `mapstructure:"udp_size"`
+	// TCPFallback asks again over TCP when a UDP answer is truncated.
+	TCPFallback bool `mapstructure:"tcp_fallback"`
+}
+
+// BrowserDriverConfig controls how the browser driver launches Chrome.
+type BrowserDriverConfig struct {
+	// ExecPath is the Chrome or Chromium binary; empty searches the usual
+	// install locations.
+	ExecPath string `mapstructure:"exec_path"`
+	// Flags are extra Chrome command-line switches, by name without the
+	// leading dashes: true adds a bare switch, false removes one that is
+	// on by default, and a string or number is passed as its value.
+	Flags map[string]any `mapstructure:"flags"`
+}
+
+// TargetNetworkConfig overrides NetworkConfig for one target.
+type TargetNetworkConfig struct {
+	// IPFamily is as in NetworkConfig; empty inherits the global setting.
+	IPFamily string `mapstructure:"ip_family"`
 }
 
 // AuthConfig defines optional authentication applied to a target request.
@@ -114,6 +366,68 @@ type HTTPConfig struct {
 	Body                    string            `mapstructure:"body"`
 	TimeoutS                int               `mapstructure:"timeout_s"`
 	AllowCrossHostRedirects bool              `mapstructure:"allow_cross_host_redirects"`
+
+	// Methods, if set, replaces Method with a weighted mix: each request
+	// picks a method with probability proportional to its weight. Keys are
+	// lowercased by the YAML parser and sent uppercased.
+	Methods map[string]float64 `mapstructure:"methods"`
+	// BodySize, if its max is set, sends random text of a size drawn from
+	// it as the body of every request, instead of Body.
+	BodySize BodySizeConfig `mapstructure:"body_size"`
+	// Resolve pins hostnames to addresses, like curl --resolve: requests to
+	// Host connect to Address instead of a looked-up IP, while the Host
+	// header and TLS server name stay unchanged.
+	Resolve []ResolveEntry `mapstructure:"resolve"`
+	// Resolver is the host:port of the DNS server that looks up hosts not
+	// listed in Resolve; empty uses the system resolver.
+	Resolver string `mapstructure:"resolver"`
+	// TLSFingerprint makes HTTPS requests present a browser's TLS
+	// ClientHello: "chrome", "firefox", or "safari". Empty or "go" keeps
+	// Go's own.
+	TLSFingerprint string `mapstructure:"tls_fingerprint"`
+	// HeaderProfile adds the navigation headers a browser sends: "chrome",
+	// "firefox", or "safari". Headers still override them; empty or "none"
+	// adds nothing.
+	HeaderProfile string `mapstructure:"header_profile"`
+	// TraceHeader names a header that carries a fresh ID on every request,
+	// recorded as request_id in output records and logs. "traceparent"
+	// sends a W3C trace context instead of a bare UUID.
+	TraceHeader string `mapstructure:"trace_header"`
+	// CaptureBody records the start of the response body in the output
+	// record, for failed responses or all of them.
+	CaptureBody CaptureBodyConfig `mapstructure:"capture_body"`
+	// ReadBody, when false or a byte count, closes the connection after the
+	// headers or that many bytes of the body, like a user abandoning the
+	// page load.
+	ReadBody ReadBody `mapstructure:"read_body"`
+	// ReadRateBps, if set, reads the response body no faster than this
+	// many bytes per second, like a client on a slow link. The request,
+	// and its worker slot, last until the body is read or timeout_s passes.
+	ReadRateBps int `mapstructure:"read_rate_bps"`
+	// AbortProbability is the fraction of requests cancelled mid-flight,
+	// each after a random delay of up to AbortAfterMs (default 1000), like
+	// users giving up on a load. Requests that finish first are unaffected.
+	AbortProbability float64 `mapstructure:"abort_probability"`
+	AbortAfterMs     int     `mapstructure:"abort_after_ms"`
+}
+
+// BodySizeConfig draws the size, in bytes, of a generated request body.
+type BodySizeConfig struct {
+	Min          int    `mapstructure:"min"`
+	Max          int    `mapstructure:"max"`          // 0 disables generated bodies
+	Distribution string `mapstructure:"distribution"` // uniform | lognormal; "" means uniform
+}
+
+// CaptureBodyConfig bounds the response body kept by http.capture_body.
+type CaptureBodyConfig struct {
+	MaxBytes int    `mapstructure:"max_bytes"` // 0 disables capture
+	On       string `mapstructure:"on"`        // error | always; "" means error
+}
+
+// ResolveEntry maps one hostname to the IP address to connect to.
+type ResolveEntry struct {
+	Host    string `mapstructure:"host"`
+	Address string `mapstructure:"address"`
 }
 
 // BrowserConfig holds headless-browser target settings.
@@ -121,12 +435,49 @@ type BrowserConfig struct {
 	Scroll          bool   `mapstructure:"scroll"`
 	WaitForSelector string `mapstructure:"wait_for_selector"`
 	TimeoutS        int    `mapstructure:"timeout_s"`
+	// Locale (a language tag such as "de-DE") and Timezone (an IANA zone
+	// such as "Europe/Berlin") are emulated for the page, and Locale is
+	// sent as Accept-Language.
+	Locale   string `mapstructure:"locale"`
+	Timezone string `mapstructure:"timezone"`
+	// NetworkProfile throttles the page's traffic like a client network:
+	// 3g, 4g, cable, or custom, which takes its numbers from NetworkCustom.
+	NetworkProfile string                  `mapstructure:"network_profile"`
+	NetworkCustom  NetworkConditionsConfig `mapstructure:"network_custom"`
+	// AbortProbability and AbortAfterMs are as in HTTPConfig: the browser
+	// is closed with the page still loading.
+	AbortProbability float64 `mapstructure:"abort_probability"`
+	AbortAfterMs     int     `mapstructure:"abort_after_ms"`
+	// ChromePath, if set, is the Chrome or Chromium binary launched for
+	// this target instead of drivers.browser.exec_path. ExtraFlags are
+	// command-line switches added after the driver's, such as
+	// "--no-sandbox" or "--proxy-server=http://proxy:3128".
+	ChromePath string   `mapstructure:"chrome_path"`
+	ExtraFlags []string `mapstructure:"extra_flags"`
+}
+
+// NetworkConditionsConfig configures browser.network_custom.
+type NetworkConditionsConfig struct {
+	LatencyMs    int `mapstructure:"latency_ms"`    // added to every request's round trip
+	DownloadKbps int `mapstructure:"download_kbps"` // kilobits per second
+	UploadKbps   int `mapstructure:"upload_kbps"`
 }
 
 // DNSConfig holds DNS resolver target settings.
 type DNSConfig struct {
-	Resolver   string `mapstructure:"resolver"`
-	RecordType string `mapstructure:"record_type"`
+	Resolver string `mapstructure:"resolver"`
+	// Resolvers, if set, replaces Resolver with a list of host:port servers
+	// tried in order: a query that gets no response fails over to the next,
+	// and a resolver that keeps failing is tried last until it recovers.
+	Resolvers  []string `mapstructure:"resolvers"`
+	RecordType string   `mapstructure:"record_type"`
+	// TimeoutS bounds each query sent to a resolver, in seconds (default
+	// 10); with resolvers, every resolver tried gets its own timeout.
+	TimeoutS int `mapstructure:"timeout_s"`
+	// UDPSize, if set, is the EDNS0 UDP payload size advertised in queries,
+	// so that answers up to that many bytes are not truncated; answers that
+	// still are get asked for again over TCP. 0 sends no EDNS0 record.
+	UDPSize int `mapstructure:"udp_size"`
 }
 
 // WebSocketConfig holds WebSocket target settings.
@@ -134,6 +485,14 @@ type WebSocketConfig struct {
 	DurationS      int      `mapstructure:"duration_s"`
 	SendMessages   []string `mapstructure:"send_messages"`
 	ExpectMessages int      `mapstructure:"expect_messages"`
+	// MeasureEcho sends send_messages one at a time, waiting for the server
+	// to echo each back, and reports the round-trip times.
+	MeasureEcho bool `mapstructure:"measure_echo"`
+	// Close is how the driver ends the connection: normal (a 1000 close
+	// frame), going_away (1001), or reset (a TCP reset, with no close
+	// frame). CloseMix, if set, replaces it with a weighted mix of them.
+	Close    string             `mapstructure:"close"`
+	CloseMix map[string]float64 `mapstructure:"close_mix"`
 }
 
 // GRPCConfig holds gRPC target settings.
@@ -167,11 +526,73 @@ type SFTPConfig struct {
 
 // OutputConfig controls writing request results to a file.
 type OutputConfig struct {
-	Enabled  bool   `mapstructure:"enabled"`
-	File     string `mapstructure:"file"`
-	Format   string `mapstructure:"format"` // jsonl | csv
-	Append   bool   `mapstructure:"append"`
-	PCAPFile string `mapstructure:"pcap_file"` // write synthetic PCAP alongside normal output
+	Enabled  bool         `mapstructure:"enabled"`
+	File     string       `mapstructure:"file"`
+	Format   string       `mapstructure:"format"` // jsonl | csv | clf
+	Append   bool         `mapstructure:"append"`
+	OnFull   string       `mapstructure:"on_full"`   // drop | block — file writer and every sink
+	PCAPFile string       `mapstructure:"pcap_file"` // write synthetic PCAP alongside normal output
+	Sinks    []SinkConfig `mapstructure:"sinks"`
+	// SampleRate is the fraction of successful results passed to the file
+	// writer, sinks, and PCAP writer. SampleErrors is the independent
+	// fraction for failed results (error or status >= 400). Both default to 1.
+	SampleRate   float64 `mapstructure:"sample_rate"`
+	SampleErrors float64 `mapstructure:"sample_errors"`
+	// Details adds optional per-request debugging fields to output records.
+	Details OutputDetailsConfig `mapstructure:"details"`
+	// Upload periodically rotates the output file and ships each completed
+	// segment to object storage.
+	Upload UploadConfig `mapstructure:"upload"`
+}
+
+// UploadConfig configures periodic upload of rotated output files to S3 or
+// GCS. Credentials are read from the environment: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and optional AWS_SESSION_TOKEN for s3;
+// GOOGLE_OAUTH_ACCESS_TOKEN or the GCE metadata server for gcs.
+type UploadConfig struct {
+	Provider    string `mapstructure:"provider"` // s3 | gcs; empty disables upload
+	Bucket      string `mapstructure:"bucket"`
+	Prefix      string `mapstructure:"prefix"`       // object key prefix, e.g. "sendit/"
+	IntervalS   int    `mapstructure:"interval_s"`   // rotation interval
+	DeleteLocal bool   `mapstructure:"delete_local"` // remove segments once uploaded
+	Region      string `mapstructure:"region"`       // s3 only; falls back to AWS_REGION
+	Endpoint    string `mapstructure:"endpoint"`     // s3 only; path-style endpoint for S3-compatible stores
+}
+
+// OutputDetailsConfig gates the extra fields HTTP results carry into output
+// records. Everything is off by default to keep records small.
+type OutputDetailsConfig struct {
+	Timings          bool     `mapstructure:"timings"`            // dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms
+	RemoteIP         bool     `mapstructure:"remote_ip"`          // remote_ip
+	TLS              bool     `mapstructure:"tls"`                // tls_version, tls_cipher
+	ResponseHeaders  []string `mapstructure:"response_headers"`   // header_<name> for each listed header
+	BodySnippetBytes int      `mapstructure:"body_snippet_bytes"` // body_snippet truncated to this many bytes; 0 disables
+}
+
+// SinkConfig describes an additional destination that receives every result
+// record, independent of the file writer controlled by Enabled.
+type SinkConfig struct {
+	Type            string            `mapstructure:"type"` // http | elasticsearch | syslog | stdout
+	URL             string            `mapstructure:"url"`
+	BatchSize       int               `mapstructure:"batch_size"`        // records per request (default 100)
+	FlushIntervalMs int               `mapstructure:"flush_interval_ms"` // max time a partial batch is held (default 1000)
+	Headers         map[string]string `mapstructure:"headers"`
+	TimeoutS        int               `mapstructure:"timeout_s"`   // per-request timeout (default 10)
+	MaxRetries      int               `mapstructure:"max_retries"` // retries per batch after the first attempt (default 3)
+
+	// Elasticsearch / OpenSearch settings. Index may contain a Go time layout
+	// in braces, e.g. "sendit-{2006.01.02}", expanded from each record's ts.
+	Index       string `mapstructure:"index"`
+	Username    string `mapstructure:"username"`
+	Password    string `mapstructure:"password"`
+	PasswordEnv string `mapstructure:"password_env"`
+	APIKey      string `mapstructure:"api_key"`
+	APIKeyEnv   string `mapstructure:"api_key_env"`
+
+	// Syslog settings. URL selects the transport — udp://host:514,
+	// tcp://host:514, or unix:///dev/log; empty means the local syslog socket.
+	Facility string `mapstructure:"facility"` // default local0
+	AppName  string `mapstructure:"app_name"` // default sendit
 }
 
 // MetricsConfig controls Prometheus metrics exposition.
@@ -179,6 +600,92 @@ type MetricsConfig struct {
 	Enabled        bool   `mapstructure:"enabled"`
 	BindAddress    string `mapstructure:"bind_address"`
 	PrometheusPort int    `mapstructure:"prometheus_port"`
+	// PerTarget adds series labelled with each target URL; off by default
+	// because their number grows with the target list.
+	PerTarget bool `mapstructure:"per_target"`
+}
+
+// SLOConfig lists the service level objectives `sendit run` checks once the
+// run ends. The top-level objectives apply to all requests together; each
+// entry in Targets adds objectives for one target URL.
+type SLOConfig struct {
+	AvailabilityPct float64            `mapstructure:"availability_pct"` // 0 disables
+	Latency         []LatencyObjective `mapstructure:"latency"`
+	Targets         []TargetSLOConfig  `mapstructure:"targets"`
+}
+
+// TargetSLOConfig holds the objectives for the target whose url matches URL.
+type TargetSLOConfig struct {
+	URL             string             `mapstructure:"url"`
+	AvailabilityPct float64            `mapstructure:"availability_pct"`
+	Latency         []LatencyObjective `mapstructure:"latency"`
+}
+
+// LatencyObjective requires the Percentile-th percentile latency of
+// successful requests to be at most MaxMs.
+type LatencyObjective struct {
+	Percentile float64 `mapstructure:"percentile"`
+	MaxMs      int     `mapstructure:"max_ms"`
+}
+
+// AlertsConfig defines thresholds watched while sendit runs. A rule that is
+// breached sends a "firing" notification to every webhook, and a
+// "resolved" one once it recovers.
+type AlertsConfig struct {
+	Webhooks []AlertWebhookConfig `mapstructure:"webhooks"`
+	Rules    []AlertRuleConfig    `mapstructure:"rules"`
+	// WindowS is the span of recent results that error-rate and latency
+	// rules are evaluated over, every CheckIntervalS. Rules do not fire on
+	// fewer than MinRequests results in the window.
+	WindowS        int `mapstructure:"window_s"`         // default 300
+	CheckIntervalS int `mapstructure:"check_interval_s"` // default 30
+	MinRequests    int `mapstructure:"min_requests"`     // default 20
+}
+
+// AlertWebhookConfig is a URL that alert notifications are POSTed to.
+type AlertWebhookConfig struct {
+	URL      string            `mapstructure:"url"`
+	Format   string            `mapstructure:"format"` // json (default) | slack
+	Headers  map[string]string `mapstructure:"headers"`
+	TimeoutS int               `mapstructure:"timeout_s"` // default 10
+}
+
+// AlertRuleConfig is one alert condition; exactly one of ErrorRatePct,
+// Latency, and ConsecutiveFailures is set. Target restricts the rule to the
+// target with that url. Without it, error-rate and latency rules cover all
+// requests, and consecutive failures are counted per target.
+type AlertRuleConfig struct {
+	Name                string           `mapstructure:"name"`
+	Target              string           `mapstructure:"target"`
+	ErrorRatePct        float64          `mapstructure:"error_rate_pct"`
+	Latency             LatencyObjective `mapstructure:"latency"`
+	ConsecutiveFailures int              `mapstructure:"consecutive_failures"`
+}
+
+// BlackoutConfig is a period in which no requests are sent, whatever the
+// pacing mode, such as a change freeze or maintenance window. It either
+// runs from Start to End, or for DurationMinutes after each firing of Cron.
+type BlackoutConfig struct {
+	// Name identifies the blackout in logs and status.
+	Name string `mapstructure:"name"`
+	// Start and End are RFC 3339 timestamps or YYYY-MM-DD dates in local
+	// time; an End date includes that whole day.
+	Start           string `mapstructure:"start"`
+	End             string `mapstructure:"end"`
+	Cron            string `mapstructure:"cron"`
+	DurationMinutes int    `mapstructure:"duration_minutes"`
+}
+
+// KVConfig configures an optional Consul or etcd backend that supplies
+// targets and rate limits from a key prefix and is watched for changes.
+type KVConfig struct {
+	Type          string `mapstructure:"type"`    // consul | etcd; empty disables
+	Address       string `mapstructure:"address"` // default http://127.0.0.1:8500 (consul) or :2379 (etcd)
+	Prefix        string `mapstructure:"prefix"`  // default "sendit/"
+	TokenEnv      string `mapstructure:"token_env"`
+	Username      string `mapstructure:"username"` // etcd only
+	PasswordEnv   string `mapstructure:"password_env"`
+	PollIntervalS int    `mapstructure:"poll_interval_s"` // etcd poll interval / consul blocking wait, default 10
 }
 
 // DaemonConfig holds daemon/process settings.
@@ -186,4 +693,25 @@ type DaemonConfig struct {
 	PIDFile   string `mapstructure:"pid_file"`
 	LogLevel  string `mapstructure:"log_level"`
 	LogFormat string `mapstructure:"log_format"`
+	// LogFile receives the logs of a detached `sendit start`, rotated once
+	// it reaches LogMaxSizeMB (0 disables rotation) with LogMaxBackups old
+	// files kept.
+	LogFile       string `mapstructure:"log_file"`
+	LogMaxSizeMB  int    `mapstructure:"log_max_size_mb"`
+	LogMaxBackups int    `mapstructure:"log_max_backups"`
+	// ControlSocket is the Unix socket `sendit targets` talks to; empty
+	// disables the control API.
+	ControlSocket string        `mapstructure:"control_socket"`
+	TaskLog       TaskLogConfig `mapstructure:"task_log"`
+}
+
+// TaskLogConfig routes the log events written for each dispatched task
+// (completions, errors, backoffs) away from the main log, which keeps the
+// lifecycle events. Successful and failed tasks are sampled independently.
+type TaskLogConfig struct {
+	Mode string `mapstructure:"mode"` // main (default) | file | none
+	// File receives the events with mode file, rotated like log_file.
+	File         string  `mapstructure:"file"`
+	SampleRate   float64 `mapstructure:"sample_rate"`   // share of successful-task events kept, default 1
+	SampleErrors float64 `mapstructure:"sample_errors"` // share of failed-task events kept, default 1
 }
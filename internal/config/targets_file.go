@@ -0,0 +1,309 @@
+package config
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-viper/mapstructure/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// targetTopLevelKeys are the keys of a target entry that are not part of a
+// driver-specific section.
+var targetTopLevelKeys = map[string]bool{
+	"url": true, "type": true, "weight": true, "share": true, "auth": true,
+	"http": true, "browser": true, "dns": true, "websocket": true, "grpc": true, "sftp": true,
+	"mirror_url": true, "mirror_pct": true, "latency_budget_ms": true, "task_deadline_s": true,
+}
+
+// loadStructuredTargets reads a CSV, JSON, or YAML targets file from r and
+// passes each entry to emit with its position in the file.
+//
+// CSV files start with a header row naming the columns: url, type, weight,
+// or any target field either as a dotted path (http.method, dns.resolver,
+// http.headers.X-Api-Key) or as a bare field of the row's driver section
+// (method, resolver, timeout_s). Empty cells are skipped, so the value from
+// target_defaults applies. A headers cell holds "Name: value" pairs
+// separated by ";".
+//
+// JSON and YAML files hold a list of target entries, either at the top level
+// or under a "targets" key, with the same fields as inline targets. Bare
+// driver fields are accepted there too. CSV and JSON are parsed row by row;
+// a YAML file is parsed as a whole.
+func loadStructuredTargets(path string, r io.Reader, emit func(n int, row map[string]any) error) error {
+	switch strings.ToLower(filepath.Ext(targetsFileName(path))) {
+	case ".csv":
+		return readCSVTargets(path, r, emit)
+	case ".json":
+		return readJSONTargets(path, r, emit)
+	default:
+		return readDocumentTargets(path, r, emit)
+	}
+}
+
+func readCSVTargets(path string, r io.Reader, emit func(n int, row map[string]any) error) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %q: %w", path, err)
+	}
+	header = slices.Clone(header)
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	for n := 1; ; n++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %q: %w", path, err)
+		}
+		row := map[string]any{}
+		for i, col := range header {
+			if i >= len(rec) || strings.TrimSpace(rec[i]) == "" || col == "" {
+				continue
+			}
+			row[col] = strings.TrimSpace(rec[i])
+		}
+		if err := emit(n, row); err != nil {
+			return err
+		}
+	}
+}
+
+// readJSONTargets decodes a JSON list of targets one element at a time, so
+// the file is never held in memory as a whole.
+func readJSONTargets(path string, r io.Reader, emit func(n int, row map[string]any) error) error {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", path, err)
+	}
+	if tok == json.Delim('{') {
+		// Skip to the "targets" key; a document without one has no targets.
+		for {
+			if !dec.More() {
+				return nil
+			}
+			key, err := dec.Token()
+			if err != nil {
+				return fmt.Errorf("parsing %q: %w", path, err)
+			}
+			if key == "targets" {
+				break
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("parsing %q: %w", path, err)
+			}
+		}
+		if tok, err = dec.Token(); err != nil {
+			return fmt.Errorf("parsing %q: %w", path, err)
+		}
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("%q: expected a list of targets", path)
+	}
+	for n := 1; dec.More(); n++ {
+		var item any
+		if err := dec.Decode(&item); err != nil {
+			return fmt.Errorf("parsing %q: %w", path, err)
+		}
+		m, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%q: entry %d is not a mapping", path, n)
+		}
+		if err := emit(n, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDocumentTargets(path string, r io.Reader, emit func(n int, row map[string]any) error) error {
+	var node yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&node); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing %q: %w", path, err)
+	}
+	var doc any
+	if node.Kind != 0 {
+		untagTimestamps(&node)
+		if err := node.Decode(&doc); err != nil {
+			return fmt.Errorf("parsing %q: %w", path, err)
+		}
+	}
+	if m, ok := doc.(map[string]any); ok {
+		doc = m["targets"]
+	}
+	if doc == nil {
+		return nil
+	}
+	list, ok := doc.([]any)
+	if !ok {
+		return fmt.Errorf("%q: expected a list of targets", path)
+	}
+	for i, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%q: entry %d is not a mapping", path, i+1)
+		}
+		if err := emit(i+1, m); err != nil {
+			return err
+		}
+		list[i] = nil // let the entry be collected once it is built or dropped
+	}
+	return nil
+}
+
+// buildTarget expands row's shorthand keys, overlays it on defaults, and
+// decodes the result.
+func buildTarget(row, defaults map[string]any, st *decodeState) (TargetConfig, error) {
+	expanded, err := expandTargetKeys(row)
+	if err != nil {
+		return TargetConfig{}, err
+	}
+	// Decode the entry on its own first so that misspelt fields are
+	// reported instead of silently ignored.
+	if err := decodeTarget(expanded, true, nil, &TargetConfig{}); err != nil {
+		return TargetConfig{}, err
+	}
+
+	merged := copyMap(defaults)
+	overlayMaps(merged, expanded)
+	var t TargetConfig
+	if err := decodeTarget(merged, false, st, &t); err != nil {
+		return TargetConfig{}, err
+	}
+	if t.Weight <= 0 && expanded["weight"] == nil {
+		t.Weight = 1
+	}
+	return t, nil
+}
+
+func decodeTarget(raw map[string]any, strict bool, st *decodeState, t *TargetConfig) error {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           t,
+		WeaklyTypedInput: true,
+		ErrorUnused:      strict,
+		DecodeHook:       envDecodeHook(st),
+	})
+	if err != nil {
+		return err
+	}
+	return dec.Decode(raw)
+}
+
+// expandTargetKeys turns dotted keys into nested maps and moves bare driver
+// fields into the section named by the row's type, so "method" on an http
+// row becomes http.method. It is shared by structured files and the
+// key=value pairs of the text format.
+func expandTargetKeys(row map[string]any) (map[string]any, error) {
+	typ, _ := row["type"].(string)
+	typ = strings.ToLower(strings.TrimSpace(typ))
+
+	out := map[string]any{}
+	for k, v := range row {
+		path := strings.Split(k, ".")
+		path[0] = strings.ToLower(path[0])
+		if !targetTopLevelKeys[path[0]] {
+			if typ == "" || !targetTopLevelKeys[typ] {
+				return nil, fmt.Errorf("field %q needs a valid type to resolve", k)
+			}
+			path = append([]string{typ}, path...)
+		}
+		if path[0] == "type" {
+			v = typ
+		}
+		if s, ok := v.(string); ok && path[len(path)-1] == "headers" {
+			h, err := parseHeaderList(s)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", k, err)
+			}
+			v = h
+		}
+		setPath(out, path, v)
+	}
+	return out, nil
+}
+
+// parseHeaderList parses "Name: value; Other: value".
+func parseHeaderList(s string) (map[string]any, error) {
+	out := map[string]any{}
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("expected \"Name: value\", got %q", part)
+		}
+		out[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	}
+	return out, nil
+}
+
+func setPath(m map[string]any, path []string, v any) {
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[p] = next
+		}
+		m = next
+	}
+	last := path[len(path)-1]
+	if sub, ok := v.(map[string]any); ok {
+		if existing, ok := m[last].(map[string]any); ok {
+			overlayMaps(existing, sub)
+			return
+		}
+	}
+	m[last] = v
+}
+
+// overlayMaps merges src into dst: nested maps are merged recursively and
+// every other value, lists included, replaces the one in dst.
+func overlayMaps(dst, src map[string]any) {
+	for k, sv := range src {
+		if s, ok := sv.(map[string]any); ok {
+			if d, ok := dst[k].(map[string]any); ok {
+				overlayMaps(d, s)
+				continue
+			}
+			sv = copyMap(s)
+		}
+		dst[k] = sv
+	}
+}
+
+func copyMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		if sub, ok := v.(map[string]any); ok {
+			v = copyMap(sub)
+		}
+		out[k] = v
+	}
+	return out
+}
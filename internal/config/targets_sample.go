@@ -0,0 +1,232 @@
+package config
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+var validSampling = map[string]bool{"none": true, "top_weight": true, "reservoir": true}
+
+// targetSink collects targets_file entries as they are parsed. Without a cap
+// each entry is decoded and appended to cfg.Targets straight away. With
+// targets_file_max_entries set and a sampling mode, only the raw entries
+// still in the sample are held while the file is read, and they are decoded
+// by finish in file order; entries dropped from the sample are never
+// decoded.
+type targetSink struct {
+	cfg      *Config
+	defaults map[string]any
+	st       *decodeState
+	max      int
+	mode     string
+	unit     string // "line" or "entry", for error messages
+
+	seen      int
+	kept      sampled
+	templates map[string]TargetConfig // see fromTemplate
+}
+
+// sampledRow is a raw entry held in a sample.
+type sampledRow struct {
+	n      int // line or entry number, which also orders the sample
+	row    map[string]any
+	weight float64
+}
+
+func newTargetSink(cfg *Config, defaults map[string]any, st *decodeState) (*targetSink, error) {
+	mode := cfg.TargetsFileSampling
+	if mode == "" {
+		mode = "none"
+	}
+	if !validSampling[mode] {
+		return nil, fmt.Errorf("targets_file_sampling must be one of none|top_weight|reservoir, got %q", mode)
+	}
+	if cfg.TargetsFileMaxEntries < 0 {
+		return nil, fmt.Errorf("targets_file_max_entries must be >= 0")
+	}
+	return &targetSink{
+		cfg:      cfg,
+		defaults: defaults,
+		st:       st,
+		max:      cfg.TargetsFileMaxEntries,
+		mode:     mode,
+		unit:     "entry",
+	}, nil
+}
+
+// add takes the entry at position n of the file.
+func (s *targetSink) add(n int, row map[string]any) error {
+	s.seen++
+	if s.max == 0 || s.mode == "none" {
+		if s.max > 0 && s.seen > s.max {
+			return fmt.Errorf("more than %d entries (targets_file_max_entries); set targets_file_sampling to keep a subset", s.max)
+		}
+		return s.build(n, row)
+	}
+
+	r := sampledRow{n: n, row: row}
+	switch s.mode {
+	case "top_weight":
+		r.weight = s.rowWeight(row)
+		if len(s.kept) < s.max {
+			heap.Push(&s.kept, r)
+		} else if r.weight > s.kept[0].weight {
+			s.kept[0] = r
+			heap.Fix(&s.kept, 0)
+		}
+	case "reservoir":
+		// Algorithm R: the i-th entry replaces a random kept one with
+		// probability max/i.
+		if len(s.kept) < s.max {
+			s.kept = append(s.kept, r)
+		} else if j := rand.Intn(s.seen); j < s.max { //nolint:gosec
+			s.kept[j] = r
+		}
+	}
+	return nil
+}
+
+// finish decodes the sampled entries, if any, in file order.
+func (s *targetSink) finish() error {
+	if len(s.kept) == 0 {
+		return nil
+	}
+	slices.SortFunc(s.kept, func(a, b sampledRow) int { return a.n - b.n })
+	s.cfg.Targets = slices.Grow(s.cfg.Targets, len(s.kept))
+	for i, r := range s.kept {
+		if err := s.build(r.n, r.row); err != nil {
+			return err
+		}
+		s.kept[i].row = nil
+	}
+	if s.seen > len(s.kept) {
+		log.Info().Msgf("targets_file: kept %d of %d entries (targets_file_sampling: %s)", len(s.kept), s.seen, s.mode)
+	}
+	s.kept = nil
+	return nil
+}
+
+func (s *targetSink) build(n int, row map[string]any) error {
+	t, ok := s.fromTemplate(row)
+	if !ok {
+		var err error
+		if t, err = buildTarget(row, s.defaults, s.st); err != nil {
+			return fmt.Errorf("%s %d: %w", s.unit, n, err)
+		}
+	}
+	s.cfg.Targets = append(s.cfg.Targets, t)
+	return nil
+}
+
+// fromTemplate builds the target for a row that sets nothing but url, type,
+// and weight — the common case for long lists — by copying target_defaults
+// decoded once per type, instead of decoding the row. Rows with other
+// fields, or values that need ${VAR} expansion, report false. Copies share
+// the defaults' maps and slices, as expanded targets do.
+func (s *targetSink) fromTemplate(row map[string]any) (TargetConfig, bool) {
+	url, _ := row["url"].(string)
+	typ, _ := row["type"].(string)
+	if url == "" || strings.Contains(url, "$") || len(row) > 3 {
+		return TargetConfig{}, false
+	}
+	weight := 0.0
+	for k, v := range row {
+		switch k {
+		case "url", "type":
+		case "weight":
+			switch w := v.(type) {
+			case float64:
+				weight = w
+			case string:
+				f, err := strconv.ParseFloat(strings.TrimSpace(w), 64)
+				if err != nil || strings.Contains(w, "$") {
+					return TargetConfig{}, false
+				}
+				weight = f
+			default:
+				return TargetConfig{}, false
+			}
+		default:
+			return TargetConfig{}, false
+		}
+	}
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	switch typ {
+	case "http", "browser", "dns", "websocket", "grpc", "sftp":
+	default:
+		return TargetConfig{}, false
+	}
+
+	tmpl, ok := s.templates[typ]
+	if !ok {
+		t, err := buildTarget(map[string]any{"type": typ}, s.defaults, s.st)
+		if err != nil {
+			return TargetConfig{}, false
+		}
+		if s.templates == nil {
+			s.templates = make(map[string]TargetConfig)
+		}
+		s.templates[typ], tmpl = t, t
+	}
+	tmpl.URL = url
+	if _, ok := row["weight"]; ok {
+		tmpl.Weight = weight
+	}
+	return tmpl, true
+}
+
+// rowWeight ranks a raw entry for top_weight sampling: its weight, or
+// target_defaults.weight when it has none. Entries with a share rank above
+// every weighted entry. A weight that does not parse ranks lowest; it is
+// reported if the entry is kept.
+func (s *targetSink) rowWeight(row map[string]any) float64 {
+	if share, ok := row["share"]; ok && share != nil && share != "" {
+		return math.Inf(1)
+	}
+	v, ok := row["weight"]
+	if !ok {
+		v = s.defaults["weight"]
+	}
+	if v == nil {
+		return 1
+	}
+	switch w := v.(type) {
+	case float64:
+		return w
+	case int:
+		return float64(w)
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(w), 64)
+		if err == nil {
+			return f
+		}
+	}
+	return math.Inf(-1)
+}
+
+// sampled is a min-heap of sampledRow by weight; among equal weights the
+// latest entry is on top, so that earlier entries win ties.
+type sampled []sampledRow
+
+func (h sampled) Len() int { return len(h) }
+func (h sampled) Less(i, j int) bool {
+	if h[i].weight != h[j].weight {
+		return h[i].weight < h[j].weight
+	}
+	return h[i].n > h[j].n
+}
+func (h sampled) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *sampled) Push(x any)   { *h = append(*h, x.(sampledRow)) }
+func (h *sampled) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Percent is a percentage such as a target's share. In YAML it may be
+// written as a number (12.5) or with a percent sign ("12.5%").
+type Percent float64
+
+var percentType = reflect.TypeOf(Percent(0))
+
+// percentHook parses "12.5%" strings into Percent fields.
+func percentHook(f, t reflect.Type, data any) (any, error) {
+	if t != percentType || f.Kind() != reflect.String {
+		return data, nil
+	}
+	s := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(data.(string)), "%"))
+	if s == "" {
+		return Percent(0), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid percentage %q", data)
+	}
+	return Percent(v), nil
+}
+
+// EffectiveWeights returns the selection weight of each target, on a scale
+// where all weights add up to 100 whenever any target has a share.
+//
+// Targets with a share get exactly that share. The remaining percentage is
+// divided among the other targets in proportion to their weights, so
+// fractional weights and shares can be mixed freely. Without any shares the
+// weights are returned unchanged.
+func EffectiveWeights(targets []TargetConfig) ([]float64, error) {
+	var shares, weights float64
+	for _, t := range targets {
+		if t.Share > 0 {
+			shares += float64(t.Share)
+		} else if t.Weight > 0 {
+			weights += t.Weight
+		}
+	}
+
+	out := make([]float64, len(targets))
+	if shares == 0 {
+		for i, t := range targets {
+			out[i] = t.Weight
+		}
+		return out, nil
+	}
+	// Allow for rounding in shares such as 33.33% × 3.
+	const epsilon = 1e-9
+	if shares > 100+epsilon {
+		return nil, fmt.Errorf("target shares add up to %g%%, more than 100%%", shares)
+	}
+	if weights > 0 && shares >= 100-epsilon {
+		return nil, errors.New("target shares add up to 100%, leaving nothing for targets without a share")
+	}
+	for i, t := range targets {
+		switch {
+		case t.Share > 0:
+			out[i] = float64(t.Share)
+		case weights > 0 && t.Weight > 0:
+			out[i] = t.Weight / weights * (100 - shares)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,122 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Client calls the control API of a running sendit over its Unix socket.
+type Client struct {
+	socket string
+	http   *http.Client
+}
+
+// NewClient returns a client for the control socket at path.
+func NewClient(path string) *Client {
+	var d net.Dialer
+	return &Client{
+		socket: path,
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return d.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Targets lists the daemon's current targets with their live counters.
+func (c *Client) Targets(ctx context.Context) ([]TargetInfo, error) {
+	var list TargetList
+	err := c.do(ctx, http.MethodGet, "/targets", nil, &list)
+	return list.Targets, err
+}
+
+// AddTarget adds a target with the given fields (the same keys as a
+// targets entry) and returns the updated list.
+func (c *Client) AddTarget(ctx context.Context, fields map[string]any) ([]TargetInfo, error) {
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var list TargetList
+	err = c.do(ctx, http.MethodPost, "/targets", body, &list)
+	return list.Targets, err
+}
+
+// RemoveTarget removes every target with rawURL and returns the updated list.
+func (c *Client) RemoveTarget(ctx context.Context, rawURL string) ([]TargetInfo, error) {
+	var list TargetList
+	err := c.do(ctx, http.MethodDelete, "/targets?url="+url.QueryEscape(rawURL), nil, &list)
+	return list.Targets, err
+}
+
+// Pause stops the daemon from dispatching new requests.
+func (c *Client) Pause(ctx context.Context) (PauseState, error) {
+	var st PauseState
+	err := c.do(ctx, http.MethodPost, "/pause", nil, &st)
+	return st, err
+}
+
+// Resume lets a paused daemon dispatch again.
+func (c *Client) Resume(ctx context.Context) (PauseState, error) {
+	var st PauseState
+	err := c.do(ctx, http.MethodPost, "/resume", nil, &st)
+	return st, err
+}
+
+// Status returns the daemon's runtime summary.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var st Status
+	err := c.do(ctx, http.MethodGet, "/status", nil, &st)
+	return st, err
+}
+
+// Dump makes the daemon log a snapshot of its internal state and returns it.
+func (c *Client) Dump(ctx context.Context) (Dump, error) {
+	var d Dump
+	err := c.do(ctx, http.MethodPost, "/dump", nil, &d)
+	return d, err
+}
+
+// do sends the request and decodes a 200 response into out.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, out any) error {
+	// The host is ignored by the dialer; it only has to form a valid URL.
+	req, err := http.NewRequestWithContext(ctx, method, "http://sendit"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach sendit on %s (is 'sendit start' running with daemon.control_socket set?): %w", c.socket, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e errorBody
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return errors.New(e.Error)
+		}
+		return fmt.Errorf("control socket: unexpected status %s", resp.Status)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("control socket: decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,521 @@
+// Package control serves a small JSON API on a Unix socket so that the CLI
+// can inspect and change a running `sendit start` without editing files and
+// sending SIGHUP. Client is the matching caller used by `sendit targets`,
+// `sendit pause`, `sendit resume`, `sendit status --full`, and `sendit dump`.
+package control
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/engine"
+	"github.com/lewta/sendit/internal/ratelimit"
+	"github.com/rs/zerolog/log"
+)
+
+// maxBodyBytes caps request bodies; a target definition is a few hundred
+// bytes at most.
+const maxBodyBytes = 64 * 1024
+
+// TargetInfo is one row of the target list: the target as currently
+// configured plus its live counters.
+type TargetInfo struct {
+	URL        string     `json:"url"`
+	Type       string     `json:"type"`
+	Weight     float64    `json:"weight"`
+	SharePct   float64    `json:"share_pct"` // effective share of picks
+	Runtime    bool       `json:"runtime"`   // added through the control socket
+	Requests   int64      `json:"requests"`
+	Errors     int64      `json:"errors"`
+	Slow       int64      `json:"slow"`
+	AvgMs      float64    `json:"avg_ms"`
+	LastStatus int        `json:"last_status,omitempty"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+}
+
+// TargetList is the response body of every /targets endpoint.
+type TargetList struct {
+	Targets []TargetInfo `json:"targets"`
+}
+
+// PauseState is the response body of /pause and /resume.
+type PauseState struct {
+	Paused bool `json:"paused"`
+	// Changed is false when the engine already was in the requested state.
+	Changed bool `json:"changed"`
+	// Since is when the current pause began or, after a resume, when the
+	// pause that just ended began.
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// Status is the response body of /status: who is running, with what
+// config, and how it is doing.
+type Status struct {
+	PID     int       `json:"pid"`
+	RunID   string    `json:"run_id"`
+	Started time.Time `json:"started"`
+	UptimeS float64   `json:"uptime_s"`
+	// Config is the path or URL passed to --config; ConfigHash is a short
+	// SHA-256 of the effective config (after kv and runtime target
+	// changes), so two daemons or two points in time can be compared.
+	Config      string     `json:"config,omitempty"`
+	ConfigHash  string     `json:"config_hash"`
+	Targets     int        `json:"targets"`
+	Mode        string     `json:"mode"`
+	Paused      bool       `json:"paused"`
+	PausedSince *time.Time `json:"paused_since,omitempty"`
+	// Blackout names the blackout period holding dispatch, if any.
+	Blackout      string     `json:"blackout,omitempty"`
+	BlackoutUntil *time.Time `json:"blackout_until,omitempty"`
+	// InWindow is set only in scheduled mode.
+	InWindow    *bool   `json:"in_window,omitempty"`
+	WindowGroup string  `json:"window_group,omitempty"`
+	ActiveRPM   float64 `json:"active_rpm,omitempty"`
+	RPS         float64 `json:"rps"` // completions per second over the last minute
+	Requests    int64   `json:"requests"`
+	Errors      int64   `json:"errors"`
+	ErrorPct    float64 `json:"error_pct"`
+	// ByType holds per-driver totals, sorted by type.
+	ByType    []TypeTotals `json:"by_type"`
+	CPUPct    float64      `json:"cpu_pct"`
+	MemUsedMB uint64       `json:"mem_used_mb"`
+	// Backoff lists the domains backing off after transient errors, and
+	// Waits the time each domain's requests have spent held, longest first.
+	Backoff []DomainBackoff `json:"backoff"`
+	Waits   []DomainWaits   `json:"waits"`
+}
+
+// DomainBackoff is the backoff state of one domain.
+type DomainBackoff struct {
+	Domain      string    `json:"domain"`
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	NextAllowed time.Time `json:"next_allowed"`
+	// RemainingS is how long until NextAllowed, or 0 once it has passed.
+	RemainingS float64 `json:"remaining_s"`
+	// Cooldown is set while the domain's tasks are skipped until
+	// NextAllowed, having exhausted MaxAttempts.
+	Cooldown bool `json:"cooldown"`
+}
+
+// DomainWaits are the seconds requests to one domain have spent held by
+// the rate limiter and by backoff since the engine started.
+type DomainWaits struct {
+	Domain     string  `json:"domain"`
+	RateLimitS float64 `json:"rate_limit_s"`
+	BackoffS   float64 `json:"backoff_s"`
+}
+
+// Dump is the response body of /dump: the engine snapshot that was also
+// written to the daemon's log.
+type Dump struct {
+	Time              time.Time `json:"time"`
+	Goroutines        int       `json:"goroutines"`
+	Workers           int       `json:"workers"` // worker slots in use
+	MaxWorkers        int       `json:"max_workers"`
+	BrowserWorkers    int       `json:"browser_workers"`
+	MaxBrowserWorkers int       `json:"max_browser_workers"`
+	Status            Status    `json:"status"`
+	// Domains are ordered by total wait, longest first; TopErrors holds
+	// the targets with the most errors.
+	Domains   []DomainDump   `json:"domains"`
+	TopErrors []TargetErrors `json:"top_errors"`
+}
+
+// DomainDump is the rate-limit and backoff state of one domain.
+type DomainDump struct {
+	Domain     string         `json:"domain"`
+	LimitRPS   float64        `json:"limit_rps"` // 0 while a server-advertised budget is exhausted
+	RateLimitS float64        `json:"rate_limit_s"`
+	BackoffS   float64        `json:"backoff_s"`
+	Backoff    *DomainBackoff `json:"backoff,omitempty"`
+}
+
+// TargetErrors are the counters of one target with errors.
+type TargetErrors struct {
+	URL        string  `json:"url"`
+	Requests   int64   `json:"requests"`
+	Errors     int64   `json:"errors"`
+	LastStatus int     `json:"last_status,omitempty"`
+	AvgMs      float64 `json:"avg_ms"`
+}
+
+// TypeTotals are the request and error counts of one driver type.
+type TypeTotals struct {
+	Type     string  `json:"type"`
+	Requests int64   `json:"requests"`
+	Errors   int64   `json:"errors"`
+	ErrorPct float64 `json:"error_pct"`
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// Server answers control requests for one engine.
+type Server struct {
+	eng       *engine.Engine
+	overrides *config.TargetOverrides
+	apply     func() error
+
+	// ConfigPath is reported by /status; set it before serving.
+	ConfigPath string
+
+	mu sync.Mutex // serialises changes so a rollback never undoes another
+}
+
+// NewServer returns a server for eng. Target changes are recorded in
+// overrides and then made live by calling apply, which must rebuild the
+// config with overrides applied and reload the engine; when apply fails the
+// change is rolled back.
+func NewServer(eng *engine.Engine, overrides *config.TargetOverrides, apply func() error) *Server {
+	return &Server{eng: eng, overrides: overrides, apply: apply}
+}
+
+// Handler returns the HTTP handler for the control API:
+//
+//	GET    /targets          list targets with live counters
+//	POST   /targets          add the target in the body (YAML or JSON)
+//	DELETE /targets?url=<u>  remove every target with URL u
+//	POST   /pause            stop dispatching new requests
+//	POST   /resume           dispatch again after /pause
+//	GET    /status           uptime, config, rate, totals, pause state,
+//	                         backoff, and per-domain wait totals
+//	POST   /dump             log a snapshot of pool, per-domain, and
+//	                         per-target state, and return it
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /targets", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.list())
+	})
+	mux.HandleFunc("POST /targets", s.addTarget)
+	mux.HandleFunc("DELETE /targets", s.removeTarget)
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, _ *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		changed := s.eng.Pause()
+		_, since := s.eng.Paused()
+		writeJSON(w, http.StatusOK, PauseState{Paused: true, Changed: changed, Since: timePtr(since)})
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, _ *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, since := s.eng.Paused()
+		changed := s.eng.Resume()
+		writeJSON(w, http.StatusOK, PauseState{Paused: false, Changed: changed, Since: timePtr(since)})
+	})
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		st, err := s.status()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+	})
+	mux.HandleFunc("POST /dump", func(w http.ResponseWriter, _ *http.Request) {
+		d, err := s.dump()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, d)
+	})
+	return mux
+}
+
+// Serve listens on the Unix socket at path until ctx is cancelled. A stale
+// socket left behind by a crashed process is replaced, but one that still
+// accepts connections is reported as in use. The socket is created with
+// mode 0600 so only the owning user can control the daemon.
+func (s *Server) Serve(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			return fmt.Errorf("control socket %s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing stale control socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return fmt.Errorf("control socket: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() { //nolint:gosec // G118: intentional — parent ctx is done, shutdown needs its own deadline
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("control server shutdown error")
+		}
+	}()
+
+	log.Info().Str("socket", path).Msg("control socket listening")
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("control socket: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) list() TargetList {
+	targets := s.eng.Config().Targets
+	stats := s.eng.TargetStats()
+	weights, _ := config.EffectiveWeights(targets)
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+
+	out := TargetList{Targets: make([]TargetInfo, 0, len(targets))}
+	for i, t := range targets {
+		info := TargetInfo{
+			URL:     t.URL,
+			Type:    t.Type,
+			Weight:  t.Weight,
+			Runtime: s.overrides.IsAdded(t.URL),
+		}
+		if total > 0 && i < len(weights) {
+			info.SharePct = weights[i] / total * 100
+		}
+		if st, ok := stats[t.URL]; ok {
+			info.Requests = st.Requests
+			info.Errors = st.Errors
+			info.Slow = st.Slow
+			info.AvgMs = float64(st.AvgLatency().Microseconds()) / 1000
+			info.LastStatus = st.LastStatus
+			info.LastSeen = timePtr(st.LastSeen)
+		}
+		out.Targets = append(out.Targets, info)
+	}
+	return out
+}
+
+func (s *Server) status() (Status, error) {
+	cfg := s.eng.Config()
+	data, err := config.Marshal(cfg, true)
+	if err != nil {
+		return Status{}, fmt.Errorf("hashing config: %w", err)
+	}
+	sum := sha256.Sum256(data)
+
+	es := s.eng.Status()
+	st := Status{
+		PID:         os.Getpid(),
+		RunID:       es.RunID,
+		Started:     es.Started.UTC(),
+		UptimeS:     time.Since(es.Started).Seconds(),
+		Config:      s.ConfigPath,
+		ConfigHash:  hex.EncodeToString(sum[:6]),
+		Targets:     len(cfg.Targets),
+		Mode:        es.Mode,
+		Paused:      es.Paused,
+		PausedSince: timePtr(es.PausedSince),
+		ActiveRPM:   es.ActiveRPM,
+		RPS:         es.RPS,
+		CPUPct:      es.CPUPct,
+		MemUsedMB:   es.MemUsedMB,
+		ByType:      []TypeTotals{},
+		Backoff:     []DomainBackoff{},
+		Waits:       []DomainWaits{},
+	}
+	st.Blackout, st.BlackoutUntil = es.Blackout, timePtr(es.BlackoutUntil)
+	if es.Mode == "scheduled" {
+		st.InWindow = &es.InWindow
+		st.WindowGroup = es.WindowGroup
+	}
+	types := s.eng.TypeStats()
+	for _, typ := range slices.Sorted(maps.Keys(types)) {
+		ts := types[typ]
+		st.Requests += ts.Requests
+		st.Errors += ts.Errors
+		st.ByType = append(st.ByType, TypeTotals{
+			Type:     typ,
+			Requests: ts.Requests,
+			Errors:   ts.Errors,
+			ErrorPct: percent(ts.Errors, ts.Requests),
+		})
+	}
+	st.ErrorPct = percent(st.Errors, st.Requests)
+
+	now := time.Now()
+	for _, b := range s.eng.Backoff() {
+		st.Backoff = append(st.Backoff, domainBackoff(b, now))
+	}
+	for domain, w := range s.eng.WaitStats() {
+		st.Waits = append(st.Waits, DomainWaits{
+			Domain:     domain,
+			RateLimitS: w.RateLimit.Seconds(),
+			BackoffS:   w.Backoff.Seconds(),
+		})
+	}
+	slices.SortFunc(st.Waits, func(a, b DomainWaits) int {
+		if c := cmp.Compare(b.RateLimitS+b.BackoffS, a.RateLimitS+a.BackoffS); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Domain, b.Domain)
+	})
+	return st, nil
+}
+
+// dump logs an engine snapshot and converts it to its JSON form.
+func (s *Server) dump() (Dump, error) {
+	st, err := s.status()
+	if err != nil {
+		return Dump{}, err
+	}
+	ed := s.eng.LogDump()
+	d := Dump{
+		Time:              ed.Time.UTC(),
+		Goroutines:        ed.Goroutines,
+		Workers:           ed.Workers,
+		MaxWorkers:        ed.MaxWorkers,
+		BrowserWorkers:    ed.BrowserWorkers,
+		MaxBrowserWorkers: ed.MaxBrowserWorkers,
+		Status:            st,
+		Domains:           []DomainDump{},
+		TopErrors:         []TargetErrors{},
+	}
+	for _, dd := range ed.Domains {
+		row := DomainDump{
+			Domain:     dd.Domain,
+			LimitRPS:   dd.LimitRPS,
+			RateLimitS: dd.Waits.RateLimit.Seconds(),
+			BackoffS:   dd.Waits.Backoff.Seconds(),
+		}
+		if dd.Backoff != nil {
+			b := domainBackoff(*dd.Backoff, ed.Time)
+			row.Backoff = &b
+		}
+		d.Domains = append(d.Domains, row)
+	}
+	for _, t := range ed.TopErrors {
+		d.TopErrors = append(d.TopErrors, TargetErrors{
+			URL:        t.URL,
+			Requests:   t.Requests,
+			Errors:     t.Errors,
+			LastStatus: t.LastStatus,
+			AvgMs:      float64(t.AvgLatency().Microseconds()) / 1000,
+		})
+	}
+	return d, nil
+}
+
+func domainBackoff(b ratelimit.BackoffState, now time.Time) DomainBackoff {
+	return DomainBackoff{
+		Domain:      b.Domain,
+		Attempts:    b.Attempts,
+		MaxAttempts: b.MaxAttempts,
+		NextAllowed: b.NextAllowed.UTC(),
+		RemainingS:  max(b.NextAllowed.Sub(now).Seconds(), 0),
+		Cooldown:    b.Cooldown,
+	}
+}
+
+// percent returns n as a percentage of total, or 0 when total is 0.
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
+
+func (s *Server) addTarget(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	t, err := config.ParseTarget(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decoding target: %w", err))
+		return
+	}
+	if t.URL == "" {
+		writeError(w, http.StatusBadRequest, errors.New("target url is required"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hasTarget(t.URL) {
+		writeError(w, http.StatusConflict, fmt.Errorf("%s is already a target", t.URL))
+		return
+	}
+	s.change(w, func() { s.overrides.Add(t) })
+}
+
+func (s *Server) removeTarget(w http.ResponseWriter, r *http.Request) {
+	url := r.URL.Query().Get("url")
+	if url == "" {
+		writeError(w, http.StatusBadRequest, errors.New("url query parameter is required"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.hasTarget(url) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s is not a target", url))
+		return
+	}
+	s.change(w, func() { s.overrides.Remove(url) })
+}
+
+// change applies fn to the overrides and reloads, restoring the previous
+// overrides when the reload fails. The caller holds s.mu.
+func (s *Server) change(w http.ResponseWriter, fn func()) {
+	saved := s.overrides.Snapshot()
+	fn()
+	if err := s.apply(); err != nil {
+		s.overrides.Restore(saved)
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.list())
+}
+
+func (s *Server) hasTarget(url string) bool {
+	return slices.ContainsFunc(s.eng.Config().Targets, func(t config.TargetConfig) bool { return t.URL == url })
+}
+
+// timePtr returns &t in UTC, or nil for the zero time.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
@@ -0,0 +1,193 @@
+package control
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/engine"
+	"github.com/lewta/sendit/internal/metrics"
+)
+
+func newTestServer(t *testing.T) (*Client, *engine.Engine) {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := `
+pacing:
+  mode: rate_limited
+  requests_per_minute: 60
+limits:
+  cpu_threshold_pct: 100
+  memory_threshold_mb: 1048576
+targets:
+  - url: https://a.example.com
+    type: http
+    weight: 1
+`
+	if err := os.WriteFile(cfgPath, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	base, err := config.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	eng, err := engine.New(base, metrics.Noop())
+	if err != nil {
+		t.Fatalf("engine.New: %v", err)
+	}
+	var overrides config.TargetOverrides
+	srv := NewServer(eng, &overrides, func() error {
+		next, err := overrides.Apply(base)
+		if err != nil {
+			return err
+		}
+		return eng.Reload(next)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	path := filepath.Join(t.TempDir(), "sendit.sock")
+	go func() { _ = srv.Serve(ctx, path) }()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return NewClient(path), eng
+}
+
+func TestServer_AddListRemove(t *testing.T) {
+	c, eng := newTestServer(t)
+	ctx := context.Background()
+
+	targets, err := c.AddTarget(ctx, map[string]any{"url": "b.example.com", "type": "dns", "weight": 3})
+	if err != nil {
+		t.Fatalf("AddTarget: %v", err)
+	}
+	if len(targets) != 2 || !targets[1].Runtime || targets[1].SharePct != 75 {
+		t.Fatalf("after add: %+v", targets)
+	}
+	if n := len(eng.Config().Targets); n != 2 {
+		t.Errorf("engine has %d targets, want 2", n)
+	}
+
+	if _, err := c.AddTarget(ctx, map[string]any{"url": "b.example.com", "type": "dns"}); err == nil || !strings.Contains(err.Error(), "already") {
+		t.Errorf("duplicate add: want already-a-target error, got %v", err)
+	}
+	if _, err := c.AddTarget(ctx, map[string]any{"url": "c.example.com", "type": "carrier-pigeon"}); err == nil {
+		t.Error("invalid add: want validation error")
+	}
+
+	targets, err = c.RemoveTarget(ctx, "https://a.example.com")
+	if err != nil {
+		t.Fatalf("RemoveTarget: %v", err)
+	}
+	if len(targets) != 1 || targets[0].URL != "b.example.com" {
+		t.Fatalf("after remove: %+v", targets)
+	}
+	if _, err := c.RemoveTarget(ctx, "b.example.com"); err == nil {
+		t.Error("removing the last target: want error")
+	}
+	if _, err := c.RemoveTarget(ctx, "https://nope.example.com"); err == nil || !strings.Contains(err.Error(), "not a target") {
+		t.Errorf("unknown remove: want not-a-target error, got %v", err)
+	}
+	if targets, err = c.Targets(ctx); err != nil || len(targets) != 1 {
+		t.Errorf("Targets after rejected changes: %+v, %v", targets, err)
+	}
+}
+
+func TestServe_RefusesSocketInUse(t *testing.T) {
+	c, _ := newTestServer(t)
+	srv := NewServer(nil, nil, nil)
+	if err := srv.Serve(context.Background(), c.socket); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("want in-use error, got %v", err)
+	}
+}
+
+func TestClient_NotRunning(t *testing.T) {
+	c := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	if _, err := c.Targets(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot reach") {
+		t.Errorf("want cannot-reach error, got %v", err)
+	}
+}
+
+func TestServer_PauseResume(t *testing.T) {
+	c, eng := newTestServer(t)
+	ctx := context.Background()
+
+	st, err := c.Pause(ctx)
+	if err != nil || !st.Paused || !st.Changed || st.Since == nil {
+		t.Fatalf("Pause = %+v, %v", st, err)
+	}
+	if paused, _ := eng.Paused(); !paused {
+		t.Error("engine not paused")
+	}
+	if st, err = c.Pause(ctx); err != nil || st.Changed {
+		t.Errorf("second Pause = %+v, %v; want unchanged", st, err)
+	}
+
+	st, err = c.Resume(ctx)
+	if err != nil || st.Paused || !st.Changed || st.Since == nil {
+		t.Fatalf("Resume = %+v, %v", st, err)
+	}
+	if st, err = c.Resume(ctx); err != nil || st.Changed {
+		t.Errorf("second Resume = %+v, %v; want unchanged", st, err)
+	}
+}
+
+func TestServer_Dump(t *testing.T) {
+	c, _ := newTestServer(t)
+
+	d, err := c.Dump(context.Background())
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	if d.Goroutines <= 0 || d.Workers != 0 || d.MaxWorkers == 0 || d.Time.IsZero() {
+		t.Errorf("dump = %+v", d)
+	}
+	if d.Status.Mode != "rate_limited" || d.Status.PID != os.Getpid() {
+		t.Errorf("dump status = %+v", d.Status)
+	}
+	if d.Domains == nil || d.TopErrors == nil {
+		t.Errorf("domains and top errors = %v, %v; want empty lists", d.Domains, d.TopErrors)
+	}
+}
+
+func TestServer_Status(t *testing.T) {
+	c, eng := newTestServer(t)
+	ctx := context.Background()
+
+	st, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if st.PID != os.Getpid() || st.Mode != "rate_limited" || st.Targets != 1 || st.InWindow != nil {
+		t.Errorf("status = %+v", st)
+	}
+	if len(st.ConfigHash) != 12 || st.Started.IsZero() || st.Paused {
+		t.Errorf("status = %+v", st)
+	}
+	if st.Backoff == nil || len(st.Backoff) != 0 || st.Waits == nil {
+		t.Errorf("backoff and waits = %v, %v; want empty lists", st.Backoff, st.Waits)
+	}
+
+	if _, err := c.AddTarget(ctx, map[string]any{"url": "b.example.com", "type": "dns"}); err != nil {
+		t.Fatalf("AddTarget: %v", err)
+	}
+	eng.Pause()
+	after, err := c.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if after.ConfigHash == st.ConfigHash {
+		t.Error("config hash did not change after adding a target")
+	}
+	if !after.Paused || after.PausedSince == nil || after.Targets != 2 {
+		t.Errorf("after add and pause: %+v", after)
+	}
+}
@@ -0,0 +1,37 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// defaultAbortAfterMs bounds the delay before an abort_probability abort
+// when abort_after_ms is unset.
+const defaultAbortAfterMs = 1000
+
+// errAborted is the cause of a request context cancelled to abort it.
+var errAborted = errors.New("aborted by client")
+
+// withAbort picks, with probability p, to abort a request: the returned
+// context is then cancelled after a random delay of up to afterMs, like a
+// user navigating away from a page still loading. aborted reports whether
+// the cancel happened; a request that finished first was not aborted. stop
+// must be called once the request is done.
+func withAbort(ctx context.Context, p float64, afterMs int) (_ context.Context, aborted func() bool, stop func()) {
+	if p <= 0 || rand.Float64() >= p { //nolint:gosec
+		return ctx, func() bool { return false }, func() {}
+	}
+	if afterMs <= 0 {
+		afterMs = defaultAbortAfterMs
+	}
+	delay := time.Duration(rand.Int63n(int64(afterMs) * int64(time.Millisecond))) //nolint:gosec
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := time.AfterFunc(delay, func() { cancel(errAborted) })
+	aborted = func() bool { return errors.Is(context.Cause(ctx), errAborted) }
+	return ctx, aborted, func() {
+		timer.Stop()
+		cancel(nil)
+	}
+}
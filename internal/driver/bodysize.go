@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+// bodyFill is the text random request bodies are cut from: printable
+// ASCII, so that a body passes as form or text content, drawn once so that
+// large bodies cost no more than copying.
+var bodyFill = func() []byte {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, 64<<10)
+	for i := range b {
+		b[i] = alphabet[rand.Intn(len(alphabet))] //nolint:gosec
+	}
+	return b
+}()
+
+// drawBodySize picks the size of a random request body from bs, which
+// must have max set. uniform spreads sizes evenly over [min, max];
+// lognormal centres them on the geometric mean of min and max, with about
+// 95% of bodies within the range and the rest clamped to it.
+func drawBodySize(bs config.BodySizeConfig) int64 {
+	lo, hi := float64(bs.Min), float64(bs.Max)
+	var n float64
+	switch bs.Distribution {
+	case "lognormal":
+		median := math.Sqrt(lo * hi)
+		sigma := math.Log(hi/lo) / 4
+		n = math.Min(math.Max(median*math.Exp(sigma*rand.NormFloat64()), lo), hi) //nolint:gosec
+	default: // uniform
+		n = lo + rand.Float64()*(hi-lo+1) //nolint:gosec
+	}
+	return min(int64(n), int64(bs.Max))
+}
+
+// randomBody reads n bytes of bodyFill, starting at a random offset so
+// that bodies of the same size differ.
+type randomBody struct {
+	off int
+	n   int64
+}
+
+func newRandomBody(n int64) *randomBody {
+	return &randomBody{off: rand.Intn(len(bodyFill)), n: n} //nolint:gosec
+}
+
+func (b *randomBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n := copy(p, bodyFill[b.off:])
+	b.off = (b.off + n) % len(bodyFill)
+	b.n -= int64(n)
+	return n, nil
+}
+
+// withBodySize records the size of a generated request body in meta.
+func withBodySize(meta map[string]string, n int64) map[string]string {
+	if meta == nil {
+		meta = make(map[string]string, 1)
+	}
+	meta["request_body_bytes"] = strconv.FormatInt(n, 10)
+	return meta
+}
@@ -3,19 +3,72 @@ package driver
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
 	"github.com/chromedp/chromedp"
+	"github.com/lewta/sendit/internal/config"
 	"github.com/lewta/sendit/internal/task"
 )
 
+// BrowserDriverOptions configures optional BrowserDriver behaviour.
+type BrowserDriverOptions struct {
+	// Settings choose the Chrome binary and add command-line switches.
+	Settings config.BrowserDriverConfig
+}
+
 // BrowserDriver executes tasks using a headless Chrome browser via chromedp.
 // Each Execute call spawns an isolated browser instance to avoid memory leaks.
-type BrowserDriver struct{}
+type BrowserDriver struct {
+	allocOpts []chromedp.ExecAllocatorOption
+}
 
 // NewBrowserDriver creates a BrowserDriver.
 func NewBrowserDriver() *BrowserDriver {
-	return &BrowserDriver{}
+	return NewBrowserDriverWithOptions(BrowserDriverOptions{})
+}
+
+// NewBrowserDriverWithOptions creates a BrowserDriver configured by opts.
+func NewBrowserDriverWithOptions(opts BrowserDriverOptions) *BrowserDriver {
+	allocOpts := append(slices.Clone(chromedp.DefaultExecAllocatorOptions[:]),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("no-sandbox", false), // keep sandbox on
+	)
+	if p := opts.Settings.ExecPath; p != "" {
+		allocOpts = append(allocOpts, chromedp.ExecPath(p))
+	}
+	for name, v := range opts.Settings.Flags {
+		if _, ok := v.(bool); !ok {
+			v = fmt.Sprint(v) // chromedp takes a switch's value as a string
+		}
+		allocOpts = append(allocOpts, chromedp.Flag(name, v))
+	}
+	return &BrowserDriver{allocOpts: allocOpts}
+}
+
+// allocatorOptions returns the driver's Chrome launch options with the
+// chrome_path and extra_flags of cfg applied over them.
+func (d *BrowserDriver) allocatorOptions(cfg config.BrowserConfig) []chromedp.ExecAllocatorOption {
+	if cfg.ChromePath == "" && len(cfg.ExtraFlags) == 0 {
+		return d.allocOpts
+	}
+	opts := slices.Clone(d.allocOpts)
+	if cfg.ChromePath != "" {
+		opts = append(opts, chromedp.ExecPath(cfg.ChromePath))
+	}
+	for _, f := range cfg.ExtraFlags {
+		// "--name=value" passes value; a bare "--name" turns the switch on.
+		name, value, ok := strings.Cut(strings.TrimPrefix(f, "--"), "=")
+		if ok {
+			opts = append(opts, chromedp.Flag(name, value))
+		} else {
+			opts = append(opts, chromedp.Flag(name, true))
+		}
+	}
+	return opts
 }
 
 // Execute navigates to t.URL with a headless Chrome instance.
@@ -28,26 +81,23 @@ func (d *BrowserDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	}
 
 	// Isolated allocator per task — prevents memory accumulation.
-	allocOpts := append(
-		chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("no-sandbox", false), // keep sandbox on
-	)
-
-	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, allocOpts...)
+	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, d.allocatorOptions(cfg)...)
 	defer allocCancel()
 
 	taskCtx, taskCancel := chromedp.NewContext(allocCtx)
 	defer taskCancel()
 
+	var pe pageErrors
+	chromedp.ListenTarget(taskCtx, pe.listen)
+
 	timeoutCtx, timeoutCancel := context.WithTimeout(taskCtx, time.Duration(timeoutS)*time.Second)
 	defer timeoutCancel()
 
 	start := time.Now()
 
-	actions := []chromedp.Action{
-		chromedp.Navigate(t.URL),
-	}
+	actions := emulationActions(cfg.Locale, cfg.Timezone)
+	actions = append(actions, throttleActions(cfg)...)
+	actions = append(actions, chromedp.Navigate(t.URL))
 
 	if cfg.WaitForSelector != "" {
 		actions = append(actions, chromedp.WaitVisible(cfg.WaitForSelector, chromedp.ByQuery))
@@ -61,16 +111,107 @@ func (d *BrowserDriver) Execute(ctx context.Context, t task.Task) task.Result {
 		)
 	}
 
-	err := chromedp.Run(timeoutCtx, actions...)
+	// An aborted page load ends with the browser closed mid-navigation.
+	runCtx, aborted, stopAbort := withAbort(timeoutCtx, cfg.AbortProbability, cfg.AbortAfterMs)
+	defer stopAbort()
+
+	err := chromedp.Run(runCtx, actions...)
 	elapsed := time.Since(start)
 
+	consoleErrors, failedRequests, consoleError := pe.counts()
+	if err != nil && aborted() {
+		return task.Result{
+			Task:           t,
+			Duration:       elapsed,
+			ConsoleErrors:  consoleErrors,
+			FailedRequests: failedRequests,
+			ConsoleError:   consoleError,
+			Aborted:        true,
+		}
+	}
 	if err != nil {
-		return task.Result{Task: t, Duration: elapsed, Error: fmt.Errorf("browser: %w", err)}
+		return task.Result{
+			Task:           t,
+			Duration:       elapsed,
+			Error:          fmt.Errorf("browser: %w", err),
+			ConsoleErrors:  consoleErrors,
+			FailedRequests: failedRequests,
+			ConsoleError:   consoleError,
+		}
 	}
 
 	return task.Result{
-		Task:       t,
-		StatusCode: 200,
-		Duration:   elapsed,
+		Task:           t,
+		StatusCode:     200,
+		Duration:       elapsed,
+		ConsoleErrors:  consoleErrors,
+		FailedRequests: failedRequests,
+		ConsoleError:   consoleError,
+	}
+}
+
+// emulationActions returns the actions that make the page see locale and
+// timezone as the browser's own, for those that are set.
+func emulationActions(locale, timezone string) []chromedp.Action {
+	var actions []chromedp.Action
+	if locale != "" {
+		actions = append(actions,
+			// Emulation wants an ICU locale ("de_DE"); Accept-Language a tag.
+			emulation.SetLocaleOverride().WithLocale(strings.ReplaceAll(locale, "-", "_")),
+			network.SetExtraHTTPHeaders(network.Headers{"Accept-Language": locale}),
+		)
+	}
+	if timezone != "" {
+		actions = append(actions, emulation.SetTimezoneOverride(timezone))
+	}
+	return actions
+}
+
+// networkProfiles are the browser.network_profile presets, after the
+// WebPageTest connectivity profiles of the same names.
+var networkProfiles = map[string]struct {
+	conditions config.NetworkConditionsConfig
+	connType   network.ConnectionType
+}{
+	"3g":    {config.NetworkConditionsConfig{LatencyMs: 300, DownloadKbps: 1600, UploadKbps: 768}, network.ConnectionTypeCellular3g},
+	"4g":    {config.NetworkConditionsConfig{LatencyMs: 170, DownloadKbps: 9000, UploadKbps: 9000}, network.ConnectionTypeCellular4g},
+	"cable": {config.NetworkConditionsConfig{LatencyMs: 28, DownloadKbps: 5000, UploadKbps: 1000}, network.ConnectionTypeEthernet},
+}
+
+// throttleActions returns the actions that throttle the page's traffic to
+// cfg.NetworkProfile, or none when it is unset.
+func throttleActions(cfg config.BrowserConfig) []chromedp.Action {
+	var (
+		c        config.NetworkConditionsConfig
+		connType network.ConnectionType
+	)
+	switch cfg.NetworkProfile {
+	case "":
+		return nil
+	case "custom":
+		c, connType = cfg.NetworkCustom, network.ConnectionTypeOther
+	default:
+		p, ok := networkProfiles[cfg.NetworkProfile]
+		if !ok {
+			return nil
+		}
+		c, connType = p.conditions, p.connType
+	}
+	latency := float64(c.LatencyMs)
+	down := float64(c.DownloadKbps) * 1000 / 8 // CDP wants bytes per second
+	up := float64(c.UploadKbps) * 1000 / 8
+	rule := network.EmulateNetworkConditionsByRule([]*network.Conditions{{
+		Latency:            latency,
+		DownloadThroughput: down,
+		UploadThroughput:   up,
+		ConnectionType:     connType,
+	}})
+	return []chromedp.Action{
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			_, err := rule.Do(ctx)
+			return err
+		}),
+		// The rule throttles traffic; this makes navigator.connection agree.
+		network.OverrideNetworkState(false, latency, down, up).WithConnectionType(connType),
 	}
 }
@@ -0,0 +1,120 @@
+package driver
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// ConnStats reports a driver's use of its pooled connections.
+type ConnStats struct {
+	New    int64 // requests that dialed a connection, since the driver was created
+	Reused int64 // requests that reused a pooled one
+	Open   int   // connections open now
+	Idle   int   // open connections no request is using
+}
+
+// ConnStatser is implemented by drivers that pool connections.
+type ConnStatser interface {
+	ConnStats() ConnStats
+}
+
+// connTracker counts the connections a driver dials, which of them are in
+// use, and how often requests dial rather than reuse one.
+type connTracker struct {
+	newConns, reused atomic.Int64
+
+	mu   sync.Mutex
+	open map[*trackedConn]struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{open: make(map[*trackedConn]struct{})}
+}
+
+// trackedConn is a connection dialed through connTracker.dial.
+type trackedConn struct {
+	net.Conn
+	t      *connTracker
+	active int // requests using it; guarded by t.mu
+	once   sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		c.t.mu.Lock()
+		delete(c.t.open, c)
+		c.t.mu.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+// dial wraps a DialContext so that the connections it opens are tracked.
+func (t *connTracker) dial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tc := &trackedConn{Conn: conn, t: t}
+		t.mu.Lock()
+		t.open[tc] = struct{}{}
+		t.mu.Unlock()
+		return tc, nil
+	}
+}
+
+// acquire counts a request that got conn, new or reused, and marks conn in
+// use until the returned func is called. conn may be a TLS connection over
+// a tracked one.
+func (t *connTracker) acquire(conn net.Conn, reused bool) func() {
+	if reused {
+		t.reused.Add(1)
+	} else {
+		t.newConns.Add(1)
+	}
+	tc := unwrapTracked(conn)
+	if tc == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	tc.active++
+	t.mu.Unlock()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			tc.active--
+			t.mu.Unlock()
+		})
+	}
+}
+
+// unwrapTracked returns the trackedConn under conn, or nil.
+func unwrapTracked(conn net.Conn) *trackedConn {
+	for conn != nil {
+		if tc, ok := conn.(*trackedConn); ok {
+			return tc
+		}
+		nc, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = nc.NetConn()
+	}
+	return nil
+}
+
+func (t *connTracker) stats() ConnStats {
+	s := ConnStats{New: t.newConns.Load(), Reused: t.reused.Load()}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s.Open = len(t.open)
+	for c := range t.open {
+		if c.active == 0 {
+			s.Idle++
+		}
+	}
+	return s
+}
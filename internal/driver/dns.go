@@ -6,10 +6,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/lewta/sendit/internal/config"
 	"github.com/lewta/sendit/internal/task"
 	"github.com/miekg/dns"
 )
 
+// defaultDNSTimeoutS is the per-query timeout of a dns target without
+// dns.timeout_s.
+const defaultDNSTimeoutS = 10
+
 // rcodeToHTTP maps a DNS RCODE to an HTTP-like status code so the engine's
 // ClassifyStatusCode logic works correctly for DNS results.
 //
@@ -33,28 +38,73 @@ func rcodeToHTTP(rcode int) int {
 	}
 }
 
+// DNSDriverOptions configures optional DNSDriver behaviour.
+type DNSDriverOptions struct {
+	// OnResolverHealth, if set, is called with a resolver's address the
+	// first time it is queried, and whenever it fails over or recovers.
+	OnResolverHealth func(resolver string, healthy bool)
+	// Settings are the client options shared by all dns targets.
+	Settings config.DNSDriverConfig
+}
+
 // DNSDriver performs DNS lookups using the miekg/dns library.
 type DNSDriver struct {
-	client *dns.Client
+	clients     map[string]*dns.Client // by network: udp, udp4, tcp6, ...
+	health      *resolverHealth
+	udpSize     int // for targets without dns.udp_size
+	tcpFallback bool
 }
 
-// NewDNSDriver creates a DNSDriver with a shared DNS client.
+// NewDNSDriver creates a DNSDriver with shared UDP and TCP DNS clients per
+// IP family, which asks again over TCP for truncated answers.
 func NewDNSDriver() *DNSDriver {
-	return &DNSDriver{
-		client: &dns.Client{
-			Net:     "udp",
-			Timeout: 10 * time.Second,
-		},
+	return NewDNSDriverWithOptions(DNSDriverOptions{Settings: config.DNSDriverConfig{TCPFallback: true}})
+}
+
+// NewDNSDriverWithOptions creates a DNSDriver configured by opts.
+func NewDNSDriverWithOptions(opts DNSDriverOptions) *DNSDriver {
+	d := &DNSDriver{
+		clients:     make(map[string]*dns.Client, 2*len(ipFamilies)),
+		health:      newResolverHealth(opts.OnResolverHealth),
+		udpSize:     opts.Settings.UDPSize,
+		tcpFallback: opts.Settings.TCPFallback,
+	}
+	for _, family := range ipFamilies {
+		for _, network := range []string{"udp", "tcp"} {
+			n := ipNetwork(network, family)
+			d.clients[n] = &dns.Client{Net: n, Timeout: defaultDNSTimeoutS * time.Second}
+		}
 	}
+	return d
+}
+
+// client returns the shared client for network, "udp" or "tcp", over
+// family, or a copy of it with timeout when that is set and differs.
+func (d *DNSDriver) client(network, family string, timeout time.Duration) *dns.Client {
+	c := d.clients[ipNetwork(network, familyKey(family))]
+	if timeout > 0 && timeout != c.Timeout {
+		cc := *c
+		cc.Timeout = timeout
+		c = &cc
+	}
+	return c
 }
 
 // Execute performs a DNS query for t.URL using the configured resolver and record type.
+// With a dns.resolvers list, a query that gets no response from one resolver
+// is sent to the next, and resolvers that keep failing are tried last. A
+// truncated UDP answer is asked for again over TCP unless the driver's
+// tcp_fallback is off.
 func (d *DNSDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	cfg := t.Config.DNS
 
-	resolver := cfg.Resolver
-	if resolver == "" {
-		resolver = "8.8.8.8:53"
+	resolvers := cfg.Resolvers
+	if len(resolvers) == 0 {
+		resolver := cfg.Resolver
+		if resolver == "" {
+			resolver = "8.8.8.8:53"
+		}
+		resolvers = []string{resolver}
 	}
 
 	recordType := strings.ToUpper(cfg.RecordType)
@@ -62,9 +112,13 @@ func (d *DNSDriver) Execute(ctx context.Context, t task.Task) task.Result {
 		recordType = "A"
 	}
 
+	// The record type, and the response code once there is one, are
+	// recorded so that results and metrics can be split by query type.
+	meta := map[string]string{"dns_record_type": recordType}
+
 	qtype, ok := dns.StringToType[recordType]
 	if !ok {
-		return task.Result{Task: t, Error: fmt.Errorf("unknown DNS record type: %s", recordType)}
+		return task.Result{Task: t, Error: fmt.Errorf("unknown DNS record type: %s", recordType), Meta: meta}
 	}
 
 	fqdn := dns.Fqdn(t.URL)
@@ -72,9 +126,67 @@ func (d *DNSDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	msg := new(dns.Msg)
 	msg.SetQuestion(fqdn, qtype)
 	msg.RecursionDesired = true
+	udpSize := cfg.UDPSize
+	if udpSize == 0 {
+		udpSize = d.udpSize
+	}
+	if udpSize > 0 {
+		// Advertise the buffer size in an EDNS0 OPT record; the client
+		// sizes its receive buffer to match.
+		msg.SetEdns0(uint16(udpSize), false)
+	}
 
 	start := time.Now()
+	family := t.Config.Network.IPFamily
+	timeout := time.Duration(cfg.TimeoutS) * time.Second
+	client := d.client("udp", family, timeout)
 
+	var err error
+	for _, resolver := range d.health.order(resolvers) {
+		meta["dns_resolver"] = resolver
+		var resp *dns.Msg
+		var rtt time.Duration
+		resp, rtt, err = exchange(ctx, client, msg, resolver)
+		if ctx.Err() != nil {
+			return task.Result{Task: t, Duration: time.Since(start), Error: ctx.Err(), Meta: meta}
+		}
+		d.health.record(resolver, err == nil)
+		if err != nil {
+			continue
+		}
+		if resp.Truncated && d.tcpFallback {
+			// The answer did not fit: ask again over TCP, and keep the
+			// truncated one if that fails.
+			tcpResp, tcpRTT, tcpErr := exchange(ctx, d.client("tcp", family, timeout), msg, resolver)
+			if ctx.Err() != nil {
+				return task.Result{Task: t, Duration: time.Since(start), Error: ctx.Err(), Meta: meta}
+			}
+			if tcpErr == nil {
+				resp, rtt = tcpResp, tcpRTT
+				meta["dns_transport"] = "tcp"
+			}
+		}
+		if resp.Truncated {
+			meta["dns_truncated"] = "true"
+		}
+		// Duration is the wall time since the first query, including any
+		// failover and the wait for the exchange goroutine to be scheduled;
+		// dns_rtt_ms is the round trip the client measured for the answer,
+		// so the gap between the two shows contention on the sending side.
+		meta["dns_rcode"] = dns.RcodeToString[resp.Rcode]
+		meta["dns_rtt_ms"] = formatMs(rtt)
+		return task.Result{
+			Task:       t,
+			StatusCode: rcodeToHTTP(resp.Rcode),
+			Duration:   time.Since(start),
+			Meta:       meta,
+		}
+	}
+	return task.Result{Task: t, Duration: time.Since(start), Error: err, Meta: meta}
+}
+
+// exchange sends msg to resolver, returning early when ctx is done.
+func exchange(ctx context.Context, client *dns.Client, msg *dns.Msg, resolver string) (*dns.Msg, time.Duration, error) {
 	// Use a goroutine so we can respect ctx cancellation.
 	type dnsResult struct {
 		resp *dns.Msg
@@ -82,23 +194,15 @@ func (d *DNSDriver) Execute(ctx context.Context, t task.Task) task.Result {
 		err  error
 	}
 	ch := make(chan dnsResult, 1)
-
 	go func() {
-		resp, rtt, err := d.client.Exchange(msg, resolver)
+		resp, rtt, err := client.Exchange(msg, resolver)
 		ch <- dnsResult{resp, rtt, err}
 	}()
 
 	select {
 	case <-ctx.Done():
-		return task.Result{Task: t, Duration: time.Since(start), Error: ctx.Err()}
+		return nil, 0, ctx.Err()
 	case r := <-ch:
-		if r.err != nil {
-			return task.Result{Task: t, Duration: time.Since(start), Error: r.err}
-		}
-		return task.Result{
-			Task:       t,
-			StatusCode: rcodeToHTTP(r.resp.Rcode),
-			Duration:   r.rtt,
-		}
+		return r.resp, r.rtt, r.err
 	}
 }
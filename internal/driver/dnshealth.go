@@ -0,0 +1,103 @@
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// resolverFailThreshold is how many queries in a row a resolver may
+	// fail, without any response, before queries fail over to the next one.
+	resolverFailThreshold = 3
+	// resolverCooldown is how long a failed-over resolver is passed over
+	// before it is tried first again.
+	resolverCooldown = 30 * time.Second
+)
+
+// resolverHealth tracks, per resolver address, the queries that got no
+// response, so that a resolver that keeps timing out is moved behind the
+// healthy ones in a target's dns.resolvers list.
+type resolverHealth struct {
+	mu       sync.Mutex
+	state    map[string]*resolverState
+	onChange func(resolver string, healthy bool)
+	now      func() time.Time
+}
+
+type resolverState struct {
+	failures  int // without a response, in a row
+	downUntil time.Time
+	down      bool
+}
+
+func newResolverHealth(onChange func(resolver string, healthy bool)) *resolverHealth {
+	return &resolverHealth{
+		state:    make(map[string]*resolverState),
+		onChange: onChange,
+		now:      time.Now,
+	}
+}
+
+// order returns resolvers with the ones that have failed over moved to the
+// end, keeping the configured order otherwise. A failed-over resolver whose
+// cooldown has passed counts as healthy again, so the next query probes it.
+func (h *resolverHealth) order(resolvers []string) []string {
+	if len(resolvers) < 2 {
+		return resolvers
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	out := make([]string, 0, len(resolvers))
+	var down []string
+	for _, r := range resolvers {
+		if s := h.state[r]; s != nil && s.down && now.Before(s.downUntil) {
+			down = append(down, r)
+			continue
+		}
+		out = append(out, r)
+	}
+	return append(out, down...)
+}
+
+// record notes whether a query to resolver got a response.
+func (h *resolverHealth) record(resolver string, ok bool) {
+	h.mu.Lock()
+	s := h.state[resolver]
+	first := s == nil
+	if first {
+		s = &resolverState{}
+		h.state[resolver] = s
+	}
+	var changed bool
+	if ok {
+		changed = s.down
+		s.failures, s.down = 0, false
+	} else {
+		s.failures++
+		if s.failures >= resolverFailThreshold {
+			changed = !s.down
+			s.down = true
+			s.downUntil = h.now().Add(resolverCooldown)
+		}
+	}
+	healthy, failures := !s.down, s.failures
+	h.mu.Unlock()
+
+	if changed {
+		if healthy {
+			log.Info().Str("resolver", resolver).Msg("dns resolver recovered")
+		} else {
+			log.Warn().
+				Str("resolver", resolver).
+				Int("failures", failures).
+				Dur("cooldown", resolverCooldown).
+				Msg("dns resolver not responding, failing over")
+		}
+	}
+	if (first || changed) && h.onChange != nil {
+		h.onChange(resolver, healthy)
+	}
+}
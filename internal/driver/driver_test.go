@@ -1,10 +1,15 @@
 package driver_test
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	cryptorand "crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -12,11 +17,16 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
 	"github.com/coder/websocket"
 	"github.com/lewta/sendit/internal/config"
 	"github.com/lewta/sendit/internal/driver"
@@ -86,6 +96,147 @@ func TestHTTPDriver_200(t *testing.T) {
 	}
 }
 
+func TestHTTPDriver_IPFamily(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	for family, wantErr := range map[string]bool{"": false, "any": false, "ipv4": false, "ipv6": true} {
+		tk := httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5}) // listens on 127.0.0.1
+		tk.Config.Network.IPFamily = family
+		result := drv.Execute(context.Background(), tk)
+		if gotErr := result.Error != nil; gotErr != wantErr {
+			t.Errorf("ip_family %q: error = %v, want error %v", family, result.Error, wantErr)
+		}
+	}
+}
+
+func TestHTTPDriver_Resolve(t *testing.T) {
+	var gotHost atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHost.Store(r.Host)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	drv := driver.NewHTTPDriver()
+	target := "http://backend.invalid:" + port + "/"
+	result := drv.Execute(context.Background(), httpTask(target, config.HTTPConfig{
+		TimeoutS: 5,
+		Resolve:  []config.ResolveEntry{{Host: "Backend.invalid", Address: "127.0.0.1"}},
+	}))
+	if result.Error != nil || result.StatusCode != 200 {
+		t.Fatalf("result = %d, %v, want 200", result.StatusCode, result.Error)
+	}
+	if got := gotHost.Load(); got != "backend.invalid:"+port {
+		t.Errorf("Host header = %v, want backend.invalid:%s", got, port)
+	}
+
+	// Without the override the same URL must not reach the server.
+	if result := drv.Execute(context.Background(), httpTask(target, config.HTTPConfig{TimeoutS: 5})); result.Error == nil {
+		t.Errorf("unresolvable host without override: got status %d, want error", result.StatusCode)
+	}
+}
+
+func TestHTTPDriver_Resolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	var queries atomic.Int32
+	resolver := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		queries.Add(1)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if q := r.Question[0]; q.Qtype == dns.TypeA && q.Name == "backend.sendit.test." {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP("127.0.0.1"),
+			})
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	drv := driver.NewHTTPDriver()
+	tk := httpTask("http://backend.sendit.test:"+port+"/", config.HTTPConfig{TimeoutS: 5, Resolver: resolver})
+	tk.Config.Network.IPFamily = "ipv4"
+	result := drv.Execute(context.Background(), tk)
+	if result.Error != nil || result.StatusCode != 200 {
+		t.Fatalf("result = %d, %v, want 200", result.StatusCode, result.Error)
+	}
+	if queries.Load() == 0 {
+		t.Error("custom resolver received no queries")
+	}
+}
+
+func TestHTTPDriver_TLSFingerprint(t *testing.T) {
+	hellos := make(chan *tls.ClientHelloInfo, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			hellos <- hello
+			return nil, nil
+		},
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// isGREASE reports whether v is one of the reserved GREASE values
+	// (RFC 8701) that Chrome sprinkles into its ClientHello and Go never
+	// sends.
+	isGREASE := func(v uint16) bool { return v&0x0f0f == 0x0a0a && v>>8 == v&0xff }
+	drv := driver.NewHTTPDriver()
+	for fingerprint, wantGREASE := range map[string]bool{"": false, "go": false, "chrome": true} {
+		// The test server's certificate is not trusted, so the request
+		// fails after the ClientHello has been seen.
+		drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, TLSFingerprint: fingerprint}))
+		hello := <-hellos
+		if got := isGREASE(hello.CipherSuites[0]); got != wantGREASE {
+			t.Errorf("tls_fingerprint %q: first cipher suite %#04x, GREASE = %v, want %v", fingerprint, hello.CipherSuites[0], got, wantGREASE)
+		}
+		if fingerprint == "chrome" && !slices.Contains(hello.SupportedProtos, "h2") {
+			t.Errorf("tls_fingerprint chrome: ALPN %v, want h2 offered", hello.SupportedProtos)
+		}
+	}
+}
+
+func TestHTTPDriver_MaxConnsPerHost(t *testing.T) {
+	var active, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriverWithOptions(driver.HTTPDriverOptions{Settings: config.HTTPDriverConfig{MaxConnsPerHost: 1}})
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5})); r.Error != nil {
+				t.Errorf("request failed: %v", r.Error)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got != 1 {
+		t.Errorf("peak concurrent requests = %d, want 1 with max_conns_per_host 1", got)
+	}
+}
+
 func TestHTTPDriver_4xx(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -103,6 +254,28 @@ func TestHTTPDriver_4xx(t *testing.T) {
 	}
 }
 
+func TestHTTPDriver_RateLimitHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.Header().Set("X-RateLimit-Remaining", "3")
+			w.Header().Set("X-RateLimit-Reset", "30")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	result := drv.Execute(context.Background(), httpTask(srv.URL+"/limited", config.HTTPConfig{TimeoutS: 5}))
+	if result.RateLimit == nil || result.RateLimit.Remaining != 3 || time.Until(result.RateLimit.Reset) < 25*time.Second {
+		t.Errorf("RateLimit = %+v, want 3 remaining with a reset about 30s away", result.RateLimit)
+	}
+
+	result = drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5}))
+	if result.RateLimit != nil {
+		t.Errorf("RateLimit = %+v without headers, want nil", result.RateLimit)
+	}
+}
+
 func TestHTTPDriver_Timeout(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(3 * time.Second) // longer than the driver timeout
@@ -141,6 +314,153 @@ func TestHTTPDriver_CustomHeaders(t *testing.T) {
 	}
 }
 
+func TestHTTPDriver_HeaderProfile(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{
+		TimeoutS:      5,
+		HeaderProfile: "chrome",
+		Headers:       map[string]string{"accept-language": "de-DE,de;q=0.9"},
+	}))
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if v := got.Get("Sec-Ch-Ua-Mobile"); v != "?0" {
+		t.Errorf("sec-ch-ua-mobile = %q, want ?0", v)
+	}
+	if v := got.Get("User-Agent"); !strings.Contains(v, "Chrome/") {
+		t.Errorf("user-agent = %q, want a Chrome user agent", v)
+	}
+	if v := got.Get("Accept-Language"); v != "de-DE,de;q=0.9" {
+		t.Errorf("accept-language = %q, want the headers value to override the profile", v)
+	}
+
+	result = drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, HeaderProfile: "none"}))
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if v := got.Get("Sec-Fetch-Mode"); v != "" {
+		t.Errorf("sec-fetch-mode = %q with profile none, want unset", v)
+	}
+}
+
+func TestHTTPDriver_HeaderProfileOrder(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// The server reads the raw requests of one kept-alive connection, so
+	// the header lines are seen in the order they crossed the wire.
+	heads := make(chan []string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		for {
+			var names []string
+			size := 0
+			for {
+				line, err := br.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimRight(line, "\r\n")
+				if line == "" {
+					break
+				}
+				name, value, ok := strings.Cut(line, ":")
+				if !ok {
+					continue // the request line
+				}
+				names = append(names, name)
+				if strings.EqualFold(name, "Content-Length") {
+					size, _ = strconv.Atoi(strings.TrimSpace(value))
+				}
+			}
+			if _, err := io.CopyN(io.Discard, br, int64(size)); err != nil {
+				return
+			}
+			heads <- names
+			_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+		}
+	}()
+
+	want := []string{
+		"Host", "sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform", "Upgrade-Insecure-Requests",
+		"User-Agent", "Accept", "Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest",
+		"Accept-Encoding", "Accept-Language", "Priority",
+	}
+	drv := driver.NewHTTPDriver()
+	target := "http://" + ln.Addr().String() + "/"
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		result := drv.Execute(context.Background(), httpTask(target, config.HTTPConfig{
+			TimeoutS:      5,
+			Method:        method,
+			Body:          "sendit",
+			HeaderProfile: "chrome",
+			Headers:       map[string]string{"X-Extra": "1"},
+		}))
+		if result.Error != nil {
+			t.Fatalf("%s: unexpected error: %v", method, result.Error)
+		}
+		names := <-heads
+		if len(names) < len(want) || !slices.Equal(names[:len(want)], want) {
+			t.Errorf("%s: header lines %v, want them to start %v", method, names, want)
+		}
+		if !slices.Contains(names[len(want):], "X-Extra") {
+			t.Errorf("%s: header lines %v, want X-Extra after the profile's", method, names)
+		}
+	}
+	if s := drv.ConnStats(); s.New != 1 || s.Reused != 1 {
+		t.Errorf("conn stats %+v, want the second request on the first's connection", s)
+	}
+}
+
+func TestHTTPDriver_TraceHeader(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Request-Id"), r.Header.Get("Traceparent"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	var ids []string
+	for range 2 {
+		result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, TraceHeader: "x-request-id"}))
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+		ids = append(ids, result.Meta["request_id"])
+	}
+	if len(ids[0]) != 36 || ids[0] == ids[1] {
+		t.Errorf("request IDs = %q, want two distinct UUIDs", ids)
+	}
+	if got[0] != ids[0] || got[2] != ids[1] {
+		t.Errorf("server saw x-request-id %q and %q, want %q", got[0], got[2], ids)
+	}
+
+	got = nil
+	result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, TraceHeader: "traceparent"}))
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	id := result.Meta["request_id"]
+	if tp := got[1]; len(id) != 32 || !strings.HasPrefix(tp, "00-"+id+"-") || !strings.HasSuffix(tp, "-01") || len(tp) != 55 {
+		t.Errorf("traceparent = %q, request_id = %q, want a W3C trace context carrying the ID", tp, id)
+	}
+}
+
 func TestHTTPDriver_CustomAuthHeader_NotForwardedToCrossHostRedirect(t *testing.T) {
 	var redirectedRequests atomic.Int32
 	var gotHeader string
@@ -297,6 +617,560 @@ func TestHTTPDriver_POST_WithBody(t *testing.T) {
 	}
 }
 
+func TestHTTPDriver_DetailsDisabledByDefault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "test")
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	result := driver.NewHTTPDriver().Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5}))
+	if result.Meta != nil {
+		t.Errorf("Meta = %v, want nil when no details are enabled", result.Meta)
+	}
+}
+
+func TestHTTPDriver_MethodMix(t *testing.T) {
+	var mu sync.Mutex
+	counts := map[string]int{}
+	bodies := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		counts[r.Method]++
+		if len(b) > 0 {
+			bodies[r.Method]++
+		}
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	// As loaded from YAML, whose parser lowercases map keys.
+	tk := httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, Body: "x", Methods: map[string]float64{"get": 3, "post": 1}})
+	for i := 0; i < 400; i++ {
+		if result := drv.Execute(context.Background(), tk); result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+	}
+	if len(counts) != 2 || counts["GET"] < 250 || counts["POST"] < 50 {
+		t.Errorf("methods sent = %v, want about 300 GET and 100 POST", counts)
+	}
+	if bodies["GET"] != 0 || bodies["POST"] != counts["POST"] {
+		t.Errorf("requests with a body = %v, want only POST", bodies)
+	}
+}
+
+func TestHTTPDriver_BodySize(t *testing.T) {
+	type got struct {
+		n             int
+		contentLength int64
+	}
+	bodies := make(chan got, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- got{len(b), r.ContentLength}
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	for _, dist := range []string{"uniform", "lognormal"} {
+		bs := config.BodySizeConfig{Min: 100, Max: 200, Distribution: dist}
+		tk := httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, Method: http.MethodPost, BodySize: bs})
+		for i := 0; i < 50; i++ {
+			result := drv.Execute(context.Background(), tk)
+			if result.Error != nil {
+				t.Fatalf("%s: unexpected error: %v", dist, result.Error)
+			}
+			b := <-bodies
+			if b.n < 100 || b.n > 200 || int64(b.n) != b.contentLength {
+				t.Fatalf("%s: body of %d bytes, Content-Length %d, want 100-200 and equal", dist, b.n, b.contentLength)
+			}
+			if want := strconv.Itoa(b.n); result.Meta["request_body_bytes"] != want {
+				t.Fatalf("%s: request_body_bytes = %q, want %s", dist, result.Meta["request_body_bytes"], want)
+			}
+		}
+	}
+}
+
+func TestHTTPDriver_RedirectChain(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/b", http.StatusMovedPermanently) })
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/c", http.StatusFound) })
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/loop", http.StatusFound) })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	result := drv.Execute(context.Background(), httpTask(srv.URL+"/a", config.HTTPConfig{TimeoutS: 5}))
+	if result.Error != nil || result.StatusCode != 200 {
+		t.Fatalf("result = %d, %v, want 200", result.StatusCode, result.Error)
+	}
+	want := []task.Hop{{URL: srv.URL + "/a", StatusCode: 301}, {URL: srv.URL + "/b", StatusCode: 302}}
+	if !slices.Equal(result.Redirects, want) {
+		t.Errorf("Redirects = %v, want %v", result.Redirects, want)
+	}
+
+	result = drv.Execute(context.Background(), httpTask(srv.URL+"/loop", config.HTTPConfig{TimeoutS: 5}))
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "stopped after 10 redirects") {
+		t.Errorf("loop: error = %v, want stopped after 10 redirects", result.Error)
+	}
+	if len(result.Redirects) != 10 {
+		t.Errorf("loop: %d hops recorded, want 10", len(result.Redirects))
+	}
+}
+
+func TestHTTPDriver_ConnStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	for i := 0; i < 3; i++ {
+		if result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5})); result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+	}
+	want := driver.ConnStats{New: 1, Reused: 2, Open: 1, Idle: 1}
+	if got := drv.ConnStats(); got != want {
+		t.Errorf("ConnStats = %+v, want %+v", got, want)
+	}
+
+	srv.CloseClientConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for drv.ConnStats().Open != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := drv.ConnStats(); got.Open != 0 || got.Idle != 0 {
+		t.Errorf("after the server closed the connection: %+v, want none open", got)
+	}
+}
+
+func TestHTTPDriver_ReadBody(t *testing.T) {
+	body := strings.Repeat("x", 1<<20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	for _, tc := range []struct {
+		readBody  config.ReadBody
+		wantBytes int64
+		abandoned bool
+	}{
+		{config.ReadAll, 1 << 20, false},
+		{100, 100, true},
+		{config.ReadNone, 0, true},
+		{2 << 20, 1 << 20, false}, // the limit is more than the body
+	} {
+		result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, ReadBody: tc.readBody}))
+		if result.Error != nil || result.StatusCode != 200 {
+			t.Fatalf("read_body %d: status %d, error %v", tc.readBody, result.StatusCode, result.Error)
+		}
+		if result.BytesRead != tc.wantBytes {
+			t.Errorf("read_body %d: BytesRead = %d, want %d", tc.readBody, result.BytesRead, tc.wantBytes)
+		}
+		if got := result.Meta["body_abandoned"] == "true"; got != tc.abandoned {
+			t.Errorf("read_body %d: body_abandoned = %v, want %v", tc.readBody, got, tc.abandoned)
+		}
+	}
+	// An abandoned body drops its connection rather than return it to the
+	// pool: the second request reuses the first's connection, and the two
+	// after an abandoned body dial anew.
+	if got := drv.ConnStats(); got.New != 3 || got.Reused != 1 {
+		t.Errorf("ConnStats = %+v, want 3 new, 1 reused", got)
+	}
+}
+
+func TestHTTPDriver_ReadRate(t *testing.T) {
+	body := strings.Repeat("x", 20000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	// 40 kB/s with a 4 kB first chunk: the other 16 kB take about 400ms.
+	start := time.Now()
+	result := driver.NewHTTPDriver().Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5, ReadRateBps: 40000}))
+	elapsed := time.Since(start)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if result.BytesRead != int64(len(body)) {
+		t.Errorf("BytesRead = %d, want %d", result.BytesRead, len(body))
+	}
+	if elapsed < 350*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Execute took %v, want about 400ms", elapsed)
+	}
+}
+
+func TestHTTPDriver_Abort(t *testing.T) {
+	gone := make(chan struct{}, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/body" {
+			_, _ = io.WriteString(w, "partial")
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			gone <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	cfg := config.HTTPConfig{TimeoutS: 10, AbortProbability: 1, AbortAfterMs: 100}
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/", 0},       // before the response
+		{"/body", 200}, // while reading the body
+	} {
+		start := time.Now()
+		result := driver.NewHTTPDriver().Execute(context.Background(), httpTask(srv.URL+tc.path, cfg))
+		if !result.Aborted || result.Error != nil {
+			t.Fatalf("%s: Aborted = %v, Error = %v; want aborted without error", tc.path, result.Aborted, result.Error)
+		}
+		if result.StatusCode != tc.status {
+			t.Errorf("%s: StatusCode = %d, want %d", tc.path, result.StatusCode, tc.status)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: Execute took %v, want it cut short after at most 100ms", tc.path, elapsed)
+		}
+		select {
+		case <-gone:
+		case <-time.After(2 * time.Second):
+			t.Errorf("%s: server did not see the client go away", tc.path)
+		}
+	}
+}
+
+func TestHTTPDriver_Protocol(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Alt-Svc", `h3=":443"; ma=86400, h3-29=":443"`)
+		w.Header().Add("Alt-Svc", `h3=":8443"`)
+	}))
+	defer srv.Close()
+
+	result := driver.NewHTTPDriver().Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5}))
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if result.Protocol != "HTTP/1.1" {
+		t.Errorf("Protocol = %q, want HTTP/1.1", result.Protocol)
+	}
+	if result.AltSvc != "h3,h3-29" || !result.AdvertisesH3() {
+		t.Errorf("AltSvc = %q, want h3,h3-29", result.AltSvc)
+	}
+}
+
+func TestHTTPDriver_Details(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "test-server")
+		w.Header().Set("X-Cache", "HIT")
+		_, _ = io.WriteString(w, "hello, world")
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriverWithOptions(driver.HTTPDriverOptions{
+		Details: config.OutputDetailsConfig{
+			Timings:          true,
+			RemoteIP:         true,
+			TLS:              true,
+			ResponseHeaders:  []string{"Server", "X-Cache", "X-Missing"},
+			BodySnippetBytes: 5,
+		},
+	})
+	result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5}))
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+
+	m := result.Meta
+	if m["remote_ip"] != "127.0.0.1" {
+		t.Errorf("remote_ip = %q, want 127.0.0.1", m["remote_ip"])
+	}
+	if _, ok := m["connect_ms"]; !ok {
+		t.Errorf("connect_ms missing from %v", m)
+	}
+	if _, ok := m["ttfb_ms"]; !ok {
+		t.Errorf("ttfb_ms missing from %v", m)
+	}
+	if _, ok := m["transfer_ms"]; !ok {
+		t.Errorf("transfer_ms missing from %v", m)
+	}
+	if _, ok := m["tls_version"]; ok {
+		t.Errorf("tls_version = %q, want absent for plain HTTP", m["tls_version"])
+	}
+	if m["header_server"] != "test-server" || m["header_x-cache"] != "HIT" {
+		t.Errorf("headers = %q / %q, want test-server / HIT", m["header_server"], m["header_x-cache"])
+	}
+	if _, ok := m["header_x-missing"]; ok {
+		t.Error("header_x-missing should be omitted when the response lacks it")
+	}
+	if m["body_snippet"] != "hello" {
+		t.Errorf("body_snippet = %q, want hello", m["body_snippet"])
+	}
+	if result.BytesRead != int64(len("hello, world")) {
+		t.Errorf("BytesRead = %d, want full body length %d", result.BytesRead, len("hello, world"))
+	}
+}
+
+func TestHTTPDriver_CaptureBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bad":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"error":"missing field: q"}`)
+		case "/binary":
+			w.WriteHeader(http.StatusBadGateway)
+			_, _ = w.Write([]byte{0xff, 0xfe, 0x00, 0x01})
+		case "/utf8":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, "café closed")
+		default:
+			_, _ = io.WriteString(w, "ok")
+		}
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	capture := func(path string, c config.CaptureBodyConfig) map[string]string {
+		t.Helper()
+		result := drv.Execute(context.Background(), httpTask(srv.URL+path, config.HTTPConfig{TimeoutS: 5, CaptureBody: c}))
+		if result.Error != nil {
+			t.Fatalf("%s: unexpected error: %v", path, result.Error)
+		}
+		return result.Meta
+	}
+
+	m := capture("/bad", config.CaptureBodyConfig{MaxBytes: 8})
+	if m["response_body"] != `{"error"` || m["response_body_truncated"] != "true" || m["response_content_type"] != "application/json" {
+		t.Errorf("400 capture = %v", m)
+	}
+	if m := capture("/bad", config.CaptureBodyConfig{MaxBytes: 1024}); m["response_body"] != `{"error":"missing field: q"}` || m["response_body_truncated"] != "" {
+		t.Errorf("untruncated capture = %v", m)
+	}
+	if m := capture("/ok", config.CaptureBodyConfig{MaxBytes: 1024}); m["response_body"] != "" {
+		t.Errorf("on: error captured a 200: %v", m)
+	}
+	if m := capture("/ok", config.CaptureBodyConfig{MaxBytes: 1024, On: "always"}); m["response_body"] != "ok" {
+		t.Errorf("on: always = %v", m)
+	}
+	if m := capture("/binary", config.CaptureBodyConfig{MaxBytes: 1024}); m["response_body"] != "//4AAQ==" || m["response_body_encoding"] != "base64" {
+		t.Errorf("binary capture = %v", m)
+	}
+	// The limit falls inside the two-byte "é", which is dropped.
+	if m := capture("/utf8", config.CaptureBodyConfig{MaxBytes: 4}); m["response_body"] != "caf" || m["response_body_encoding"] != "" {
+		t.Errorf("split UTF-8 capture = %v", m)
+	}
+}
+
+func TestHTTPDriver_DecodesCompressedBody(t *testing.T) {
+	plain := strings.Repeat("sendit compresses well. ", 200)
+	var gz, br bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = io.WriteString(zw, plain)
+	_ = zw.Close()
+	bw := brotli.NewWriter(&br)
+	_, _ = io.WriteString(bw, plain)
+	_ = bw.Close()
+
+	var acceptEncoding atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding.Store(r.Header.Get("Accept-Encoding"))
+		switch r.URL.Path {
+		case "/gzip":
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(gz.Bytes())
+		case "/br":
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = w.Write(br.Bytes())
+		case "/unknown":
+			w.Header().Set("Content-Encoding", "compress")
+			_, _ = io.WriteString(w, "opaque")
+		default:
+			_, _ = io.WriteString(w, plain)
+		}
+	}))
+	defer srv.Close()
+
+	drv := driver.NewHTTPDriver()
+	for _, tc := range []struct {
+		path          string
+		wire, decoded int
+	}{
+		{"/gzip", gz.Len(), len(plain)},
+		{"/br", br.Len(), len(plain)},
+		{"/plain", len(plain), len(plain)},
+		{"/unknown", len("opaque"), 0},
+	} {
+		cfg := config.HTTPConfig{TimeoutS: 5, CaptureBody: config.CaptureBodyConfig{MaxBytes: 6, On: "always"}}
+		result := drv.Execute(context.Background(), httpTask(srv.URL+tc.path, cfg))
+		if result.Error != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.path, result.Error)
+		}
+		if result.BytesRead != int64(tc.wire) || result.DecodedBytes != int64(tc.decoded) {
+			t.Errorf("%s: BytesRead = %d, DecodedBytes = %d, want %d and %d", tc.path, result.BytesRead, result.DecodedBytes, tc.wire, tc.decoded)
+		}
+		if want := "sendit"; tc.decoded > 0 && result.Meta["response_body"] != want {
+			t.Errorf("%s: captured body = %q, want decoded %q", tc.path, result.Meta["response_body"], want)
+		}
+	}
+	if got := acceptEncoding.Load(); got != "gzip, br" {
+		t.Errorf("Accept-Encoding = %q, want gzip, br", got)
+	}
+
+	// An Accept-Encoding from headers is sent as is.
+	result := drv.Execute(context.Background(), httpTask(srv.URL+"/plain", config.HTTPConfig{TimeoutS: 5, Headers: map[string]string{"accept-encoding": "identity"}}))
+	if got := acceptEncoding.Load(); result.Error != nil || got != "identity" {
+		t.Errorf("Accept-Encoding = %q (err %v), want identity", got, result.Error)
+	}
+}
+
+// --- Proxy pool ---
+
+// startSOCKS5 runs a minimal no-auth SOCKS5 server (CONNECT only) and
+// returns its address and a counter of the connections it relayed.
+func startSOCKS5(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	var relayed atomic.Int32
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				buf := make([]byte, 262)
+				// Greeting: VER NMETHODS METHODS...
+				if _, err := io.ReadFull(c, buf[:2]); err != nil {
+					return
+				}
+				if _, err := io.ReadFull(c, buf[:buf[1]]); err != nil {
+					return
+				}
+				if _, err := c.Write([]byte{0x05, 0x00}); err != nil {
+					return
+				}
+				// Request: VER CMD RSV ATYP DST.ADDR DST.PORT
+				if _, err := io.ReadFull(c, buf[:4]); err != nil {
+					return
+				}
+				var host string
+				switch buf[3] {
+				case 0x01:
+					_, _ = io.ReadFull(c, buf[:4])
+					host = net.IP(buf[:4]).String()
+				case 0x03:
+					_, _ = io.ReadFull(c, buf[:1])
+					n := int(buf[0])
+					_, _ = io.ReadFull(c, buf[:n])
+					host = string(buf[:n])
+				default:
+					return
+				}
+				_, _ = io.ReadFull(c, buf[:2])
+				port := int(buf[0])<<8 | int(buf[1])
+				up, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+				if err != nil {
+					_, _ = c.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer up.Close()
+				relayed.Add(1)
+				_, _ = c.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+				go func() { _, _ = io.Copy(up, c) }()
+				_, _ = io.Copy(c, up)
+			}()
+		}
+	}()
+	return ln.Addr().String(), &relayed
+}
+
+func TestHTTPDriver_ProxiesRoundRobin(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	a, viaA := startSOCKS5(t)
+	b, viaB := startSOCKS5(t)
+
+	pool, err := driver.NewProxyPool(config.NetworkConfig{
+		Proxies:        []string{"socks5://" + a, "socks5h://" + b},
+		ProxySelection: "round_robin",
+	})
+	if err != nil {
+		t.Fatalf("NewProxyPool: %v", err)
+	}
+	drv := driver.NewHTTPDriverWithOptions(driver.HTTPDriverOptions{Proxies: pool})
+	for range 4 {
+		result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5}))
+		if result.Error != nil || result.StatusCode != 200 {
+			t.Fatalf("result = %d, %v, want 200", result.StatusCode, result.Error)
+		}
+	}
+	if viaA.Load() != 2 || viaB.Load() != 2 {
+		t.Errorf("relayed = %d, %d, want 2 through each proxy", viaA.Load(), viaB.Load())
+	}
+}
+
+func TestProxyPool_HealthCheckSkipsDeadProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	live, relayed := startSOCKS5(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := ln.Addr().String()
+	_ = ln.Close()
+
+	pool, err := driver.NewProxyPool(config.NetworkConfig{
+		Proxies:          []string{"socks5://" + dead, "socks5://" + live},
+		ProxySelection:   "round_robin",
+		ProxyHealthCheck: config.ProxyHealthCheckConfig{IntervalS: 60, TimeoutMs: 1000},
+	})
+	if err != nil {
+		t.Fatalf("NewProxyPool: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool.Start(ctx)
+
+	drv := driver.NewHTTPDriverWithOptions(driver.HTTPDriverOptions{Proxies: pool})
+	// The first check runs as soon as the pool starts; once it has taken
+	// the dead proxy out, every request succeeds.
+	deadline := time.Now().Add(2 * time.Second)
+	for ok := 0; ok < 4; {
+		if time.Now().After(deadline) {
+			t.Fatal("requests still routed to the dead proxy after the health check")
+		}
+		if result := drv.Execute(context.Background(), httpTask(srv.URL, config.HTTPConfig{TimeoutS: 5})); result.Error == nil {
+			ok++
+		} else {
+			ok = 0
+		}
+	}
+	if relayed.Load() < 4 {
+		t.Errorf("live proxy relayed %d connections, want >= 4", relayed.Load())
+	}
+}
+
 // --- DNS driver ---
 
 // startDNSServer starts a local miekg/dns server on a random UDP port and
@@ -318,6 +1192,48 @@ func startDNSServer(t *testing.T, handler func(w dns.ResponseWriter, r *dns.Msg)
 	return addr
 }
 
+// startDNSServerUDPAndTCP is startDNSServer listening for TCP queries too,
+// on the same port.
+func startDNSServerUDPAndTCP(t *testing.T, handler func(w dns.ResponseWriter, r *dns.Msg)) string {
+	t.Helper()
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", handler)
+	for range 10 {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("ListenPacket: %v", err)
+		}
+		addr := pc.LocalAddr().String()
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			_ = pc.Close() // port taken for TCP; try another
+			continue
+		}
+		udp := &dns.Server{PacketConn: pc, Net: "udp", Handler: mux}
+		tcp := &dns.Server{Listener: ln, Net: "tcp", Handler: mux}
+		go udp.ActivateAndServe() //nolint:errcheck
+		go tcp.ActivateAndServe() //nolint:errcheck
+		t.Cleanup(func() { _ = udp.Shutdown(); _ = tcp.Shutdown() })
+		return addr
+	}
+	t.Fatal("no port free for both UDP and TCP")
+	return ""
+}
+
+// bigTXT answers r with TXT records totalling about 2 KB, which do not fit
+// a plain 512-byte UDP response.
+func bigTXT(r *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	for i := range 8 {
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+			Txt: []string{strings.Repeat(strconv.Itoa(i), 250)},
+		})
+	}
+	return m
+}
+
 func TestDNSDriver_NOERROR(t *testing.T) {
 	addr := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
 		m := new(dns.Msg)
@@ -335,6 +1251,13 @@ func TestDNSDriver_NOERROR(t *testing.T) {
 	if result.StatusCode != 200 {
 		t.Errorf("StatusCode = %d, want 200 (NOERROR)", result.StatusCode)
 	}
+	rtt, err := strconv.ParseFloat(result.Meta["dns_rtt_ms"], 64)
+	if err != nil || rtt <= 0 {
+		t.Fatalf("dns_rtt_ms = %q, want the client-measured round trip", result.Meta["dns_rtt_ms"])
+	}
+	if total := float64(result.Duration.Microseconds()) / 1000; total < rtt {
+		t.Errorf("Duration = %vms, want at least dns_rtt_ms %vms", total, rtt)
+	}
 }
 
 func TestDNSDriver_NXDOMAIN(t *testing.T) {
@@ -354,6 +1277,9 @@ func TestDNSDriver_NXDOMAIN(t *testing.T) {
 	if result.StatusCode != 404 {
 		t.Errorf("StatusCode = %d, want 404 (NXDOMAIN)", result.StatusCode)
 	}
+	if rt, rc := result.Meta["dns_record_type"], result.Meta["dns_rcode"]; rt != "A" || rc != "NXDOMAIN" {
+		t.Errorf("meta = %s %s, want A NXDOMAIN", rt, rc)
+	}
 }
 
 func TestDNSDriver_SERVFAIL(t *testing.T) {
@@ -375,6 +1301,25 @@ func TestDNSDriver_SERVFAIL(t *testing.T) {
 	}
 }
 
+func TestDNSDriver_IPFamily(t *testing.T) {
+	addr := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		_ = w.WriteMsg(m)
+	})
+
+	drv := driver.NewDNSDriver()
+	tk := dnsTask("example.com", addr, "A")
+	tk.Config.Network.IPFamily = "ipv4"
+	if result := drv.Execute(context.Background(), tk); result.Error != nil {
+		t.Errorf("ipv4: unexpected error: %v", result.Error)
+	}
+	tk.Config.Network.IPFamily = "ipv6"
+	if result := drv.Execute(context.Background(), tk); result.Error == nil {
+		t.Errorf("ipv6: expected error dialing IPv4 resolver %s, got status %d", addr, result.StatusCode)
+	}
+}
+
 func TestDNSDriver_UnreachableResolver(t *testing.T) {
 	// Use a port that nothing is listening on.
 	drv := driver.NewDNSDriver()
@@ -387,6 +1332,137 @@ func TestDNSDriver_UnreachableResolver(t *testing.T) {
 	}
 }
 
+func TestDNSDriver_Timeout(t *testing.T) {
+	// A resolver that never answers.
+	addr := startDNSServer(t, func(dns.ResponseWriter, *dns.Msg) {})
+
+	drv := driver.NewDNSDriver()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tk := dnsTask("example.com", addr, "A")
+	tk.Config.DNS.TimeoutS = 1
+	start := time.Now()
+	result := drv.Execute(ctx, tk)
+
+	if result.Error == nil || ctx.Err() != nil {
+		t.Fatalf("error = %v, ctx err = %v; want the query to time out on its own", result.Error, ctx.Err())
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("query gave up after %v, want about 1s", elapsed)
+	}
+}
+
+func TestDNSDriver_UDPSize(t *testing.T) {
+	var advertised atomic.Int32
+	addr := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if opt := r.IsEdns0(); opt != nil {
+			advertised.Store(int32(opt.UDPSize()))
+		}
+		_ = w.WriteMsg(bigTXT(r))
+	})
+
+	drv := driver.NewDNSDriver()
+	tk := dnsTask("example.com", addr, "TXT")
+	tk.Config.DNS.UDPSize = 4096
+	result := drv.Execute(context.Background(), tk)
+
+	if result.Error != nil || result.StatusCode != 200 {
+		t.Fatalf("status %d, error %v; want 200", result.StatusCode, result.Error)
+	}
+	if got := advertised.Load(); got != 4096 {
+		t.Errorf("advertised EDNS0 UDP size = %d, want 4096", got)
+	}
+	if result.Meta["dns_truncated"] != "" || result.Meta["dns_transport"] != "" {
+		t.Errorf("meta = %v, want the answer over UDP in full", result.Meta)
+	}
+
+	// drivers.dns.udp_size applies to targets that set none.
+	drv = driver.NewDNSDriverWithOptions(driver.DNSDriverOptions{Settings: config.DNSDriverConfig{UDPSize: 1232}})
+	drv.Execute(context.Background(), dnsTask("example.com", addr, "TXT"))
+	if got := advertised.Load(); got != 1232 {
+		t.Errorf("advertised EDNS0 UDP size = %d, want the driver's 1232", got)
+	}
+}
+
+func TestDNSDriver_TruncatedFallsBackToTCP(t *testing.T) {
+	var tcpQueries atomic.Int32
+	addr := startDNSServerUDPAndTCP(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		if w.RemoteAddr().Network() == "tcp" {
+			tcpQueries.Add(1)
+		}
+		// Cut the UDP answer to the 512 bytes a query without EDNS0 allows.
+		m := bigTXT(r)
+		if w.RemoteAddr().Network() == "udp" {
+			m.Truncate(dns.MinMsgSize)
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	drv := driver.NewDNSDriver()
+	result := drv.Execute(context.Background(), dnsTask("example.com", addr, "TXT"))
+
+	if result.Error != nil || result.StatusCode != 200 {
+		t.Fatalf("status %d, error %v; want 200", result.StatusCode, result.Error)
+	}
+	if tcpQueries.Load() != 1 || result.Meta["dns_transport"] != "tcp" {
+		t.Errorf("TCP queries = %d, meta = %v; want the truncated answer asked for again over TCP", tcpQueries.Load(), result.Meta)
+	}
+
+	// With tcp_fallback off, the truncated answer is the result.
+	drv = driver.NewDNSDriverWithOptions(driver.DNSDriverOptions{})
+	result = drv.Execute(context.Background(), dnsTask("example.com", addr, "TXT"))
+	if tcpQueries.Load() != 1 || result.Meta["dns_truncated"] != "true" {
+		t.Errorf("TCP queries = %d, meta = %v; want the truncated UDP answer kept", tcpQueries.Load(), result.Meta)
+	}
+}
+
+func TestDNSDriver_ResolverFailover(t *testing.T) {
+	good := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		_ = w.WriteMsg(m)
+	})
+	const dead = "127.0.0.1:19998" // nothing listening
+
+	var mu sync.Mutex
+	health := map[string]bool{}
+	var changes []string
+	drv := driver.NewDNSDriverWithOptions(driver.DNSDriverOptions{
+		OnResolverHealth: func(resolver string, healthy bool) {
+			mu.Lock()
+			defer mu.Unlock()
+			health[resolver] = healthy
+			changes = append(changes, fmt.Sprintf("%s=%t", resolver, healthy))
+		},
+	})
+
+	tk := dnsTask("example.com", "", "A")
+	tk.Config.DNS.Resolvers = []string{dead, good}
+	for i := 0; i < 4; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		result := drv.Execute(ctx, tk)
+		cancel()
+		if result.Error != nil || result.StatusCode != 200 {
+			t.Fatalf("query %d: status %d, error %v; want 200 from %s", i, result.StatusCode, result.Error, good)
+		}
+		if got := result.Meta["dns_resolver"]; got != good {
+			t.Errorf("query %d: dns_resolver = %q, want %q", i, got, good)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if health[dead] || !health[good] {
+		t.Errorf("health = %v, want %s down and %s up", health, dead, good)
+	}
+	// Once failed over, the dead resolver is not queried again during its
+	// cooldown, so it reports exactly one change to unhealthy.
+	want := []string{dead + "=true", good + "=true", dead + "=false"}
+	if fmt.Sprint(changes) != fmt.Sprint(want) {
+		t.Errorf("health changes = %v, want %v", changes, want)
+	}
+}
+
 // --- WebSocket driver ---
 
 func TestWebSocketDriver_Connect(t *testing.T) {
@@ -422,6 +1498,104 @@ func TestWebSocketDriver_Connect(t *testing.T) {
 	}
 }
 
+func TestWebSocketDriver_MeasureEcho(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow() //nolint:errcheck
+		for {
+			typ, data, err := conn.Read(r.Context())
+			if err != nil {
+				return
+			}
+			if string(data) == "drop" {
+				continue
+			}
+			// Push an unrelated message first; the driver must skip it.
+			conn.Write(r.Context(), typ, []byte("tick")) //nolint:errcheck,gosec
+			time.Sleep(5 * time.Millisecond)
+			if err := conn.Write(r.Context(), typ, data); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	drv := driver.NewWebSocketDriver()
+	cfg := config.WebSocketConfig{DurationS: 1, SendMessages: []string{"a", "b", "c", "drop", "d"}, MeasureEcho: true}
+	result := drv.Execute(context.Background(), wsTask("ws://"+srv.Listener.Addr().String(), cfg))
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	e := result.Echo
+	if e == nil {
+		t.Fatal("Echo = nil, want round-trip stats")
+	}
+	// "drop" is never echoed, so measuring stops there and "d" is not sent.
+	if e.Sent != 4 || e.Echoed != 3 {
+		t.Errorf("Sent, Echoed = %d, %d; want 4, 3", e.Sent, e.Echoed)
+	}
+	if e.P50 < 5*time.Millisecond || e.P95 < e.P50 {
+		t.Errorf("P50, P95 = %v, %v; want >= 5ms and P95 >= P50", e.P50, e.P95)
+	}
+
+	cfg.MeasureEcho = false
+	if result := drv.Execute(context.Background(), wsTask("ws://"+srv.Listener.Addr().String(), cfg)); result.Echo != nil {
+		t.Errorf("Echo = %+v without measure_echo, want nil", result.Echo)
+	}
+}
+
+func TestWebSocketDriver_CloseModes(t *testing.T) {
+	closes := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow() //nolint:errcheck
+		_, _, err = conn.Read(r.Context())
+		closes <- err
+	}))
+	defer srv.Close()
+
+	drv := driver.NewWebSocketDriver()
+	for _, tc := range []struct {
+		close  string
+		status websocket.StatusCode // -1: no close frame
+	}{
+		{"", websocket.StatusNormalClosure},
+		{"going_away", websocket.StatusGoingAway},
+		{"reset", -1},
+	} {
+		cfg := config.WebSocketConfig{DurationS: 1, Close: tc.close}
+		result := drv.Execute(context.Background(), wsTask("ws://"+srv.Listener.Addr().String(), cfg))
+		if result.Error != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.close, result.Error)
+		}
+		want := tc.close
+		if want == "" {
+			want = "normal"
+		}
+		if got := result.Meta["ws_close"]; got != want {
+			t.Errorf("ws_close = %q, want %q", got, want)
+		}
+		err := <-closes
+		if got := websocket.CloseStatus(err); got != tc.status {
+			t.Errorf("%s: server saw close status %d (%v), want %d", want, got, err, tc.status)
+		}
+	}
+
+	// A mix with one mode always picks it.
+	cfg := config.WebSocketConfig{DurationS: 1, CloseMix: map[string]float64{"going_away": 1}}
+	result := drv.Execute(context.Background(), wsTask("ws://"+srv.Listener.Addr().String(), cfg))
+	if got := result.Meta["ws_close"]; got != "going_away" {
+		t.Errorf("close_mix: ws_close = %q, want going_away", got)
+	}
+	<-closes
+}
+
 func TestWebSocketDriver_ServerClosesEarly(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
@@ -916,3 +2090,42 @@ func TestBrowserDriver_Skipped(t *testing.T) {
 	t.Skip("browser driver requires Chrome — tested manually via sendit start")
 	_ = strings.NewReader("") // suppress unused import if test body is empty
 }
+
+func TestBrowserDriver_ChromePathAndExtraFlags(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake Chrome is a shell script")
+	}
+	// A stand-in for Chrome that records its arguments and exits, which is
+	// enough to see how the driver launches it.
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	chrome := filepath.Join(dir, "chrome")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\nexit 1\n"
+	if err := os.WriteFile(chrome, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	drv := driver.NewBrowserDriver()
+	tk := task.Task{URL: "https://example.com", Type: "browser", Config: config.TargetConfig{
+		URL:  "https://example.com",
+		Type: "browser",
+		Browser: config.BrowserConfig{
+			TimeoutS:   5,
+			ChromePath: chrome,
+			ExtraFlags: []string{"--no-sandbox", "--proxy-server=http://proxy:3128"},
+		},
+	}}
+	if result := drv.Execute(context.Background(), tk); result.Error == nil {
+		t.Fatal("Execute succeeded with a Chrome that exits at once")
+	}
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("chrome_path was not launched: %v", err)
+	}
+	args := strings.Fields(string(data))
+	for _, want := range []string{"--no-sandbox", "--proxy-server=http://proxy:3128", "--disable-dev-shm-usage"} {
+		if !slices.Contains(args, want) {
+			t.Errorf("Chrome args %v lack %s", args, want)
+		}
+	}
+}
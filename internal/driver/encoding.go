@@ -0,0 +1,61 @@
+package driver
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultAcceptEncoding is sent by http targets whose headers and header
+// profile leave Accept-Encoding unset.
+const defaultAcceptEncoding = "gzip, br"
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// decodeBody returns a reader of the body r sent with the given
+// Content-Encoding, and a func that releases it. It reports false for an
+// encoding it cannot decode (or a list of several), in which case the body
+// should be read as is. A body that does not start a valid stream decodes
+// as empty.
+func decodeBody(r io.Reader, encoding string) (io.Reader, func(), bool) {
+	nop := func() {}
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return r, nop, true
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return strings.NewReader(""), nop, true
+		}
+		return zr, nop, true
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return strings.NewReader(""), nop, true
+		}
+		return zr, func() { _ = zr.Close() }, true
+	case "br":
+		return brotli.NewReader(r), nop, true
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return strings.NewReader(""), nop, true
+		}
+		return zr, zr.Close, true
+	}
+	return nil, nil, false
+}
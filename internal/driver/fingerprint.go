@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// tlsFingerprints maps http.tls_fingerprint to the uTLS ClientHello preset
+// of the current release of each browser.
+var tlsFingerprints = map[string]utls.ClientHelloID{
+	"chrome":  utls.HelloChrome_Auto,
+	"firefox": utls.HelloFirefox_Auto,
+	"safari":  utls.HelloSafari_Auto,
+}
+
+// dialUTLS returns a DialTLSContext that connects with dial and performs
+// the TLS handshake with uTLS, presenting hello. The browser presets offer
+// h2 over ALPN; net/http speaks HTTP/2 when the server accepts it.
+func dialUTLS(dial func(ctx context.Context, network, addr string) (net.Conn, error), hello utls.ClientHelloID) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		uc := utls.UClient(raw, &utls.Config{ServerName: host}, hello)
+		if err := uc.HandshakeContext(ctx); err != nil {
+			_ = raw.Close()
+			return nil, err
+		}
+		return utlsConn{uc}, nil
+	}
+}
+
+// utlsConn reports a uTLS connection's state as a crypto/tls one, which is
+// how net/http learns the negotiated protocol and fills in Response.TLS.
+type utlsConn struct {
+	*utls.UConn
+}
+
+func (c utlsConn) ConnectionState() tls.ConnectionState {
+	cs := c.UConn.ConnectionState()
+	return tls.ConnectionState{
+		Version:            cs.Version,
+		HandshakeComplete:  cs.HandshakeComplete,
+		DidResume:          cs.DidResume,
+		CipherSuite:        cs.CipherSuite,
+		NegotiatedProtocol: cs.NegotiatedProtocol,
+		ServerName:         cs.ServerName,
+		PeerCertificates:   cs.PeerCertificates,
+		VerifiedChains:     cs.VerifiedChains,
+	}
+}
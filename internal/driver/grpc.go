@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"net"
 	"net/url"
 	"path"
 	"strings"
@@ -88,8 +89,9 @@ type grpcMethodInfo struct {
 //	grpcs://host:port/package.Service/Method  — TLS
 type GRPCDriver struct {
 	mu      sync.Mutex
-	conns   map[string]*grpc.ClientConn // keyed by addr+tlsMode
+	conns   map[string]*grpc.ClientConn // keyed by addr+tlsMode+family
 	methods sync.Map                    // keyed by addr+fullMethod → grpcMethodInfo
+	proxies *ProxyPool
 }
 
 // NewGRPCDriver creates a GRPCDriver.
@@ -99,6 +101,13 @@ func NewGRPCDriver() *GRPCDriver {
 	}
 }
 
+// SetProxies routes new connections through p. Connections are cached, so
+// a proxy is picked once per connection rather than per call. Call it
+// before the driver is used.
+func (d *GRPCDriver) SetProxies(p *ProxyPool) {
+	d.proxies = p
+}
+
 // Execute performs the unary gRPC call described by t.
 func (d *GRPCDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	cfg := t.Config.GRPC
@@ -127,7 +136,7 @@ func (d *GRPCDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	callCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutS)*time.Second)
 	defer cancel()
 
-	conn, err := d.getConn(addr, useTLS, cfg.Insecure)
+	conn, err := d.getConn(addr, familyKey(t.Config.Network.IPFamily), useTLS, cfg.Insecure)
 	if err != nil {
 		return task.Result{Task: t, Error: err}
 	}
@@ -166,14 +175,14 @@ func (d *GRPCDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	}
 }
 
-func (d *GRPCDriver) getConn(addr string, useTLS, insecureSkip bool) (*grpc.ClientConn, error) {
+func (d *GRPCDriver) getConn(addr, family string, useTLS, insecureSkip bool) (*grpc.ClientConn, error) {
 	tlsMode := "plain"
 	if useTLS && insecureSkip {
 		tlsMode = "tls-insecure"
 	} else if useTLS {
 		tlsMode = "tls"
 	}
-	key := addr + ":" + tlsMode
+	key := addr + ":" + tlsMode + ":" + family
 
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -193,7 +202,14 @@ func (d *GRPCDriver) getConn(addr string, useTLS, insecureSkip bool) (*grpc.Clie
 		creds = insecure.NewCredentials()
 	}
 
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if family != "any" || d.proxies != nil {
+		dial := dialFunc(family, d.proxies)
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return dial(ctx, "tcp", addr)
+		}))
+	}
+	conn, err := grpc.NewClient(addr, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("creating gRPC client for %q: %w", addr, err)
 	}
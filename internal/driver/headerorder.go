@@ -0,0 +1,430 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+// net/http writes request headers sorted by name over HTTP/1.1 and in map
+// order over HTTP/2, neither of which is a browser's. A target with a
+// header_profile therefore dials connections that rewrite each request's
+// header block on its way to the wire, putting the headers in the
+// profile's order; the request line, body, and everything the server sends
+// pass through untouched.
+
+// dialOrdered returns a DialContext whose connections write the headers of
+// plain HTTP/1.1 requests in p's order.
+func dialOrdered(dial func(ctx context.Context, network, addr string) (net.Conn, error), p headerProfile) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &orderedConn{Conn: conn, w: &h1Orderer{conn: conn, p: p}}, nil
+	}
+}
+
+// dialOrderedTLS returns a DialTLSContext that wraps the connections of
+// dialTLS so that they write request headers in p's order, over HTTP/1.1
+// or HTTP/2, whichever ALPN settled on.
+func dialOrderedTLS(dialTLS func(ctx context.Context, network, addr string) (net.Conn, error), p headerProfile) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialTLS(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		oc := &orderedConn{Conn: conn, w: &h1Orderer{conn: conn, p: p}}
+		cs, ok := conn.(connectionStater)
+		if !ok {
+			return oc, nil
+		}
+		if cs.ConnectionState().NegotiatedProtocol == "h2" {
+			oc.w = newH2Orderer(conn, p)
+		}
+		return orderedTLSConn{oc, cs}, nil
+	}
+}
+
+// dialTLS returns a DialTLSContext that connects with dial and performs the
+// handshake with crypto/tls, as net/http would itself, offering h2 over
+// ALPN. Targets with a header_profile but no tls_fingerprint use it, so
+// that their headers can be ordered above TLS.
+func dialTLS(dial func(ctx context.Context, network, addr string) (net.Conn, error), sessions tls.ClientSessionCache) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		tc := tls.Client(raw, &tls.Config{
+			ServerName:         host,
+			NextProtos:         []string{"h2", "http/1.1"},
+			ClientSessionCache: sessions,
+		})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			_ = raw.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+}
+
+type connectionStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// orderedConn is a connection whose writes go through an orderer.
+type orderedConn struct {
+	net.Conn
+	w interface{ Write(p []byte) (int, error) }
+}
+
+func (c *orderedConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+// NetConn returns the connection under c, for connTracker.
+func (c *orderedConn) NetConn() net.Conn { return c.Conn }
+
+// orderedTLSConn is an orderedConn over TLS, which reports the TLS state
+// that net/http picks the protocol by.
+type orderedTLSConn struct {
+	*orderedConn
+	cs connectionStater
+}
+
+func (c orderedTLSConn) ConnectionState() tls.ConnectionState { return c.cs.ConnectionState() }
+
+// rankHeaders stably sorts fields by the position of their names in order,
+// compared without regard to case. Fields whose names order lacks go after
+// the others, in the order they came.
+func rankHeaders[T any](fields []T, name func(T) string, order []string) {
+	rank := func(f T) int {
+		for i, o := range order {
+			if strings.EqualFold(name(f), o) {
+				return i
+			}
+		}
+		return len(order)
+	}
+	slices.SortStableFunc(fields, func(a, b T) int { return rank(a) - rank(b) })
+}
+
+// h1Orderer rewrites the header blocks of the HTTP/1.1 requests written to
+// it. It follows each request's Content-Length or chunked body, so that it
+// finds the header block of the next request on a kept-alive connection.
+type h1Orderer struct {
+	conn net.Conn
+	p    headerProfile
+
+	head    []byte // the header block so far, until it is complete
+	body    int64  // bytes of body (or of the current chunk) still to pass
+	chunked bool   // the body is chunked and has not ended
+	trailer bool   // the last chunk has been passed; in the trailer
+	line    []byte // the chunk-size or trailer line so far
+}
+
+func (o *h1Orderer) Write(p []byte) (int, error) {
+	n := len(p)
+	var out []byte
+	for len(p) > 0 {
+		switch {
+		case o.body > 0:
+			k := int(min(o.body, int64(len(p))))
+			out = append(out, p[:k]...)
+			o.body -= int64(k)
+			p = p[k:]
+		case o.chunked:
+			i := bytes.IndexByte(p, '\n')
+			if i < 0 {
+				o.line = append(o.line, p...)
+				out = append(out, p...)
+				p = nil
+				break
+			}
+			o.line = append(o.line, p[:i+1]...)
+			out = append(out, p[:i+1]...)
+			p = p[i+1:]
+			if err := o.endChunkLine(); err != nil {
+				return 0, err
+			}
+		default:
+			o.head = append(o.head, p...)
+			i := bytes.Index(o.head, []byte("\r\n\r\n"))
+			if i < 0 {
+				p = nil
+				break
+			}
+			p = append([]byte(nil), o.head[i+4:]...)
+			out = append(out, o.order(o.head[:i+4])...)
+			o.head = o.head[:0]
+		}
+	}
+	if len(out) > 0 {
+		if _, err := o.conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// endChunkLine handles the complete chunk-size or trailer line in o.line.
+func (o *h1Orderer) endChunkLine() error {
+	line := strings.TrimSpace(string(o.line))
+	o.line = o.line[:0]
+	if o.trailer {
+		if line == "" {
+			o.chunked, o.trailer = false, false
+		}
+		return nil
+	}
+	if i := strings.IndexByte(line, ';'); i >= 0 {
+		line = strings.TrimSpace(line[:i])
+	}
+	size, err := strconv.ParseInt(line, 16, 64)
+	if err != nil || size < 0 {
+		return errors.New("header order: malformed chunk size")
+	}
+	if size == 0 {
+		o.trailer = true
+	} else {
+		o.body = size + 2 // and the CRLF after the data
+	}
+	return nil
+}
+
+// order returns the header block head with Host first, as browsers send
+// it, then the profile's headers in its order and spelling, then the rest
+// as net/http wrote them. It notes how the request's body is framed.
+func (o *h1Orderer) order(head []byte) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(head), "\r\n\r\n"), "\r\n")
+	fields := lines[1:]
+	o.body, o.chunked = 0, false
+	for _, f := range fields {
+		name, value, _ := strings.Cut(f, ":")
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.EqualFold(name, "Content-Length"):
+			o.body, _ = strconv.ParseInt(value, 10, 64)
+		case strings.EqualFold(name, "Transfer-Encoding"):
+			o.chunked = strings.EqualFold(value, "chunked")
+		}
+	}
+	if o.chunked {
+		o.body = 0
+	}
+
+	order := make([]string, 0, len(o.p.fields)+1)
+	order = append(order, "Host")
+	for _, f := range o.p.fields {
+		order = append(order, f.name)
+	}
+	headerName := func(f string) string {
+		name, _, _ := strings.Cut(f, ":")
+		return name
+	}
+	rankHeaders(fields, headerName, order)
+
+	var b strings.Builder
+	b.WriteString(lines[0])
+	b.WriteString("\r\n")
+	for _, f := range fields {
+		name, rest, _ := strings.Cut(f, ":")
+		for _, pf := range o.p.fields {
+			if strings.EqualFold(name, pf.name) {
+				name = pf.name
+				break
+			}
+		}
+		b.WriteString(name)
+		b.WriteString(":")
+		b.WriteString(rest)
+		b.WriteString("\r\n")
+	}
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// HTTP/2 frame types and flags (RFC 9113, section 6) that h2Orderer reads.
+const (
+	h2FrameHeaders      = 0x1
+	h2FrameContinuation = 0x9
+
+	h2FlagEndHeaders = 0x4
+	h2FlagPadded     = 0x8
+	h2FlagPriority   = 0x20
+
+	// h2MaxFrame is the largest frame h2Orderer writes, the size every
+	// peer must accept.
+	h2MaxFrame = 16384
+)
+
+// h2Preface is the connection preface a client sends before its frames.
+const h2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// h2Orderer rewrites the HEADERS frames (and their CONTINUATIONs) written
+// to it. It decodes each header block with the HPACK state net/http's
+// encoder built, orders the fields, and encodes them again with its own
+// encoder, which then is the only one the server hears from. That encoder
+// keeps no dynamic table, as it cannot follow the server's
+// SETTINGS_HEADER_TABLE_SIZE; every other frame is passed on as it is.
+type h2Orderer struct {
+	conn net.Conn
+	p    headerProfile
+
+	preface int    // bytes of the preface still to pass
+	buf     []byte // the start of a frame, until it is complete
+
+	dec *hpack.Decoder
+	enc *hpack.Encoder
+	eb  bytes.Buffer // enc's output
+
+	// The HEADERS frame whose block is being collected, if any.
+	pending  bool
+	stream   uint32
+	flags    byte
+	priority []byte
+	block    []byte
+}
+
+func newH2Orderer(conn net.Conn, p headerProfile) *h2Orderer {
+	o := &h2Orderer{conn: conn, p: p, preface: len(h2Preface)}
+	o.dec = hpack.NewDecoder(4096, nil)
+	o.enc = hpack.NewEncoder(&o.eb)
+	o.enc.SetMaxDynamicTableSize(0)
+	return o
+}
+
+func (o *h2Orderer) Write(p []byte) (int, error) {
+	n := len(p)
+	var out []byte
+	if o.preface > 0 {
+		k := min(o.preface, len(p))
+		out = append(out, p[:k]...)
+		o.preface -= k
+		p = p[k:]
+	}
+	o.buf = append(o.buf, p...)
+	for len(o.buf) >= 9 {
+		size := int(o.buf[0])<<16 | int(o.buf[1])<<8 | int(o.buf[2])
+		if len(o.buf) < 9+size {
+			break
+		}
+		typ, flags := o.buf[3], o.buf[4]
+		stream := binary.BigEndian.Uint32(o.buf[5:9]) & 0x7fffffff
+		payload := o.buf[9 : 9+size]
+		var err error
+		switch {
+		case typ == h2FrameHeaders:
+			out, err = o.headers(out, stream, flags, payload)
+		case typ == h2FrameContinuation && o.pending:
+			o.block = append(o.block, payload...)
+			if flags&h2FlagEndHeaders != 0 {
+				out, err = o.flush(out)
+			}
+		default:
+			out = append(out, o.buf[:9+size]...)
+		}
+		if err != nil {
+			return 0, err
+		}
+		o.buf = o.buf[9+size:]
+	}
+	o.buf = slices.Clone(o.buf)
+	if len(out) > 0 {
+		if _, err := o.conn.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// headers starts collecting the block of a HEADERS frame, and writes it to
+// out once it is complete.
+func (o *h2Orderer) headers(out []byte, stream uint32, flags byte, payload []byte) ([]byte, error) {
+	if flags&h2FlagPadded != 0 {
+		if len(payload) < 1 || int(payload[0]) > len(payload)-1 {
+			return nil, errors.New("header order: malformed HEADERS padding")
+		}
+		payload = payload[1 : len(payload)-int(payload[0])]
+	}
+	var priority []byte
+	if flags&h2FlagPriority != 0 {
+		if len(payload) < 5 {
+			return nil, errors.New("header order: malformed HEADERS priority")
+		}
+		priority, payload = slices.Clone(payload[:5]), payload[5:]
+	}
+	o.pending = true
+	o.stream = stream
+	o.flags = flags &^ (h2FlagPadded | h2FlagEndHeaders)
+	o.priority = priority
+	o.block = append(o.block[:0], payload...)
+	if flags&h2FlagEndHeaders == 0 {
+		return out, nil
+	}
+	return o.flush(out)
+}
+
+// flush orders and re-encodes the collected header block, and appends it
+// to out as a HEADERS frame and as many CONTINUATIONs as it needs.
+func (o *h2Orderer) flush(out []byte) ([]byte, error) {
+	o.pending = false
+	fields, err := o.dec.DecodeFull(o.block)
+	if err != nil {
+		return nil, err
+	}
+	var pseudo, regular []hpack.HeaderField
+	for _, f := range fields {
+		if f.IsPseudo() {
+			pseudo = append(pseudo, f)
+		} else {
+			regular = append(regular, f)
+		}
+	}
+	fieldName := func(f hpack.HeaderField) string { return f.Name }
+	rankHeaders(pseudo, fieldName, o.p.pseudo)
+	order := make([]string, len(o.p.fields))
+	for i, f := range o.p.fields {
+		order[i] = f.name
+	}
+	rankHeaders(regular, fieldName, order)
+
+	o.eb.Reset()
+	for _, f := range append(pseudo, regular...) {
+		if err := o.enc.WriteField(f); err != nil {
+			return nil, err
+		}
+	}
+	block := o.eb.Bytes()
+
+	typ, flags, first := byte(h2FrameHeaders), o.flags, o.priority
+	for {
+		room := h2MaxFrame - len(first)
+		frag := block[:min(room, len(block))]
+		block = block[len(frag):]
+		if len(block) == 0 {
+			flags |= h2FlagEndHeaders
+		}
+		size := len(first) + len(frag)
+		out = append(out, byte(size>>16), byte(size>>8), byte(size), typ, flags)
+		out = binary.BigEndian.AppendUint32(out, o.stream)
+		out = append(out, first...)
+		out = append(out, frag...)
+		if len(block) == 0 {
+			return out, nil
+		}
+		// CONTINUATION frames carry only END_HEADERS.
+		typ, flags, first = h2FrameContinuation, 0, nil
+	}
+}
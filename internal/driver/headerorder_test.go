@@ -0,0 +1,147 @@
+package driver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func TestHeaderOrder_HTTP2(t *testing.T) {
+	// The httptest server only lends its certificate; the test serves
+	// HTTP/2 by hand, so that the header block is read as it came.
+	certSrv := httptest.NewUnstartedServer(nil)
+	certSrv.StartTLS()
+	cert, roots := certSrv.TLS.Certificates[0], certSrv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	certSrv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: []string{"h2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if _, err := io.ReadFull(conn, make([]byte, len(http2.ClientPreface))); err != nil {
+			return
+		}
+		fr := http2.NewFramer(conn, conn)
+		fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+		if err := fr.WriteSettings(); err != nil {
+			return
+		}
+		for {
+			f, err := fr.ReadFrame()
+			if err != nil {
+				return
+			}
+			switch f := f.(type) {
+			case *http2.SettingsFrame:
+				if !f.IsAck() {
+					_ = fr.WriteSettingsAck()
+				}
+			case *http2.MetaHeadersFrame:
+				var names []string
+				for _, hf := range f.Fields {
+					names = append(names, hf.Name)
+				}
+				got <- names
+				var block bytes.Buffer
+				_ = hpack.NewEncoder(&block).WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+				_ = fr.WriteHeaders(http2.HeadersFrameParam{StreamID: f.StreamID, BlockFragment: block.Bytes(), EndStream: true, EndHeaders: true})
+			}
+		}
+	}()
+
+	profile := headerProfiles["firefox"]
+	dial := (&net.Dialer{}).DialContext
+	dialTLS := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&tls.Dialer{NetDialer: &net.Dialer{}, Config: &tls.Config{RootCAs: roots, NextProtos: []string{"h2"}}}).DialContext(ctx, network, addr)
+	}
+	tr := &http.Transport{DialContext: dial, DialTLSContext: dialOrderedTLS(dialTLS, profile), ForceAttemptHTTP2: true}
+	defer tr.CloseIdleConnections()
+
+	req, err := http.NewRequest(http.MethodGet, "https://"+ln.Addr().String()+"/page", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyHeaderProfile(req.Header, "firefox")
+	req.Header.Set("X-Extra", "1")
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("proto %s, want HTTP/2", resp.Proto)
+	}
+
+	want := slices.Clone(profile.pseudo)
+	for _, f := range profile.fields {
+		want = append(want, strings.ToLower(f.name))
+	}
+	names := <-got
+	if len(names) < len(want) || !slices.Equal(names[:len(want)], want) {
+		t.Errorf("header fields %v, want them to start %v", names, want)
+	}
+	if !slices.Contains(names[len(want):], "x-extra") {
+		t.Errorf("header fields %v, want x-extra after the profile's", names)
+	}
+}
+
+func TestHeaderOrder_HTTP1ChunkedBody(t *testing.T) {
+	var wire bytes.Buffer
+	o := &h1Orderer{conn: writerConn{&wire}, p: headerProfile{fields: []headerField{{"b", ""}, {"a", ""}}}}
+	reqs := "POST / HTTP/1.1\r\nHost: x\r\nA: 1\r\nB: 2\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5\r\n\r\n\r\n!\r\n0\r\n\r\n" +
+		"GET / HTTP/1.1\r\nHost: x\r\nA: 1\r\nB: 2\r\n\r\n"
+	// Written a byte at a time, and with a chunk that looks like the end of
+	// a header block, the requests still come out whole.
+	for i := range len(reqs) {
+		if _, err := o.Write([]byte{reqs[i]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "POST / HTTP/1.1\r\nHost: x\r\nb: 2\r\na: 1\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5\r\n\r\n\r\n!\r\n0\r\n\r\n" +
+		"GET / HTTP/1.1\r\nHost: x\r\nb: 2\r\na: 1\r\n\r\n"
+	if wire.String() != want {
+		t.Errorf("wire:\n%q\nwant:\n%q", wire.String(), want)
+	}
+}
+
+// writerConn is a net.Conn that only writes, to w.
+type writerConn struct {
+	w io.Writer
+}
+
+func (c writerConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+func (writerConn) Read([]byte) (int, error) { return 0, io.EOF }
+
+func (writerConn) Close() error { return nil }
+
+func (writerConn) LocalAddr() net.Addr { return nil }
+
+func (writerConn) RemoteAddr() net.Addr { return nil }
+
+func (writerConn) SetDeadline(time.Time) error { return nil }
+
+func (writerConn) SetReadDeadline(time.Time) error { return nil }
+
+func (writerConn) SetWriteDeadline(time.Time) error { return nil }
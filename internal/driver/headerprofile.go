@@ -0,0 +1,79 @@
+package driver
+
+import "net/http"
+
+// headerField is one request header of a browser profile.
+type headerField struct {
+	name, value string
+}
+
+// headerProfile is the headers a browser sends on a top-level navigation,
+// and the order it sends them in.
+type headerProfile struct {
+	// pseudo is the order of the HTTP/2 pseudo-headers.
+	pseudo []string
+	// fields are the headers, listed in the browser's order. Names are
+	// spelled as the browser spells them over HTTP/1.1.
+	fields []headerField
+}
+
+// headerProfiles maps http.header_profile to its browser's headers. The
+// values follow the current desktop release of each browser, including a
+// User-Agent that agrees with the client hints. The requests are written
+// in the profile's order by orderHeaders.
+var headerProfiles = map[string]headerProfile{
+	"chrome": {
+		pseudo: []string{":method", ":authority", ":scheme", ":path"},
+		fields: []headerField{
+			{"sec-ch-ua", `"Google Chrome";v="131", "Chromium";v="131", "Not_A Brand";v="24"`},
+			{"sec-ch-ua-mobile", "?0"},
+			{"sec-ch-ua-platform", `"Windows"`},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-User", "?1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Accept-Encoding", "gzip, deflate, br, zstd"},
+			{"Accept-Language", "en-US,en;q=0.9"},
+			{"Priority", "u=0, i"},
+		},
+	},
+	"firefox": {
+		pseudo: []string{":method", ":path", ":authority", ":scheme"},
+		fields: []headerField{
+			{"User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:133.0) Gecko/20100101 Firefox/133.0"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			{"Accept-Language", "en-US,en;q=0.5"},
+			{"Accept-Encoding", "gzip, deflate, br, zstd"},
+			{"Upgrade-Insecure-Requests", "1"},
+			{"Sec-Fetch-Dest", "document"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-User", "?1"},
+			{"Priority", "u=0, i"},
+		},
+	},
+	"safari": {
+		pseudo: []string{":method", ":scheme", ":path", ":authority"},
+		fields: []headerField{
+			{"Sec-Fetch-Dest", "document"},
+			{"User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.1 Safari/605.1.15"},
+			{"Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"},
+			{"Sec-Fetch-Site", "none"},
+			{"Sec-Fetch-Mode", "navigate"},
+			{"Accept-Language", "en-US,en;q=0.9"},
+			{"Priority", "u=0, i"},
+			{"Accept-Encoding", "gzip, deflate, br"},
+		},
+	},
+}
+
+// applyHeaderProfile adds the headers of the named profile to h. Unknown
+// names, "none", and the empty string add nothing.
+func applyHeaderProfile(h http.Header, name string) {
+	for _, f := range headerProfiles[name].fields {
+		h.Set(f.name, f.value)
+	}
+}
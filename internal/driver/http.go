@@ -1,13 +1,26 @@
 package driver
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"maps"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/ratelimit"
 	"github.com/lewta/sendit/internal/task"
 )
 
@@ -15,56 +28,195 @@ import (
 // different host.
 type RedirectLimiter func(ctx context.Context, host string) error
 
+// HTTPDriverOptions configures optional HTTPDriver behaviour.
+type HTTPDriverOptions struct {
+	// RedirectLimiter, if set, is consulted before following a redirect to a
+	// different host.
+	RedirectLimiter RedirectLimiter
+	// Details selects which extra fields are recorded in Result.Meta.
+	Details config.OutputDetailsConfig
+	// Proxies, if set, carries every request through one of its proxies.
+	// Keep-alives are then disabled so that each request picks a proxy.
+	Proxies *ProxyPool
+	// Settings tunes the transports' connection pools and TLS session
+	// cache; zero fields keep the defaults.
+	Settings config.HTTPDriverConfig
+}
+
 // HTTPDriver executes HTTP requests.
 type HTTPDriver struct {
-	client          *http.Client
+	mu              sync.Mutex
+	clients         map[string]*http.Client // by dialing setup; see clientFor
 	redirectLimiter RedirectLimiter
+	details         config.OutputDetailsConfig
+	proxies         *ProxyPool
+	tracker         *connTracker
+	settings        config.HTTPDriverConfig
+	sessions        tls.ClientSessionCache // shared by all transports; nil = none
 }
 
 // NewHTTPDriver creates an HTTPDriver with a shared transport.
 func NewHTTPDriver() *HTTPDriver {
-	return NewHTTPDriverWithRedirectLimiter(nil)
+	return NewHTTPDriverWithOptions(HTTPDriverOptions{})
 }
 
 // NewHTTPDriverWithRedirectLimiter creates an HTTPDriver that asks
 // redirectLimiter for permission before following cross-host redirects.
 func NewHTTPDriverWithRedirectLimiter(redirectLimiter RedirectLimiter) *HTTPDriver {
-	return &HTTPDriver{
-		redirectLimiter: redirectLimiter,
-		client: &http.Client{
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     90 * time.Second,
-			},
-		},
+	return NewHTTPDriverWithOptions(HTTPDriverOptions{RedirectLimiter: redirectLimiter})
+}
+
+// NewHTTPDriverWithOptions creates an HTTPDriver configured by opts.
+func NewHTTPDriverWithOptions(opts HTTPDriverOptions) *HTTPDriver {
+	d := &HTTPDriver{
+		redirectLimiter: opts.RedirectLimiter,
+		details:         opts.Details,
+		proxies:         opts.Proxies,
+		clients:         make(map[string]*http.Client),
+		tracker:         newConnTracker(),
+		settings:        opts.Settings,
+	}
+	if n := opts.Settings.TLSSessionCacheSize; n > 0 {
+		d.sessions = tls.NewLRUClientSessionCache(n)
+	}
+	return d
+}
+
+// orDefault returns v, or def when v is 0.
+func orDefault(v, def int) int {
+	if v == 0 {
+		return def
 	}
+	return v
 }
 
-func (d *HTTPDriver) redirectPolicy(allowCrossHost bool) func(req *http.Request, via []*http.Request) error {
+// ConnStats reports how requests have used the driver's keep-alive pools,
+// across all of its transports.
+func (d *HTTPDriver) ConnStats() ConnStats {
+	return d.tracker.stats()
+}
+
+// clientFor returns the client for t's IP family, resolve overrides,
+// resolver, TLS fingerprint, and header profile. Targets that dial
+// differently get separate transports, so that a pooled connection is only
+// reused by targets that would have dialed the same address with the same
+// ClientHello and written their headers the same way.
+func (d *HTTPDriver) clientFor(t config.TargetConfig) *http.Client {
+	family := familyKey(t.Network.IPFamily)
+	cfg := t.HTTP
+
+	var b strings.Builder
+	writeCachePart(&b, family)
+	writeCachePart(&b, cfg.Resolver)
+	writeCachePart(&b, cfg.TLSFingerprint)
+	profile, ordered := headerProfiles[cfg.HeaderProfile]
+	if ordered {
+		writeCachePart(&b, cfg.HeaderProfile)
+	} else {
+		writeCachePart(&b, "")
+	}
+	for _, r := range cfg.Resolve {
+		writeCachePart(&b, strings.ToLower(r.Host)+"="+r.Address)
+	}
+	key := b.String()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if c, ok := d.clients[key]; ok {
+		return c
+	}
+
+	dialer := &hostDialer{family: family, proxies: d.proxies}
+	if len(cfg.Resolve) > 0 {
+		dialer.resolve = make(map[string]string, len(cfg.Resolve))
+		for _, r := range cfg.Resolve {
+			dialer.resolve[strings.ToLower(r.Host)] = r.Address
+		}
+	}
+	if cfg.Resolver != "" {
+		dialer.net.Resolver = dnsResolver(cfg.Resolver)
+	}
+	dial := d.tracker.dial(dialer.DialContext)
+	st := d.settings
+	tr := &http.Transport{
+		DialContext:         dial,
+		DisableKeepAlives:   d.proxies != nil,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        orDefault(st.MaxIdleConns, 100),
+		MaxIdleConnsPerHost: orDefault(st.MaxIdleConnsPerHost, 10),
+		MaxConnsPerHost:     st.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(orDefault(st.IdleConnTimeoutS, 90)) * time.Second,
+	}
+	if d.sessions != nil {
+		tr.TLSClientConfig = &tls.Config{ClientSessionCache: d.sessions}
+	}
+	if hello, ok := tlsFingerprints[cfg.TLSFingerprint]; ok {
+		tr.DialTLSContext = dialUTLS(dial, hello)
+	}
+	if ordered {
+		if tr.DialTLSContext == nil {
+			tr.DialTLSContext = dialTLS(dial, d.sessions)
+		}
+		tr.DialContext = dialOrdered(dial, profile)
+		tr.DialTLSContext = dialOrderedTLS(tr.DialTLSContext, profile)
+	}
+	c := &http.Client{Transport: tr}
+	d.clients[key] = c
+	return c
+}
+
+// Client returns a client on the transport the driver uses for t, with its
+// proxies, IP family, resolver, and TLS settings, for requests made on a
+// target's behalf such as fetching its robots.txt. It does not follow the
+// driver's redirect policy or count towards ConnStats' in-use connections.
+func (d *HTTPDriver) Client(t config.TargetConfig) *http.Client {
+	return &http.Client{Transport: d.clientFor(t).Transport}
+}
+
+// maxRedirects is how many redirects a request follows before it fails, as
+// with net/http's default policy, so that a redirect loop ends.
+const maxRedirects = 10
+
+// redirectPolicy returns the CheckRedirect func of one request, which
+// appends each redirect it follows to chain.
+func (d *HTTPDriver) redirectPolicy(allowCrossHost bool, chain *[]task.Hop) func(req *http.Request, via []*http.Request) error {
 	return func(req *http.Request, via []*http.Request) error {
 		if len(via) == 0 {
 			return nil
 		}
-
-		if strings.EqualFold(req.URL.Host, via[len(via)-1].URL.Host) {
-			return nil
+		hop := task.Hop{URL: via[len(via)-1].URL.String(), StatusCode: req.Response.StatusCode}
+		if len(via) >= maxRedirects {
+			*chain = append(*chain, hop)
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
 		}
-
-		if !allowCrossHost {
-			return http.ErrUseLastResponse
+		if err := d.checkRedirect(req, via, allowCrossHost); err != nil {
+			return err
 		}
+		*chain = append(*chain, hop)
+		return nil
+	}
+}
 
-		if d.redirectLimiter == nil {
-			return nil
-		}
+// checkRedirect decides whether req, a redirect from the last of via, is
+// followed.
+func (d *HTTPDriver) checkRedirect(req *http.Request, via []*http.Request, allowCrossHost bool) error {
+	if strings.EqualFold(req.URL.Host, via[len(via)-1].URL.Host) {
+		return nil
+	}
 
-		host := req.URL.Hostname()
-		if host == "" {
-			return nil
-		}
-		return d.redirectLimiter(req.Context(), host)
+	if !allowCrossHost {
+		return http.ErrUseLastResponse
+	}
+
+	if d.redirectLimiter == nil {
+		return nil
+	}
+
+	host := req.URL.Hostname()
+	if host == "" {
+		return nil
 	}
+	return d.redirectLimiter(req.Context(), host)
 }
 
 // Execute performs the HTTP request described by t.
@@ -76,49 +228,403 @@ func (d *HTTPDriver) Execute(ctx context.Context, t task.Task) task.Result {
 		timeoutS = 15
 	}
 	method := cfg.Method
+	if len(cfg.Methods) > 0 {
+		method = pickMethod(cfg.Methods)
+	}
 	if method == "" {
 		method = http.MethodGet
 	}
 
 	reqCtx, cancel := context.WithTimeout(ctx, time.Duration(timeoutS)*time.Second)
 	defer cancel()
+	reqCtx, aborted, stopAbort := withAbort(reqCtx, cfg.AbortProbability, cfg.AbortAfterMs)
+	defer stopAbort()
+
+	var tr *requestTrace
+	if d.details.Timings || d.details.RemoteIP {
+		tr = &requestTrace{}
+		reqCtx = httptrace.WithClientTrace(reqCtx, tr.clientTrace())
+	}
+	// The connections a request (and its redirects) got are in use until
+	// it returns, having read the body.
+	var connMu sync.Mutex
+	var releases []func()
+	defer func() {
+		connMu.Lock()
+		defer connMu.Unlock()
+		for _, release := range releases {
+			release()
+		}
+	}()
+	reqCtx = httptrace.WithClientTrace(reqCtx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			release := d.tracker.acquire(info.Conn, info.Reused)
+			connMu.Lock()
+			releases = append(releases, release)
+			connMu.Unlock()
+		},
+	})
 
+	// A method mix sends the body only with the methods that carry one.
+	sendBody := len(cfg.Methods) == 0 || (method != http.MethodGet && method != http.MethodHead)
 	var bodyReader io.Reader
-	if cfg.Body != "" {
+	if cfg.Body != "" && sendBody {
 		bodyReader = strings.NewReader(cfg.Body)
 	}
+	bodySize := int64(-1) // of a generated body; -1 when none is sent
+	if cfg.BodySize.Max > 0 && sendBody {
+		bodySize = drawBodySize(cfg.BodySize)
+		bodyReader = newRandomBody(bodySize)
+	}
 
 	req, err := http.NewRequestWithContext(reqCtx, method, t.URL, bodyReader)
 	if err != nil {
 		return task.Result{Task: t, Error: fmt.Errorf("creating request: %w", err)}
 	}
+	if bodySize >= 0 {
+		req.ContentLength = bodySize
+		req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(newRandomBody(bodySize)), nil }
+		if bodySize == 0 {
+			req.Body = http.NoBody
+		}
+	}
 
+	applyHeaderProfile(req.Header, cfg.HeaderProfile)
 	for k, v := range cfg.Headers {
 		req.Header.Set(k, v)
 	}
+	// Setting Accept-Encoding ourselves stops net/http decompressing
+	// transparently, so the wire size of the body can be counted.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", defaultAcceptEncoding)
+	}
+	var reqID string
+	if cfg.TraceHeader != "" {
+		var value string
+		value, reqID = newRequestID(cfg.TraceHeader)
+		req.Header.Set(cfg.TraceHeader, value)
+	}
 
 	if err := applyAuth(req, t.Config.Auth); err != nil {
 		return task.Result{Task: t, Error: err}
 	}
 
 	start := time.Now()
-	clientCopy := *d.client
-	clientCopy.CheckRedirect = d.redirectPolicy(cfg.AllowCrossHostRedirects)
+	clientCopy := *d.clientFor(t.Config)
+	var redirects []task.Hop
+	clientCopy.CheckRedirect = d.redirectPolicy(cfg.AllowCrossHostRedirects, &redirects)
 	client := &clientCopy
 	resp, err := client.Do(req)
 	elapsed := time.Since(start)
 
 	if err != nil {
-		return task.Result{Task: t, Duration: elapsed, Error: err}
+		meta := d.detailMeta(tr, start, reqID, nil, nil)
+		if bodySize >= 0 {
+			meta = withBodySize(meta, bodySize)
+		}
+		if aborted() {
+			return task.Result{Task: t, Duration: elapsed, Redirects: redirects, Meta: meta, Aborted: true}
+		}
+		return task.Result{Task: t, Duration: elapsed, Error: err, Redirects: redirects, Meta: meta}
 	}
 	defer resp.Body.Close()
 
-	n, _ := io.Copy(io.Discard, resp.Body)
+	// The status is known before the body is read, so the body is only
+	// buffered when a snippet or capture_body will keep part of it.
+	captureMax := 0
+	if c := cfg.CaptureBody; c.MaxBytes > 0 && (c.On == "always" || resp.StatusCode >= 400) {
+		captureMax = c.MaxBytes
+	}
+	// Snippets, captures, and the decoded size see the decoded body; a
+	// Content-Encoding that cannot be decoded leaves it as sent, with an
+	// unknown decoded size.
+	var respBody io.Reader = resp.Body
+	if cfg.ReadRateBps > 0 {
+		respBody = newSlowReader(reqCtx, resp.Body, cfg.ReadRateBps)
+	}
+	wire := &countingReader{r: respBody}
+	// http.read_body stops after that many bytes as sent; closing the body
+	// then drops the connection, or resets the stream over HTTP/2.
+	var src io.Reader = wire
+	if cfg.ReadBody != config.ReadAll {
+		src = io.LimitReader(wire, max(int64(cfg.ReadBody), 0))
+	}
+	body, release, decoded := decodeBody(src, resp.Header.Get("Content-Encoding"))
+	if !decoded {
+		body, release = src, func() {}
+	}
+	var head []byte
+	var n int64
+	if limit := max(d.details.BodySnippetBytes, captureMax); limit > 0 {
+		var buf bytes.Buffer
+		kept, _ := io.CopyN(&buf, body, int64(limit))
+		rest, _ := io.Copy(io.Discard, body)
+		n = kept + rest
+		head = buf.Bytes()
+	} else {
+		n, _ = io.Copy(io.Discard, body)
+	}
+	release()
+	// Read whatever the decoder left, such as trailing garbage.
+	_, _ = io.Copy(io.Discard, src)
+	stopAbort()
+	cut := aborted()
+	abandoned := cfg.ReadBody != config.ReadAll && bodyLeft(resp, wire.n, int64(cfg.ReadBody))
+	if tr != nil {
+		tr.mark(&tr.bodyDone)
+	}
 
-	return task.Result{
+	var snippet []byte
+	if limit := d.details.BodySnippetBytes; limit > 0 {
+		snippet = head[:min(len(head), limit)]
+	}
+	result := task.Result{
 		Task:       t,
 		StatusCode: resp.StatusCode,
 		Duration:   elapsed,
-		BytesRead:  n,
+		BytesRead:  wire.n,
+		Protocol:   httpProtocol(resp),
+		AltSvc:     altSvcProtocols(resp.Header),
+		Redirects:  redirects,
+		Meta:       d.detailMeta(tr, start, reqID, resp, snippet),
+		Aborted:    cut,
+	}
+	if decoded && !abandoned && !cut {
+		result.DecodedBytes = n
+	}
+	if bodySize >= 0 {
+		result.Meta = withBodySize(result.Meta, bodySize)
 	}
+	if abandoned {
+		if result.Meta == nil {
+			result.Meta = make(map[string]string, 1)
+		}
+		result.Meta["body_abandoned"] = "true"
+	}
+	if captureMax > 0 {
+		result.Meta = captureBody(result.Meta, resp, head[:min(len(head), captureMax)], n)
+	}
+	if b, ok := ratelimit.ParseHeaders(resp.Header, time.Now()); ok {
+		result.RateLimit = &b
+	}
+	return result
+}
+
+// bodyLeft reports whether resp's body had more to it than the read bytes
+// that http.read_body let through, limit being the read_body setting.
+func bodyLeft(resp *http.Response, read int64, limit int64) bool {
+	if resp.Body == http.NoBody {
+		return false
+	}
+	if resp.ContentLength >= 0 {
+		return resp.ContentLength > read
+	}
+	// Without a length, a read that stopped short of the limit hit the end.
+	return read >= limit
+}
+
+// pickMethod picks a method from an http.methods mix, with probability
+// proportional to its weight.
+func pickMethod(methods map[string]float64) string {
+	return strings.ToUpper(pickWeighted(methods))
+}
+
+// pickWeighted picks a key of mix with probability proportional to its
+// value.
+func pickWeighted(mix map[string]float64) string {
+	names := slices.Sorted(maps.Keys(mix))
+	total := 0.0
+	for _, n := range names {
+		total += mix[n]
+	}
+	r := rand.Float64() * total //nolint:gosec
+	for _, n := range names {
+		if r -= mix[n]; r < 0 {
+			return n
+		}
+	}
+	return names[len(names)-1]
+}
+
+// httpProtocol names the HTTP version resp came over, writing HTTP/2 and
+// HTTP/3 without the minor version net/http adds to Proto.
+func httpProtocol(resp *http.Response) string {
+	switch resp.ProtoMajor {
+	case 2, 3:
+		return fmt.Sprintf("HTTP/%d", resp.ProtoMajor)
+	}
+	return resp.Proto
+}
+
+// altSvcProtocols returns the protocol IDs in h's Alt-Svc header
+// (RFC 7838), such as "h3,h3-29" for
+// `h3=":443"; ma=86400, h3-29=":443"`, without duplicates.
+func altSvcProtocols(h http.Header) string {
+	var ids []string
+	for _, v := range h.Values("Alt-Svc") {
+		for _, alt := range strings.Split(v, ",") {
+			id, _, ok := strings.Cut(alt, "=")
+			id = strings.TrimSpace(id)
+			if !ok || id == "" || slices.Contains(ids, id) {
+				continue // "clear", or malformed
+			}
+			ids = append(ids, id)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// detailMeta builds the Result.Meta fields enabled by d.details, plus the
+// request_id sent in http.trace_header, if any. resp and snippet are nil
+// when the request failed before a response arrived.
+func (d *HTTPDriver) detailMeta(tr *requestTrace, start time.Time, reqID string, resp *http.Response, snippet []byte) map[string]string {
+	meta := make(map[string]string)
+	if reqID != "" {
+		meta["request_id"] = reqID
+	}
+
+	if tr != nil {
+		if d.details.Timings {
+			tr.addTimings(meta, start)
+		}
+		if d.details.RemoteIP {
+			tr.mu.Lock()
+			if tr.remoteIP != "" {
+				meta["remote_ip"] = tr.remoteIP
+			}
+			tr.mu.Unlock()
+		}
+	}
+
+	if resp != nil {
+		if d.details.TLS && resp.TLS != nil {
+			meta["tls_version"] = tls.VersionName(resp.TLS.Version)
+			meta["tls_cipher"] = tls.CipherSuiteName(resp.TLS.CipherSuite)
+		}
+		for _, h := range d.details.ResponseHeaders {
+			if v := resp.Header.Get(h); v != "" {
+				meta["header_"+strings.ToLower(h)] = v
+			}
+		}
+		if snippet != nil {
+			meta["body_snippet"] = string(snippet)
+		}
+	}
+
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// captureBody adds the http.capture_body fields to meta: the response's
+// content type, the captured start of its body, and, when body is shorter
+// than the n bytes read, a truncation flag. A body that is not UTF-8 text,
+// beyond a character cut off at the end, is stored base64-encoded.
+func captureBody(meta map[string]string, resp *http.Response, body []byte, n int64) map[string]string {
+	if meta == nil {
+		meta = make(map[string]string, 3)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		meta["response_content_type"] = ct
+	}
+	text := body
+	if int64(len(body)) < n {
+		meta["response_body_truncated"] = "true"
+		// Drop a multi-byte character split by the limit.
+		for i := 0; i < utf8.UTFMax-1 && len(text) > 0 && !utf8.Valid(text); i++ {
+			text = text[:len(text)-1]
+		}
+	}
+	if utf8.Valid(text) {
+		meta["response_body"] = string(text)
+	} else {
+		meta["response_body"] = base64.StdEncoding.EncodeToString(body)
+		meta["response_body_encoding"] = "base64"
+	}
+	return meta
+}
+
+// requestTrace records connection phase timestamps via httptrace. Only the
+// first occurrence of each phase is recorded; redirects reuse or open further
+// connections whose phases are not broken out. Hooks may fire from dialer
+// goroutines, so every access holds mu.
+type requestTrace struct {
+	mu                        sync.Mutex
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	firstByte, bodyDone       time.Time
+	remoteIP                  string
+}
+
+func (tr *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			tr.mark(&tr.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			tr.mark(&tr.dnsDone)
+		},
+		ConnectStart: func(string, string) {
+			tr.mark(&tr.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			tr.mark(&tr.connectDone)
+		},
+		TLSHandshakeStart: func() {
+			tr.mark(&tr.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			tr.mark(&tr.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tr.mu.Lock()
+			defer tr.mu.Unlock()
+			if tr.remoteIP == "" && info.Conn != nil {
+				addr := info.Conn.RemoteAddr().String()
+				if host, _, err := net.SplitHostPort(addr); err == nil {
+					addr = host
+				}
+				tr.remoteIP = addr
+			}
+		},
+		GotFirstResponseByte: func() {
+			tr.mark(&tr.firstByte)
+		},
+	}
+}
+
+// mark records the current time in *ts unless it is already set.
+func (tr *requestTrace) mark(ts *time.Time) {
+	now := time.Now()
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if ts.IsZero() {
+		*ts = now
+	}
+}
+
+// addTimings writes each completed phase duration in milliseconds. Phases
+// that did not happen (e.g. DNS for an IP literal, TLS for plain HTTP, or
+// everything on a reused keep-alive connection) are omitted.
+func (tr *requestTrace) addTimings(meta map[string]string, start time.Time) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	phase := func(key string, from, to time.Time) {
+		if from.IsZero() || to.IsZero() {
+			return
+		}
+		meta[key] = formatMs(to.Sub(from))
+	}
+	phase("dns_ms", tr.dnsStart, tr.dnsDone)
+	phase("connect_ms", tr.connectStart, tr.connectDone)
+	phase("tls_ms", tr.tlsStart, tr.tlsDone)
+	phase("ttfb_ms", start, tr.firstByte)
+	phase("transfer_ms", tr.firstByte, tr.bodyDone)
+}
+
+// formatMs renders d as fractional milliseconds with microsecond precision.
+func formatMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d.Microseconds())/1000, 'f', 3, 64)
 }
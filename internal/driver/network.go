@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// ipFamilies are the values of network.ip_family; "" is treated as "any".
+var ipFamilies = []string{"any", "ipv4", "ipv6"}
+
+// ipNetwork narrows a "tcp" or "udp" network to family, so that only
+// addresses of that IP version are dialed.
+func ipNetwork(network, family string) string {
+	switch family {
+	case "ipv4":
+		return network + "4"
+	case "ipv6":
+		return network + "6"
+	default:
+		return network
+	}
+}
+
+// familyKey maps family to one of ipFamilies, an unset or unknown value
+// to "any".
+func familyKey(family string) string {
+	switch family {
+	case "ipv4", "ipv6":
+		return family
+	default:
+		return "any"
+	}
+}
+
+// hostDialer dials with a target's network settings: only addresses of
+// family, hosts in resolve connected to their pinned IP, and other hosts
+// looked up by the resolver set in net, if any. With proxies set, TCP
+// connections go through the pool.
+type hostDialer struct {
+	family  string
+	resolve map[string]string // lowercased host → IP
+	net     net.Dialer
+	proxies *ProxyPool
+}
+
+func (d *hostDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		if ip, ok := d.resolve[strings.ToLower(host)]; ok {
+			addr = net.JoinHostPort(ip, port)
+		}
+	}
+	if d.proxies != nil && strings.HasPrefix(network, "tcp") {
+		return d.proxies.DialContext(ctx, "tcp", addr, d.family, d.net.Resolver)
+	}
+	return d.net.DialContext(ctx, ipNetwork(network, d.family), addr)
+}
+
+// dialFunc returns a DialContext that dials only addresses of family,
+// through proxies when it is not nil.
+func dialFunc(family string, proxies *ProxyPool) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return (&hostDialer{family: family, proxies: proxies}).DialContext
+}
+
+// dnsResolver returns a resolver that sends every lookup to server
+// (host:port) instead of the system's configured servers.
+func dnsResolver(server string) *net.Resolver {
+	var d net.Dialer
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package driver
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+)
+
+// maxConsoleErrorLen bounds the console error message kept in a Result.
+const maxConsoleErrorLen = 200
+
+// pageErrors counts, from the DevTools events of one browser task, the
+// JavaScript errors the page logged or threw and the requests it made that
+// failed or got an error status.
+type pageErrors struct {
+	mu             sync.Mutex
+	consoleErrors  int
+	failedRequests int
+	first          string
+}
+
+// listen is a chromedp.ListenTarget callback.
+func (p *pageErrors) listen(ev any) {
+	switch ev := ev.(type) {
+	case *runtime.EventConsoleAPICalled:
+		if ev.Type != runtime.APITypeError {
+			return
+		}
+		parts := make([]string, 0, len(ev.Args))
+		for _, a := range ev.Args {
+			parts = append(parts, remoteObjectText(a))
+		}
+		p.consoleError(strings.Join(parts, " "))
+	case *runtime.EventExceptionThrown:
+		d := ev.ExceptionDetails
+		msg := d.Text
+		if d.Exception != nil && d.Exception.Description != "" {
+			// The description holds the error and its stack; keep the error.
+			msg, _, _ = strings.Cut(d.Exception.Description, "\n")
+		}
+		p.consoleError(msg)
+	case *network.EventLoadingFailed:
+		// Requests the page itself cancelled, such as on navigation, are
+		// not failures.
+		if !ev.Canceled {
+			p.failedRequest()
+		}
+	case *network.EventResponseReceived:
+		if ev.Response != nil && ev.Response.Status >= 400 {
+			p.failedRequest()
+		}
+	}
+}
+
+func (p *pageErrors) consoleError(msg string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.consoleErrors++
+	if p.first == "" {
+		if len(msg) > maxConsoleErrorLen {
+			msg = msg[:maxConsoleErrorLen]
+		}
+		p.first = msg
+	}
+}
+
+func (p *pageErrors) failedRequest() {
+	p.mu.Lock()
+	p.failedRequests++
+	p.mu.Unlock()
+}
+
+// counts returns the console errors, failed requests, and first console
+// error message seen so far.
+func (p *pageErrors) counts() (consoleErrors, failedRequests int, first string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.consoleErrors, p.failedRequests, p.first
+}
+
+// remoteObjectText returns a console argument as the console would print
+// it: strings unquoted, other values by their description.
+func remoteObjectText(o *runtime.RemoteObject) string {
+	if o.Type == runtime.TypeString {
+		var s string
+		if err := json.Unmarshal(o.Value, &s); err == nil {
+			return s
+		}
+	}
+	if o.Description != "" {
+		return o.Description
+	}
+	if o.UnserializableValue != "" {
+		return string(o.UnserializableValue)
+	}
+	return string(o.Value)
+}
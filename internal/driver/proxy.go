@@ -0,0 +1,208 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyPool routes TCP connections through a pool of SOCKS5 proxies,
+// picking one per connection by round robin or at random. Proxies that
+// fail a health check are left out of rotation until they pass one again.
+type ProxyPool struct {
+	proxies  []*socksProxy
+	random   bool
+	next     atomic.Uint64
+	interval time.Duration
+	timeout  time.Duration
+}
+
+type socksProxy struct {
+	name string // URL with any password redacted, for logs
+	addr string
+	// remoteDNS is set for socks5h://, where the proxy resolves hostnames;
+	// with socks5:// they are resolved locally first.
+	remoteDNS bool
+	auth      bool
+	dialer    proxy.ContextDialer
+	healthy   atomic.Bool
+}
+
+// NewProxyPool builds a pool from cfg.Proxies. It returns nil when no
+// proxies are configured.
+func NewProxyPool(cfg config.NetworkConfig) (*ProxyPool, error) {
+	if len(cfg.Proxies) == 0 {
+		return nil, nil
+	}
+	p := &ProxyPool{
+		random:   cfg.ProxySelection == "random",
+		interval: time.Duration(cfg.ProxyHealthCheck.IntervalS) * time.Second,
+		timeout:  time.Duration(cfg.ProxyHealthCheck.TimeoutMs) * time.Millisecond,
+	}
+	for _, raw := range cfg.Proxies {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		var auth *proxy.Auth
+		if u.User != nil {
+			pass, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: pass}
+		}
+		d, err := proxy.SOCKS5("tcp", u.Host, auth, &net.Dialer{})
+		if err != nil {
+			return nil, fmt.Errorf("proxy %s: %w", u.Redacted(), err)
+		}
+		sp := &socksProxy{
+			name:      u.Redacted(),
+			addr:      u.Host,
+			remoteDNS: u.Scheme == "socks5h",
+			auth:      auth != nil,
+			dialer:    d.(proxy.ContextDialer),
+		}
+		sp.healthy.Store(true)
+		p.proxies = append(p.proxies, sp)
+	}
+	return p, nil
+}
+
+// Start runs the health checks until ctx is cancelled. It does nothing
+// when checks are disabled.
+func (p *ProxyPool) Start(ctx context.Context) {
+	if p == nil || p.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			p.checkAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// DialContext connects to addr through the next proxy. Unless the proxy
+// resolves hostnames itself, the host is looked up with resolver (nil for
+// the system resolver), restricted to family.
+func (p *ProxyPool) DialContext(ctx context.Context, network, addr, family string, resolver *net.Resolver) (net.Conn, error) {
+	sp := p.pick()
+	if !sp.remoteDNS {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if net.ParseIP(host) == nil {
+			if resolver == nil {
+				resolver = net.DefaultResolver
+			}
+			ips, err := resolver.LookupNetIP(ctx, ipNetwork("ip", family), host)
+			if err != nil {
+				return nil, err
+			}
+			if len(ips) == 0 {
+				return nil, &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+			}
+			addr = net.JoinHostPort(ips[0].Unmap().String(), port)
+		}
+	}
+	conn, err := sp.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("via proxy %s: %w", sp.name, err)
+	}
+	return conn, nil
+}
+
+// pick returns the next proxy in rotation, or the next of all proxies
+// when none is healthy, so that traffic never falls back to dialing
+// directly.
+func (p *ProxyPool) pick() *socksProxy {
+	candidates := make([]*socksProxy, 0, len(p.proxies))
+	for _, sp := range p.proxies {
+		if sp.healthy.Load() {
+			candidates = append(candidates, sp)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.proxies
+	}
+	if p.random {
+		return candidates[rand.Intn(len(candidates))] //nolint:gosec
+	}
+	return candidates[(p.next.Add(1)-1)%uint64(len(candidates))]
+}
+
+// checkAll checks every proxy concurrently and logs changes in health.
+func (p *ProxyPool) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, sp := range p.proxies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := sp.check(ctx, p.timeout)
+			if ctx.Err() != nil {
+				return
+			}
+			ok := err == nil
+			if sp.healthy.Swap(ok) == ok {
+				return
+			}
+			if ok {
+				log.Info().Str("proxy", sp.name).Msg("proxy passed health check, back in rotation")
+			} else {
+				log.Warn().Err(err).Str("proxy", sp.name).Msg("proxy failed health check, removed from rotation")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// check opens a connection to the proxy and completes the SOCKS5 method
+// negotiation (RFC 1928, section 3), offering the method the pool would
+// use.
+func (sp *socksProxy) check(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", sp.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	method := byte(0x00) // no authentication required
+	if sp.auth {
+		method = 0x02 // username/password
+	}
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("not a SOCKS5 server (version %d)", reply[0])
+	}
+	if reply[1] != method {
+		return fmt.Errorf("proxy refused authentication method %d", method)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package driver
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// newRequestID returns a fresh ID for one request and the value that carries
+// it in header. For the W3C traceparent header the value is a full trace
+// context and the ID its trace-id, which is what tracing backends index;
+// any other header gets a random UUID as both.
+func newRequestID(header string) (value, id string) {
+	if !strings.EqualFold(header, "traceparent") {
+		id = uuid.NewString()
+		return id, id
+	}
+	var b [24]byte
+	_, _ = rand.Read(b[:])
+	id = hex.EncodeToString(b[:16])
+	return "00-" + id + "-" + hex.EncodeToString(b[16:]) + "-01", id
+}
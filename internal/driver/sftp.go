@@ -43,17 +43,33 @@ type sftpConnection struct {
 	ssh          *ssh.Client
 	client       *sftp.Client
 	authMaterial string
+	raw          net.Conn
 }
 
 // SFTPDriver executes upload, download, and list operations over SFTP.
 type SFTPDriver struct {
-	mu    sync.Mutex
-	conns map[string]*sftpConnection
+	mu      sync.Mutex
+	conns   map[string]*sftpConnection
+	proxies *ProxyPool
+	tracker *connTracker
 }
 
 // NewSFTPDriver creates an SFTP driver with a shared connection cache.
 func NewSFTPDriver() *SFTPDriver {
-	return &SFTPDriver{conns: make(map[string]*sftpConnection)}
+	return &SFTPDriver{conns: make(map[string]*sftpConnection), tracker: newConnTracker()}
+}
+
+// ConnStats reports how operations have used the driver's cached
+// connections.
+func (d *SFTPDriver) ConnStats() ConnStats {
+	return d.tracker.stats()
+}
+
+// SetProxies routes new connections through p. Connections are cached, so
+// a proxy is picked once per connection rather than per operation. Call it
+// before the driver is used.
+func (d *SFTPDriver) SetProxies(p *ProxyPool) {
+	d.proxies = p
 }
 
 // Execute performs the SFTP operation described by t.
@@ -78,8 +94,9 @@ func (d *SFTPDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	defer cancel()
 
 	addr := sftpAddress(u, cfg.Port)
-	cacheKey := sftpCacheKey(addr, cfg)
-	conn, err := d.getConn(callCtx, addr, cacheKey, cfg, meta)
+	family := familyKey(t.Config.Network.IPFamily)
+	cacheKey := sftpCacheKey(addr, family, cfg)
+	conn, reused, err := d.getConn(callCtx, addr, family, cacheKey, cfg, meta)
 	if err != nil {
 		return task.Result{
 			Task:       t,
@@ -88,6 +105,7 @@ func (d *SFTPDriver) Execute(ctx context.Context, t task.Task) task.Result {
 			Meta:       meta,
 		}
 	}
+	defer d.tracker.acquire(conn.raw, reused)()
 
 	operation := cfg.Operation
 	if operation == "" {
@@ -130,7 +148,9 @@ func (d *SFTPDriver) Execute(ctx context.Context, t task.Task) task.Result {
 	}
 }
 
-func (d *SFTPDriver) getConn(ctx context.Context, addr, cacheKey string, cfg config.SFTPConfig, meta map[string]string) (*sftpConnection, error) {
+// getConn returns the cached connection for cacheKey, or dials one,
+// reporting whether it was cached.
+func (d *SFTPDriver) getConn(ctx context.Context, addr, family, cacheKey string, cfg config.SFTPConfig, meta map[string]string) (*sftpConnection, bool, error) {
 	authMaterial := sftpAuthMaterial(cfg)
 	var stale *sftpConnection
 
@@ -139,7 +159,7 @@ func (d *SFTPDriver) getConn(ctx context.Context, addr, cacheKey string, cfg con
 		if conn.authMaterial == authMaterial {
 			populateCachedSFTPMetadata(conn, cfg, meta)
 			d.mu.Unlock()
-			return conn, nil
+			return conn, true, nil
 		}
 		stale = conn
 		delete(d.conns, cacheKey)
@@ -153,19 +173,18 @@ func (d *SFTPDriver) getConn(ctx context.Context, addr, cacheKey string, cfg con
 
 	sshCfg, err := sftpSSHConfig(cfg, meta)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	var dialer net.Dialer
-	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	rawConn, err := d.tracker.dial(dialFunc(family, d.proxies))(ctx, "tcp", addr)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	sshConn, chans, reqs, err := ssh.NewClientConn(rawConn, addr, sshCfg)
 	if err != nil {
 		_ = rawConn.Close()
-		return nil, err
+		return nil, false, err
 	}
 
 	sshClient := ssh.NewClient(sshConn, chans, reqs)
@@ -174,14 +193,14 @@ func (d *SFTPDriver) getConn(ctx context.Context, addr, cacheKey string, cfg con
 	sftpClient, err := sftp.NewClient(sshClient)
 	if err != nil {
 		_ = sshClient.Close()
-		return nil, err
+		return nil, false, err
 	}
 
-	conn := &sftpConnection{ssh: sshClient, client: sftpClient, authMaterial: authMaterial}
+	conn := &sftpConnection{ssh: sshClient, client: sftpClient, authMaterial: authMaterial, raw: rawConn}
 	d.mu.Lock()
 	d.conns[cacheKey] = conn
 	d.mu.Unlock()
-	return conn, nil
+	return conn, false, nil
 }
 
 func (d *SFTPDriver) evict(cacheKey string) {
@@ -407,9 +426,10 @@ func populateCachedSFTPMetadata(conn *sftpConnection, cfg config.SFTPConfig, met
 	}
 }
 
-func sftpCacheKey(addr string, cfg config.SFTPConfig) string {
+func sftpCacheKey(addr, family string, cfg config.SFTPConfig) string {
 	var b strings.Builder
 	writeCachePart(&b, addr)
+	writeCachePart(&b, family)
 	writeCachePart(&b, cfg.Username)
 	writeCachePart(&b, sftpAuthMethod(cfg))
 	writeCachePart(&b, strconv.FormatBool(cfg.Insecure))
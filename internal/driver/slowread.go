@@ -0,0 +1,51 @@
+package driver
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// maxSlowReadChunk bounds how much of a throttled body is read at once, so
+// that the bytes trickle in rather than arrive in bursts.
+const maxSlowReadChunk = 16 << 10
+
+// slowReader reads r no faster than a rate in bytes per second, for
+// http.read_rate_bps.
+type slowReader struct {
+	ctx context.Context
+	r   io.Reader
+	lim *rate.Limiter
+}
+
+// newSlowReader limits reads of r to bps bytes per second, in chunks of
+// about a tenth of a second's worth.
+func newSlowReader(ctx context.Context, r io.Reader, bps int) *slowReader {
+	chunk := min(max(bps/10, 1), maxSlowReadChunk)
+	return &slowReader{ctx: ctx, r: r, lim: rate.NewLimiter(rate.Limit(bps), chunk)}
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	if len(p) > s.lim.Burst() {
+		p = p[:s.lim.Burst()]
+	}
+	n, err := s.r.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	// Unlike WaitN, which gives up at once on a wait that would pass the
+	// deadline, sleep until the deadline so the read lasts as long as
+	// timeout_s allows.
+	if d := s.lim.ReserveN(time.Now(), n).Delay(); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-s.ctx.Done():
+			return n, s.ctx.Err()
+		}
+	}
+	return n, err
+}
@@ -3,6 +3,9 @@ package driver
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"slices"
 	"time"
 
 	"github.com/coder/websocket"
@@ -10,13 +13,48 @@ import (
 )
 
 // WebSocketDriver connects to a WebSocket endpoint, sends messages, and waits.
-type WebSocketDriver struct{}
+type WebSocketDriver struct {
+	// clients dial the handshake, per IP family; SetProxies replaces them.
+	clients map[string]*http.Client
+}
 
 // NewWebSocketDriver creates a WebSocketDriver.
 func NewWebSocketDriver() *WebSocketDriver {
-	return &WebSocketDriver{}
+	d := &WebSocketDriver{clients: make(map[string]*http.Client)}
+	for _, family := range ipFamilies {
+		d.clients[family] = wsClient(family, nil)
+	}
+	return d
+}
+
+// SetProxies routes every connection through p. Call it before the driver
+// is used.
+func (d *WebSocketDriver) SetProxies(p *ProxyPool) {
+	for _, family := range ipFamilies {
+		d.clients[family] = wsClient(family, p)
+	}
 }
 
+func wsClient(family string, proxies *ProxyPool) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if proxies != nil {
+		tr.Proxy = nil // network.proxies replaces HTTP_PROXY and friends
+	}
+	dial := dialFunc(family, proxies)
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if h, ok := ctx.Value(rawConnKey{}).(*net.Conn); ok && err == nil {
+			*h = conn
+		}
+		return conn, err
+	}
+	return &http.Client{Transport: tr}
+}
+
+// rawConnKey is the context key under which Execute asks the dialer for
+// the connection under the WebSocket, to reset it.
+type rawConnKey struct{}
+
 // Execute opens a WebSocket connection, sends configured messages, optionally
 // waits for expected messages, then holds the connection for duration_s.
 func (d *WebSocketDriver) Execute(ctx context.Context, t task.Task) task.Result {
@@ -32,31 +70,56 @@ func (d *WebSocketDriver) Execute(ctx context.Context, t task.Task) task.Result
 
 	start := time.Now()
 
-	dialOpts := &websocket.DialOptions{}
+	dialOpts := &websocket.DialOptions{HTTPClient: d.clients[familyKey(t.Config.Network.IPFamily)]}
 	if hdrs, err := authHeaders(t.Config.Auth); err != nil {
 		return task.Result{Task: t, Duration: time.Since(start), Error: err}
 	} else if hdrs != nil {
 		dialOpts.HTTPHeader = hdrs
 	}
 
-	conn, _, err := websocket.Dial(connCtx, t.URL, dialOpts)
+	var raw net.Conn
+	conn, _, err := websocket.Dial(context.WithValue(connCtx, rawConnKey{}, &raw), t.URL, dialOpts)
 	if err != nil {
 		return task.Result{Task: t, Duration: time.Since(start), Error: fmt.Errorf("dialing: %w", err)}
 	}
 	defer conn.CloseNow() //nolint:errcheck
 
-	// Send configured messages.
+	received := 0
+	readCtx, readCancel := context.WithTimeout(connCtx, time.Duration(durationS)*time.Second)
+	defer readCancel()
+
+	// Send configured messages, with measure_echo each after the previous
+	// one has come back.
+	var echo *task.EchoStats
+	var rtts []time.Duration
 	for _, msg := range cfg.SendMessages {
+		sent := time.Now()
 		if err := conn.Write(connCtx, websocket.MessageText, []byte(msg)); err != nil {
 			return task.Result{Task: t, Duration: time.Since(start), Error: fmt.Errorf("sending message: %w", err)}
 		}
+		if !cfg.MeasureEcho {
+			continue
+		}
+		if echo == nil {
+			echo = &task.EchoStats{}
+		}
+		echo.Sent++
+		n, ok := awaitEcho(readCtx, conn, msg)
+		received += n
+		if !ok {
+			break // no echo before the deadline; later ones would not fit either
+		}
+		rtts = append(rtts, time.Since(sent))
+	}
+	if echo != nil {
+		echo.Echoed = len(rtts)
+		if len(rtts) > 0 {
+			slices.Sort(rtts)
+			echo.P50, echo.P95 = nearestRank(rtts, 50), nearestRank(rtts, 95)
+		}
 	}
 
 	// Read expected messages.
-	received := 0
-	readCtx, readCancel := context.WithTimeout(connCtx, time.Duration(durationS)*time.Second)
-	defer readCancel()
-
 	for received < cfg.ExpectMessages {
 		_, _, err := conn.Read(readCtx)
 		if err != nil {
@@ -71,11 +134,68 @@ func (d *WebSocketDriver) Execute(ctx context.Context, t task.Task) task.Result
 
 	<-holdCtx.Done()
 
-	conn.Close(websocket.StatusNormalClosure, "done") //nolint:errcheck,gosec
+	closeMode := cfg.Close
+	if len(cfg.CloseMix) > 0 {
+		closeMode = pickWeighted(cfg.CloseMix)
+	}
+	switch closeMode {
+	case "going_away":
+		conn.Close(websocket.StatusGoingAway, "going away") //nolint:errcheck,gosec
+	case "reset":
+		resetConn(raw)
+	default:
+		closeMode = "normal"
+		conn.Close(websocket.StatusNormalClosure, "done") //nolint:errcheck,gosec
+	}
 
 	return task.Result{
 		Task:       t,
 		StatusCode: 101, // Switching Protocols — connection established
 		Duration:   time.Since(start),
+		Echo:       echo,
+		Meta:       map[string]string{"ws_close": closeMode},
 	}
 }
+
+// resetConn closes the TCP connection under conn, which may be wrapped in
+// TLS, with a reset instead of a FIN, so that the server sees the client
+// vanish mid-stream. Connections that are not TCP, such as those through
+// some proxies, are closed normally.
+func resetConn(conn net.Conn) {
+	for conn != nil {
+		if tc, ok := conn.(*net.TCPConn); ok {
+			tc.SetLinger(0) //nolint:errcheck,gosec
+			break
+		}
+		nc, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			break
+		}
+		conn = nc.NetConn()
+	}
+	if conn != nil {
+		conn.Close() //nolint:errcheck,gosec
+	}
+}
+
+// awaitEcho reads messages until one equal to msg arrives, returning how
+// many it read and whether the echo was among them.
+func awaitEcho(ctx context.Context, conn *websocket.Conn, msg string) (int, bool) {
+	n := 0
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return n, false
+		}
+		n++
+		if string(data) == msg {
+			return n, true
+		}
+	}
+}
+
+// nearestRank returns the nearest-rank p-th percentile of sorted.
+func nearestRank(sorted []time.Duration, p int) time.Duration {
+	rank := (len(sorted)*p + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
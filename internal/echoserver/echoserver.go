@@ -0,0 +1,344 @@
+// Package echoserver is a self-contained HTTP, WebSocket, and DNS target
+// for `sendit serve`: demos, docs, and integration tests can aim sendit at
+// it without depending on external services. Every protocol can add a
+// fixed latency plus random jitter and answer a share of requests with an
+// error.
+package echoserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/miekg/dns"
+	"github.com/rs/zerolog/log"
+)
+
+// maxEchoBytes caps how much of a request body is echoed back.
+const maxEchoBytes = 64 * 1024
+
+// Options configures the listeners and the injected latency and errors.
+type Options struct {
+	// HTTPAddr is the TCP address for HTTP and WebSocket; "" disables it.
+	HTTPAddr string
+	// DNSAddr is the UDP address for DNS; "" disables it.
+	DNSAddr string
+	// Latency is added before every response; Jitter adds a further random
+	// delay in [0, Jitter).
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate is the percentage (0–100) of requests answered with an
+	// error: ErrorStatus for HTTP and WebSocket handshakes, SERVFAIL for DNS.
+	ErrorRate   float64
+	ErrorStatus int
+}
+
+// Server is a set of bound echo listeners. Create it with Listen, then call
+// Serve.
+type Server struct {
+	opts   Options
+	httpLn net.Listener
+	dnsPC  net.PacketConn
+
+	mu  sync.Mutex // guards rnd
+	rnd *rand.Rand
+}
+
+// Listen validates opts and binds the enabled listeners, so that their
+// addresses are known (and port conflicts reported) before Serve.
+func Listen(opts Options) (*Server, error) {
+	if opts.HTTPAddr == "" && opts.DNSAddr == "" {
+		return nil, errors.New("at least one of the HTTP and DNS listeners must be enabled")
+	}
+	if opts.ErrorRate < 0 || opts.ErrorRate > 100 {
+		return nil, fmt.Errorf("error rate must be between 0 and 100, got %g", opts.ErrorRate)
+	}
+	if opts.Latency < 0 || opts.Jitter < 0 {
+		return nil, errors.New("latency and jitter must not be negative")
+	}
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = http.StatusInternalServerError
+	}
+	if opts.ErrorStatus < 400 || opts.ErrorStatus > 599 {
+		return nil, fmt.Errorf("error status must be between 400 and 599, got %d", opts.ErrorStatus)
+	}
+
+	s := &Server{opts: opts, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))} //nolint:gosec // not security sensitive
+	var err error
+	if opts.HTTPAddr != "" {
+		if s.httpLn, err = net.Listen("tcp", opts.HTTPAddr); err != nil {
+			return nil, fmt.Errorf("http listener: %w", err)
+		}
+	}
+	if opts.DNSAddr != "" {
+		if s.dnsPC, err = net.ListenPacket("udp", opts.DNSAddr); err != nil {
+			if s.httpLn != nil {
+				_ = s.httpLn.Close()
+			}
+			return nil, fmt.Errorf("dns listener: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// HTTPAddr returns the bound HTTP/WebSocket address, or "" when disabled.
+func (s *Server) HTTPAddr() string {
+	if s.httpLn == nil {
+		return ""
+	}
+	return s.httpLn.Addr().String()
+}
+
+// DNSAddr returns the bound DNS address, or "" when disabled.
+func (s *Server) DNSAddr() string {
+	if s.dnsPC == nil {
+		return ""
+	}
+	return s.dnsPC.LocalAddr().String()
+}
+
+// Serve answers requests until ctx is cancelled, then shuts the listeners
+// down.
+func (s *Server) Serve(ctx context.Context) error {
+	errc := make(chan error, 2)
+	var servers int
+
+	var httpSrv *http.Server
+	if s.httpLn != nil {
+		servers++
+		httpSrv = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := httpSrv.Serve(s.httpLn); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errc <- fmt.Errorf("http: %w", err)
+				return
+			}
+			errc <- nil
+		}()
+	}
+	var dnsSrv *dns.Server
+	if s.dnsPC != nil {
+		servers++
+		// Shutting a dns.Server down before it has started fails and leaves
+		// it running, so wait for it to start first.
+		started := make(chan struct{})
+		dnsSrv = &dns.Server{
+			PacketConn:        s.dnsPC,
+			Net:               "udp",
+			Handler:           dns.HandlerFunc(s.serveDNS),
+			NotifyStartedFunc: func() { close(started) },
+		}
+		go func() {
+			if err := dnsSrv.ActivateAndServe(); err != nil {
+				errc <- fmt.Errorf("dns: %w", err)
+				return
+			}
+			errc <- nil
+		}()
+		select {
+		case <-started:
+		case err := <-errc:
+			servers--
+			if httpSrv != nil {
+				_ = httpSrv.Close()
+				<-errc
+			}
+			return err
+		}
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case err = <-errc:
+		servers--
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if httpSrv != nil {
+		_ = httpSrv.Shutdown(shutdownCtx)
+	}
+	if dnsSrv != nil {
+		_ = dnsSrv.ShutdownContext(shutdownCtx)
+	}
+	for ; servers > 0; servers-- {
+		if e := <-errc; err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// echoResponse is the JSON body returned for plain HTTP requests.
+type echoResponse struct {
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query,omitempty"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body,omitempty"`
+	Remote  string              `json:"remote"`
+}
+
+// Handler returns the HTTP handler:
+//
+//	/status/<code>  respond with that status code
+//	/delay/<dur>    wait for <dur> (a Go duration or seconds) before responding
+//	anything else   echo the request back as JSON
+//
+// Requests carrying a WebSocket upgrade, on any path, are accepted and every
+// message is echoed back until the client closes the connection.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.delay(r.Context()) {
+			return
+		}
+		if s.fail() {
+			log.Debug().Str("method", r.Method).Str("path", r.URL.Path).Int("status", s.opts.ErrorStatus).Msg("echo: injected error")
+			http.Error(w, http.StatusText(s.opts.ErrorStatus), s.opts.ErrorStatus)
+			return
+		}
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			s.serveWebSocket(w, r)
+			return
+		}
+
+		status := http.StatusOK
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/status/"):
+			code, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/status/"))
+			if err != nil || code < 100 || code > 599 {
+				http.Error(w, "status must be a number between 100 and 599", http.StatusBadRequest)
+				return
+			}
+			status = code
+		case strings.HasPrefix(r.URL.Path, "/delay/"):
+			d, err := parseDelay(strings.TrimPrefix(r.URL.Path, "/delay/"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			select {
+			case <-time.After(d):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		body, _ := io.ReadAll(io.LimitReader(r.Body, maxEchoBytes))
+		log.Debug().Str("method", r.Method).Str("path", r.URL.Path).Int("status", status).Msg("echo: http")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(echoResponse{
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   r.URL.RawQuery,
+			Headers: r.Header,
+			Body:    string(body),
+			Remote:  r.RemoteAddr,
+		})
+	})
+}
+
+// parseDelay accepts a Go duration ("250ms") or a number of seconds ("2").
+func parseDelay(v string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("delay %q is not a duration or a number of seconds", v)
+	}
+	return d, nil
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	log.Debug().Str("path", r.URL.Path).Msg("echo: websocket connected")
+	ctx := r.Context()
+	for {
+		typ, msg, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		if !s.delay(ctx) {
+			return
+		}
+		if err := conn.Write(ctx, typ, msg); err != nil {
+			return
+		}
+	}
+}
+
+// serveDNS answers A with 127.0.0.1, AAAA with ::1, and TXT with a fixed
+// string for any name; other types get an empty NOERROR answer.
+func (s *Server) serveDNS(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+	if !s.delay(context.Background()) {
+		return
+	}
+	if s.fail() {
+		m.Rcode = dns.RcodeServerFailure
+		_ = w.WriteMsg(m)
+		return
+	}
+	for _, q := range req.Question {
+		hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 60}
+		switch q.Qtype {
+		case dns.TypeA:
+			m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: net.IPv4(127, 0, 0, 1)})
+		case dns.TypeAAAA:
+			m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.IPv6loopback})
+		case dns.TypeTXT:
+			m.Answer = append(m.Answer, &dns.TXT{Hdr: hdr, Txt: []string{"sendit echo server"}})
+		}
+		log.Debug().Str("name", q.Name).Str("type", dns.TypeToString[q.Qtype]).Msg("echo: dns")
+	}
+	_ = w.WriteMsg(m)
+}
+
+// delay sleeps for the configured latency plus jitter. It returns false if
+// ctx ended first.
+func (s *Server) delay(ctx context.Context) bool {
+	d := s.opts.Latency
+	if s.opts.Jitter > 0 {
+		s.mu.Lock()
+		d += time.Duration(s.rnd.Int63n(int64(s.opts.Jitter)))
+		s.mu.Unlock()
+	}
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// fail reports whether this request should get an injected error.
+func (s *Server) fail() bool {
+	if s.opts.ErrorRate <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rnd.Float64()*100 < s.opts.ErrorRate
+}
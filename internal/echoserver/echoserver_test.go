@@ -0,0 +1,139 @@
+package echoserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/miekg/dns"
+)
+
+func startServer(t *testing.T, opts Options) *Server {
+	t.Helper()
+	s, err := Listen(opts)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	})
+	return s
+}
+
+func TestServer_HTTPEchoAndStatus(t *testing.T) {
+	s := startServer(t, Options{HTTPAddr: "127.0.0.1:0"})
+	base := "http://" + s.HTTPAddr()
+
+	resp, err := http.Post(base+"/hello?x=1", "text/plain", strings.NewReader("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var echo echoResponse
+	err = json.NewDecoder(resp.Body).Decode(&echo)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decoding echo: %v", err)
+	}
+	if resp.StatusCode != 200 || echo.Method != "POST" || echo.Path != "/hello" || echo.Query != "x=1" || echo.Body != "ping" {
+		t.Errorf("echo = %d %+v", resp.StatusCode, echo)
+	}
+
+	resp, err = http.Get(base + "/status/418")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 418 {
+		t.Errorf("/status/418 returned %d", resp.StatusCode)
+	}
+
+	start := time.Now()
+	resp, err = http.Get(base + "/delay/100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("/delay/100ms answered after %s", elapsed)
+	}
+}
+
+func TestServer_InjectedLatencyAndErrors(t *testing.T) {
+	s := startServer(t, Options{HTTPAddr: "127.0.0.1:0", Latency: 50 * time.Millisecond, ErrorRate: 100, ErrorStatus: 503})
+	start := time.Now()
+	resp, err := http.Get("http://" + s.HTTPAddr() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 503 {
+		t.Errorf("status = %d, want 503", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("answered after %s, want at least the 50ms latency", elapsed)
+	}
+}
+
+func TestServer_WebSocketEcho(t *testing.T) {
+	s := startServer(t, Options{HTTPAddr: "127.0.0.1:0"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws://"+s.HTTPAddr()+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.CloseNow()
+	if err := conn.Write(ctx, websocket.MessageText, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := conn.Read(ctx); err != nil || string(msg) != "hi" {
+		t.Errorf("echo = %q, %v", msg, err)
+	}
+	_ = conn.Close(websocket.StatusNormalClosure, "")
+}
+
+func TestServer_DNS(t *testing.T) {
+	s := startServer(t, Options{DNSAddr: "127.0.0.1:0"})
+	m := new(dns.Msg)
+	m.SetQuestion("anything.example.", dns.TypeA)
+	r, _, err := new(dns.Client).Exchange(m, s.DNSAddr())
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if r.Rcode != dns.RcodeSuccess || len(r.Answer) != 1 || r.Answer[0].(*dns.A).A.String() != "127.0.0.1" {
+		t.Errorf("answer = %v", r)
+	}
+
+	failing := startServer(t, Options{DNSAddr: "127.0.0.1:0", ErrorRate: 100})
+	r, _, err = new(dns.Client).Exchange(m, failing.DNSAddr())
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if r.Rcode != dns.RcodeServerFailure {
+		t.Errorf("rcode = %s, want SERVFAIL", dns.RcodeToString[r.Rcode])
+	}
+}
+
+func TestListen_Validation(t *testing.T) {
+	for _, opts := range []Options{
+		{},
+		{HTTPAddr: "127.0.0.1:0", ErrorRate: 101},
+		{HTTPAddr: "127.0.0.1:0", Latency: -time.Second},
+		{HTTPAddr: "127.0.0.1:0", ErrorStatus: 200},
+	} {
+		if s, err := Listen(opts); err == nil {
+			_ = s.httpLn.Close()
+			t.Errorf("Listen(%+v): want error", opts)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/resource"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// autoscaleInterval is how often the browser limit is reconsidered.
+	autoscaleInterval = 5 * time.Second
+	// defaultBrowserMB is the memory a browser is assumed to use until one
+	// has been seen running.
+	defaultBrowserMB = 256.0
+)
+
+// browserAutoscaler sets the pool's browser limit from the memory that
+// running Chrome instances use: it estimates the memory per browser and
+// allows as many as fit in the budget, growing by one browser per interval
+// so that the estimate can catch up, and shrinking at once.
+type browserAutoscaler struct {
+	pool        *Pool
+	max         int
+	budgetMB    uint64 // 0 = the headroom below thresholdMB
+	thresholdMB uint64
+	perMB       float64 // estimated memory per browser
+	chromeMB    func() (uint64, error)
+	systemStats func() (cpuPercent float64, memUsedMB uint64)
+}
+
+func newBrowserAutoscaler(pool *Pool, limits config.LimitsConfig, monitor *resource.Monitor) *browserAutoscaler {
+	return &browserAutoscaler{
+		pool:        pool,
+		max:         limits.MaxBrowserWorkers,
+		budgetMB:    limits.BrowserAutoscale.MemoryBudgetMB,
+		thresholdMB: limits.MemoryThresholdMB,
+		perMB:       defaultBrowserMB,
+		chromeMB:    resource.ChildMemoryMB,
+		systemStats: monitor.Stats,
+	}
+}
+
+// Start starts with a limit of one browser and adjusts it every
+// autoscaleInterval until ctx is cancelled.
+func (a *browserAutoscaler) Start(ctx context.Context) {
+	a.pool.SetBrowserLimit(1)
+	go func() {
+		ticker := time.NewTicker(autoscaleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.adjust()
+			}
+		}
+	}()
+}
+
+func (a *browserAutoscaler) adjust() {
+	chromeMB, err := a.chromeMB()
+	if err != nil {
+		log.Debug().Err(err).Msg("browser autoscale: reading Chrome memory")
+		return
+	}
+	_, running := a.pool.InUse()
+	cur := a.pool.BrowserLimit()
+	next := a.limit(chromeMB, running, cur)
+	if next == cur {
+		return
+	}
+	a.pool.SetBrowserLimit(next)
+	log.Info().
+		Int("from", cur).
+		Int("to", next).
+		Uint64("chrome_mb", chromeMB).
+		Int("per_browser_mb", int(a.perMB)).
+		Msg("browser autoscale: browser limit changed")
+}
+
+// limit returns the browser limit to set, given the memory chromeMB that
+// running browsers use and the current limit cur.
+func (a *browserAutoscaler) limit(chromeMB uint64, running, cur int) int {
+	if running > 0 && chromeMB > 0 {
+		// Smooth the estimate, since pages differ and a browser's memory
+		// grows while its page loads.
+		a.perMB = 0.5*a.perMB + 0.5*float64(chromeMB)/float64(running)
+	}
+
+	var fits int
+	if a.budgetMB > 0 {
+		fits = int(float64(a.budgetMB) / a.perMB)
+	} else {
+		_, usedMB := a.systemStats()
+		// Used memory already counts the running browsers.
+		fits = running + int((float64(a.thresholdMB)-float64(usedMB))/a.perMB)
+	}
+
+	next := min(fits, cur+1)
+	return max(1, min(next, a.max))
+}
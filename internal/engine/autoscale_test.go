@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+func TestBrowserAutoscaler_Limit(t *testing.T) {
+	limits := config.LimitsConfig{
+		MaxBrowserWorkers: 8,
+		MemoryThresholdMB: 4096,
+		BrowserAutoscale:  config.BrowserAutoscaleConfig{Enabled: true, MemoryBudgetMB: 1024},
+	}
+	a := &browserAutoscaler{
+		max:         limits.MaxBrowserWorkers,
+		budgetMB:    limits.BrowserAutoscale.MemoryBudgetMB,
+		thresholdMB: limits.MemoryThresholdMB,
+		perMB:       defaultBrowserMB,
+	}
+
+	// Nothing running yet: grow by one per step.
+	if got := a.limit(0, 0, 1); got != 2 {
+		t.Errorf("idle limit = %d, want 2", got)
+	}
+	if got := a.limit(200, 2, 2); got != 3 {
+		t.Errorf("limit with light browsers = %d, want 3", got)
+	}
+
+	// Heavy pages: 3 browsers using 1500MB soon exceed the 1024MB budget,
+	// and the limit drops in one step.
+	a.limit(1500, 3, 3)
+	if got := a.limit(1500, 3, 3); got != 2 {
+		t.Errorf("limit with heavy browsers = %d, want 2", got)
+	}
+
+	// Never below one browser, never above max_browser_workers.
+	if got := a.limit(10000, 1, 2); got != 1 {
+		t.Errorf("limit over budget = %d, want 1", got)
+	}
+	a.perMB = 1
+	if got := a.limit(0, 0, 8); got != 8 {
+		t.Errorf("limit at max = %d, want 8", got)
+	}
+}
+
+func TestBrowserAutoscaler_LimitWithoutBudget(t *testing.T) {
+	a := &browserAutoscaler{
+		max:         10,
+		thresholdMB: 4096,
+		perMB:       256,
+		systemStats: func() (float64, uint64) { return 0, 3072 },
+	}
+	// 1024MB of headroom fits 4 more browsers beside the one running, but
+	// the limit grows by one per step.
+	if got := a.limit(256, 1, 4); got != 5 {
+		t.Errorf("limit = %d, want 5", got)
+	}
+	a.systemStats = func() (float64, uint64) { return 0, 4608 }
+	if got := a.limit(1024, 4, 5); got != 2 {
+		t.Errorf("limit above threshold = %d, want 2", got)
+	}
+}
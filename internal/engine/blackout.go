@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// blackoutRecheck bounds how long the dispatch loop sleeps in a blackout
+// before looking again, so that a reload that ends it early takes effect.
+var blackoutRecheck = 5 * time.Second
+
+// blackout is one period from config.Blackouts: fixed from start to end,
+// or recurring for d after each firing of sched.
+type blackout struct {
+	name       string
+	start, end time.Time
+	sched      cron.Schedule
+	d          time.Duration
+}
+
+// blackouts are the periods in which no requests are sent.
+type blackouts []blackout
+
+// newBlackouts builds the blackouts from config, which validate has
+// checked; an entry that does not parse is skipped.
+func newBlackouts(cfg []config.BlackoutConfig) *blackouts {
+	bs := make(blackouts, 0, len(cfg))
+	for i, c := range cfg {
+		b := blackout{name: c.Name}
+		if b.name == "" {
+			b.name = fmt.Sprintf("blackouts[%d]", i)
+		}
+		var err error
+		if c.Cron != "" {
+			b.sched, err = cron.ParseStandard(c.Cron)
+			b.d = time.Duration(c.DurationMinutes) * time.Minute
+		} else {
+			b.start, b.end, err = c.Range()
+		}
+		if err != nil {
+			log.Error().Err(err).Str("blackout", b.name).Msg("invalid blackout, ignoring it")
+			continue
+		}
+		bs = append(bs, b)
+	}
+	return &bs
+}
+
+// at returns the blackout in effect at now and when it ends; of several,
+// the one that ends last.
+func (bs blackouts) at(now time.Time) (name string, until time.Time, ok bool) {
+	for _, b := range bs {
+		end := b.end
+		if b.sched != nil {
+			at, open := lastFiringWithin(b.sched, b.d, now)
+			if !open {
+				continue
+			}
+			end = at.Add(b.d)
+		} else if now.Before(b.start) || !now.Before(b.end) {
+			continue
+		}
+		if end.After(until) {
+			name, until, ok = b.name, end, true
+		}
+	}
+	return name, until, ok
+}
+
+// Blackout returns the blackout in effect, if any, and when it ends.
+func (e *Engine) Blackout() (name string, until time.Time, ok bool) {
+	return e.blackouts.Load().at(time.Now())
+}
+
+// waitOutBlackout blocks while a blackout is in effect or until ctx is
+// done.
+func (e *Engine) waitOutBlackout(ctx context.Context) error {
+	for {
+		name, until, ok := e.Blackout()
+		if !ok {
+			if e.inBlackout.CompareAndSwap(true, false) {
+				log.Info().Msg("blackout over, dispatch resuming")
+			}
+			return nil
+		}
+		if e.inBlackout.CompareAndSwap(false, true) {
+			log.Info().Str("blackout", name).Time("until", until).Msg("blackout started, dispatch held")
+		}
+		select {
+		case <-time.After(min(time.Until(until), blackoutRecheck)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/ratelimit"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// crawlDelayAgent is the product token matched against robots.txt
+	// User-agent groups, and sent when fetching robots.txt.
+	crawlDelayAgent = "sendit"
+	// robotsTimeout bounds the robots.txt fetch for one host.
+	robotsTimeout = 10 * time.Second
+	// robotsRetry is how long a host whose robots.txt could not be fetched
+	// goes without a delay before the fetch is tried again.
+	robotsRetry = time.Minute
+	// maxRobotsBytes caps how much of a robots.txt is read.
+	maxRobotsBytes = 512 << 10
+)
+
+// crawlDelays fetches each host's robots.txt the first time an http or
+// browser target visits it with rate_limits.honor_crawl_delay set, and
+// remembers its Crawl-delay for the rest of the run. A fetch that fails is
+// tried again after robotsRetry.
+type crawlDelays struct {
+	// client returns the HTTP client for a target's requests, so that
+	// robots.txt goes through the same proxies, IP family, and TLS settings.
+	client func(config.TargetConfig) *http.Client
+	mu     sync.Mutex
+	hosts  map[string]*crawlDelay
+}
+
+// crawlDelay is the Crawl-delay of one host. mu is held while its
+// robots.txt is fetched, so that concurrent requests to the host wait for
+// one fetch rather than each sending their own.
+type crawlDelay struct {
+	mu      sync.Mutex
+	fetched bool
+	d       time.Duration
+	retryAt time.Time // after a failed fetch, when to try again
+}
+
+func newCrawlDelays(client func(config.TargetConfig) *http.Client) *crawlDelays {
+	return &crawlDelays{
+		client: client,
+		hosts:  make(map[string]*crawlDelay),
+	}
+}
+
+// delay returns the Crawl-delay of rawURL's host, fetching its robots.txt
+// with the client of target t if it has not been yet; 0 when it sets none
+// or cannot be fetched.
+func (c *crawlDelays) delay(ctx context.Context, rawURL string, t config.TargetConfig) time.Duration {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return 0
+	}
+	c.mu.Lock()
+	cd, ok := c.hosts[u.Host]
+	if !ok {
+		cd = &crawlDelay{}
+		c.hosts[u.Host] = cd
+	}
+	c.mu.Unlock()
+
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	if cd.fetched || time.Now().Before(cd.retryAt) {
+		return cd.d
+	}
+	d, err := c.fetch(ctx, t, u)
+	switch {
+	case err == nil:
+		cd.fetched, cd.d = true, d
+	case ctx.Err() != nil:
+		// Cut short by this caller's context; the next caller tries again.
+	default:
+		log.Debug().Err(err).Str("host", u.Host).Dur("retry_in", robotsRetry).Msg("robots.txt fetch failed, no crawl delay for now")
+		cd.retryAt = time.Now().Add(robotsRetry)
+	}
+	return cd.d
+}
+
+// fetch reads the Crawl-delay from the robots.txt of u's scheme and host.
+// A robots.txt that is missing (a 4xx status) sets no delay; a server
+// error is a failure, to be tried again.
+func (c *crawlDelays) fetch(ctx context.Context, t config.TargetConfig, u *url.URL) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, robotsTimeout)
+	defer cancel()
+	robots := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robots.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", crawlDelayAgent)
+	resp, err := c.client(t).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return 0, fmt.Errorf("fetching %s: HTTP %d", robots, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, nil
+	}
+	d, ok := ratelimit.ParseCrawlDelay(io.LimitReader(resp.Body, maxRobotsBytes), crawlDelayAgent)
+	if ok {
+		log.Info().Str("host", u.Host).Dur("crawl_delay", d).Msg("honoring robots.txt crawl delay")
+	}
+	return d, nil
+}
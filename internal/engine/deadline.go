@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lewta/sendit/internal/driver"
+	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog/log"
+)
+
+// errTaskDeadline is the error of a result cut off by task_deadline_s.
+var errTaskDeadline = errors.New("task deadline exceeded")
+
+// deadlineGrace is how long a driver has to return once its task deadline
+// has cancelled its context, before the engine gives up waiting for it.
+var deadlineGrace = time.Second
+
+// executeTask runs t on drv within the target's task_deadline_s, if set.
+// When the deadline passes, the driver's context is cancelled; a driver
+// that still has not returned after deadlineGrace is abandoned, and its
+// task completes with errTaskDeadline, so that it gives up its worker slot
+// whatever the driver does.
+func executeTask(ctx context.Context, drv driver.Driver, t task.Task) task.Result {
+	limit := time.Duration(t.Config.TaskDeadlineS) * time.Second
+	if limit <= 0 {
+		return drv.Execute(ctx, t)
+	}
+	dctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan task.Result, 1)
+	go func() { done <- drv.Execute(dctx, t) }()
+
+	var result task.Result
+	select {
+	case result = <-done:
+	case <-dctx.Done():
+		if ctx.Err() != nil {
+			return <-done // shutting down: let the driver finish as before
+		}
+		select {
+		case result = <-done:
+		case <-time.After(deadlineGrace):
+			log.Warn().
+				Str("url", t.URL).
+				Str("type", t.Type).
+				Dur("deadline", limit).
+				Msg("driver did not return after its task deadline, abandoning it")
+			result = task.Result{Task: t, Duration: time.Since(start)}
+		}
+	}
+	// Report the deadline rather than the driver's own view of the
+	// cancellation, which may read as a shutdown.
+	if ctx.Err() == nil && dctx.Err() == context.DeadlineExceeded && (result.Error != nil || result.StatusCode == 0) {
+		result.Error = fmt.Errorf("%w (%s)", errTaskDeadline, limit)
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/lewta/sendit/internal/ratelimit"
+	"github.com/rs/zerolog/log"
+)
+
+// dumpTopTargets caps the targets listed in a Dump.
+const dumpTopTargets = 10
+
+// Dump is a snapshot of a running engine for diagnosing a run that is
+// alive but slower than expected.
+type Dump struct {
+	Time              time.Time
+	Goroutines        int
+	Workers           int // worker slots in use
+	MaxWorkers        int
+	BrowserWorkers    int
+	MaxBrowserWorkers int // current browser limit, lower than configured when autoscaled
+	Status            Status
+	// Domains lists every domain requests have been sent to, the one whose
+	// requests waited longest first.
+	Domains []DomainDump
+	// TopErrors lists the targets with the most errors, at most
+	// dumpTopTargets of them; targets without errors are left out.
+	TopErrors []TargetDump
+}
+
+// DomainDump is the rate-limit and backoff state of one domain.
+type DomainDump struct {
+	Domain string
+	// LimitRPS is the domain's current rate limit, zero while a budget
+	// advertised by the server is exhausted.
+	LimitRPS float64
+	Waits    WaitStats
+	Backoff  *ratelimit.BackoffState // nil unless the domain is backing off
+}
+
+// TargetDump is the counters of one target.
+type TargetDump struct {
+	URL string
+	TargetStats
+}
+
+// Dump returns a snapshot of the engine's pool, pacing, per-domain, and
+// per-target state.
+func (e *Engine) Dump() Dump {
+	cfg := e.Config()
+	d := Dump{
+		Time:              time.Now(),
+		Goroutines:        runtime.NumGoroutine(),
+		MaxWorkers:        cfg.Limits.MaxWorkers,
+		MaxBrowserWorkers: e.pool.BrowserLimit(),
+		Status:            e.Status(),
+	}
+	d.Workers, d.BrowserWorkers = e.pool.InUse()
+
+	backoff := make(map[string]ratelimit.BackoffState)
+	for _, b := range e.Backoff() {
+		backoff[b.Domain] = b
+	}
+	rl := e.rl.Load()
+	for domain, w := range e.WaitStats() {
+		dd := DomainDump{Domain: domain, LimitRPS: rl.Limit(domain), Waits: w}
+		if b, ok := backoff[domain]; ok {
+			dd.Backoff = &b
+		}
+		d.Domains = append(d.Domains, dd)
+	}
+	slices.SortFunc(d.Domains, func(a, b DomainDump) int {
+		return cmp.Or(
+			cmp.Compare(b.Waits.RateLimit+b.Waits.Backoff, a.Waits.RateLimit+a.Waits.Backoff),
+			cmp.Compare(a.Domain, b.Domain),
+		)
+	})
+
+	for url, s := range e.TargetStats() {
+		if s.Errors > 0 {
+			d.TopErrors = append(d.TopErrors, TargetDump{URL: url, TargetStats: s})
+		}
+	}
+	slices.SortFunc(d.TopErrors, func(a, b TargetDump) int {
+		return cmp.Or(cmp.Compare(b.Errors, a.Errors), cmp.Compare(a.URL, b.URL))
+	})
+	if len(d.TopErrors) > dumpTopTargets {
+		d.TopErrors = d.TopErrors[:dumpTopTargets]
+	}
+	return d
+}
+
+// LogDump writes a Dump to the log, one line for the engine and one per
+// domain and listed target, and returns it.
+func (e *Engine) LogDump() Dump {
+	d := e.Dump()
+	st := d.Status
+	ev := log.Info().
+		Int("goroutines", d.Goroutines).
+		Str("workers", fmtSlots(d.Workers, d.MaxWorkers)).
+		Str("browser_workers", fmtSlots(d.BrowserWorkers, d.MaxBrowserWorkers)).
+		Str("mode", st.Mode).
+		Bool("paused", st.Paused).
+		Float64("rps", st.RPS).
+		Float64("cpu_pct", st.CPUPct).
+		Uint64("mem_used_mb", st.MemUsedMB)
+	if st.Blackout != "" {
+		ev.Str("blackout", st.Blackout).Time("blackout_until", st.BlackoutUntil)
+	}
+	if st.Mode == "scheduled" {
+		ev.Bool("in_window", st.InWindow).Str("window_group", st.WindowGroup)
+	}
+	if st.ActiveRPM > 0 {
+		ev.Float64("active_rpm", st.ActiveRPM)
+	}
+	ev.Int("domains", len(d.Domains)).Msg("state dump")
+
+	for _, dd := range d.Domains {
+		ev := log.Info().
+			Str("domain", dd.Domain).
+			Float64("limit_rps", dd.LimitRPS).
+			Dur("rate_limit_wait", dd.Waits.RateLimit).
+			Dur("backoff_wait", dd.Waits.Backoff)
+		if b := dd.Backoff; b != nil {
+			ev.Int("backoff_attempts", b.Attempts).
+				Int("backoff_max_attempts", b.MaxAttempts).
+				Time("next_allowed", b.NextAllowed).
+				Bool("cooldown", b.Cooldown)
+		}
+		ev.Msg("state dump: domain")
+	}
+	for _, t := range d.TopErrors {
+		log.Info().
+			Str("url", t.URL).
+			Int64("errors", t.Errors).
+			Int64("requests", t.Requests).
+			Int("last_status", t.LastStatus).
+			Dur("avg_latency", t.AvgLatency()).
+			Msg("state dump: target")
+	}
+	return d
+}
+
+func fmtSlots(used, limit int) string {
+	return fmt.Sprintf("%d/%d", used, limit)
+}
@@ -2,10 +2,18 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/lewta/sendit/internal/alert"
 	"github.com/lewta/sendit/internal/config"
 	"github.com/lewta/sendit/internal/driver"
 	"github.com/lewta/sendit/internal/metrics"
@@ -14,6 +22,7 @@ import (
 	"github.com/lewta/sendit/internal/ratelimit"
 	"github.com/lewta/sendit/internal/resource"
 	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -22,70 +31,156 @@ type Engine struct {
 	cfg        atomic.Pointer[config.Config]
 	pool       *Pool
 	scheduler  *Scheduler
-	selector   atomic.Pointer[task.Selector]
+	selector   atomic.Pointer[selectors]
 	rl         atomic.Pointer[ratelimit.Registry]
 	backoff    atomic.Pointer[ratelimit.BackoffRegistry]
+	retry      atomic.Pointer[retryPolicy]
+	crawl      *crawlDelays            // robots.txt Crawl-delay per host
+	redis      *ratelimit.RedisLimiter // shared rate-limit budget; nil = local only
+	proxies    *driver.ProxyPool       // network.proxies; nil = direct
 	monitor    *resource.Monitor
 	metrics    *metrics.Metrics
 	writer     *output.Writer
+	sinks      []output.Sink
+	sampler    *output.Sampler
 	pcapWriter *pcap.Writer
+	alerts     *alert.Alerter // nil when no alert rules are configured
 	drivers    map[string]driver.Driver
-	observer   atomic.Pointer[func(task.Result)]
+	observers  atomic.Pointer[[]*observerEntry]
+	obsMu      sync.Mutex     // serializes changes to observers
+	resultObs  *observerEntry // the SetObserver function; guarded by obsMu
+	counters   targetCounters
+	pause      pauseGate
+	blackouts  atomic.Pointer[blackouts]
+	inBlackout atomic.Bool // a dispatch loop has seen a blackout begin
+	started    time.Time
+	runID      string
+	taskLog    *zerolog.Logger // per-task events; nil = the global logger
 }
 
-// SetObserver registers a function called after every completed dispatch.
-// It is safe to call before or after Run. Pass nil to clear.
-func (e *Engine) SetObserver(fn func(task.Result)) {
-	e.observer.Store(&fn)
+// SetRunID replaces the run ID generated by New. Call it before Run.
+func (e *Engine) SetRunID(id string) {
+	e.runID = id
+}
+
+// SetTaskLogger sends the log events about individual tasks (dispatch,
+// completion, errors, backoff) to l instead of the global logger, which
+// keeps engine lifecycle and domain state changes. Call it before Run.
+func (e *Engine) SetTaskLogger(l zerolog.Logger) {
+	e.taskLog = &l
+}
+
+// taskLogger returns the logger for per-task events.
+func (e *Engine) taskLogger() zerolog.Logger {
+	if e.taskLog != nil {
+		return *e.taskLog
+	}
+	return log.Logger
+}
+
+// RunID returns the ID stamped on every result of this run.
+func (e *Engine) RunID() string {
+	return e.runID
 }
 
 // New creates an Engine wired with all dependencies.
 func New(cfg *config.Config, m *metrics.Metrics) (*Engine, error) {
-	sel, err := task.NewSelector(cfg.Targets)
+	sel, err := newSelector(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	perDomain := make(map[string]float64)
-	for _, d := range cfg.RateLimits.PerDomain {
-		perDomain[d.Domain] = d.RPS
-	}
-
 	e := &Engine{
 		pool:      NewPool(cfg.Limits.MaxWorkers, cfg.Limits.MaxBrowserWorkers),
 		scheduler: NewScheduler(cfg.Pacing),
 		monitor:   resource.New(cfg.Limits.CPUThresholdPct, cfg.Limits.MemoryThresholdMB),
 		metrics:   m,
+		sampler:   output.NewSampler(cfg.Output),
+		alerts:    alert.New(cfg.Alerts),
+		started:   time.Now(),
+		runID:     uuid.NewString(),
 	}
 
+	e.scheduler.SetBurst(cfg.RateLimits.Burst)
+	m.SetBackoffSource(func() int { return len(e.Backoff()) })
+	m.SetBlackoutSource(func() bool {
+		_, _, ok := e.Blackout()
+		return ok
+	})
 	e.cfg.Store(cfg)
 	e.selector.Store(sel)
-	e.rl.Store(ratelimit.NewRegistry(cfg.RateLimits.DefaultRPS, perDomain))
-	e.backoff.Store(ratelimit.NewBackoffRegistry(
-		cfg.Backoff.InitialMs,
-		cfg.Backoff.MaxMs,
-		cfg.Backoff.Multiplier,
-		cfg.Backoff.MaxAttempts,
-	))
-	e.drivers = map[string]driver.Driver{
-		"http": driver.NewHTTPDriverWithRedirectLimiter(func(ctx context.Context, host string) error {
+	if r := cfg.RateLimits.Redis; r.Address != "" {
+		e.redis = ratelimit.NewRedisLimiter(ratelimit.RedisOptions{
+			Address:   r.Address,
+			Username:  r.Username,
+			Password:  os.Getenv(r.PasswordEnv),
+			DB:        r.DB,
+			TLS:       r.TLS,
+			KeyPrefix: r.KeyPrefix,
+			Timeout:   time.Duration(r.TimeoutMs) * time.Millisecond,
+		})
+	}
+	e.rl.Store(newRateRegistry(cfg.RateLimits, e.redis))
+	e.backoff.Store(newBackoffRegistry(cfg.Backoff))
+	e.retry.Store(newRetryPolicy(cfg.Retry))
+	e.blackouts.Store(newBlackouts(cfg.Blackouts))
+
+	e.proxies, err = driver.NewProxyPool(cfg.Network)
+	if err != nil {
+		return nil, fmt.Errorf("creating proxy pool: %w", err)
+	}
+	ws, grpcDrv, sftpDrv := driver.NewWebSocketDriver(), driver.NewGRPCDriver(), driver.NewSFTPDriver()
+	if e.proxies != nil {
+		ws.SetProxies(e.proxies)
+		grpcDrv.SetProxies(e.proxies)
+		sftpDrv.SetProxies(e.proxies)
+	}
+	httpDrv := driver.NewHTTPDriverWithOptions(driver.HTTPDriverOptions{
+		RedirectLimiter: func(ctx context.Context, host string) error {
 			return e.rl.Load().Wait(ctx, host)
+		},
+		Details:  cfg.Output.Details,
+		Proxies:  e.proxies,
+		Settings: cfg.Drivers.HTTP,
+	})
+	e.crawl = newCrawlDelays(httpDrv.Client)
+	e.drivers = map[string]driver.Driver{
+		"http":    httpDrv,
+		"browser": driver.NewBrowserDriverWithOptions(driver.BrowserDriverOptions{Settings: cfg.Drivers.Browser}),
+		"dns": driver.NewDNSDriverWithOptions(driver.DNSDriverOptions{
+			OnResolverHealth: m.SetResolverHealth,
+			Settings:         cfg.Drivers.DNS,
 		}),
-		"browser":   driver.NewBrowserDriver(),
-		"dns":       driver.NewDNSDriver(),
-		"websocket": driver.NewWebSocketDriver(),
-		"grpc":      driver.NewGRPCDriver(),
-		"sftp":      driver.NewSFTPDriver(),
+		"websocket": ws,
+		"grpc":      grpcDrv,
+		"sftp":      sftpDrv,
 	}
+	m.SetConnStatsSource(func() map[string]metrics.ConnStats {
+		out := make(map[string]metrics.ConnStats)
+		for typ, drv := range e.drivers {
+			if cs, ok := drv.(driver.ConnStatser); ok {
+				out[typ] = metrics.ConnStats(cs.ConnStats())
+			}
+		}
+		return out
+	})
 
 	if cfg.Output.Enabled {
-		w, err := output.New(cfg.Output)
+		w, err := output.NewWithDropFunc(cfg.Output, m.RecordOutputDropped)
 		if err != nil {
 			return nil, fmt.Errorf("creating output writer: %w", err)
 		}
 		e.writer = w
 	}
 
+	if len(cfg.Output.Sinks) > 0 {
+		sinks, err := output.NewSinks(cfg.Output, m.RecordOutputDropped)
+		if err != nil {
+			return nil, fmt.Errorf("creating output sinks: %w", err)
+		}
+		e.sinks = sinks
+	}
+
 	if cfg.Output.PCAPFile != "" {
 		pw, err := pcap.New(cfg.Output.PCAPFile)
 		if err != nil {
@@ -103,30 +198,91 @@ func (e *Engine) Run(ctx context.Context) {
 	if e.writer != nil {
 		defer e.writer.Close()
 	}
+	for _, s := range e.sinks {
+		defer s.Close()
+	}
 	if e.pcapWriter != nil {
 		defer e.pcapWriter.Close()
 	}
+	if e.redis != nil {
+		defer e.redis.Close()
+	}
+	defer e.alerts.Close()
 
 	e.monitor.Start(ctx)
+	if limits := e.cfg.Load().Limits; limits.BrowserAutoscale.Enabled {
+		newBrowserAutoscaler(e.pool, limits, e.monitor).Start(ctx)
+	}
 	e.scheduler.Start(ctx)
+	e.proxies.Start(ctx)
+	e.alerts.Start(ctx, e.runID)
 
 	cfg := e.cfg.Load()
-	log.Info().
+	ev := log.Info().
 		Str("mode", cfg.Pacing.Mode).
-		Int("max_workers", cfg.Limits.MaxWorkers).
-		Msg("engine started")
+		Str("load_model", cfg.Pacing.LoadModel).
+		Int("max_workers", cfg.Limits.MaxWorkers)
+
+	if cfg.Pacing.LoadModel == "closed" {
+		users := cfg.Pacing.VirtualUsers
+		if users == 0 {
+			users = cfg.Limits.MaxWorkers
+		}
+		ev.Int("virtual_users", users).Msg("engine started")
+		var wg sync.WaitGroup
+		for range users {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				e.loop(ctx, true)
+			}()
+		}
+		wg.Wait()
+	} else {
+		ev.Msg("engine started")
+		e.loop(ctx, false)
+	}
 
+	log.Info().Msg("engine shutting down, waiting for in-flight tasks")
+	e.pool.Wait()
+	log.Info().Msg("engine stopped")
+}
+
+// loop paces, picks, and dispatches tasks until ctx is cancelled. The open
+// model runs one loop that hands each task to its own goroutine; in the
+// closed model every virtual user runs a loop that executes its task before
+// pausing for the next.
+func (e *Engine) loop(ctx context.Context, closed bool) {
+	// think is the think time of the target picked last, which sets the
+	// pause before the next pick.
+	var think config.ThinkTimeConfig
 	for {
 		// --- Pacing delay ---
-		if err := e.scheduler.Wait(ctx); err != nil {
-			break
+		if err := e.scheduler.WaitAfter(ctx, think); err != nil {
+			return
 		}
 
-		t := e.selector.Load().Pick()
+		group := e.scheduler.Group()
+		t, ok := e.selector.Load().Pick(group)
+		if !ok {
+			log.Warn().Str("group", group).Msg("no targets in the scheduled window's group, skipping")
+			continue
+		}
+		think = t.Config.ThinkTime
 
 		// --- Resource gate ---
 		if err := e.monitor.Admit(ctx); err != nil {
-			break
+			return
+		}
+
+		// --- Pause gate ---
+		if err := e.waitWhilePaused(ctx); err != nil {
+			return
+		}
+
+		// --- Blackout gate ---
+		if err := e.waitOutBlackout(ctx); err != nil {
+			return
 		}
 
 		// --- Worker slot ---
@@ -134,17 +290,19 @@ func (e *Engine) Run(ctx context.Context) {
 		// slow or rate-limited domain does not stall the dispatch loop and
 		// starve all other domains.
 		if err := e.pool.Acquire(ctx, t.Type); err != nil {
-			break
+			return
 		}
 
-		go e.dispatch(ctx, t)
+		if closed {
+			e.dispatch(ctx, t)
+		} else {
+			go e.dispatch(ctx, t)
+		}
 	}
-
-	log.Info().Msg("engine shutting down, waiting for in-flight tasks")
-	e.pool.Wait()
-	log.Info().Msg("engine stopped")
 }
 
+// dispatch sends t, and sends it again while the retry policy allows,
+// holding its worker slot throughout.
 func (e *Engine) dispatch(ctx context.Context, t task.Task) {
 	defer e.pool.Release(t.Type)
 
@@ -155,6 +313,41 @@ func (e *Engine) dispatch(ctx context.Context, t task.Task) {
 	}
 
 	host := hostname(t.URL)
+	tl := e.taskLogger()
+	for retries := 0; ; retries++ {
+		class, sent := e.attempt(ctx, drv, t, retries)
+		if !sent || class == ratelimit.ErrorClassNone {
+			return
+		}
+		switch e.retry.Load().next(class, retries) {
+		case noRetry:
+			return
+		case retryBudgetExhausted:
+			e.metrics.RecordRetry(t.Type, host, metrics.RetryBudgetExhausted)
+			tl.Debug().Str("url", t.URL).Msg("retry budget exhausted, dropping task")
+			return
+		}
+		e.metrics.RecordRetry(t.Type, host, metrics.RetrySent)
+		tl.Debug().
+			Str("url", t.URL).
+			Int("retry", retries+1).
+			Msg("retrying task")
+		if err := e.waitWhilePaused(ctx); err != nil {
+			return
+		}
+		if err := e.waitOutBlackout(ctx); err != nil {
+			return
+		}
+	}
+}
+
+// attempt sends t once, after waiting out its domain's backoff and rate
+// limit, and records the result. retries is how many times t was sent
+// before. It returns the class of the outcome, and false when t was not
+// sent because its domain is in cooldown or ctx was cancelled.
+func (e *Engine) attempt(ctx context.Context, drv driver.Driver, t task.Task, retries int) (ratelimit.ErrorClass, bool) {
+	host := hostname(t.URL)
+	tl := e.taskLogger()
 
 	// Snapshot the registries once so that a concurrent Reload cannot
 	// swap them mid-dispatch.
@@ -162,94 +355,204 @@ func (e *Engine) dispatch(ctx context.Context, t task.Task) {
 	bo := e.backoff.Load()
 
 	// --- Backoff wait ---
+	waitStart := time.Now()
 	if err := bo.Wait(ctx, host); err != nil {
-		return // context cancelled
+		if errors.Is(err, ratelimit.ErrCooldown) {
+			e.metrics.RecordSkipped(host, metrics.SkipCooldown)
+			tl.Debug().Str("url", t.URL).Msg("domain in cooldown, skipping task")
+		}
+		return 0, false // cooldown or context cancelled
+	}
+	boWait := time.Since(waitStart)
+
+	if t.Config.Network.IPFamily == "" {
+		t.Config.Network.IPFamily = e.cfg.Load().Network.IPFamily
 	}
 
-	// --- Per-domain rate limit ---
+	// --- Per-domain rate limit, global cap, and politeness gap ---
+	if e.cfg.Load().RateLimits.HonorCrawlDelay && (t.Type == "http" || t.Type == "browser") {
+		if d := e.crawl.delay(ctx, t.URL, t.Config); d > 0 {
+			rl.RaiseMinInterval(host, d)
+		}
+	}
 	if err := rl.Wait(ctx, host); err != nil {
-		return // context cancelled
+		return 0, false // context cancelled
 	}
+	rlWait := time.Since(waitStart) - boWait
+	e.counters.recordWait(host, rlWait, boWait)
+	e.metrics.RecordWait(host, metrics.WaitBackoff, boWait)
+	e.metrics.RecordWait(host, metrics.WaitRateLimit, rlWait)
 
-	log.Debug().
+	tl.Debug().
 		Str("url", t.URL).
 		Str("type", t.Type).
 		Msg("dispatching task")
 
-	result := drv.Execute(ctx, t)
-
-	e.metrics.Record(result)
+	e.notify(func(o Observer) { o.OnDispatch(t, retries) })
+	result, mirror := e.execute(ctx, drv, t, rl, bo)
+	result.RunID = e.runID
+	result.Retry = retries
+
+	// Tie the log lines about this request to its output record. Its
+	// outcome goes to the task log, changes to the domain's state to the
+	// main log.
+	lg := log.Logger
+	if id := result.Meta["request_id"]; id != "" {
+		lg = lg.With().Str("request_id", id).Logger()
+		tl = tl.With().Str("request_id", id).Logger()
+	}
 
-	if obs := e.observer.Load(); obs != nil {
-		(*obs)(result)
+	if result.RateLimit != nil && e.cfg.Load().RateLimits.HonorHeaders {
+		rl.Observe(host, *result.RateLimit)
+	}
+	if ratelimit.ClassifyError(result.Error) != ratelimit.ErrorClassFatal && !result.Aborted {
+		if adj, ok := rl.ObserveLatency(host, result.Duration); ok {
+			ev := lg.Info()
+			if !adj.Up {
+				ev = lg.Warn()
+			}
+			ev.Str("host", host).
+				Dur("p95", adj.P95).
+				Float64("rps", adj.RPS).
+				Msg("adaptive rate limit adjusted")
+		}
 	}
 
-	if e.writer != nil {
-		e.writer.Send(result)
+	// A mirrored pair is kept or dropped by output sampling together, so
+	// that neither half of a comparison is missing.
+	keep := e.sampler.Keep(result)
+	if mirror != nil {
+		mirror.RunID = e.runID
+		mirror.Retry = retries
+		keep = e.sampler.Keep(*mirror) || keep
+		e.record(ctx, *mirror, keep)
+		tl.Debug().
+			Str("url", mirror.Task.URL).
+			Int("status", mirror.StatusCode).
+			Dur("duration", mirror.Duration).
+			AnErr("error", mirror.Error).
+			Msg("mirror request complete")
 	}
-	if e.pcapWriter != nil {
-		e.pcapWriter.Send(result)
+	e.record(ctx, result, keep)
+
+	// The request was cut short on purpose; it says nothing about the
+	// domain, so backoff is left as it was.
+	if result.Aborted {
+		tl.Debug().
+			Str("url", t.URL).
+			Dur("duration", result.Duration).
+			Msg("task aborted")
+		return ratelimit.ErrorClassNone, true
 	}
 
 	if result.Error != nil {
 		class := ratelimit.ClassifyError(result.Error)
 		if class == ratelimit.ErrorClassFatal {
-			return
+			return class, true
 		}
 		if class == ratelimit.ErrorClassTransient {
-			if bo.Attempts(host) < bo.MaxAttempts() {
+			if bo.Attempts(host) < bo.MaxAttemptsFor(host) {
 				delay := bo.RecordError(host)
-				log.Warn().
-					Str("host", host).
-					Dur("backoff", delay).
-					Err(result.Error).
-					Msg("transient error, backing off")
+				e.notifyBackoff(host, delay, bo.InCooldown(host), result)
+				if bo.InCooldown(host) {
+					lg.Error().
+						Str("host", host).
+						Dur("cooldown", delay).
+						Err(result.Error).
+						Msg("max backoff attempts reached, domain in cooldown")
+				} else {
+					tl.Warn().
+						Str("host", host).
+						Dur("backoff", delay).
+						Err(result.Error).
+						Msg("transient error, backing off")
+				}
 			} else {
-				log.Error().
+				tl.Error().
 					Str("host", host).
 					Err(result.Error).
 					Msg("max backoff attempts reached, skipping domain temporarily")
 			}
 		} else {
-			log.Error().
+			tl.Error().
 				Str("url", t.URL).
 				Err(result.Error).
 				Msg("permanent error, skipping")
 		}
-		return
+		return class, true
 	}
 
 	class := ratelimit.ClassifyStatusCode(result.StatusCode)
 	switch class {
 	case ratelimit.ErrorClassTransient:
-		if bo.Attempts(host) < bo.MaxAttempts() {
+		if bo.Attempts(host) < bo.MaxAttemptsFor(host) {
 			delay := bo.RecordError(host)
-			log.Warn().
-				Str("host", host).
-				Int("status", result.StatusCode).
-				Dur("backoff", delay).
-				Msg("transient HTTP error, backing off")
+			e.notifyBackoff(host, delay, bo.InCooldown(host), result)
+			if bo.InCooldown(host) {
+				lg.Error().
+					Str("host", host).
+					Int("status", result.StatusCode).
+					Dur("cooldown", delay).
+					Msg("max backoff attempts reached, domain in cooldown")
+			} else {
+				tl.Warn().
+					Str("host", host).
+					Int("status", result.StatusCode).
+					Dur("backoff", delay).
+					Msg("transient HTTP error, backing off")
+			}
 		}
 	case ratelimit.ErrorClassPermanent:
-		log.Error().
+		tl.Error().
 			Str("url", t.URL).
 			Int("status", result.StatusCode).
 			Msg("permanent HTTP error, skipping")
 	case ratelimit.ErrorClassNone:
 		bo.RecordSuccess(host)
-		log.Info().
-			Str("url", t.URL).
+		ev := tl.Info()
+		if result.Slow() {
+			ev = tl.Warn().Bool("slow", true)
+		}
+		ev.Str("url", t.URL).
 			Str("type", t.Type).
 			Int("status", result.StatusCode).
 			Dur("duration", result.Duration).
 			Int64("bytes", result.BytesRead).
 			Msg("task complete")
 	}
+	return class, true
+}
+
+// record passes result to metrics, counters, alerts, and the result
+// observers, and to the output writers when keep is set.
+func (e *Engine) record(ctx context.Context, result task.Result, keep bool) {
+	e.metrics.Record(result)
+	e.counters.record(result)
+	// Requests cut off by shutdown, or aborted on purpose, say nothing
+	// about the target.
+	if ctx.Err() == nil && !result.Aborted {
+		e.alerts.Record(result)
+	}
+
+	e.notify(func(o Observer) { o.OnResult(result) })
+
+	if keep {
+		if e.writer != nil {
+			e.writer.Send(result)
+		}
+		for _, s := range e.sinks {
+			s.Send(result)
+		}
+		if e.pcapWriter != nil {
+			e.pcapWriter.Send(result)
+		}
+	}
 }
 
 // Reload atomically applies a new configuration to the running engine.
-// Targets, rate limits, backoff, and pacing are updated in-place.
-// Changes to pacing mode, resource limits, or scheduled windows require a restart.
+// Targets, rate limits, backoff, blackouts, and pacing, including scheduled
+// windows, are updated in-place. Changes to pacing mode or resource limits
+// require a restart.
 func (e *Engine) Reload(newCfg *config.Config) error {
 	old := e.cfg.Load()
 
@@ -257,32 +560,53 @@ func (e *Engine) Reload(newCfg *config.Config) error {
 	logTargetsDiff(old.Targets, newCfg.Targets)
 
 	// Swap Selector.
-	sel, err := task.NewSelector(newCfg.Targets)
+	sel, err := newSelector(newCfg)
 	if err != nil {
 		return fmt.Errorf("hot-reload: building selector: %w", err)
 	}
 	e.selector.Store(sel)
 
-	// Swap rate-limit registry.
-	perDomain := make(map[string]float64, len(newCfg.RateLimits.PerDomain))
-	for _, d := range newCfg.RateLimits.PerDomain {
-		perDomain[d.Domain] = d.RPS
+	// Swap the rate-limit registry only when its settings changed, and then
+	// keep the budgets, adaptive rates, and Crawl-delays it has learnt.
+	if !reflect.DeepEqual(old.RateLimits, newCfg.RateLimits) {
+		rl := newRateRegistry(newCfg.RateLimits, e.redis)
+		rl.CarryOver(e.rl.Load())
+		e.rl.Store(rl)
+	}
+	if old.RateLimits.Redis != newCfg.RateLimits.Redis {
+		log.Warn().Msg("hot-reload: rate_limits.redis changes require restart")
+	}
+	if n, o := newCfg.Network, old.Network; !slices.Equal(n.Proxies, o.Proxies) || n.ProxySelection != o.ProxySelection || n.ProxyHealthCheck != o.ProxyHealthCheck {
+		log.Warn().Msg("hot-reload: network.proxies changes require restart")
 	}
-	e.rl.Store(ratelimit.NewRegistry(newCfg.RateLimits.DefaultRPS, perDomain))
 
-	// Swap backoff registry.
-	e.backoff.Store(ratelimit.NewBackoffRegistry(
-		newCfg.Backoff.InitialMs, newCfg.Backoff.MaxMs,
-		newCfg.Backoff.Multiplier, newCfg.Backoff.MaxAttempts,
-	))
+	// Swap the backoff registry only when its settings changed, keeping the
+	// domains it is backing off or quarantining; then swap the retry policy.
+	if !reflect.DeepEqual(old.Backoff, newCfg.Backoff) {
+		bo := newBackoffRegistry(newCfg.Backoff)
+		bo.CarryOver(e.backoff.Load())
+		e.backoff.Store(bo)
+	}
+	e.retry.Store(newRetryPolicy(newCfg.Retry))
+	e.blackouts.Store(newBlackouts(newCfg.Blackouts))
 
 	// Update pacing (or warn if mode change requires restart).
+	e.scheduler.SetBurst(newCfg.RateLimits.Burst)
 	if old.Pacing.Mode != newCfg.Pacing.Mode {
 		log.Warn().Str("old", old.Pacing.Mode).Str("new", newCfg.Pacing.Mode).
 			Msg("hot-reload: pacing mode change requires restart")
 	} else {
 		e.scheduler.UpdatePacing(newCfg.Pacing)
 	}
+	if old.Pacing.LoadModel != newCfg.Pacing.LoadModel || old.Pacing.VirtualUsers != newCfg.Pacing.VirtualUsers {
+		log.Warn().Msg("hot-reload: pacing.load_model and virtual_users changes require restart")
+	}
+	if !reflect.DeepEqual(old.Alerts, newCfg.Alerts) {
+		log.Warn().Msg("hot-reload: alerts changes require restart")
+	}
+	if !reflect.DeepEqual(old.Drivers, newCfg.Drivers) {
+		log.Warn().Msg("hot-reload: drivers changes require restart")
+	}
 
 	// Warn if resource limits changed.
 	if old.Limits != newCfg.Limits {
@@ -291,6 +615,7 @@ func (e *Engine) Reload(newCfg *config.Config) error {
 
 	e.cfg.Store(newCfg)
 	log.Info().Msg("hot-reload: config reloaded")
+	e.notify(func(o Observer) { o.OnReload(newCfg) })
 	return nil
 }
 
@@ -315,6 +640,130 @@ func logTargetsDiff(old, next []config.TargetConfig) {
 	}
 }
 
+// selectors holds the selector over all targets and one per target group
+// named by a schedule window.
+type selectors struct {
+	all     *task.Selector
+	byGroup map[string]*task.Selector
+}
+
+// Pick picks a target from group, or from all targets when group is "".
+// It reports false when the group has no targets.
+func (s *selectors) Pick(group string) (task.Task, bool) {
+	if group == "" {
+		return s.all.Pick(), true
+	}
+	sel, ok := s.byGroup[group]
+	if !ok {
+		return task.Task{}, false
+	}
+	return sel.Pick(), true
+}
+
+// newSelector builds the target selectors, with their selection mode and
+// any domain spacing, from config: one over all targets, and one for each
+// group a pacing.schedule window drives.
+func newSelector(cfg *config.Config) (*selectors, error) {
+	build := func(targets []config.TargetConfig) (*task.Selector, error) {
+		sel, err := task.NewSelector(targets)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Pacing.Selection == "deck" {
+			sel.UseDeck()
+		}
+		if ms := cfg.Pacing.DomainSpacingMs; ms > 0 {
+			sel.SetDomainSpacing(time.Duration(ms) * time.Millisecond)
+		}
+		return sel, nil
+	}
+
+	all, err := build(cfg.Targets)
+	if err != nil {
+		return nil, err
+	}
+	s := &selectors{all: all, byGroup: make(map[string]*task.Selector)}
+	for _, w := range cfg.Pacing.Schedule {
+		if w.Group == "" || s.byGroup[w.Group] != nil {
+			continue
+		}
+		var members []config.TargetConfig
+		for _, t := range cfg.Targets {
+			if t.Group == w.Group {
+				members = append(members, t)
+			}
+		}
+		if len(members) == 0 {
+			continue // Pick reports the empty group
+		}
+		sel, err := build(members)
+		if err != nil {
+			return nil, fmt.Errorf("target group %q: %w", w.Group, err)
+		}
+		s.byGroup[w.Group] = sel
+	}
+	return s, nil
+}
+
+// newRateRegistry builds the per-domain rate limiter registry from config,
+// drawing on the shared budget in redis when it is not nil.
+func newRateRegistry(c config.RateLimitsConfig, redis *ratelimit.RedisLimiter) *ratelimit.Registry {
+	perDomain := make(map[string]float64, len(c.PerDomain))
+	for _, d := range c.PerDomain {
+		if d.RPS > 0 {
+			perDomain[d.Domain] = d.RPS
+		}
+	}
+	r := ratelimit.NewRegistry(c.DefaultRPS, perDomain)
+	bursts := make(map[string]int)
+	gaps := make(map[string]time.Duration)
+	for _, d := range c.PerDomain {
+		if d.Burst > 0 {
+			bursts[d.Domain] = d.Burst
+		}
+		if d.MinIntervalMs > 0 {
+			gaps[d.Domain] = time.Duration(d.MinIntervalMs) * time.Millisecond
+		}
+	}
+	r.SetBurst(c.Burst, bursts)
+	r.SetMinInterval(gaps)
+	r.SetGlobal(c.GlobalRPS, c.Burst)
+	if redis != nil {
+		r.SetShared(redis)
+	}
+	if a := c.Adaptive; a.Enabled {
+		r.SetAdaptive(&ratelimit.Adaptive{
+			P95Threshold:   time.Duration(a.P95ThresholdMs) * time.Millisecond,
+			MinRPS:         a.MinRPS,
+			DecreaseFactor: a.DecreaseFactor,
+			RecoveryStep:   a.RecoveryStep,
+			Interval:       time.Duration(a.IntervalS) * time.Second,
+		})
+	}
+	return r
+}
+
+// newBackoffRegistry builds the backoff registry, with any per-domain
+// profiles, from config.
+func newBackoffRegistry(c config.BackoffConfig) *ratelimit.BackoffRegistry {
+	r := ratelimit.NewBackoffRegistry(c.InitialMs, c.MaxMs, c.Multiplier, c.MaxAttempts)
+	r.SetCooldown(c.CooldownS)
+	if len(c.PerDomain) > 0 {
+		perDomain := make(map[string]ratelimit.BackoffPolicy, len(c.PerDomain))
+		for _, d := range c.PerDomain {
+			perDomain[d.Domain] = ratelimit.BackoffPolicy{
+				InitialMs:   d.InitialMs,
+				MaxMs:       d.MaxMs,
+				Multiplier:  d.Multiplier,
+				MaxAttempts: d.MaxAttempts,
+				CooldownS:   d.CooldownS,
+			}
+		}
+		r.SetPerDomain(perDomain)
+	}
+	return r
+}
+
 func hostname(rawURL string) string {
 	u, err := url.Parse(rawURL)
 	if err != nil {
@@ -1,17 +1,25 @@
 package engine
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"slices"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/driver"
 	"github.com/lewta/sendit/internal/metrics"
+	"github.com/lewta/sendit/internal/ratelimit"
 	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog"
 )
 
 func baseCfg(targets []config.TargetConfig) *config.Config {
@@ -62,13 +70,44 @@ func TestReload_SwapsTargets(t *testing.T) {
 
 	// The selector must serve tasks from the new target list.
 	for range 20 {
-		task := eng.selector.Load().Pick()
+		task, _ := eng.selector.Load().Pick("")
 		if task.URL != "https://b.example.com" {
 			t.Errorf("selector returned %q after reload, want b.example.com", task.URL)
 		}
 	}
 }
 
+func TestSelectors_PickByGroup(t *testing.T) {
+	cfg := baseCfg([]config.TargetConfig{
+		{URL: "https://api.example.com", Weight: 1, Type: "http", Group: "api"},
+		{URL: "https://backup.example.com", Weight: 1, Type: "http", Group: "backup"},
+		{URL: "https://other.example.com", Weight: 1, Type: "http"},
+	})
+	cfg.Pacing.Schedule = []config.ScheduleEntry{{Group: "api"}, {Group: "backup"}, {}}
+	sel, err := newSelector(cfg)
+	if err != nil {
+		t.Fatalf("newSelector: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for range 100 {
+		tk, _ := sel.Pick("")
+		seen[tk.URL] = true
+		if tk, ok := sel.Pick("api"); !ok || tk.URL != "https://api.example.com" {
+			t.Fatalf(`Pick("api") = %q, %v, want api.example.com`, tk.URL, ok)
+		}
+		if tk, ok := sel.Pick("backup"); !ok || tk.URL != "https://backup.example.com" {
+			t.Fatalf(`Pick("backup") = %q, %v, want backup.example.com`, tk.URL, ok)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf(`Pick("") picked %d targets, want all 3`, len(seen))
+	}
+	if _, ok := sel.Pick("missing"); ok {
+		t.Error(`Pick("missing") reported a target`)
+	}
+}
+
 func TestReload_SwapsRateLimits(t *testing.T) {
 	targets := []config.TargetConfig{
 		{URL: "https://a.example.com", Weight: 1, Type: "http"},
@@ -92,6 +131,39 @@ func TestReload_SwapsRateLimits(t *testing.T) {
 	}
 }
 
+func TestReload_KeepsLearntRateLimitState(t *testing.T) {
+	targets := []config.TargetConfig{
+		{URL: "https://a.example.com", Weight: 1, Type: "http"},
+	}
+	eng, err := New(baseCfg(targets), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	rl := eng.rl.Load()
+	rl.Observe("a.example.com", ratelimit.Budget{Remaining: 0, Reset: time.Now().Add(time.Minute)})
+
+	// A reload that leaves rate_limits alone keeps the registry itself.
+	if err := eng.Reload(baseCfg(append(targets, config.TargetConfig{URL: "https://b.example.com", Weight: 1, Type: "http"}))); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if eng.rl.Load() != rl {
+		t.Error("unchanged rate_limits rebuilt the registry")
+	}
+
+	// One that changes them carries the exhausted budget over.
+	newCfg := baseCfg(targets)
+	newCfg.RateLimits.DefaultRPS = 99
+	if err := eng.Reload(newCfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := eng.rl.Load().Limit("a.example.com"); got != 0 {
+		t.Errorf("limit after reload = %v, want 0 while the advertised budget is exhausted", got)
+	}
+	if got := eng.rl.Load().Limit("b.example.com"); got != 99 {
+		t.Errorf("limit of an unseen domain = %v, want the new 99", got)
+	}
+}
+
 func TestReload_SwapsBackoff(t *testing.T) {
 	targets := []config.TargetConfig{
 		{URL: "https://a.example.com", Weight: 1, Type: "http"},
@@ -118,6 +190,41 @@ func TestReload_SwapsBackoff(t *testing.T) {
 	}
 }
 
+func TestReload_KeepsBackoffCooldowns(t *testing.T) {
+	targets := []config.TargetConfig{
+		{URL: "https://a.example.com", Weight: 1, Type: "http"},
+	}
+	cfg := baseCfg(targets)
+	cfg.Backoff.CooldownS = 60
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	bo := eng.backoff.Load()
+	for range cfg.Backoff.MaxAttempts {
+		bo.RecordError("a.example.com")
+	}
+
+	// Unchanged backoff settings keep the registry itself.
+	same := baseCfg(targets)
+	same.Backoff.CooldownS = 60
+	if err := eng.Reload(same); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if eng.backoff.Load() != bo {
+		t.Error("unchanged backoff rebuilt the registry")
+	}
+
+	changed := baseCfg(targets)
+	changed.Backoff.CooldownS = 120
+	if err := eng.Reload(changed); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !eng.backoff.Load().InCooldown("a.example.com") {
+		t.Error("reload released a quarantined domain")
+	}
+}
+
 func TestDispatch_RateLimitsCrossHostRedirectDestination(t *testing.T) {
 	var dstRequests atomic.Int32
 	dst := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -189,6 +296,43 @@ func TestDispatch_RateLimitsCrossHostRedirectDestination(t *testing.T) {
 	}
 }
 
+func TestReload_SwapsSchedule(t *testing.T) {
+	targets := []config.TargetConfig{
+		{URL: "https://api.example.com", Weight: 1, Type: "http", Group: "api"},
+		{URL: "https://backup.example.com", Weight: 1, Type: "http", Group: "backup"},
+	}
+	cfg := baseCfg(targets)
+	cfg.Pacing = config.PacingConfig{Mode: "scheduled", Schedule: []config.ScheduleEntry{
+		{Cron: "0 9 * * *", DurationMinutes: 60, RequestsPerMinute: 30, Group: "api"},
+	}}
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng.scheduler.Start(ctx)
+
+	newCfg := baseCfg(targets)
+	newCfg.Pacing = config.PacingConfig{Mode: "scheduled", Schedule: []config.ScheduleEntry{
+		{Cron: "0 1 * * *", DurationMinutes: 120, RequestsPerMinute: 5, Group: "backup"},
+	}}
+	if err := eng.Reload(newCfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	eng.scheduler.mu.Lock()
+	entries := eng.scheduler.cron.Entries()
+	eng.scheduler.mu.Unlock()
+	midnight := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	if len(entries) != 1 || entries[0].Schedule.Next(midnight).Hour() != 1 {
+		t.Errorf("cron has %d entries after reload, want the 01:00 window alone", len(entries))
+	}
+	if tk, ok := eng.selector.Load().Pick("backup"); !ok || tk.URL != "https://backup.example.com" {
+		t.Errorf(`Pick("backup") = %q, %v after reload, want backup.example.com`, tk.URL, ok)
+	}
+}
+
 func TestReload_PacingModeChangeNoError(t *testing.T) {
 	targets := []config.TargetConfig{
 		{URL: "https://a.example.com", Weight: 1, Type: "http"},
@@ -227,3 +371,733 @@ func TestHostname(t *testing.T) {
 		}
 	}
 }
+
+func TestDispatch_StampsRunID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	target := config.TargetConfig{URL: srv.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}}
+	eng, err := New(baseCfg([]config.TargetConfig{target}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if len(eng.RunID()) != 36 {
+		t.Errorf("generated run ID = %q, want a UUID", eng.RunID())
+	}
+	eng.SetRunID("nightly-42")
+
+	results := make(chan task.Result, 1)
+	eng.SetObserver(func(result task.Result) {
+		results <- result
+	})
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+
+	if got := (<-results).RunID; got != "nightly-42" {
+		t.Errorf("result RunID = %q, want nightly-42", got)
+	}
+	if got := eng.Status().RunID; got != "nightly-42" {
+		t.Errorf("Status().RunID = %q, want nightly-42", got)
+	}
+}
+
+func TestDispatch_TaskLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	target := config.TargetConfig{URL: srv.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}}
+	eng, err := New(baseCfg([]config.TargetConfig{target}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var buf bytes.Buffer
+	eng.SetTaskLogger(zerolog.New(&buf))
+
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+
+	if out := buf.String(); !strings.Contains(out, `"message":"task complete"`) || !strings.Contains(out, srv.URL) {
+		t.Errorf("task log = %q, want the task complete event", out)
+	}
+}
+
+func TestDispatch_Mirror(t *testing.T) {
+	var primaryHits, mirrorHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+	}))
+	defer primary.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer mirror.Close()
+
+	// A different host name for the mirror gives it a backoff of its own.
+	mirrorURL := strings.Replace(mirror.URL, "127.0.0.1", "localhost", 1)
+
+	target := config.TargetConfig{URL: primary.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}, MirrorURL: mirrorURL}
+	eng, err := New(baseCfg([]config.TargetConfig{target}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	results := make(chan task.Result, 2)
+	eng.SetObserver(func(result task.Result) {
+		results <- result
+	})
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+
+	byRole := map[string]task.Result{}
+	for range 2 {
+		r := <-results
+		byRole[r.Meta["mirror"]] = r
+	}
+	p, m := byRole["primary"], byRole["mirror"]
+	if p.Task.URL != primary.URL || p.StatusCode != 200 || m.Task.URL != mirrorURL || m.StatusCode != 503 {
+		t.Errorf("primary = %s %d, mirror = %s %d", p.Task.URL, p.StatusCode, m.Task.URL, m.StatusCode)
+	}
+	if id := p.Meta["correlation_id"]; id == "" || m.Meta["correlation_id"] != id {
+		t.Errorf("correlation IDs = %q and %q, want one shared ID", id, m.Meta["correlation_id"])
+	}
+	if primaryHits.Load() != 1 || mirrorHits.Load() != 1 {
+		t.Errorf("hits = %d primary, %d mirror, want 1 each", primaryHits.Load(), mirrorHits.Load())
+	}
+	// The mirror's failure backs off its own host, not the primary's.
+	if got := eng.TargetStats()[mirrorURL]; got.Requests != 1 || got.Errors != 1 {
+		t.Errorf("mirror stats = %+v", got)
+	}
+	if a := eng.backoff.Load().Attempts("localhost"); a != 1 {
+		t.Errorf("backoff attempts for mirror host = %d, want 1", a)
+	}
+	if a := eng.backoff.Load().Attempts(hostname(primary.URL)); a != 0 {
+		t.Errorf("backoff attempts for primary host = %d, want 0", a)
+	}
+	if g, _ := eng.pool.InUse(); g != 0 {
+		t.Errorf("worker slots in use after dispatch = %d, want 0", g)
+	}
+}
+
+func TestDispatch_MirrorSkippedWithoutFreeWorker(t *testing.T) {
+	var mirrorHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer primary.Close()
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+	}))
+	defer mirror.Close()
+
+	target := config.TargetConfig{URL: primary.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}, MirrorURL: mirror.URL}
+	cfg := baseCfg([]config.TargetConfig{target})
+	cfg.Limits.MaxWorkers = 1
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var results atomic.Int32
+	eng.SetObserver(func(result task.Result) {
+		results.Add(1)
+		if result.Meta["correlation_id"] != "" {
+			t.Errorf("an unmirrored result carries correlation_id %q", result.Meta["correlation_id"])
+		}
+	})
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+
+	if results.Load() != 1 || mirrorHits.Load() != 0 {
+		t.Errorf("results = %d, mirror hits = %d; want only the primary with the pool full", results.Load(), mirrorHits.Load())
+	}
+}
+
+func TestDispatch_SlowCountedSeparately(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	target := config.TargetConfig{URL: srv.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}, LatencyBudgetMs: 10}
+	eng, err := New(baseCfg([]config.TargetConfig{target}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+
+	// A slow 200 is still a success for errors and backoff.
+	if got := eng.TargetStats()[srv.URL]; got.Requests != 1 || got.Slow != 1 || got.Errors != 0 {
+		t.Errorf("stats = %+v, want 1 request, 1 slow, 0 errors", got)
+	}
+}
+
+func TestDispatch_RetriesSameTask(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	target := config.TargetConfig{URL: srv.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}}
+	cfg := baseCfg([]config.TargetConfig{target})
+	cfg.Backoff.InitialMs = 10
+	cfg.Retry = config.RetryConfig{MaxRetries: 3, On: []string{"transient"}}
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var retries []int
+	eng.SetObserver(func(r task.Result) { retries = append(retries, r.Retry) })
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+
+	// Two 503s, then the third attempt succeeds and stops the retries.
+	if got := eng.TargetStats()[srv.URL]; hits.Load() != 3 || got.Requests != 3 || got.Errors != 2 {
+		t.Errorf("hits = %d, stats = %+v, want 3 attempts with 2 errors", hits.Load(), got)
+	}
+	if !slices.Equal(retries, []int{0, 1, 2}) {
+		t.Errorf("Retry fields = %v, want [0 1 2]", retries)
+	}
+}
+
+// recordingObserver records the engine events it receives.
+type recordingObserver struct {
+	NopObserver
+	events []string
+}
+
+func (o *recordingObserver) OnDispatch(t task.Task, retries int) {
+	o.events = append(o.events, fmt.Sprintf("dispatch %d", retries))
+}
+
+func (o *recordingObserver) OnResult(r task.Result) {
+	o.events = append(o.events, fmt.Sprintf("result %d", r.StatusCode))
+}
+
+func (o *recordingObserver) OnBackoff(ev BackoffEvent) {
+	o.events = append(o.events, fmt.Sprintf("backoff %d", ev.Result.StatusCode))
+}
+
+func (o *recordingObserver) OnReload(*config.Config) {
+	o.events = append(o.events, "reload")
+}
+
+func TestObserver_ReceivesEvents(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	target := config.TargetConfig{URL: srv.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}}
+	cfg := baseCfg([]config.TargetConfig{target})
+	cfg.Backoff.InitialMs = 10
+	cfg.Retry = config.RetryConfig{MaxRetries: 1, On: []string{"transient"}}
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	obs := &recordingObserver{}
+	remove := eng.AddObserver(obs)
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+	if err := eng.Reload(baseCfg([]config.TargetConfig{target})); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	want := []string{"dispatch 0", "result 503", "backoff 503", "dispatch 1", "result 200", "reload"}
+	if !slices.Equal(obs.events, want) {
+		t.Errorf("events = %v, want %v", obs.events, want)
+	}
+
+	remove()
+	if err := eng.Reload(baseCfg([]config.TargetConfig{target})); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(obs.events) != len(want) {
+		t.Errorf("events after remove = %v, want no more", obs.events[len(want):])
+	}
+}
+
+func TestDispatch_AbortedNeitherRetriedNorBackedOff(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	target := config.TargetConfig{URL: srv.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 5, AbortProbability: 1, AbortAfterMs: 20}}
+	cfg := baseCfg([]config.TargetConfig{target})
+	cfg.Retry = config.RetryConfig{MaxRetries: 3, On: []string{"transient"}}
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var results []task.Result
+	eng.SetObserver(func(r task.Result) { results = append(results, r) })
+	if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+
+	if hits.Load() != 1 || len(results) != 1 || !results[0].Aborted {
+		t.Fatalf("hits = %d, %d result(s); want one aborted request", hits.Load(), len(results))
+	}
+	if bo := eng.Backoff(); len(bo) != 0 {
+		t.Errorf("backoff = %+v, want none for an aborted request", bo)
+	}
+}
+
+func TestRetryPolicy_Next(t *testing.T) {
+	p := newRetryPolicy(config.RetryConfig{MaxRetries: 2, On: []string{"transient"}, BudgetPerMinute: 1})
+	if got := p.next(ratelimit.ErrorClassPermanent, 0); got != noRetry {
+		t.Errorf("permanent = %d, want noRetry", got)
+	}
+	if got := p.next(ratelimit.ErrorClassTransient, 2); got != noRetry {
+		t.Errorf("past max_retries = %d, want noRetry", got)
+	}
+	if got := p.next(ratelimit.ErrorClassTransient, 0); got != retryNow {
+		t.Errorf("first retry = %d, want retryNow", got)
+	}
+	if got := p.next(ratelimit.ErrorClassTransient, 0); got != retryBudgetExhausted {
+		t.Errorf("second retry in the minute = %d, want retryBudgetExhausted", got)
+	}
+	if got := newRetryPolicy(config.RetryConfig{On: []string{"transient"}}).next(ratelimit.ErrorClassTransient, 0); got != noRetry {
+		t.Errorf("max_retries 0 = %d, want noRetry", got)
+	}
+}
+
+// stuckDriver ignores its context and returns only once release is closed,
+// or with ctx.Err() when cooperative is set.
+type stuckDriver struct {
+	release     chan struct{}
+	cooperative bool
+}
+
+func (d stuckDriver) Execute(ctx context.Context, t task.Task) task.Result {
+	if d.cooperative {
+		<-ctx.Done()
+		return task.Result{Task: t, Error: ctx.Err()}
+	}
+	<-d.release
+	return task.Result{Task: t, StatusCode: 200}
+}
+
+func TestExecuteTask_Deadline(t *testing.T) {
+	defer func(g time.Duration) { deadlineGrace = g }(deadlineGrace)
+	deadlineGrace = 10 * time.Millisecond
+
+	release := make(chan struct{})
+	tk := task.Task{URL: "https://a.example.com", Type: "http", Config: config.TargetConfig{TaskDeadlineS: 1}}
+	for _, drv := range []stuckDriver{{release: release}, {cooperative: true}} {
+		start := time.Now()
+		r := executeTask(context.Background(), drv, tk)
+		if !errors.Is(r.Error, errTaskDeadline) {
+			t.Errorf("cooperative=%v: err = %v, want errTaskDeadline", drv.cooperative, r.Error)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("cooperative=%v: returned after %s, want about 1s", drv.cooperative, elapsed)
+		}
+	}
+
+	// Without a deadline the driver runs to completion.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if r := executeTask(context.Background(), stuckDriver{release: release}, task.Task{URL: tk.URL, Type: "http"}); r.Error != nil {
+			t.Errorf("no deadline: err = %v", r.Error)
+		}
+	}()
+	select {
+	case <-done:
+		t.Error("no deadline: returned before the driver did")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+}
+
+func TestMirrorTask_Pct(t *testing.T) {
+	cfg := config.TargetConfig{URL: "https://a.example.com", Type: "http", MirrorURL: "https://b.example.com", MirrorPct: 25}
+	picked := 0
+	for range 4000 {
+		if m, ok := mirrorTask(task.Task{URL: cfg.URL, Type: cfg.Type, Config: cfg}); ok {
+			picked++
+			if m.URL != cfg.MirrorURL || m.Config.URL != cfg.MirrorURL || m.Config.MirrorURL != "" {
+				t.Fatalf("mirror task = %+v", m)
+			}
+		}
+	}
+	if picked < 800 || picked > 1200 {
+		t.Errorf("mirrored %d of 4000 at mirror_pct 25, want about 1000", picked)
+	}
+	if _, ok := mirrorTask(task.Task{Config: config.TargetConfig{URL: cfg.URL}}); ok {
+		t.Error("target without mirror_url was mirrored")
+	}
+}
+
+func TestTargetStats_CountsPerURL(t *testing.T) {
+	eng, err := New(baseCfg([]config.TargetConfig{{URL: "https://a.example.com", Weight: 1, Type: "http"}}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	a := task.Task{URL: "https://a.example.com", Type: "http"}
+	eng.counters.record(task.Result{Task: a, StatusCode: 200, Duration: 10 * time.Millisecond})
+	eng.counters.record(task.Result{Task: a, StatusCode: 503, Duration: 30 * time.Millisecond})
+	eng.counters.record(task.Result{Task: task.Task{URL: "b.example.com", Type: "dns"}, StatusCode: 200})
+
+	stats := eng.TargetStats()
+	got := stats["https://a.example.com"]
+	if got.Requests != 2 || got.Errors != 1 || got.LastStatus != 503 || got.AvgLatency() != 20*time.Millisecond {
+		t.Errorf("a.example.com stats = %+v", got)
+	}
+	if len(stats) != 2 {
+		t.Errorf("want 2 targets with stats, got %d", len(stats))
+	}
+
+	types := eng.TypeStats()
+	if types["http"] != (TypeStats{Requests: 2, Errors: 1}) || types["dns"] != (TypeStats{Requests: 1}) {
+		t.Errorf("type stats = %+v", types)
+	}
+
+	// All three completed in the current second, which becomes the last
+	// whole second a second from now.
+	now := time.Now().Add(time.Second)
+	if got := eng.counters.rps(now, now.Add(-time.Hour)); got != 3.0/rateWindow {
+		t.Errorf("rps over a full window = %v, want %v", got, 3.0/rateWindow)
+	}
+	if got := eng.counters.rps(now, now.Add(-3*time.Second)); got != 1 {
+		t.Errorf("rps 3s after start = %v, want 1", got)
+	}
+}
+
+func TestWaitStatsAndBackoff(t *testing.T) {
+	eng, err := New(baseCfg([]config.TargetConfig{{URL: "https://a.example.com", Weight: 1, Type: "http"}}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	eng.counters.recordWait("a.example.com", 2*time.Second, 0)
+	eng.counters.recordWait("a.example.com", time.Second, 5*time.Second)
+	eng.counters.recordWait("b.example.com", 0, 0)
+	waits := eng.WaitStats()
+	if len(waits) != 1 || waits["a.example.com"] != (WaitStats{RateLimit: 3 * time.Second, Backoff: 5 * time.Second}) {
+		t.Errorf("wait stats = %+v", waits)
+	}
+
+	eng.backoff.Load().RecordError("a.example.com")
+	bo := eng.Backoff()
+	if len(bo) != 1 || bo[0].Domain != "a.example.com" || bo[0].Attempts != 1 || !bo[0].NextAllowed.After(time.Now()) {
+		t.Errorf("backoff = %+v", bo)
+	}
+}
+
+func TestDump(t *testing.T) {
+	eng, err := New(baseCfg([]config.TargetConfig{{URL: "https://a.example.com", Weight: 1, Type: "http"}}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	eng.counters.recordWait("a.example.com", time.Second, 0)
+	eng.counters.recordWait("b.example.com", 0, 3*time.Second)
+	eng.backoff.Load().RecordError("b.example.com")
+	for _, r := range []task.Result{
+		{Task: task.Task{URL: "https://a.example.com/"}, StatusCode: 503},
+		{Task: task.Task{URL: "https://b.example.com/"}, StatusCode: 500},
+		{Task: task.Task{URL: "https://b.example.com/"}, StatusCode: 500},
+		{Task: task.Task{URL: "https://c.example.com/"}, StatusCode: 200},
+	} {
+		eng.counters.record(r)
+	}
+	if err := eng.pool.Acquire(context.Background(), "browser"); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	defer eng.pool.Release("browser")
+
+	d := eng.LogDump()
+	if d.Goroutines <= 0 || d.Workers != 1 || d.MaxWorkers != 2 || d.BrowserWorkers != 1 || d.MaxBrowserWorkers != 1 {
+		t.Errorf("pool = %+v", d)
+	}
+	if len(d.Domains) != 2 || d.Domains[0].Domain != "b.example.com" || d.Domains[0].Backoff == nil || d.Domains[1].Backoff != nil {
+		t.Errorf("domains = %+v, want b.example.com (backing off) before a.example.com", d.Domains)
+	}
+	if d.Domains[1].LimitRPS != 10 {
+		t.Errorf("a.example.com limit = %v, want 10", d.Domains[1].LimitRPS)
+	}
+	if len(d.TopErrors) != 2 || d.TopErrors[0].URL != "https://b.example.com/" || d.TopErrors[0].Errors != 2 {
+		t.Errorf("top errors = %+v", d.TopErrors)
+	}
+}
+
+func TestDispatch_SkipsDomainInCooldown(t *testing.T) {
+	cfg := baseCfg([]config.TargetConfig{{URL: "https://a.example.com", Weight: 1, Type: "http"}})
+	cfg.Backoff.MaxAttempts = 1
+	cfg.Backoff.CooldownS = 60
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	eng.drivers["http"] = noopDriver{}
+	var dispatched atomic.Int64
+	eng.SetObserver(func(task.Result) { dispatched.Add(1) })
+
+	eng.backoff.Load().RecordError("a.example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := eng.pool.Acquire(ctx, "http"); err != nil {
+		t.Fatalf("pool.Acquire: %v", err)
+	}
+	eng.dispatch(ctx, task.Task{URL: "https://a.example.com", Type: "http", Config: cfg.Targets[0]})
+
+	if ctx.Err() != nil || dispatched.Load() != 0 {
+		t.Errorf("dispatch during cooldown: sent %d task(s), ctx err %v; want it skipped at once", dispatched.Load(), ctx.Err())
+	}
+	if bo := eng.Backoff(); len(bo) != 1 || !bo[0].Cooldown {
+		t.Errorf("backoff = %+v, want a.example.com in cooldown", bo)
+	}
+}
+
+func TestCrawlDelays_FetchesRobotsOncePerHost(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		fetches.Add(1)
+		_, _ = w.Write([]byte("User-agent: *\nCrawl-delay: 1.5\n"))
+	}))
+	defer srv.Close()
+
+	c := newCrawlDelays(driver.NewHTTPDriver().Client)
+	for _, path := range []string{"/a", "/b?q=1"} {
+		if d := c.delay(context.Background(), srv.URL+path, config.TargetConfig{}); d != 1500*time.Millisecond {
+			t.Errorf("delay(%s) = %v, want 1.5s", path, d)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("robots.txt fetched %d times, want once", n)
+	}
+
+	// A host without a robots.txt has no delay.
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	if d := c.delay(context.Background(), missing.URL, config.TargetConfig{}); d != 0 {
+		t.Errorf("delay without robots.txt = %v, want 0", d)
+	}
+}
+
+func TestCrawlDelays_RetriesAfterFailure(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("User-agent: *\nCrawl-delay: 2\n"))
+	}))
+	defer srv.Close()
+
+	c := newCrawlDelays(driver.NewHTTPDriver().Client)
+	// A caller whose context is already done fetches nothing, and leaves
+	// the fetch to the next caller.
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if d := c.delay(cancelled, srv.URL, config.TargetConfig{}); d != 0 {
+		t.Errorf("delay with a cancelled context = %v, want 0", d)
+	}
+	if d := c.delay(context.Background(), srv.URL, config.TargetConfig{}); d != 0 {
+		t.Errorf("delay after a 503 = %v, want 0", d)
+	}
+	if d := c.delay(context.Background(), srv.URL, config.TargetConfig{}); d != 0 || fetches.Load() != 1 {
+		t.Errorf("delay = %v after %d fetches, want 0 and no refetch before robotsRetry", d, fetches.Load())
+	}
+
+	u, _ := url.Parse(srv.URL)
+	c.hosts[u.Host].retryAt = time.Now()
+	if d := c.delay(context.Background(), srv.URL, config.TargetConfig{}); d != 2*time.Second {
+		t.Errorf("delay after retrying = %v, want 2s", d)
+	}
+}
+
+func TestBlackouts_At(t *testing.T) {
+	day := func(d, h, m int) time.Time { return time.Date(2026, 12, d, h, m, 0, 0, time.Local) }
+	bs := *newBlackouts([]config.BlackoutConfig{
+		{Name: "freeze", Start: "2026-12-20", End: "2026-12-27"},
+		{Name: "maintenance", Cron: "0 2 * * *", DurationMinutes: 60},
+	})
+	for _, tc := range []struct {
+		now   time.Time
+		name  string // "" when no blackout is in effect
+		until time.Time
+	}{
+		{day(19, 23, 59), "", time.Time{}},
+		{day(20, 0, 0), "freeze", day(28, 0, 0)},
+		{day(27, 23, 59), "freeze", day(28, 0, 0)}, // the end date is included
+		{day(28, 0, 0), "", time.Time{}},
+		{day(29, 2, 30), "maintenance", day(29, 3, 0)},
+		{day(29, 3, 0), "", time.Time{}},
+	} {
+		name, until, ok := bs.at(tc.now)
+		if name != tc.name || ok != (tc.name != "") || !until.Equal(tc.until) {
+			t.Errorf("at %v: got %q until %v (%v), want %q until %v", tc.now, name, until, ok, tc.name, tc.until)
+		}
+	}
+
+	// Of overlapping blackouts, the one that ends last is reported.
+	if name, _, _ := bs.at(day(22, 2, 30)); name != "freeze" {
+		t.Errorf("overlap: got %q, want freeze", name)
+	}
+}
+
+func TestBlackout_HoldsDispatchUntilReloaded(t *testing.T) {
+	defer func(d time.Duration) { blackoutRecheck = d }(blackoutRecheck)
+	blackoutRecheck = 10 * time.Millisecond
+
+	cfg := baseCfg([]config.TargetConfig{{URL: "https://a.example.com", Weight: 1, Type: "http"}})
+	cfg.Blackouts = []config.BlackoutConfig{{Name: "always", Cron: "* * * * *", DurationMinutes: 5}}
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if st := eng.Status(); st.Blackout != "always" || st.BlackoutUntil.IsZero() {
+		t.Errorf("status blackout = %q until %v, want always", st.Blackout, st.BlackoutUntil)
+	}
+
+	released := make(chan error, 1)
+	go func() { released <- eng.waitOutBlackout(context.Background()) }()
+	select {
+	case <-released:
+		t.Fatal("waitOutBlackout returned in a blackout")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	next := *cfg
+	next.Blackouts = nil
+	if err := eng.Reload(&next); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Errorf("waitOutBlackout = %v after the blackout was removed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitOutBlackout still blocked after the blackout was removed")
+	}
+}
+
+func TestPause_HoldsDispatchUntilResume(t *testing.T) {
+	eng, err := New(baseCfg([]config.TargetConfig{{URL: "https://a.example.com", Weight: 1, Type: "http"}}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := eng.waitWhilePaused(context.Background()); err != nil {
+		t.Fatalf("not paused: waitWhilePaused = %v", err)
+	}
+
+	if !eng.Pause() || eng.Pause() {
+		t.Fatal("Pause should report a change only the first time")
+	}
+	if paused, since := eng.Paused(); !paused || since.IsZero() {
+		t.Errorf("Paused() = %v, %v", paused, since)
+	}
+	released := make(chan error, 1)
+	go func() { released <- eng.waitWhilePaused(context.Background()) }()
+	select {
+	case <-released:
+		t.Fatal("waitWhilePaused returned while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !eng.Resume() || eng.Resume() {
+		t.Fatal("Resume should report a change only the first time")
+	}
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Errorf("waitWhilePaused = %v after Resume", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waitWhilePaused still blocked after Resume")
+	}
+
+	eng.Pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := eng.waitWhilePaused(ctx); err == nil {
+		t.Error("waitWhilePaused should return the context error when cancelled while paused")
+	}
+}
+
+// sliceObserver is not comparable, so == on it as an Observer panics.
+type sliceObserver struct {
+	NopObserver
+	seen *[]string
+	tags []string
+}
+
+func (o sliceObserver) OnReload(*config.Config) { *o.seen = append(*o.seen, o.tags...) }
+
+func TestAddObserver_RemoveNonComparable(t *testing.T) {
+	cfg := baseCfg([]config.TargetConfig{{URL: "https://a.example.com", Weight: 1, Type: "http"}})
+	eng, err := New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var seen []string
+	removeA := eng.AddObserver(sliceObserver{seen: &seen, tags: []string{"a"}})
+	eng.AddObserver(sliceObserver{seen: &seen, tags: []string{"b"}})
+	removeA()
+	removeA()
+	if err := eng.Reload(cfg); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !slices.Equal(seen, []string{"b"}) {
+		t.Errorf("reload reached %v, want only the observer left", seen)
+	}
+}
+
+func TestSetObserver_Replaces(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	target := config.TargetConfig{URL: srv.URL, Type: "http", Weight: 1, HTTP: config.HTTPConfig{TimeoutS: 1}}
+	eng, err := New(baseCfg([]config.TargetConfig{target}), metrics.Noop())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var first, second atomic.Int32
+	eng.SetObserver(func(task.Result) { first.Add(1) })
+	eng.SetObserver(func(task.Result) { second.Add(1) })
+	send := func() {
+		if err := eng.pool.Acquire(context.Background(), target.Type); err != nil {
+			t.Fatalf("pool.Acquire: %v", err)
+		}
+		eng.dispatch(context.Background(), task.Task{URL: target.URL, Type: target.Type, Config: target})
+	}
+	send()
+	eng.SetObserver(nil)
+	send()
+	if first.Load() != 0 || second.Load() != 1 {
+		t.Errorf("calls = %d first, %d second; want only the current function, until cleared", first.Load(), second.Load())
+	}
+}
@@ -527,6 +527,47 @@ func TestIntegration_BurstMode(t *testing.T) {
 	}
 }
 
+// TestIntegration_ClosedLoadModel verifies that in the closed load model no
+// more requests are in flight than there are virtual users, even in burst
+// mode with spare worker slots.
+func TestIntegration_ClosedLoadModel(t *testing.T) {
+	var inFlight, peak, total atomic.Int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		total.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	cfg := testCfg([]config.TargetConfig{
+		{URL: srv.URL, Type: "http", Weight: 1},
+	})
+	cfg.Pacing.Mode = "burst"
+	cfg.Pacing.LoadModel = "closed"
+	cfg.Pacing.VirtualUsers = 2
+
+	eng, err := engine.New(cfg, metrics.Noop())
+	if err != nil {
+		t.Fatalf("engine.New: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	eng.Run(ctx)
+
+	if n := total.Load(); n < 4 {
+		t.Errorf("expected >= 4 requests, got %d", n)
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak in-flight requests = %d, want <= 2 virtual users", p)
+	}
+}
+
 // TestIntegration_OutputWriter_JSONL verifies that enabling output.enabled with
 // format=jsonl causes the engine to write valid newline-delimited JSON records
 // containing url, status, and duration_ms fields.
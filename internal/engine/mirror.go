@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math/rand"
+
+	"github.com/google/uuid"
+	"github.com/lewta/sendit/internal/driver"
+	"github.com/lewta/sendit/internal/metrics"
+	"github.com/lewta/sendit/internal/ratelimit"
+	"github.com/lewta/sendit/internal/task"
+)
+
+// execute runs t on drv, within its task deadline. When the target has a
+// mirror_url and this request is picked for mirroring, a copy of t aimed at
+// the mirror runs alongside it, and both results carry one correlation_id.
+// The mirror result is nil otherwise, or when the mirror was not sent.
+func (e *Engine) execute(ctx context.Context, drv driver.Driver, t task.Task, rl *ratelimit.Registry, bo *ratelimit.BackoffRegistry) (task.Result, *task.Result) {
+	m, ok := mirrorTask(t)
+	if !ok {
+		return executeTask(ctx, drv, t), nil
+	}
+
+	done := make(chan *task.Result, 1)
+	go func() { done <- e.sendMirror(ctx, drv, m, hostname(t.URL), rl, bo) }()
+	result := executeTask(ctx, drv, t)
+	mirror := <-done
+	if mirror == nil {
+		return result, nil
+	}
+
+	id := uuid.NewString()
+	result.Meta = tagMirror(result.Meta, id, "primary")
+	mirror.Meta = tagMirror(mirror.Meta, id, "mirror")
+	return result, mirror
+}
+
+// sendMirror sends m like any other task to its host: it takes a worker
+// slot of its own, waits out the host's backoff and rate limit, and is held
+// back by a blackout. It returns nil when the mirror is skipped because no
+// worker slot is free, its host is in cooldown, a blackout started, or ctx
+// was cancelled. The outcome feeds the mirror host's backoff only when that
+// host differs from primaryHost, so a failing canary on the same host never
+// slows the traffic it is compared against.
+func (e *Engine) sendMirror(ctx context.Context, drv driver.Driver, m task.Task, primaryHost string, rl *ratelimit.Registry, bo *ratelimit.BackoffRegistry) *task.Result {
+	host := hostname(m.URL)
+	tl := e.taskLogger()
+	if !e.pool.TryAcquire(m.Type) {
+		tl.Debug().Str("url", m.URL).Msg("no free worker slot, skipping mirror request")
+		return nil
+	}
+	defer e.pool.Release(m.Type)
+
+	if err := bo.Wait(ctx, host); err != nil {
+		if errors.Is(err, ratelimit.ErrCooldown) {
+			e.metrics.RecordSkipped(host, metrics.SkipCooldown)
+			tl.Debug().Str("url", m.URL).Msg("mirror domain in cooldown, skipping mirror request")
+		}
+		return nil
+	}
+	if err := rl.Wait(ctx, host); err != nil {
+		return nil
+	}
+	if name, _, ok := e.Blackout(); ok {
+		tl.Debug().Str("url", m.URL).Str("blackout", name).Msg("blackout started, skipping mirror request")
+		return nil
+	}
+
+	result := executeTask(ctx, drv, m)
+	if host != primaryHost && !result.Aborted {
+		class := ratelimit.ClassifyError(result.Error)
+		if result.Error == nil {
+			class = ratelimit.ClassifyStatusCode(result.StatusCode)
+		}
+		switch class {
+		case ratelimit.ErrorClassTransient:
+			if bo.Attempts(host) < bo.MaxAttemptsFor(host) {
+				bo.RecordError(host)
+			}
+		case ratelimit.ErrorClassNone:
+			bo.RecordSuccess(host)
+		}
+	}
+	return &result
+}
+
+// mirrorTask returns the copy of t to send to its mirror_url, reporting
+// false when the target has none or this request was not picked.
+func mirrorTask(t task.Task) (task.Task, bool) {
+	c := t.Config
+	if c.MirrorURL == "" {
+		return task.Task{}, false
+	}
+	if pct := c.MirrorPct; pct > 0 && pct < 100 && rand.Float64()*100 >= pct { //nolint:gosec
+		return task.Task{}, false
+	}
+	m := t
+	m.URL = c.MirrorURL
+	m.Config.URL = c.MirrorURL
+	m.Config.MirrorURL = ""
+	return m, true
+}
+
+// tagMirror copies meta with the correlation_id shared by a primary and
+// mirror pair, and which of the two it belongs to.
+func tagMirror(meta map[string]string, id, role string) map[string]string {
+	out := make(map[string]string, len(meta)+2)
+	maps.Copy(out, meta)
+	out["correlation_id"] = id
+	out["mirror"] = role
+	return out
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+)
+
+// Observer receives engine events, so that a program embedding the engine
+// can react to them. Methods are called synchronously on the goroutine
+// that raised the event — the dispatch loop, a worker, or the caller of
+// Reload — so they must return quickly and be safe for concurrent use.
+// Embed NopObserver to implement only some of them.
+type Observer interface {
+	// OnDispatch is called as t is handed to its driver, once pacing,
+	// backoff, and rate limits have let it through; retries is how many
+	// times t was sent before.
+	OnDispatch(t task.Task, retries int)
+	// OnResult is called with the result of every request, mirrored ones
+	// included.
+	OnResult(r task.Result)
+	// OnBackoff is called when a transient error backs a domain off.
+	OnBackoff(ev BackoffEvent)
+	// OnReload is called once Reload has put cfg into effect.
+	OnReload(cfg *config.Config)
+}
+
+// BackoffEvent describes a domain backing off after a transient error.
+type BackoffEvent struct {
+	Domain string
+	// Delay is how long the domain's requests are held.
+	Delay time.Duration
+	// Cooldown reports that the domain reached backoff.max_attempts and
+	// its tasks are skipped for Delay.
+	Cooldown bool
+	// Result is the request that failed.
+	Result task.Result
+}
+
+// NopObserver implements Observer with methods that do nothing.
+type NopObserver struct{}
+
+func (NopObserver) OnDispatch(task.Task, int) {}
+func (NopObserver) OnResult(task.Result)      {}
+func (NopObserver) OnBackoff(BackoffEvent)    {}
+func (NopObserver) OnReload(*config.Config)   {}
+
+// AddObserver registers o to receive engine events, after any observers
+// added before it, and returns a function that removes it again. It is
+// safe to call before or after Run, and o need not be comparable.
+func (e *Engine) AddObserver(o Observer) (remove func()) {
+	e.obsMu.Lock()
+	defer e.obsMu.Unlock()
+	ent := e.addObserver(o)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			e.obsMu.Lock()
+			defer e.obsMu.Unlock()
+			e.removeObserver(ent)
+		})
+	}
+}
+
+// SetObserver registers fn to be called with the result of every request,
+// in place of the function passed before; nil clears it. It is an Observer
+// with only OnResult, registered like one passed to AddObserver, and is
+// safe to call before or after Run.
+func (e *Engine) SetObserver(fn func(task.Result)) {
+	e.obsMu.Lock()
+	defer e.obsMu.Unlock()
+	if e.resultObs != nil {
+		e.removeObserver(e.resultObs)
+		e.resultObs = nil
+	}
+	if fn != nil {
+		e.resultObs = e.addObserver(resultObserver{fn: fn})
+	}
+}
+
+// observerEntry is the registration of an Observer; entries are told apart
+// by address, so the observers themselves are never compared.
+type observerEntry struct{ o Observer }
+
+// resultObserver is the Observer registered by SetObserver.
+type resultObserver struct {
+	NopObserver
+	fn func(task.Result)
+}
+
+func (r resultObserver) OnResult(res task.Result) { r.fn(res) }
+
+// addObserver appends o to the observers. The caller holds e.obsMu.
+func (e *Engine) addObserver(o Observer) *observerEntry {
+	ent := &observerEntry{o: o}
+	var obs []*observerEntry
+	if old := e.observers.Load(); old != nil {
+		obs = slices.Clone(*old)
+	}
+	obs = append(obs, ent)
+	e.observers.Store(&obs)
+	return ent
+}
+
+// removeObserver drops ent from the observers. The caller holds e.obsMu.
+func (e *Engine) removeObserver(ent *observerEntry) {
+	old := e.observers.Load()
+	if old == nil {
+		return
+	}
+	obs := slices.DeleteFunc(slices.Clone(*old), func(x *observerEntry) bool { return x == ent })
+	e.observers.Store(&obs)
+}
+
+// notify calls fn with every registered observer.
+func (e *Engine) notify(fn func(Observer)) {
+	if obs := e.observers.Load(); obs != nil {
+		for _, ent := range *obs {
+			fn(ent.o)
+		}
+	}
+}
+
+// notifyBackoff tells the observers that domain backed off for delay.
+func (e *Engine) notifyBackoff(domain string, delay time.Duration, cooldown bool, result task.Result) {
+	ev := BackoffEvent{Domain: domain, Delay: delay, Cooldown: cooldown, Result: result}
+	e.notify(func(o Observer) { o.OnBackoff(ev) })
+}
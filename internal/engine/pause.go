@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// pauseGate holds the dispatch loop while the engine is paused. resumed is
+// closed (and replaced) on Resume, waking every waiter at once.
+type pauseGate struct {
+	mu      sync.Mutex
+	paused  bool
+	since   time.Time
+	resumed chan struct{}
+}
+
+// Pause stops the dispatch loop from starting new requests until Resume.
+// Requests already in flight finish normally, and the process, its drivers,
+// rate limiters, and backoff state stay as they are. It reports whether
+// the call changed anything, i.e. false when already paused.
+func (e *Engine) Pause() bool {
+	g := &e.pause
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused {
+		return false
+	}
+	g.paused, g.since = true, time.Now()
+	g.resumed = make(chan struct{})
+	log.Info().Msg("dispatch paused")
+	return true
+}
+
+// Resume lets a paused dispatch loop continue. It reports whether the
+// engine was paused.
+func (e *Engine) Resume() bool {
+	g := &e.pause
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return false
+	}
+	close(g.resumed)
+	log.Info().Dur("paused_for", time.Since(g.since).Round(time.Second)).Msg("dispatch resumed")
+	g.paused, g.since = false, time.Time{}
+	return true
+}
+
+// Paused reports whether dispatch is paused and, if so, since when.
+func (e *Engine) Paused() (paused bool, since time.Time) {
+	e.pause.mu.Lock()
+	defer e.pause.mu.Unlock()
+	return e.pause.paused, e.pause.since
+}
+
+// waitWhilePaused blocks until the engine is not paused or ctx is done.
+func (e *Engine) waitWhilePaused(ctx context.Context) error {
+	e.pause.mu.Lock()
+	paused, resumed := e.pause.paused, e.pause.resumed
+	e.pause.mu.Unlock()
+	if !paused {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -6,18 +6,25 @@ import (
 )
 
 // Pool manages a global concurrency semaphore and an optional browser sub-semaphore.
+// The browser limit can change while tasks run; see SetBrowserLimit.
 type Pool struct {
-	global  chan struct{}
-	browser chan struct{}
-	wg      sync.WaitGroup
+	global chan struct{}
+	wg     sync.WaitGroup
+
+	mu           sync.Mutex
+	cond         *sync.Cond // signalled when a browser slot frees or the limit grows
+	browserLimit int
+	browserInUse int
 }
 
 // NewPool creates a Pool with the given global and browser worker limits.
 func NewPool(maxWorkers, maxBrowserWorkers int) *Pool {
-	return &Pool{
-		global:  make(chan struct{}, maxWorkers),
-		browser: make(chan struct{}, maxBrowserWorkers),
+	p := &Pool{
+		global:       make(chan struct{}, maxWorkers),
+		browserLimit: maxBrowserWorkers,
 	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
 }
 
 // Acquire obtains a global slot (and a browser slot for browser tasks).
@@ -32,11 +39,9 @@ func (p *Pool) Acquire(ctx context.Context, taskType string) error {
 
 	// Browser sub-slot.
 	if taskType == "browser" {
-		select {
-		case p.browser <- struct{}{}:
-		case <-ctx.Done():
+		if err := p.acquireBrowser(ctx); err != nil {
 			<-p.global
-			return ctx.Err()
+			return err
 		}
 	}
 
@@ -44,15 +49,85 @@ func (p *Pool) Acquire(ctx context.Context, taskType string) error {
 	return nil
 }
 
+// TryAcquire obtains the slots Acquire would without blocking, reporting
+// false when one is not free.
+func (p *Pool) TryAcquire(taskType string) bool {
+	select {
+	case p.global <- struct{}{}:
+	default:
+		return false
+	}
+	if taskType == "browser" {
+		p.mu.Lock()
+		if p.browserInUse >= p.browserLimit {
+			p.mu.Unlock()
+			<-p.global
+			return false
+		}
+		p.browserInUse++
+		p.mu.Unlock()
+	}
+	p.wg.Add(1)
+	return true
+}
+
+func (p *Pool) acquireBrowser(ctx context.Context) error {
+	// Wake the wait below when ctx is cancelled.
+	stop := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.cond.Broadcast()
+	})
+	defer stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.browserInUse >= p.browserLimit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.cond.Wait()
+	}
+	p.browserInUse++
+	return nil
+}
+
 // Release frees the slots acquired for the given task type.
 func (p *Pool) Release(taskType string) {
 	if taskType == "browser" {
-		<-p.browser
+		p.mu.Lock()
+		p.browserInUse--
+		p.mu.Unlock()
+		p.cond.Broadcast()
 	}
 	<-p.global
 	p.wg.Done()
 }
 
+// SetBrowserLimit changes how many browser tasks may run at once. Lowering
+// it lets running tasks finish and holds new ones until the count drops
+// below n.
+func (p *Pool) SetBrowserLimit(n int) {
+	p.mu.Lock()
+	p.browserLimit = n
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+// BrowserLimit returns how many browser tasks may run at once.
+func (p *Pool) BrowserLimit() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.browserLimit
+}
+
+// InUse returns the number of global and browser slots currently held.
+func (p *Pool) InUse() (global, browser int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.global), p.browserInUse
+}
+
 // Wait blocks until all in-flight tasks have completed.
 func (p *Pool) Wait() {
 	p.wg.Wait()
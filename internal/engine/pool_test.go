@@ -182,3 +182,39 @@ func TestPool_MaxConcurrency(t *testing.T) {
 		t.Error("peak concurrency should be > 0")
 	}
 }
+
+func TestPool_SetBrowserLimit(t *testing.T) {
+	p := NewPool(4, 2)
+	p.SetBrowserLimit(1)
+	ctx := context.Background()
+
+	if err := p.Acquire(ctx, "browser"); err != nil {
+		t.Fatalf("first browser Acquire: %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- p.Acquire(ctx, "browser") }()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("second browser Acquire returned %v under a limit of 1", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Raising the limit admits the waiting task.
+	p.SetBrowserLimit(2)
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("second browser Acquire: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second browser Acquire still blocked after raising the limit")
+	}
+
+	if _, browser := p.InUse(); browser != 2 {
+		t.Errorf("browser in use = %d, want 2", browser)
+	}
+	p.Release("browser")
+	p.Release("browser")
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+// retryPolicy decides whether a failed task is dispatched again, from the
+// retry config section.
+type retryPolicy struct {
+	max    int
+	on     map[ratelimit.ErrorClass]bool
+	budget *rate.Limiter // nil = unlimited
+}
+
+func newRetryPolicy(cfg config.RetryConfig) *retryPolicy {
+	p := &retryPolicy{max: cfg.MaxRetries, on: make(map[ratelimit.ErrorClass]bool, len(cfg.On))}
+	for _, class := range cfg.On {
+		switch class {
+		case "transient":
+			p.on[ratelimit.ErrorClassTransient] = true
+		case "permanent":
+			p.on[ratelimit.ErrorClassPermanent] = true
+		}
+	}
+	if n := cfg.BudgetPerMinute; n > 0 {
+		p.budget = rate.NewLimiter(rate.Limit(float64(n)/60), n)
+	}
+	return p
+}
+
+// Outcomes of retryPolicy.next.
+const (
+	noRetry = iota
+	retryNow
+	retryBudgetExhausted
+)
+
+// next decides what follows an attempt of a task that ended in class, given
+// how many times the task has been retried already. A retry the policy
+// allows takes a token from the budget, and is refused when none is left.
+func (p *retryPolicy) next(class ratelimit.ErrorClass, retries int) int {
+	if retries >= p.max || !p.on[class] {
+		return noRetry
+	}
+	if p.budget != nil && !p.budget.Allow() {
+		return retryBudgetExhausted
+	}
+	return retryNow
+}
@@ -3,6 +3,8 @@ package engine
 import (
 	"context"
 	"math/rand"
+	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -24,12 +26,23 @@ type Scheduler struct {
 	// activeRPM is used in rate_limited / scheduled mode.
 	activeRPM atomic.Value // stores float64
 
+	// jitterFactor is the fraction of the interval between requests by which
+	// rate_limited / scheduled mode delays each one; reloadable.
+	jitterFactor atomic.Value // stores float64
+
 	// inWindow indicates whether a cron window is currently active.
 	inWindow atomic.Bool
 
+	// shares are the target groups the open windows drive, each with its
+	// part of activeRPM.
+	shares atomic.Pointer[[]windowShare]
+
 	// limiter is only set in rate_limited / scheduled mode; nil otherwise.
 	limiter atomic.Pointer[rate.Limiter]
 
+	// burst is the bucket size of limiter (rate_limits.burst).
+	burst atomic.Int64
+
 	// startedAt records when the scheduler was created. Used by burst mode
 	// to compute the linear ramp-up delay.
 	startedAt time.Time
@@ -37,6 +50,37 @@ type Scheduler struct {
 	// scheduledRecheckEvery controls how often scheduled mode rechecks whether a
 	// cron window has opened while dispatch is paused.
 	scheduledRecheckEvery time.Duration
+
+	// The scheduled mode's windows, as a reload leaves them; mu guards them
+	// and the cron that opens them.
+	mu       sync.Mutex
+	schedule []config.ScheduleEntry
+	overlap  string        // pacing.schedule_overlap
+	cron     *cron.Cron    // nil until Start, and after ctx is done
+	open     []*openWindow // in the order they opened
+}
+
+// windowShare is a target group driven by the open windows and its rate.
+type windowShare struct {
+	group string // "" means all targets
+	rpm   float64
+}
+
+// openWindow is an open scheduled window and the timer that closes it.
+type openWindow struct {
+	entry    config.ScheduleEntry
+	opened   time.Time
+	closesAt time.Time
+	close    *time.Timer
+}
+
+// closeAfter (re)arms w's close timer for d from now; mu must be held.
+func (s *Scheduler) closeAfter(w *openWindow, d time.Duration) {
+	if w.close != nil {
+		w.close.Stop()
+	}
+	w.closesAt = time.Now().Add(d)
+	w.close = time.AfterFunc(d, func() { s.closeWindow(w) })
 }
 
 // NewScheduler creates a Scheduler from the pacing config.
@@ -45,16 +89,20 @@ func NewScheduler(cfg config.PacingConfig) *Scheduler {
 		cfg:                   cfg,
 		startedAt:             time.Now(),
 		scheduledRecheckEvery: 5 * time.Second,
+		schedule:              cfg.Schedule,
+		overlap:               cfg.ScheduleOverlap,
 	}
 
 	s.minDelayMs.Store(int64(cfg.MinDelayMs))
 	s.maxDelayMs.Store(int64(cfg.MaxDelayMs))
+	s.jitterFactor.Store(cfg.JitterFactor)
+	s.burst.Store(1)
 
 	switch cfg.Mode {
 	case "rate_limited":
 		rpm := cfg.RequestsPerMinute
 		s.activeRPM.Store(rpm)
-		s.limiter.Store(rate.NewLimiter(rate.Limit(rpm/60.0), 1))
+		s.limiter.Store(s.newLimiter(rpm))
 	case "scheduled":
 		s.inWindow.Store(false)
 	default: // human, burst
@@ -69,54 +117,224 @@ func (s *Scheduler) Start(ctx context.Context) {
 		return
 	}
 
-	// A single AfterFunc timer replaces the per-window goroutine that was
-	// previously spawned on every cron firing. This prevents goroutine
-	// accumulation when the same window fires many times over a long run.
-	var (
-		closeMu    sync.Mutex
-		closeTimer *time.Timer
-	)
+	s.mu.Lock()
+	s.cron = s.newCron(s.schedule)
+	s.cron.Start()
+	s.catchUp(time.Now())
+	s.mu.Unlock()
 
-	c := cron.New()
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cron.Stop()
+		s.cron = nil
+		// Stop pending window-close timers so they don't fire after shutdown.
+		for _, w := range s.open {
+			w.close.Stop()
+		}
+	}()
+}
 
-	for _, entry := range s.cfg.Schedule {
+// newCron returns a cron, not yet started, that opens the windows in
+// entries.
+func (s *Scheduler) newCron(entries []config.ScheduleEntry) *cron.Cron {
+	c := cron.New()
+	for _, entry := range entries {
 		e := entry // capture
-		_, err := c.AddFunc(e.Cron, func() {
-			rpm := e.RequestsPerMinute
-			log.Info().Float64("rpm", rpm).Msg("scheduled window opening")
-			lim := rate.NewLimiter(rate.Limit(rpm/60.0), 1)
-			s.limiter.Store(lim)
-			s.activeRPM.Store(rpm)
-			s.inWindow.Store(true)
-
-			// Reset the single close timer so only one window-close is pending.
-			duration := time.Duration(e.DurationMinutes) * time.Minute
-			closeMu.Lock()
-			if closeTimer != nil {
-				closeTimer.Stop()
-			}
-			closeTimer = time.AfterFunc(duration, func() {
-				s.inWindow.Store(false)
-				log.Info().Msg("scheduled window closed")
-			})
-			closeMu.Unlock()
-		})
-		if err != nil {
+		if _, err := c.AddFunc(e.Cron, func() { s.openWindow(c, e) }); err != nil {
 			log.Error().Err(err).Str("cron", e.Cron).Msg("invalid cron expression")
 		}
 	}
+	return c
+}
 
-	c.Start()
-	go func() {
-		<-ctx.Done()
-		c.Stop()
-		// Stop any pending window-close timer so it doesn't fire after shutdown.
-		closeMu.Lock()
-		if closeTimer != nil {
-			closeTimer.Stop()
+// openWindow opens the window of e, fired by cron c. A window of e that is
+// still open from an earlier firing is restarted rather than doubled, so
+// that one window-close per entry is pending, however often windows fire
+// over a long run.
+func (s *Scheduler) openWindow(c *cron.Cron, e config.ScheduleEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c != s.cron {
+		return // fired as a reload or shutdown stopped c
+	}
+	log.Info().Float64("rpm", e.RequestsPerMinute).Str("group", e.Group).Msg("scheduled window opening")
+	s.startWindow(e, time.Now())
+}
+
+// startWindow opens e's window as having opened at opened; mu must be held.
+func (s *Scheduler) startWindow(e config.ScheduleEntry, opened time.Time) {
+	if i := s.openIndex(e); i >= 0 {
+		s.open[i].close.Stop()
+		s.open = slices.Delete(s.open, i, i+1)
+	}
+	w := &openWindow{entry: e, opened: opened}
+	s.open = append(s.open, w)
+	slices.SortStableFunc(s.open, func(a, b *openWindow) int { return a.opened.Compare(b.opened) })
+	s.applyOpen()
+	s.closeAfter(w, max(time.Until(w.closes()), 0))
+}
+
+// openIndex returns the index in s.open of the window of e, matched by cron
+// expression and group, or -1 when it is not open; mu must be held.
+func (s *Scheduler) openIndex(e config.ScheduleEntry) int {
+	return slices.IndexFunc(s.open, func(w *openWindow) bool { return sameWindow(w.entry, e) })
+}
+
+// sameWindow reports whether a and b are the same window of the schedule,
+// perhaps at different rates or durations after a reload.
+func sameWindow(a, b config.ScheduleEntry) bool {
+	return a.Cron == b.Cron && a.Group == b.Group
+}
+
+// closes returns when w's window ends.
+func (w *openWindow) closes() time.Time {
+	return w.opened.Add(time.Duration(w.entry.DurationMinutes) * time.Minute)
+}
+
+// catchUp opens the windows that would be open at now had the scheduler
+// been running when they fired, so that starting mid-window, as after a
+// restart, does not leave them shut until their next firing. They open in
+// the order they fired, as they would have. mu must be held.
+func (s *Scheduler) catchUp(now time.Time) {
+	type firing struct {
+		entry config.ScheduleEntry
+		at    time.Time
+	}
+	var missed []firing
+	for _, e := range s.schedule {
+		if s.openIndex(e) >= 0 {
+			continue
 		}
-		closeMu.Unlock()
-	}()
+		if at, ok := lastFiring(e, now); ok {
+			missed = append(missed, firing{e, at})
+		}
+	}
+	slices.SortStableFunc(missed, func(a, b firing) int { return a.at.Compare(b.at) })
+	for _, f := range missed {
+		log.Info().
+			Float64("rpm", f.entry.RequestsPerMinute).
+			Str("group", f.entry.Group).
+			Time("opened_at", f.at).
+			Msg("scheduled window already open, opening it now")
+		s.startWindow(f.entry, f.at)
+	}
+}
+
+// lastFiring returns the last time before now that e's cron fired, when its
+// window is still open at now.
+func lastFiring(e config.ScheduleEntry, now time.Time) (time.Time, bool) {
+	sched, err := cron.ParseStandard(e.Cron)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return lastFiringWithin(sched, time.Duration(e.DurationMinutes)*time.Minute, now)
+}
+
+// lastFiringWithin returns the last time before now that sched fired, when
+// that was less than d ago.
+func lastFiringWithin(sched cron.Schedule, d time.Duration, now time.Time) (time.Time, bool) {
+	at := sched.Next(now.Add(-d))
+	if at.IsZero() || at.After(now) {
+		return time.Time{}, false
+	}
+	for next := sched.Next(at); !next.IsZero() && !next.After(now); next = sched.Next(at) {
+		at = next
+	}
+	return at, true
+}
+
+// applyOpen puts the rate and groups of the open windows into effect, as
+// pacing.schedule_overlap combines them: "sum" adds up their rates, "max"
+// takes the fastest window, and "latest" the one opened last. mu must be
+// held.
+func (s *Scheduler) applyOpen() {
+	if len(s.open) == 0 {
+		s.inWindow.Store(false)
+		s.activeRPM.Store(float64(0))
+		s.shares.Store(nil)
+		return
+	}
+	var shares []windowShare
+	switch s.overlap {
+	case "max":
+		w := s.open[0]
+		for _, o := range s.open[1:] {
+			if o.entry.RequestsPerMinute >= w.entry.RequestsPerMinute {
+				w = o // a tie goes to the later window
+			}
+		}
+		shares = []windowShare{{w.entry.Group, w.entry.RequestsPerMinute}}
+	case "latest":
+		w := s.open[len(s.open)-1]
+		shares = []windowShare{{w.entry.Group, w.entry.RequestsPerMinute}}
+	default: // sum
+		for _, w := range s.open {
+			shares = append(shares, windowShare{w.entry.Group, w.entry.RequestsPerMinute})
+		}
+	}
+	var rpm float64
+	for _, sh := range shares {
+		rpm += sh.rpm
+	}
+	if was, _ := s.activeRPM.Load().(float64); was != rpm || s.limiter.Load() == nil {
+		s.limiter.Store(s.newLimiter(rpm))
+	}
+	s.activeRPM.Store(rpm)
+	s.shares.Store(&shares)
+	s.inWindow.Store(true)
+}
+
+// closeWindow closes w, unless it has been restarted or replaced since or a
+// reload has moved its close later.
+func (s *Scheduler) closeWindow(w *openWindow) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.open, w)
+	if i < 0 || time.Now().Before(w.closesAt) {
+		return
+	}
+	s.open = slices.Delete(s.open, i, i+1)
+	s.applyOpen()
+	log.Info().Str("cron", w.entry.Cron).Str("group", w.entry.Group).Msg("scheduled window closed")
+}
+
+// Reschedule replaces the scheduled-mode windows with entries, as a reload
+// of pacing.schedule does. An open window stays open when entries still has
+// one with its cron expression and group, taking on that entry's rate and
+// duration (counted from when it opened); otherwise it closes at once. Then
+// any window of entries that would be open now opens.
+func (s *Scheduler) Reschedule(entries []config.ScheduleEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedule = entries
+	if s.cron == nil {
+		return // not started, or shut down
+	}
+	s.cron.Stop()
+	s.cron = s.newCron(entries)
+	s.cron.Start()
+
+	kept := s.open[:0]
+	for _, w := range s.open {
+		i := slices.IndexFunc(entries, func(e config.ScheduleEntry) bool { return sameWindow(e, w.entry) })
+		if i < 0 {
+			w.close.Stop()
+			log.Info().Str("cron", w.entry.Cron).Msg("hot-reload: open scheduled window removed, closing it")
+			continue
+		}
+		if e := entries[i]; e != w.entry {
+			w.entry = e
+			log.Info().Float64("rpm", e.RequestsPerMinute).Str("group", e.Group).Msg("hot-reload: open scheduled window updated")
+		}
+		s.closeAfter(w, max(time.Until(w.closes()), 0))
+		kept = append(kept, w)
+	}
+	clear(s.open[len(kept):])
+	s.open = kept
+	s.applyOpen()
+	s.catchUp(time.Now())
 }
 
 // Wait implements the pacing delay for the current mode.
@@ -136,6 +354,20 @@ func (s *Scheduler) Wait(ctx context.Context) error {
 	}
 }
 
+// WaitAfter is Wait for the pause that follows a request to a target with
+// think time tt. In human mode a distribution set in tt replaces the
+// min/max delay range; the other modes ignore tt.
+func (s *Scheduler) WaitAfter(ctx context.Context, tt config.ThinkTimeConfig) error {
+	switch s.cfg.Mode {
+	case "rate_limited", "scheduled", "burst":
+		return s.Wait(ctx)
+	}
+	if tt.Distribution == "" {
+		return s.humanWait(ctx)
+	}
+	return sleepCtx(ctx, thinkTime(tt))
+}
+
 func (s *Scheduler) humanWait(ctx context.Context) error {
 	minMs := s.minDelayMs.Load()
 	maxMs := s.maxDelayMs.Load()
@@ -150,6 +382,71 @@ func (s *Scheduler) humanWait(ctx context.Context) error {
 	return sleepCtx(ctx, time.Duration(delayMs)*time.Millisecond)
 }
 
+// Window reports whether a scheduled-mode cron window is open and the
+// requests-per-minute cap currently in effect (zero in human and burst mode,
+// and in scheduled mode while no window is open).
+func (s *Scheduler) Window() (inWindow bool, rpm float64) {
+	rpm, _ = s.activeRPM.Load().(float64)
+	return s.inWindow.Load(), rpm
+}
+
+// Group returns the target group to pick the next request from: that of
+// an open scheduled window, chosen in proportion to the windows' rates when
+// several drive different groups, or "" for all targets (always, outside
+// scheduled mode).
+func (s *Scheduler) Group() string {
+	if !s.inWindow.Load() || s.shares.Load() == nil {
+		return ""
+	}
+	shares := *s.shares.Load()
+	if len(shares) == 1 {
+		return shares[0].group
+	}
+	var total float64
+	for _, sh := range shares {
+		total += sh.rpm
+	}
+	n := rand.Float64() * total //nolint:gosec
+	for _, sh := range shares {
+		if n -= sh.rpm; n < 0 {
+			return sh.group
+		}
+	}
+	return shares[len(shares)-1].group
+}
+
+// WindowGroups returns the target groups the open scheduled windows drive,
+// comma-separated, or "" when they drive every target or none is open.
+func (s *Scheduler) WindowGroups() string {
+	if !s.inWindow.Load() || s.shares.Load() == nil {
+		return ""
+	}
+	var groups []string
+	for _, sh := range *s.shares.Load() {
+		if sh.group == "" {
+			return ""
+		}
+		if !slices.Contains(groups, sh.group) {
+			groups = append(groups, sh.group)
+		}
+	}
+	return strings.Join(groups, ",")
+}
+
+// SetBurst sets how many requests the rate_limited / scheduled limiter lets
+// through back to back after an idle spell. The default is 1.
+func (s *Scheduler) SetBurst(n int) {
+	s.burst.Store(int64(max(n, 1)))
+	if s.limiter.Load() != nil {
+		rpm, _ := s.activeRPM.Load().(float64)
+		s.limiter.Store(s.newLimiter(rpm)) // starts with a full bucket
+	}
+}
+
+func (s *Scheduler) newLimiter(rpm float64) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(rpm/60.0), int(s.burst.Load()))
+}
+
 // UpdatePacing updates reloadable pacing parameters at runtime.
 // Mode changes are not supported — callers should warn and skip.
 func (s *Scheduler) UpdatePacing(cfg config.PacingConfig) {
@@ -161,10 +458,19 @@ func (s *Scheduler) UpdatePacing(cfg config.PacingConfig) {
 			Msg("hot-reload: human pacing updated")
 	case "rate_limited":
 		rpm := cfg.RequestsPerMinute
-		s.limiter.Store(rate.NewLimiter(rate.Limit(rpm/60.0), 1))
+		s.limiter.Store(s.newLimiter(rpm))
 		s.activeRPM.Store(rpm)
-		log.Info().Float64("rpm", rpm).Msg("hot-reload: rate_limited pacing updated")
-	case "scheduled", "burst":
+		s.jitterFactor.Store(cfg.JitterFactor)
+		log.Info().Float64("rpm", rpm).Float64("jitter_factor", cfg.JitterFactor).
+			Msg("hot-reload: rate_limited pacing updated")
+	case "scheduled":
+		s.jitterFactor.Store(cfg.JitterFactor)
+		s.mu.Lock()
+		s.overlap = cfg.ScheduleOverlap
+		s.mu.Unlock()
+		s.Reschedule(cfg.Schedule)
+		log.Info().Int("windows", len(cfg.Schedule)).Msg("hot-reload: scheduled pacing updated")
+	case "burst":
 		log.Warn().Str("mode", s.cfg.Mode).Msg("hot-reload: pacing changes require restart")
 	}
 }
@@ -174,9 +480,16 @@ func (s *Scheduler) rateLimitedWait(ctx context.Context) error {
 	if err := lim.Wait(ctx); err != nil {
 		return err
 	}
-	// Small additional jitter to avoid thundering herd.
-	jitterMs := rand.Intn(200) //nolint:gosec
-	return sleepCtx(ctx, time.Duration(jitterMs)*time.Millisecond)
+	// Delay each request by up to jitter_factor of the interval between
+	// requests, so they do not land on an exact beat. The next token still
+	// comes a full interval after this one, so the rate is unchanged.
+	rpm, _ := s.activeRPM.Load().(float64)
+	jf, _ := s.jitterFactor.Load().(float64)
+	if rpm <= 0 || jf <= 0 {
+		return nil
+	}
+	maxJitter := int64(jf * float64(time.Minute) / rpm)
+	return sleepCtx(ctx, time.Duration(rand.Int63n(maxJitter+1))) //nolint:gosec
 }
 
 func (s *Scheduler) scheduledWait(ctx context.Context) error {
@@ -55,6 +55,55 @@ func TestScheduler_Human_DelayInBounds(t *testing.T) {
 	}
 }
 
+// TestScheduler_WaitAfter_ThinkTime checks that a target's think time
+// replaces the human-mode delay range, and that other modes ignore it.
+func TestScheduler_WaitAfter_ThinkTime(t *testing.T) {
+	fixed := config.ThinkTimeConfig{Distribution: "fixed", Params: config.ThinkTimeParams{DelayMs: 10}}
+	ctx := context.Background()
+
+	s := NewScheduler(humanCfg(5000, 10000, 0))
+	start := time.Now()
+	if err := s.WaitAfter(ctx, fixed); err != nil {
+		t.Fatalf("WaitAfter error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond || elapsed > time.Second {
+		t.Errorf("fixed think time waited %v, want about 10ms", elapsed)
+	}
+
+	s = NewScheduler(config.PacingConfig{Mode: "burst"})
+	fixed.Params.DelayMs = 5000
+	start = time.Now()
+	if err := s.WaitAfter(ctx, fixed); err != nil {
+		t.Fatalf("WaitAfter error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("burst mode waited %v, want think time ignored", elapsed)
+	}
+}
+
+func TestThinkTime_Distributions(t *testing.T) {
+	uniform := config.ThinkTimeConfig{Distribution: "uniform", Params: config.ThinkTimeParams{MinMs: 100, MaxMs: 200}}
+	lognormal := config.ThinkTimeConfig{Distribution: "lognormal", Params: config.ThinkTimeParams{MedianMs: 1000, Sigma: 1, MaxMs: 3000}}
+
+	var below int
+	for i := 0; i < 1000; i++ {
+		if d := thinkTime(uniform); d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("uniform think time %v outside [100ms, 200ms]", d)
+		}
+		d := thinkTime(lognormal)
+		if d <= 0 || d > 3*time.Second {
+			t.Fatalf("lognormal think time %v outside (0, 3s]", d)
+		}
+		if d < time.Second {
+			below++
+		}
+	}
+	// Half the draws fall below the median.
+	if below < 400 || below > 600 {
+		t.Errorf("%d of 1000 lognormal draws below median_ms, want about 500", below)
+	}
+}
+
 // TestScheduler_Human_ContextCancel verifies Wait returns on cancellation.
 func TestScheduler_Human_ContextCancel(t *testing.T) {
 	s := NewScheduler(humanCfg(5000, 10000, 0)) // long delay
@@ -96,6 +145,53 @@ func TestScheduler_RateLimited_ThrottlesRequests(t *testing.T) {
 	}
 }
 
+// TestScheduler_RateLimited_Jitter checks that jitter_factor delays requests
+// by at most that fraction of the interval, and not at all when it is 0.
+func TestScheduler_RateLimited_Jitter(t *testing.T) {
+	const rpm = 60.0 // one token per second
+	ctx := context.Background()
+
+	s := NewScheduler(rateLimitedCfg(rpm))
+	start := time.Now()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatalf("Wait error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("jitter_factor 0: first request waited %v", elapsed)
+	}
+
+	cfg := rateLimitedCfg(rpm)
+	cfg.JitterFactor = 0.2 // up to 200ms
+	for i := 0; i < 5; i++ {
+		s := NewScheduler(cfg)
+		start := time.Now()
+		if err := s.Wait(ctx); err != nil {
+			t.Fatalf("iter %d: Wait error: %v", i, err)
+		}
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond+20*time.Millisecond {
+			t.Errorf("iter %d: jitter_factor 0.2 waited %v, want at most 200ms", i, elapsed)
+		}
+	}
+}
+
+// TestScheduler_RateLimited_Burst verifies that a larger bucket lets several
+// requests through without waiting for tokens.
+func TestScheduler_RateLimited_Burst(t *testing.T) {
+	s := NewScheduler(rateLimitedCfg(0.01))
+	s.SetBurst(3)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		if err := s.Wait(ctx); err != nil {
+			t.Fatalf("request %d within the burst: %v", i, err)
+		}
+	}
+	if err := s.Wait(ctx); err == nil {
+		t.Error("fourth request passed a burst of 3")
+	}
+}
+
 // TestScheduler_RateLimited_ContextCancel verifies cancellation works.
 func TestScheduler_RateLimited_ContextCancel(t *testing.T) {
 	const rpm = 0.01 // very slow
@@ -176,6 +272,171 @@ func TestScheduler_Scheduled_InWindowDispatches(t *testing.T) {
 	}
 }
 
+// TestScheduler_Reschedule checks that a reload of pacing.schedule keeps an
+// open window that is still scheduled, with its new rate and duration, and
+// closes one that is not.
+func TestScheduler_Reschedule(t *testing.T) {
+	never := "0 3 31 2 *" // Feb 31
+	api := config.ScheduleEntry{Cron: never, DurationMinutes: 60, RequestsPerMinute: 60, Group: "api"}
+	s := NewScheduler(config.PacingConfig{Mode: "scheduled", Schedule: []config.ScheduleEntry{api}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	s.mu.Lock()
+	c := s.cron
+	s.mu.Unlock()
+	s.openWindow(c, api)
+
+	faster := api
+	faster.RequestsPerMinute = 120
+	s.Reschedule([]config.ScheduleEntry{faster})
+	if in, rpm := s.Window(); !in || rpm != 120 || s.Group() != "api" {
+		t.Errorf("after raising the rate: in window %v, rpm %v, group %q; want open at 120 for api", in, rpm, s.Group())
+	}
+
+	// A shorter duration that has already run out closes the window.
+	s.mu.Lock()
+	s.open[0].opened = time.Now().Add(-2 * time.Minute)
+	s.mu.Unlock()
+	shorter := faster
+	shorter.DurationMinutes = 1
+	s.Reschedule([]config.ScheduleEntry{shorter})
+	deadline := time.Now().Add(time.Second)
+	for in, _ := s.Window(); in && time.Now().Before(deadline); in, _ = s.Window() {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if in, _ := s.Window(); in {
+		t.Error("window past its new duration is still open")
+	}
+
+	// A firing of the cron that a reload replaced is ignored.
+	s.openWindow(c, api)
+	if in, _ := s.Window(); in {
+		t.Error("window fired by a replaced cron opened")
+	}
+
+	// A window dropped from the schedule closes at once.
+	s.mu.Lock()
+	c = s.cron
+	s.mu.Unlock()
+	s.openWindow(c, api)
+	s.Reschedule([]config.ScheduleEntry{{Cron: never, DurationMinutes: 60, RequestsPerMinute: 60, Group: "backup"}})
+	if in, _ := s.Window(); in {
+		t.Error("window removed from the schedule is still open")
+	}
+}
+
+// TestScheduler_OverlappingWindows checks how each schedule_overlap policy
+// combines windows that are open at once, and that closing one leaves the
+// other in effect.
+func TestScheduler_OverlappingWindows(t *testing.T) {
+	never := "0 3 31 2 *" // Feb 31
+	api := config.ScheduleEntry{Cron: never, DurationMinutes: 60, RequestsPerMinute: 30, Group: "api"}
+	web := config.ScheduleEntry{Cron: never, DurationMinutes: 60, RequestsPerMinute: 10, Group: "web"}
+	for _, tc := range []struct {
+		overlap string
+		rpm     float64
+		groups  string
+	}{
+		{"sum", 40, "api,web"},
+		{"max", 30, "api"},
+		{"latest", 10, "web"},
+	} {
+		s := NewScheduler(config.PacingConfig{Mode: "scheduled", ScheduleOverlap: tc.overlap, Schedule: []config.ScheduleEntry{api, web}})
+		ctx, cancel := context.WithCancel(context.Background())
+		s.Start(ctx)
+		s.mu.Lock()
+		c := s.cron
+		s.mu.Unlock()
+		s.openWindow(c, api)
+		s.openWindow(c, web)
+
+		if in, rpm := s.Window(); !in || rpm != tc.rpm || s.WindowGroups() != tc.groups {
+			t.Errorf("%s: in window %v, rpm %v, groups %q; want %v for %q", tc.overlap, in, rpm, s.WindowGroups(), tc.rpm, tc.groups)
+		}
+
+		// Dropping web leaves api's window in effect.
+		s.Reschedule([]config.ScheduleEntry{api})
+		if in, rpm := s.Window(); !in || rpm != 30 || s.Group() != "api" {
+			t.Errorf("%s: after web closed: in window %v, rpm %v, group %q; want 30 for api", tc.overlap, in, rpm, s.Group())
+		}
+
+		// Closing every window leaves no rate in effect.
+		s.Reschedule(nil)
+		if in, rpm := s.Window(); in || rpm != 0 || s.WindowGroups() != "" {
+			t.Errorf("%s: after all closed: in window %v, rpm %v, groups %q; want none", tc.overlap, in, rpm, s.WindowGroups())
+		}
+		cancel()
+	}
+}
+
+// TestScheduler_Group_SharesByRate checks that with several windows summed,
+// requests go to each window's group in proportion to its rate.
+func TestScheduler_Group_SharesByRate(t *testing.T) {
+	s := NewScheduler(config.PacingConfig{Mode: "scheduled"})
+	s.mu.Lock()
+	s.open = []*openWindow{
+		{entry: config.ScheduleEntry{RequestsPerMinute: 30, Group: "api"}},
+		{entry: config.ScheduleEntry{RequestsPerMinute: 10, Group: "web"}},
+	}
+	s.applyOpen()
+	s.mu.Unlock()
+
+	var api int
+	for i := 0; i < 1000; i++ {
+		if s.Group() == "api" {
+			api++
+		}
+	}
+	if api < 700 || api > 800 {
+		t.Errorf("%d of 1000 picks from api, want about 750", api)
+	}
+}
+
+func TestLastFiring(t *testing.T) {
+	office := config.ScheduleEntry{Cron: "0 9 * * 1-5", DurationMinutes: 480}
+	day := func(d, h, m int) time.Time { return time.Date(2026, 3, d, h, m, 0, 0, time.Local) } // March 2 is a Monday
+	for _, tc := range []struct {
+		now    time.Time
+		opened time.Time // zero when no window is open
+	}{
+		{day(2, 9, 30), day(2, 9, 0)},
+		{day(2, 16, 59), day(2, 9, 0)},
+		{day(2, 8, 59), time.Time{}},
+		{day(2, 17, 0), time.Time{}}, // closed just now
+		{day(7, 10, 0), time.Time{}}, // Saturday
+	} {
+		at, ok := lastFiring(office, tc.now)
+		if ok != !tc.opened.IsZero() || !at.Equal(tc.opened) {
+			t.Errorf("at %v: lastFiring = %v, %v; want %v", tc.now, at, ok, tc.opened)
+		}
+	}
+
+	// Of the firings within the duration, the last one counts.
+	every := config.ScheduleEntry{Cron: "*/10 * * * *", DurationMinutes: 30}
+	if at, ok := lastFiring(every, day(2, 9, 25)); !ok || !at.Equal(day(2, 9, 20)) {
+		t.Errorf("*/10: lastFiring = %v, %v; want 09:20", at, ok)
+	}
+}
+
+// TestScheduler_Scheduled_StartsInOpenWindow checks that a scheduler
+// started within a window opens it at once, with that window's rate.
+func TestScheduler_Scheduled_StartsInOpenWindow(t *testing.T) {
+	cfg := config.PacingConfig{Mode: "scheduled", Schedule: []config.ScheduleEntry{
+		{Cron: "0 3 31 2 *", DurationMinutes: 60, RequestsPerMinute: 10},             // never
+		{Cron: "@hourly", DurationMinutes: 120, RequestsPerMinute: 30, Group: "api"}, // always open
+	}}
+	s := NewScheduler(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+
+	if in, rpm := s.Window(); !in || rpm != 30 || s.Group() != "api" {
+		t.Errorf("in window %v, rpm %v, group %q; want the @hourly window open", in, rpm, s.Group())
+	}
+}
+
 // --- burst mode ---
 
 func burstCfg(rampUpS int) config.PacingConfig {
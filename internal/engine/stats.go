@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/ratelimit"
+	"github.com/lewta/sendit/internal/task"
+)
+
+// TargetStats are the live counters kept for one target URL since the
+// engine started.
+type TargetStats struct {
+	Requests   int64
+	Errors     int64 // errored or returned a status of 400 or above
+	Slow       int64 // slower than the target's latency_budget_ms
+	Duration   time.Duration
+	LastStatus int
+	LastSeen   time.Time
+}
+
+// AvgLatency is the mean request duration, or zero before the first request.
+func (s TargetStats) AvgLatency() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.Duration / time.Duration(s.Requests)
+}
+
+// TypeStats are the totals kept for one driver type since the engine
+// started.
+type TypeStats struct {
+	Requests int64
+	Errors   int64
+}
+
+// WaitStats are the totals of time requests to one domain spent held
+// before dispatch since the engine started.
+type WaitStats struct {
+	RateLimit time.Duration // per-domain limiter and global cap
+	Backoff   time.Duration
+}
+
+// rateWindow is how far back CurrentRPS looks.
+const rateWindow = 60
+
+// targetCounters is a mutex-guarded set of TargetStats keyed by URL, totals
+// keyed by driver type, and a ring of per-second completion counts for the
+// last rateWindow seconds.
+type targetCounters struct {
+	mu     sync.Mutex
+	byURL  map[string]*TargetStats
+	byType map[string]*TypeStats
+	waits  map[string]*WaitStats
+	secs   [rateWindow]int64 // unix second each slot of counts belongs to
+	counts [rateWindow]int64
+}
+
+func (c *targetCounters) record(r task.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byURL == nil {
+		c.byURL = make(map[string]*TargetStats)
+		c.byType = make(map[string]*TypeStats)
+	}
+	failed := r.Error != nil || r.StatusCode >= 400
+	now := time.Now()
+	s, ok := c.byURL[r.Task.URL]
+	if !ok {
+		s = &TargetStats{}
+		c.byURL[r.Task.URL] = s
+	}
+	s.Requests++
+	if failed {
+		s.Errors++
+	}
+	if r.Slow() {
+		s.Slow++
+	}
+	s.Duration += r.Duration
+	s.LastStatus = r.StatusCode
+	s.LastSeen = now
+
+	ts, ok := c.byType[r.Task.Type]
+	if !ok {
+		ts = &TypeStats{}
+		c.byType[r.Task.Type] = ts
+	}
+	ts.Requests++
+	if failed {
+		ts.Errors++
+	}
+
+	sec := now.Unix()
+	slot := sec % rateWindow
+	if c.secs[slot] != sec {
+		c.secs[slot], c.counts[slot] = sec, 0
+	}
+	c.counts[slot]++
+}
+
+// recordWait adds the time a request to domain was held by the rate
+// limiter and by backoff.
+func (c *targetCounters) recordWait(domain string, rateLimit, backoff time.Duration) {
+	if rateLimit <= 0 && backoff <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.waits == nil {
+		c.waits = make(map[string]*WaitStats)
+	}
+	w, ok := c.waits[domain]
+	if !ok {
+		w = &WaitStats{}
+		c.waits[domain] = w
+	}
+	w.RateLimit += rateLimit
+	w.Backoff += backoff
+}
+
+// rps returns the mean completions per second over the rateWindow whole
+// seconds before now, or over the time since start when that is shorter.
+func (c *targetCounters) rps(now, start time.Time) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cur := now.Unix()
+	var n int64
+	for i, sec := range c.secs {
+		if sec < cur && sec >= cur-rateWindow {
+			n += c.counts[i]
+		}
+	}
+	span := float64(rateWindow)
+	if elapsed := now.Sub(start).Seconds(); elapsed < span {
+		span = elapsed
+	}
+	if span < 1 {
+		return 0
+	}
+	return float64(n) / span
+}
+
+// Config returns the configuration the engine is currently running with,
+// including any hot-reloaded changes.
+func (e *Engine) Config() *config.Config {
+	return e.cfg.Load()
+}
+
+// TargetStats returns a copy of the live counters of every target that has
+// completed at least one request, keyed by URL. Counters are kept across
+// reloads, so a target removed and added again resumes its totals.
+func (e *Engine) TargetStats() map[string]TargetStats {
+	e.counters.mu.Lock()
+	defer e.counters.mu.Unlock()
+	out := make(map[string]TargetStats, len(e.counters.byURL))
+	for url, s := range e.counters.byURL {
+		out[url] = *s
+	}
+	return out
+}
+
+// TypeStats returns a copy of the totals for every driver type that has
+// completed at least one request, keyed by type.
+func (e *Engine) TypeStats() map[string]TypeStats {
+	e.counters.mu.Lock()
+	defer e.counters.mu.Unlock()
+	out := make(map[string]TypeStats, len(e.counters.byType))
+	for typ, s := range e.counters.byType {
+		out[typ] = *s
+	}
+	return out
+}
+
+// WaitStats returns a copy of the wait totals of every domain whose
+// requests have been held, keyed by domain.
+func (e *Engine) WaitStats() map[string]WaitStats {
+	e.counters.mu.Lock()
+	defer e.counters.mu.Unlock()
+	out := make(map[string]WaitStats, len(e.counters.waits))
+	for domain, w := range e.counters.waits {
+		out[domain] = *w
+	}
+	return out
+}
+
+// Backoff returns the domains currently backing off after transient
+// errors, sorted by domain. A reload clears backoff state.
+func (e *Engine) Backoff() []ratelimit.BackoffState {
+	return e.backoff.Load().Snapshot()
+}
+
+// Status is a point-in-time summary of a running engine.
+type Status struct {
+	RunID       string
+	Started     time.Time
+	Mode        string
+	Paused      bool
+	PausedSince time.Time
+	// Blackout names the blackout holding dispatch until BlackoutUntil, or
+	// is "" outside blackouts.
+	Blackout      string
+	BlackoutUntil time.Time
+	// InWindow reports whether a cron window is open; it is only
+	// meaningful in scheduled mode.
+	InWindow bool
+	// WindowGroup is the target group the open windows drive, comma-separated
+	// when they drive several, or "" when they drive every target.
+	WindowGroup string
+	// ActiveRPM is the requests-per-minute cap in effect in rate_limited
+	// and scheduled mode, and zero otherwise.
+	ActiveRPM float64
+	// RPS is the completion rate over the last minute.
+	RPS       float64
+	CPUPct    float64
+	MemUsedMB uint64
+}
+
+// Status returns the current pacing, pause, and resource state and the
+// recent request rate.
+func (e *Engine) Status() Status {
+	now := time.Now()
+	st := Status{
+		RunID:   e.runID,
+		Started: e.started,
+		Mode:    e.Config().Pacing.Mode,
+		RPS:     e.counters.rps(now, e.started),
+	}
+	st.Paused, st.PausedSince = e.Paused()
+	st.Blackout, st.BlackoutUntil, _ = e.Blackout()
+	st.InWindow, st.ActiveRPM = e.scheduler.Window()
+	st.WindowGroup = e.scheduler.WindowGroups()
+	st.CPUPct, st.MemUsedMB = e.monitor.Stats()
+	return st
+}
@@ -0,0 +1,30 @@
+package engine
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+// thinkTime draws a pause from tt, which must have a distribution set.
+func thinkTime(tt config.ThinkTimeConfig) time.Duration {
+	p := tt.Params
+	var ms float64
+	switch tt.Distribution {
+	case "fixed":
+		ms = float64(p.DelayMs)
+	case "uniform":
+		ms = float64(p.MinMs) + rand.Float64()*float64(p.MaxMs-p.MinMs) //nolint:gosec
+	case "lognormal":
+		// The median of exp(N(mu, sigma)) is exp(mu), so scaling by the
+		// median keeps median_ms the typical pause while sigma sets how
+		// long the tail of slow readers is.
+		ms = float64(p.MedianMs) * math.Exp(p.Sigma*rand.NormFloat64()) //nolint:gosec
+		if p.MaxMs > 0 {
+			ms = min(ms, float64(p.MaxMs))
+		}
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,116 @@
+// Package logfile is a size-rotated log file for the detached `sendit start`
+// daemon: once the file would grow past its limit it is renamed to
+// <path>.1 (shifting older backups to .2, .3, ...) and a fresh file is
+// opened in its place.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is an io.Writer appending to a rotated log file. It is safe for
+// concurrent use.
+type File struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu    sync.Mutex
+	f     *os.File
+	size  int64
+	stdio bool // re-point stdout and stderr at each new file
+}
+
+// Open opens (or creates) the log file at path for appending. The file is
+// rotated when a write would take it past maxSizeMB megabytes; 0 disables
+// rotation. At most maxBackups rotated files are kept.
+func Open(path string, maxSizeMB, maxBackups int) (*File, error) {
+	l := &File{path: path, maxBytes: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *File) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	l.f, l.size = f, fi.Size()
+	if l.stdio {
+		if err := dupStdio(f); err != nil {
+			return fmt.Errorf("redirecting stdout and stderr to log file: %w", err)
+		}
+	}
+	return nil
+}
+
+// CaptureStdio points the process's stdout and stderr at the log file and
+// keeps them there across rotations, so panics and anything else written
+// straight to file descriptors 1 and 2 land in the current file rather
+// than in a rotated backup. It does nothing on Windows.
+func (l *File) CaptureStdio() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stdio = true
+	if err := dupStdio(l.f); err != nil {
+		return fmt.Errorf("redirecting stdout and stderr to log file: %w", err)
+	}
+	return nil
+}
+
+// Write appends p, rotating first if p would not fit under the size limit.
+// A single write larger than the limit is written whole to a fresh file.
+func (l *File) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxBytes > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxBytes {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups along, moves the current file to .1, and opens
+// a new one. The caller holds l.mu.
+func (l *File) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+	if l.maxBackups <= 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("rotating log file: %w", err)
+		}
+		return l.open()
+	}
+	_ = os.Remove(backupName(l.path, l.maxBackups))
+	for i := l.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(backupName(l.path, i), backupName(l.path, i+1))
+	}
+	if err := os.Rename(l.path, backupName(l.path, 1)); err != nil {
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+	return l.open()
+}
+
+// Close closes the current file.
+func (l *File) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
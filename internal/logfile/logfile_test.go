@@ -0,0 +1,61 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFile_RotatesAndKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sendit.log")
+	l := &File{path: path, maxBytes: 10, maxBackups: 2}
+	if err := l.open(); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		path:        "dddddddd\n",
+		path + ".1": "cccccccc\n",
+		path + ".2": "bbbbbbbb\n",
+	}
+	for p, content := range want {
+		got, err := os.ReadFile(p)
+		if err != nil || string(got) != content {
+			t.Errorf("%s = %q (%v), want %q", filepath.Base(p), got, err, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, found %s.3", filepath.Base(path))
+	}
+}
+
+func TestOpen_AppendsAndCountsExistingSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sendit.log")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 1<<20)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(path, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Write([]byte("next\n")); err != nil {
+		t.Fatal(err)
+	}
+	_ = l.Close()
+
+	if got, _ := os.ReadFile(path); string(got) != "next\n" {
+		t.Errorf("a full existing file should be rotated on the first write, got %d bytes", len(got))
+	}
+	if fi, err := os.Stat(path + ".1"); err != nil || fi.Size() != 1<<20 {
+		t.Errorf("backup missing or wrong size: %v", err)
+	}
+}
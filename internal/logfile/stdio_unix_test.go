@@ -0,0 +1,54 @@
+//go:build !windows
+
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCaptureStdio_FollowsRotation(t *testing.T) {
+	// Save and restore the test binary's own stdout and stderr.
+	saved := map[int]int{}
+	for _, fd := range []int{1, 2} {
+		d, err := unix.Dup(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved[fd] = d
+	}
+	defer func() {
+		for fd, d := range saved {
+			_ = unix.Dup2(d, fd)
+			_ = unix.Close(d)
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "sendit.log")
+	l := &File{path: path, maxBytes: 10, maxBackups: 1}
+	if err := l.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close() //nolint:errcheck
+	if err := l.CaptureStdio(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Write([]byte("aaaaaaaa\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Write([]byte("bbbbbbbb\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unix.Write(2, []byte("panic\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "bbbbbbbb\npanic\n" {
+		t.Errorf("current file = %q, want stderr output after the rotation", got)
+	}
+	if got, _ := os.ReadFile(path + ".1"); string(got) != "aaaaaaaa\n" {
+		t.Errorf("backup = %q, stderr should no longer reach it", got)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package logfile
+
+import "os"
+
+// dupStdio is a no-op on Windows, where the child's standard handles stay
+// on the file the parent opened.
+func dupStdio(*os.File) error { return nil }
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConnStats is one driver type's use of its pooled connections.
+type ConnStats struct {
+	New    int64 // requests that dialed a new connection
+	Reused int64 // requests that reused a pooled one
+	Open   int   // connections open now
+	Idle   int   // open connections no request is using
+}
+
+// connCollector reads the drivers' connection stats at scrape time, since
+// the pools are counted by the drivers themselves.
+type connCollector struct {
+	src                  atomic.Pointer[func() map[string]ConnStats]
+	acquired, open, idle *prometheus.Desc
+}
+
+func newConnCollector() *connCollector {
+	return &connCollector{
+		acquired: prometheus.NewDesc("sendit_connections_acquired_total",
+			"Total requests that got a connection, by driver type and whether it was reused from the pool (\"true\") or newly dialed (\"false\").",
+			[]string{"type", "reused"}, nil),
+		open: prometheus.NewDesc("sendit_connections_open",
+			"Connections a driver holds open, by driver type.",
+			[]string{"type"}, nil),
+		idle: prometheus.NewDesc("sendit_connections_idle",
+			"Open connections no request is using, by driver type.",
+			[]string{"type"}, nil),
+	}
+}
+
+func (c *connCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.open
+	ch <- c.idle
+}
+
+func (c *connCollector) Collect(ch chan<- prometheus.Metric) {
+	fn := c.src.Load()
+	if fn == nil {
+		return
+	}
+	for typ, s := range (*fn)() {
+		ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.CounterValue, float64(s.Reused), typ, "true")
+		ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.CounterValue, float64(s.New), typ, "false")
+		ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.Open), typ)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle), typ)
+	}
+}
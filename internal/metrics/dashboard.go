@@ -0,0 +1,301 @@
+package metrics
+
+import (
+	"encoding/json"
+)
+
+// DashboardOptions configures the generated Grafana dashboard.
+type DashboardOptions struct {
+	Title string // defaults to "sendit"
+	UID   string // defaults to "sendit"
+}
+
+// Dashboard returns a Grafana dashboard (JSON model, importable through
+// Dashboards → Import or file provisioning) whose panels query the metrics
+// defined in this package. A datasource variable selects the Prometheus
+// instance, and type, domain, and target variables filter the panels. The
+// per-target row only has data when metrics.per_target is enabled.
+func Dashboard(opts DashboardOptions) ([]byte, error) {
+	if opts.Title == "" {
+		opts.Title = "sendit"
+	}
+	if opts.UID == "" {
+		opts.UID = "sendit"
+	}
+
+	var b dashboardBuilder
+	b.row("Overview")
+	b.stat("Requests", "short",
+		`sum(increase(sendit_requests_total{`+sel+`}[$__range]) or increase(sendit_errors_total{`+sel+`}[$__range])) or vector(0)`)
+	b.stat("Request rate", "reqps",
+		`sum(rate(sendit_requests_total{`+sel+`}[$__rate_interval]) or rate(sendit_errors_total{`+sel+`}[$__rate_interval])) or vector(0)`)
+	b.stat("Error ratio", "percentunit", errorRatio(""))
+	b.stat("p95 latency", "s",
+		`histogram_quantile(0.95, sum by (le) (rate(sendit_request_duration_seconds_bucket{`+sel+`}[$__rate_interval])))`)
+	b.stat("Bytes received", "Bps",
+		`sum(rate(sendit_bytes_read_total{type=~"$type"}[$__rate_interval])) or vector(0)`)
+	b.stat("Output records dropped", "short",
+		`sum(increase(sendit_output_dropped_total[$__range])) or vector(0)`)
+	b.stat("In blackout", "short", `max(sendit_blackout_active)`)
+
+	b.row("Traffic")
+	b.timeseries("Requests/s by type", "reqps", 12,
+		target(`sum by (type) (rate(sendit_requests_total{`+sel+`}[$__rate_interval]) or rate(sendit_errors_total{`+sel+`}[$__rate_interval]))`, "{{type}}"))
+	b.timeseries("Responses/s by status code", "reqps", 12,
+		target(`sum by (status_code) (rate(sendit_requests_total{`+sel+`}[$__rate_interval]))`, "{{status_code}}"))
+	b.timeseries("Errors/s by type and class", "reqps", 12,
+		target(`sum by (type, error_class) (rate(sendit_errors_total{`+sel+`}[$__rate_interval]))`, "{{type}} {{error_class}}"))
+	b.timeseries("Error ratio by type", "percentunit", 12,
+		target(errorRatio("type"), "{{type}}"))
+
+	b.row("Latency")
+	b.timeseries("Latency percentiles", "s", 12,
+		target(quantile("0.5", ""), "p50"),
+		target(quantile("0.95", ""), "p95"),
+		target(quantile("0.99", ""), "p99"))
+	b.timeseries("p95 latency by type", "s", 12,
+		target(quantile("0.95", "type"), "{{type}}"))
+
+	b.row("Domains")
+	b.timeseries("Requests/s by domain (top 10)", "reqps", 12,
+		target(`topk(10, sum by (domain) (rate(sendit_requests_total{`+sel+`}[$__rate_interval])))`, "{{domain}}"))
+	b.timeseries("p95 latency by domain (top 10)", "s", 12,
+		target(`topk(10, `+quantile("0.95", "domain")+`)`, "{{domain}}"))
+
+	b.row("HTTP protocols")
+	b.timeseries("HTTP responses/s by protocol", "reqps", 12,
+		target(`sum by (protocol) (rate(sendit_http_responses_total{domain=~"$domain"}[$__rate_interval]))`, "{{protocol}}"))
+	b.timeseries("HTTP responses advertising HTTP/3 (Alt-Svc)", "percentunit", 12,
+		target(`sum(rate(sendit_http_responses_total{domain=~"$domain", h3_advertised="true"}[$__rate_interval])) / sum(rate(sendit_http_responses_total{domain=~"$domain"}[$__rate_interval]))`, "h3 advertised"))
+
+	b.row("Connections")
+	b.timeseries("Connection reuse ratio by type", "percentunit", 12,
+		target(`sum by (type) (rate(sendit_connections_acquired_total{type=~"$type", reused="true"}[$__rate_interval])) / sum by (type) (rate(sendit_connections_acquired_total{type=~"$type"}[$__rate_interval]))`, "{{type}}"))
+	b.timeseries("Open and idle connections by type", "short", 12,
+		target(`sum by (type) (sendit_connections_open{type=~"$type"})`, "{{type}} open"),
+		target(`sum by (type) (sendit_connections_idle{type=~"$type"})`, "{{type}} idle"))
+
+	b.row("Redirects")
+	b.timeseries("p95 redirect hops by domain (top 10)", "short", 12,
+		target(`topk(10, histogram_quantile(0.95, sum by (domain, le) (rate(sendit_redirect_hops_bucket{domain=~"$domain"}[$__rate_interval]))))`, "{{domain}}"))
+	b.timeseries("Requests stopped by the redirect limit/s by domain", "reqps", 12,
+		target(`sum by (domain) (rate(sendit_redirect_hops_count{domain=~"$domain"}[$__rate_interval])) - sum by (domain) (rate(sendit_redirect_hops_bucket{domain=~"$domain", le="9"}[$__rate_interval]))`, "{{domain}}"))
+
+	b.row("Aborted requests")
+	b.timeseries("Aborted requests/s by type", "reqps", 12,
+		target(`sum by (type) (rate(sendit_aborted_requests_total{type=~"$type", domain=~"$domain"}[$__rate_interval]))`, "{{type}}"))
+	b.timeseries("Requests aborted by type", "percentunit", 12,
+		target(`sum by (type) (rate(sendit_aborted_requests_total{type=~"$type", domain=~"$domain"}[$__rate_interval])) / (sum by (type) (rate(sendit_aborted_requests_total{type=~"$type", domain=~"$domain"}[$__rate_interval])) + sum by (type) (rate(sendit_requests_total{type=~"$type", domain=~"$domain"}[$__rate_interval])))`, "{{type}}"))
+
+	b.row("Browser pages")
+	b.timeseries("Broken browser pages/s by domain (top 10)", "reqps", 12,
+		target(`topk(10, sum by (domain) (rate(sendit_browser_broken_pages_total{domain=~"$domain"}[$__rate_interval])))`, "{{domain}}"))
+	b.timeseries("Browser pages loaded broken", "percentunit", 12,
+		target(`sum(rate(sendit_browser_broken_pages_total{domain=~"$domain"}[$__rate_interval])) / sum(rate(sendit_requests_total{type="browser", domain=~"$domain"}[$__rate_interval]))`, "broken"))
+
+	b.row("DNS")
+	b.timeseries("DNS queries/s by record type", "reqps", 8,
+		target(`sum by (record_type) (rate(sendit_dns_queries_total{domain=~"$domain"}[$__rate_interval]))`, "{{record_type}}"))
+	b.timeseries("DNS responses/s by record type and rcode", "reqps", 8,
+		target(`sum by (record_type, rcode) (rate(sendit_dns_queries_total{domain=~"$domain"}[$__rate_interval]))`, "{{record_type}} {{rcode}}"))
+	b.timeseries("DNS p95 latency by record type", "s", 8,
+		target(`histogram_quantile(0.95, sum by (record_type, le) (rate(sendit_dns_query_duration_seconds_bucket{domain=~"$domain"}[$__rate_interval])))`, "{{record_type}} total"),
+		target(`histogram_quantile(0.95, sum by (record_type, le) (rate(sendit_dns_rtt_seconds_bucket{domain=~"$domain"}[$__rate_interval])))`, "{{record_type}} rtt"))
+	b.timeseries("DNS resolver health (1 = answering)", "short", 24,
+		target(`min by (resolver) (sendit_dns_resolver_healthy)`, "{{resolver}}"))
+
+	b.row("Rate limiting and backoff")
+	b.stat("Domains in backoff", "short", `max(sendit_backoff_domains)`)
+	b.timeseries("Time held by rate limit, by domain (top 10)", "percentunit", 10,
+		target(`topk(10, `+waitRate(WaitRateLimit)+`)`, "{{domain}}"))
+	b.timeseries("Time held by backoff, by domain (top 10)", "percentunit", 10,
+		target(`topk(10, `+waitRate(WaitBackoff)+`)`, "{{domain}}"))
+	b.timeseries("Tasks skipped in cooldown/s by domain (top 10)", "reqps", 24,
+		target(`topk(10, sum by (domain) (rate(sendit_skipped_total{domain=~"$domain", reason="`+SkipCooldown+`"}[$__rate_interval])))`, "{{domain}}"))
+	b.timeseries("Retries/s by result", "reqps", 24,
+		target(`sum by (result) (rate(sendit_retries_total{`+sel+`}[$__rate_interval]))`, "{{result}}"))
+
+	b.row("Targets (requires metrics.per_target)")
+	b.timeseries("Requests/s by target (top 20)", "reqps", 12,
+		target(`topk(20, `+targetRate("")+`)`, "{{target}}"))
+	b.timeseries("Error ratio by target (top 20)", "percentunit", 12,
+		target(`topk(20, (`+targetRate(`, result="error"`)+` or `+targetRate("")+` * 0) / `+targetRate("")+`)`, "{{target}}"))
+	b.timeseries("p95 latency by target (top 20)", "s", 24,
+		target(`topk(20, histogram_quantile(0.95, sum by (target, le) (rate(sendit_target_request_duration_seconds_bucket{target=~"$target"}[$__rate_interval]))))`, "{{target}}"))
+
+	dash := map[string]any{
+		"title":         opts.Title,
+		"uid":           opts.UID,
+		"tags":          []string{"sendit"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"refresh":       "30s",
+		"time":          map[string]any{"from": "now-1h", "to": "now"},
+		"timezone":      "browser",
+		"graphTooltip":  1,
+		"panels":        b.panels,
+		"templating": map[string]any{"list": []any{
+			map[string]any{
+				"name": "datasource", "label": "Data source", "type": "datasource",
+				"query": "prometheus", "current": map[string]any{},
+			},
+			labelVariable("type", "Type", "label_values(sendit_requests_total, type)"),
+			labelVariable("domain", "Domain", `label_values(sendit_requests_total{type=~"$type"}, domain)`),
+			labelVariable("target", "Target", `label_values(sendit_target_requests_total{type=~"$type"}, target)`),
+		}},
+	}
+	return json.MarshalIndent(dash, "", "  ")
+}
+
+// sel and targetSel are the label matchers applied by the dashboard
+// variables.
+const (
+	sel       = `type=~"$type", domain=~"$domain"`
+	targetSel = `type=~"$type", target=~"$target"`
+)
+
+// errorRatio is the share of requests that errored or returned a status of
+// 400 or above, optionally grouped by the given label. Errors and responses
+// are separate metrics, so each side joins them with "or" (their label sets
+// never match) before summing; "or total * 0" reports 0 instead of no data
+// while nothing has failed.
+func errorRatio(by string) string {
+	group := "sum"
+	if by != "" {
+		group = "sum by (" + by + ")"
+	}
+	errs := `rate(sendit_errors_total{` + sel + `}[$__rate_interval])`
+	failed := group + ` (` + errs + ` or rate(sendit_requests_total{` + sel + `, status_code=~"[45].."}[$__rate_interval]))`
+	total := group + ` (` + errs + ` or rate(sendit_requests_total{` + sel + `}[$__rate_interval]))`
+	return `(` + failed + ` or ` + total + ` * 0) / ` + total
+}
+
+// targetRate is the per-target completion rate, narrowed by extra matchers.
+func targetRate(extra string) string {
+	return `sum by (target) (rate(sendit_target_requests_total{` + targetSel + extra + `}[$__rate_interval]))`
+}
+
+// waitRate is the seconds per second each domain's requests spend held for
+// reason; above 1 means several requests are waiting at once.
+func waitRate(reason string) string {
+	return `sum by (domain) (rate(sendit_wait_seconds_total{domain=~"$domain", reason="` + reason + `"}[$__rate_interval]))`
+}
+
+// quantile is the q latency quantile of sendit_request_duration_seconds,
+// optionally grouped by the given label.
+func quantile(q, by string) string {
+	labels := "le"
+	if by != "" {
+		labels = by + ", le"
+	}
+	return `histogram_quantile(` + q + `, sum by (` + labels + `) (rate(sendit_request_duration_seconds_bucket{` + sel + `}[$__rate_interval])))`
+}
+
+func target(expr, legend string) map[string]any {
+	return map[string]any{
+		"datasource":   datasourceRef,
+		"expr":         expr,
+		"legendFormat": legend,
+	}
+}
+
+// withRef sets the query's refId to the i-th letter.
+func withRef(t map[string]any, i int) map[string]any {
+	t["refId"] = string(rune('A' + i))
+	return t
+}
+
+func labelVariable(name, label, query string) map[string]any {
+	return map[string]any{
+		"name":       name,
+		"label":      label,
+		"type":       "query",
+		"datasource": datasourceRef,
+		"query":      query,
+		"definition": query,
+		"refresh":    2,
+		"includeAll": true,
+		"multi":      true,
+		"allValue":   ".*",
+		"current":    map[string]any{"text": "All", "value": "$__all"},
+		"sort":       1,
+	}
+}
+
+var datasourceRef = map[string]any{"type": "prometheus", "uid": "${datasource}"}
+
+// dashboardBuilder lays panels out on Grafana's 24-column grid, left to
+// right and top to bottom, numbering them as it goes.
+type dashboardBuilder struct {
+	panels []any
+	x, y   int
+	rowH   int
+	nextID int
+}
+
+func (b *dashboardBuilder) place(w, h int) map[string]any {
+	if b.x+w > 24 {
+		b.x, b.y, b.rowH = 0, b.y+b.rowH, 0
+	}
+	pos := map[string]any{"x": b.x, "y": b.y, "w": w, "h": h}
+	b.x += w
+	b.rowH = max(b.rowH, h)
+	return pos
+}
+
+func (b *dashboardBuilder) add(p map[string]any) {
+	b.nextID++
+	p["id"] = b.nextID
+	b.panels = append(b.panels, p)
+}
+
+func (b *dashboardBuilder) row(title string) {
+	if b.x > 0 {
+		b.x, b.y, b.rowH = 0, b.y+b.rowH, 0
+	}
+	b.add(map[string]any{
+		"type": "row", "title": title, "collapsed": false, "panels": []any{},
+		"gridPos": b.place(24, 1),
+	})
+}
+
+func (b *dashboardBuilder) stat(title, unit, expr string) {
+	b.add(map[string]any{
+		"type":       "stat",
+		"title":      title,
+		"datasource": datasourceRef,
+		"gridPos":    b.place(4, 4),
+		"targets":    []any{withRef(target(expr, ""), 0)},
+		"fieldConfig": map[string]any{
+			"defaults":  map[string]any{"unit": unit},
+			"overrides": []any{},
+		},
+		"options": map[string]any{
+			"reduceOptions": map[string]any{"calcs": []string{"lastNotNull"}, "fields": "", "values": false},
+			"colorMode":     "value",
+			"graphMode":     "area",
+		},
+	})
+}
+
+func (b *dashboardBuilder) timeseries(title, unit string, w int, targets ...map[string]any) {
+	ts := make([]any, len(targets))
+	for i, t := range targets {
+		ts[i] = withRef(t, i)
+	}
+	b.add(map[string]any{
+		"type":       "timeseries",
+		"title":      title,
+		"datasource": datasourceRef,
+		"gridPos":    b.place(w, 8),
+		"targets":    ts,
+		"fieldConfig": map[string]any{
+			"defaults":  map[string]any{"unit": unit, "custom": map[string]any{"fillOpacity": 10}},
+			"overrides": []any{},
+		},
+		"options": map[string]any{
+			"legend":  map[string]any{"displayMode": "list", "placement": "bottom", "showLegend": true},
+			"tooltip": map[string]any{"mode": "multi", "sort": "desc"},
+		},
+	})
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/task"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestDashboard_QueriesKnownMetrics checks that every metric the dashboard
+// queries is one this package exports, so renaming a metric without updating
+// the dashboard fails here.
+func TestDashboard_QueriesKnownMetrics(t *testing.T) {
+	m := NewWithOptions(Options{PerTarget: true})
+	httpResult := makeResult("http", 200, 10*time.Millisecond, 100, nil)
+	httpResult.Protocol = "HTTP/2"
+	httpResult.Redirects = []task.Hop{{URL: "https://example.com/old", StatusCode: 301}}
+	m.Record(httpResult)
+	browserResult := makeResult("browser", 200, time.Second, 0, nil)
+	browserResult.ConsoleErrors = 1
+	m.Record(browserResult)
+	m.Record(makeResult("http", 0, 10*time.Millisecond, 0, errSentinel{}))
+	abortedResult := makeResult("http", 0, 10*time.Millisecond, 0, nil)
+	abortedResult.Aborted = true
+	m.Record(abortedResult)
+	dnsResult := makeResult("dns", 200, time.Millisecond, 0, nil)
+	dnsResult.Meta = map[string]string{"dns_record_type": "AAAA", "dns_rcode": "NOERROR", "dns_rtt_ms": "0.800"}
+	m.Record(dnsResult)
+	m.RecordOutputDropped("file")
+	m.RecordWait("a.com", WaitBackoff, time.Second)
+	m.RecordSkipped("a.com", SkipCooldown)
+	m.RecordRetry("http", "a.com", RetrySent)
+	m.SetResolverHealth("8.8.8.8:53", true)
+	m.SetConnStatsSource(func() map[string]ConnStats {
+		return map[string]ConnStats{"http": {New: 1, Reused: 3, Open: 1, Idle: 1}}
+	})
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := make(map[string]bool)
+	for _, f := range families {
+		known[f.GetName()] = true
+	}
+
+	data, err := Dashboard(DashboardOptions{Title: "load test"})
+	if err != nil {
+		t.Fatalf("Dashboard: %v", err)
+	}
+	var dash struct {
+		Title  string `json:"title"`
+		Panels []struct {
+			ID      int    `json:"id"`
+			Type    string `json:"type"`
+			GridPos struct {
+				X, W int
+			} `json:"gridPos"`
+			Targets []struct {
+				Expr  string `json:"expr"`
+				RefID string `json:"refId"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dash); err != nil {
+		t.Fatalf("dashboard is not valid JSON: %v", err)
+	}
+	if dash.Title != "load test" {
+		t.Errorf("title = %q", dash.Title)
+	}
+
+	metricRef := regexp.MustCompile(`sendit_[a-z_]+`)
+	seen := make(map[int]bool)
+	queried := make(map[string]bool)
+	for _, p := range dash.Panels {
+		if seen[p.ID] {
+			t.Errorf("duplicate panel id %d", p.ID)
+		}
+		seen[p.ID] = true
+		if p.GridPos.X+p.GridPos.W > 24 {
+			t.Errorf("panel %d overflows the grid: %+v", p.ID, p.GridPos)
+		}
+		if p.Type != "row" && len(p.Targets) == 0 {
+			t.Errorf("panel %d has no queries", p.ID)
+		}
+		for _, q := range p.Targets {
+			if q.RefID == "" {
+				t.Errorf("panel %d: query without refId", p.ID)
+			}
+			for _, name := range metricRef.FindAllString(q.Expr, -1) {
+				for _, suffix := range []string{"_bucket", "_count", "_sum"} { // histogram series
+					name = strings.TrimSuffix(name, suffix)
+				}
+				queried[name] = true
+				if !known[name] {
+					t.Errorf("panel %d queries unknown metric %s", p.ID, name)
+				}
+			}
+		}
+	}
+	for name := range known {
+		if !queried[name] {
+			t.Errorf("no panel shows %s", name)
+		}
+	}
+}
+
+func TestNewWithOptions_RunIDLabel(t *testing.T) {
+	m := NewWithOptions(Options{RunID: "run-1"})
+	m.Record(makeResult("http", 200, 10*time.Millisecond, 0, nil))
+
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, mf := range families {
+		for _, metric := range mf.GetMetric() {
+			var runID string
+			for _, l := range metric.GetLabel() {
+				if l.GetName() == "run_id" {
+					runID = l.GetValue()
+				}
+			}
+			if runID != "run-1" {
+				t.Errorf("%s has run_id %q, want run-1", mf.GetName(), runID)
+			}
+		}
+	}
+}
+
+func TestRecord_PerTarget(t *testing.T) {
+	m := NewWithOptions(Options{PerTarget: true})
+	m.Record(makeResult("http", 200, 10*time.Millisecond, 0, nil))
+	m.Record(makeResult("http", 503, 10*time.Millisecond, 0, nil))
+	m.Record(makeResult("http", 0, 10*time.Millisecond, 0, errSentinel{}))
+
+	if got := testutil.ToFloat64(m.targetRequests.WithLabelValues("https://example.com", "http", "success")); got != 1 {
+		t.Errorf("success = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.targetRequests.WithLabelValues("https://example.com", "http", "error")); got != 2 {
+		t.Errorf("error = %v, want 2", got)
+	}
+	if New().targetRequests != nil {
+		t.Error("per-target series registered without Options.PerTarget")
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/lewta/sendit/internal/task"
@@ -19,18 +21,67 @@ type Metrics struct {
 	registry        *prometheus.Registry
 	requestsTotal   *prometheus.CounterVec
 	errorsTotal     *prometheus.CounterVec
+	slowTotal       *prometheus.CounterVec
 	durationSeconds *prometheus.HistogramVec
 	bytesRead       *prometheus.CounterVec
+	outputDropped   *prometheus.CounterVec
+	waitSeconds     *prometheus.CounterVec
+	skipped         *prometheus.CounterVec
+	retries         *prometheus.CounterVec
+	dnsQueries      *prometheus.CounterVec
+	dnsDuration     *prometheus.HistogramVec
+	dnsRTT          *prometheus.HistogramVec
+	dnsResolvers    *prometheus.GaugeVec
+	httpResponses   *prometheus.CounterVec
+	redirectHops    *prometheus.HistogramVec
+	brokenPages     *prometheus.CounterVec
+	abortedTotal    *prometheus.CounterVec
+
+	// backoffDomains reports how many domains are backing off; the engine
+	// supplies it through SetBackoffSource.
+	backoffDomains atomic.Pointer[func() int]
+	// blackout reports whether a blackout is holding dispatch; the engine
+	// supplies it through SetBlackoutSource.
+	blackout atomic.Pointer[func() bool]
+	// conns reports the drivers' connection pools; the engine supplies
+	// their stats through SetConnStatsSource.
+	conns *connCollector
+
+	// perTarget enables the target-labelled series below.
+	perTarget      bool
+	targetRequests *prometheus.CounterVec
+	targetDuration *prometheus.HistogramVec
+}
+
+// Options configures optional metric series.
+type Options struct {
+	// PerTarget adds sendit_target_requests_total and
+	// sendit_target_request_duration_seconds, labelled with the full target
+	// URL. Series grow with the number of targets, so it is off by default.
+	PerTarget bool
+	// RunID, if set, is added to every series as a run_id label, so that
+	// runs scraped into the same Prometheus can be told apart.
+	RunID string
 }
 
 // New creates and registers a Metrics instance on an isolated registry,
 // preventing double-registration panics when multiple instances are created
 // (e.g. in tests).
 func New() *Metrics {
-	reg := prometheus.NewRegistry()
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions is New with optional series enabled by opts.
+func NewWithOptions(opts Options) *Metrics {
+	registry := prometheus.NewRegistry()
+	var reg prometheus.Registerer = registry
+	if opts.RunID != "" {
+		reg = prometheus.WrapRegistererWith(prometheus.Labels{"run_id": opts.RunID}, registry)
+	}
 
 	m := &Metrics{
-		registry: reg,
+		registry: registry,
+		conns:    newConnCollector(),
 		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "sendit_requests_total",
 			Help: "Total number of requests dispatched, by type, domain, and status code.",
@@ -41,6 +92,11 @@ func New() *Metrics {
 			Help: "Total number of request errors, by type and domain.",
 		}, []string{"type", "domain", "error_class"}),
 
+		slowTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_slow_total",
+			Help: "Total number of responses slower than their target's latency_budget_ms, by type and domain.",
+		}, []string{"type", "domain"}),
+
 		durationSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "sendit_request_duration_seconds",
 			Help:    "Request duration in seconds, by type and domain.",
@@ -51,25 +107,148 @@ func New() *Metrics {
 			Name: "sendit_bytes_read_total",
 			Help: "Total bytes read from responses, by type.",
 		}, []string{"type"}),
+
+		outputDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_output_dropped_total",
+			Help: "Total result records discarded, from a full output buffer or undelivered by a sink, by sink.",
+		}, []string{"sink"}),
+
+		waitSeconds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_wait_seconds_total",
+			Help: "Total time requests spent held before dispatch, by domain and reason (rate_limit or backoff).",
+		}, []string{"domain", "reason"}),
+
+		skipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_skipped_total",
+			Help: "Total tasks dropped without being sent, by domain and reason (cooldown).",
+		}, []string{"domain", "reason"}),
+
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_retries_total",
+			Help: "Total failed tasks the retry policy sent again (retried) or dropped for lack of budget (budget_exhausted), by type and domain.",
+		}, []string{"type", "domain", "result"}),
+
+		dnsQueries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_dns_queries_total",
+			Help: "Total DNS queries, by domain, record type, and response code (\"error\" when no response arrived).",
+		}, []string{"domain", "record_type", "rcode"}),
+
+		dnsDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sendit_dns_query_duration_seconds",
+			Help:    "DNS query duration in seconds, from the first query sent to the answer returned, by domain and record type.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"domain", "record_type"}),
+
+		dnsRTT: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sendit_dns_rtt_seconds",
+			Help:    "DNS round-trip time in seconds as measured by the DNS client for answered queries, by domain and record type.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"domain", "record_type"}),
+
+		dnsResolvers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "sendit_dns_resolver_healthy",
+			Help: "1 while a DNS resolver answers queries, 0 once it has failed over to the next in its target's dns.resolvers list.",
+		}, []string{"resolver"}),
+
+		httpResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_http_responses_total",
+			Help: "Total HTTP responses, by domain, protocol (HTTP/1.1, HTTP/2, ...), and whether Alt-Svc advertised HTTP/3.",
+		}, []string{"domain", "protocol", "h3_advertised"}),
+
+		redirectHops: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sendit_redirect_hops",
+			Help:    "Redirects followed by HTTP requests that were redirected, by domain; requests that hit the limit of 10 fall in the top bucket.",
+			Buckets: []float64{1, 2, 3, 5, 9},
+		}, []string{"domain"}),
+
+		brokenPages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_browser_broken_pages_total",
+			Help: "Browser pages that loaded but logged JavaScript errors or had requests fail, by domain.",
+		}, []string{"domain"}),
+
+		abortedTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_aborted_requests_total",
+			Help: "Requests cancelled mid-flight by abort_probability, by type and domain; they are not counted as requests or errors.",
+		}, []string{"type", "domain"}),
 	}
 
 	reg.MustRegister(
 		m.requestsTotal,
 		m.errorsTotal,
+		m.slowTotal,
 		m.durationSeconds,
 		m.bytesRead,
+		m.outputDropped,
+		m.waitSeconds,
+		m.skipped,
+		m.retries,
+		m.dnsQueries,
+		m.dnsDuration,
+		m.dnsRTT,
+		m.dnsResolvers,
+		m.httpResponses,
+		m.redirectHops,
+		m.brokenPages,
+		m.abortedTotal,
+		m.conns,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "sendit_backoff_domains",
+			Help: "Number of domains currently backing off after transient errors.",
+		}, func() float64 {
+			if fn := m.backoffDomains.Load(); fn != nil {
+				return float64((*fn)())
+			}
+			return 0
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "sendit_blackout_active",
+			Help: "1 while a blackout period holds dispatch, 0 otherwise.",
+		}, func() float64 {
+			if fn := m.blackout.Load(); fn != nil && (*fn)() {
+				return 1
+			}
+			return 0
+		}),
 	)
 
+	if opts.PerTarget {
+		m.perTarget = true
+		m.targetRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "sendit_target_requests_total",
+			Help: "Total number of completed requests, by target URL, type, and result (success or error).",
+		}, []string{"target", "type", "result"})
+		m.targetDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "sendit_target_request_duration_seconds",
+			Help:    "Request duration in seconds, by target URL.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"target"})
+		reg.MustRegister(m.targetRequests, m.targetDuration)
+	}
+
 	return m
 }
 
 // Noop returns a Metrics instance that does nothing (used when metrics disabled).
 func Noop() *Metrics {
 	return &Metrics{
+		conns:           newConnCollector(),
 		requestsTotal:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_requests"}, []string{"type", "domain", "status_code"}),
 		errorsTotal:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_errors"}, []string{"type", "domain", "error_class"}),
+		slowTotal:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_slow"}, []string{"type", "domain"}),
 		durationSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "noop_duration"}, []string{"type", "domain"}),
 		bytesRead:       prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_bytes"}, []string{"type"}),
+		outputDropped:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_output_dropped"}, []string{"sink"}),
+		waitSeconds:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_wait"}, []string{"domain", "reason"}),
+		skipped:         prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_skipped"}, []string{"domain", "reason"}),
+		retries:         prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_retries"}, []string{"type", "domain", "result"}),
+		dnsQueries:      prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_dns_queries"}, []string{"domain", "record_type", "rcode"}),
+		dnsDuration:     prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "noop_dns_duration"}, []string{"domain", "record_type"}),
+		dnsRTT:          prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "noop_dns_rtt"}, []string{"domain", "record_type"}),
+		dnsResolvers:    prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "noop_dns_resolvers"}, []string{"resolver"}),
+		httpResponses:   prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_http_responses"}, []string{"domain", "protocol", "h3_advertised"}),
+		redirectHops:    prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "noop_redirect_hops"}, []string{"domain"}),
+		brokenPages:     prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_broken_pages"}, []string{"domain"}),
+		abortedTotal:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: "noop_aborted"}, []string{"type", "domain"}),
 	}
 }
 
@@ -77,21 +256,129 @@ func Noop() *Metrics {
 func (m *Metrics) Record(r task.Result) {
 	t := r.Task.Type
 	d := domainOf(r.Task.URL)
-	m.durationSeconds.WithLabelValues(t, d).Observe(r.Duration.Seconds())
-
 	if r.BytesRead > 0 {
 		m.bytesRead.WithLabelValues(t).Add(float64(r.BytesRead))
 	}
+	// An aborted request's duration is the delay it was cancelled after.
+	if r.Aborted {
+		m.abortedTotal.WithLabelValues(t, d).Inc()
+		return
+	}
+	m.durationSeconds.WithLabelValues(t, d).Observe(r.Duration.Seconds())
+
+	if m.perTarget {
+		result := "success"
+		if r.Error != nil || r.StatusCode >= 400 {
+			result = "error"
+		}
+		m.targetRequests.WithLabelValues(r.Task.URL, t, result).Inc()
+		m.targetDuration.WithLabelValues(r.Task.URL).Observe(r.Duration.Seconds())
+	}
+
+	if rt := r.Meta["dns_record_type"]; t == "dns" && rt != "" {
+		rcode := r.Meta["dns_rcode"]
+		if rcode == "" {
+			rcode = "error"
+		}
+		m.dnsQueries.WithLabelValues(d, rt, rcode).Inc()
+		m.dnsDuration.WithLabelValues(d, rt).Observe(r.Duration.Seconds())
+		if ms, err := strconv.ParseFloat(r.Meta["dns_rtt_ms"], 64); err == nil {
+			m.dnsRTT.WithLabelValues(d, rt).Observe(ms / 1000)
+		}
+	}
+
+	if t == "http" && r.Protocol != "" {
+		m.httpResponses.WithLabelValues(d, r.Protocol, strconv.FormatBool(r.AdvertisesH3())).Inc()
+	}
+	if n := len(r.Redirects); n > 0 {
+		m.redirectHops.WithLabelValues(d).Observe(float64(n))
+	}
+	if t == "browser" && r.Broken() {
+		m.brokenPages.WithLabelValues(d).Inc()
+	}
 
 	if r.Error != nil {
 		m.errorsTotal.WithLabelValues(t, d, "error").Inc()
 		return
 	}
 
+	if r.Slow() {
+		m.slowTotal.WithLabelValues(t, d).Inc()
+	}
 	code := fmt.Sprintf("%d", r.StatusCode)
 	m.requestsTotal.WithLabelValues(t, d, code).Inc()
 }
 
+// RecordOutputDropped counts a result discarded by the named output sink
+// (e.g. "file" or "syslog") because its buffer was full.
+func (m *Metrics) RecordOutputDropped(sink string) {
+	m.outputDropped.WithLabelValues(sink).Inc()
+}
+
+// Reasons a request is held before dispatch, for RecordWait.
+const (
+	WaitRateLimit = "rate_limit"
+	WaitBackoff   = "backoff"
+)
+
+// RecordWait adds time a request to domain spent held by the per-domain
+// rate limiter (WaitRateLimit) or by backoff (WaitBackoff).
+func (m *Metrics) RecordWait(domain, reason string, d time.Duration) {
+	if d > 0 {
+		m.waitSeconds.WithLabelValues(domain, reason).Add(d.Seconds())
+	}
+}
+
+// Reasons a task is skipped, for RecordSkipped.
+const (
+	SkipCooldown = "cooldown"
+)
+
+// RecordSkipped counts a task to domain dropped for reason without being
+// sent, such as while the domain is in backoff cooldown (SkipCooldown).
+func (m *Metrics) RecordSkipped(domain, reason string) {
+	m.skipped.WithLabelValues(domain, reason).Inc()
+}
+
+// Outcomes of a retry decision, for RecordRetry.
+const (
+	RetrySent            = "retried"
+	RetryBudgetExhausted = "budget_exhausted"
+)
+
+// RecordRetry counts a failed task to domain that the retry policy sent
+// again (RetrySent) or would have but for its budget (RetryBudgetExhausted).
+func (m *Metrics) RecordRetry(typ, domain, result string) {
+	m.retries.WithLabelValues(typ, domain, result).Inc()
+}
+
+// SetResolverHealth sets sendit_dns_resolver_healthy for resolver (a
+// host:port) to whether it is answering DNS queries.
+func (m *Metrics) SetResolverHealth(resolver string, healthy bool) {
+	v := 0.0
+	if healthy {
+		v = 1
+	}
+	m.dnsResolvers.WithLabelValues(resolver).Set(v)
+}
+
+// SetBackoffSource registers the function sendit_backoff_domains reports.
+func (m *Metrics) SetBackoffSource(fn func() int) {
+	m.backoffDomains.Store(&fn)
+}
+
+// SetBlackoutSource registers the function sendit_blackout_active reports.
+func (m *Metrics) SetBlackoutSource(fn func() bool) {
+	m.blackout.Store(&fn)
+}
+
+// SetConnStatsSource registers the function that reports each driver
+// type's connection pool, for sendit_connections_acquired_total,
+// sendit_connections_open, and sendit_connections_idle.
+func (m *Metrics) SetConnStatsSource(fn func() map[string]ConnStats) {
+	m.conns.src.Store(&fn)
+}
+
 // domainOf extracts the hostname from a URL string.
 // For bare hostnames (DNS targets) it returns the string as-is.
 func domainOf(rawURL string) string {
@@ -11,6 +11,7 @@ import (
 
 	"github.com/lewta/sendit/internal/config"
 	"github.com/lewta/sendit/internal/task"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // makeResult creates a task.Result for testing.
@@ -248,3 +249,268 @@ func TestDomainOf(t *testing.T) {
 		}
 	}
 }
+
+func TestRecordOutputDropped(t *testing.T) {
+	m := New()
+	m.RecordOutputDropped("file")
+	m.RecordOutputDropped("file")
+	m.RecordOutputDropped("syslog")
+
+	if got := testutil.ToFloat64(m.outputDropped.WithLabelValues("file")); got != 2 {
+		t.Errorf("file drops = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.outputDropped.WithLabelValues("syslog")); got != 1 {
+		t.Errorf("syslog drops = %v, want 1", got)
+	}
+
+	Noop().RecordOutputDropped("file") // must not panic
+}
+
+func TestRecordWaitAndBackoffSource(t *testing.T) {
+	m := New()
+	m.RecordWait("a.com", WaitRateLimit, 1500*time.Millisecond)
+	m.RecordWait("a.com", WaitRateLimit, 500*time.Millisecond)
+	m.RecordWait("a.com", WaitBackoff, 0)
+	if got := testutil.ToFloat64(m.waitSeconds.WithLabelValues("a.com", WaitRateLimit)); got != 2 {
+		t.Errorf("rate-limit wait = %v, want 2", got)
+	}
+	if n := testutil.CollectAndCount(m.waitSeconds); n != 1 {
+		t.Errorf("wait series = %d, want 1 (zero waits are not recorded)", n)
+	}
+
+	m.SetBackoffSource(func() int { return 3 })
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got float64 = -1
+	for _, f := range families {
+		if f.GetName() == "sendit_backoff_domains" {
+			got = f.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	if got != 3 {
+		t.Errorf("sendit_backoff_domains = %v, want 3", got)
+	}
+
+	Noop().RecordWait("a.com", WaitBackoff, time.Second) // must not panic
+}
+
+func TestBlackoutSource(t *testing.T) {
+	m := New()
+	gauge := func() float64 {
+		families, err := m.registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range families {
+			if f.GetName() == "sendit_blackout_active" {
+				return f.GetMetric()[0].GetGauge().GetValue()
+			}
+		}
+		return -1
+	}
+	if got := gauge(); got != 0 {
+		t.Errorf("sendit_blackout_active without a source = %v, want 0", got)
+	}
+	m.SetBlackoutSource(func() bool { return true })
+	if got := gauge(); got != 1 {
+		t.Errorf("sendit_blackout_active = %v, want 1", got)
+	}
+}
+
+func TestRecordSkipped(t *testing.T) {
+	m := New()
+	m.RecordSkipped("a.com", SkipCooldown)
+	m.RecordSkipped("a.com", SkipCooldown)
+	if got := testutil.ToFloat64(m.skipped.WithLabelValues("a.com", SkipCooldown)); got != 2 {
+		t.Errorf("skipped = %v, want 2", got)
+	}
+	Noop().RecordSkipped("a.com", SkipCooldown) // must not panic
+}
+
+func TestRecord_Slow(t *testing.T) {
+	m := New()
+	slow := makeResult("http", 200, 9*time.Second, 0, nil)
+	slow.Task.Config.LatencyBudgetMs = 8000
+	fast := slow
+	fast.Duration = time.Second
+	failed := slow
+	failed.Error = errSentinel{}
+	unbudgeted := makeResult("http", 200, 9*time.Second, 0, nil)
+	for _, r := range []task.Result{slow, fast, failed, unbudgeted} {
+		m.Record(r)
+	}
+	if got := testutil.ToFloat64(m.slowTotal.WithLabelValues("http", "example.com")); got != 1 {
+		t.Errorf("slow = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("http", "example.com", "200")); got != 3 {
+		t.Errorf("requests = %v, want 3 (slow responses still count)", got)
+	}
+}
+
+func TestRecord_HTTPProtocol(t *testing.T) {
+	m := New()
+	for _, p := range []struct{ proto, altSvc string }{{"HTTP/2", "h3,h3-29"}, {"HTTP/2", ""}, {"HTTP/1.1", "h2"}, {"", ""}} {
+		r := makeResult("http", 200, 5*time.Millisecond, 0, nil)
+		r.Protocol, r.AltSvc = p.proto, p.altSvc
+		m.Record(r)
+	}
+	for _, c := range []struct {
+		proto, h3 string
+		want      float64
+	}{{"HTTP/2", "true", 1}, {"HTTP/2", "false", 1}, {"HTTP/1.1", "false", 1}} {
+		if got := testutil.ToFloat64(m.httpResponses.WithLabelValues("example.com", c.proto, c.h3)); got != c.want {
+			t.Errorf("http_responses{%s,%s} = %v, want %v", c.proto, c.h3, got, c.want)
+		}
+	}
+	if n := testutil.CollectAndCount(m.httpResponses); n != 3 {
+		t.Errorf("got %d series, want 3 (no series without a protocol)", n)
+	}
+}
+
+func TestRecord_RedirectHops(t *testing.T) {
+	m := New()
+	hop := task.Hop{URL: "https://example.com/a", StatusCode: 302}
+	for _, chain := range [][]task.Hop{nil, {hop}, {hop, hop}, make([]task.Hop, 10)} {
+		r := makeResult("http", 200, 5*time.Millisecond, 0, nil)
+		r.Redirects = chain
+		m.Record(r)
+	}
+	// Requests without redirects are not observed.
+	if got := testutil.CollectAndCount(m.redirectHops); got != 1 {
+		t.Fatalf("got %d series, want 1", got)
+	}
+	families, err := m.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != "sendit_redirect_hops" {
+			continue
+		}
+		h := f.GetMetric()[0].GetHistogram()
+		if n, sum := h.GetSampleCount(), h.GetSampleSum(); n != 3 || sum != 13 {
+			t.Errorf("count, sum = %d, %v; want 3, 13", n, sum)
+		}
+	}
+}
+
+func TestRecord_BrokenPages(t *testing.T) {
+	m := New()
+	for _, r := range []task.Result{
+		makeResult("browser", 200, time.Second, 0, nil),
+		{ConsoleErrors: 2},
+		{FailedRequests: 1},
+		{FailedRequests: 1, Error: errSentinel{}}, // failed outright, not broken
+	} {
+		res := makeResult("browser", 200, time.Second, 0, r.Error)
+		res.ConsoleErrors, res.FailedRequests = r.ConsoleErrors, r.FailedRequests
+		m.Record(res)
+	}
+	if got := testutil.ToFloat64(m.brokenPages.WithLabelValues("example.com")); got != 2 {
+		t.Errorf("broken_pages = %v, want 2", got)
+	}
+}
+
+func TestRecord_Aborted(t *testing.T) {
+	m := New()
+	r := makeResult("http", 200, 50*time.Millisecond, 100, nil)
+	r.Aborted = true
+	m.Record(r)
+
+	if got := testutil.ToFloat64(m.abortedTotal.WithLabelValues("http", "example.com")); got != 1 {
+		t.Errorf("aborted = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("http", "example.com", "200")); got != 0 {
+		t.Errorf("requests = %v, want 0 for an aborted request", got)
+	}
+	if got := testutil.ToFloat64(m.bytesRead.WithLabelValues("http")); got != 100 {
+		t.Errorf("bytes = %v, want 100", got)
+	}
+}
+
+func TestRecord_DNSRecordType(t *testing.T) {
+	m := New()
+	for _, q := range []struct{ rt, rcode string }{{"A", "NOERROR"}, {"A", "NOERROR"}, {"AAAA", "NXDOMAIN"}, {"HTTPS", ""}} {
+		r := makeResult("dns", 200, 5*time.Millisecond, 0, nil)
+		r.Task.URL = "example.com"
+		r.Meta = map[string]string{"dns_record_type": q.rt}
+		if q.rcode != "" {
+			r.Meta["dns_rcode"] = q.rcode
+			r.Meta["dns_rtt_ms"] = "2.500"
+		} else {
+			r.Error = errSentinel{}
+		}
+		m.Record(r)
+	}
+	for _, c := range []struct {
+		rt, rcode string
+		want      float64
+	}{{"A", "NOERROR", 2}, {"AAAA", "NXDOMAIN", 1}, {"HTTPS", "error", 1}} {
+		if got := testutil.ToFloat64(m.dnsQueries.WithLabelValues("example.com", c.rt, c.rcode)); got != c.want {
+			t.Errorf("dns_queries{%s,%s} = %v, want %v", c.rt, c.rcode, got, c.want)
+		}
+	}
+	if n := testutil.CollectAndCount(m.dnsDuration); n != 3 {
+		t.Errorf("dns duration series = %d, want 3 (one per record type)", n)
+	}
+	// Only answered queries have a client round-trip time.
+	if n := testutil.CollectAndCount(m.dnsRTT); n != 2 {
+		t.Errorf("dns rtt series = %d, want 2 (A and AAAA)", n)
+	}
+}
+
+func TestRecordRetry(t *testing.T) {
+	m := New()
+	m.RecordRetry("http", "a.com", RetrySent)
+	m.RecordRetry("http", "a.com", RetrySent)
+	m.RecordRetry("http", "a.com", RetryBudgetExhausted)
+	if got := testutil.ToFloat64(m.retries.WithLabelValues("http", "a.com", RetrySent)); got != 2 {
+		t.Errorf("retried = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.retries.WithLabelValues("http", "a.com", RetryBudgetExhausted)); got != 1 {
+		t.Errorf("budget_exhausted = %v, want 1", got)
+	}
+	Noop().RecordRetry("http", "a.com", RetrySent) // must not panic
+}
+
+func TestSetResolverHealth(t *testing.T) {
+	m := New()
+	m.SetResolverHealth("10.0.0.1:53", true)
+	m.SetResolverHealth("10.0.0.2:53", true)
+	m.SetResolverHealth("10.0.0.1:53", false)
+	if got := testutil.ToFloat64(m.dnsResolvers.WithLabelValues("10.0.0.1:53")); got != 0 {
+		t.Errorf("failed resolver = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(m.dnsResolvers.WithLabelValues("10.0.0.2:53")); got != 1 {
+		t.Errorf("healthy resolver = %v, want 1", got)
+	}
+	Noop().SetResolverHealth("10.0.0.1:53", true) // must not panic
+}
+
+func TestSetConnStatsSource(t *testing.T) {
+	m := New()
+	if n := testutil.CollectAndCount(m.conns); n != 0 {
+		t.Errorf("got %d series before a source is set, want 0", n)
+	}
+	m.SetConnStatsSource(func() map[string]ConnStats {
+		return map[string]ConnStats{"http": {New: 2, Reused: 5, Open: 2, Idle: 1}}
+	})
+	want := `
+# HELP sendit_connections_acquired_total Total requests that got a connection, by driver type and whether it was reused from the pool ("true") or newly dialed ("false").
+# TYPE sendit_connections_acquired_total counter
+sendit_connections_acquired_total{reused="false",type="http"} 2
+sendit_connections_acquired_total{reused="true",type="http"} 5
+# HELP sendit_connections_idle Open connections no request is using, by driver type.
+# TYPE sendit_connections_idle gauge
+sendit_connections_idle{type="http"} 1
+# HELP sendit_connections_open Connections a driver holds open, by driver type.
+# TYPE sendit_connections_open gauge
+sendit_connections_open{type="http"} 2
+`
+	if err := testutil.CollectAndCompare(m.conns, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+	Noop().SetConnStatsSource(func() map[string]ConnStats { return nil }) // must not panic
+}
@@ -0,0 +1,104 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lewta/sendit/internal/task"
+)
+
+// clfTimeLayout is the timestamp format used by the NCSA common and combined
+// log formats, e.g. 10/Oct/2000:13:55:36 -0700.
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// defaultHTTPUserAgent is what net/http sends when no User-Agent is set.
+const defaultHTTPUserAgent = "Go-http-client/1.1"
+
+type clfEncoder struct{ bw *bufio.Writer }
+
+func (e *clfEncoder) header() error { return nil }
+
+// encode writes r as a CLF line, silently skipping results that have no
+// combined-log representation.
+func (e *clfEncoder) encode(r task.Result) error {
+	line, ok := toCLFLine(r, time.Now())
+	if !ok {
+		return nil
+	}
+	_, err := e.bw.WriteString(line)
+	return err
+}
+
+// toCLFLine renders r as an NCSA combined log line:
+//
+//	host ident authuser [date] "method path proto" status bytes "referer" "user-agent"
+//
+// Only http and browser results that received a response are rendered —
+// a server would never log a request that failed before reaching it — so ok
+// is false for everything else. The client host is not known to sendit and
+// is written as "-".
+func toCLFLine(r task.Result, now time.Time) (string, bool) {
+	if r.Task.Type != "http" && r.Task.Type != "browser" {
+		return "", false
+	}
+	if r.Error != nil || r.StatusCode == 0 {
+		return "", false
+	}
+
+	method := http.MethodGet
+	userAgent := "-"
+	referer := "-"
+	if r.Task.Type == "http" {
+		cfg := r.Task.Config.HTTP
+		if cfg.Method != "" {
+			method = strings.ToUpper(cfg.Method)
+		}
+		userAgent = defaultHTTPUserAgent
+		if v := headerValue(cfg.Headers, "User-Agent"); v != "" {
+			userAgent = v
+		}
+		if v := headerValue(cfg.Headers, "Referer"); v != "" {
+			referer = v
+		}
+	}
+
+	path := "/"
+	if u, err := url.Parse(r.Task.URL); err == nil && u.RequestURI() != "" {
+		path = u.RequestURI()
+	}
+
+	// Browser results do not record the protocol.
+	proto := r.Protocol
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	size := "-"
+	if r.BytesRead > 0 {
+		size = fmt.Sprintf("%d", r.BytesRead)
+	}
+
+	return fmt.Sprintf("- - - [%s] %q %d %s %q %q\n",
+		now.Format(clfTimeLayout),
+		method+" "+path+" "+proto,
+		r.StatusCode,
+		size,
+		referer,
+		userAgent,
+	), true
+}
+
+// headerValue looks up name in headers case-insensitively; config keys are
+// lower-cased by the YAML loader but may be canonical when built in code.
+func headerValue(headers map[string]string, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package output
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+)
+
+func TestToCLFLine_HTTP(t *testing.T) {
+	r := task.Result{
+		Task: task.Task{
+			URL:  "https://example.com/search?q=go",
+			Type: "http",
+			Config: config.TargetConfig{
+				Type: "http",
+				HTTP: config.HTTPConfig{
+					Method: "post",
+					Headers: map[string]string{
+						"user-agent": "Mozilla/5.0",
+						"referer":    "https://example.com/",
+					},
+				},
+			},
+		},
+		StatusCode: 201,
+		BytesRead:  512,
+	}
+	now := time.Date(2026, 10, 14, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+
+	got, ok := toCLFLine(r, now)
+	if !ok {
+		t.Fatal("toCLFLine returned ok=false for an http result")
+	}
+	want := `- - - [14/Oct/2026:13:55:36 -0700] "POST /search?q=go HTTP/1.1" 201 512 "https://example.com/" "Mozilla/5.0"` + "\n"
+	if got != want {
+		t.Errorf("line =\n  %q\nwant\n  %q", got, want)
+	}
+}
+
+func TestToCLFLine_DefaultsAndSkips(t *testing.T) {
+	now := time.Now()
+
+	browser := makeResult("https://example.com", "browser", 200, time.Millisecond, 0, nil)
+	line, ok := toCLFLine(browser, now)
+	if !ok {
+		t.Fatal("browser result should be rendered")
+	}
+	if !strings.Contains(line, `"GET / HTTP/1.1" 200 - "-" "-"`) {
+		t.Errorf("browser line = %q, want defaults for path, bytes, referer, and UA", line)
+	}
+
+	h2 := makeResult("https://example.com/", "http", 200, time.Millisecond, 0, nil)
+	h2.Protocol = "HTTP/2"
+	if line, _ := toCLFLine(h2, now); !strings.Contains(line, `"GET / HTTP/2" 200`) {
+		t.Errorf("HTTP/2 line = %q, want the response's protocol", line)
+	}
+
+	if _, ok := toCLFLine(makeResult("example.com", "dns", 200, time.Millisecond, 0, nil), now); ok {
+		t.Error("dns result should be skipped")
+	}
+	if _, ok := toCLFLine(makeResult("https://example.com", "http", 0, time.Millisecond, 0, errors.New("refused")), now); ok {
+		t.Error("result without a response should be skipped")
+	}
+}
+
+func TestWriter_CLF(t *testing.T) {
+	f := t.TempDir() + "/access.log"
+	w, err := New(config.OutputConfig{File: f, Format: "clf"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	w.Send(makeResult("https://example.com/a", "http", 200, time.Millisecond, 10, nil))
+	w.Send(makeResult("example.com", "dns", 200, time.Millisecond, 0, nil))
+	w.Send(makeResult("https://example.com/b", "http", 404, time.Millisecond, 0, nil))
+	w.Close()
+
+	data, err := os.ReadFile(f)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2 (dns skipped):\n%s", len(lines), data)
+	}
+	if !strings.Contains(lines[0], `"GET /a HTTP/1.1" 200 10`) {
+		t.Errorf("line 0 = %q", lines[0])
+	}
+	if !strings.Contains(lines[1], `"GET /b HTTP/1.1" 404 -`) {
+		t.Errorf("line 1 = %q", lines[1])
+	}
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+const defaultESIndex = "sendit-{2006.01.02}"
+
+// NewElasticsearchSink returns an HTTPSink that indexes result records via
+// the Elasticsearch / OpenSearch _bulk API. cfg.URL is the cluster base URL;
+// the index name is expanded per record from cfg.Index so daily (or other
+// date-based) indices work without an ingest pipeline.
+//
+// Credentials are either basic auth (username + password) or an API key,
+// each of which can be supplied literally or via an environment variable.
+func NewElasticsearchSink(cfg config.SinkConfig) (*HTTPSink, error) {
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+
+	switch {
+	case cfg.APIKey != "" || cfg.APIKeyEnv != "":
+		key, err := sinkSecret(cfg.APIKey, cfg.APIKeyEnv, "api_key")
+		if err != nil {
+			return nil, err
+		}
+		headers["Authorization"] = "ApiKey " + key
+	case cfg.Username != "":
+		password := cfg.Password
+		if password == "" && cfg.PasswordEnv != "" {
+			p, err := sinkSecret("", cfg.PasswordEnv, "password")
+			if err != nil {
+				return nil, err
+			}
+			password = p
+		}
+		creds := base64.StdEncoding.EncodeToString([]byte(cfg.Username + ":" + password))
+		headers["Authorization"] = "Basic " + creds
+	}
+
+	index := cfg.Index
+	if index == "" {
+		index = defaultESIndex
+	}
+
+	s := newHTTPSink(cfg)
+	s.url = strings.TrimRight(cfg.URL, "/") + "/_bulk"
+	s.headers = headers
+	s.contentType = "application/x-ndjson"
+	s.name = "elasticsearch"
+	s.encode = func(batch []map[string]any) ([]byte, error) {
+		return encodeBulk(index, batch)
+	}
+	s.checkBody = checkBulkResponse
+	go s.run()
+	return s, nil
+}
+
+// encodeBulk renders batch as a _bulk request body: an action line followed
+// by the document for every record.
+func encodeBulk(indexPattern string, batch []map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range batch {
+		ts := time.Now().UTC()
+		if s, ok := rec["ts"].(string); ok {
+			if parsed, err := time.Parse(time.RFC3339, s); err == nil {
+				ts = parsed
+			}
+		}
+		action := map[string]any{"index": map[string]string{"_index": expandIndex(indexPattern, ts)}}
+		if err := enc.Encode(action); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(rec); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// expandIndex replaces every {layout} segment in pattern with ts formatted
+// using that Go time layout.
+func expandIndex(pattern string, ts time.Time) string {
+	var b strings.Builder
+	for {
+		open := strings.IndexByte(pattern, '{')
+		if open < 0 {
+			b.WriteString(pattern)
+			return b.String()
+		}
+		end := strings.IndexByte(pattern[open:], '}')
+		if end < 0 {
+			b.WriteString(pattern)
+			return b.String()
+		}
+		b.WriteString(pattern[:open])
+		b.WriteString(ts.Format(pattern[open+1 : open+end]))
+		pattern = pattern[open+end+1:]
+	}
+}
+
+// checkBulkResponse reports per-document failures, which _bulk signals with
+// a 200 response and "errors": true rather than an HTTP error status. Items
+// come back in request order; those that failed with 429 or 5xx are
+// returned in a *partialError to be sent again, and the rest of the failed
+// ones are rejected for good.
+func checkBulkResponse(body []byte) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decoding bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	perr := &partialError{total: len(resp.Items)}
+	for i, item := range resp.Items {
+		for _, op := range item {
+			switch {
+			case op.Status == http.StatusTooManyRequests || op.Status >= 500:
+				perr.retry = append(perr.retry, i)
+			case op.Status >= 300:
+				perr.rejected++
+			}
+		}
+	}
+	return perr
+}
+
+// sinkSecret returns literal if set, otherwise the value of envVar.
+func sinkSecret(literal, envVar, field string) (string, error) {
+	if literal != "" {
+		return literal, nil
+	}
+	val := os.Getenv(envVar)
+	if val == "" {
+		return "", fmt.Errorf("env var %q (%s_env) is not set", envVar, field)
+	}
+	return val, nil
+}
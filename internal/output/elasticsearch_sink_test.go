@@ -0,0 +1,165 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+func TestElasticsearchSink_BulkFormat(t *testing.T) {
+	var (
+		path  string
+		ctype string
+		auth  string
+		lines []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		ctype = r.Header.Get("Content-Type")
+		auth = r.Header.Get("Authorization")
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewElasticsearchSink(config.SinkConfig{
+		Type:   "elasticsearch",
+		URL:    srv.URL + "/",
+		Index:  "probes-{2006.01}",
+		APIKey: "abc123",
+	})
+	if err != nil {
+		t.Fatalf("NewElasticsearchSink: %v", err)
+	}
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 10, nil))
+	s.Close()
+
+	if path != "/_bulk" {
+		t.Errorf("path = %q, want /_bulk", path)
+	}
+	if ctype != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ctype)
+	}
+	if auth != "ApiKey abc123" {
+		t.Errorf("Authorization = %q, want ApiKey abc123", auth)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2 (action + document)", len(lines))
+	}
+
+	var action map[string]map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		t.Fatalf("decoding action line: %v", err)
+	}
+	want := "probes-" + time.Now().UTC().Format("2006.01")
+	if action["index"]["_index"] != want {
+		t.Errorf("_index = %q, want %q", action["index"]["_index"], want)
+	}
+	if !strings.Contains(lines[1], `"url":"https://example.com"`) {
+		t.Errorf("document line = %s, want url field", lines[1])
+	}
+}
+
+func TestElasticsearchSink_BasicAuthFromEnv(t *testing.T) {
+	t.Setenv("SENDIT_TEST_ES_PASSWORD", "s3cret")
+	var user, pass string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ = r.BasicAuth()
+		_, _ = w.Write([]byte(`{"errors":false}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewElasticsearchSink(config.SinkConfig{
+		URL:         srv.URL,
+		Username:    "elastic",
+		PasswordEnv: "SENDIT_TEST_ES_PASSWORD",
+	})
+	if err != nil {
+		t.Fatalf("NewElasticsearchSink: %v", err)
+	}
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil))
+	s.Close()
+
+	if user != "elastic" || pass != "s3cret" {
+		t.Errorf("basic auth = %q:%q, want elastic:s3cret", user, pass)
+	}
+}
+
+func TestElasticsearchSink_MissingAPIKeyEnv(t *testing.T) {
+	_, err := NewElasticsearchSink(config.SinkConfig{URL: "http://localhost:9200", APIKeyEnv: "SENDIT_TEST_UNSET_KEY"})
+	if err == nil {
+		t.Fatal("expected error when api_key_env is unset")
+	}
+}
+
+func TestExpandIndex(t *testing.T) {
+	ts := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct{ pattern, want string }{
+		{"sendit", "sendit"},
+		{"sendit-{2006.01.02}", "sendit-2026.03.07"},
+		{"{2006}-probes-{01}", "2026-probes-03"},
+		{"broken-{2006", "broken-{2006"},
+	}
+	for _, tt := range tests {
+		if got := expandIndex(tt.pattern, ts); got != tt.want {
+			t.Errorf("expandIndex(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBulkResponse(t *testing.T) {
+	if err := checkBulkResponse([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := checkBulkResponse([]byte(`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400}},{"index":{"status":429}},{"index":{"status":503}}]}`))
+	var perr *partialError
+	if !errors.As(err, &perr) || perr.rejected != 1 || !slices.Equal(perr.retry, []int{2, 3}) {
+		t.Errorf("err = %#v, want 1 rejected and items 2, 3 to retry", err)
+	}
+}
+
+func TestElasticsearchSink_RetriesOnlyDeferredDocuments(t *testing.T) {
+	var bodies [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var lines []string
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		bodies = append(bodies, lines)
+		if len(bodies) == 1 {
+			_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429}},{"index":{"status":400}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+	}))
+	defer srv.Close()
+
+	s, err := NewElasticsearchSink(config.SinkConfig{Type: "elasticsearch", URL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewElasticsearchSink: %v", err)
+	}
+	s.retryBase = time.Millisecond
+	for _, u := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"} {
+		s.Send(makeResult(u, "http", 200, time.Millisecond, 0, nil))
+	}
+	s.Close()
+
+	if len(bodies) != 2 {
+		t.Fatalf("got %d bulk requests, want 2", len(bodies))
+	}
+	if len(bodies[1]) != 2 || !strings.Contains(bodies[1][1], `"url":"https://b.example.com"`) {
+		t.Errorf("retry = %v, want only the 429 document", bodies[1])
+	}
+}
@@ -0,0 +1,254 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultSinkBatchSize     = 100
+	defaultSinkFlushInterval = time.Second
+	defaultSinkTimeout       = 10 * time.Second
+	defaultSinkMaxRetries    = 3
+	maxSinkRetryDelay        = 30 * time.Second
+)
+
+// HTTPSink POSTs batches of result records to a remote collector as a JSON
+// array. Each record has the same shape as a JSONL output line.
+//
+// Like the file writer, Send drops the record when the internal buffer is
+// full unless the sink is configured with on_full: block, in which case a
+// slow collector applies backpressure to dispatch. Failed batches are
+// retried with exponential backoff on network errors, 429, and 5xx; the
+// records of a batch that is given up on are counted as dropped too.
+type HTTPSink struct {
+	url           string
+	headers       map[string]string
+	batchSize     int
+	flushInterval time.Duration
+	timeout       time.Duration
+	maxRetries    int
+	retryBase     time.Duration
+	client        *http.Client
+
+	// contentType, encode, and checkBody let other HTTP-based sinks (e.g.
+	// Elasticsearch) reuse the batching and retry machinery. checkBody may
+	// return a *partialError to resend only some records of a batch.
+	contentType string
+	encode      func(batch []map[string]any) ([]byte, error)
+	checkBody   func(body []byte) error
+
+	name   string // reported to onDrop
+	block  bool
+	onDrop DropFunc
+
+	ch   chan task.Result
+	done chan struct{}
+}
+
+// NewHTTPSink creates an HTTPSink and starts its background batching
+// goroutine. Zero-valued fields in cfg fall back to built-in defaults.
+func NewHTTPSink(cfg config.SinkConfig) *HTTPSink {
+	s := newHTTPSink(cfg)
+	go s.run()
+	return s
+}
+
+// newHTTPSink builds an HTTPSink with defaults applied but does not start
+// the background goroutine, so callers can customise the encoding first.
+func newHTTPSink(cfg config.SinkConfig) *HTTPSink {
+	s := &HTTPSink{
+		url:           cfg.URL,
+		headers:       cfg.Headers,
+		batchSize:     cfg.BatchSize,
+		flushInterval: time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+		timeout:       time.Duration(cfg.TimeoutS) * time.Second,
+		maxRetries:    cfg.MaxRetries,
+		retryBase:     500 * time.Millisecond,
+		client:        &http.Client{},
+		contentType:   "application/json",
+		name:          "http",
+		encode:        func(batch []map[string]any) ([]byte, error) { return json.Marshal(batch) },
+		ch:            make(chan task.Result, chanBuf),
+		done:          make(chan struct{}),
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultSinkBatchSize
+	}
+	if s.flushInterval <= 0 {
+		s.flushInterval = defaultSinkFlushInterval
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultSinkTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		s.maxRetries = defaultSinkMaxRetries
+	}
+	return s
+}
+
+// Send enqueues a result. When the buffer is full it blocks or drops,
+// depending on on_full.
+func (s *HTTPSink) Send(r task.Result) {
+	enqueue(s.ch, r, s.block, s.name, s.onDrop)
+}
+
+// drop reports n records the collector never got to onDrop.
+func (s *HTTPSink) drop(n int) {
+	if s.onDrop == nil {
+		return
+	}
+	for range n {
+		s.onDrop(s.name)
+	}
+}
+
+// Close flushes any buffered records and stops the background goroutine.
+func (s *HTTPSink) Close() {
+	close(s.ch)
+	<-s.done
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]map[string]any, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.post(batch)
+		batch = make([]map[string]any, 0, s.batchSize)
+	}
+
+	for {
+		select {
+		case r, ok := <-s.ch:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, toJSONLRecord(r))
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// partialError reports a batch the collector took only in part: the records
+// at the retry indices failed in a way worth retrying, and rejected more
+// were refused for good.
+type partialError struct {
+	retry    []int
+	rejected int
+	total    int
+}
+
+func (e *partialError) Error() string {
+	return fmt.Sprintf("collector rejected %d and deferred %d of %d records", e.rejected, len(e.retry), e.total)
+}
+
+// post delivers one batch, retrying retryable failures up to maxRetries times.
+// When the collector defers only some records, only those are sent again.
+func (s *HTTPSink) post(batch []map[string]any) {
+	delay := s.retryBase
+	for attempt := 0; ; attempt++ {
+		body, err := s.encode(batch)
+		if err != nil {
+			log.Warn().Err(err).Msg("http sink: failed to encode batch")
+			s.drop(len(batch))
+			return
+		}
+		retryable, err := s.postOnce(body)
+		if err == nil {
+			return
+		}
+		var perr *partialError
+		if errors.As(err, &perr) {
+			if perr.rejected > 0 {
+				log.Warn().Err(err).Str("url", s.url).Int("records", perr.rejected).
+					Msg("http sink: dropping rejected records")
+				s.drop(perr.rejected)
+			}
+			deferred := make([]map[string]any, 0, len(perr.retry))
+			for _, i := range perr.retry {
+				if i < len(batch) {
+					deferred = append(deferred, batch[i])
+				}
+			}
+			if len(deferred) == 0 {
+				return
+			}
+			batch, retryable = deferred, true
+		}
+		if !retryable || attempt >= s.maxRetries {
+			log.Warn().Err(err).Str("url", s.url).Int("records", len(batch)).
+				Msg("http sink: dropping batch")
+			s.drop(len(batch))
+			return
+		}
+		log.Debug().Err(err).Str("url", s.url).Dur("retry_in", delay).
+			Msg("http sink: delivery failed, retrying")
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxSinkRetryDelay {
+			delay = maxSinkRetryDelay
+		}
+	}
+}
+
+// postOnce performs a single POST. The bool result reports whether a
+// failure is worth retrying.
+func (s *HTTPSink) postOnce(body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if s.checkBody == nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			return false, nil
+		}
+		// Read all of it: a truncated response would not decode.
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return true, fmt.Errorf("reading response: %w", err)
+		}
+		return false, s.checkBody(respBody)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("collector returned %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("collector returned %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,191 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+func TestHTTPSink_BatchesRecords(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]map[string]any
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", r.Header.Get("Content-Type"))
+		}
+		if r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("X-Api-Key = %q, want secret", r.Header.Get("X-Api-Key"))
+		}
+		var batch []map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Errorf("decoding batch: %v", err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(config.SinkConfig{
+		Type:      "http",
+		URL:       srv.URL,
+		BatchSize: 2,
+		Headers:   map[string]string{"X-Api-Key": "secret"},
+	})
+	for range 5 {
+		s.Send(makeResult("https://example.com", "http", 200, 10*time.Millisecond, 64, nil))
+	}
+	s.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 3 {
+		t.Fatalf("got %d batches, want 3 (2+2+1)", len(batches))
+	}
+	total := 0
+	for _, b := range batches {
+		total += len(b)
+	}
+	if total != 5 {
+		t.Errorf("got %d records, want 5", total)
+	}
+	if batches[0][0]["url"] != "https://example.com" {
+		t.Errorf("url = %v, want https://example.com", batches[0][0]["url"])
+	}
+}
+
+func TestHTTPSink_FlushesOnInterval(t *testing.T) {
+	got := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+		got <- len(batch)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(config.SinkConfig{URL: srv.URL, BatchSize: 100, FlushIntervalMs: 20})
+	defer s.Close()
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil))
+
+	select {
+	case n := <-got:
+		if n != 1 {
+			t.Errorf("batch size = %d, want 1", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("partial batch was not flushed on interval")
+	}
+}
+
+func TestHTTPSink_RetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(config.SinkConfig{URL: srv.URL, MaxRetries: 3})
+	s.retryBase = time.Millisecond
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil))
+	s.Close()
+
+	if got := calls.Load(); got != 3 {
+		t.Errorf("collector called %d times, want 3 (two 503s then success)", got)
+	}
+}
+
+func TestHTTPSink_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	s := NewHTTPSink(config.SinkConfig{URL: srv.URL, MaxRetries: 3})
+	s.retryBase = time.Millisecond
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil))
+	s.Close()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("collector called %d times, want 1 (400 is not retried)", got)
+	}
+}
+
+func TestHTTPSink_ReportsDroppedBatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var dropped []string
+	sinks, err := NewSinks(config.OutputConfig{
+		OnFull: "block",
+		Sinks:  []config.SinkConfig{{Type: "http", URL: srv.URL, MaxRetries: 1}},
+	}, func(sink string) {
+		mu.Lock()
+		dropped = append(dropped, sink)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("NewSinks: %v", err)
+	}
+	s := sinks[0].(*HTTPSink)
+	s.retryBase = time.Millisecond
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil))
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil))
+	s.Close()
+
+	if len(dropped) != 2 || dropped[0] != "http" || dropped[1] != "http" {
+		t.Errorf("dropped = %v, want both records of the given-up batch reported as http", dropped)
+	}
+}
+
+func TestHTTPSink_DropsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+
+	var drops atomic.Int32
+	sinks, err := NewSinks(config.OutputConfig{
+		OnFull: "drop",
+		Sinks:  []config.SinkConfig{{Type: "http", URL: srv.URL, BatchSize: 1}},
+	}, func(string) { drops.Add(1) })
+	if err != nil {
+		t.Fatalf("NewSinks: %v", err)
+	}
+	defer sinks[0].Close()
+	defer close(release)
+	// With the collector stalled, the buffer fills and Send drops rather
+	// than waiting.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range chanBuf + 10 {
+			sinks[0].Send(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send blocked with on_full: drop")
+	}
+	if drops.Load() == 0 {
+		t.Error("no records reported dropped")
+	}
+}
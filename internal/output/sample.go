@@ -0,0 +1,42 @@
+package output
+
+import (
+	"math/rand"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+)
+
+// Sampler decides which results are written to output. Successful and
+// failed results are sampled independently so that high-RPS runs can keep
+// every error while thinning out the successes.
+type Sampler struct {
+	rate      float64
+	errorRate float64
+}
+
+// NewSampler creates a Sampler from cfg. Rates <= 0 are treated as 1 (keep
+// everything), matching the defaults applied by config.Load.
+func NewSampler(cfg config.OutputConfig) *Sampler {
+	s := &Sampler{rate: cfg.SampleRate, errorRate: cfg.SampleErrors}
+	if s.rate <= 0 {
+		s.rate = 1
+	}
+	if s.errorRate <= 0 {
+		s.errorRate = 1
+	}
+	return s
+}
+
+// Keep reports whether r should be passed to the output destinations.
+// A result is treated as failed if it has an error or a status >= 400.
+func (s *Sampler) Keep(r task.Result) bool {
+	rate := s.rate
+	if r.Error != nil || r.StatusCode >= 400 {
+		rate = s.errorRate
+	}
+	if rate >= 1 {
+		return true
+	}
+	return rand.Float64() < rate //nolint:gosec
+}
@@ -0,0 +1,43 @@
+package output
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+func TestSampler_DefaultsKeepEverything(t *testing.T) {
+	s := NewSampler(config.OutputConfig{})
+	for range 100 {
+		if !s.Keep(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil)) {
+			t.Fatal("zero-valued sampler must keep every result")
+		}
+	}
+}
+
+func TestSampler_KeepsAllErrorsWhileSamplingSuccesses(t *testing.T) {
+	s := NewSampler(config.OutputConfig{SampleRate: 0.1, SampleErrors: 1.0})
+
+	const n = 10000
+	kept := 0
+	for range n {
+		if s.Keep(makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil)) {
+			kept++
+		}
+	}
+	// Expect ~1000; allow generous slack to avoid flakiness.
+	if kept < 700 || kept > 1300 {
+		t.Errorf("kept %d of %d successes at rate 0.1, want ~1000", kept, n)
+	}
+
+	for range 100 {
+		if !s.Keep(makeResult("https://example.com", "http", 0, time.Millisecond, 0, errors.New("boom"))) {
+			t.Fatal("error result dropped with sample_errors 1.0")
+		}
+		if !s.Keep(makeResult("https://example.com", "http", 503, time.Millisecond, 0, nil)) {
+			t.Fatal("503 result dropped with sample_errors 1.0")
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package output
+
+import (
+	"fmt"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog/log"
+)
+
+// Sink is a destination for completed task results. Send is called from
+// many dispatch goroutines concurrently; Close is called once after all
+// in-flight tasks have finished and must flush any buffered records.
+type Sink interface {
+	Send(r task.Result)
+	Close()
+}
+
+// DropFunc is called with the sink name each time a record is discarded,
+// because that sink's buffer was full or, for the HTTP-based sinks, because
+// the collector did not take it.
+type DropFunc func(sink string)
+
+// NewSinks builds a Sink for every entry in cfg.Sinks; cfg.OnFull selects the
+// full-buffer behaviour of sinks that support dropping. If any sink fails to
+// initialise, the ones already created are closed before returning.
+func NewSinks(cfg config.OutputConfig, onDrop DropFunc) ([]Sink, error) {
+	sinks := make([]Sink, 0, len(cfg.Sinks))
+	for i, c := range cfg.Sinks {
+		s, err := newSink(c, cfg.OnFull == "block", onDrop)
+		if err != nil {
+			for _, prev := range sinks {
+				prev.Close()
+			}
+			return nil, fmt.Errorf("sinks[%d]: %w", i, err)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+func newSink(c config.SinkConfig, block bool, onDrop DropFunc) (Sink, error) {
+	switch c.Type {
+	case "http":
+		s := NewHTTPSink(c)
+		s.block = block
+		s.onDrop = onDrop
+		return s, nil
+	case "elasticsearch":
+		s, err := NewElasticsearchSink(c)
+		if err != nil {
+			return nil, err
+		}
+		s.block = block
+		s.onDrop = onDrop
+		return s, nil
+	case "syslog":
+		s, err := NewSyslogSink(c)
+		if err != nil {
+			return nil, err
+		}
+		s.block = block
+		s.onDrop = onDrop
+		return s, nil
+	case "stdout":
+		s := NewStdoutSink()
+		s.block = block
+		s.onDrop = onDrop
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", c.Type)
+	}
+}
+
+// enqueue sends r on ch. When block is false and ch is full the record is
+// dropped, logged, and reported to onDrop under name.
+func enqueue(ch chan<- task.Result, r task.Result, block bool, name string, onDrop DropFunc) {
+	if block {
+		ch <- r
+		return
+	}
+	select {
+	case ch <- r:
+	default:
+		log.Warn().Str("sink", name).Msg("output buffer full, dropping result")
+		if onDrop != nil {
+			onDrop(name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package output
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+)
+
+func TestNewSinks_UnknownType(t *testing.T) {
+	if _, err := NewSinks(config.OutputConfig{Sinks: []config.SinkConfig{{Type: "kafka"}}}, nil); err == nil {
+		t.Fatal("expected error for unknown sink type")
+	}
+}
+
+func TestEnqueue_DropReportsSinkName(t *testing.T) {
+	ch := make(chan task.Result, 1)
+	var dropped []string
+	onDrop := func(sink string) { dropped = append(dropped, sink) }
+
+	r := makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil)
+	enqueue(ch, r, false, "file", onDrop)
+	enqueue(ch, r, false, "file", onDrop)
+	enqueue(ch, r, false, "file", onDrop)
+
+	if len(ch) != 1 {
+		t.Errorf("channel len = %d, want 1", len(ch))
+	}
+	if len(dropped) != 2 || dropped[0] != "file" {
+		t.Errorf("dropped = %v, want two drops reported as file", dropped)
+	}
+}
+
+func TestEnqueue_BlockWaitsForRoom(t *testing.T) {
+	ch := make(chan task.Result, 1)
+	r := makeResult("https://example.com", "http", 200, time.Millisecond, 0, nil)
+	enqueue(ch, r, true, "file", nil)
+
+	sent := make(chan struct{})
+	go func() {
+		enqueue(ch, r, true, "file", func(string) { t.Error("blocking enqueue must not drop") })
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("blocking enqueue returned while the buffer was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-ch
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("blocking enqueue did not proceed after room was made")
+	}
+}
@@ -0,0 +1,71 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog/log"
+)
+
+// StdoutSink writes one JSON record per line (the JSONL output shape) to
+// standard output. Application logs go to stderr, so stdout carries only
+// result events and can be consumed directly by a container log pipeline.
+//
+// Like Writer, Send drops records when the buffer is full unless the output
+// is configured with on_full: block.
+type StdoutSink struct {
+	out io.Writer
+
+	block  bool
+	onDrop DropFunc
+
+	ch   chan task.Result
+	done chan struct{}
+}
+
+// NewStdoutSink starts the background writer goroutine for os.Stdout.
+func NewStdoutSink() *StdoutSink {
+	return newStdoutSink(os.Stdout)
+}
+
+func newStdoutSink(out io.Writer) *StdoutSink {
+	s := &StdoutSink{
+		out:  out,
+		ch:   make(chan task.Result, chanBuf),
+		done: make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Send enqueues a result for writing.
+func (s *StdoutSink) Send(r task.Result) {
+	enqueue(s.ch, r, s.block, "stdout", s.onDrop)
+}
+
+// Close drains the buffer and flushes any pending output.
+func (s *StdoutSink) Close() {
+	close(s.ch)
+	<-s.done
+}
+
+func (s *StdoutSink) run() {
+	defer close(s.done)
+	bw := bufio.NewWriter(s.out)
+	enc := json.NewEncoder(bw)
+	for r := range s.ch {
+		if err := enc.Encode(toJSONLRecord(r)); err != nil {
+			log.Warn().Err(err).Msg("stdout sink: failed to encode result")
+			continue
+		}
+		// Flush per record only when nothing else is queued, so bursts are
+		// written in one syscall without delaying idle output.
+		if len(s.ch) == 0 {
+			_ = bw.Flush()
+		}
+	}
+	_ = bw.Flush()
+}
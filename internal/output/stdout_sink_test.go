@@ -0,0 +1,30 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdoutSink_WritesJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	s := newStdoutSink(&buf)
+	s.Send(makeResult("https://example.com", "http", 200, 5*time.Millisecond, 10, nil))
+	s.Send(makeResult("example.com", "dns", 0, time.Millisecond, 0, errors.New("NXDOMAIN")))
+	s.Close()
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if rec["type"] != "dns" || rec["error"] != "NXDOMAIN" {
+		t.Errorf("record = %v", rec)
+	}
+}
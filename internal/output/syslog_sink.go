@@ -0,0 +1,194 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+	"github.com/rs/zerolog/log"
+)
+
+// syslogFacilities maps facility names to RFC 5424 facility codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+const (
+	syslogSeverityWarning = 4
+	syslogSeverityInfo    = 6
+)
+
+// localSyslogSockets are tried in order when a syslog sink has no URL.
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogSink emits one RFC 5424 message per result record. The MSG part is
+// the JSON record (same shape as a JSONL output line). Results with an
+// error or a status >= 400 are logged at severity warning, others at info.
+//
+// Like Writer, Send drops records when the buffer is full unless the output
+// is configured with on_full: block. Over TCP, messages use octet-counting framing (RFC 6587).
+type SyslogSink struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+
+	block  bool
+	onDrop DropFunc
+
+	conn net.Conn
+	ch   chan task.Result
+	done chan struct{}
+}
+
+// NewSyslogSink connects to the syslog endpoint described by cfg.URL and
+// starts the background writer goroutine.
+func NewSyslogSink(cfg config.SinkConfig) (*SyslogSink, error) {
+	network, address, err := syslogEndpoint(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		facility = syslogFacilities["local0"]
+	}
+	appName := cfg.AppName
+	if appName == "" {
+		appName = "sendit"
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &SyslogSink{
+		network:  network,
+		address:  address,
+		facility: facility,
+		appName:  appName,
+		hostname: hostname,
+		ch:       make(chan task.Result, chanBuf),
+		done:     make(chan struct{}),
+	}
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
+
+// syslogEndpoint resolves a sink URL to a net.Dial network and address.
+// An empty URL selects the first local syslog socket that exists.
+func syslogEndpoint(rawURL string) (string, string, error) {
+	if rawURL == "" {
+		for _, p := range localSyslogSockets {
+			if _, err := os.Stat(p); err == nil {
+				return "unixgram", p, nil
+			}
+		}
+		return "", "", fmt.Errorf("no local syslog socket found (tried %v)", localSyslogSockets)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("parsing syslog url: %w", err)
+	}
+	switch u.Scheme {
+	case "udp", "tcp":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "514")
+		}
+		return u.Scheme, host, nil
+	case "unix":
+		return "unixgram", u.Path, nil
+	default:
+		return "", "", fmt.Errorf("unsupported syslog scheme %q", u.Scheme)
+	}
+}
+
+func (s *SyslogSink) dial() error {
+	conn, err := net.DialTimeout(s.network, s.address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("connecting to syslog %s %s: %w", s.network, s.address, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// Send enqueues a result for writing.
+func (s *SyslogSink) Send(r task.Result) {
+	enqueue(s.ch, r, s.block, "syslog", s.onDrop)
+}
+
+// Close drains the buffer and closes the connection.
+func (s *SyslogSink) Close() {
+	close(s.ch)
+	<-s.done
+}
+
+func (s *SyslogSink) run() {
+	defer close(s.done)
+	defer func() {
+		if s.conn != nil {
+			_ = s.conn.Close()
+		}
+	}()
+
+	for r := range s.ch {
+		msg, err := s.format(r, time.Now())
+		if err != nil {
+			log.Warn().Err(err).Msg("syslog sink: failed to encode result")
+			continue
+		}
+		if err := s.write(msg); err != nil {
+			log.Warn().Err(err).Msg("syslog sink: failed to write result")
+		}
+	}
+}
+
+// write sends msg, reconnecting once if the connection has gone away.
+func (s *SyslogSink) write(msg []byte) error {
+	if s.network == "tcp" {
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+	if s.conn != nil {
+		if _, err := s.conn.Write(msg); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.dial(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// format renders r as an RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG
+func (s *SyslogSink) format(r task.Result, now time.Time) ([]byte, error) {
+	body, err := json.Marshal(toJSONLRecord(r))
+	if err != nil {
+		return nil, err
+	}
+	severity := syslogSeverityInfo
+	if r.Error != nil || r.StatusCode >= 400 {
+		severity = syslogSeverityWarning
+	}
+	pri := s.facility*8 + severity
+	header := fmt.Sprintf("<%d>1 %s %s %s %d result - ",
+		pri, now.UTC().Format(time.RFC3339Nano), s.hostname, s.appName, os.Getpid())
+	return append([]byte(header), body...), nil
+}
@@ -0,0 +1,139 @@
+package output
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+func TestSyslogSink_UDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	defer pc.Close()
+
+	s, err := NewSyslogSink(config.SinkConfig{
+		Type:     "syslog",
+		URL:      "udp://" + pc.LocalAddr().String(),
+		Facility: "local3",
+		AppName:  "probe",
+	})
+	if err != nil {
+		t.Fatalf("NewSyslogSink: %v", err)
+	}
+	s.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 5, nil))
+	s.Close()
+
+	_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	msg := string(buf[:n])
+
+	// local3 (19) * 8 + info (6) = 158
+	if !strings.HasPrefix(msg, "<158>1 ") {
+		t.Errorf("message = %q, want <158>1 prefix", msg)
+	}
+	fields := strings.SplitN(msg, " ", 8)
+	if len(fields) != 8 {
+		t.Fatalf("message has %d header fields, want 8: %q", len(fields), msg)
+	}
+	if fields[3] != "probe" {
+		t.Errorf("APP-NAME = %q, want probe", fields[3])
+	}
+	if fields[5] != "result" {
+		t.Errorf("MSGID = %q, want result", fields[5])
+	}
+	if !strings.Contains(fields[7], `"url":"https://example.com"`) {
+		t.Errorf("MSG = %q, want JSON record", fields[7])
+	}
+}
+
+func TestSyslogSink_TCPOctetCounting(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		lenStr, err := br.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(br, msg); err != nil {
+			return
+		}
+		got <- string(msg)
+	}()
+
+	s, err := NewSyslogSink(config.SinkConfig{URL: "tcp://" + ln.Addr().String()})
+	if err != nil {
+		t.Fatalf("NewSyslogSink: %v", err)
+	}
+	s.Send(makeResult("https://example.com", "http", 0, time.Millisecond, 0, errors.New("timeout")))
+	s.Close()
+
+	select {
+	case msg := <-got:
+		// local0 (16) * 8 + warning (4) = 132
+		if !strings.HasPrefix(msg, "<132>1 ") {
+			t.Errorf("message = %q, want <132>1 prefix", msg)
+		}
+		if !strings.Contains(msg, `"error":"timeout"`) {
+			t.Errorf("message = %q, want error field", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no syslog message received over TCP")
+	}
+}
+
+func TestSyslogEndpoint(t *testing.T) {
+	tests := []struct {
+		url, network, address string
+	}{
+		{"udp://syslog.example.com", "udp", "syslog.example.com:514"},
+		{"tcp://10.0.0.1:6514", "tcp", "10.0.0.1:6514"},
+		{"unix:///dev/log", "unixgram", "/dev/log"},
+	}
+	for _, tt := range tests {
+		network, address, err := syslogEndpoint(tt.url)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.url, err)
+			continue
+		}
+		if network != tt.network || address != tt.address {
+			t.Errorf("%s: got %s %s, want %s %s", tt.url, network, address, tt.network, tt.address)
+		}
+	}
+	if _, _, err := syslogEndpoint("http://example.com"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestSyslogSink_UnixSocketUnreachable(t *testing.T) {
+	_, err := NewSyslogSink(config.SinkConfig{URL: "unix://" + filepath.Join(t.TempDir(), "missing.sock")})
+	if err == nil {
+		t.Fatal("expected error for a missing unix socket")
+	}
+}
@@ -0,0 +1,258 @@
+package output
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lewta/sendit/internal/awssig"
+	"github.com/lewta/sendit/internal/config"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	uploadTimeout   = 5 * time.Minute
+	uploadQueueSize = 64
+	// uploadShutdownTimeout bounds how long Close waits for the queue to
+	// drain, so that shutdown is not held up by a slow bucket.
+	uploadShutdownTimeout = time.Minute
+
+	gcsUploadBase    = "https://storage.googleapis.com/upload/storage/v1/b/"
+	gcsMetadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// Uploader ships completed output segments to object storage one at a time.
+// Failed uploads are logged and the local file is kept.
+type Uploader struct {
+	provider    string
+	bucket      string
+	prefix      string
+	region      string
+	endpoint    string
+	deleteLocal bool
+	creds       awssig.Credentials
+	client      *http.Client
+
+	shutdownTimeout time.Duration
+	ctx             context.Context // cancelled when shutdownTimeout passes
+	cancel          context.CancelFunc
+
+	ch   chan string
+	done chan struct{}
+}
+
+// NewUploader validates cfg and starts the background upload goroutine.
+// The caller must call Close when done.
+func NewUploader(cfg config.UploadConfig) (*Uploader, error) {
+	u := &Uploader{
+		provider:    cfg.Provider,
+		bucket:      cfg.Bucket,
+		prefix:      cfg.Prefix,
+		region:      cfg.Region,
+		endpoint:    strings.TrimSuffix(cfg.Endpoint, "/"),
+		deleteLocal: cfg.DeleteLocal,
+		client:      &http.Client{Timeout: uploadTimeout},
+
+		shutdownTimeout: uploadShutdownTimeout,
+
+		ch:   make(chan string, uploadQueueSize),
+		done: make(chan struct{}),
+	}
+	u.ctx, u.cancel = context.WithCancel(context.Background())
+	switch u.provider {
+	case "s3":
+		if u.region == "" {
+			u.region = awssig.RegionFromEnv()
+		}
+		creds, err := awssig.CredentialsFromEnv()
+		if err != nil {
+			return nil, fmt.Errorf("s3 upload: %w", err)
+		}
+		u.creds = creds
+	case "gcs":
+	default:
+		return nil, fmt.Errorf("unknown upload provider %q", u.provider)
+	}
+	go u.run()
+	return u, nil
+}
+
+// Enqueue schedules the file at path for upload. When uploadQueueSize
+// segments are already waiting it does not block the output writer's
+// rotation: the segment is left on disk, with a warning, and not uploaded.
+func (u *Uploader) Enqueue(path string) {
+	select {
+	case u.ch <- path:
+	default:
+		log.Warn().Str("file", path).Str("bucket", u.bucket).Int("queued", cap(u.ch)).
+			Msg("upload: queue full, keeping local file")
+	}
+}
+
+// Close waits for the queued uploads to finish, for up to shutdownTimeout
+// in all. The upload in progress then is abandoned, and it and the
+// segments still queued are left on disk.
+func (u *Uploader) Close() {
+	defer u.cancel()
+	close(u.ch)
+	timer := time.NewTimer(u.shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-u.done:
+		return
+	case <-timer.C:
+	}
+	u.cancel()
+	<-u.done
+}
+
+func (u *Uploader) run() {
+	defer close(u.done)
+	skipped := 0
+	for p := range u.ch {
+		if u.ctx.Err() != nil {
+			skipped++
+			continue
+		}
+		key := path.Join(u.prefix, filepath.Base(p))
+		ctx, cancel := context.WithTimeout(u.ctx, uploadTimeout)
+		err := u.upload(ctx, p, key)
+		cancel()
+		if err != nil {
+			log.Error().Err(err).Str("file", p).Str("bucket", u.bucket).Str("key", key).
+				Msg("upload: failed, keeping local file")
+			continue
+		}
+		log.Info().Str("file", p).Str("bucket", u.bucket).Str("key", key).Msg("upload: done")
+		if u.deleteLocal {
+			if err := os.Remove(p); err != nil {
+				log.Warn().Err(err).Str("file", p).Msg("upload: removing local file failed")
+			}
+		}
+	}
+	if skipped > 0 {
+		log.Warn().Int("segments", skipped).Str("bucket", u.bucket).Dur("timeout", u.shutdownTimeout).
+			Msg("upload: shutdown timeout passed, keeping local files")
+	}
+}
+
+// upload streams the file at p to key. For S3 the file is read twice, once
+// for the SigV4 payload hash and once as the request body, so a segment is
+// never held in memory.
+func (u *Uploader) upload(ctx context.Context, p, key string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var req *http.Request
+	switch u.provider {
+	case "s3":
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		req, err = u.s3Request(ctx, key, f, fi.Size(), hex.EncodeToString(h.Sum(nil)), time.Now().UTC())
+	case "gcs":
+		req, err = u.gcsRequest(ctx, key, f, fi.Size())
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// s3Request builds a SigV4-signed PutObject request. With a custom endpoint
+// the bucket goes in the path (path-style); otherwise virtual-hosted style
+// is used.
+func (u *Uploader) s3Request(ctx context.Context, key string, body io.Reader, size int64, payloadHash string, now time.Time) (*http.Request, error) {
+	var target string
+	if u.endpoint != "" {
+		target = u.endpoint + "/" + awssig.URIEscape(u.bucket) + "/" + awssig.URIEscape(key)
+	} else {
+		target = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", u.bucket, u.region, awssig.URIEscape(key))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	awssig.Sign(req, payloadHash, u.region, "s3", u.creds, now)
+	return req, nil
+}
+
+func (u *Uploader) gcsRequest(ctx context.Context, key string, body io.Reader, size int64) (*http.Request, error) {
+	token, err := u.gcsToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("obtaining GCS access token: %w", err)
+	}
+	target := gcsUploadBase + url.PathEscape(u.bucket) + "/o?uploadType=media&name=" + url.QueryEscape(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// gcsToken returns GOOGLE_OAUTH_ACCESS_TOKEN when set, otherwise asks the
+// GCE metadata server for the default service account's token.
+func (u *Uploader) gcsToken(ctx context.Context) (string, error) {
+	if tok := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); tok != "" {
+		return tok, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataToken, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("set GOOGLE_OAUTH_ACCESS_TOKEN or run on GCE: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned HTTP %d", resp.StatusCode)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	if tok.AccessToken == "" {
+		return "", errors.New("metadata server returned an empty token")
+	}
+	return tok.AccessToken, nil
+}
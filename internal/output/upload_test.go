@@ -0,0 +1,205 @@
+package output
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+func TestSegmentPath(t *testing.T) {
+	ts := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	got := segmentPath("/tmp/results.jsonl", ts)
+	if got != "/tmp/results.20261014T120000.000000000Z.jsonl" {
+		t.Errorf("segmentPath = %q", got)
+	}
+}
+
+type capturedUpload struct {
+	path string
+	auth string
+	body string
+}
+
+func newS3Server(t *testing.T) (*httptest.Server, func() []capturedUpload) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []capturedUpload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, capturedUpload{path: r.URL.Path, auth: r.Header.Get("Authorization"), body: string(b)})
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []capturedUpload {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]capturedUpload(nil), got...)
+	}
+}
+
+func TestUploader_S3PathStyle(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	srv, uploads := newS3Server(t)
+
+	dir := t.TempDir()
+	p := filepath.Join(dir, "seg.jsonl")
+	if err := os.WriteFile(p, []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	u, err := NewUploader(config.UploadConfig{
+		Provider: "s3", Bucket: "bkt", Prefix: "runs", Region: "eu-west-1",
+		Endpoint: srv.URL, DeleteLocal: true,
+	})
+	if err != nil {
+		t.Fatalf("NewUploader: %v", err)
+	}
+	u.Enqueue(p)
+	u.Close()
+
+	got := uploads()
+	if len(got) != 1 {
+		t.Fatalf("uploads = %d, want 1", len(got))
+	}
+	if got[0].path != "/bkt/runs/seg.jsonl" {
+		t.Errorf("path = %q", got[0].path)
+	}
+	if !strings.HasPrefix(got[0].auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+		!strings.Contains(got[0].auth, "/eu-west-1/s3/aws4_request") {
+		t.Errorf("Authorization = %q", got[0].auth)
+	}
+	if got[0].body != "{}\n" {
+		t.Errorf("body = %q", got[0].body)
+	}
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("local file should be deleted after upload, stat err = %v", err)
+	}
+}
+
+func TestUploader_FailureKeepsLocalFile(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	p := filepath.Join(t.TempDir(), "seg.jsonl")
+	_ = os.WriteFile(p, []byte("x"), 0o600)
+	u, err := NewUploader(config.UploadConfig{Provider: "s3", Bucket: "b", Endpoint: srv.URL, DeleteLocal: true})
+	if err != nil {
+		t.Fatalf("NewUploader: %v", err)
+	}
+	u.Enqueue(p)
+	u.Close()
+
+	if _, err := os.Stat(p); err != nil {
+		t.Errorf("local file should be kept after failed upload: %v", err)
+	}
+}
+
+func TestUploader_CloseGivesUpAfterShutdownTimeout(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // a bucket that does not answer until the test ends
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	dir := t.TempDir()
+	var segments []string
+	for _, name := range []string{"a.jsonl", "b.jsonl"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("{}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		segments = append(segments, p)
+	}
+	u, err := NewUploader(config.UploadConfig{Provider: "s3", Bucket: "b", Endpoint: srv.URL, DeleteLocal: true})
+	if err != nil {
+		t.Fatalf("NewUploader: %v", err)
+	}
+	u.shutdownTimeout = 50 * time.Millisecond
+	for _, p := range segments {
+		u.Enqueue(p)
+	}
+
+	start := time.Now()
+	u.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Close took %v, want it bounded by the shutdown timeout", elapsed)
+	}
+	for _, p := range segments {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("segment %s should be kept after the shutdown timeout: %v", filepath.Base(p), err)
+		}
+	}
+}
+
+func TestUploader_EnqueueDoesNotBlockWhenFull(t *testing.T) {
+	u := &Uploader{ch: make(chan string, 1)}
+	u.Enqueue("a")
+	done := make(chan struct{})
+	go func() {
+		u.Enqueue("b")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue blocked on a full queue")
+	}
+	if len(u.ch) != 1 || <-u.ch != "a" {
+		t.Error("the queued segment should be kept and the extra one skipped")
+	}
+}
+
+func TestNewUploader_S3RequiresCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := NewUploader(config.UploadConfig{Provider: "s3", Bucket: "b"}); err == nil {
+		t.Error("expected error without AWS credentials")
+	}
+}
+
+func TestWriter_RotatesAndUploads(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	srv, uploads := newS3Server(t)
+
+	f := filepath.Join(t.TempDir(), "out.csv")
+	w, err := New(config.OutputConfig{
+		File: f, Format: "csv",
+		Upload: config.UploadConfig{Provider: "s3", Bucket: "b", Endpoint: srv.URL, IntervalS: 3600, DeleteLocal: true},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	w.Send(makeResult("https://example.com", "http", 200, time.Millisecond, 1, nil))
+	w.Close()
+
+	got := uploads()
+	if len(got) != 1 {
+		t.Fatalf("uploads = %d, want 1", len(got))
+	}
+	if !strings.HasPrefix(got[0].path, "/b/out.") || !strings.HasSuffix(got[0].path, ".csv") {
+		t.Errorf("path = %q", got[0].path)
+	}
+	if !strings.HasPrefix(got[0].body, "ts,url,type") || !strings.Contains(got[0].body, "https://example.com") {
+		t.Errorf("body = %q", got[0].body)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(f)); len(entries) != 0 {
+		t.Errorf("expected no local files after upload, got %d", len(entries))
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/lewta/sendit/internal/config"
@@ -15,17 +17,34 @@ import (
 
 const chanBuf = 512
 
-// Writer serialises task.Result values to a file in JSONL or CSV format.
-// Send is non-blocking; results are dropped (with a warning) if the internal
-// buffer is full. Close drains the buffer and flushes the file.
+// Writer serialises task.Result values to a file in JSONL, CSV, or NCSA
+// combined log format.
+// By default Send is non-blocking; results are dropped (with a warning) if the
+// internal buffer is full. With on_full: block, Send waits for room instead.
+// Close drains the buffer and flushes the file.
+//
+// When output.upload is configured, the file is rotated every interval and
+// each completed segment is handed to an Uploader.
 type Writer struct {
-	ch   chan task.Result
-	done chan struct{}
+	ch     chan task.Result
+	done   chan struct{}
+	block  bool
+	onDrop DropFunc
+
+	path        string
+	rotateEvery time.Duration
+	uploader    *Uploader
 }
 
 // New opens the output file and starts the background writer goroutine.
 // The caller must call Close() when done.
 func New(cfg config.OutputConfig) (*Writer, error) {
+	return NewWithDropFunc(cfg, nil)
+}
+
+// NewWithDropFunc is like New but reports every dropped result to onDrop
+// under the sink name "file".
+func NewWithDropFunc(cfg config.OutputConfig, onDrop DropFunc) (*Writer, error) {
 	flag := os.O_CREATE | os.O_WRONLY
 	if cfg.Append {
 		flag |= os.O_APPEND
@@ -39,42 +58,177 @@ func New(cfg config.OutputConfig) (*Writer, error) {
 	}
 
 	w := &Writer{
-		ch:   make(chan task.Result, chanBuf),
-		done: make(chan struct{}),
+		ch:     make(chan task.Result, chanBuf),
+		done:   make(chan struct{}),
+		block:  cfg.OnFull == "block",
+		onDrop: onDrop,
+		path:   cfg.File,
 	}
+
+	if cfg.Upload.Provider != "" {
+		u, err := NewUploader(cfg.Upload)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("creating uploader: %w", err)
+		}
+		w.uploader = u
+		w.rotateEvery = time.Duration(cfg.Upload.IntervalS) * time.Second
+	}
+
 	go w.run(f, cfg.Format, cfg.Append)
 	return w, nil
 }
 
-// Send enqueues a result for writing. Non-blocking; drops if buffer is full.
+// Send enqueues a result for writing, dropping or blocking when the buffer
+// is full according to on_full.
 func (w *Writer) Send(r task.Result) {
-	select {
-	case w.ch <- r:
-	default:
-		log.Warn().Msg("output writer buffer full, dropping result")
-	}
+	enqueue(w.ch, r, w.block, "file", w.onDrop)
 }
 
-// Close drains the channel and closes the file.
+// Close drains the channel and closes the file. If uploads are configured,
+// the final segment is rotated and uploaded before Close returns.
 func (w *Writer) Close() {
 	close(w.ch)
 	<-w.done
 }
 
+// recordEncoder writes result records to a buffered file in one format.
+type recordEncoder interface {
+	// header is written at the start of every fresh (non-appended) file.
+	header() error
+	encode(r task.Result) error
+}
+
+func newRecordEncoder(format string, bw *bufio.Writer) recordEncoder {
+	switch format {
+	case "csv":
+		return &csvEncoder{cw: csv.NewWriter(bw)}
+	case "clf":
+		return &clfEncoder{bw: bw}
+	default: // jsonl
+		return &jsonlEncoder{enc: json.NewEncoder(bw)}
+	}
+}
+
 func (w *Writer) run(f *os.File, format string, appendMode bool) {
 	defer close(w.done)
 	bw := bufio.NewWriter(f)
-	defer func() {
+	enc := newRecordEncoder(format, bw)
+
+	if !appendMode {
+		_ = enc.header()
 		_ = bw.Flush()
-		_ = f.Close()
-	}()
+	}
 
-	switch format {
-	case "csv":
-		w.runCSV(bw, appendMode)
-	default: // jsonl
-		w.runJSONL(bw)
+	var tick <-chan time.Time
+	if w.rotateEvery > 0 {
+		ticker := time.NewTicker(w.rotateEvery)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case r, ok := <-w.ch:
+			if !ok {
+				_ = bw.Flush()
+				_ = f.Close()
+				if w.uploader != nil {
+					w.uploadSegment()
+					w.uploader.Close()
+				}
+				return
+			}
+			if err := enc.encode(r); err != nil {
+				log.Warn().Err(err).Str("format", format).Msg("output writer: failed to encode result")
+				continue
+			}
+			_ = bw.Flush()
+		case <-tick:
+			_ = bw.Flush()
+			_ = f.Close()
+			w.uploadSegment()
+			nf, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				// Nothing more can be written; keep draining so Send never
+				// blocks forever, and report each lost record.
+				log.Error().Err(err).Str("file", w.path).Msg("output writer: reopening after rotation failed")
+				for range w.ch {
+					if w.onDrop != nil {
+						w.onDrop("file")
+					}
+				}
+				w.uploader.Close()
+				return
+			}
+			f = nf
+			bw.Reset(f)
+			_ = enc.header()
+			_ = bw.Flush()
+		}
+	}
+}
+
+// uploadSegment renames the (closed) current file to a timestamped segment
+// name and queues it for upload. Empty segments are removed instead.
+func (w *Writer) uploadSegment() {
+	if fi, err := os.Stat(w.path); err != nil || fi.Size() == 0 {
+		_ = os.Remove(w.path)
+		return
+	}
+	segment := segmentPath(w.path, time.Now().UTC())
+	if err := os.Rename(w.path, segment); err != nil {
+		log.Error().Err(err).Str("file", w.path).Msg("output writer: rotating file failed")
+		return
 	}
+	w.uploader.Enqueue(segment)
+}
+
+// segmentPath inserts a UTC timestamp before the extension of path, e.g.
+// results.jsonl → results.20261014T120000.000000000Z.jsonl.
+func segmentPath(path string, ts time.Time) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + ts.Format("20060102T150405.000000000Z") + ext
+}
+
+type jsonlEncoder struct{ enc *json.Encoder }
+
+func (e *jsonlEncoder) header() error { return nil }
+
+func (e *jsonlEncoder) encode(r task.Result) error { return e.enc.Encode(toJSONLRecord(r)) }
+
+type csvEncoder struct{ cw *csv.Writer }
+
+func (e *csvEncoder) header() error {
+	if err := e.cw.Write([]string{"ts", "url", "type", "status", "duration_ms", "bytes", "error", "run_id", "request_id", "slow"}); err != nil {
+		return err
+	}
+	e.cw.Flush()
+	return e.cw.Error()
+}
+
+func (e *csvEncoder) encode(r task.Result) error {
+	rec := toRecord(r)
+	row := []string{
+		rec.TS,
+		rec.URL,
+		rec.Type,
+		fmt.Sprintf("%d", rec.Status),
+		fmt.Sprintf("%d", rec.DurationMs),
+		fmt.Sprintf("%d", rec.Bytes),
+		rec.Error,
+		r.RunID,
+		r.Meta["request_id"],
+		"",
+	}
+	if r.Slow() {
+		row[len(row)-1] = "true"
+	}
+	if err := e.cw.Write(row); err != nil {
+		return err
+	}
+	e.cw.Flush()
+	return e.cw.Error()
 }
 
 type record struct {
@@ -103,17 +257,6 @@ func toRecord(r task.Result) record {
 	}
 }
 
-func (w *Writer) runJSONL(bw *bufio.Writer) {
-	enc := json.NewEncoder(bw)
-	for r := range w.ch {
-		if err := enc.Encode(toJSONLRecord(r)); err != nil {
-			log.Warn().Err(err).Msg("output writer: failed to encode result")
-			continue
-		}
-		_ = bw.Flush()
-	}
-}
-
 func toJSONLRecord(r task.Result) map[string]any {
 	rec := toRecord(r)
 	out := map[string]any{
@@ -127,6 +270,54 @@ func toJSONLRecord(r task.Result) map[string]any {
 	if rec.Error != "" {
 		out["error"] = rec.Error
 	}
+	if r.DecodedBytes > 0 {
+		out["decoded_bytes"] = r.DecodedBytes
+	}
+	if r.Protocol != "" {
+		out["protocol"] = r.Protocol
+	}
+	if r.AltSvc != "" {
+		out["alt_svc"] = r.AltSvc
+	}
+	if len(r.Redirects) > 0 {
+		chain := make([]map[string]any, len(r.Redirects))
+		for i, h := range r.Redirects {
+			chain[i] = map[string]any{"url": h.URL, "status": h.StatusCode}
+		}
+		out["redirects"] = chain
+		out["redirect_hops"] = len(r.Redirects)
+	}
+	if r.ConsoleErrors > 0 {
+		out["console_errors"] = r.ConsoleErrors
+		out["console_error"] = r.ConsoleError
+	}
+	if r.FailedRequests > 0 {
+		out["failed_requests"] = r.FailedRequests
+	}
+	if r.Broken() {
+		out["page_broken"] = true
+	}
+	if r.Aborted {
+		out["aborted"] = true
+	}
+	if e := r.Echo; e != nil {
+		out["echo_sent"] = e.Sent
+		out["echo_received"] = e.Echoed
+		if e.Echoed > 0 {
+			// Fractional, since echoes from nearby servers take under 1ms.
+			out["echo_p50_ms"] = float64(e.P50.Microseconds()) / 1000
+			out["echo_p95_ms"] = float64(e.P95.Microseconds()) / 1000
+		}
+	}
+	if r.Slow() {
+		out["slow"] = true
+	}
+	if r.RunID != "" {
+		out["run_id"] = r.RunID
+	}
+	if r.Retry > 0 {
+		out["retry"] = r.Retry
+	}
 	for k, v := range r.Meta {
 		if _, reserved := out[k]; reserved {
 			continue
@@ -135,28 +326,3 @@ func toJSONLRecord(r task.Result) map[string]any {
 	}
 	return out
 }
-
-func (w *Writer) runCSV(bw *bufio.Writer, appendMode bool) {
-	cw := csv.NewWriter(bw)
-	if !appendMode {
-		_ = cw.Write([]string{"ts", "url", "type", "status", "duration_ms", "bytes", "error"})
-		cw.Flush()
-	}
-	for r := range w.ch {
-		rec := toRecord(r)
-		row := []string{
-			rec.TS,
-			rec.URL,
-			rec.Type,
-			fmt.Sprintf("%d", rec.Status),
-			fmt.Sprintf("%d", rec.DurationMs),
-			fmt.Sprintf("%d", rec.Bytes),
-			rec.Error,
-		}
-		if err := cw.Write(row); err != nil {
-			log.Warn().Err(err).Msg("output writer: failed to write CSV row")
-			continue
-		}
-		cw.Flush()
-	}
-}
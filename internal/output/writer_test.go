@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -109,6 +111,145 @@ func TestWriter_JSONL_MetaFields(t *testing.T) {
 	}
 }
 
+func TestWriter_JSONL_RunID(t *testing.T) {
+	f := t.TempDir() + "/out.jsonl"
+	w, err := New(config.OutputConfig{File: f, Format: "jsonl"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	r := makeResult("https://example.com", "http", 200, time.Millisecond, 42, nil)
+	r.RunID = "nightly-42"
+	w.Send(r)
+	w.Close()
+
+	data, _ := os.ReadFile(f)
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimRight(string(data), "\n")), &rec); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if rec["run_id"] != "nightly-42" {
+		t.Errorf("run_id = %v, want nightly-42", rec["run_id"])
+	}
+}
+
+func TestWriter_JSONL_Slow(t *testing.T) {
+	f := t.TempDir() + "/out.jsonl"
+	w, err := New(config.OutputConfig{File: f, Format: "jsonl"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	slow := makeResult("https://example.com/slow", "http", 200, 2*time.Second, 42, nil)
+	slow.Task.Config.LatencyBudgetMs = 500
+	fast := makeResult("https://example.com/fast", "http", 200, 100*time.Millisecond, 42, nil)
+	fast.Task.Config.LatencyBudgetMs = 500
+	w.Send(slow)
+	w.Send(fast)
+	w.Close()
+
+	data, _ := os.ReadFile(f)
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	for i, want := range []any{true, nil} {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &rec); err != nil {
+			t.Fatalf("json.Unmarshal: %v", err)
+		}
+		if rec["slow"] != want {
+			t.Errorf("%s: slow = %v, want %v", rec["url"], rec["slow"], want)
+		}
+	}
+}
+
+func TestWriter_JSONL_ProtocolAndRedirects(t *testing.T) {
+	f := t.TempDir() + "/out.jsonl"
+	w, err := New(config.OutputConfig{File: f, Format: "jsonl"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	r := makeResult("https://example.com/", "http", 200, 10*time.Millisecond, 42, nil)
+	r.Protocol, r.AltSvc = "HTTP/2", "h3"
+	r.Redirects = []task.Hop{{URL: "http://example.com/", StatusCode: 301}}
+	w.Send(r)
+	w.Close()
+
+	data, _ := os.ReadFile(f)
+	var rec map[string]any
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if rec["protocol"] != "HTTP/2" || rec["alt_svc"] != "h3" {
+		t.Errorf("protocol, alt_svc = %v, %v; want HTTP/2, h3", rec["protocol"], rec["alt_svc"])
+	}
+	want := []any{map[string]any{"url": "http://example.com/", "status": float64(301)}}
+	if !reflect.DeepEqual(rec["redirects"], want) || rec["redirect_hops"] != float64(1) {
+		t.Errorf("redirects, redirect_hops = %v, %v; want %v, 1", rec["redirects"], rec["redirect_hops"], want)
+	}
+}
+
+func TestWriter_JSONL_PageErrors(t *testing.T) {
+	f := t.TempDir() + "/out.jsonl"
+	w, err := New(config.OutputConfig{File: f, Format: "jsonl"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	r := makeResult("https://example.com/", "browser", 200, time.Second, 0, nil)
+	r.ConsoleErrors, r.FailedRequests, r.ConsoleError = 2, 1, "TypeError: x is undefined"
+	w.Send(r)
+	w.Send(makeResult("https://example.com/", "browser", 200, time.Second, 0, nil))
+	w.Close()
+
+	data, _ := os.ReadFile(f)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if rec["console_errors"] != float64(2) || rec["failed_requests"] != float64(1) || rec["page_broken"] != true {
+		t.Errorf("record = %v, want console_errors 2, failed_requests 1, page_broken", rec)
+	}
+	if rec["console_error"] != "TypeError: x is undefined" {
+		t.Errorf("console_error = %v", rec["console_error"])
+	}
+	rec = nil
+	if err := json.Unmarshal([]byte(lines[1]), &rec); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	for _, k := range []string{"console_errors", "failed_requests", "console_error", "page_broken", "aborted"} {
+		if _, ok := rec[k]; ok {
+			t.Errorf("clean page record has %s", k)
+		}
+	}
+}
+
+func TestWriter_JSONL_Echo(t *testing.T) {
+	f := t.TempDir() + "/out.jsonl"
+	w, err := New(config.OutputConfig{File: f, Format: "jsonl"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	r := makeResult("ws://example.com/", "websocket", 101, time.Second, 0, nil)
+	r.Echo = &task.EchoStats{Sent: 3, Echoed: 2, P50: 1500 * time.Microsecond, P95: 12 * time.Millisecond}
+	w.Send(r)
+	w.Close()
+
+	data, _ := os.ReadFile(f)
+	var rec map[string]any
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if rec["echo_sent"] != float64(3) || rec["echo_received"] != float64(2) {
+		t.Errorf("echo_sent, echo_received = %v, %v; want 3, 2", rec["echo_sent"], rec["echo_received"])
+	}
+	if rec["echo_p50_ms"] != 1.5 || rec["echo_p95_ms"] != float64(12) {
+		t.Errorf("echo_p50_ms, echo_p95_ms = %v, %v; want 1.5, 12", rec["echo_p50_ms"], rec["echo_p95_ms"])
+	}
+}
+
 func TestWriter_JSONL_MetaCannotOverwriteReservedFields(t *testing.T) {
 	f := t.TempDir() + "/out.jsonl"
 	w, err := New(config.OutputConfig{File: f, Format: "jsonl"})
@@ -143,7 +284,11 @@ func TestWriter_CSV(t *testing.T) {
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
-	w.Send(makeResult("https://example.com", "http", 200, 42*time.Millisecond, 512, nil))
+	r := makeResult("https://example.com", "http", 200, 42*time.Millisecond, 512, nil)
+	r.RunID = "run-1"
+	r.Meta = map[string]string{"request_id": "req-1"}
+	r.Task.Config.LatencyBudgetMs = 10
+	w.Send(r)
 	w.Close()
 
 	data, _ := os.ReadFile(f)
@@ -170,6 +315,12 @@ func TestWriter_CSV(t *testing.T) {
 	if rows[1][4] != "42" {
 		t.Errorf("duration_ms = %q, want 42", rows[1][4])
 	}
+	if got := rows[0][7:]; !slices.Equal(got, []string{"run_id", "request_id", "slow"}) {
+		t.Errorf("trailing header columns = %v", got)
+	}
+	if got := rows[1][7:]; !slices.Equal(got, []string{"run-1", "req-1", "true"}) {
+		t.Errorf("run_id, request_id, slow = %v", got)
+	}
 }
 
 func TestWriter_CSV_AppendNoHeader(t *testing.T) {
@@ -2,7 +2,14 @@ package ratelimit
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"maps"
 	"math/rand"
+	"net"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -40,7 +47,11 @@ func ClassifyStatusCode(code int) ErrorClass {
 	}
 }
 
-// ClassifyError checks a Go error for context cancellation.
+// ClassifyError returns the ErrorClass for a request that failed with err.
+// Context cancellation is fatal. Failures that sending again will not fix —
+// a certificate that does not verify, a TLS handshake the server rejects,
+// a host name that does not exist — are permanent; other network errors,
+// such as timeouts, refused connections, and resets, are transient.
 func ClassifyError(err error) ErrorClass {
 	if err == nil {
 		return ErrorClassNone
@@ -48,44 +59,141 @@ func ClassifyError(err error) ErrorClass {
 	if err == context.Canceled || err == context.DeadlineExceeded {
 		return ErrorClassFatal
 	}
+	if permanentNetError(err) {
+		return ErrorClassPermanent
+	}
 	return ErrorClassTransient
 }
 
+// permanentBrowserErrors are the Chrome network error codes, as reported
+// in navigation errors, that permanentNetError treats as permanent.
+var permanentBrowserErrors = []string{
+	"net::ERR_NAME_NOT_RESOLVED",
+	"net::ERR_CERT_",
+	"net::ERR_SSL_PROTOCOL_ERROR",
+	"net::ERR_SSL_VERSION_OR_CIPHER_MISMATCH",
+}
+
+// permanentNetError reports whether err, or an error it wraps, is a DNS
+// NXDOMAIN, a certificate verification failure, or a TLS handshake failure.
+func permanentNetError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsNotFound
+	}
+
+	var (
+		verifyErr    *tls.CertificateVerificationError
+		unknownCA    x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		alertErr     tls.AlertError
+		recordHdrErr tls.RecordHeaderError
+	)
+	switch {
+	case errors.As(err, &verifyErr),
+		errors.As(err, &unknownCA),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		return true
+	case errors.As(err, &alertErr):
+		// The server refused the handshake: protocol version, cipher
+		// suites, or a client certificate it wanted.
+		return true
+	case errors.As(err, &recordHdrErr):
+		// The server did not answer in TLS, e.g. https to a plain port.
+		return true
+	}
+
+	msg := err.Error()
+	for _, code := range permanentBrowserErrors {
+		if strings.Contains(msg, code) {
+			return true
+		}
+	}
+	return false
+}
+
 // domainBackoff tracks backoff state for a single domain.
 type domainBackoff struct {
 	mu          sync.Mutex
 	attempts    int
 	nextAllowed time.Time
+	cooldown    bool // exhausted MaxAttempts; skip until nextAllowed
+	policy      BackoffPolicy
+}
+
+// BackoffPolicy is one backoff profile: the delay starts at InitialMs, grows
+// by Multiplier per attempt up to MaxMs, and gives up after MaxAttempts.
+// With CooldownS set, a domain that gives up is then quarantined for that
+// many seconds.
+type BackoffPolicy struct {
+	InitialMs   int
+	MaxMs       int
+	Multiplier  float64
+	MaxAttempts int
+	CooldownS   int
 }
 
+// ErrCooldown is returned by BackoffRegistry.Wait for a domain in cooldown:
+// the task should be skipped rather than sent.
+var ErrCooldown = errors.New("domain in cooldown")
+
 // BackoffRegistry tracks backoff state per domain using decorrelated jitter.
 type BackoffRegistry struct {
-	mu          sync.Mutex
-	domains     map[string]*domainBackoff
-	initialMs   int
-	maxMs       int
-	multiplier  float64
-	maxAttempts int
+	mu        sync.Mutex
+	domains   map[string]*domainBackoff
+	policy    BackoffPolicy
+	perDomain map[string]BackoffPolicy
 }
 
 // NewBackoffRegistry creates a BackoffRegistry from config values.
 func NewBackoffRegistry(initialMs, maxMs int, multiplier float64, maxAttempts int) *BackoffRegistry {
 	return &BackoffRegistry{
-		domains:     make(map[string]*domainBackoff),
-		initialMs:   initialMs,
-		maxMs:       maxMs,
-		multiplier:  multiplier,
-		maxAttempts: maxAttempts,
+		domains: make(map[string]*domainBackoff),
+		policy: BackoffPolicy{
+			InitialMs:   initialMs,
+			MaxMs:       maxMs,
+			Multiplier:  multiplier,
+			MaxAttempts: maxAttempts,
+		},
 	}
 }
 
+// SetCooldown sets the default policy's cooldown, in seconds; see
+// BackoffPolicy. Call it before the registry is used.
+func (r *BackoffRegistry) SetCooldown(seconds int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policy.CooldownS = seconds
+}
+
+// SetPerDomain gives domains their own backoff policy. Keys are hostnames
+// or "*.example.com" / ".example.com" patterns, matched as for Registry
+// overrides. Call it before the registry is used.
+func (r *BackoffRegistry) SetPerDomain(perDomain map[string]BackoffPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.perDomain = perDomain
+}
+
+// policyFor returns the backoff policy that applies to domain.
+func (r *BackoffRegistry) policyFor(domain string) BackoffPolicy {
+	if p, ok := matchDomain(r.perDomain, domain); ok {
+		return p
+	}
+	return r.policy
+}
+
 // RecordError notes a transient error for the given domain and updates backoff.
-// Returns the delay that will be applied before the next attempt.
+// Returns the delay that will be applied before the next attempt. The error
+// that brings the domain to MaxAttempts starts its cooldown, if the policy
+// has one, and the delay returned is then at least the cooldown.
 func (r *BackoffRegistry) RecordError(domain string) time.Duration {
 	r.mu.Lock()
 	db, ok := r.domains[domain]
 	if !ok {
-		db = &domainBackoff{}
+		db = &domainBackoff{policy: r.policyFor(domain)}
 		r.domains[domain] = db
 	}
 	r.mu.Unlock()
@@ -94,7 +202,11 @@ func (r *BackoffRegistry) RecordError(domain string) time.Duration {
 	defer db.mu.Unlock()
 
 	db.attempts++
-	delay := r.decorrelatedJitter(db.attempts)
+	delay := db.policy.decorrelatedJitter(db.attempts)
+	if db.attempts >= db.policy.MaxAttempts && db.policy.CooldownS > 0 {
+		db.cooldown = true
+		delay = max(delay, time.Duration(db.policy.CooldownS)*time.Second)
+	}
 	db.nextAllowed = time.Now().Add(delay)
 	return delay
 }
@@ -107,6 +219,7 @@ func (r *BackoffRegistry) RecordSuccess(domain string) {
 }
 
 // Wait blocks until the backoff delay for the domain has elapsed, or ctx is done.
+// A domain in cooldown returns ErrCooldown at once instead of waiting.
 // If the domain has reached max_attempts and its delay has expired, the entry is
 // evicted so the map does not grow without bound.
 func (r *BackoffRegistry) Wait(ctx context.Context, domain string) error {
@@ -120,18 +233,23 @@ func (r *BackoffRegistry) Wait(ctx context.Context, domain string) error {
 	db.mu.Lock()
 	until := db.nextAllowed
 	attempts := db.attempts
+	maxAttempts := db.policy.MaxAttempts
+	cooldown := db.cooldown
 	db.mu.Unlock()
 
 	remaining := time.Until(until)
 	if remaining <= 0 {
 		// Evict entries that have exhausted max attempts and served their delay.
-		if attempts >= r.maxAttempts {
+		if attempts >= maxAttempts {
 			r.mu.Lock()
 			delete(r.domains, domain)
 			r.mu.Unlock()
 		}
 		return nil
 	}
+	if cooldown {
+		return ErrCooldown
+	}
 
 	select {
 	case <-time.After(remaining):
@@ -141,6 +259,29 @@ func (r *BackoffRegistry) Wait(ctx context.Context, domain string) error {
 	}
 }
 
+// CarryOver copies into r the backoff state of every domain old tracks —
+// attempts, next allowed time, and cooldown — so that a registry rebuilt for
+// a config reload keeps delaying and quarantining the same domains. Each
+// domain takes r's policy for it from now on. Call it before r is used.
+func (r *BackoffRegistry) CarryOver(old *BackoffRegistry) {
+	old.mu.Lock()
+	domains := maps.Clone(old.domains)
+	old.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for domain, o := range domains {
+		o.mu.Lock()
+		r.domains[domain] = &domainBackoff{
+			attempts:    o.attempts,
+			nextAllowed: o.nextAllowed,
+			cooldown:    o.cooldown,
+			policy:      r.policyFor(domain),
+		}
+		o.mu.Unlock()
+	}
+}
+
 // Attempts returns the current backoff attempt count for a domain.
 func (r *BackoffRegistry) Attempts(domain string) int {
 	r.mu.Lock()
@@ -154,21 +295,76 @@ func (r *BackoffRegistry) Attempts(domain string) int {
 	return db.attempts
 }
 
+// InCooldown reports whether the domain is quarantined after exhausting its
+// attempts.
+func (r *BackoffRegistry) InCooldown(domain string) bool {
+	r.mu.Lock()
+	db, ok := r.domains[domain]
+	r.mu.Unlock()
+	if !ok {
+		return false
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.cooldown && time.Now().Before(db.nextAllowed)
+}
+
+// BackoffState is the backoff of one domain: how many consecutive
+// transient errors it has had and when it may next be requested. Cooldown
+// is set while its tasks are skipped until NextAllowed.
+type BackoffState struct {
+	Domain      string
+	Attempts    int
+	MaxAttempts int
+	NextAllowed time.Time
+	Cooldown    bool
+}
+
+// Snapshot returns the state of every domain currently tracked, sorted by
+// domain. A domain stays listed after its delay has passed until it
+// succeeds or, having exhausted MaxAttempts, is evicted by Wait.
+func (r *BackoffRegistry) Snapshot() []BackoffState {
+	r.mu.Lock()
+	out := make([]BackoffState, 0, len(r.domains))
+	for domain, db := range r.domains {
+		db.mu.Lock()
+		out = append(out, BackoffState{
+			Domain:      domain,
+			Attempts:    db.attempts,
+			MaxAttempts: db.policy.MaxAttempts,
+			NextAllowed: db.nextAllowed,
+			Cooldown:    db.cooldown,
+		})
+		db.mu.Unlock()
+	}
+	r.mu.Unlock()
+	slices.SortFunc(out, func(a, b BackoffState) int { return strings.Compare(a.Domain, b.Domain) })
+	return out
+}
+
 // MaxAttempts returns the configured maximum retry attempts.
 func (r *BackoffRegistry) MaxAttempts() int {
-	return r.maxAttempts
+	return r.policy.MaxAttempts
+}
+
+// MaxAttemptsFor returns the maximum retry attempts for domain, taking any
+// per-domain policy into account.
+func (r *BackoffRegistry) MaxAttemptsFor(domain string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.policyFor(domain).MaxAttempts
 }
 
 // decorrelatedJitter implements AWS-style decorrelated jitter backoff.
 // delay = random(base, prev_delay * multiplier), capped at maxMs.
-func (r *BackoffRegistry) decorrelatedJitter(attempt int) time.Duration {
-	base := float64(r.initialMs)
-	cap := float64(r.maxMs)
+func (p BackoffPolicy) decorrelatedJitter(attempt int) time.Duration {
+	base := float64(p.InitialMs)
+	cap := float64(p.MaxMs)
 
 	// Exponential ceiling for this attempt.
 	ceiling := base
 	for i := 1; i < attempt; i++ {
-		ceiling *= r.multiplier
+		ceiling *= p.Multiplier
 		if ceiling > cap {
 			ceiling = cap
 			break
@@ -2,7 +2,16 @@ package ratelimit
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 )
@@ -63,6 +72,49 @@ func TestClassifyError_OtherError(t *testing.T) {
 	}
 }
 
+func TestClassifyError_NetworkErrors(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	cases := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"nxdomain", wrap(&net.DNSError{Err: "no such host", Name: "nope.example", IsNotFound: true}), ErrorClassPermanent},
+		{"dns timeout", wrap(&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}), ErrorClassTransient},
+		{"refused", wrap(errors.New("connect: connection refused")), ErrorClassTransient},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://example.com", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, ErrorClassPermanent},
+		{"hostname mismatch", fmt.Errorf("dialing: %w", x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}), ErrorClassPermanent},
+		{"expired", x509.CertificateInvalidError{Reason: x509.Expired}, ErrorClassPermanent},
+		{"handshake alert", wrap(tls.AlertError(40)), ErrorClassPermanent},
+		{"not tls", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}, ErrorClassPermanent},
+		{"browser nxdomain", errors.New("browser: page load error net::ERR_NAME_NOT_RESOLVED"), ErrorClassPermanent},
+		{"browser cert", errors.New("browser: page load error net::ERR_CERT_DATE_INVALID"), ErrorClassPermanent},
+		{"browser reset", errors.New("browser: page load error net::ERR_CONNECTION_RESET"), ErrorClassTransient},
+	}
+	for _, tc := range cases {
+		if got := ClassifyError(tc.err); got != tc.want {
+			t.Errorf("%s: ClassifyError(%v) = %v, want %v", tc.name, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestClassifyError_UntrustedCertificate(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // the rejected handshake
+	srv.StartTLS()
+	defer srv.Close()
+
+	_, err := http.Get(srv.URL)
+	if err == nil {
+		t.Fatal("GET with an untrusted certificate succeeded")
+	}
+	if got := ClassifyError(err); got != ErrorClassPermanent {
+		t.Errorf("ClassifyError(%v) = %v, want ErrorClassPermanent", err, got)
+	}
+}
+
 // --- BackoffRegistry tests ---
 
 func newTestRegistry() *BackoffRegistry {
@@ -160,6 +212,25 @@ func TestBackoffRegistry_MaxAttempts(t *testing.T) {
 	}
 }
 
+func TestBackoffRegistry_PerDomainPolicy(t *testing.T) {
+	r := NewBackoffRegistry(100, 5000, 2.0, 3)
+	r.SetPerDomain(map[string]BackoffPolicy{
+		"*.flaky.com": {InitialMs: 20_000, MaxMs: 60_000, Multiplier: 2.0, MaxAttempts: 10},
+	})
+	if got := r.MaxAttemptsFor("api.flaky.com"); got != 10 {
+		t.Errorf("MaxAttemptsFor(api.flaky.com) = %d, want 10", got)
+	}
+	if got := r.MaxAttemptsFor("other.com"); got != 3 {
+		t.Errorf("MaxAttemptsFor(other.com) = %d, want the default 3", got)
+	}
+	if d := r.RecordError("api.flaky.com"); d < 20*time.Second {
+		t.Errorf("flaky delay = %v, want at least its 20s initial_ms", d)
+	}
+	if d := r.RecordError("other.com"); d > 5*time.Second {
+		t.Errorf("default delay = %v, want at most the 5s max_ms", d)
+	}
+}
+
 func TestBackoffRegistry_ConcurrentAccess(t *testing.T) {
 	r := newTestRegistry()
 	done := make(chan struct{})
@@ -207,6 +278,63 @@ func TestBackoffRegistry_EvictsMaxedOutDomain(t *testing.T) {
 	}
 }
 
+func TestBackoffRegistry_CooldownSkipsThenEvicts(t *testing.T) {
+	r := NewBackoffRegistry(1, 10, 2.0, 2)
+	r.SetPerDomain(map[string]BackoffPolicy{
+		"cool.com": {InitialMs: 1, MaxMs: 10, Multiplier: 2, MaxAttempts: 2, CooldownS: 60},
+	})
+
+	r.RecordError("cool.com")
+	if r.InCooldown("cool.com") {
+		t.Fatal("cooldown started before max attempts")
+	}
+	if d := r.RecordError("cool.com"); d < time.Minute {
+		t.Errorf("delay at max attempts = %v, want the 60s cooldown", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Wait(ctx, "cool.com"); !errors.Is(err, ErrCooldown) {
+		t.Fatalf("Wait in cooldown = %v, want ErrCooldown without blocking", err)
+	}
+	if s := r.Snapshot(); len(s) != 1 || !s[0].Cooldown {
+		t.Errorf("Snapshot = %+v, want cool.com in cooldown", s)
+	}
+
+	// Once the cooldown has passed the domain is evicted and traffic resumes.
+	r.domains["cool.com"].nextAllowed = time.Now().Add(-time.Millisecond)
+	if err := r.Wait(ctx, "cool.com"); err != nil {
+		t.Fatalf("Wait after cooldown = %v", err)
+	}
+	if r.InCooldown("cool.com") || r.Attempts("cool.com") != 0 {
+		t.Error("domain should have been evicted once its cooldown ended")
+	}
+}
+
+func TestBackoffRegistry_CarryOverKeepsCooldowns(t *testing.T) {
+	old := NewBackoffRegistry(1, 10, 2.0, 2)
+	old.SetCooldown(60)
+	old.RecordError("cool.com")
+	old.RecordError("cool.com")
+	old.RecordError("warm.com")
+
+	r := NewBackoffRegistry(1, 10, 2.0, 5)
+	r.CarryOver(old)
+	if !r.InCooldown("cool.com") {
+		t.Error("cooldown lost when the registry was rebuilt")
+	}
+	if got := r.Attempts("warm.com"); got != 1 {
+		t.Errorf("warm.com attempts = %d, want 1", got)
+	}
+	if got := r.Snapshot()[0].MaxAttempts; got != 5 {
+		t.Errorf("carried domain MaxAttempts = %d, want the new policy's 5", got)
+	}
+	old.RecordSuccess("cool.com")
+	if !r.InCooldown("cool.com") {
+		t.Error("the new registry shares state with the old one")
+	}
+}
+
 func TestBackoffRegistry_IsolatesDomains(t *testing.T) {
 	r := newTestRegistry()
 	r.RecordError("a.com")
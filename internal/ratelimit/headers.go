@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Budget is a request allowance advertised by a server: Remaining more
+// requests may be sent before Reset, when the allowance is renewed.
+type Budget struct {
+	Remaining int
+	Reset     time.Time
+}
+
+// epochThreshold separates X-RateLimit-Reset values given as Unix
+// timestamps (GitHub, Twitter) from values given as seconds to wait.
+const epochThreshold = 1_000_000_000
+
+// ParseHeaders reads a rate-limit budget from response headers. It
+// understands, in order of preference:
+//
+//	RateLimit: "default";r=50;t=30     IETF draft (structured field)
+//	RateLimit: limit=100, remaining=50, reset=30
+//	RateLimit-Remaining / RateLimit-Reset (seconds)
+//	X-RateLimit-Remaining / X-RateLimit-Reset (seconds or Unix time)
+//
+// ok is false when no remaining count with a usable reset time is present.
+func ParseHeaders(h http.Header, now time.Time) (b Budget, ok bool) {
+	if v := h.Get("RateLimit"); v != "" {
+		if b, ok := parseRateLimitField(v, now); ok {
+			return b, true
+		}
+	}
+	for _, prefix := range []string{"RateLimit-", "X-RateLimit-"} {
+		rem, okRem := atoiHeader(h, prefix+"Remaining")
+		reset, okReset := atoiHeader(h, prefix+"Reset")
+		if !okRem || !okReset {
+			continue
+		}
+		if prefix == "X-RateLimit-" && reset >= epochThreshold {
+			return Budget{Remaining: rem, Reset: time.Unix(int64(reset), 0)}, true
+		}
+		return Budget{Remaining: rem, Reset: now.Add(time.Duration(reset) * time.Second)}, true
+	}
+	return Budget{}, false
+}
+
+// parseRateLimitField parses the combined RateLimit header, accepting both
+// the "r=…;t=…" parameters of the current draft and the older
+// "remaining=…, reset=…" form. With several policies the first one wins.
+func parseRateLimitField(v string, now time.Time) (Budget, bool) {
+	item, _, _ := strings.Cut(v, ",")
+	if !strings.Contains(item, ";") {
+		item = v // older form: comma-separated key=value pairs
+	}
+	var (
+		rem, reset     int
+		okRem, okReset bool
+	)
+	for _, part := range strings.FieldsFunc(item, func(r rune) bool { return r == ';' || r == ',' }) {
+		key, val, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		n, err := strconv.Atoi(strings.Trim(val, `" `))
+		if err != nil || n < 0 {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "r", "remaining":
+			rem, okRem = n, true
+		case "t", "reset":
+			reset, okReset = n, true
+		}
+	}
+	if !okRem || !okReset {
+		return Budget{}, false
+	}
+	return Budget{Remaining: rem, Reset: now.Add(time.Duration(reset) * time.Second)}, true
+}
+
+func atoiHeader(h http.Header, name string) (int, bool) {
+	v := strings.TrimSpace(h.Get(name))
+	if v == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseHeaders(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    Budget
+		ok      bool
+	}{
+		{"none", nil, Budget{}, false},
+		{"x-ratelimit delta", map[string]string{"X-RateLimit-Remaining": "40", "X-RateLimit-Reset": "20"},
+			Budget{Remaining: 40, Reset: now.Add(20 * time.Second)}, true},
+		{"x-ratelimit epoch", map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1700000060"},
+			Budget{Remaining: 0, Reset: now.Add(time.Minute)}, true},
+		{"ietf split", map[string]string{"RateLimit-Remaining": "9", "RateLimit-Reset": "3"},
+			Budget{Remaining: 9, Reset: now.Add(3 * time.Second)}, true},
+		{"ietf structured", map[string]string{"RateLimit": `"default";r=50;t=30, "burst";r=5;t=1`},
+			Budget{Remaining: 50, Reset: now.Add(30 * time.Second)}, true},
+		{"ietf older combined", map[string]string{"RateLimit": "limit=100, remaining=7, reset=12"},
+			Budget{Remaining: 7, Reset: now.Add(12 * time.Second)}, true},
+		{"combined preferred", map[string]string{"RateLimit": `"p";r=1;t=2`, "X-RateLimit-Remaining": "99", "X-RateLimit-Reset": "60"},
+			Budget{Remaining: 1, Reset: now.Add(2 * time.Second)}, true},
+		{"remaining without reset", map[string]string{"X-RateLimit-Remaining": "5"}, Budget{}, false},
+		{"malformed", map[string]string{"X-RateLimit-Remaining": "lots", "X-RateLimit-Reset": "10"}, Budget{}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			got, ok := ParseHeaders(h, now)
+			if ok != tc.ok || got.Remaining != tc.want.Remaining || !got.Reset.Equal(tc.want.Reset) {
+				t.Errorf("ParseHeaders = %+v, %v; want %+v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package ratelimit
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
@@ -10,41 +13,430 @@ import (
 // Registry maintains per-domain token bucket rate limiters.
 type Registry struct {
 	mu         sync.Mutex
-	limiters   map[string]*rate.Limiter
+	limiters   map[string]*domainLimiter
 	defaultRPS float64
 	perDomain  map[string]float64
+	burst      int
+	perBurst   map[string]int
+	perGap     map[string]time.Duration
+	adaptive   *Adaptive
+	global     *rate.Limiter // caps the sum over all domains; nil = none
+	shared     Shared        // budget shared with other instances; nil = none
 }
 
-// NewRegistry creates a Registry with the given defaults and per-domain overrides.
+// Adaptive configures latency-adaptive limiting: every Interval, a domain
+// whose p95 latency over its recent requests exceeds P95Threshold has its
+// rate multiplied by DecreaseFactor (but not below MinRPS); a domain under
+// the threshold regains RecoveryStep of its configured rate per Interval.
+type Adaptive struct {
+	P95Threshold   time.Duration
+	MinRPS         float64
+	DecreaseFactor float64
+	RecoveryStep   float64
+	Interval       time.Duration
+}
+
+// Adjustment describes a rate change made by ObserveLatency.
+type Adjustment struct {
+	P95 time.Duration
+	RPS float64 // the domain's new rate
+	Up  bool    // recovering rather than backing off
+}
+
+const (
+	// latencyWindow is how many recent latencies per domain feed the p95.
+	latencyWindow = 50
+	// minLatencySamples is how many samples are needed before adapting.
+	minLatencySamples = 5
+)
+
+// domainLimiter is the limiter of one domain plus any budget the server
+// advertised through Observe and the latency factor kept by ObserveLatency.
+// The configured rate is restored once the budget's reset time has passed.
+type domainLimiter struct {
+	lim         *rate.Limiter
+	base        float64   // configured requests per second
+	burst       int       // configured bucket size
+	budgetRPS   float64   // rate allowed by the advertised budget; 0 = none
+	budgetUntil time.Time // when the advertised budget resets
+	blocked     bool      // the budget was exhausted; wait for budgetUntil
+
+	gap    time.Duration // minimum time between two requests; 0 = none
+	raised time.Duration // gap asked for through RaiseMinInterval
+	nextAt time.Time     // when the next request may start, given gap
+
+	factor     float64         // latency-adaptive share of base, in (0, 1]
+	latencies  []time.Duration // ring of recent latencies
+	next       int             // ring write position
+	lastAdjust time.Time
+}
+
+// effective returns the rate the limiter should run at.
+func (dl *domainLimiter) effective() float64 {
+	rps := dl.base * dl.factor
+	if dl.budgetRPS > 0 {
+		rps = min(rps, dl.budgetRPS)
+	}
+	return rps
+}
+
+// NewRegistry creates a Registry with the given defaults and per-domain
+// overrides. An override key is either a hostname or a suffix pattern —
+// "*.example.com" or ".example.com" — covering every subdomain, each of
+// which gets its own limiter at the override's rate; see matchDomain.
 func NewRegistry(defaultRPS float64, perDomain map[string]float64) *Registry {
 	return &Registry{
-		limiters:   make(map[string]*rate.Limiter),
+		limiters:   make(map[string]*domainLimiter),
 		defaultRPS: defaultRPS,
 		perDomain:  perDomain,
+		burst:      1,
+	}
+}
+
+// SetBurst sets the bucket size of every domain's limiter, with per-domain
+// overrides; a limiter lets that many requests through back to back after
+// an idle spell. The default is 1. Call it before the registry is used.
+func (r *Registry) SetBurst(defaultBurst int, perDomain map[string]int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.burst = max(defaultBurst, 1)
+	r.perBurst = perDomain
+}
+
+// SetMinInterval sets, per domain, the minimum time between the starts of
+// two requests to it, whatever its rate; keys are as for NewRegistry. Call
+// it before the registry is used.
+func (r *Registry) SetMinInterval(perDomain map[string]time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.perGap = perDomain
+}
+
+// RaiseMinInterval makes the minimum time between two requests to domain
+// at least d, as a robots.txt Crawl-delay asks.
+func (r *Registry) RaiseMinInterval(domain string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dl := r.getLocked(domain)
+	dl.raised = max(dl.raised, d)
+	dl.gap = max(dl.gap, d)
+}
+
+// CarryOver copies into r what old has learnt about each domain — an
+// advertised budget still in force, the latency-adaptive rate and its
+// samples, and any minimum interval raised through RaiseMinInterval — so
+// that a registry rebuilt for a config reload throttles as old did. The
+// configured rates, bursts, and intervals stay r's own. Call it before r
+// is used.
+func (r *Registry) CarryOver(old *Registry) {
+	old.mu.Lock()
+	learnt := make(map[string]domainLimiter, len(old.limiters))
+	for domain, dl := range old.limiters {
+		c := *dl
+		c.latencies = slices.Clone(dl.latencies)
+		learnt[domain] = c
+	}
+	old.mu.Unlock()
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for domain, o := range learnt {
+		dl := r.getLocked(domain)
+		if o.budgetUntil.After(now) {
+			dl.budgetRPS, dl.budgetUntil, dl.blocked = o.budgetRPS, o.budgetUntil, o.blocked
+		}
+		if r.adaptive != nil {
+			dl.factor, dl.latencies, dl.next, dl.lastAdjust = o.factor, o.latencies, o.next, o.lastAdjust
+		}
+		dl.raised = o.raised
+		dl.gap = max(dl.gap, o.raised)
+		dl.nextAt = o.nextAt
+		dl.lim.SetLimitAt(now, rate.Limit(dl.effective()))
 	}
 }
 
-// Wait blocks until the rate limiter for the given domain allows the request,
-// or until ctx is cancelled.
+// Wait blocks until the rate limiter for the given domain, then the shared
+// budget and the global cap if set, allow the request, and the domain's
+// minimum interval has passed since its previous request, or until ctx is
+// cancelled.
 func (r *Registry) Wait(ctx context.Context, domain string) error {
-	lim := r.getLimiter(domain)
-	return lim.Wait(ctx)
+	dl, until := r.limiter(domain, time.Now())
+	if err := sleep(ctx, time.Until(until)); err != nil {
+		return err
+	}
+	if err := dl.lim.Wait(ctx); err != nil {
+		return err
+	}
+	if r.shared != nil {
+		if err := r.waitShared(ctx, domain, dl.base, dl.burst); err != nil {
+			return err
+		}
+	}
+	if r.global != nil {
+		if err := r.global.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	// Last, so that nothing holds the request once its slot comes.
+	return sleep(ctx, r.reserveGap(dl, time.Now()))
+}
+
+// reserveGap takes the domain's next request slot under its minimum
+// interval and returns how long to wait for it.
+func (r *Registry) reserveGap(dl *domainLimiter, now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dl.gap <= 0 {
+		return 0
+	}
+	at := now
+	if dl.nextAt.After(now) {
+		at = dl.nextAt
+	}
+	dl.nextAt = at.Add(dl.gap)
+	return at.Sub(now)
+}
+
+// waitShared retries the shared budget until it grants the domain a token.
+// If the shared store fails the request goes ahead on the local limits
+// alone, so an outage slows nothing down but stops coordinating instances.
+func (r *Registry) waitShared(ctx context.Context, domain string, rps float64, burst int) error {
+	for {
+		d, err := r.shared.Reserve(ctx, domain, rps, burst)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return nil
+		}
+		if d <= 0 {
+			return nil
+		}
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// SetShared makes every domain also draw from a budget shared with other
+// instances, at the domain's configured rate and burst, after its own
+// limiter; nil removes it. Call it before the registry is used.
+func (r *Registry) SetShared(s Shared) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shared = s
+}
+
+// sleep waits for d, or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetGlobal caps the combined rate of every domain at rps requests per
+// second, with a bucket of burst, on top of the per-domain limits; rps <= 0
+// removes the cap. Call it before the registry is used.
+func (r *Registry) SetGlobal(rps float64, burst int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rps <= 0 {
+		r.global = nil
+		return
+	}
+	r.global = rate.NewLimiter(rate.Limit(rps), max(burst, 1))
+}
+
+// maxBudgetWindow caps how far ahead an advertised reset is honoured, so a
+// misread or hostile header cannot stall a domain indefinitely.
+const maxBudgetWindow = time.Hour
+
+// Observe adapts the domain's limiter to a budget advertised by the server,
+// spreading the remaining requests evenly until the reset time. The rate
+// never rises above the configured one, and a budget of zero holds every
+// request for the domain until the reset (at most maxBudgetWindow ahead).
+// Budgets whose reset time has already passed are ignored.
+func (r *Registry) Observe(domain string, b Budget) {
+	now := time.Now()
+	window := b.Reset.Sub(now)
+	if window <= 0 {
+		return
+	}
+	if window > maxBudgetWindow {
+		window = maxBudgetWindow
+		b.Reset = now.Add(window)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dl := r.getLocked(domain)
+	dl.budgetUntil = b.Reset
+	dl.blocked = b.Remaining <= 0
+	if dl.blocked {
+		return
+	}
+	dl.budgetRPS = float64(b.Remaining) / window.Seconds()
+	dl.lim.SetLimitAt(now, rate.Limit(dl.effective()))
+	// A burst must not overspend what the server has left.
+	dl.lim.SetBurstAt(now, min(dl.burst, b.Remaining))
+}
+
+// SetAdaptive enables latency-adaptive limiting for every domain; nil
+// disables it. Call it before the registry is used.
+func (r *Registry) SetAdaptive(a *Adaptive) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.adaptive = a
+}
+
+// ObserveLatency records a request latency for the domain and, when
+// adaptive limiting is enabled and an Interval has passed since the last
+// change, lowers or restores the domain's rate from the p95 of its recent
+// latencies. It reports the change made, if any.
+func (r *Registry) ObserveLatency(domain string, d time.Duration) (Adjustment, bool) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a := r.adaptive
+	if a == nil {
+		return Adjustment{}, false
+	}
+	dl := r.getLocked(domain)
+	if len(dl.latencies) < latencyWindow {
+		dl.latencies = append(dl.latencies, d)
+	} else {
+		dl.latencies[dl.next] = d
+		dl.next = (dl.next + 1) % latencyWindow
+	}
+	if len(dl.latencies) < minLatencySamples || now.Sub(dl.lastAdjust) < a.Interval {
+		return Adjustment{}, false
+	}
+
+	p95 := percentile95(dl.latencies)
+	floor := min(1, a.MinRPS/dl.base)
+	factor := dl.factor
+	switch {
+	case p95 > a.P95Threshold:
+		factor = max(floor, factor*a.DecreaseFactor)
+	case factor < 1:
+		factor = min(1, factor+a.RecoveryStep)
+	}
+	if factor == dl.factor {
+		return Adjustment{}, false
+	}
+	up := factor > dl.factor
+	dl.factor, dl.lastAdjust = factor, now
+	if !up {
+		// Judge the next step on latencies seen at the new rate only.
+		dl.latencies, dl.next = dl.latencies[:0], 0
+	}
+	dl.lim.SetLimitAt(now, rate.Limit(dl.effective()))
+	return Adjustment{P95: p95, RPS: dl.effective(), Up: up}, true
 }
 
-func (r *Registry) getLimiter(domain string) *rate.Limiter {
+// percentile95 returns the nearest-rank 95th percentile of ds.
+func percentile95(ds []time.Duration) time.Duration {
+	sorted := slices.Clone(ds)
+	slices.Sort(sorted)
+	rank := (len(sorted)*95 + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// Limit returns the domain's current requests-per-second limit, reflecting
+// any budget advertised by the server (zero while the budget is exhausted).
+func (r *Registry) Limit(domain string) float64 {
+	dl, until := r.limiter(domain, time.Now())
+	if !until.IsZero() {
+		return 0
+	}
+	return float64(dl.lim.Limit())
+}
+
+// limiter returns the domain's limiter state and, while an exhausted budget is in
+// force, the time to wait until before using it. An expired budget is
+// dropped and the configured rate restored.
+// Only its lim, base, and burst may be read without holding r.mu.
+func (r *Registry) limiter(domain string, now time.Time) (*domainLimiter, time.Time) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	dl := r.getLocked(domain)
+	if !dl.budgetUntil.IsZero() && !now.Before(dl.budgetUntil) {
+		dl.budgetUntil, dl.blocked, dl.budgetRPS = time.Time{}, false, 0
+		dl.lim.SetLimitAt(now, rate.Limit(dl.effective()))
+		dl.lim.SetBurstAt(now, dl.burst)
+	}
+	if dl.blocked {
+		return dl, dl.budgetUntil
+	}
+	return dl, time.Time{}
+}
+
+// matchDomain looks host up in a map keyed by hostnames and suffix
+// patterns. An exact key wins; otherwise the longest pattern whose suffix
+// matches applies, where "*.example.com" and ".example.com" both match
+// a.example.com and a.b.example.com but not example.com itself. Matching is
+// case-insensitive.
+func matchDomain[V any](m map[string]V, host string) (V, bool) {
+	if v, ok := m[host]; ok {
+		return v, true
+	}
+	host = strings.ToLower(host)
+	var (
+		best    V
+		bestLen int
+	)
+	for key, v := range m {
+		if strings.EqualFold(key, host) {
+			return v, true
+		}
+		suffix, ok := domainSuffix(key)
+		if ok && len(suffix) > bestLen && strings.HasSuffix(host, suffix) {
+			best, bestLen = v, len(suffix)
+		}
+	}
+	return best, bestLen > 0
+}
 
-	if lim, ok := r.limiters[domain]; ok {
-		return lim
+// domainSuffix returns the lower-cased ".example.com" suffix a pattern key
+// matches, or false for a plain hostname.
+func domainSuffix(key string) (string, bool) {
+	key = strings.ToLower(key)
+	switch {
+	case strings.HasPrefix(key, "*."):
+		return key[1:], len(key) > 2
+	case strings.HasPrefix(key, "."):
+		return key, len(key) > 1
+	}
+	return "", false
+}
+
+func (r *Registry) getLocked(domain string) *domainLimiter {
+	if dl, ok := r.limiters[domain]; ok {
+		return dl
 	}
 
 	rps := r.defaultRPS
-	if override, ok := r.perDomain[domain]; ok {
+	if override, ok := matchDomain(r.perDomain, domain); ok {
 		rps = override
 	}
 
-	lim := rate.NewLimiter(rate.Limit(rps), 1)
-	r.limiters[domain] = lim
-	return lim
+	burst := r.burst
+	if override, ok := matchDomain(r.perBurst, domain); ok && override > 0 {
+		burst = override
+	}
+
+	gap, _ := matchDomain(r.perGap, domain)
+
+	dl := &domainLimiter{lim: rate.NewLimiter(rate.Limit(rps), burst), base: rps, burst: burst, factor: 1, gap: gap}
+	r.limiters[domain] = dl
+	return dl
 }
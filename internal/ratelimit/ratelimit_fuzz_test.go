@@ -13,6 +13,7 @@ func FuzzClassifyError(f *testing.F) {
 	f.Add("deadline exceeded")
 	f.Add("")
 	f.Add("unexpected EOF")
+	f.Add("page load error net::ERR_NAME_NOT_RESOLVED")
 	f.Add("\x00\xff")
 
 	f.Fuzz(func(t *testing.T, msg string) {
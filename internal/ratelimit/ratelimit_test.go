@@ -57,6 +57,130 @@ func TestRegistry_PerDomainOverride(t *testing.T) {
 	}
 }
 
+func TestRegistry_BurstAllowsBackToBackRequests(t *testing.T) {
+	reg := NewRegistry(0.01, nil)
+	reg.SetBurst(3, map[string]int{"single.com": 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		if err := reg.Wait(ctx, "bursty.com"); err != nil {
+			t.Fatalf("request %d within the burst: %v", i, err)
+		}
+	}
+	if err := reg.Wait(ctx, "bursty.com"); err == nil {
+		t.Error("fourth request passed a burst of 3 at 0.01 rps")
+	}
+
+	_ = reg.Wait(ctx, "single.com")
+	if err := reg.Wait(ctx, "single.com"); err == nil {
+		t.Error("per-domain burst of 1 let a second request through")
+	}
+}
+
+func TestRegistry_MinIntervalSpacesRequests(t *testing.T) {
+	reg := NewRegistry(1000, nil)
+	reg.SetBurst(10, nil)
+	reg.SetMinInterval(map[string]time.Duration{"*.polite.com": 50 * time.Millisecond})
+	ctx := context.Background()
+
+	// The bucket would let all three through at once; the gap holds them.
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := reg.Wait(ctx, "a.polite.com"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests with a 50ms gap took %v, want at least 100ms", elapsed)
+	}
+
+	// Other domains are not held, until a crawl delay raises their gap.
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		_ = reg.Wait(ctx, "other.com")
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Millisecond {
+		t.Errorf("domain without a gap took %v for 3 requests", elapsed)
+	}
+	reg.RaiseMinInterval("other.com", 50*time.Millisecond)
+	reg.RaiseMinInterval("other.com", 10*time.Millisecond) // lower: no change
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		_ = reg.Wait(ctx, "other.com")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests after RaiseMinInterval(50ms) took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	m := map[string]float64{
+		"shop.example.com":  1,
+		"*.example.com":     2,
+		"*.eu.example.com":  3,
+		".internal":         4,
+		"Mixed.Example.org": 5,
+	}
+	for _, tc := range []struct {
+		host string
+		want float64
+		ok   bool
+	}{
+		{"shop.example.com", 1, true},
+		{"a.example.com", 2, true},
+		{"a.b.example.com", 2, true},
+		{"a.eu.example.com", 3, true},
+		{"example.com", 0, false},
+		{"db.internal", 4, true},
+		{"internal", 0, false},
+		{"A.EXAMPLE.COM", 2, true},
+		{"mixed.example.org", 5, true},
+		{"notexample.com", 0, false},
+	} {
+		got, ok := matchDomain(m, tc.host)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("matchDomain(%q) = %v, %v; want %v, %v", tc.host, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRegistry_WildcardOverrideGivesEachSubdomainItsOwnLimiter(t *testing.T) {
+	reg := NewRegistry(100, map[string]float64{"*.slow.com": 0.01})
+	if got := reg.Limit("a.slow.com"); got != 0.01 {
+		t.Errorf("a.slow.com limit = %v, want 0.01", got)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := reg.Wait(ctx, "a.slow.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Wait(ctx, "b.slow.com"); err != nil {
+		t.Errorf("b.slow.com shares a.slow.com's bucket: %v", err)
+	}
+}
+
+func TestRegistry_GlobalCapSpansDomains(t *testing.T) {
+	reg := NewRegistry(1000, nil)
+	reg.SetGlobal(0.01, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	for _, domain := range []string{"a.com", "b.com"} {
+		if err := reg.Wait(ctx, domain); err != nil {
+			t.Fatalf("%s within the global burst: %v", domain, err)
+		}
+	}
+	if err := reg.Wait(ctx, "c.com"); err == nil {
+		t.Error("a third domain passed a global cap of 2 requests")
+	}
+
+	reg.SetGlobal(0, 0)
+	if err := reg.Wait(context.Background(), "c.com"); err != nil {
+		t.Errorf("Wait with the cap removed: %v", err)
+	}
+}
+
 func TestRegistry_LazilySeparatesDomains(t *testing.T) {
 	reg := NewRegistry(100.0, nil)
 	ctx := context.Background()
@@ -77,3 +201,152 @@ func TestRegistry_LazilySeparatesDomains(t *testing.T) {
 		t.Errorf("expected %d limiters, got %d", len(domains), count)
 	}
 }
+
+func TestRegistry_ObserveLowersRateUntilReset(t *testing.T) {
+	reg := NewRegistry(100.0, nil)
+	reg.Observe("api.com", Budget{Remaining: 10, Reset: time.Now().Add(200 * time.Millisecond)})
+	if got := reg.Limit("api.com"); got <= 0 || got > 51 {
+		t.Errorf("limit with 10 left for 200ms = %v, want about 50", got)
+	}
+	if got := reg.Limit("other.com"); got != 100 {
+		t.Errorf("other domain limit = %v, want 100", got)
+	}
+
+	// A generous budget never raises the rate above the configured one.
+	reg.Observe("other.com", Budget{Remaining: 1_000_000, Reset: time.Now().Add(time.Second)})
+	if got := reg.Limit("other.com"); got != 100 {
+		t.Errorf("limit with a large budget = %v, want the configured 100", got)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if got := reg.Limit("api.com"); got != 100 {
+		t.Errorf("limit after reset = %v, want the configured 100", got)
+	}
+}
+
+func TestRegistry_ObserveExhaustedBudgetHoldsUntilReset(t *testing.T) {
+	reg := NewRegistry(100.0, nil)
+	reset := time.Now().Add(150 * time.Millisecond)
+	reg.Observe("api.com", Budget{Remaining: 0, Reset: reset})
+	if got := reg.Limit("api.com"); got != 0 {
+		t.Errorf("limit with an exhausted budget = %v, want 0", got)
+	}
+
+	if err := reg.Wait(context.Background(), "api.com"); err != nil {
+		t.Fatal(err)
+	}
+	if time.Now().Before(reset) {
+		t.Error("Wait returned before the advertised reset")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reg.Observe("api.com", Budget{Remaining: 0, Reset: time.Now().Add(time.Minute)})
+	if err := reg.Wait(ctx, "api.com"); err == nil {
+		t.Error("Wait ignored a cancelled context while held")
+	}
+
+	// Resets already in the past are ignored.
+	reg.Observe("stale.com", Budget{Remaining: 0, Reset: time.Now().Add(-time.Second)})
+	if got := reg.Limit("stale.com"); got != 100 {
+		t.Errorf("limit after a stale budget = %v, want 100", got)
+	}
+}
+
+func TestRegistry_ObserveLatencyBacksOffAndRecovers(t *testing.T) {
+	reg := NewRegistry(10.0, nil)
+	if _, ok := reg.ObserveLatency("api.com", time.Second); ok {
+		t.Error("ObserveLatency adjusted with adaptive limiting disabled")
+	}
+
+	reg = NewRegistry(10.0, nil)
+	reg.SetAdaptive(&Adaptive{
+		P95Threshold:   100 * time.Millisecond,
+		MinRPS:         2,
+		DecreaseFactor: 0.5,
+		RecoveryStep:   0.5,
+		Interval:       time.Nanosecond,
+	})
+	slow := func() (Adjustment, bool) {
+		var adj Adjustment
+		var ok bool
+		for range minLatencySamples {
+			adj, ok = reg.ObserveLatency("api.com", 500*time.Millisecond)
+		}
+		return adj, ok
+	}
+
+	if adj, ok := slow(); !ok || adj.Up || adj.RPS != 5 {
+		t.Fatalf("after slow responses = %+v, %v; want down to 5 rps", adj, ok)
+	}
+	if got := reg.Limit("other.com"); got != 10 {
+		t.Errorf("other domain limit = %v, want 10", got)
+	}
+	slow()
+	if adj, _ := slow(); adj.RPS != 2 {
+		t.Errorf("rate after repeated slow responses = %v, want the 2 rps floor", adj.RPS)
+	}
+
+	// Fast responses push the p95 back under the threshold.
+	var adj Adjustment
+	for range latencyWindow {
+		if a, ok := reg.ObserveLatency("api.com", 10*time.Millisecond); ok {
+			adj = a
+		}
+	}
+	if !adj.Up || reg.Limit("api.com") != 10 {
+		t.Errorf("after recovery: last adjustment %+v, limit %v; want back to 10", adj, reg.Limit("api.com"))
+	}
+}
+
+func TestRegistry_CarryOverKeepsLearntState(t *testing.T) {
+	adaptive := &Adaptive{P95Threshold: 100 * time.Millisecond, MinRPS: 1, DecreaseFactor: 0.5, RecoveryStep: 0.5, Interval: time.Hour}
+	old := NewRegistry(10.0, nil)
+	old.SetAdaptive(adaptive)
+	old.Observe("budget.com", Budget{Remaining: 1, Reset: time.Now().Add(time.Second)})
+	old.RaiseMinInterval("slow.com", 50*time.Millisecond)
+	for range minLatencySamples {
+		old.ObserveLatency("laggy.com", time.Second)
+	}
+
+	// The new registry doubles the default rate, as a reload might.
+	reg := NewRegistry(20.0, nil)
+	reg.SetAdaptive(adaptive)
+	reg.CarryOver(old)
+	if got := reg.Limit("budget.com"); got <= 0 || got > 1.1 {
+		t.Errorf("limit under a carried budget = %v, want about 1", got)
+	}
+	if got := reg.Limit("laggy.com"); got != 10 {
+		t.Errorf("adaptive limit = %v, want half the new 20 rps", got)
+	}
+	if got := reg.Limit("fresh.com"); got != 20 {
+		t.Errorf("untouched domain limit = %v, want the new 20", got)
+	}
+	start := time.Now()
+	for range 2 {
+		_ = reg.Wait(context.Background(), "slow.com")
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("2 requests to slow.com took %v; the raised interval was lost", elapsed)
+	}
+
+	// Without adaptive limiting the latency factor is dropped.
+	plain := NewRegistry(20.0, nil)
+	plain.CarryOver(old)
+	if got := plain.Limit("laggy.com"); got != 20 {
+		t.Errorf("limit with adaptive limiting off = %v, want 20", got)
+	}
+}
+
+func TestPercentile95(t *testing.T) {
+	ds := make([]time.Duration, 100)
+	for i := range ds {
+		ds[len(ds)-1-i] = time.Duration(i+1) * time.Millisecond
+	}
+	if got := percentile95(ds); got != 95*time.Millisecond {
+		t.Errorf("percentile95 = %v, want 95ms", got)
+	}
+	if got := percentile95(ds[:1]); got != 100*time.Millisecond {
+		t.Errorf("percentile95 of one sample = %v, want that sample", got)
+	}
+}
@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Shared is a rate-limit budget kept outside the process, so that several
+// sendit instances together respect one per-domain rate. Reserve takes a
+// token for domain from a bucket refilled at rps with room for burst, or
+// reports how long to wait before trying again without taking one.
+type Shared interface {
+	Reserve(ctx context.Context, domain string, rps float64, burst int) (time.Duration, error)
+}
+
+// RedisOptions configures a RedisLimiter.
+type RedisOptions struct {
+	Address   string // host:port
+	Username  string // for Redis 6 ACLs; "" uses the default user
+	Password  string
+	DB        int
+	TLS       bool
+	KeyPrefix string
+	Timeout   time.Duration // per command, including dialling
+}
+
+// RedisLimiter is a Shared budget held in Redis. Every domain is a GCRA
+// bucket (one key holding the bucket's theoretical arrival time) updated by
+// a Lua script, so instances never race and clock skew between them does
+// not matter: the script reads the time from Redis.
+type RedisLimiter struct {
+	opts RedisOptions
+	idle chan *respConn
+
+	mu       sync.Mutex // guards lastWarn
+	lastWarn time.Time
+}
+
+// maxIdleRedisConns is how many connections a RedisLimiter keeps open
+// between commands.
+const maxIdleRedisConns = 8
+
+// gcraScript admits a request when the bucket's theoretical arrival time
+// (TAT) is at most burst-1 intervals ahead of now, advancing it by one
+// interval; otherwise it returns the microseconds until it would be.
+// ARGV[1] is the interval in microseconds and ARGV[2] the burst.
+const gcraScript = `
+if redis.replicate_commands then redis.replicate_commands() end
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+if tat < now then tat = now end
+local wait = tat + interval - burst * interval - now
+if wait > 0 then return wait end
+redis.call('SET', KEYS[1], string.format('%.0f', tat + interval), 'PX', math.ceil((tat + interval - now) / 1000) + 1)
+return 0
+`
+
+// NewRedisLimiter returns a limiter for the Redis server in opts. It
+// connects lazily, on the first Reserve.
+func NewRedisLimiter(opts RedisOptions) *RedisLimiter {
+	if opts.Timeout <= 0 {
+		opts.Timeout = time.Second
+	}
+	return &RedisLimiter{opts: opts, idle: make(chan *respConn, maxIdleRedisConns)}
+}
+
+// Reserve implements Shared. Errors are also logged, at most once per
+// redisWarnEvery, since the caller carries on without the shared budget.
+func (l *RedisLimiter) Reserve(ctx context.Context, domain string, rps float64, burst int) (time.Duration, error) {
+	if rps <= 0 {
+		return 0, nil
+	}
+	interval := int64(float64(time.Second/time.Microsecond) / rps)
+	reply, err := l.do(ctx, "EVAL", gcraScript, "1", l.opts.KeyPrefix+domain,
+		strconv.FormatInt(max(interval, 1), 10), strconv.Itoa(max(burst, 1)))
+	if err == nil {
+		if _, ok := reply.(int64); !ok {
+			err = fmt.Errorf("redis: unexpected script reply %v", reply)
+		}
+	}
+	if err != nil {
+		l.warn(err)
+		return 0, err
+	}
+	wait := reply.(int64)
+	return time.Duration(wait) * time.Microsecond, nil
+}
+
+// Close closes the idle connections.
+func (l *RedisLimiter) Close() error {
+	for {
+		select {
+		case c := <-l.idle:
+			_ = c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do runs one command on a pooled connection. A connection that fails is
+// discarded; one that returned an error reply is still usable.
+func (l *RedisLimiter) do(ctx context.Context, args ...string) (any, error) {
+	deadline := time.Now().Add(l.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c, err := l.get(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(deadline, args...)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	l.put(c)
+	if e, ok := reply.(respError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+func (l *RedisLimiter) get(ctx context.Context, deadline time.Time) (*respConn, error) {
+	select {
+	case c := <-l.idle:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Deadline: deadline}
+	nc, err := d.DialContext(ctx, "tcp", l.opts.Address)
+	if err != nil {
+		return nil, err
+	}
+	if l.opts.TLS {
+		host, _, _ := net.SplitHostPort(l.opts.Address)
+		nc = tls.Client(nc, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	}
+	c := newRESPConn(nc)
+	var setup [][]string
+	switch {
+	case l.opts.Username != "":
+		setup = append(setup, []string{"AUTH", l.opts.Username, l.opts.Password})
+	case l.opts.Password != "":
+		setup = append(setup, []string{"AUTH", l.opts.Password})
+	}
+	if l.opts.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(l.opts.DB)})
+	}
+	for _, cmd := range setup {
+		reply, err := c.do(deadline, cmd...)
+		if err == nil {
+			if e, ok := reply.(respError); ok {
+				err = e
+			}
+		}
+		if err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("%s: %w", cmd[0], err)
+		}
+	}
+	return c, nil
+}
+
+func (l *RedisLimiter) put(c *respConn) {
+	select {
+	case l.idle <- c:
+	default:
+		_ = c.Close()
+	}
+}
+
+// redisWarnEvery throttles the warning logged while Redis is unreachable.
+const redisWarnEvery = 30 * time.Second
+
+// warn logs a failed Reserve at most once per redisWarnEvery.
+func (l *RedisLimiter) warn(err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.lastWarn) < redisWarnEvery {
+		return
+	}
+	l.lastWarn = time.Now()
+	log.Warn().Err(err).Str("address", l.opts.Address).
+		Msg("shared rate limit unavailable, falling back to local limits")
+}
@@ -0,0 +1,218 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis speaks just enough RESP to stand in for Redis: AUTH, SELECT,
+// and EVAL of gcraScript, which it runs natively.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	tat      map[string]int64 // key → theoretical arrival time, µs
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, password: password, tat: make(map[string]int64)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(newRESPConn(c))
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(c *respConn) {
+	defer c.Close()
+	authed := f.password == ""
+	for {
+		req, err := c.read()
+		if err != nil {
+			return
+		}
+		items, _ := req.([]any)
+		args := make([]string, len(items))
+		for i, it := range items {
+			args[i], _ = it.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		f.mu.Unlock()
+
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] != f.password {
+				reply = "-WRONGPASS invalid password\r\n"
+				break
+			}
+			authed = true
+			reply = "+OK\r\n"
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "EVAL" && len(args) == 6 && args[1] == gcraScript:
+			interval, _ := strconv.ParseInt(args[4], 10, 64)
+			burst, _ := strconv.ParseInt(args[5], 10, 64)
+			reply = fmt.Sprintf(":%d\r\n", f.gcra(args[3], interval, burst))
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		if _, err := c.conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// gcra mirrors gcraScript.
+func (f *fakeRedis) gcra(key string, interval, burst int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now().UnixMicro()
+	tat := max(f.tat[key], now)
+	if wait := tat + interval - burst*interval - now; wait > 0 {
+		return wait
+	}
+	f.tat[key] = tat + interval
+	return 0
+}
+
+func (f *fakeRedis) saw(cmd string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.commands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRedisLimiter_SharesBudgetAcrossInstances(t *testing.T) {
+	srv := newFakeRedis(t, "s3cret")
+	opts := RedisOptions{Address: srv.ln.Addr().String(), Password: "s3cret", DB: 2, KeyPrefix: "sendit:ratelimit:"}
+	a, b := NewRedisLimiter(opts), NewRedisLimiter(opts)
+	defer a.Close()
+	defer b.Close()
+	ctx := context.Background()
+
+	// One request per second with a bucket of two: the instances share it.
+	for i, l := range []*RedisLimiter{a, b} {
+		if wait, err := l.Reserve(ctx, "api.example.com", 1, 2); err != nil || wait != 0 {
+			t.Fatalf("instance %d: Reserve = %v, %v; want 0, nil", i, wait, err)
+		}
+	}
+	wait, err := a.Reserve(ctx, "api.example.com", 1, 2)
+	if err != nil || wait < 500*time.Millisecond || wait > time.Second {
+		t.Errorf("third Reserve = %v, %v; want a wait of just under 1s", wait, err)
+	}
+	if wait, err := b.Reserve(ctx, "other.example.com", 1, 2); err != nil || wait != 0 {
+		t.Errorf("other domain: Reserve = %v, %v; want its own bucket", wait, err)
+	}
+	if !srv.saw("AUTH") || !srv.saw("SELECT") {
+		t.Errorf("commands = %v, want AUTH and SELECT on connect", srv.commands)
+	}
+}
+
+func TestRedisLimiter_AuthFailure(t *testing.T) {
+	srv := newFakeRedis(t, "s3cret")
+	l := NewRedisLimiter(RedisOptions{Address: srv.ln.Addr().String(), Password: "wrong"})
+	defer l.Close()
+
+	_, err := l.Reserve(context.Background(), "api.example.com", 1, 1)
+	var re respError
+	if !errors.As(err, &re) {
+		t.Errorf("Reserve error = %v, want the server's WRONGPASS reply", err)
+	}
+}
+
+func TestRegistry_SharedBudgetHoldsSecondInstance(t *testing.T) {
+	srv := newFakeRedis(t, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Each registry's own bucket is full, so only the shared one can hold
+	// the second request back, by one 100ms interval.
+	var regs []*Registry
+	for range 2 {
+		l := NewRedisLimiter(RedisOptions{Address: srv.ln.Addr().String()})
+		defer l.Close()
+		r := NewRegistry(10, nil)
+		r.SetShared(l)
+		regs = append(regs, r)
+	}
+	if err := regs[0].Wait(ctx, "api.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := regs[1].Wait(ctx, "api.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("second instance waited %v, want about 100ms", d)
+	}
+}
+
+func TestRegistry_SharedBudgetFailsOpen(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close() // nothing listens there any more
+
+	l := NewRedisLimiter(RedisOptions{Address: addr, Timeout: 200 * time.Millisecond})
+	r := NewRegistry(100, nil)
+	r.SetShared(l)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Wait(ctx, "api.example.com"); err != nil {
+		t.Errorf("Wait with Redis down = %v, want the local limiter alone to apply", err)
+	}
+}
+
+func TestRESPRead(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		defer server.Close()
+		server.Write([]byte("*4\r\n+OK\r\n:-7\r\n$-1\r\n$5\r\nhe\r\no\r\n-ERR boom\r\n"))
+	}()
+	c := newRESPConn(client)
+
+	got, err := c.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	items, ok := got.([]any)
+	if !ok || len(items) != 4 || items[0] != "OK" || items[1] != int64(-7) || items[2] != nil || items[3] != "he\r\no" {
+		t.Errorf("array reply = %#v", got)
+	}
+	if got, err := c.read(); err != nil || got != respError("ERR boom") {
+		t.Errorf("error reply = %#v, %v", got, err)
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxCrawlDelay caps the Crawl-delay honoured from robots.txt, so that a
+// misread or hostile value cannot stall a domain indefinitely.
+const MaxCrawlDelay = time.Minute
+
+// ParseCrawlDelay returns the Crawl-delay robots.txt r sets for agent, a
+// product token such as "sendit": that of a User-agent group naming agent,
+// or failing that of the "*" group. Delays are in seconds and may be
+// fractional; they are capped at MaxCrawlDelay. ok is false when no group
+// that applies sets one.
+func ParseCrawlDelay(r io.Reader, agent string) (d time.Duration, ok bool) {
+	agent = strings.ToLower(agent)
+	var (
+		group                 []string // agents of the group being read
+		inRules               bool     // past the group's User-agent lines
+		named, wildcard       time.Duration
+		hasNamed, hasWildcard bool
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, val, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+		switch key {
+		case "user-agent":
+			if inRules {
+				group, inRules = nil, false
+			}
+			group = append(group, strings.ToLower(val))
+		case "crawl-delay":
+			inRules = true
+			secs, err := strconv.ParseFloat(val, 64)
+			if err != nil || secs < 0 {
+				continue
+			}
+			delay := time.Duration(min(secs, MaxCrawlDelay.Seconds()) * float64(time.Second))
+			for _, a := range group {
+				switch {
+				case a == "*":
+					wildcard, hasWildcard = delay, true
+				case a != "" && strings.Contains(agent, a):
+					named, hasNamed = delay, true
+				}
+			}
+		default:
+			inRules = true
+		}
+	}
+	switch {
+	case hasNamed:
+		return named, true
+	case hasWildcard:
+		return wildcard, true
+	}
+	return 0, false
+}
@@ -0,0 +1,34 @@
+package ratelimit
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCrawlDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		robots string
+		want   time.Duration
+		ok     bool
+	}{
+		{"none", "User-agent: *\nDisallow: /private\n", 0, false},
+		{"wildcard", "User-agent: *\nCrawl-delay: 5\n", 5 * time.Second, true},
+		{"fractional", "user-agent: *\ncrawl-delay: 0.5 # half a second\n", 500 * time.Millisecond, true},
+		{"named wins", "User-agent: *\nCrawl-delay: 10\n\nUser-agent: sendit\nCrawl-delay: 2\n", 2 * time.Second, true},
+		{"shared group", "User-agent: googlebot\nUser-agent: sendit\nDisallow: /x\nCrawl-delay: 3\n", 3 * time.Second, true},
+		{"other agent", "User-agent: googlebot\nCrawl-delay: 3\n", 0, false},
+		{"group ends at next agent", "User-agent: *\nDisallow: /\nUser-agent: googlebot\nCrawl-delay: 3\n", 0, false},
+		{"capped", "User-agent: *\nCrawl-delay: 86400\n", MaxCrawlDelay, true},
+		{"malformed", "User-agent: *\nCrawl-delay: soon\n", 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ParseCrawlDelay(strings.NewReader(tc.robots), "sendit")
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("ParseCrawlDelay = %v, %v; want %v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText renders rep as plain-text tables. top limits the error, target,
+// and domain tables to their busiest rows; 0 shows everything.
+func WriteText(w io.Writer, rep Report, top int) error {
+	var b strings.Builder
+	o := rep.Overall
+
+	if rep.Duration() > 0 {
+		fmt.Fprintf(&b, "Period:   %s → %s (%s)\n",
+			rep.From.Format(time.RFC3339), rep.To.Format(time.RFC3339), rep.Duration().Round(time.Second))
+	}
+	fmt.Fprintf(&b, "Requests: %d (%.1f%% errors)", o.Count, o.ErrorRate())
+	if rps := rep.RPS(); rps > 0 {
+		fmt.Fprintf(&b, ", %.3g req/s", rps)
+	}
+	fmt.Fprintf(&b, ", %s received\n", formatBytes(o.Bytes))
+	if rep.Skipped > 0 {
+		fmt.Fprintf(&b, "Skipped:  %d malformed line(s)\n", rep.Skipped)
+	}
+	b.WriteString("\nLatency (ms, successful requests):\n")
+	fmt.Fprintf(&b, "  min %s  avg %s  p50 %s  p90 %s  p95 %s  p99 %s  max %s\n",
+		ms(o, o.Min), ms(o, o.Avg), ms(o, o.P50), ms(o, o.P90), ms(o, o.P95), ms(o, o.P99), ms(o, o.Max))
+
+	if len(rep.Errors) > 0 {
+		b.WriteString("\nErrors:\n")
+		fmt.Fprintf(&b, "  %8s  %6s  %s\n", "COUNT", "SHARE", "REASON")
+		for _, e := range limit(rep.Errors, top) {
+			fmt.Fprintf(&b, "  %8d  %5.1f%%  %s\n", e.Count, float64(e.Count)/float64(o.Count)*100, e.Reason)
+		}
+		more(&b, len(rep.Errors), top)
+	}
+
+	writeStatsTable(&b, "Targets", rep.Targets, top)
+	writeStatsTable(&b, "Domains", rep.Domains, top)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeStatsTable(b *strings.Builder, title string, rows []Stats, top int) {
+	fmt.Fprintf(b, "\n%s (%d):\n", title, len(rows))
+	fmt.Fprintf(b, "  %-40s %8s %7s %8s %8s %8s %8s\n", "NAME", "REQS", "ERR%", "P50", "P95", "P99", "MAX")
+	for _, s := range limit(rows, top) {
+		fmt.Fprintf(b, "  %-40s %8d %6.1f%% %8s %8s %8s %8s\n",
+			s.Name, s.Count, s.ErrorRate(), ms(s, s.P50), ms(s, s.P95), ms(s, s.P99), ms(s, s.Max))
+	}
+	more(b, len(rows), top)
+}
+
+func limit[T any](rows []T, top int) []T {
+	if top > 0 && len(rows) > top {
+		return rows[:top]
+	}
+	return rows
+}
+
+func more(b *strings.Builder, n, top int) {
+	if top > 0 && n > top {
+		fmt.Fprintf(b, "  … %d more (raise --top to see them)\n", n-top)
+	}
+}
+
+// ms formats one of s's latency figures; "-" when no request in s
+// succeeded, so there was nothing to measure.
+func ms(s Stats, v float64) string {
+	if s.Count == s.Errors {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+var htmlTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ms":    ms,
+	"bytes": formatBytes,
+	"pct": func(n, total int) string {
+		if total == 0 {
+			return "0.0%"
+		}
+		return fmt.Sprintf("%.1f%%", float64(n)/float64(total)*100)
+	},
+	"rate": func(s Stats) string { return fmt.Sprintf("%.1f%%", s.ErrorRate()) },
+	"ts":   func(t time.Time) string { return t.Format(time.RFC3339) },
+	"dur":  func(d time.Duration) string { return d.Round(time.Second).String() },
+	"rps":  func(v float64) string { return fmt.Sprintf("%.3g", v) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2rem; }
+th, td { padding: 0.3rem 0.8rem; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { background: #f4f4f4; }
+.err { color: #b00; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{with .Report}}
+<p>
+{{if gt .Duration 0}}{{ts .From}} → {{ts .To}} ({{dur .Duration}})<br>{{end}}
+{{.Overall.Count}} requests, <span class="err">{{rate .Overall}} errors</span>{{if gt .RPS 0.0}}, {{rps .RPS}} req/s{{end}}, {{bytes .Overall.Bytes}} received
+{{if .Skipped}}<br>{{.Skipped}} malformed line(s) skipped{{end}}
+</p>
+<h2>Latency (ms, successful requests)</h2>
+<table>
+<tr><th>min</th><th>avg</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th><th>max</th></tr>
+{{with .Overall}}<tr><td>{{ms . .Min}}</td><td>{{ms . .Avg}}</td><td>{{ms . .P50}}</td><td>{{ms . .P90}}</td><td>{{ms . .P95}}</td><td>{{ms . .P99}}</td><td>{{ms . .Max}}</td></tr>{{end}}
+</table>
+{{if .Errors}}
+<h2>Errors</h2>
+<table>
+<tr><th>Reason</th><th>Count</th><th>Share</th></tr>
+{{$total := .Overall.Count}}{{range .Errors}}<tr><td>{{.Reason}}</td><td>{{.Count}}</td><td>{{pct .Count $total}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range $.Tables}}
+<h2>{{.Title}} ({{len .Rows}})</h2>
+<table>
+<tr><th>Name</th><th>Requests</th><th>Errors</th><th>Bytes</th><th>avg</th><th>p50</th><th>p90</th><th>p95</th><th>p99</th><th>max</th></tr>
+{{range .Rows}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{rate .}}</td><td>{{bytes .Bytes}}</td><td>{{ms . .Avg}}</td><td>{{ms . .P50}}</td><td>{{ms . .P90}}</td><td>{{ms . .P95}}</td><td>{{ms . .P99}}</td><td>{{ms . .Max}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders rep as a standalone HTML page with every row of every
+// table.
+func WriteHTML(w io.Writer, rep Report, title string) error {
+	type table struct {
+		Title string
+		Rows  []Stats
+	}
+	return htmlTmpl.Execute(w, struct {
+		Title  string
+		Report Report
+		Tables []table
+	}{
+		Title:  title,
+		Report: rep,
+		Tables: []table{{"Targets", rep.Targets}, {"Domains", rep.Domains}},
+	})
+}
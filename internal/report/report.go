@@ -0,0 +1,429 @@
+// Package report summarises result files written by the output writer
+// (JSONL or CSV) into latency percentiles, an error breakdown, and
+// per-target and per-domain tables for `sendit report`.
+package report
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lewta/sendit/internal/task"
+)
+
+// maxReasonLen caps the length of an error reason so that long driver
+// errors do not blow out the table width.
+const maxReasonLen = 100
+
+// Record is one result line as written by output.Writer.
+type Record struct {
+	TS         time.Time
+	URL        string
+	Type       string
+	Status     int
+	DurationMs int64
+	Bytes      int64
+	Error      string
+}
+
+// Failed reports whether the request errored or returned a status of 400 or
+// above. Non-HTTP drivers map their outcomes onto HTTP-like codes, so the
+// same rule applies to every type.
+func (r Record) Failed() bool {
+	return r.Error != "" || r.Status >= 400
+}
+
+// reason is the key the record is grouped under in the error breakdown.
+func (r Record) reason() string {
+	if r.Error == "" {
+		return fmt.Sprintf("status %d", r.Status)
+	}
+	if len(r.Error) > maxReasonLen {
+		return r.Error[:maxReasonLen-3] + "..."
+	}
+	return r.Error
+}
+
+// ReadFile parses a JSONL or CSV result file, chosen by the .csv extension.
+// Malformed lines are skipped and counted rather than failing the read, so
+// a file truncated by a crash still produces a report.
+func ReadFile(path string) (records []Record, skipped int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening %q: %w", path, err)
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		records, skipped, err = readCSV(f)
+	} else {
+		records, skipped, err = readJSONL(f)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading %q: %w", path, err)
+	}
+	return records, skipped, nil
+}
+
+func readJSONL(r io.Reader) ([]Record, int, error) {
+	var (
+		records []Record
+		skipped int
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var raw struct {
+			TS         string `json:"ts"`
+			URL        string `json:"url"`
+			Type       string `json:"type"`
+			Status     int    `json:"status"`
+			DurationMs int64  `json:"duration_ms"`
+			Bytes      int64  `json:"bytes"`
+			Error      string `json:"error"`
+		}
+		if err := json.Unmarshal(line, &raw); err != nil || raw.URL == "" {
+			skipped++
+			continue
+		}
+		ts, _ := time.Parse(time.RFC3339, raw.TS)
+		records = append(records, Record{
+			TS: ts, URL: raw.URL, Type: raw.Type, Status: raw.Status,
+			DurationMs: raw.DurationMs, Bytes: raw.Bytes, Error: raw.Error,
+		})
+	}
+	return records, skipped, sc.Err()
+}
+
+func readCSV(r io.Reader) ([]Record, int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	for _, name := range []string{"url", "status", "duration_ms"} {
+		if _, ok := col[name]; !ok {
+			return nil, 0, fmt.Errorf("CSV header is missing the %q column", name)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var (
+		records []Record
+		skipped int
+	)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		status, err1 := strconv.Atoi(field(row, "status"))
+		dur, err2 := strconv.ParseInt(field(row, "duration_ms"), 10, 64)
+		if err1 != nil || err2 != nil || field(row, "url") == "" {
+			skipped++
+			continue
+		}
+		bytes, _ := strconv.ParseInt(field(row, "bytes"), 10, 64)
+		ts, _ := time.Parse(time.RFC3339, field(row, "ts"))
+		records = append(records, Record{
+			TS: ts, URL: field(row, "url"), Type: field(row, "type"), Status: status,
+			DurationMs: dur, Bytes: bytes, Error: field(row, "error"),
+		})
+	}
+	return records, skipped, nil
+}
+
+// Stats aggregates a group of records. Latency figures are in milliseconds
+// and cover successful requests only; they are zero when none succeeded.
+type Stats struct {
+	Name   string
+	Count  int
+	Errors int
+	Bytes  int64
+	Min    float64
+	Avg    float64
+	P50    float64
+	P90    float64
+	P95    float64
+	P99    float64
+	Max    float64
+}
+
+// ErrorRate is the percentage of failed requests.
+func (s Stats) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Count) * 100
+}
+
+// ErrorCount is one row of the error breakdown.
+type ErrorCount struct {
+	Reason string
+	Count  int
+}
+
+// Report is the summary of one or more result files.
+type Report struct {
+	From, To time.Time
+	Skipped  int
+	Overall  Stats
+	Errors   []ErrorCount // most frequent first
+	Targets  []Stats      // by URL, busiest first
+	Domains  []Stats      // by host, busiest first
+}
+
+// Duration is the wall-clock span between the first and last record.
+func (r Report) Duration() time.Duration {
+	if r.From.IsZero() || r.To.IsZero() {
+		return 0
+	}
+	return r.To.Sub(r.From)
+}
+
+// RPS is the average request rate over Duration; zero when the span is
+// unknown or shorter than a second.
+func (r Report) RPS() float64 {
+	d := r.Duration()
+	if d < time.Second {
+		return 0
+	}
+	return float64(r.Overall.Count) / d.Seconds()
+}
+
+// Summarize builds a Report from records.
+func Summarize(records []Record) Report {
+	s := newSummary()
+	for _, r := range records {
+		s.add(r)
+	}
+	return s.report()
+}
+
+// summary accumulates a Report as records are added, without keeping the
+// records themselves.
+type summary struct {
+	from, to time.Time
+	overall  group
+	targets  map[string]*group // by URL
+	domains  map[string]*group // by host
+	errs     map[string]int    // by reason
+}
+
+func newSummary() *summary {
+	return &summary{
+		targets: make(map[string]*group),
+		domains: make(map[string]*group),
+		errs:    make(map[string]int),
+	}
+}
+
+func (s *summary) add(r Record) {
+	if !r.TS.IsZero() {
+		if s.from.IsZero() || r.TS.Before(s.from) {
+			s.from = r.TS
+		}
+		if r.TS.After(s.to) {
+			s.to = r.TS
+		}
+	}
+	s.overall.add(r)
+	addTo(s.targets, r.URL, r)
+	addTo(s.domains, domainOf(r.URL), r)
+	if r.Failed() {
+		s.errs[r.reason()]++
+	}
+}
+
+func (s *summary) report() Report {
+	rep := Report{From: s.from, To: s.to, Overall: s.overall.stats("total")}
+	rep.Targets = groupStats(s.targets)
+	rep.Domains = groupStats(s.domains)
+	for reason, n := range s.errs {
+		rep.Errors = append(rep.Errors, ErrorCount{Reason: reason, Count: n})
+	}
+	slices.SortFunc(rep.Errors, func(a, b ErrorCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Reason, b.Reason)
+	})
+	return rep
+}
+
+func addTo(groups map[string]*group, name string, r Record) {
+	g, ok := groups[name]
+	if !ok {
+		g = &group{}
+		groups[name] = g
+	}
+	g.add(r)
+}
+
+func groupStats(groups map[string]*group) []Stats {
+	out := make([]Stats, 0, len(groups))
+	for name, g := range groups {
+		out = append(out, g.stats(name))
+	}
+	slices.SortFunc(out, func(a, b Stats) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out
+}
+
+// group accumulates the Stats of one group of records. Successful
+// latencies are counted per whole millisecond, the resolution of
+// DurationMs, so percentiles stay exact while memory grows with the spread
+// of latencies rather than with the number of records.
+type group struct {
+	count, errors int
+	bytes         int64
+	ok            int           // successful records
+	sumMs         float64       // of successful records
+	latency       map[int64]int // successful records by DurationMs
+}
+
+func (g *group) add(r Record) {
+	g.count++
+	g.bytes += r.Bytes
+	if r.Failed() {
+		g.errors++
+		return
+	}
+	if g.latency == nil {
+		g.latency = make(map[int64]int)
+	}
+	g.latency[r.DurationMs]++
+	g.ok++
+	g.sumMs += float64(r.DurationMs)
+}
+
+func (g *group) stats(name string) Stats {
+	s := Stats{Name: name, Count: g.count, Errors: g.errors, Bytes: g.bytes}
+	if g.ok == 0 {
+		return s
+	}
+	q := g.percentiles(0, 50, 90, 95, 99, 100)
+	s.Min, s.P50, s.P90, s.P95, s.P99, s.Max = q[0], q[1], q[2], q[3], q[4], q[5]
+	s.Avg = g.sumMs / float64(g.ok)
+	return s
+}
+
+// percentiles returns the nearest-rank percentiles ps of the successful
+// latencies; 0 is the fastest and 100 the slowest. g must have at least one
+// successful record.
+func (g *group) percentiles(ps ...float64) []float64 {
+	keys := slices.Sorted(maps.Keys(g.latency))
+	out := make([]float64, len(ps))
+	for i, p := range ps {
+		rank := max(int(math.Ceil(p/100*float64(g.ok))), 1)
+		seen := 0
+		for _, k := range keys {
+			if seen += g.latency[k]; seen >= rank {
+				out[i] = float64(k)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// domainOf returns the host of a target URL. DNS targets are bare hostnames
+// and gRPC/SFTP targets may be host:port, so anything without a scheme is
+// treated as a host itself.
+func domainOf(target string) string {
+	if strings.Contains(target, "://") {
+		if u, err := url.Parse(target); err == nil && u.Hostname() != "" {
+			return strings.ToLower(u.Hostname())
+		}
+		return target
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return strings.ToLower(host)
+	}
+	return strings.ToLower(target)
+}
+
+// Collector summarises results as they arrive so a report can be printed
+// at the end of a run. It keeps running totals and latency counts rather
+// than the results, so its memory does not grow with the length of the
+// run. It is safe for concurrent use; the zero value is ready to use.
+type Collector struct {
+	mu sync.Mutex
+	s  *summary
+}
+
+// Record adds r; its signature matches engine.Engine.SetObserver.
+func (c *Collector) Record(r task.Result) {
+	rec := Record{
+		TS:         time.Now().UTC(),
+		URL:        r.Task.URL,
+		Type:       r.Task.Type,
+		Status:     r.StatusCode,
+		DurationMs: r.Duration.Milliseconds(),
+		Bytes:      r.BytesRead,
+	}
+	if r.Error != nil {
+		rec.Error = r.Error.Error()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summary().add(rec)
+}
+
+// Report summarises everything recorded so far.
+func (c *Collector) Report() Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.summary().report()
+}
+
+// summary returns c.s, creating it on first use. The caller holds c.mu.
+func (c *Collector) summary() *summary {
+	if c.s == nil {
+		c.s = newSummary()
+	}
+	return c.s
+}
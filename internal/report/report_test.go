@@ -0,0 +1,213 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/task"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestReadFile_JSONL(t *testing.T) {
+	p := writeFile(t, "r.jsonl", `{"ts":"2026-10-14T12:00:00Z","url":"https://a.example/x","type":"http","status":200,"duration_ms":12,"bytes":100}
+not json
+{"ts":"2026-10-14T12:00:05Z","url":"example.com","type":"dns","status":0,"duration_ms":3,"bytes":0,"error":"timeout","dns_ms":"1.0"}
+
+`)
+	recs, skipped, err := ReadFile(p)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(recs) != 2 || skipped != 1 {
+		t.Fatalf("got %d records, %d skipped; want 2, 1", len(recs), skipped)
+	}
+	if recs[0].Status != 200 || recs[0].DurationMs != 12 || !recs[0].TS.Equal(time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("first record = %+v", recs[0])
+	}
+	if recs[1].Error != "timeout" || !recs[1].Failed() {
+		t.Errorf("second record = %+v", recs[1])
+	}
+}
+
+func TestReadFile_CSV(t *testing.T) {
+	p := writeFile(t, "r.csv", `ts,url,type,status,duration_ms,bytes,error
+2026-10-14T12:00:00Z,https://a.example/x,http,503,40,10,
+2026-10-14T12:00:01Z,https://a.example/x,http,oops,40,10,
+2026-10-14T12:00:02Z,https://b.example/,http,200,20,10,
+`)
+	recs, skipped, err := ReadFile(p)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if len(recs) != 2 || skipped != 1 {
+		t.Fatalf("got %d records, %d skipped; want 2, 1", len(recs), skipped)
+	}
+	if recs[0].Status != 503 || !recs[0].Failed() || recs[1].URL != "https://b.example/" {
+		t.Errorf("records = %+v", recs)
+	}
+}
+
+func TestReadFile_CSVMissingColumn(t *testing.T) {
+	p := writeFile(t, "r.csv", "ts,url\n2026-10-14T12:00:00Z,https://a.example/\n")
+	if _, _, err := ReadFile(p); err == nil || !strings.Contains(err.Error(), `missing the "status" column`) {
+		t.Errorf("expected missing column error, got %v", err)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	base := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	var recs []Record
+	for i := 1; i <= 100; i++ {
+		recs = append(recs, Record{TS: base.Add(time.Duration(i) * time.Second), URL: "https://a.example/x", Status: 200, DurationMs: int64(i), Bytes: 10})
+	}
+	recs = append(recs,
+		Record{TS: base, URL: "https://A.example:8443/y", Status: 503, DurationMs: 5000},
+		Record{TS: base, URL: "b.example", Error: "i/o timeout"},
+		Record{TS: base, URL: "b.example", Error: "i/o timeout"},
+	)
+
+	rep := Summarize(recs)
+	o := rep.Overall
+	if o.Count != 103 || o.Errors != 3 || o.Bytes != 1000 {
+		t.Errorf("overall = %+v", o)
+	}
+	if o.Min != 1 || o.P50 != 50 || o.P90 != 90 || o.P99 != 99 || o.Max != 100 || o.Avg != 50.5 {
+		t.Errorf("latency = %+v", o)
+	}
+	if rep.Duration() != 100*time.Second {
+		t.Errorf("Duration = %s", rep.Duration())
+	}
+	if len(rep.Errors) != 2 || rep.Errors[0] != (ErrorCount{"i/o timeout", 2}) || rep.Errors[1] != (ErrorCount{"status 503", 1}) {
+		t.Errorf("errors = %+v", rep.Errors)
+	}
+	if len(rep.Targets) != 3 || rep.Targets[0].Name != "https://a.example/x" {
+		t.Errorf("targets = %+v", rep.Targets)
+	}
+	if len(rep.Domains) != 2 || rep.Domains[0].Name != "a.example" || rep.Domains[0].Count != 101 {
+		t.Errorf("domains = %+v", rep.Domains)
+	}
+	if b := rep.Domains[1]; b.Name != "b.example" || b.ErrorRate() != 100 || b.P50 != 0 {
+		t.Errorf("b.example = %+v", b)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	rep := Summarize([]Record{
+		{URL: "https://a.example/", Status: 200, DurationMs: 10},
+		{URL: "https://b.example/", Status: 200, DurationMs: 20},
+		{URL: "https://c.example/", Error: "refused"},
+	})
+	var buf bytes.Buffer
+	if err := WriteText(&buf, rep, 2); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"Requests: 3 (33.3% errors)", "p50 10", "refused", "1 more", "Domains (3)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHTML_EscapesValues(t *testing.T) {
+	rep := Summarize([]Record{{URL: "https://a.example/?q=<script>", Error: "<b>bad</b>"}})
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, rep, "t"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<script>") || strings.Contains(out, "<b>bad") {
+		t.Errorf("unescaped values in HTML:\n%s", out)
+	}
+	if !strings.Contains(out, "<h2>Targets (1)</h2>") {
+		t.Errorf("missing targets table:\n%s", out)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	var c Collector
+	c.Record(task.Result{Task: task.Task{URL: "https://a.example/", Type: "http"}, StatusCode: 200, Duration: 15 * time.Millisecond, BytesRead: 7})
+	c.Record(task.Result{Task: task.Task{URL: "https://a.example/", Type: "http"}, Error: errors.New("refused")})
+
+	rep := c.Report()
+	if rep.Overall.Count != 2 || rep.Overall.Errors != 1 || rep.Overall.P50 != 15 || rep.Overall.Bytes != 7 {
+		t.Errorf("overall = %+v", rep.Overall)
+	}
+	if len(rep.Errors) != 1 || rep.Errors[0].Reason != "refused" {
+		t.Errorf("errors = %+v", rep.Errors)
+	}
+}
+
+func TestCollector_MemoryBoundedByLatencySpread(t *testing.T) {
+	var c Collector
+	for i := range 10000 {
+		c.Record(task.Result{Task: task.Task{URL: "https://a.example/", Type: "http"}, StatusCode: 200, Duration: time.Duration(1+i%3) * time.Millisecond})
+	}
+	if n := len(c.s.overall.latency); n != 3 {
+		t.Errorf("kept %d latency buckets for 3 distinct latencies", n)
+	}
+	if rep := c.Report(); rep.Overall.Count != 10000 || rep.Overall.Min != 1 || rep.Overall.P50 != 2 || rep.Overall.Max != 3 {
+		t.Errorf("overall = %+v", rep.Overall)
+	}
+}
+
+func TestEvaluateSLO(t *testing.T) {
+	var recs []Record
+	for i := 1; i <= 100; i++ {
+		recs = append(recs, Record{URL: "https://a.example/", Status: 200, DurationMs: int64(i)})
+	}
+	recs = append(recs, Record{URL: "https://b.example/", Error: "refused"})
+
+	objs := EvaluateSLO(recs, config.SLOConfig{
+		AvailabilityPct: 99,
+		Latency:         []config.LatencyObjective{{Percentile: 95, MaxMs: 95}, {Percentile: 99, MaxMs: 50}},
+		Targets: []config.TargetSLOConfig{
+			{URL: "https://b.example/", AvailabilityPct: 50, Latency: []config.LatencyObjective{{Percentile: 50, MaxMs: 10}}},
+			{URL: "https://c.example/", AvailabilityPct: 50},
+		},
+	})
+	want := []struct {
+		name, actual string
+		met          bool
+	}{
+		{"availability", "99.01%", true},
+		{"p95 latency", "95ms", true},
+		{"p99 latency", "99ms", false},
+		{"availability", "0.00%", false},
+		{"p50 latency", "no requests", false},
+		{"availability", "no requests", false},
+	}
+	if len(objs) != len(want) {
+		t.Fatalf("got %d objectives, want %d: %+v", len(objs), len(want), objs)
+	}
+	for i, w := range want {
+		if o := objs[i]; o.Name != w.name || o.Actual != w.actual || o.Met != w.met {
+			t.Errorf("objective %d = %+v, want %+v", i, o, w)
+		}
+	}
+	if n := len(Violations(objs)); n != 4 {
+		t.Errorf("Violations = %d, want 4", n)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSLO(&buf, objs); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "SLO (6 objectives, 4 missed)") || !strings.Contains(out, "FAIL") {
+		t.Errorf("unexpected SLO table:\n%s", out)
+	}
+}
@@ -0,0 +1,101 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lewta/sendit/internal/config"
+)
+
+// Objective is the outcome of one SLO objective.
+type Objective struct {
+	Scope  string // "all requests" or a target URL
+	Name   string // e.g. "availability" or "p99 latency"
+	Target string // the objective, e.g. ">= 99.9%"
+	Actual string // the measured value, e.g. "99.95%"
+	Met    bool
+}
+
+// EvaluateSLO checks records against slo and returns one Objective per
+// configured objective, global ones first. Availability is the share of
+// requests that did not fail; latency percentiles are taken over successful
+// requests, as in the rest of the report. An objective with no requests to
+// measure is not met.
+func EvaluateSLO(records []Record, slo config.SLOConfig) []Objective {
+	s := newSummary()
+	for _, r := range records {
+		s.add(r)
+	}
+	return s.objectives(slo)
+}
+
+func (s *summary) objectives(slo config.SLOConfig) []Objective {
+	var out []Objective
+	out = appendObjectives(out, "all requests", &s.overall, slo.AvailabilityPct, slo.Latency)
+	for _, t := range slo.Targets {
+		g := s.targets[t.URL]
+		if g == nil {
+			g = &group{}
+		}
+		out = appendObjectives(out, t.URL, g, t.AvailabilityPct, t.Latency)
+	}
+	return out
+}
+
+func appendObjectives(out []Objective, scope string, g *group, availabilityPct float64, latency []config.LatencyObjective) []Objective {
+	if availabilityPct > 0 {
+		o := Objective{Scope: scope, Name: "availability", Target: fmt.Sprintf(">= %g%%", availabilityPct), Actual: "no requests"}
+		if g.count > 0 {
+			got := float64(g.count-g.errors) / float64(g.count) * 100
+			o.Actual = fmt.Sprintf("%.2f%%", got)
+			o.Met = got >= availabilityPct
+		}
+		out = append(out, o)
+	}
+	for _, l := range latency {
+		o := Objective{Scope: scope, Name: fmt.Sprintf("p%g latency", l.Percentile), Target: fmt.Sprintf("<= %dms", l.MaxMs), Actual: "no requests"}
+		if g.ok > 0 {
+			got := g.percentiles(l.Percentile)[0]
+			o.Actual = fmt.Sprintf("%.0fms", got)
+			o.Met = got <= float64(l.MaxMs)
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+// Violations returns the objectives in objs that were not met.
+func Violations(objs []Objective) []Objective {
+	var out []Objective
+	for _, o := range objs {
+		if !o.Met {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// WriteSLO renders objs as a plain-text table headed like the WriteText
+// sections.
+func WriteSLO(w io.Writer, objs []Objective) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nSLO (%d objectives, %d missed):\n", len(objs), len(Violations(objs)))
+	fmt.Fprintf(&b, "  %-4s  %-40s %-16s %-10s %s\n", "", "SCOPE", "OBJECTIVE", "TARGET", "ACTUAL")
+	for _, o := range objs {
+		mark := "PASS"
+		if !o.Met {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&b, "  %-4s  %-40s %-16s %-10s %s\n", mark, o.Scope, o.Name, o.Target, o.Actual)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CheckSLO evaluates everything recorded so far against slo.
+func (c *Collector) CheckSLO(slo config.SLOConfig) []Objective {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.summary().objectives(slo)
+}
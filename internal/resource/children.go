@@ -0,0 +1,34 @@
+package resource
+
+import (
+	"os"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// ChildMemoryMB returns the resident memory, in MB, of every process
+// descended from this one: the Chrome instances the browser driver has
+// started, and their renderers. Memory shared between them is counted once
+// per process, so the total errs high.
+func ChildMemoryMB() (uint64, error) {
+	self, err := process.NewProcess(int32(os.Getpid())) //nolint:gosec // PIDs fit in int32
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	var walk func(p *process.Process)
+	walk = func(p *process.Process) {
+		children, err := p.Children()
+		if err != nil {
+			return // none, or exited since
+		}
+		for _, c := range children {
+			if mi, err := c.MemoryInfo(); err == nil {
+				total += mi.RSS
+			}
+			walk(c)
+		}
+	}
+	walk(self)
+	return total / (1024 * 1024), nil
+}
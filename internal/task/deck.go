@@ -0,0 +1,144 @@
+package task
+
+import (
+	"math/rand"
+	"time"
+)
+
+// deck deals target indices from shuffled decks. Each deck holds about one
+// card per target, and at least minDeck, a target getting cards in
+// proportion to its weight; the
+// fraction of a card a target is owed carries over to the next deck, so a
+// light target still comes up at its long-run rate. Within a deck every
+// target appears within one card of its share, which bounds the runs and
+// droughts that independent weighted draws produce, and the same target is
+// not dealt twice in a row while any other is left.
+type deck struct {
+	share  []float64 // cards owed per deck, by target
+	credit []float64 // fractional cards carried over
+	cards  []int
+	pos    int
+	last   int // target dealt most recently, -1 before the first
+}
+
+// minDeck is the smallest deck dealt. Tiny decks are often impossible to
+// lay out without a target following itself across the deck boundary.
+const minDeck = 20
+
+func newDeck(weights []float64) *deck {
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	d := &deck{
+		share:  make([]float64, len(weights)),
+		credit: make([]float64, len(weights)),
+		last:   -1,
+	}
+	size := float64(max(len(weights), minDeck))
+	for i, w := range weights {
+		d.share[i] = w * size / total
+	}
+	return d
+}
+
+// deal returns the next card. With recent set it deals the first card left
+// in the deck for which recent is false or, if there is none, the one whose
+// lastPicked is earliest, keeping the rest in order.
+func (d *deck) deal(recent func(int) bool, lastPicked func(int) time.Time) int {
+	if d.pos == len(d.cards) {
+		d.refill()
+	}
+	if recent != nil && recent(d.cards[d.pos]) {
+		best := d.pos
+		for j := d.pos + 1; j < len(d.cards); j++ {
+			if !recent(d.cards[j]) {
+				best = j
+				break
+			}
+			if lastPicked(d.cards[j]).Before(lastPicked(d.cards[best])) {
+				best = j
+			}
+		}
+		d.cards[d.pos], d.cards[best] = d.cards[best], d.cards[d.pos]
+	}
+	d.last = d.cards[d.pos]
+	d.pos++
+	return d.last
+}
+
+// refill builds the next deck. Cards are laid out in random order, except
+// that a target never follows itself unless only its cards are left.
+func (d *deck) refill() {
+	counts := make([]int, len(d.share))
+	left := 0
+	for left == 0 {
+		for i, sh := range d.share {
+			d.credit[i] += sh
+			n := int(d.credit[i])
+			d.credit[i] -= float64(n)
+			counts[i] += n
+			left += n
+		}
+	}
+
+	d.cards, d.pos = d.cards[:0], 0
+	prev := d.last
+	for ; left > 0; left-- {
+		c := nextCard(counts, left, prev)
+		counts[c]--
+		d.cards = append(d.cards, c)
+		prev = c
+	}
+}
+
+// nextCard picks one of the left cards, counted by target in counts, to
+// follow prev: a random card of another target, unless taking it would
+// force two cards of one target together later, in which case the target
+// with the most cards left.
+func nextCard(counts []int, left, prev int) int {
+	pool := left
+	if prev >= 0 {
+		pool -= counts[prev]
+	}
+	if pool == 0 {
+		return prev
+	}
+	c, r := -1, rand.Intn(pool) //nolint:gosec
+	for i, n := range counts {
+		if i == prev {
+			continue
+		}
+		if r < n {
+			c = i
+			break
+		}
+		r -= n
+	}
+	if !arrangeable(counts, left, c) {
+		c = -1
+		for i, n := range counts {
+			if i != prev && n > 0 && (c < 0 || n > counts[c]) {
+				c = i
+			}
+		}
+	}
+	return c
+}
+
+// arrangeable reports whether, after taking a card of target c, the
+// remaining cards can still be laid out with no target twice in a row.
+func arrangeable(counts []int, left, c int) bool {
+	rest := left - 1
+	for i, n := range counts {
+		if i == c {
+			n--
+			if n > rest/2 { // cannot lead, so needs a gap before each card
+				return false
+			}
+		} else if n > (rest+1)/2 {
+			return false
+		}
+	}
+	return true
+}
@@ -13,7 +13,7 @@ func makeTargets(n int) []config.TargetConfig {
 		targets[i] = config.TargetConfig{
 			URL:    fmt.Sprintf("http://example%d.com", i),
 			Type:   "http",
-			Weight: i + 1,
+			Weight: float64(i + 1),
 		}
 	}
 	return targets
@@ -3,9 +3,13 @@ package task
 import (
 	"fmt"
 	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lewta/sendit/internal/config"
+	"github.com/lewta/sendit/internal/ratelimit"
 )
 
 // Task is a single unit of work dispatched to a driver.
@@ -20,17 +24,101 @@ type Result struct {
 	Task       Task
 	StatusCode int
 	Duration   time.Duration
-	BytesRead  int64
-	Error      error
-	Meta       map[string]string
+	BytesRead  int64 // as received, before any Content-Encoding is removed
+	// DecodedBytes is the size of the body once its Content-Encoding is
+	// removed, for drivers that decode it; 0 when unknown.
+	DecodedBytes int64
+	// Protocol is the HTTP version a response came over, such as "HTTP/1.1"
+	// or "HTTP/2"; empty for other drivers and when no response arrived.
+	Protocol string
+	// AltSvc lists the protocol IDs, such as "h3", that the response's
+	// Alt-Svc header advertises, comma-separated; empty when it has none.
+	AltSvc string
+	// Redirects are the responses an http request was redirected by, in the
+	// order they were followed; the last response is not among them.
+	Redirects []Hop
+	// ConsoleErrors and FailedRequests count, for browser tasks, the
+	// JavaScript errors the page logged or threw and the requests it made
+	// that failed or got a status of 400 or above. ConsoleError is the
+	// first error's message.
+	ConsoleErrors  int
+	FailedRequests int
+	ConsoleError   string
+	Error          error
+	Meta           map[string]string
+	// RateLimit is the budget advertised by the server's rate-limit
+	// headers, or nil when the response carried none.
+	RateLimit *ratelimit.Budget
+	// RunID identifies the engine run that produced the result; the engine
+	// sets it after the driver returns.
+	RunID string
+	// Retry is how many times the engine had sent this task before this
+	// result; 0 for the first attempt.
+	Retry int
+	// Echo summarises the round trips of a websocket target with
+	// measure_echo set; nil otherwise.
+	Echo *EchoStats
+	// Aborted reports that the driver cancelled the request mid-flight, as
+	// abort_probability asked; StatusCode is 0 when no response had
+	// arrived. An aborted request has no Error.
+	Aborted bool
+}
+
+// EchoStats are the round-trip times of the messages a websocket
+// connection sent and had echoed back.
+type EchoStats struct {
+	Sent   int // messages sent
+	Echoed int // of which echoed back before the read deadline
+	P50    time.Duration
+	P95    time.Duration
+}
+
+// Hop is one redirecting response in Result.Redirects.
+type Hop struct {
+	URL        string
+	StatusCode int
+}
+
+// Slow reports whether the request got a response, of any status, but took
+// longer than its target's latency_budget_ms.
+func (r Result) Slow() bool {
+	budget := r.Task.Config.LatencyBudgetMs
+	return budget > 0 && r.Error == nil && r.Duration > time.Duration(budget)*time.Millisecond
+}
+
+// Broken reports whether a browser page loaded but logged JavaScript errors
+// or had requests fail.
+func (r Result) Broken() bool {
+	return r.Error == nil && r.StatusCode < 400 && (r.ConsoleErrors > 0 || r.FailedRequests > 0)
+}
+
+// AdvertisesH3 reports whether the response's Alt-Svc header offers HTTP/3,
+// under its final ("h3") or a draft ("h3-29") protocol ID.
+func (r Result) AdvertisesH3() bool {
+	for _, id := range strings.Split(r.AltSvc, ",") {
+		if id == "h3" || strings.HasPrefix(id, "h3-") {
+			return true
+		}
+	}
+	return false
 }
 
 // Selector picks tasks by weight using the Vose alias method for O(1) selection.
 type Selector struct {
 	targets []config.TargetConfig
-	alias   []int
+	weights []float64
+	domains []string // hostname of each target
+	alias   []int32
 	prob    []float64
 	n       int
+
+	// spacing, when set, keeps the same domain from being picked twice
+	// within it; see SetDomainSpacing. deck, when set, replaces independent
+	// draws; see UseDeck.
+	spacing  time.Duration
+	mu       sync.Mutex // guards lastPick and deck
+	lastPick map[string]time.Time
+	deck     *deck
 }
 
 // NewSelector builds the alias table from the target list.
@@ -41,74 +129,104 @@ func NewSelector(targets []config.TargetConfig) (*Selector, error) {
 		return nil, fmt.Errorf("selector requires at least one target")
 	}
 
-	totalWeight := 0
-	for _, t := range targets {
-		totalWeight += t.Weight
+	weights, err := config.EffectiveWeights(targets)
+	if err != nil {
+		return nil, err
+	}
+	totalWeight := 0.0
+	for _, w := range weights {
+		totalWeight += w
 	}
 	if totalWeight <= 0 {
 		return nil, fmt.Errorf("total weight must be > 0")
 	}
 
+	// Scaled probabilities so each slot has expected value 1. The table is
+	// built in place: prob starts as the scaled weights, and small and large
+	// share one work list, filled from either end, so that building a
+	// selector over millions of targets needs no per-target scratch space
+	// beyond it.
 	prob := make([]float64, n)
-	alias := make([]int, n)
-
-	// Scaled probabilities so each slot has expected value 1.
-	scaled := make([]float64, n)
-	for i, t := range targets {
-		scaled[i] = float64(t.Weight) * float64(n) / float64(totalWeight)
+	for i, w := range weights {
+		prob[i] = w * float64(n) / totalWeight
 	}
+	alias := make([]int32, n)
 
-	small := make([]int, 0, n)
-	large := make([]int, 0, n)
+	work := make([]int32, n)
+	nSmall, nLarge := 0, 0
+	pushSmall := func(i int32) { work[nSmall] = i; nSmall++ }
+	pushLarge := func(i int32) { nLarge++; work[n-nLarge] = i }
 
-	for i, p := range scaled {
+	for i, p := range prob {
 		if p < 1.0 {
-			small = append(small, i)
+			pushSmall(int32(i))
 		} else {
-			large = append(large, i)
+			pushLarge(int32(i))
 		}
 	}
 
-	for len(small) > 0 && len(large) > 0 {
-		l := small[len(small)-1]
-		small = small[:len(small)-1]
-		g := large[len(large)-1]
-		large = large[:len(large)-1]
+	for nSmall > 0 && nLarge > 0 {
+		nSmall--
+		l := work[nSmall]
+		g := work[n-nLarge]
+		nLarge--
 
-		prob[l] = scaled[l]
 		alias[l] = g
-		scaled[g] = (scaled[g] + scaled[l]) - 1.0
+		prob[g] = (prob[g] + prob[l]) - 1.0
 
-		if scaled[g] < 1.0 {
-			small = append(small, g)
+		if prob[g] < 1.0 {
+			pushSmall(g)
 		} else {
-			large = append(large, g)
+			pushLarge(g)
 		}
 	}
 
-	for _, g := range large {
-		prob[g] = 1.0
+	for _, i := range work[:nSmall] {
+		prob[i] = 1.0
 	}
-	for _, l := range small {
-		prob[l] = 1.0
+	for _, i := range work[n-nLarge:] {
+		prob[i] = 1.0
+	}
+
+	domains := make([]string, n)
+	for i, t := range targets {
+		domains[i] = hostname(t.URL)
 	}
 
 	return &Selector{
 		targets: targets,
+		weights: weights,
+		domains: domains,
 		alias:   alias,
 		prob:    prob,
 		n:       n,
 	}, nil
 }
 
+// SetDomainSpacing makes Pick avoid returning a target whose domain was
+// picked less than d ago, so that weighted random selection does not send
+// several requests in a row to one site. d <= 0 turns spacing off. Call it
+// before the selector is used.
+func (s *Selector) SetDomainSpacing(d time.Duration) {
+	s.spacing = d
+	s.lastPick = make(map[string]time.Time)
+}
+
+// UseDeck makes Pick deal targets from shuffled decks instead of drawing
+// each one independently; see deck. Call it before the selector is used.
+func (s *Selector) UseDeck() {
+	s.deck = newDeck(s.weights)
+}
+
 // Pick selects a target with probability proportional to its weight.
+// With domain spacing set, targets on recently picked domains are passed
+// over while others are available.
 func (s *Selector) Pick() Task {
-	i := rand.Intn(s.n) //nolint:gosec
 	var idx int
-	if rand.Float64() < s.prob[i] { //nolint:gosec
-		idx = i
+	if s.deck == nil && s.spacing <= 0 {
+		idx = s.pick()
 	} else {
-		idx = s.alias[i]
+		idx = s.next(time.Now())
 	}
 	t := s.targets[idx]
 	return Task{
@@ -117,3 +235,98 @@ func (s *Selector) Pick() Task {
 		Config: t,
 	}
 }
+
+// pick draws a target index from the alias table.
+func (s *Selector) pick() int {
+	i := rand.Intn(s.n)             //nolint:gosec
+	if rand.Float64() < s.prob[i] { //nolint:gosec
+		return i
+	}
+	return int(s.alias[i])
+}
+
+// maxRepicks is how many fresh draws spaced makes before falling back to
+// scanning the targets.
+const maxRepicks = 8
+
+// next picks a target from the deck or the alias table, taking domain
+// spacing into account.
+func (s *Selector) next(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recent func(int) bool
+	lastPicked := func(i int) time.Time { return s.lastPick[s.domains[i]] }
+	if s.spacing > 0 {
+		recent = func(i int) bool {
+			last, ok := s.lastPick[s.domains[i]]
+			return ok && now.Sub(last) < s.spacing
+		}
+	}
+	var idx int
+	if s.deck != nil {
+		idx = s.deck.deal(recent, lastPicked)
+	} else {
+		idx = s.spaced(recent)
+	}
+	if recent != nil {
+		s.lastPick[s.domains[idx]] = now
+	}
+	return idx
+}
+
+// spaced draws a target on a domain that is not recent: first by drawing
+// and redrawing, then by a weighted draw over the eligible targets. When
+// every domain is recent it defers to the one picked longest ago.
+func (s *Selector) spaced(recent func(int) bool) int {
+	idx := s.pick()
+	for try := 0; recent(idx) && try < maxRepicks; try++ {
+		idx = s.pick()
+	}
+	if recent(idx) {
+		idx = s.eligible(recent)
+	}
+	return idx
+}
+
+// eligible draws by weight among the targets whose domain is not recent,
+// or returns the target whose domain was picked longest ago if none is.
+func (s *Selector) eligible(recent func(int) bool) int {
+	total := 0.0
+	for i, w := range s.weights {
+		if !recent(i) {
+			total += w
+		}
+	}
+	if total > 0 {
+		r := rand.Float64() * total //nolint:gosec
+		last := -1
+		for i, w := range s.weights {
+			if recent(i) || w <= 0 {
+				continue
+			}
+			if last = i; r < w {
+				return i
+			}
+			r -= w
+		}
+		return last
+	}
+	oldest := -1
+	for i, d := range s.domains {
+		if s.weights[i] > 0 && (oldest < 0 || s.lastPick[d].Before(s.lastPick[s.domains[oldest]])) {
+			oldest = i
+		}
+	}
+	return oldest
+}
+
+// hostname returns the host of a target URL; bare hostnames (DNS targets)
+// are returned as-is.
+func hostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return rawURL
+	}
+	return u.Hostname()
+}
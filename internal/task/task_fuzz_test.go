@@ -27,7 +27,7 @@ func FuzzSelector(f *testing.F) {
 			targets[i] = config.TargetConfig{
 				URL:    "http://example.com",
 				Type:   "http",
-				Weight: int(b),
+				Weight: float64(b),
 			}
 		}
 		sel, err := NewSelector(targets)
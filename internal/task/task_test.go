@@ -3,11 +3,12 @@ package task
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/lewta/sendit/internal/config"
 )
 
-func makeTarget(url string, weight int, typ string) config.TargetConfig {
+func makeTarget(url string, weight float64, typ string) config.TargetConfig {
 	return config.TargetConfig{URL: url, Weight: weight, Type: typ}
 }
 
@@ -169,3 +170,141 @@ func TestPick_ConcurrentSafety(t *testing.T) {
 		<-done
 	}
 }
+
+// TestPick_SharesAndFractionalWeights verifies that a fixed share is honoured
+// and the remainder is split by (fractional) weight.
+func TestPick_SharesAndFractionalWeights(t *testing.T) {
+	a := makeTarget("https://a.com", 0, "http")
+	a.Share = 50
+	targets := []config.TargetConfig{
+		a,                                        // 50%
+		makeTarget("https://b.com", 0.5, "http"), // 50% × 0.25
+		makeTarget("https://c.com", 1.5, "http"), // 50% × 0.75
+	}
+	sel, err := NewSelector(targets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const iterations = 10_000
+	counts := make(map[string]int, 3)
+	for i := 0; i < iterations; i++ {
+		counts[sel.Pick().URL]++
+	}
+	expected := map[string]float64{
+		"https://a.com": 0.50,
+		"https://b.com": 0.125,
+		"https://c.com": 0.375,
+	}
+	const tol = 0.05
+	for url, want := range expected {
+		got := float64(counts[url]) / float64(iterations)
+		if math.Abs(got-want) > tol {
+			t.Errorf("URL %s: frequency = %.3f, want %.3f ± %.3f", url, got, want, tol)
+		}
+	}
+}
+
+// TestPick_DomainSpacing verifies that, with spacing set, a domain is not
+// picked again while other domains are still outside the window.
+func TestPick_DomainSpacing(t *testing.T) {
+	targets := []config.TargetConfig{
+		makeTarget("https://a.com/1", 5, "http"),
+		makeTarget("https://a.com/2", 5, "http"), // same domain as a.com/1
+		makeTarget("https://b.com", 1, "http"),
+		makeTarget("c.com", 1, "dns"),
+	}
+	sel, err := NewSelector(targets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sel.SetDomainSpacing(time.Hour)
+
+	// Within the window every domain is recent after three picks, so the
+	// selector must cycle through them, least recently picked first.
+	var prev string
+	seen := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		d := hostname(sel.Pick().URL)
+		if d == prev {
+			t.Fatalf("pick %d: %s picked twice in a row", i, d)
+		}
+		prev = d
+		seen[d]++
+	}
+	for _, d := range []string{"a.com", "b.com", "c.com"} {
+		if seen[d] != 100 {
+			t.Errorf("%s picked %d times, want 100", d, seen[d])
+		}
+	}
+}
+
+// TestPick_DeckKeepsProportionsWithoutRepeats verifies that deck selection
+// honours the weights closely over any stretch of picks and never returns
+// the same target twice in a row while none has more than half the weight.
+func TestPick_DeckKeepsProportionsWithoutRepeats(t *testing.T) {
+	targets := []config.TargetConfig{
+		makeTarget("https://a.com", 1, "http"),
+		makeTarget("https://b.com", 2, "http"),
+		makeTarget("https://c.com", 3, "http"),
+		makeTarget("https://d.com", 4, "http"),
+	}
+	sel, err := NewSelector(targets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sel.UseDeck()
+
+	const iterations = 10_000
+	counts := make(map[string]int, 4)
+	var prev string
+	for i := 0; i < iterations; i++ {
+		u := sel.Pick().URL
+		if u == prev {
+			t.Fatalf("pick %d: %s dealt twice in a row", i, u)
+		}
+		prev = u
+		counts[u]++
+	}
+	// Every full deck holds each target's exact share, to within a card,
+	// so the totals are off by at most the partly dealt last deck.
+	for i, tc := range targets {
+		want := iterations * (i + 1) / 10
+		if got := counts[tc.URL]; got < want-minDeck || got > want+minDeck {
+			t.Errorf("%s picked %d times, want %d ± %d", tc.URL, got, want, minDeck)
+		}
+	}
+}
+
+// TestPick_DeckHonoursDomainSpacing verifies that a deck deals around
+// domains inside the spacing window when it can.
+func TestPick_DeckHonoursDomainSpacing(t *testing.T) {
+	targets := []config.TargetConfig{
+		makeTarget("https://a.com/1", 1, "http"),
+		makeTarget("https://a.com/2", 1, "http"),
+		makeTarget("https://b.com/1", 1, "http"),
+		makeTarget("https://b.com/2", 1, "http"),
+	}
+	sel, err := NewSelector(targets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sel.UseDeck()
+	sel.SetDomainSpacing(time.Hour)
+
+	// Both domains are always recent, so each pick should go to the one
+	// picked longer ago; a deck can run out of a domain's cards before its
+	// end, repeating the other.
+	repeats := 0
+	var prev string
+	for i := 0; i < 400; i++ {
+		d := hostname(sel.Pick().URL)
+		if d == prev {
+			repeats++
+		}
+		prev = d
+	}
+	if repeats > 400/minDeck {
+		t.Errorf("%d domain repeats in 400 picks, want at most one per deck", repeats)
+	}
+}